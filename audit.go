@@ -0,0 +1,306 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AuditRecord is a record of a single invocation of a plugin.
+//
+// AuditRecords are written as lines of JSON to the audit log given by ClientWithAuditLog.
+type AuditRecord struct {
+	// Time is the time the invocation started.
+	Time time.Time `json:"time"`
+	// Program is the absolute path of the plugin binary.
+	//
+	// This is only set for Runners that run a program on disk, such as the Runners
+	// returned from NewExecRunner and NewExecServeRunner.
+	Program string `json:"program,omitempty"`
+	// ProgramSHA256 is the hex-encoded SHA-256 digest of the plugin binary.
+	//
+	// This is only set if Program is set.
+	ProgramSHA256 string `json:"program_sha256,omitempty"`
+	// Procedure is the path of the Procedure that was called.
+	//
+	// This is empty for invocations that do not call a Procedure, for example to
+	// retrieve the Spec.
+	Procedure string `json:"procedure,omitempty"`
+	// Args are the args the plugin was invoked with.
+	//
+	// The values of --metadata flags are masked, as metadata may contain secrets.
+	Args []string `json:"args"`
+	// Duration is the duration of the invocation, serialized in nanoseconds.
+	Duration time.Duration `json:"duration_ns"`
+	// Code is the code of the error that the invocation resulted in.
+	//
+	// This is empty if the invocation succeeded.
+	Code string `json:"code,omitempty"`
+	// ErrorDetails are the details of the error that the invocation resulted in, as JSON,
+	// see Error.Details.
+	//
+	// Details are redacted with the Redactor given by ClientWithRedactor. Details whose
+	// types are not registered in protoregistry.GlobalTypes cannot be redacted, and are
+	// omitted.
+	ErrorDetails []json.RawMessage `json:"error_details,omitempty"`
+	// ExitCode is the exit code of the plugin.
+	ExitCode int `json:"exit_code"`
+	// RequestBytes is the number of bytes sent to the plugin on stdin.
+	RequestBytes int64 `json:"request_bytes"`
+	// ResponseBytes is the number of bytes received from the plugin on stdout.
+	ResponseBytes int64 `json:"response_bytes"`
+}
+
+// *** PRIVATE ***
+
+// programRunner is implemented by Runners that run a program on disk.
+type programRunner interface {
+	// programPath returns the absolute path of the program.
+	programPath() (string, error)
+	// programArgs returns the args given to the program before the args of each call.
+	programArgs() []string
+	// programEnv returns the environment of the program as key=value pairs sorted by key.
+	programEnv() []string
+}
+
+func (e *execRunner) programPath() (string, error) {
+	return lookPathAbs(e.programName)
+}
+
+func (e *execRunner) programArgs() []string {
+	return e.programBaseArgs
+}
+
+func (e *execRunner) programEnv() []string {
+	return programEnv(e.env, e.inheritedEnvVars)
+}
+
+func (e *execServeRunner) programPath() (string, error) {
+	return lookPathAbs(e.programName)
+}
+
+func (e *execServeRunner) programArgs() []string {
+	return e.programBaseArgs
+}
+
+func (e *execServeRunner) programEnv() []string {
+	return programEnv(e.env, e.inheritedEnvVars)
+}
+
+// auditLog writes AuditRecords as lines of JSON.
+//
+// A nil *auditLog does nothing.
+type auditLog struct {
+	writer   io.Writer
+	runner   Runner
+	redactor Redactor
+	lock     sync.Mutex
+	// programDigests caches digests by program path. The digest is recomputed if
+	// the size or modification time of the program changes.
+	programDigests map[string]programDigest
+}
+
+type programDigest struct {
+	size    int64
+	modTime time.Time
+	sha256  string
+}
+
+func newAuditLog(writer io.Writer, runner Runner, redactor Redactor) *auditLog {
+	if writer == nil {
+		return nil
+	}
+	return &auditLog{
+		writer:         writer,
+		runner:         runner,
+		redactor:       redactor,
+		programDigests: make(map[string]programDigest),
+	}
+}
+
+// start starts recording an invocation.
+//
+// The returned Env must be used for the invocation, and finish must be called
+// with the result of the invocation.
+func (a *auditLog) start(procedurePath string, env Env) (*auditInvocation, Env) {
+	if a == nil {
+		return nil, env
+	}
+	env = env.withDefaults()
+	auditInvocation := &auditInvocation{
+		auditLog: a,
+		record: AuditRecord{
+			Time:      time.Now(),
+			Procedure: procedurePath,
+			Args:      maskMetadataArgs(env.Args),
+		},
+	}
+	env.Stdin = &countingReader{reader: env.Stdin, count: &auditInvocation.requestBytes}
+	env.Stdout = &countingWriter{writer: env.Stdout, count: &auditInvocation.responseBytes}
+	return auditInvocation, env
+}
+
+func (a *auditLog) write(record AuditRecord) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if record.Program != "" {
+		// The digest is best-effort, as the program may have been removed since
+		// the invocation.
+		record.ProgramSHA256, _ = a.programSHA256(record.Program)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// Write the record with a single write so that records are not interleaved
+	// when multiple processes append to the same file.
+	if _, err := a.writer.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// programPath returns the absolute path of the program run by the Runner, if any.
+func (a *auditLog) programPath() string {
+	programRunner, ok := a.runner.(programRunner)
+	if !ok {
+		return ""
+	}
+	programPath, err := programRunner.programPath()
+	if err != nil {
+		return ""
+	}
+	return programPath
+}
+
+// programSHA256 returns the digest of the program. Must be called with the lock held.
+func (a *auditLog) programSHA256(programPath string) (string, error) {
+	fileInfo, err := os.Stat(programPath)
+	if err != nil {
+		return "", err
+	}
+	if cached, ok := a.programDigests[programPath]; ok &&
+		cached.size == fileInfo.Size() && cached.modTime.Equal(fileInfo.ModTime()) {
+		return cached.sha256, nil
+	}
+	file, err := os.Open(programPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	a.programDigests[programPath] = programDigest{
+		size:    fileInfo.Size(),
+		modTime: fileInfo.ModTime(),
+		sha256:  digest,
+	}
+	return digest, nil
+}
+
+// auditInvocation is a single invocation being recorded.
+//
+// A nil *auditInvocation does nothing.
+type auditInvocation struct {
+	auditLog      *auditLog
+	record        AuditRecord
+	requestBytes  atomic.Int64
+	responseBytes atomic.Int64
+}
+
+// finish writes the record for the invocation given the error it resulted in.
+//
+// If the record cannot be written, an error is returned, joined with the given
+// error if any. Otherwise, the given error is returned.
+func (a *auditInvocation) finish(err error) error {
+	if a == nil {
+		return err
+	}
+	record := a.record
+	record.Duration = time.Since(record.Time)
+	record.RequestBytes = a.requestBytes.Load()
+	record.ResponseBytes = a.responseBytes.Load()
+	record.Program = a.auditLog.programPath()
+	if err != nil {
+		record.Code = WrapError(err).Code().String()
+		record.ErrorDetails = redactedErrorDetailsJSON(a.auditLog.redactor, err)
+		exitError := &ExitError{}
+		if errors.As(err, &exitError) {
+			record.ExitCode = exitError.ExitCode()
+		}
+	}
+	if writeErr := a.auditLog.write(record); writeErr != nil {
+		return errors.Join(err, writeErr)
+	}
+	return err
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+func (c *countingReader) Read(data []byte) (int, error) {
+	n, err := c.reader.Read(data)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	writer io.Writer
+	count  *atomic.Int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.writer.Write(data)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+// programEnv returns the environment for a program with the given environment variables
+// and the given environment variables inherited from the current process.
+//
+// Unlike newCmdEnv, this returns nil if there are no environment variables.
+func programEnv(env map[string]string, inheritedEnvVars []string) []string {
+	cmdEnv := newCmdEnv(env, inheritedEnvVars)
+	if slices.Equal(cmdEnv, emptyEnv) {
+		return nil
+	}
+	return cmdEnv
+}
+
+// lookPathAbs returns the absolute path of the program as resolved by os/exec.
+func lookPathAbs(programName string) (string, error) {
+	programPath, err := exec.LookPath(programName)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(programPath)
+}
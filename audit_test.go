@@ -0,0 +1,97 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestClientWithAuditLog(t *testing.T) {
+	t.Parallel()
+
+	auditLog := bytes.NewBuffer(nil)
+	client := pluginrpc.NewClient(
+		pluginrpc.NewExecRunner(echoPluginProgramName),
+		pluginrpc.ClientWithAuditLog(auditLog),
+	)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+	require.NoError(t, err)
+	_, err = echoServiceClient.EchoError(
+		context.Background(),
+		&examplev1.EchoErrorRequest{
+			Code:    pluginrpcv1.Code_CODE_NOT_FOUND,
+			Message: "foo",
+		},
+	)
+	require.Error(t, err)
+
+	var records []pluginrpc.AuditRecord
+	scanner := bufio.NewScanner(auditLog)
+	for scanner.Scan() {
+		record := pluginrpc.AuditRecord{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	// The protocol, the spec, and the two calls.
+	require.Len(t, records, 4)
+	require.Equal(t, []string{"--protocol"}, records[0].Args)
+	require.Empty(t, records[0].Procedure)
+	require.Equal(t, examplev1pluginrpc.EchoServiceEchoRequestPath, records[2].Procedure)
+	require.Empty(t, records[2].Code)
+	require.Positive(t, records[2].RequestBytes)
+	require.Positive(t, records[2].ResponseBytes)
+	require.Equal(t, examplev1pluginrpc.EchoServiceEchoErrorPath, records[3].Procedure)
+	require.Equal(t, pluginrpc.CodeNotFound.String(), records[3].Code)
+	require.Equal(t, 0, records[3].ExitCode)
+	for _, record := range records {
+		require.NotEmpty(t, record.Program)
+		require.Len(t, record.ProgramSHA256, 64)
+		require.False(t, record.Time.IsZero())
+		require.Positive(t, record.Duration)
+	}
+}
+
+func TestClientWithAuditLogWriteError(t *testing.T) {
+	t.Parallel()
+
+	server, err := newServer()
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(
+		pluginrpc.NewServerRunner(server),
+		pluginrpc.ClientWithAuditLog(errorWriter{}),
+	)
+	_, err = client.Spec(context.Background())
+	require.ErrorContains(t, err, "failed to write audit record")
+}
+
+type errorWriter struct{}
+
+func (errorWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
@@ -0,0 +1,381 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// BreakingRuleProcedureNoDelete says that Procedures must not be deleted or disabled.
+	//
+	// A Procedure that was renamed with ProcedureWithRenamedFrom is not deleted, as clients
+	// can still call it with its previous path and args.
+	BreakingRuleProcedureNoDelete BreakingRule = 1
+	// BreakingRuleProcedureSameArgs says that the args of Procedures must not change.
+	//
+	// Adding args to a Procedure without args does not break clients, as Procedures can
+	// always be invoked with their path.
+	BreakingRuleProcedureSameArgs BreakingRule = 2
+	// BreakingRuleProcedureSameInputType says that the request types of Procedures must not change.
+	//
+	// This is only checked if both Specs have descriptors, see SpecWithDescriptors.
+	BreakingRuleProcedureSameInputType BreakingRule = 3
+	// BreakingRuleProcedureSameOutputType says that the response types of Procedures must not change.
+	//
+	// This is only checked if both Specs have descriptors, see SpecWithDescriptors.
+	BreakingRuleProcedureSameOutputType BreakingRule = 4
+	// BreakingRuleProcedureSameStreaming says that Procedures must not change between unary
+	// and server-streaming.
+	//
+	// This is only checked if both Specs have descriptors, see SpecWithDescriptors.
+	BreakingRuleProcedureSameStreaming BreakingRule = 5
+
+	breakingRuleProcedureNoDeleteString       = "procedure_no_delete"
+	breakingRuleProcedureSameArgsString       = "procedure_same_args"
+	breakingRuleProcedureSameInputTypeString  = "procedure_same_input_type"
+	breakingRuleProcedureSameOutputTypeString = "procedure_same_output_type"
+	breakingRuleProcedureSameStreamingString  = "procedure_same_streaming"
+)
+
+var (
+	// AllBreakingRules are all BreakingRules.
+	AllBreakingRules = []BreakingRule{
+		BreakingRuleProcedureNoDelete,
+		BreakingRuleProcedureSameArgs,
+		BreakingRuleProcedureSameInputType,
+		BreakingRuleProcedureSameOutputType,
+		BreakingRuleProcedureSameStreaming,
+	}
+)
+
+// BreakingRule is a rule checked by CheckBreaking.
+type BreakingRule int
+
+// String implements fmt.Stringer.
+func (b BreakingRule) String() string {
+	switch b {
+	case BreakingRuleProcedureNoDelete:
+		return breakingRuleProcedureNoDeleteString
+	case BreakingRuleProcedureSameArgs:
+		return breakingRuleProcedureSameArgsString
+	case BreakingRuleProcedureSameInputType:
+		return breakingRuleProcedureSameInputTypeString
+	case BreakingRuleProcedureSameOutputType:
+		return breakingRuleProcedureSameOutputTypeString
+	case BreakingRuleProcedureSameStreaming:
+		return breakingRuleProcedureSameStreamingString
+	default:
+		return "breaking_rule_" + strconv.Itoa(int(b))
+	}
+}
+
+// BreakingRuleForString returns the BreakingRule for the given string, for example
+// "procedure_no_delete".
+//
+// Returns 0 if the BreakingRule is unknown or s is empty.
+func BreakingRuleForString(s string) BreakingRule {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, breakingRule := range AllBreakingRules {
+		if breakingRule.String() == s {
+			return breakingRule
+		}
+	}
+	return 0
+}
+
+// BreakingChange is a change between two Specs that breaks clients of the previous Spec.
+type BreakingChange struct {
+	// Rule is the BreakingRule that the change violates.
+	Rule BreakingRule
+	// Procedure is the path of the Procedure in the previous Spec.
+	Procedure string
+	// Message is a human-readable message describing the change.
+	Message string
+}
+
+// String implements fmt.Stringer.
+func (b BreakingChange) String() string {
+	return b.Message + " (" + b.Rule.String() + ")"
+}
+
+// CheckBreaking returns the changes from the previous Spec to the current Spec of a plugin
+// that break clients of the previous Spec.
+//
+// Unlike comparing the Specs, this only reports changes that break clients, for example
+// adding a Procedure or renaming a Procedure with ProcedureWithRenamedFrom does not break
+// clients. Only Procedures that are not disabled are checked. The changes are returned in
+// the order of the Procedures of the previous Spec.
+//
+// This is meant to be used in the CI of plugins, with the previous Spec read from a file
+// or from a released version of the plugin.
+//
+// Returns error if the descriptors of either Spec are invalid.
+func CheckBreaking(previous Spec, current Spec, options ...CheckBreakingOption) ([]BreakingChange, error) {
+	checkBreakingOptions := newCheckBreakingOptions()
+	for _, option := range options {
+		option(checkBreakingOptions)
+	}
+	previousFiles, err := newFilesForFileDescriptorSet(previous.FileDescriptorSet())
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptors of previous Spec: %w", err)
+	}
+	currentFiles, err := newFilesForFileDescriptorSet(current.FileDescriptorSet())
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptors of current Spec: %w", err)
+	}
+	isChecked := func(breakingRule BreakingRule) bool {
+		return slices.Contains(checkBreakingOptions.rules, breakingRule)
+	}
+	previousPathToCallableProcedure := getPathToCallableProcedure(previous)
+	currentPathToCallableProcedure := getPathToCallableProcedure(current)
+	var breakingChanges []BreakingChange
+	addBreakingChange := func(breakingRule BreakingRule, path string, format string, args ...any) {
+		breakingChanges = append(
+			breakingChanges,
+			BreakingChange{
+				Rule:      breakingRule,
+				Procedure: path,
+				Message:   fmt.Sprintf(format, args...),
+			},
+		)
+	}
+	for _, previousProcedure := range getCallableProcedures(previous) {
+		path := previousProcedure.procedure.Path()
+		if slices.Contains(checkBreakingOptions.ignorePaths, path) {
+			continue
+		}
+		currentProcedure, ok := currentPathToCallableProcedure[path]
+		if !ok {
+			if isChecked(BreakingRuleProcedureNoDelete) {
+				if renamedTo := getRenamedToPath(previousProcedure.procedure, previousPathToCallableProcedure, current); renamedTo != "" {
+					addBreakingChange(
+						BreakingRuleProcedureNoDelete,
+						path,
+						"procedure %q was deleted, it may have been renamed to %q without ProcedureWithRenamedFrom",
+						path,
+						renamedTo,
+					)
+				} else {
+					addBreakingChange(BreakingRuleProcedureNoDelete, path, "procedure %q was deleted", path)
+				}
+			}
+			continue
+		}
+		// Procedures can always be invoked with their path as the single arg, so only
+		// changing or removing custom args breaks clients.
+		previousArgs := previousProcedure.procedure.Args()
+		currentArgs := currentProcedure.procedure.Args()
+		if isChecked(BreakingRuleProcedureSameArgs) && len(previousArgs) > 0 && !slices.Equal(previousArgs, currentArgs) {
+			if len(currentArgs) == 0 {
+				currentArgs = []string{path}
+			}
+			addBreakingChange(
+				BreakingRuleProcedureSameArgs,
+				path,
+				"args of procedure %q changed from %q to %q",
+				path,
+				strings.Join(previousArgs, " "),
+				strings.Join(currentArgs, " "),
+			)
+		}
+		if previousFiles == nil || currentFiles == nil {
+			continue
+		}
+		// The methods are looked up by the paths of the Procedures that are renamed to,
+		// as a method does not exist for the paths of the Procedures renamed from.
+		previousMethod := getMethodDescriptorForPath(previousFiles, previousProcedure.methodPath)
+		currentMethod := getMethodDescriptorForPath(currentFiles, currentProcedure.methodPath)
+		if previousMethod == nil || currentMethod == nil {
+			continue
+		}
+		if isChecked(BreakingRuleProcedureSameInputType) && previousMethod.Input().FullName() != currentMethod.Input().FullName() {
+			addBreakingChange(
+				BreakingRuleProcedureSameInputType,
+				path,
+				"request type of procedure %q changed from %q to %q",
+				path,
+				previousMethod.Input().FullName(),
+				currentMethod.Input().FullName(),
+			)
+		}
+		if isChecked(BreakingRuleProcedureSameOutputType) && previousMethod.Output().FullName() != currentMethod.Output().FullName() {
+			addBreakingChange(
+				BreakingRuleProcedureSameOutputType,
+				path,
+				"response type of procedure %q changed from %q to %q",
+				path,
+				previousMethod.Output().FullName(),
+				currentMethod.Output().FullName(),
+			)
+		}
+		if isChecked(BreakingRuleProcedureSameStreaming) && previousMethod.IsStreamingServer() != currentMethod.IsStreamingServer() {
+			addBreakingChange(
+				BreakingRuleProcedureSameStreaming,
+				path,
+				"procedure %q changed from %s to %s",
+				path,
+				getStreamingString(previousMethod),
+				getStreamingString(currentMethod),
+			)
+		}
+	}
+	return breakingChanges, nil
+}
+
+// CheckBreakingOption is an option for CheckBreaking.
+type CheckBreakingOption func(*checkBreakingOptions)
+
+// CheckBreakingWithRules returns a new CheckBreakingOption that only checks the given
+// BreakingRules.
+//
+// The default is to check AllBreakingRules.
+func CheckBreakingWithRules(rules ...BreakingRule) CheckBreakingOption {
+	return func(checkBreakingOptions *checkBreakingOptions) {
+		checkBreakingOptions.rules = rules
+	}
+}
+
+// CheckBreakingWithIgnorePaths returns a new CheckBreakingOption that does not check the
+// Procedures with the given paths, for example Procedures that are intentionally removed.
+//
+// This option can be specified multiple times, in which case the paths are appended.
+func CheckBreakingWithIgnorePaths(paths ...string) CheckBreakingOption {
+	return func(checkBreakingOptions *checkBreakingOptions) {
+		checkBreakingOptions.ignorePaths = append(checkBreakingOptions.ignorePaths, paths...)
+	}
+}
+
+// *** PRIVATE ***
+
+type checkBreakingOptions struct {
+	rules       []BreakingRule
+	ignorePaths []string
+}
+
+func newCheckBreakingOptions() *checkBreakingOptions {
+	return &checkBreakingOptions{
+		rules: AllBreakingRules,
+	}
+}
+
+// callableProcedure is a Procedure that clients can call.
+type callableProcedure struct {
+	procedure Procedure
+	// methodPath is the path of the Procedure that procedure was renamed to, or the path
+	// of procedure if it was not renamed.
+	methodPath string
+}
+
+// getCallableProcedures returns the Procedures of the Spec that are not disabled, and the
+// Procedures that they were renamed from.
+func getCallableProcedures(spec Spec) []callableProcedure {
+	var callableProcedures []callableProcedure
+	for _, procedure := range spec.Procedures() {
+		if procedure.Disabled() {
+			continue
+		}
+		callableProcedures = append(callableProcedures, callableProcedure{procedure: procedure, methodPath: procedure.Path()})
+		for _, renamedFrom := range procedure.RenamedFrom() {
+			callableProcedures = append(callableProcedures, callableProcedure{procedure: renamedFrom, methodPath: procedure.Path()})
+		}
+	}
+	return callableProcedures
+}
+
+func getPathToCallableProcedure(spec Spec) map[string]callableProcedure {
+	pathToCallableProcedure := make(map[string]callableProcedure)
+	for _, callableProcedure := range getCallableProcedures(spec) {
+		pathToCallableProcedure[callableProcedure.procedure.Path()] = callableProcedure
+	}
+	return pathToCallableProcedure
+}
+
+// getRenamedToPath returns the path of a Procedure of the current Spec that the deleted
+// Procedure was likely renamed to, that is a Procedure that is new in the current Spec and
+// has the same custom args or the same method name as the deleted Procedure.
+//
+// Returns empty if there is no such Procedure.
+func getRenamedToPath(
+	deleted Procedure,
+	previousPathToCallableProcedure map[string]callableProcedure,
+	current Spec,
+) string {
+	deletedMethodName := getMethodName(deleted.Path())
+	for _, procedure := range current.Procedures() {
+		if procedure.Disabled() {
+			continue
+		}
+		if _, ok := previousPathToCallableProcedure[procedure.Path()]; ok {
+			continue
+		}
+		if len(deleted.Args()) > 0 && slices.Equal(deleted.Args(), procedure.Args()) {
+			return procedure.Path()
+		}
+		if getMethodName(procedure.Path()) == deletedMethodName {
+			return procedure.Path()
+		}
+	}
+	return ""
+}
+
+// getMethodName returns the last component of a path, for example "Method" for
+// /package.Service/Method.
+func getMethodName(path string) string {
+	return path[strings.LastIndexByte(path, '/')+1:]
+}
+
+// newFilesForFileDescriptorSet returns nil if fileDescriptorSet is nil.
+func newFilesForFileDescriptorSet(fileDescriptorSet *descriptorpb.FileDescriptorSet) (*protoregistry.Files, error) {
+	if fileDescriptorSet == nil {
+		return nil, nil
+	}
+	return protodesc.NewFiles(fileDescriptorSet)
+}
+
+// getMethodDescriptorForPath returns the MethodDescriptor for a path of the form
+// /package.Service/Method.
+//
+// Returns nil if no such method exists.
+func getMethodDescriptorForPath(files *protoregistry.Files, path string) protoreflect.MethodDescriptor {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return nil
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	return serviceDescriptor.Methods().ByName(protoreflect.Name(methodName))
+}
+
+func getStreamingString(methodDescriptor protoreflect.MethodDescriptor) string {
+	if methodDescriptor.IsStreamingServer() {
+		return "server-streaming"
+	}
+	return "unary"
+}
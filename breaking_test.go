@@ -0,0 +1,164 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+)
+
+const (
+	testEchoRequestPath = "/pluginrpc.example.v1.EchoService/EchoRequest"
+	testEchoListPath    = "/pluginrpc.example.v1.EchoService/EchoList"
+	testEchoErrorPath   = "/pluginrpc.example.v1.EchoService/EchoError"
+)
+
+func TestCheckBreakingProcedures(t *testing.T) {
+	t.Parallel()
+
+	previous := newTestBreakingSpec(
+		t,
+		nil,
+		newTestProcedure(t, testEchoRequestPath, ProcedureWithArgs("echo", "request")),
+		newTestProcedure(t, testEchoListPath),
+		newTestProcedure(t, testEchoErrorPath),
+		newTestProcedure(t, "/foo.Bar/Baz"),
+		newTestProcedure(t, "/foo.Bar/Quux"),
+	)
+	current := newTestBreakingSpec(
+		t,
+		nil,
+		newTestProcedure(t, testEchoRequestPath, ProcedureWithArgs("echo", "req")),
+		newTestProcedure(t, testEchoListPath, ProcedureWithDisabled()),
+		newTestProcedure(t, "/foo.Bar/Qux", ProcedureWithRenamedFrom("/foo.Bar/Baz")),
+		newTestProcedure(t, "/foo.Bar/Quux", ProcedureWithArgs("quux")),
+	)
+	breakingChanges, err := CheckBreaking(previous, current)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]BreakingChange{
+			{
+				Rule:      BreakingRuleProcedureSameArgs,
+				Procedure: testEchoRequestPath,
+				Message:   `args of procedure "/pluginrpc.example.v1.EchoService/EchoRequest" changed from "echo request" to "echo req"`,
+			},
+			{
+				Rule:      BreakingRuleProcedureNoDelete,
+				Procedure: testEchoListPath,
+				Message:   `procedure "/pluginrpc.example.v1.EchoService/EchoList" was deleted`,
+			},
+			{
+				Rule:      BreakingRuleProcedureNoDelete,
+				Procedure: testEchoErrorPath,
+				Message:   `procedure "/pluginrpc.example.v1.EchoService/EchoError" was deleted`,
+			},
+		},
+		breakingChanges,
+	)
+
+	breakingChanges, err = CheckBreaking(
+		previous,
+		current,
+		CheckBreakingWithRules(BreakingRuleProcedureSameArgs),
+		CheckBreakingWithIgnorePaths(testEchoRequestPath),
+	)
+	require.NoError(t, err)
+	require.Empty(t, breakingChanges)
+
+	breakingChanges, err = CheckBreaking(previous, previous)
+	require.NoError(t, err)
+	require.Empty(t, breakingChanges)
+}
+
+func TestCheckBreakingDescriptors(t *testing.T) {
+	t.Parallel()
+
+	previousFileDescriptorSet := newFileDescriptorSet(nil, []protoreflect.FileDescriptor{examplev1.File_pluginrpc_example_v1_example_proto})
+	currentFileDescriptorSet := proto.Clone(previousFileDescriptorSet).(*descriptorpb.FileDescriptorSet)
+	for _, file := range currentFileDescriptorSet.GetFile() {
+		for _, service := range file.GetService() {
+			for _, method := range service.GetMethod() {
+				switch method.GetName() {
+				case "EchoRequest":
+					method.InputType = proto.String(".pluginrpc.example.v1.EchoListRequest")
+				case "EchoList":
+					method.OutputType = proto.String(".pluginrpc.example.v1.EchoRequestResponse")
+					method.ServerStreaming = proto.Bool(true)
+				}
+			}
+		}
+	}
+	_, err := protodesc.NewFiles(currentFileDescriptorSet)
+	require.NoError(t, err)
+	procedures := []Procedure{
+		newTestProcedure(t, testEchoRequestPath),
+		newTestProcedure(t, testEchoListPath),
+	}
+	previous := newTestBreakingSpec(t, previousFileDescriptorSet, procedures...)
+	current := newTestBreakingSpec(t, currentFileDescriptorSet, procedures...)
+	breakingChanges, err := CheckBreaking(previous, current)
+	require.NoError(t, err)
+	rules := make([]BreakingRule, len(breakingChanges))
+	for i, breakingChange := range breakingChanges {
+		rules[i] = breakingChange.Rule
+	}
+	require.Equal(
+		t,
+		[]BreakingRule{
+			BreakingRuleProcedureSameInputType,
+			BreakingRuleProcedureSameOutputType,
+			BreakingRuleProcedureSameStreaming,
+		},
+		rules,
+	)
+	require.Equal(
+		t,
+		`procedure "/pluginrpc.example.v1.EchoService/EchoList" changed from unary to server-streaming (procedure_same_streaming)`,
+		breakingChanges[2].String(),
+	)
+
+	// Types are not checked without descriptors for both Specs.
+	breakingChanges, err = CheckBreaking(previous, newTestBreakingSpec(t, nil, procedures...))
+	require.NoError(t, err)
+	require.Empty(t, breakingChanges)
+}
+
+func TestBreakingRuleForString(t *testing.T) {
+	t.Parallel()
+
+	for _, breakingRule := range AllBreakingRules {
+		require.Equal(t, breakingRule, BreakingRuleForString(breakingRule.String()))
+	}
+	require.Equal(t, BreakingRule(0), BreakingRuleForString("unknown"))
+}
+
+func newTestProcedure(t *testing.T, path string, options ...ProcedureOption) Procedure {
+	procedure, err := NewProcedure(path, options...)
+	require.NoError(t, err)
+	return procedure
+}
+
+func newTestBreakingSpec(t *testing.T, fileDescriptorSet *descriptorpb.FileDescriptorSet, procedures ...Procedure) Spec {
+	spec, err := NewSpecWithOptions(procedures, SpecWithFileDescriptorSet(fileDescriptorSet))
+	require.NoError(t, err)
+	return spec
+}
@@ -0,0 +1,120 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// resourceBudgetPollInterval is the interval at which the resource usage of a
+// process is checked against its budget.
+const resourceBudgetPollInterval = 10 * time.Millisecond
+
+// resourceBudget is the maximum resources a single invocation of a plugin may use.
+//
+// Zero values mean no limit.
+type resourceBudget struct {
+	maxMemoryBytes uint64
+	maxCPUTime     time.Duration
+}
+
+type resourceBudgetContextKey struct{}
+
+func withResourceBudget(ctx context.Context, budget resourceBudget) context.Context {
+	if budget.isZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, resourceBudgetContextKey{}, budget)
+}
+
+func resourceBudgetFromContext(ctx context.Context) (resourceBudget, bool) {
+	budget, ok := ctx.Value(resourceBudgetContextKey{}).(resourceBudget)
+	return budget, ok
+}
+
+func (r resourceBudget) isZero() bool {
+	return r.maxMemoryBytes == 0 && r.maxCPUTime == 0
+}
+
+// check returns an error if the process has exceeded the budget.
+func (r resourceBudget) check(pid int) error {
+	if r.maxMemoryBytes > 0 {
+		if rssBytes, ok := processRSSBytes(pid); ok && rssBytes > r.maxMemoryBytes {
+			return r.newMemoryExceededError()
+		}
+	}
+	if r.maxCPUTime > 0 {
+		if cpuTime, ok := processCPUTime(pid); ok && cpuTime > r.maxCPUTime {
+			return r.newCPUTimeExceededError()
+		}
+	}
+	return nil
+}
+
+func (r resourceBudget) newMemoryExceededError() error {
+	return NewError(CodeResourceExhausted, fmt.Errorf("plugin exceeded memory budget of %d bytes", r.maxMemoryBytes))
+}
+
+func (r resourceBudget) newCPUTimeExceededError() error {
+	return NewError(CodeResourceExhausted, fmt.Errorf("plugin exceeded CPU time budget of %v", r.maxCPUTime))
+}
+
+// runWithResourceBudget runs the command, killing it if it exceeds the budget.
+//
+// If the budget is exceeded, an *ExitError wrapping an *Error with CodeResourceExhausted
+// is returned. Otherwise, the error from cmd.Wait is returned.
+func runWithResourceBudget(cmd *exec.Cmd, budget resourceBudget) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	var budgetErr error
+	var wg sync.WaitGroup
+	doneC := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(resourceBudgetPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-doneC:
+				return
+			case <-ticker.C:
+				if err := budget.check(cmd.Process.Pid); err != nil {
+					budgetErr = err
+					_ = cmd.Process.Kill()
+					return
+				}
+			}
+		}
+	}()
+	err := cmd.Wait()
+	close(doneC)
+	wg.Wait()
+	if budgetErr != nil {
+		return NewExitError(exitCodeInternal, budgetErr)
+	}
+	// The CPU time is only polled on some platforms, so also check the final CPU time.
+	if budget.maxCPUTime > 0 && cmd.ProcessState != nil {
+		if cmd.ProcessState.UserTime()+cmd.ProcessState.SystemTime() > budget.maxCPUTime {
+			return NewExitError(exitCodeInternal, budget.newCPUTimeExceededError())
+		}
+	}
+	return err
+}
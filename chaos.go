@@ -0,0 +1,216 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// NewChaosRunner returns a new Runner that wraps the given Runner and injects faults
+// into invocations.
+//
+// This is used to test the error handling of hosts against misbehaving plugins. Each
+// fault is injected independently with its given probability for every invocation.
+// Faults are injected in the order delay, exit code, garbage stdout, and truncated stdout.
+// If no faults are specified, the Runner behaves as the given Runner.
+//
+// Faults that modify stdout buffer stdout until the plugin exits when they are injected,
+// so streaming responses will not be received until the plugin exits.
+func NewChaosRunner(runner Runner, options ...ChaosRunnerOption) Runner {
+	return newChaosRunner(runner, options...)
+}
+
+// ChaosRunnerOption is an option for a new chaos Runner.
+type ChaosRunnerOption func(*chaosRunnerOptions)
+
+// ChaosRunnerWithDelay returns a new ChaosRunnerOption that delays invocations by the
+// given duration with the given probability.
+//
+// The delay is cut short if the context is done.
+func ChaosRunnerWithDelay(delay time.Duration, probability float64) ChaosRunnerOption {
+	return func(chaosRunnerOptions *chaosRunnerOptions) {
+		chaosRunnerOptions.delay = delay
+		chaosRunnerOptions.delayProbability = probability
+	}
+}
+
+// ChaosRunnerWithExitCode returns a new ChaosRunnerOption that fails invocations with
+// an *ExitError with the given exit code with the given probability, without running
+// the plugin.
+func ChaosRunnerWithExitCode(exitCode int, probability float64) ChaosRunnerOption {
+	return func(chaosRunnerOptions *chaosRunnerOptions) {
+		chaosRunnerOptions.exitCode = exitCode
+		chaosRunnerOptions.exitCodeProbability = probability
+	}
+}
+
+// ChaosRunnerWithGarbageStdout returns a new ChaosRunnerOption that replaces the stdout
+// of invocations with random bytes of the same length with the given probability.
+//
+// If the plugin wrote nothing to stdout, a single random byte is written instead.
+func ChaosRunnerWithGarbageStdout(probability float64) ChaosRunnerOption {
+	return func(chaosRunnerOptions *chaosRunnerOptions) {
+		chaosRunnerOptions.garbageStdoutProbability = probability
+	}
+}
+
+// ChaosRunnerWithTruncatedStdout returns a new ChaosRunnerOption that truncates the stdout
+// of invocations to a random shorter length with the given probability.
+func ChaosRunnerWithTruncatedStdout(probability float64) ChaosRunnerOption {
+	return func(chaosRunnerOptions *chaosRunnerOptions) {
+		chaosRunnerOptions.truncatedStdoutProbability = probability
+	}
+}
+
+// ChaosRunnerWithFilter returns a new ChaosRunnerOption that only injects faults into
+// invocations whose args match the given filter.
+//
+// For example, this can be used to only inject faults into calls to a specific Procedure,
+// and not into retrieving the Spec.
+//
+// The default is to inject faults into all invocations.
+func ChaosRunnerWithFilter(filter func(args []string) bool) ChaosRunnerOption {
+	return func(chaosRunnerOptions *chaosRunnerOptions) {
+		chaosRunnerOptions.filter = filter
+	}
+}
+
+// ChaosRunnerWithSeed returns a new ChaosRunnerOption that seeds the source of randomness
+// used to decide which faults to inject, making faults reproducible.
+//
+// The default is to seed from the current time.
+func ChaosRunnerWithSeed(seed int64) ChaosRunnerOption {
+	return func(chaosRunnerOptions *chaosRunnerOptions) {
+		chaosRunnerOptions.seed = &seed
+	}
+}
+
+// *** PRIVATE ***
+
+type chaosRunner struct {
+	runner  Runner
+	options *chaosRunnerOptions
+	rand    *rand.Rand
+	// lock guards rand.
+	lock sync.Mutex
+}
+
+func newChaosRunner(runner Runner, options ...ChaosRunnerOption) *chaosRunner {
+	chaosRunnerOptions := newChaosRunnerOptions()
+	for _, option := range options {
+		option(chaosRunnerOptions)
+	}
+	seed := time.Now().UnixNano()
+	if chaosRunnerOptions.seed != nil {
+		seed = *chaosRunnerOptions.seed
+	}
+	return &chaosRunner{
+		runner:  runner,
+		options: chaosRunnerOptions,
+		rand:    rand.New(rand.NewSource(seed)), //nolint:gosec // faults do not need cryptographic randomness
+	}
+}
+
+func (c *chaosRunner) Run(ctx context.Context, env Env) error {
+	if c.options.filter != nil && !c.options.filter(env.Args) {
+		return c.runner.Run(ctx, env)
+	}
+	env = env.withDefaults()
+	if c.inject(c.options.delayProbability) {
+		timer := time.NewTimer(c.options.delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	if c.inject(c.options.exitCodeProbability) {
+		return NewExitError(c.options.exitCode, fmt.Errorf("chaos: injected exit code %d", c.options.exitCode))
+	}
+	garbageStdout := c.inject(c.options.garbageStdoutProbability)
+	truncatedStdout := c.inject(c.options.truncatedStdoutProbability)
+	if !garbageStdout && !truncatedStdout {
+		return c.runner.Run(ctx, env)
+	}
+	stdout := env.Stdout
+	buffer := bytes.NewBuffer(nil)
+	env.Stdout = buffer
+	runErr := c.runner.Run(ctx, env)
+	data := buffer.Bytes()
+	if garbageStdout {
+		data = c.garbage(len(data))
+	}
+	if truncatedStdout {
+		data = data[:c.intn(len(data))]
+	}
+	if _, err := stdout.Write(data); err != nil {
+		return errors.Join(runErr, err)
+	}
+	return runErr
+}
+
+// inject returns true if a fault with the given probability should be injected.
+func (c *chaosRunner) inject(probability float64) bool {
+	if probability <= 0 {
+		return false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.rand.Float64() < probability
+}
+
+// intn returns a random int in [0, n), or 0 if n is 0.
+func (c *chaosRunner) intn(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.rand.Intn(n)
+}
+
+// garbage returns random bytes of the given length, or of length 1 if length is 0.
+func (c *chaosRunner) garbage(length int) []byte {
+	if length == 0 {
+		length = 1
+	}
+	data := make([]byte, length)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, _ = c.rand.Read(data)
+	return data
+}
+
+type chaosRunnerOptions struct {
+	delay                      time.Duration
+	delayProbability           float64
+	exitCode                   int
+	exitCodeProbability        float64
+	garbageStdoutProbability   float64
+	truncatedStdoutProbability float64
+	filter                     func([]string) bool
+	seed                       *int64
+}
+
+func newChaosRunnerOptions() *chaosRunnerOptions {
+	return &chaosRunnerOptions{}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestChaosRunner(t *testing.T) {
+	t.Parallel()
+
+	// EchoRequest is invoked with the args "echo request".
+	onlyEchoRequest := pluginrpc.ChaosRunnerWithFilter(
+		func(args []string) bool {
+			return len(args) >= 2 && slices.Equal(args[:2], []string{"echo", "request"})
+		},
+	)
+	testCases := []struct {
+		name    string
+		options []pluginrpc.ChaosRunnerOption
+		check   func(*testing.T, error)
+	}{
+		{
+			name: "none",
+			check: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:    "exit_code",
+			options: []pluginrpc.ChaosRunnerOption{pluginrpc.ChaosRunnerWithExitCode(3, 1)},
+			check: func(t *testing.T, err error) {
+				exitError := &pluginrpc.ExitError{}
+				require.ErrorAs(t, err, &exitError)
+				require.Equal(t, 3, exitError.ExitCode())
+			},
+		},
+		{
+			name:    "garbage_stdout",
+			options: []pluginrpc.ChaosRunnerOption{pluginrpc.ChaosRunnerWithGarbageStdout(1), onlyEchoRequest},
+			check: func(t *testing.T, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			name:    "truncated_stdout",
+			options: []pluginrpc.ChaosRunnerOption{pluginrpc.ChaosRunnerWithTruncatedStdout(1), onlyEchoRequest},
+			check: func(t *testing.T, err error) {
+				require.Error(t, err)
+			},
+		},
+		{
+			name:    "delay",
+			options: []pluginrpc.ChaosRunnerOption{pluginrpc.ChaosRunnerWithDelay(time.Hour, 1), onlyEchoRequest},
+			check: func(t *testing.T, err error) {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+			},
+		},
+		{
+			name:    "zero_probability",
+			options: []pluginrpc.ChaosRunnerOption{pluginrpc.ChaosRunnerWithExitCode(3, 0)},
+			check: func(t *testing.T, err error) {
+				require.NoError(t, err)
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(
+			testCase.name,
+			func(t *testing.T) {
+				t.Parallel()
+				server, err := newServer()
+				require.NoError(t, err)
+				runner := pluginrpc.NewChaosRunner(
+					pluginrpc.NewServerRunner(server),
+					append(testCase.options, pluginrpc.ChaosRunnerWithSeed(1))...,
+				)
+				echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner))
+				require.NoError(t, err)
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+				_, err = echoServiceClient.EchoRequest(ctx, &examplev1.EchoRequestRequest{Message: "hello"})
+				testCase.check(t, err)
+			},
+		)
+	}
+}
@@ -17,11 +17,17 @@ package pluginrpc
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
 )
 
 var (
@@ -36,9 +42,25 @@ type Client interface {
 	// Spec returns the Spec that the client receives.
 	//
 	// Clients will cache retrieved protocols and Specs. If it is possible that a plugin will
-	// change during the lifetime of a Client, it is the responsibility of the caller to
-	// create a new Client. We may change this requirement in the future.
+	// change during the lifetime of a Client, call InvalidateSpec, or create a new Client.
 	Spec(ctx context.Context) (Spec, error)
+	// InvalidateSpec invalidates the cached Spec, including any entry in the on-disk cache
+	// given with ClientWithSpecCache, so that the next call gets the Spec from the plugin.
+	//
+	// If the Spec was given with ClientWithSpec, this has no effect.
+	InvalidateSpec()
+	// Info returns the Info that the client receives.
+	//
+	// Clients will cache retrieved Infos in the same manner as Specs. If the plugin
+	// does not support the --info flag, an error is returned.
+	Info(ctx context.Context) (Info, error)
+	// Ping verifies that the plugin can be run, speaks a supported protocol version, and
+	// responds to calls, by calling the health check Procedure, see HealthCheckPath.
+	//
+	// This does not require the Spec, and is not cached. This is useful to validate plugins
+	// when a host starts, and for watchdogs of long-lived plugins. Plugins built with
+	// versions of this package that do not serve the health check Procedure return an error.
+	Ping(ctx context.Context) error
 	// Call calls the given Procedure.
 	//
 	// The request will be sent over stdin, with a response being sent on stdout.
@@ -50,6 +72,33 @@ type Client interface {
 		response any,
 		options ...CallOption,
 	) error
+	// CallServerStream calls the given server-streaming Procedure.
+	//
+	// The request will be sent over stdin, with responses being sent on stdout as a
+	// sequence of frames. For every response, newResponse is called to create a value to
+	// populate, and onResponse is called with the populated value as soon as it is received.
+	//
+	// If onResponse returns an error, the plugin is stopped and the error is returned.
+	CallServerStream(
+		ctx context.Context,
+		procedurePath string,
+		request any,
+		newResponse func() any,
+		onResponse func(any) error,
+		options ...CallOption,
+	) error
+	// CallBidiStream calls the given bidirectional streaming Procedure.
+	//
+	// Requests are sent over stdin as a sequence of frames as they are sent on the
+	// returned BidiStream, with responses being sent on stdout as a sequence of frames.
+	//
+	// The Runner must support streaming stdin while the plugin is running, which
+	// is the case for all Runners provided by this package.
+	CallBidiStream(
+		ctx context.Context,
+		procedurePath string,
+		options ...CallOption,
+	) (BidiStream, error)
 
 	isClient()
 }
@@ -81,19 +130,571 @@ func ClientWithFormat(format Format) ClientOption {
 	}
 }
 
+// ClientWithFormatFallback will result in the client retrying invocations of the plugin
+// with FormatJSON if the plugin does not support FormatBinary, and using FormatJSON for all
+// further invocations.
+//
+// The plugin is considered to not support FormatBinary if it exits with an error that
+// refers to --format on stderr, for example because it does not know the flag or the
+// value, or if it responds with JSON. This improves interoperability with older plugins,
+// and plugins implemented in other languages that only support JSON.
+//
+// Only the discovery of the Spec and unary calls are retried, streaming calls use the
+// Format that was negotiated by previous invocations. Unary calls with a request payload
+// are not retried, as payloads cannot be read twice, see CallWithRequestPayload.
+//
+// This only applies if the Format of the client is FormatBinary.
+// The default is to not fall back.
+func ClientWithFormatFallback() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.formatFallback = true
+	}
+}
+
+// ClientWithSpecCompression will result in the client requesting a compressed
+// Spec from the plugin by specifying --compress alongside --spec.
+//
+// The plugin must support the --compress flag. This is useful for plugins with
+// large Specs. The decompressed Spec is bounded, see ClientWithDecompressionLimits.
+//
+// The default is to not request compression.
+func ClientWithSpecCompression() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.specCompression = true
+	}
+}
+
+// ClientWithDecompressionLimits will result in the client failing when compressed data
+// from the plugin decompresses to more than the given number of bytes, or to more than
+// the given multiple of the size of the compressed data.
+//
+// This protects clients of untrusted plugins from zip bombs, where a small compressed
+// payload decompresses to an amount of data that exhausts memory. This applies to
+// compressed Specs and responses, see ClientWithSpecCompression and ClientWithCompression.
+//
+// If a limit is exceeded, the call fails with CodeResourceExhausted. The default is a
+// maximum of 64 MiB, and no maximum ratio. A value that is not positive results in the
+// default being used.
+func ClientWithDecompressionLimits(maxBytes int64, maxRatio int64) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.maxDecompressedBytes = maxBytes
+		clientOptions.maxDecompressionRatio = maxRatio
+	}
+}
+
+// ClientWithCompression will result in the client compressing requests with the given
+// Compression, and asking the plugin to compress responses with it by specifying
+// --compression, if the plugin supports the Compression.
+//
+// The Compressions that the plugin supports are requested with the Spec by specifying
+// --compressions alongside --spec, so the plugin must support the --compressions flag. If
+// the Spec of the plugin does not include the Compression, for example because it was
+// given with ClientWithSpec, calls are not compressed. This applies to unary calls and the
+// requests of server-streaming calls. Responses are decompressed within the limits given
+// by ClientWithDecompressionLimits.
+//
+// This is useful for plugins that exchange large requests or responses, such as descriptor
+// sets or file contents.
+//
+// The default is to not compress requests and responses.
+func ClientWithCompression(compression Compression) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.compression = compression
+	}
+}
+
+// ClientWithMaxResponseSize will result in the client stopping the plugin and failing the
+// call with CodeResourceExhausted when the plugin writes more than the given number of
+// bytes to stdout.
+//
+// This protects clients of untrusted plugins from running out of memory, as responses
+// are buffered in memory. For streaming calls, the limit applies to each response. This
+// applies to calls, and not to the Spec, Info, or protocol version of the plugin.
+//
+// The default is no limit.
+func ClientWithMaxResponseSize(maxResponseSize int64) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.maxResponseSize = maxResponseSize
+	}
+}
+
+// ClientWithStdoutNoiseTolerance will result in the client skipping noise that precedes
+// the output of the plugin on stdout, such as a UTF-8 byte order mark or lines written by
+// a wrapper script, logging a warning to the logger given with ClientWithLogger.
+//
+// Without this option, such noise results in an error that describes the unexpected
+// prefix. Noise is only looked for if the output cannot be decoded, and is looked for on
+// whole lines. Noise is reliably detected with FormatJSON, and with FormatBinary if the
+// plugin writes the binary header, see ClientWithBinaryHeader. This applies to unary calls,
+// the Spec, the Info, and the protocol version of the plugin, and not to streaming calls.
+//
+// The default is to not tolerate noise.
+func ClientWithStdoutNoiseTolerance() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.stdoutNoiseTolerance = true
+	}
+}
+
+// ClientWithSpecDescriptors will result in the client requesting the descriptors of
+// the plugin with the Spec by specifying --descriptors alongside --spec.
+//
+// The plugin must support the --descriptors flag. The descriptors are available from
+// the FileDescriptorSet of the Spec returned by Client.Spec, if the plugin was built
+// with SpecWithDescriptors.
+//
+// The default is to not request descriptors.
+func ClientWithSpecDescriptors() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.specDescriptors = true
+	}
+}
+
+// ClientWithSpecDocs will result in the client requesting the documentation of the
+// Procedures of the plugin with the Spec by specifying --docs alongside --spec.
+//
+// The plugin must support the --docs flag. The documentation is available from the Doc
+// of each Procedure of the Spec returned by Client.Spec, see ProcedureWithDoc.
+//
+// The default is to not request documentation.
+func ClientWithSpecDocs() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.specDocs = true
+	}
+}
+
+// ClientWithSpecVersion will result in the client requesting the version of the plugin
+// with the Spec by specifying --version alongside --spec.
+//
+// The plugin must support the --version flag. The version is available from the Version
+// of the Spec returned by Client.Spec, see ServerWithVersion.
+//
+// The default is to not request the version.
+func ClientWithSpecVersion() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.specVersion = true
+	}
+}
+
+// ClientWithErrorDetails will result in the client requesting error details, such as
+// retry hints, from the plugin by specifying --error-details when calling Procedures.
+//
+// The plugin must support the --error-details flag. Retry hints are available on
+// returned errors via Error.RetryAfter.
+//
+// The default is to not request error details.
+func ClientWithErrorDetails() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.errorDetails = true
+	}
+}
+
+// ClientWithLocale will result in the client preferring messages in the given locale
+// for errors returned from plugins, available via Error.LocalizedMessage.
+//
+// The locale should be a BCP 47 language tag, for example "en-US". This implies
+// ClientWithErrorDetails, as localized messages are sent as error details.
+//
+// The default is to not have a preferred locale.
+func ClientWithLocale(locale string) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.locale = locale
+		clientOptions.errorDetails = true
+	}
+}
+
+// ClientWithBinaryHeader will result in the client prefixing binary-format requests
+// with a magic header, allowing the plugin to detect payloads that are not from
+// pluginrpc and produce a helpful error. Plugins respond with the header when the
+// request has it.
+//
+// The plugin must support the binary header. This has no effect with FormatJSON.
+//
+// The default is to not send the binary header.
+func ClientWithBinaryHeader() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.binaryHeader = true
+	}
+}
+
+// ClientWithReplayProtection will result in the client sending a random nonce and the
+// current timestamp with every call, allowing plugins to reject replays of requests
+// to replay-protected Procedures.
+//
+// The plugin must support the --nonce and --timestamp flags. To retry a call safely,
+// use CallWithNonce to specify the same nonce for each attempt.
+//
+// The default is to not send nonces unless CallWithNonce is specified.
+func ClientWithReplayProtection() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.replayProtection = true
+	}
+}
+
+// ClientWithDeadlinePropagation will result in the client sending the time remaining
+// until the deadline of the context of a call, if any, to the plugin by specifying
+// --timeout when calling Procedures.
+//
+// The plugin must support the --timeout flag. Handlers will then be called with a
+// context with the corresponding deadline, and can return CodeDeadlineExceeded
+// themselves instead of being killed when the context of the call is done.
+//
+// The default is to not send the deadline.
+func ClientWithDeadlinePropagation() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.deadlinePropagation = true
+	}
+}
+
+// ClientWithWarningHandler returns a new ClientOption that calls the given function for
+// every warning a plugin sends about a call, for example when calling a deprecated
+// Procedure or using a deprecated Format. See ProcedureWithDeprecation and
+// ServerWithDeprecatedFormat.
+//
+// This allows hosts to surface warnings in their own diagnostics systems instead of
+// plugins writing them to stderr. The function may be called concurrently for concurrent
+// calls, and is called before the call returns.
+//
+// The plugin must support the --warnings flag, which was added to pluginrpc-go together
+// with this option. The default is to not request warnings.
+func ClientWithWarningHandler(handleWarning func(Warning)) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.warningHandler = handleWarning
+	}
+}
+
+// ClientWithMaxConcurrentProcesses will result in the client running at most the given
+// number of plugin invocations at once, including invocations to get the protocol version
+// and Spec. Calls beyond the limit wait until an invocation completes or their context is done,
+// and are run in order of priority, see CallWithPriority.
+//
+// Streaming calls hold their invocation until the stream is complete. When used with a
+// ServeRunner, this bounds the number of concurrent calls to the long-lived plugin process,
+// which avoids the cost of starting a process per call altogether.
+//
+// The default is to not limit the number of concurrent invocations.
+func ClientWithMaxConcurrentProcesses(maxConcurrentProcesses int) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.maxConcurrentProcesses = maxConcurrentProcesses
+	}
+}
+
+// ClientWithSpec will result in the client using the given Spec instead of getting the
+// Spec from the plugin, for example a Spec built with a generated SpecBuilder.
+//
+// The client never invokes the plugin with --protocol or --spec, so each call is a single
+// invocation of the plugin. The Spec must match the Spec of the plugin, otherwise
+// calls may invoke the wrong arguments.
+//
+// The default is to get the Spec from the plugin.
+func ClientWithSpec(spec Spec) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.spec = spec
+	}
+}
+
+// ClientWithSpecCache will result in the client caching Specs on disk in the given directory,
+// so that new clients for the same plugin do not need to invoke the plugin to get its Spec.
+//
+// Entries are keyed by the path, size, and modification time of the program, and the args
+// given with ExecRunnerWithArgs, so a rebuilt plugin results in a cache miss. If a plugin
+// may change its Spec without being rebuilt, for example based on its environment, this
+// should not be used. The cache is only used with Runners created with NewExecRunner or
+// NewExecServeRunner, and errors reading or writing the cache are ignored.
+//
+// The default is to not cache Specs on disk.
+func ClientWithSpecCache(dirPath string) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.specCacheDirPath = dirPath
+	}
+}
+
+// ClientWithRetry will result in calls made with Call being attempted up to the given
+// number of times if they fail with a transient error.
+//
+// Errors reported by the plugin with CodeUnavailable or CodeAborted, and errors where the
+// plugin could not be run, for example due to file locks or resource exhaustion, are
+// retried with exponential backoff, see RetryOption. If the plugin gave a hint with
+// ErrorWithRetryAfter, the hint is used as the backoff. Retries stop when the context
+// of the call is done.
+//
+// Interceptors given with ClientWithInterceptors see each call once, regardless of the
+// number of attempts. Streaming calls are not retried.
+//
+// The default is to not retry calls.
+func ClientWithRetry(maxAttempts int, options ...RetryOption) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.retryMaxAttempts = maxAttempts
+		clientOptions.retryOptions = options
+	}
+}
+
+// ClientWithCombinedHandshake will result in the client getting the protocol version and
+// the Spec of the plugin with a single invocation of the plugin, specifying both --protocol
+// and --spec, instead of an invocation for each.
+//
+// If the plugin does not support this, the client falls back to separate invocations.
+//
+// The default is to use separate invocations.
+func ClientWithCombinedHandshake() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.combinedHandshake = true
+	}
+}
+
 // CallOption is an option for an individual client call.
 type CallOption func(*callOptions)
 
+// CallWithNonce returns a new CallOption that sends the given nonce and the current
+// timestamp with the call.
+//
+// Plugins will reject requests to replay-protected Procedures with a nonce that was
+// already received with CodeAlreadyExists. Specify the same nonce when retrying a call
+// to prevent duplicate execution. The nonce must be at most 128 printable ASCII characters.
+func CallWithNonce(nonce string) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.nonce = nonce
+	}
+}
+
+// CallWithMetadata returns a new CallOption that sends the given request metadata with
+// the call, available to handlers via MetadataFromContext.
+//
+// The plugin must support the --metadata flag. Keys must not be empty or contain "=".
+// This option can be specified multiple times, in which case the metadata is merged.
+//
+// The metadata is passed as --metadata flags. Unless the plugin is run with
+// NewExecServeRunner, which sends the flags on stdin, they are on the command line of the
+// plugin, where they are visible to other processes on the same machine, for example in
+// process listings. Prefer NewExecServeRunner, optionally with ExecRunnerWithSessionMetadata,
+// to send secrets such as credentials. The values are masked in audit records, logs, debug
+// dumps, and the commands returned from ReproCommandOf.
+func CallWithMetadata(metadata map[string]string) CallOption {
+	return func(callOptions *callOptions) {
+		if callOptions.metadata == nil {
+			callOptions.metadata = make(map[string]string, len(metadata))
+		}
+		for key, value := range metadata {
+			callOptions.metadata[key] = value
+		}
+	}
+}
+
+// CallWithResponseMetadata returns a new CallOption that requests response metadata set
+// by the handler with SetResponseMetadata, and adds it to the given map once the call
+// completes, including if the call returns an error.
+//
+// The plugin must support the --response-metadata flag. Response metadata is only
+// available for unary calls made with Call.
+func CallWithResponseMetadata(responseMetadata map[string]string) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.responseMetadata = responseMetadata
+	}
+}
+
+// CallWithMaxMemoryBytes returns a new CallOption that kills the plugin if its resident
+// set size exceeds the given number of bytes, in which case the call returns an error
+// wrapping an *Error with CodeResourceExhausted.
+//
+// Memory budgets are only enforced by Runners created with NewExecRunner on Linux, and
+// are ignored otherwise. Memory usage is sampled, so short spikes may not be detected.
+func CallWithMaxMemoryBytes(maxMemoryBytes uint64) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.resourceBudget.maxMemoryBytes = maxMemoryBytes
+	}
+}
+
+// CallWithMaxCPUTime returns a new CallOption that kills the plugin if its user and system
+// CPU time exceeds the given duration, in which case the call returns an error wrapping
+// an *Error with CodeResourceExhausted.
+//
+// CPU time budgets are only enforced by Runners created with NewExecRunner, and are
+// ignored otherwise. On platforms other than Linux, the CPU time is only checked once
+// the plugin exits.
+func CallWithMaxCPUTime(maxCPUTime time.Duration) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.resourceBudget.maxCPUTime = maxCPUTime
+	}
+}
+
+// CallWithPriority returns a new CallOption that specifies the priority of the call.
+//
+// Calls waiting to be run are run in order of priority, with calls with a higher priority
+// run first, so that for example interactive calls are run before background bulk calls.
+// Calls wait if ClientWithMaxConcurrentProcesses is specified, or if the plugin is run by a
+// ServeRunner and bounds the number of calls it handles at once, see
+// ServerWithSessionConcurrency. Calls that are already running are not preempted.
+//
+// The default priority is zero. Priorities may be negative.
+func CallWithPriority(priority int32) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.priority = priority
+	}
+}
+
+// CallWithTimeout returns a new CallOption that cancels the call if it does not complete
+// within the given duration, in which case the call returns an error wrapping an *Error
+// with CodeDeadlineExceeded.
+//
+// Runners created with NewExecRunner terminate the plugin when a call is canceled, and
+// kill it if it does not exit within a grace period, see
+// ExecRunnerWithTerminationGracePeriod. If ClientWithRetry is used, the timeout
+// applies to each attempt. The timeout does not apply to CallBidiStream, use the context
+// given to CallBidiStream instead.
+//
+// The default is to not time out calls other than by the context of the call.
+func CallWithTimeout(timeout time.Duration) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.timeout = timeout
+	}
+}
+
+// ClientWithEnvDefaults will result in the client honoring the FormatEnvVarName and
+// ProtocolEnvVarName environment variables of the current process, read when the client
+// is created.
+//
+// If FormatEnvVarName is set, it is used as the Format unless ClientWithFormat is
+// specified. If ProtocolEnvVarName is set, the plugin is assumed to support the given
+// protocol version, and is not asked for its protocol version. This is useful for
+// debugging sessions, for example to switch to FormatJSON without editing the host.
+//
+// To honor the same environment variables in the plugin, pass them through with
+// ExecRunnerWithInheritedEnvVars and use ServerWithEnvDefaults.
+//
+// If an environment variable has an invalid value, calls return an error.
+//
+// The default is to ignore these environment variables.
+func ClientWithEnvDefaults() ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.envDefaults = true
+	}
+}
+
+// ClientWithDebugWriter will result in the client dumping the args, stdin, stdout, and
+// exit code of every invocation of the plugin to the given writer once the invocation
+// completes.
+//
+// The request and response envelopes are dumped as they are sent and received, as text
+// if they are printable, and as a hex dump otherwise. This is meant for diagnosing
+// interoperability issues with plugins, for example plugins written in other languages.
+//
+// The values of requests and responses are redacted with the Redactor given by
+// ClientWithRedactor. Redacted envelopes are dumped uncompressed and without the binary
+// header. Envelopes that cannot be decoded, for example because the types of their values
+// are not registered in protoregistry.GlobalTypes, cannot be redacted, and only their
+// size is dumped.
+//
+// For Runners created with NewExecRunner and NewExecServeRunner, every dump also contains
+// a shell command that reproduces the invocation, see ReproCommandOf.
+//
+// The default is to not dump invocations.
+func ClientWithDebugWriter(debugWriter io.Writer) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.debugWriter = debugWriter
+	}
+}
+
+// ClientWithLogger will result in the client logging the start and end of every
+// invocation of the plugin at debug level to the given logger, including the Procedure,
+// args, format, duration, exit code, and error, if any.
+//
+// Invocations to retrieve the Spec or Info of the plugin are logged without a Procedure.
+//
+// The default is to not log.
+func ClientWithLogger(logger *slog.Logger) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.logger = logger
+	}
+}
+
+// ClientWithAuditLog will result in the client writing an AuditRecord for every
+// invocation of the plugin to the given writer, as a line of JSON.
+//
+// Every record is written with a single call to Write, so an *os.File opened with
+// os.O_APPEND can be used as an append-only audit log shared by multiple processes.
+// If a record cannot be written, the call fails.
+//
+// The default is to not write an audit log.
+func ClientWithAuditLog(auditLog io.Writer) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.auditLog = auditLog
+	}
+}
+
+// ClientWithRedactor will result in the client redacting requests, responses, and error
+// details with the given Redactor before they are written anywhere outside of the call
+// itself, that is the logger given by ClientWithLogger, the audit log given by
+// ClientWithAuditLog, the dumps written to the writer given by ClientWithDebugWriter,
+// and the commands returned from ReproCommandOf.
+//
+// The default is NewRedactor(), which redacts fields with the debug_redact field option.
+func ClientWithRedactor(redactor Redactor) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.redactor = redactor
+	}
+}
+
+// ClientWithInterceptors will result in every call made with Call being wrapped
+// with the given interceptors.
+//
+// The first interceptor is the outermost. This option can be specified multiple
+// times, in which case the interceptors are appended.
+func ClientWithInterceptors(interceptors ...ClientInterceptor) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.interceptors = append(clientOptions.interceptors, interceptors...)
+	}
+}
+
 // *** PRIVATE ***
 
 type client struct {
 	runner Runner
 	stderr io.Writer
 	format Format
+	// formatFallback is nil if the client does not fall back to FormatJSON.
+	formatFallback  *formatFallback
+	specCompression bool
+	compression     Compression
+	specDescriptors bool
+	specDocs        bool
+	specVersion     bool
+	errorDetails    bool
+	locale          string
+	// maxDecompressedBytes and maxDecompressionRatio bound decompressed data from the plugin.
+	maxDecompressedBytes  int64
+	maxDecompressionRatio int64
+	maxResponseSize       int64
+	stdoutNoiseTolerance  bool
+	binaryHeader          bool
+	replayProtection      bool
+	deadlinePropagation   bool
+	combinedHandshake     bool
+	// envProtocolVersion is the protocol version from ProtocolEnvVarName, if set.
+	envProtocolVersion int
+	// envDefaultsErr is the error from reading the environment defaults, if any.
+	envDefaultsErr error
+	auditLog       *auditLog
+	callLogger     *callLogger
+	// programRunner is the Runner given to NewClient if it runs a program on disk.
+	//
+	// This is used for ReproCommandOf.
+	programRunner programRunner
+	redactor      Redactor
+	specCache     *specCache
+	// configuredSpec is the Spec given with ClientWithSpec, if any.
+	configuredSpec Spec
+	retryPolicy    *retryPolicy
+	warningHandler func(Warning)
+	hostServices   Server
+	// callFunc is the intercepted version of call.
+	callFunc CallFunc
 
 	spec    Spec
 	specErr error
 	lock    sync.RWMutex
+
+	info     Info
+	infoErr  error
+	infoLock sync.RWMutex
 }
 
 func newClient(
@@ -107,22 +708,69 @@ func newClient(
 	if clientOptions.stderr == nil {
 		clientOptions.stderr = defaultStderr
 	}
+	var envDefaults envDefaults
+	var envDefaultsErr error
+	if clientOptions.envDefaults {
+		envDefaults, envDefaultsErr = getEnvDefaults()
+		if clientOptions.format == 0 {
+			clientOptions.format = envDefaults.format
+		}
+	}
 	if clientOptions.format == 0 {
 		clientOptions.format = FormatBinary
 	}
-	return &client{
-		runner: runner,
-		stderr: clientOptions.stderr,
-		format: clientOptions.format,
+	if clientOptions.maxDecompressedBytes <= 0 {
+		clientOptions.maxDecompressedBytes = defaultMaxDecompressedBytes
+	}
+	if clientOptions.redactor == nil {
+		clientOptions.redactor = NewRedactor()
 	}
+	auditLog := newAuditLog(clientOptions.auditLog, runner, clientOptions.redactor)
+	programRunner, _ := runner.(programRunner)
+	specCache := newSpecCache(clientOptions.specCacheDirPath, runner)
+	if clientOptions.debugWriter != nil {
+		runner = newDebugRunner(runner, clientOptions.debugWriter, clientOptions.redactor)
+	}
+	if clientOptions.maxConcurrentProcesses > 0 {
+		runner = newConcurrencyLimitedRunner(runner, clientOptions.maxConcurrentProcesses)
+	}
+	client := &client{
+		runner:                runner,
+		stderr:                clientOptions.stderr,
+		format:                clientOptions.format,
+		formatFallback:        newFormatFallback(clientOptions.formatFallback),
+		specCompression:       clientOptions.specCompression,
+		compression:           clientOptions.compression,
+		specDescriptors:       clientOptions.specDescriptors,
+		specDocs:              clientOptions.specDocs,
+		specVersion:           clientOptions.specVersion,
+		errorDetails:          clientOptions.errorDetails,
+		locale:                clientOptions.locale,
+		maxDecompressedBytes:  clientOptions.maxDecompressedBytes,
+		maxDecompressionRatio: clientOptions.maxDecompressionRatio,
+		maxResponseSize:       clientOptions.maxResponseSize,
+		stdoutNoiseTolerance:  clientOptions.stdoutNoiseTolerance,
+		binaryHeader:          clientOptions.binaryHeader,
+		replayProtection:      clientOptions.replayProtection,
+		deadlinePropagation:   clientOptions.deadlinePropagation,
+		combinedHandshake:     clientOptions.combinedHandshake,
+		envProtocolVersion:    envDefaults.protocolVersion,
+		envDefaultsErr:        envDefaultsErr,
+		auditLog:              auditLog,
+		callLogger:            newCallLogger(clientOptions.logger, clientOptions.format, clientOptions.redactor),
+		programRunner:         programRunner,
+		redactor:              clientOptions.redactor,
+		specCache:             specCache,
+		configuredSpec:        clientOptions.spec,
+		retryPolicy:           newRetryPolicy(clientOptions.retryMaxAttempts, clientOptions.retryOptions...),
+		warningHandler:        clientOptions.warningHandler,
+		hostServices:          clientOptions.hostServices,
+		spec:                  clientOptions.spec,
+	}
+	client.callFunc = chainClientInterceptors(client.retryCallFunc(client.call), clientOptions.interceptors)
+	return client
 }
 
-// TODO: Provide ability for Spec to be invalidated via cache invalidate.
-//
-// One way this could look: A request sends over a "spec ID", which is an ID that is returned when
-// getting a spec from a plugin. If the plugin does not currently match this spec ID, an error
-// is returned on the response, and the client invalidates the Spec cache, and retries. This will
-// be desirable for situations where clients are long-lived, for example in services.
 func (c *client) Spec(ctx context.Context) (Spec, error) {
 	// Difficult to use sync.OnceValues since we want to use the context for cancellation
 	// when passing to the runner. It's awkward if the client constructor took a conteext.
@@ -143,107 +791,640 @@ func (c *client) Spec(ctx context.Context) (Spec, error) {
 	return c.spec, c.specErr
 }
 
+func (c *client) InvalidateSpec() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.configuredSpec != nil {
+		return
+	}
+	c.spec = nil
+	c.specErr = nil
+	c.specCache.remove()
+}
+
+// clearSpecErr clears the error from getting the Spec, if any, so that the Spec is
+// retrieved again on the next call.
+func (c *client) clearSpecErr() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.specErr != nil {
+		c.spec = c.configuredSpec
+		c.specErr = nil
+	}
+}
+
+func (c *client) Info(ctx context.Context) (Info, error) {
+	c.infoLock.RLock()
+	if c.info != nil || c.infoErr != nil {
+		c.infoLock.RUnlock()
+		return c.info, c.infoErr
+	}
+	c.infoLock.RUnlock()
+
+	c.infoLock.Lock()
+	defer c.infoLock.Unlock()
+
+	if c.info != nil || c.infoErr != nil {
+		return c.info, c.infoErr
+	}
+	c.info, c.infoErr = c.getInfoUncached(ctx)
+	return c.info, c.infoErr
+}
+
 func (c *client) Call(
 	ctx context.Context,
 	procedurePath string,
 	request any,
 	response any,
-	_ ...CallOption,
+	options ...CallOption,
 ) error {
+	return c.callFunc(ctx, procedurePath, request, response, options...)
+}
+
+func (c *client) CallServerStream(
+	ctx context.Context,
+	procedurePath string,
+	request any,
+	newResponse func() any,
+	onResponse func(any) error,
+	options ...CallOption,
+) (retErr error) {
+	callOptions := newCallOptions()
+	for _, option := range options {
+		option(callOptions)
+	}
+	if callOptions.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOptions.timeout)
+		defer cancel()
+	}
+	args, stdinData, format, err := c.prepareCall(ctx, procedurePath, request, callOptions)
+	if err != nil {
+		return withErrorSource(err, ErrorSourceMarshal)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// The error from the last frame, if any.
+	var streamErr error
+	// The error returned from onResponse, if any.
+	var onResponseErr error
+	stdout := newFrameWriter(
+		func(frame []byte) error {
+			if streamErr != nil {
+				return errors.New("received frame after error")
+			}
+			response := newResponse()
+			if err := unmarshalResponseWithMetadata(format, frame, response, nil, c.protoWarningHandler(procedurePath)); err != nil {
+				pluginrpcError := &Error{}
+				if errors.As(err, &pluginrpcError) {
+					streamErr = err
+					return nil
+				}
+				return withErrorSource(err, ErrorSourceDecode)
+			}
+			if err := onResponse(response); err != nil {
+				onResponseErr = err
+				// Stop the plugin, as we will not process any further responses.
+				cancel()
+				return err
+			}
+			return nil
+		},
+	).withMaxSize(c.maxResponseFrameSize(), cancel)
+	loggedCall := c.callLogger.start(ctx, procedurePath, args)
+	auditInvocation, env := c.auditLog.start(
+		procedurePath,
+		Env{
+			Args:   args,
+			Stdin:  bytes.NewReader(stdinData),
+			Stdout: stdout,
+			Stderr: c.stderr,
+		},
+	)
+	defer func() {
+		loggedCall.finish(retErr)
+		retErr = auditInvocation.finish(retErr)
+	}()
+	runErr := c.runner.Run(c.runContext(ctx, callOptions), env)
+	if onResponseErr != nil {
+		return onResponseErr
+	}
+	if stdout.exceededErr != nil {
+		return withReproCommand(withErrorSource(stdout.exceededErr, ErrorSourceDecode), c.programRunner, c.redactor, args, stdinData)
+	}
+	if runErr != nil {
+		return withReproCommand(wrapRunError(ctx, runErr), c.programRunner, c.redactor, args, stdinData)
+	}
+	if err := stdout.Close(); err != nil {
+		return withReproCommand(withErrorSource(err, ErrorSourceDecode), c.programRunner, c.redactor, args, stdinData)
+	}
+	return withReproCommand(withErrorSource(c.localizeError(streamErr), ErrorSourcePlugin), c.programRunner, c.redactor, args, stdinData)
+}
+
+func (c *client) CallBidiStream(
+	ctx context.Context,
+	procedurePath string,
+	options ...CallOption,
+) (BidiStream, error) {
+	callOptions := newCallOptions()
+	for _, option := range options {
+		option(callOptions)
+	}
+	args, _, format, err := c.prepareCall(ctx, procedurePath, nil, callOptions)
+	if err != nil {
+		return nil, withErrorSource(err, ErrorSourceMarshal)
+	}
+	return newBidiStream(c.runContext(ctx, callOptions), c.runner, format, procedurePath, args, c.maxResponseFrameSize(), c.stderr, c.auditLog, c.callLogger, c.localizeError, c.protoWarningHandler(procedurePath)), nil
+}
+
+func (*client) isClient() {}
+
+// runContext returns the context to run the plugin with for a call.
+func (c *client) runContext(ctx context.Context, callOptions *callOptions) context.Context {
+	hostServices := callOptions.hostServices
+	if hostServices == nil {
+		hostServices = c.hostServices
+	}
+	return withHostServices(withCallPriority(withResourceBudget(ctx, callOptions.resourceBudget), callOptions.priority), hostServices)
+}
+
+// maxResponseFrameSize returns the maximum size of each response of a streaming call.
+func (c *client) maxResponseFrameSize() uint32 {
+	if c.maxResponseSize > 0 && c.maxResponseSize < maxFrameSize {
+		return uint32(c.maxResponseSize)
+	}
+	return maxFrameSize
+}
+
+// call calls the Procedure without interceptors.
+//
+// If the plugin does not support the Format of the client, the call is retried with
+// FormatJSON, see ClientWithFormatFallback. Calls with a request payload are not retried,
+// as payloads cannot be read twice, but further calls use FormatJSON.
+func (c *client) call(
+	ctx context.Context,
+	procedurePath string,
+	request any,
+	response any,
+	options ...CallOption,
+) error {
+	err := c.callOnce(ctx, procedurePath, request, response, options...)
+	if isFormatFallbackError(err) {
+		callOptions := newCallOptions()
+		for _, option := range options {
+			option(callOptions)
+		}
+		if callOptions.requestPayload != nil {
+			return err
+		}
+		resetResponse(response)
+		return c.callOnce(ctx, procedurePath, request, response, options...)
+	}
+	return err
+}
+
+// callOnce calls the Procedure with a single invocation of the plugin.
+func (c *client) callOnce(
+	ctx context.Context,
+	procedurePath string,
+	request any,
+	response any,
+	options ...CallOption,
+) (retErr error) {
+	callOptions := newCallOptions()
+	for _, option := range options {
+		option(callOptions)
+	}
+	if callOptions.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOptions.timeout)
+		defer cancel()
+	}
+	args, stdinData, format, err := c.prepareCall(ctx, procedurePath, request, callOptions)
+	if err != nil {
+		return withErrorSource(err, ErrorSourceMarshal)
+	}
+	// Stop the plugin if the response exceeds the maximum size.
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	stdout := newLimitedBuffer(c.maxResponseSize, cancelRun)
+	var stdin io.Reader = bytes.NewReader(stdinData)
+	var stdoutWriter io.Writer = stdout
+	// With payloads, stdin and stdout consist of frames, see callPayloads.
+	var framedStdout *framedStdout
+	if callOptions.requestPayload != nil || callOptions.responsePayload != nil {
+		args = append(args, "--"+PayloadFlagName)
+		stdin = newPayloadFramer(stdinData, callOptions.requestPayload)
+		framedStdout = newFramedStdout(callOptions.responsePayload, c.maxResponseFrameSize(), cancelRun)
+		stdoutWriter = framedStdout
+	}
+	stderr, checkFormatFallback := c.formatFallback.start(format, c.stderr)
+	loggedCall := c.callLogger.start(ctx, procedurePath, args)
+	auditInvocation, env := c.auditLog.start(
+		procedurePath,
+		Env{
+			Args:   args,
+			Stdin:  stdin,
+			Stdout: stdoutWriter,
+			Stderr: stderr,
+		},
+	)
+	defer func() {
+		loggedCall.finish(retErr)
+		retErr = auditInvocation.finish(retErr)
+	}()
+	runErr := c.runner.Run(c.runContext(runCtx, callOptions), env)
+	if exceededErr := stdout.exceededErr(); exceededErr != nil {
+		return withReproCommand(withErrorSource(exceededErr, ErrorSourceDecode), c.programRunner, c.redactor, args, stdinData)
+	}
+	if framedStdout != nil && framedStdout.failedErr != nil {
+		return withReproCommand(withErrorSource(framedStdout.failedErr, ErrorSourceDecode), c.programRunner, c.redactor, args, stdinData)
+	}
+	if runErr != nil {
+		return checkFormatFallback(withReproCommand(wrapRunError(ctx, runErr), c.programRunner, c.redactor, args, stdinData), stdout.Bytes())
+	}
+	stdoutData := stdout.Bytes()
+	if framedStdout != nil {
+		stdoutData, err = framedStdout.responseData()
+		if err != nil {
+			return withReproCommand(withErrorSource(err, ErrorSourceDecode), c.programRunner, c.redactor, args, stdinData)
+		}
+	}
+	data, _, err := decompressEnvelope(stdoutData, c.maxDecompressedBytes, c.maxDecompressionRatio)
+	if err != nil {
+		return withReproCommand(
+			withErrorSource(fmt.Errorf("plugin stdout is not a properly-compressed pluginrpc response: %w", err), ErrorSourceDecode),
+			c.programRunner,
+			c.redactor,
+			args,
+			stdinData,
+		)
+	}
+	return checkFormatFallback(
+		withReproCommand(
+			withResponseErrorSource(c.localizeError(c.unmarshalStdoutResponse(ctx, format, data, response, callOptions.responseMetadata, c.protoWarningHandler(procedurePath)))),
+			c.programRunner,
+			c.redactor,
+			args,
+			stdinData,
+		),
+		stdoutData,
+	)
+}
+
+// wrapRunError wraps the error from running the plugin for a call.
+//
+// If the context is done, the plugin was stopped because of the context, so an error
+// for the context is returned instead of the exit error of the plugin.
+func wrapRunError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return withErrorSource(WrapError(ctxErr), ErrorSourceTransport)
+	}
+	return withErrorSource(WrapExitError(err), errorSourceForRunError(err))
+}
+
+// prepareCall returns the args and stdin data for a call to the Procedure, and the Format
+// of the call.
+func (c *client) prepareCall(
+	ctx context.Context,
+	procedurePath string,
+	request any,
+	callOptions *callOptions,
+) ([]string, []byte, Format, error) {
+	if c.envDefaultsErr != nil {
+		return nil, nil, 0, c.envDefaultsErr
+	}
 	// Could make the constructor return an error and validate this at construction
 	// but it seems like a bad ROI for such a simple check.
 	if err := validateFormat(c.format); err != nil {
-		return err
+		return nil, nil, 0, err
 	}
 	spec, err := c.Spec(ctx)
 	if err != nil {
-		return err
+		return nil, nil, 0, err
 	}
+	// The Format may have changed while getting the Spec, see ClientWithFormatFallback.
+	format := c.formatFallback.format(c.format)
 	procedure := spec.ProcedureForPath(procedurePath)
 	if procedure == nil {
-		return NewErrorf(CodeUnimplemented, "procedure unimplemented: %q", procedurePath)
+		return nil, nil, 0, NewErrorf(CodeUnimplemented, "procedure unimplemented: %q", procedurePath)
 	}
-	data, err := marshalRequest(c.format, request)
+	data, err := marshalRequest(format, request)
 	if err != nil {
-		return err
+		return nil, nil, 0, err
+	}
+	// Only compress if the plugin supports the Compression, see ClientWithCompression.
+	compression := c.compression
+	if !slices.Contains(spec.Compressions(), compression) {
+		compression = 0
+	}
+	switch {
+	case compression != 0 && len(data) > 0:
+		data, err = compressEnvelope(compression, data)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	case c.binaryHeader && format == FormatBinary:
+		data = addBinaryHeader(data)
 	}
-	stdin := bytes.NewReader(data)
-	stdout := bytes.NewBuffer(nil)
 	args := procedure.Args()
 	if len(args) == 0 {
 		args = []string{procedure.Path()}
 	}
-	args = append(args, "--"+FormatFlagName, c.format.String())
-	if err := c.runner.Run(
-		ctx,
-		Env{
-			Args:   args,
-			Stdin:  stdin,
-			Stdout: stdout,
-			Stderr: c.stderr,
-		},
-	); err != nil {
-		return WrapExitError(err)
+	args = append(args, "--"+FormatFlagName, format.String())
+	if compression != 0 {
+		args = append(args, "--"+CompressionFlagName, compression.String())
+	}
+	if c.errorDetails {
+		args = append(args, "--"+ErrorDetailsFlagName)
+	}
+	nonce := callOptions.nonce
+	if nonce == "" && c.replayProtection {
+		nonce, err = newNonce()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	if nonce != "" {
+		if err := validateNonce(nonce); err != nil {
+			return nil, nil, 0, err
+		}
+		args = append(
+			args,
+			"--"+NonceFlagName, nonce,
+			"--"+TimestampFlagName, time.Now().UTC().Format(time.RFC3339Nano),
+		)
+	}
+	metadataKeys := make([]string, 0, len(callOptions.metadata))
+	for key := range callOptions.metadata {
+		metadataKeys = append(metadataKeys, key)
+	}
+	sort.Strings(metadataKeys)
+	for _, key := range metadataKeys {
+		if err := validateMetadataKey(key); err != nil {
+			return nil, nil, 0, err
+		}
+		args = append(args, "--"+MetadataFlagName, key+"="+callOptions.metadata[key])
+	}
+	if callOptions.responseMetadata != nil {
+		args = append(args, "--"+ResponseMetadataFlagName)
 	}
-	return unmarshalResponse(c.format, stdout.Bytes(), response)
+	if c.warningHandler != nil {
+		args = append(args, "--"+WarningsFlagName)
+	}
+	if c.deadlinePropagation {
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout := time.Until(deadline)
+			if timeout <= 0 {
+				return nil, nil, 0, NewError(CodeDeadlineExceeded, context.DeadlineExceeded)
+			}
+			args = append(args, "--"+TimeoutFlagName, timeout.String())
+		}
+	}
+	return args, data, format, nil
 }
 
-func (*client) isClient() {}
+// protoWarningHandler returns a function that calls the warning handler of the client for
+// warnings about a call to the given Procedure, or nil if the client has no warning handler.
+func (c *client) protoWarningHandler(procedurePath string) func(*extv1.Warning) {
+	if c.warningHandler == nil {
+		return nil
+	}
+	return func(protoWarning *extv1.Warning) {
+		c.warningHandler(newWarning(procedurePath, protoWarning))
+	}
+}
+
+// localizeError applies the preferred locale of the client to the error if it is an Error.
+func (c *client) localizeError(err error) error {
+	pluginrpcError := &Error{}
+	if err != nil && c.locale != "" && errors.As(err, &pluginrpcError) {
+		return pluginrpcError.withLocale(c.locale)
+	}
+	return err
+}
 
 func (c *client) getSpecUncached(ctx context.Context) (Spec, error) {
+	// A cached Spec without descriptors or a version may have been stored by a client
+	// that did not request them.
+	if spec, ok := c.specCache.load(); ok && (!c.specDescriptors || spec.FileDescriptorSet() != nil) && (!c.specVersion || spec.Version() != "") {
+		return spec, nil
+	}
+	spec, err := c.getSpecFromPlugin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.specCache.store(spec)
+	return spec, nil
+}
+
+// getSpecFromPlugin gets the Spec by invoking the plugin.
+func (c *client) getSpecFromPlugin(ctx context.Context) (Spec, error) {
+	if c.envDefaultsErr != nil {
+		return nil, c.envDefaultsErr
+	}
+	// If the protocol version was given by ProtocolEnvVarName, it is not checked.
+	if c.combinedHandshake && c.envProtocolVersion == 0 {
+		if spec, err := c.getSpecUncachedForArgs(ctx, "--"+ProtocolFlagName); err == nil {
+			return spec, nil
+		}
+		// The plugin may not support --protocol and --spec together, fall back to
+		// separate invocations.
+	}
 	if err := c.checkProtocolVersion(ctx); err != nil {
 		return nil, err
 	}
+	return c.getSpecUncachedForArgs(ctx)
+}
+
+// getSpecUncachedForArgs gets the Spec with the given additional args.
+//
+// If --protocol is one of the args, the protocol version is expected on the first line
+// of the output, and is checked. If the plugin does not support the Format of the client,
+// the Spec is retried with FormatJSON, see ClientWithFormatFallback.
+func (c *client) getSpecUncachedForArgs(ctx context.Context, additionalArgs ...string) (Spec, error) {
+	spec, err := c.getSpecUncachedForFormat(ctx, c.formatFallback.format(c.format), additionalArgs...)
+	if isFormatFallbackError(err) {
+		return c.getSpecUncachedForFormat(ctx, c.formatFallback.format(c.format), additionalArgs...)
+	}
+	return spec, err
+}
+
+// getSpecUncachedForFormat gets the Spec in the given Format with the given additional args.
+func (c *client) getSpecUncachedForFormat(ctx context.Context, format Format, additionalArgs ...string) (_ Spec, retErr error) {
+	args := []string{"--" + SpecFlagName, "--" + FormatFlagName, format.String()}
+	if c.specCompression {
+		args = append(args, "--"+CompressFlagName)
+	}
+	if c.specDescriptors {
+		args = append(args, "--"+DescriptorsFlagName)
+	}
+	if c.specDocs {
+		args = append(args, "--"+DocsFlagName)
+	}
+	if c.specVersion {
+		args = append(args, "--"+VersionFlagName)
+	}
+	if c.compression != 0 {
+		args = append(args, "--"+CompressionsFlagName)
+	}
+	args = append(args, additionalArgs...)
 	stdout := bytes.NewBuffer(nil)
-	if err := c.runner.Run(
-		ctx,
+	stderr, checkFormatFallback := c.formatFallback.start(format, c.stderr)
+	loggedCall := c.callLogger.start(ctx, "", args)
+	auditInvocation, env := c.auditLog.start(
+		"",
 		Env{
-			Args:   []string{"--" + SpecFlagName, "--" + FormatFlagName, c.format.String()},
+			Args:   args,
 			Stdout: stdout,
-			Stderr: c.stderr,
+			Stderr: stderr,
 		},
-	); err != nil {
-		return nil, err
+	)
+	defer func() {
+		loggedCall.finish(retErr)
+		retErr = auditInvocation.finish(retErr)
+	}()
+	if err := c.runner.Run(ctx, env); err != nil {
+		return nil, checkFormatFallback(withErrorSource(err, errorSourceForRunError(err)), stdout.Bytes())
+	}
+	// All other errors are from the output of the plugin.
+	defer func() {
+		retErr = checkFormatFallback(withErrorSource(retErr, ErrorSourceDecode), stdout.Bytes())
+	}()
+	return decodeStdout(
+		ctx,
+		c,
+		stdout.Bytes(),
+		func(data []byte) (Spec, error) {
+			return c.parseSpec(format, data, additionalArgs)
+		},
+	)
+}
+
+// parseSpec parses the Spec in the given Format from the output of the plugin for the
+// given additional args.
+func (c *client) parseSpec(format Format, data []byte, additionalArgs []string) (Spec, error) {
+	if slices.Contains(additionalArgs, "--"+ProtocolFlagName) {
+		protocolData, specData, ok := bytes.Cut(data, []byte("\n"))
+		if !ok {
+			return nil, fmt.Errorf("--%s with --%s did not return a protocol version followed by a spec", ProtocolFlagName, SpecFlagName)
+		}
+		version, err := unmarshalProtocol(protocolData)
+		if err != nil {
+			return nil, fmt.Errorf("--%s did not return a properly-formed protocol version: %w", ProtocolFlagName, err)
+		}
+		if version != protocolVersion {
+			return nil, fmt.Errorf("--%s returned unknown protocol version %d", ProtocolFlagName, version)
+		}
+		data = specData
+	}
+	data, err := decompressSpec(data, c.maxDecompressedBytes, c.maxDecompressionRatio)
+	if err != nil {
+		return nil, fmt.Errorf("--%s did not return a properly-compressed spec: %w", SpecFlagName, err)
 	}
-	data := stdout.Bytes()
 	if len(data) == 0 {
 		return nil, fmt.Errorf("--%s did not return a spec", SpecFlagName)
 	}
+	if c.specDescriptors || c.specDocs || c.specVersion || c.compression != 0 {
+		extProtoSpec := &extv1.Spec{}
+		if err := unmarshalSpec(format, data, extProtoSpec); err != nil {
+			return nil, fmt.Errorf("--%s did not return a properly-formed spec: %w", SpecFlagName, err)
+		}
+		return newSpecForExtProtoSpec(extProtoSpec)
+	}
 	protoSpec := &pluginrpcv1.Spec{}
-	if err := unmarshalSpec(c.format, data, protoSpec); err != nil {
+	if err := unmarshalSpec(format, data, protoSpec); err != nil {
 		return nil, fmt.Errorf("--%s did not return a properly-formed spec: %w", SpecFlagName, err)
 	}
 	return NewSpecForProto(protoSpec)
 }
 
+func (c *client) getInfoUncached(ctx context.Context) (_ Info, retErr error) {
+	if err := c.checkProtocolVersion(ctx); err != nil {
+		return nil, err
+	}
+	stdout := bytes.NewBuffer(nil)
+	format := c.formatFallback.format(c.format)
+	args := []string{"--" + InfoFlagName, "--" + FormatFlagName, format.String()}
+	loggedCall := c.callLogger.start(ctx, "", args)
+	auditInvocation, env := c.auditLog.start(
+		"",
+		Env{
+			Args:   args,
+			Stdout: stdout,
+			Stderr: c.stderr,
+		},
+	)
+	defer func() {
+		loggedCall.finish(retErr)
+		retErr = auditInvocation.finish(retErr)
+	}()
+	if err := c.runner.Run(ctx, env); err != nil {
+		return nil, err
+	}
+	protoInfo, err := decodeStdout(
+		ctx,
+		c,
+		stdout.Bytes(),
+		func(data []byte) (*extv1.Info, error) {
+			protoInfo := &extv1.Info{}
+			return protoInfo, unmarshalInfo(format, data, protoInfo)
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("--%s did not return a properly-formed info: %w", InfoFlagName, err)
+	}
+	return newInfoForProto(protoInfo)
+}
+
 func (c *client) checkProtocolVersion(ctx context.Context) error {
+	if c.envDefaultsErr != nil {
+		return c.envDefaultsErr
+	}
+	if c.envProtocolVersion != 0 {
+		// getEnvDefaults only accepts the supported protocol version.
+		return nil
+	}
 	version, err := c.getProtocolVersionUncached(ctx)
 	if err != nil {
 		return err
 	}
 	if version != protocolVersion {
-		return fmt.Errorf("--%s returned unknown protocol version %d", ProtocolFlagName, version)
+		return withErrorSource(fmt.Errorf("--%s returned unknown protocol version %d", ProtocolFlagName, version), ErrorSourceDecode)
 	}
 	return nil
 }
 
-func (c *client) getProtocolVersionUncached(ctx context.Context) (int, error) {
+func (c *client) getProtocolVersionUncached(ctx context.Context) (_ int, retErr error) {
 	stdout := bytes.NewBuffer(nil)
-	if err := c.runner.Run(
-		ctx,
+	args := []string{"--" + ProtocolFlagName}
+	loggedCall := c.callLogger.start(ctx, "", args)
+	auditInvocation, env := c.auditLog.start(
+		"",
 		Env{
-			Args:   []string{"--" + ProtocolFlagName},
+			Args:   args,
 			Stdout: stdout,
 			Stderr: c.stderr,
 		},
-	); err != nil {
-		return 0, err
+	)
+	defer func() {
+		loggedCall.finish(retErr)
+		retErr = auditInvocation.finish(retErr)
+	}()
+	if err := c.runner.Run(ctx, env); err != nil {
+		return 0, withErrorSource(err, errorSourceForRunError(err))
 	}
+	// All other errors are from the output of the plugin.
+	defer func() {
+		retErr = withErrorSource(retErr, ErrorSourceDecode)
+	}()
 	data := stdout.Bytes()
 	if len(data) == 0 {
 		return 0, fmt.Errorf("--%s did not return a protocol version", ProtocolFlagName)
 	}
-	version, err := unmarshalProtocol(data)
+	version, err := decodeStdout(ctx, c, data, unmarshalProtocol)
 	if err != nil {
 		return 0, fmt.Errorf("--%s did not return a properly-formed protocol version: %w", ProtocolFlagName, err)
 	}
@@ -251,12 +1432,55 @@ func (c *client) getProtocolVersionUncached(ctx context.Context) (int, error) {
 }
 
 type clientOptions struct {
-	stderr io.Writer
-	format Format
+	stderr                 io.Writer
+	format                 Format
+	formatFallback         bool
+	specCompression        bool
+	compression            Compression
+	maxDecompressedBytes   int64
+	maxDecompressionRatio  int64
+	maxResponseSize        int64
+	stdoutNoiseTolerance   bool
+	specDescriptors        bool
+	specDocs               bool
+	specVersion            bool
+	errorDetails           bool
+	locale                 string
+	binaryHeader           bool
+	replayProtection       bool
+	deadlinePropagation    bool
+	auditLog               io.Writer
+	logger                 *slog.Logger
+	envDefaults            bool
+	debugWriter            io.Writer
+	redactor               Redactor
+	interceptors           []ClientInterceptor
+	maxConcurrentProcesses int
+	combinedHandshake      bool
+	spec                   Spec
+	specCacheDirPath       string
+	retryMaxAttempts       int
+	retryOptions           []RetryOption
+	warningHandler         func(Warning)
+	hostServices           Server
 }
 
 func newClientOptions() *clientOptions {
 	return &clientOptions{}
 }
 
-type callOptions struct{}
+type callOptions struct {
+	nonce            string
+	metadata         map[string]string
+	responseMetadata map[string]string
+	resourceBudget   resourceBudget
+	timeout          time.Duration
+	priority         int32
+	requestPayload   io.Reader
+	responsePayload  io.Writer
+	hostServices     Server
+}
+
+func newCallOptions() *callOptions {
+	return &callOptions{}
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"pluginrpc.com/pluginrpc"
+)
+
+const (
+	benchUsage = `Usage: pluginrpc bench [flags] <plugin> <procedure> [plugin args...]
+
+Call a procedure of a plugin repeatedly, and print the throughput and latency.
+
+Unless --serve is specified, every call spawns a new plugin process, and latencies
+include the overhead of spawning the process. With --serve, calls are made within a
+session of a single long-lived plugin process, which must support --serve. The Spec
+of the plugin is loaded before the benchmark starts.
+
+A request can only be given with --data if the descriptors for the request and
+response types of the procedure are available, as given by --descriptor-set.
+
+Flags:`
+
+	dataFlagName        = "data"
+	requestsFlagName    = "requests"
+	concurrencyFlagName = "concurrency"
+	serveFlagName       = "serve"
+)
+
+func runBench(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("bench", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	// Everything after the procedure is an argument to the plugin.
+	flagSet.SetInterspersed(false)
+	var data string
+	var requests int
+	var concurrency int
+	var serve bool
+	var descriptorSetFilePaths []string
+	flagSet.StringVarP(&data, dataFlagName, "d", "", "The request as JSON.")
+	flagSet.IntVarP(&requests, requestsFlagName, "n", 100, "The number of calls to make.")
+	flagSet.IntVarP(&concurrency, concurrencyFlagName, "c", 1, "The number of calls to make concurrently.")
+	flagSet.BoolVar(&serve, serveFlagName, false, "Make calls within a session of a single plugin process started with --serve.")
+	flagSet.StringSliceVar(
+		&descriptorSetFilePaths,
+		descriptorSetFlagName,
+		nil,
+		"A binary FileDescriptorSet containing the request and response types of the plugin.",
+	)
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", benchUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() < 2 {
+		flagSet.Usage()
+		return errUsage
+	}
+	if requests < 1 {
+		return fmt.Errorf("--%s must be positive: %d", requestsFlagName, requests)
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("--%s must be positive: %d", concurrencyFlagName, concurrency)
+	}
+	files, err := readDescriptorSets(descriptorSetFilePaths)
+	if err != nil {
+		return err
+	}
+	programName, path, pluginArgs := flagSet.Arg(0), flagSet.Arg(1), flagSet.Args()[2:]
+	var runner pluginrpc.Runner
+	if serve {
+		serveRunner := pluginrpc.NewExecServeRunner(programName, pluginrpc.ExecRunnerWithArgs(pluginArgs...))
+		defer func() {
+			_ = serveRunner.Close()
+		}()
+		runner = serveRunner
+	} else {
+		runner = pluginrpc.NewExecRunner(programName, pluginrpc.ExecRunnerWithArgs(pluginArgs...))
+	}
+	// Plugin processes of concurrent calls write to stderr at the same time.
+	client := pluginrpc.NewClient(runner, pluginrpc.ClientWithStderr(&lockedWriter{writer: stderr}))
+	spec, err := client.Spec(ctx)
+	if err != nil {
+		return err
+	}
+	call, err := newBenchCall(client, spec, files, path, data)
+	if err != nil {
+		return err
+	}
+	result := runBenchCalls(ctx, call, requests, concurrency)
+	return result.print(stdout)
+}
+
+// newBenchCall returns a function that calls the Procedure with the given JSON request.
+//
+// If no descriptors are available for the Procedure, the Procedure is called as a unary
+// Procedure without a request, and the response is discarded.
+func newBenchCall(
+	client pluginrpc.Client,
+	spec pluginrpc.Spec,
+	files *protoregistry.Files,
+	path string,
+	data string,
+) (func(context.Context) error, error) {
+	if spec.ProcedureForPath(path) == nil {
+		return nil, fmt.Errorf("unknown procedure: %q", path)
+	}
+	if files == nil {
+		if data != "" {
+			return nil, fmt.Errorf("no descriptors available for procedure %q, specify --%s", path, descriptorSetFlagName)
+		}
+		return func(ctx context.Context) error {
+			return client.Call(ctx, path, nil, nil)
+		}, nil
+	}
+	methodDescriptor, err := methodDescriptorForPath(spec, files, path)
+	if err != nil {
+		return nil, err
+	}
+	var request proto.Message
+	if data != "" {
+		request, err = pluginrpc.NewRequestForJSON([]byte(data), methodDescriptor.Input())
+		if err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+	newResponse := func() any { return dynamicpb.NewMessage(methodDescriptor.Output()) }
+	if methodDescriptor.IsStreamingServer() {
+		return func(ctx context.Context) error {
+			return client.CallServerStream(ctx, path, request, newResponse, func(any) error { return nil })
+		}, nil
+	}
+	return func(ctx context.Context) error {
+		return client.Call(ctx, path, request, newResponse())
+	}, nil
+}
+
+type benchResult struct {
+	duration time.Duration
+	// latencies are the latencies of all calls, sorted.
+	latencies []time.Duration
+	errors    int
+	// firstErr is the first error any call failed with, if any.
+	firstErr error
+}
+
+// runBenchCalls makes the given number of calls, with the given number of calls in flight at once.
+func runBenchCalls(ctx context.Context, call func(context.Context) error, requests int, concurrency int) *benchResult {
+	result := &benchResult{
+		latencies: make([]time.Duration, 0, requests),
+	}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	requestC := make(chan struct{}, requests)
+	for i := 0; i < requests; i++ {
+		requestC <- struct{}{}
+	}
+	close(requestC)
+	start := time.Now()
+	for i := 0; i < min(concurrency, requests); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range requestC {
+				callStart := time.Now()
+				err := call(ctx)
+				latency := time.Since(callStart)
+				lock.Lock()
+				result.latencies = append(result.latencies, latency)
+				if err != nil {
+					result.errors++
+					if result.firstErr == nil {
+						result.firstErr = err
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	result.duration = time.Since(start)
+	sort.Slice(result.latencies, func(i int, j int) bool { return result.latencies[i] < result.latencies[j] })
+	return result
+}
+
+func (b *benchResult) print(writer io.Writer) error {
+	if _, err := fmt.Fprintf(
+		writer,
+		`requests:    %d
+errors:      %d
+duration:    %v
+throughput:  %.1f requests/s
+latency:     mean=%v p50=%v p90=%v p99=%v max=%v
+`,
+		len(b.latencies),
+		b.errors,
+		b.duration.Round(time.Microsecond),
+		float64(len(b.latencies))/b.duration.Seconds(),
+		b.mean(),
+		b.percentile(0.5),
+		b.percentile(0.9),
+		b.percentile(0.99),
+		b.latencies[len(b.latencies)-1].Round(time.Microsecond),
+	); err != nil {
+		return err
+	}
+	if b.firstErr != nil {
+		_, err := fmt.Fprintf(writer, "first error: %v\n", b.firstErr)
+		return err
+	}
+	return nil
+}
+
+func (b *benchResult) mean() time.Duration {
+	var sum time.Duration
+	for _, latency := range b.latencies {
+		sum += latency
+	}
+	return (sum / time.Duration(len(b.latencies))).Round(time.Microsecond)
+}
+
+// percentile returns the nearest-rank percentile of the latencies.
+func (b *benchResult) percentile(percentile float64) time.Duration {
+	rank := int(math.Ceil(percentile * float64(len(b.latencies))))
+	return b.latencies[max(rank, 1)-1].Round(time.Microsecond)
+}
+
+// lockedWriter is a writer that can be written to concurrently.
+type lockedWriter struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.writer.Write(p)
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+)
+
+func TestBench(t *testing.T) {
+	t.Parallel()
+
+	data, err := proto.Marshal(newFileDescriptorSet(examplev1.File_pluginrpc_example_v1_example_proto))
+	require.NoError(t, err)
+	descriptorSetFilePath := filepath.Join(t.TempDir(), "image.binpb")
+	require.NoError(t, os.WriteFile(descriptorSetFilePath, data, 0o600))
+
+	for _, args := range [][]string{
+		{"-n", "4", "-c", "2", echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoList"},
+		{
+			"-n", "8", "-c", "4", "--serve",
+			"--descriptor-set", descriptorSetFilePath,
+			"-d", `{"message":"hello"}`,
+			echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoRequest",
+		},
+		{
+			"-n", "2",
+			"--descriptor-set", descriptorSetFilePath,
+			"-d", `{"messages":["foo","bar"]}`,
+			echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoStream",
+		},
+	} {
+		stdout := bytes.NewBuffer(nil)
+		require.NoError(
+			t,
+			run(context.Background(), append([]string{"bench"}, args...), nil, stdout, bytes.NewBuffer(nil)),
+			args,
+		)
+		output := stdout.String()
+		require.Regexp(t, `(?m)^requests: +[0-9]+$`, output)
+		require.Regexp(t, `(?m)^errors: +0$`, output)
+		require.Regexp(t, `(?m)^throughput: +[0-9.]+ requests/s$`, output)
+		require.Regexp(t, `(?m)^latency: +mean=\S+ p50=\S+ p90=\S+ p99=\S+ max=\S+$`, output)
+		require.NotContains(t, output, "first error")
+	}
+
+	err = run(
+		context.Background(),
+		[]string{"bench", "-d", `{"message":"hello"}`, echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoRequest"},
+		nil,
+		bytes.NewBuffer(nil),
+		bytes.NewBuffer(nil),
+	)
+	require.ErrorContains(t, err, "no descriptors available")
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	"pluginrpc.com/pluginrpc"
+)
+
+const (
+	breakingUsage = `Usage: pluginrpc breaking --against <spec.json> [flags] <plugin> [plugin args...]
+
+Check the Spec of a plugin for changes that break clients of a previous Spec, and print
+every breaking change. Exits with a non-zero exit code if there are breaking changes.
+
+The previous Spec is read from a file printed by the spec command. If the file includes
+descriptors, the descriptors of the plugin are requested, and the request and response
+types of procedures are also checked.
+
+Rules: procedure_no_delete, procedure_same_args, procedure_same_input_type,
+procedure_same_output_type, procedure_same_streaming.
+
+Flags:`
+
+	againstFlagName    = "against"
+	ruleFlagName       = "rule"
+	ignorePathFlagName = "ignore-path"
+)
+
+func runBreaking(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("breaking", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	// Everything after the plugin is an argument to the plugin.
+	flagSet.SetInterspersed(false)
+	var against string
+	var ruleStrings []string
+	var ignorePaths []string
+	flagSet.StringVar(&against, againstFlagName, "", "The path of the previous Spec, as printed by the spec command. Required.")
+	flagSet.StringSliceVar(&ruleStrings, ruleFlagName, nil, "The rules to check. May be specified multiple times. Defaults to all rules.")
+	flagSet.StringSliceVar(&ignorePaths, ignorePathFlagName, nil, "The path of a procedure to not check. May be specified multiple times.")
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", breakingUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() < 1 || against == "" {
+		flagSet.Usage()
+		return errUsage
+	}
+	checkBreakingOptions := []pluginrpc.CheckBreakingOption{
+		pluginrpc.CheckBreakingWithIgnorePaths(ignorePaths...),
+	}
+	if len(ruleStrings) > 0 {
+		rules := make([]pluginrpc.BreakingRule, len(ruleStrings))
+		for i, ruleString := range ruleStrings {
+			rule := pluginrpc.BreakingRuleForString(ruleString)
+			if rule == 0 {
+				return fmt.Errorf("unknown value for --%s: %q", ruleFlagName, ruleString)
+			}
+			rules[i] = rule
+		}
+		checkBreakingOptions = append(checkBreakingOptions, pluginrpc.CheckBreakingWithRules(rules...))
+	}
+	previousSpec, err := readSpec(against)
+	if err != nil {
+		return err
+	}
+	var clientOptions []pluginrpc.ClientOption
+	if previousSpec.FileDescriptorSet() != nil {
+		clientOptions = append(clientOptions, pluginrpc.ClientWithSpecDescriptors())
+	}
+	currentSpec, err := newJSONClient(flagSet.Arg(0), flagSet.Args()[1:], stderr, clientOptions...).Spec(ctx)
+	if err != nil {
+		return err
+	}
+	breakingChanges, err := pluginrpc.CheckBreaking(previousSpec, currentSpec, checkBreakingOptions...)
+	if err != nil {
+		return err
+	}
+	for _, breakingChange := range breakingChanges {
+		if _, err := fmt.Fprintln(stdout, breakingChange.String()); err != nil {
+			return err
+		}
+	}
+	if len(breakingChanges) > 0 {
+		return fmt.Errorf("%d breaking changes against %q", len(breakingChanges), against)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaking(t *testing.T) {
+	t.Parallel()
+
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(t, run(context.Background(), []string{"spec", "--descriptors", echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil)))
+	require.Contains(t, stdout.String(), `"fileDescriptorSet"`)
+	specFilePath := filepath.Join(t.TempDir(), "spec.json")
+	require.NoError(t, os.WriteFile(specFilePath, stdout.Bytes(), 0o600))
+
+	stdout.Reset()
+	require.NoError(t, run(context.Background(), []string{"breaking", "--against", specFilePath, echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil)))
+	require.Empty(t, stdout.String())
+
+	deletedSpecFilePath := filepath.Join(t.TempDir(), "spec.json")
+	require.NoError(
+		t,
+		os.WriteFile(
+			deletedSpecFilePath,
+			[]byte(`{"procedures":[{"path":"/pluginrpc.example.v1.EchoService/EchoDeleted"},{"path":"/pluginrpc.example.v1.EchoService/EchoList","args":["echo","list"]}]}`),
+			0o600,
+		),
+	)
+	stdout.Reset()
+	err := run(context.Background(), []string{"breaking", "--against", deletedSpecFilePath, echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil))
+	require.ErrorContains(t, err, "2 breaking changes")
+	require.Equal(
+		t,
+		`procedure "/pluginrpc.example.v1.EchoService/EchoDeleted" was deleted (procedure_no_delete)
+args of procedure "/pluginrpc.example.v1.EchoService/EchoList" changed from "echo list" to "/pluginrpc.example.v1.EchoService/EchoList" (procedure_same_args)
+`,
+		stdout.String(),
+	)
+
+	stdout.Reset()
+	require.NoError(
+		t,
+		run(
+			context.Background(),
+			[]string{"breaking", "--against", deletedSpecFilePath, "--rule", "procedure_same_args", "--ignore-path", "/pluginrpc.example.v1.EchoService/EchoList", echoPluginProgramName},
+			nil,
+			stdout,
+			bytes.NewBuffer(nil),
+		),
+	)
+	require.Empty(t, stdout.String())
+	err = run(context.Background(), []string{"breaking", "--against", deletedSpecFilePath, "--rule", "unknown", echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil))
+	require.ErrorContains(t, err, "unknown value for --rule")
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+	"pluginrpc.com/pluginrpc"
+)
+
+const callUsage = `Usage: pluginrpc call [flags] <plugin> <procedure> [plugin args...]
+
+Call a procedure of a plugin, and print the responses as JSON.
+
+A request can only be given with --data, and responses can only be printed, if the
+descriptors for the request and response types of the procedure are available, as
+given by --descriptor-set. Without descriptors, the procedure is called without a
+request and the response is discarded, which is useful to check that the call succeeds.
+
+Flags:`
+
+func runCall(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("call", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	// Everything after the procedure is an argument to the plugin.
+	flagSet.SetInterspersed(false)
+	var data string
+	var descriptorSetFilePaths []string
+	flagSet.StringVarP(&data, dataFlagName, "d", "", "The request as JSON.")
+	flagSet.StringSliceVar(
+		&descriptorSetFilePaths,
+		descriptorSetFlagName,
+		nil,
+		"A binary FileDescriptorSet containing the request and response types of the plugin.",
+	)
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", callUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() < 2 {
+		flagSet.Usage()
+		return errUsage
+	}
+	files, err := readDescriptorSets(descriptorSetFilePaths)
+	if err != nil {
+		return err
+	}
+	programName, path, pluginArgs := flagSet.Arg(0), flagSet.Arg(1), flagSet.Args()[2:]
+	if files == nil {
+		if data != "" {
+			return fmt.Errorf("no descriptors available for procedure %q, specify --%s", path, descriptorSetFlagName)
+		}
+		// Responses in FormatJSON cannot be parsed without the descriptors of the response
+		// type, even if the response is discarded, so use the default Format.
+		client := pluginrpc.NewClient(
+			pluginrpc.NewExecRunner(programName, pluginrpc.ExecRunnerWithArgs(pluginArgs...)),
+			pluginrpc.ClientWithStderr(stderr),
+		)
+		spec, err := client.Spec(ctx)
+		if err != nil {
+			return err
+		}
+		if spec.ProcedureForPath(path) == nil {
+			return fmt.Errorf("unknown procedure: %q", path)
+		}
+		return client.Call(ctx, path, nil, nil)
+	}
+	client := newJSONClient(programName, pluginArgs, stderr)
+	spec, err := client.Spec(ctx)
+	if err != nil {
+		return err
+	}
+	methodDescriptor, err := methodDescriptorForPath(spec, files, path)
+	if err != nil {
+		return err
+	}
+	return callProcedure(
+		ctx,
+		client,
+		methodDescriptor,
+		path,
+		data,
+		func(m map[string]any) error {
+			return printMap(stdout, m)
+		},
+	)
+}
+
+// newJSONClient returns a new Client for the plugin that uses FormatJSON.
+func newJSONClient(programName string, programArgs []string, stderr io.Writer, clientOptions ...pluginrpc.ClientOption) pluginrpc.Client {
+	return pluginrpc.NewClient(
+		pluginrpc.NewExecRunner(programName, pluginrpc.ExecRunnerWithArgs(programArgs...)),
+		append(
+			[]pluginrpc.ClientOption{
+				pluginrpc.ClientWithStderr(stderr),
+				pluginrpc.ClientWithFormat(pluginrpc.FormatJSON),
+			},
+			clientOptions...,
+		)...,
+	)
+}
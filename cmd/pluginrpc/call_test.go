@@ -0,0 +1,79 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+)
+
+func TestCall(t *testing.T) {
+	t.Parallel()
+
+	data, err := proto.Marshal(newFileDescriptorSet(examplev1.File_pluginrpc_example_v1_example_proto))
+	require.NoError(t, err)
+	descriptorSetFilePath := filepath.Join(t.TempDir(), "image.binpb")
+	require.NoError(t, os.WriteFile(descriptorSetFilePath, data, 0o600))
+	runCall := func(args ...string) (string, error) {
+		stdout := bytes.NewBuffer(nil)
+		err := run(context.Background(), append([]string{"call"}, args...), nil, stdout, bytes.NewBuffer(nil))
+		return stdout.String(), err
+	}
+
+	output, err := runCall(
+		"--descriptor-set", descriptorSetFilePath,
+		"-d", `{"message":"hello"}`,
+		echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoRequest",
+	)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"message":"hello"}`, output)
+
+	output, err = runCall(
+		"--descriptor-set", descriptorSetFilePath,
+		"-d", `{"messages":["foo","bar"]}`,
+		echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoStream",
+	)
+	require.NoError(t, err)
+	require.Equal(t, "{\n  \"message\": \"foo\"\n}\n{\n  \"message\": \"bar\"\n}\n", output)
+
+	// Without descriptors, the response is discarded.
+	output, err = runCall(echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoList")
+	require.NoError(t, err)
+	require.Empty(t, output)
+
+	_, err = runCall("-d", `{"message":"hello"}`, echoPluginProgramName, "/pluginrpc.example.v1.EchoService/EchoRequest")
+	require.ErrorContains(t, err, "no descriptors available")
+	_, err = runCall(echoPluginProgramName, "/pluginrpc.example.v1.EchoService/Unknown")
+	require.ErrorContains(t, err, "unknown procedure")
+}
+
+func TestSpecAndProtocol(t *testing.T) {
+	t.Parallel()
+
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(t, run(context.Background(), []string{"protocol", echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil)))
+	require.Equal(t, "1\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run(context.Background(), []string{"spec", echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil)))
+	require.Contains(t, stdout.String(), `"path": "/pluginrpc.example.v1.EchoService/EchoRequest"`)
+}
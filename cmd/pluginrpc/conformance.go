@@ -0,0 +1,102 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/pflag"
+	"pluginrpc.com/pluginrpc"
+	"pluginrpc.com/pluginrpc/pluginrpcconformance"
+)
+
+const (
+	conformanceUsage = `Usage: pluginrpc conformance [flags] <plugin> [plugin args...]
+
+Check that a plugin conforms to the PluginRPC protocol, and print the result of every
+check. Exits with a non-zero exit code if the plugin violates the protocol.
+
+Unless --no-calls is specified, every procedure of the plugin is called with an empty
+request and with a malformed request.
+
+Flags:`
+
+	timeoutFlagName = "timeout"
+	noCallsFlagName = "no-calls"
+)
+
+func runConformance(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("conformance", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	// Everything after the plugin is an argument to the plugin.
+	flagSet.SetInterspersed(false)
+	var timeout time.Duration
+	var noCalls bool
+	flagSet.DurationVar(&timeout, timeoutFlagName, 10*time.Second, "The maximum duration of a single invocation of the plugin.")
+	flagSet.BoolVar(&noCalls, noCallsFlagName, false, "Do not call the procedures of the plugin.")
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", conformanceUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() < 1 {
+		flagSet.Usage()
+		return errUsage
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("--%s must be positive: %v", timeoutFlagName, timeout)
+	}
+	runOptions := []pluginrpcconformance.RunOption{
+		pluginrpcconformance.RunWithInvocationTimeout(timeout),
+	}
+	if noCalls {
+		runOptions = append(runOptions, pluginrpcconformance.RunWithoutProcedureCalls())
+	}
+	results, err := pluginrpcconformance.Run(
+		ctx,
+		pluginrpc.NewExecRunner(flagSet.Arg(0), pluginrpc.ExecRunnerWithArgs(flagSet.Args()[1:]...)),
+		runOptions...,
+	)
+	if err != nil {
+		return err
+	}
+	var violations int
+	for _, result := range results {
+		switch {
+		case result.Violation != "":
+			violations++
+			_, err = fmt.Fprintf(stdout, "FAIL %s: %s\n", result.Name, result.Violation)
+		case result.SkipReason != "":
+			_, err = fmt.Fprintf(stdout, "SKIP %s: %s\n", result.Name, result.SkipReason)
+		default:
+			_, err = fmt.Fprintf(stdout, "PASS %s\n", result.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if violations > 0 {
+		return fmt.Errorf("%d of %d checks found protocol violations", violations, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(
+		t,
+		run(context.Background(), []string{"conformance", echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil)),
+	)
+	require.Contains(t, stdout.String(), "PASS protocol\n")
+	require.Contains(t, stdout.String(), "PASS malformed-request/json/pluginrpc.example.v1.EchoService/EchoRequest\n")
+	require.NotContains(t, stdout.String(), "FAIL")
+
+	stdout.Reset()
+	require.NoError(
+		t,
+		run(context.Background(), []string{"conformance", "--no-calls", echoPluginProgramName}, nil, stdout, bytes.NewBuffer(nil)),
+	)
+	require.Contains(t, stdout.String(), "SKIP empty-request/binary/pluginrpc.example.v1.EchoService/EchoRequest: procedure calls disabled\n")
+}
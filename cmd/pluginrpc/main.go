@@ -0,0 +1,89 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main implements the pluginrpc CLI, a tool for interacting with plugins
+// without writing Go.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"pluginrpc.com/pluginrpc/pluginrpcinfo"
+)
+
+const usage = `Usage: pluginrpc <command> [flags] <plugin> [plugin args...]
+
+Commands:
+  bench		Measure the throughput and latency of a procedure of a plugin.
+  breaking	Check the Spec of a plugin for changes that break clients of a previous Spec.
+  call		Call a procedure of a plugin and print the responses as JSON.
+  conformance	Check that a plugin conforms to the PluginRPC protocol.
+  new-plugin	Create a new plugin as a Go module that is ready to build.
+  protocol	Print the protocol version of a plugin.
+  repl		Start an interactive session with a plugin.
+  spec		Print the Spec of a plugin as JSON.
+
+Flags:
+  -h, --help	Print this help and exit.
+      --version	Print the version and exit.`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned when the usage has already been printed to stderr.
+var errUsage = errors.New("usage")
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usage)
+		return errUsage
+	}
+	switch args[0] {
+	case "--version":
+		_, err := fmt.Fprintln(stdout, pluginrpcinfo.Get())
+		return err
+	case "-h", "--help":
+		_, err := fmt.Fprintln(stdout, usage)
+		return err
+	case "bench":
+		return runBench(ctx, args[1:], stdout, stderr)
+	case "breaking":
+		return runBreaking(ctx, args[1:], stdout, stderr)
+	case "call":
+		return runCall(ctx, args[1:], stdout, stderr)
+	case "conformance":
+		return runConformance(ctx, args[1:], stdout, stderr)
+	case "new-plugin":
+		return runNewPlugin(args[1:], stdout, stderr)
+	case "protocol":
+		return runProtocol(ctx, args[1:], stdout, stderr)
+	case "repl":
+		return runRepl(ctx, args[1:], stdin, stdout, stderr)
+	case "spec":
+		return runSpec(ctx, args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "unknown command: %q\n\n%s\n", args[0], usage)
+		return errUsage
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	newPluginUsage = `Usage: pluginrpc new-plugin --module <module> [flags] <directory>
+
+Create a new plugin in a directory, as a Go module that is ready to build.
+
+The module contains a service definition, the buf configuration to generate code for it,
+and a plugin that implements the service, with tests. See the README.md of the module
+for the next steps. Existing files are never overwritten.
+
+Flags:`
+
+	moduleFlagName  = "module"
+	packageFlagName = "package"
+	serviceFlagName = "service"
+
+	defaultNewPluginService = "EchoService"
+)
+
+var (
+	//go:embed template
+	newPluginTemplateFS embed.FS
+
+	newPluginTemplate = template.Must(template.ParseFS(newPluginTemplateFS, "template/*.tmpl"))
+
+	newPluginNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// A package of lowercase components that ends with a version, i.e. foo.v1.
+	newPluginProtoPackageRegexp = regexp.MustCompile(`^([a-z][a-z0-9]*\.)+v[0-9]+$`)
+	newPluginServiceRegexp      = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*Service$`)
+)
+
+func runNewPlugin(args []string, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("new-plugin", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	var module string
+	var protoPackage string
+	var service string
+	flagSet.StringVar(&module, moduleFlagName, "", "The path of the Go module of the plugin, i.e. github.com/acme/foo-plugin. Required.")
+	flagSet.StringVar(&protoPackage, packageFlagName, "", "The Protobuf package of the service. Defaults to the name of the directory, followed by v1.")
+	flagSet.StringVar(&service, serviceFlagName, defaultNewPluginService, "The name of the service.")
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", newPluginUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() != 1 || module == "" {
+		flagSet.Usage()
+		return errUsage
+	}
+	dirPath := flagSet.Arg(0)
+	data, err := newPluginTemplateData(filepath.Base(dirPath), module, protoPackage, service)
+	if err != nil {
+		return err
+	}
+	files, err := data.render()
+	if err != nil {
+		return err
+	}
+	// Check all files before writing any, so that a failure does not leave a partial module.
+	for _, file := range files {
+		if _, err := os.Lstat(filepath.Join(dirPath, file.path)); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dirPath, file.path))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, file := range files {
+		filePath := filepath.Join(dirPath, file.path)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, file.data, 0o644); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(stdout, filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pluginTemplateData is the data that the templates of new-plugin are executed with.
+type pluginTemplateData struct {
+	// Name is the name of the plugin, and of its binary.
+	Name   string
+	Module string
+	// ProtoPackage is i.e. foo.v1.
+	ProtoPackage string
+	// ProtoFilePath is i.e. proto/foo/v1/echo_service.proto.
+	ProtoFilePath string
+	// Service is i.e. EchoService.
+	Service string
+	// Method is the method of the service, i.e. Echo.
+	Method string
+	// GoPackageName is the name of the Go package generated for ProtoPackage with
+	// buf managed mode, i.e. foov1.
+	GoPackageName string
+	GoPackagePath string
+	// FileDescriptorVar is the variable of the generated Go code for the file, i.e.
+	// File_foo_v1_echo_service_proto.
+	FileDescriptorVar string
+	// HandlerType is i.e. echoServiceHandler.
+	HandlerType string
+}
+
+type newPluginFile struct {
+	path string
+	data []byte
+}
+
+func newPluginTemplateData(name string, module string, protoPackage string, service string) (*pluginTemplateData, error) {
+	if !newPluginNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid plugin name %q, the name of the directory is used as the name of the plugin", name)
+	}
+	if protoPackage == "" {
+		protoPackage = newPluginDefaultProtoPackage(name)
+	}
+	if !newPluginProtoPackageRegexp.MatchString(protoPackage) {
+		return nil, fmt.Errorf("invalid value for --%s: %q, must be lowercase components followed by a version, i.e. foo.v1", packageFlagName, protoPackage)
+	}
+	if !newPluginServiceRegexp.MatchString(service) {
+		return nil, fmt.Errorf("invalid value for --%s: %q, must be PascalCase and end with Service, i.e. FooService", serviceFlagName, service)
+	}
+	protoPackageComponents := strings.Split(protoPackage, ".")
+	protoFileName := newPluginSnakeCase(service) + ".proto"
+	protoFilePath := path.Join(append([]string{"proto"}, append(protoPackageComponents, protoFileName)...)...)
+	return &pluginTemplateData{
+		Name:          name,
+		Module:        module,
+		ProtoPackage:  protoPackage,
+		ProtoFilePath: protoFilePath,
+		Service:       service,
+		Method:        strings.TrimSuffix(service, "Service"),
+		GoPackageName: strings.Join(protoPackageComponents[len(protoPackageComponents)-2:], ""),
+		GoPackagePath: path.Join(append([]string{module, "gen"}, protoPackageComponents...)...),
+		FileDescriptorVar: "File_" + strings.NewReplacer("/", "_", ".", "_").Replace(
+			strings.TrimPrefix(protoFilePath, "proto/"),
+		),
+		HandlerType: strings.ToLower(service[:1]) + service[1:] + "Handler",
+	}, nil
+}
+
+func (d *pluginTemplateData) render() ([]*newPluginFile, error) {
+	templates := []struct {
+		templateName string
+		path         string
+	}{
+		{"go.mod.tmpl", "go.mod"},
+		{"README.md.tmpl", "README.md"},
+		{"buf.yaml.tmpl", "buf.yaml"},
+		{"buf.gen.yaml.tmpl", "buf.gen.yaml"},
+		{"service.proto.tmpl", d.ProtoFilePath},
+		{"main.go.tmpl", path.Join("cmd", d.Name, "main.go")},
+		{"main_test.go.tmpl", path.Join("cmd", d.Name, "main_test.go")},
+	}
+	files := make([]*newPluginFile, len(templates))
+	for i, templateFile := range templates {
+		buffer := bytes.NewBuffer(nil)
+		if err := newPluginTemplate.ExecuteTemplate(buffer, templateFile.templateName, d); err != nil {
+			return nil, err
+		}
+		files[i] = &newPluginFile{
+			path: filepath.FromSlash(templateFile.path),
+			data: buffer.Bytes(),
+		}
+	}
+	return files, nil
+}
+
+// newPluginDefaultProtoPackage returns the default package for the plugin name, i.e.
+// foobar.v1 for foo-bar-plugin.
+func newPluginDefaultProtoPackage(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), "-plugin")
+	var builder strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9' && builder.Len() > 0:
+			_, _ = builder.WriteRune(r)
+		}
+	}
+	if builder.Len() == 0 {
+		return "plugin.v1"
+	}
+	return builder.String() + ".v1"
+}
+
+// newPluginSnakeCase returns i.e. echo_service for EchoService.
+func newPluginSnakeCase(s string) string {
+	var builder strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				_ = builder.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		_, _ = builder.WriteRune(r)
+	}
+	return builder.String()
+}
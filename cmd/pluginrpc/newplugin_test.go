@@ -0,0 +1,100 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPlugin(t *testing.T) {
+	t.Parallel()
+
+	dirPath := filepath.Join(t.TempDir(), "greet-plugin")
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(
+		t,
+		run(context.Background(), []string{"new-plugin", "--module", "example.com/greet-plugin", "--service", "GreetService", dirPath}, nil, stdout, bytes.NewBuffer(nil)),
+	)
+	require.Equal(
+		t,
+		strings.Join(
+			[]string{
+				filepath.Join(dirPath, "go.mod"),
+				filepath.Join(dirPath, "README.md"),
+				filepath.Join(dirPath, "buf.yaml"),
+				filepath.Join(dirPath, "buf.gen.yaml"),
+				filepath.Join(dirPath, "proto", "greet", "v1", "greet_service.proto"),
+				filepath.Join(dirPath, "cmd", "greet-plugin", "main.go"),
+				filepath.Join(dirPath, "cmd", "greet-plugin", "main_test.go"),
+				"",
+			},
+			"\n",
+		),
+		stdout.String(),
+	)
+	protoData, err := os.ReadFile(filepath.Join(dirPath, "proto", "greet", "v1", "greet_service.proto"))
+	require.NoError(t, err)
+	require.Contains(t, string(protoData), "package greet.v1;\n")
+	require.Contains(t, string(protoData), "rpc Greet(GreetRequest) returns (GreetResponse);\n")
+	bufGenData, err := os.ReadFile(filepath.Join(dirPath, "buf.gen.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, string(bufGenData), "value: example.com/greet-plugin/gen\n")
+	for _, fileName := range []string{"main.go", "main_test.go"} {
+		data, err := os.ReadFile(filepath.Join(dirPath, "cmd", "greet-plugin", fileName))
+		require.NoError(t, err)
+		formatted, err := format.Source(data)
+		require.NoError(t, err)
+		require.Equal(t, string(formatted), string(data))
+		require.Contains(t, string(data), `greetv1 "example.com/greet-plugin/gen/greet/v1"`)
+		require.Contains(t, string(data), `"example.com/greet-plugin/gen/greet/v1/greetv1pluginrpc"`)
+	}
+
+	// Existing files are never overwritten.
+	err = run(context.Background(), []string{"new-plugin", "--module", "example.com/greet-plugin", dirPath}, nil, stdout, bytes.NewBuffer(nil))
+	require.ErrorContains(t, err, "already exists")
+}
+
+func TestNewPluginErrors(t *testing.T) {
+	t.Parallel()
+
+	dirPath := filepath.Join(t.TempDir(), "foo")
+	err := run(context.Background(), []string{"new-plugin", dirPath}, nil, bytes.NewBuffer(nil), bytes.NewBuffer(nil))
+	require.ErrorIs(t, err, errUsage)
+	err = run(context.Background(), []string{"new-plugin", "--module", "example.com/foo", "--package", "Foo.v1", dirPath}, nil, bytes.NewBuffer(nil), bytes.NewBuffer(nil))
+	require.ErrorContains(t, err, "invalid value for --package")
+	err = run(context.Background(), []string{"new-plugin", "--module", "example.com/foo", "--package", "foo", dirPath}, nil, bytes.NewBuffer(nil), bytes.NewBuffer(nil))
+	require.ErrorContains(t, err, "invalid value for --package")
+	err = run(context.Background(), []string{"new-plugin", "--module", "example.com/foo", "--service", "foo", dirPath}, nil, bytes.NewBuffer(nil), bytes.NewBuffer(nil))
+	require.ErrorContains(t, err, "invalid value for --service")
+	_, err = os.Stat(dirPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewPluginDefaultProtoPackage(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "foo.v1", newPluginDefaultProtoPackage("foo-plugin"))
+	require.Equal(t, "foobar.v1", newPluginDefaultProtoPackage("Foo_Bar"))
+	require.Equal(t, "foo2.v1", newPluginDefaultProtoPackage("2foo2"))
+	require.Equal(t, "plugin.v1", newPluginDefaultProtoPackage("-plugin"))
+}
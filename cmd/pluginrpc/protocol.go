@@ -0,0 +1,77 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"pluginrpc.com/pluginrpc"
+)
+
+const protocolUsage = `Usage: pluginrpc protocol [flags] <plugin> [plugin args...]
+
+Print the protocol version of a plugin.
+
+Flags:`
+
+func runProtocol(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("protocol", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	// Everything after the plugin is an argument to the plugin.
+	flagSet.SetInterspersed(false)
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", protocolUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() < 1 {
+		flagSet.Usage()
+		return errUsage
+	}
+	// The Client does not expose the protocol version, as it only checks that the
+	// version is supported. Invoke the plugin directly so that any version is printed.
+	output := bytes.NewBuffer(nil)
+	if err := pluginrpc.NewExecRunner(
+		flagSet.Arg(0),
+		pluginrpc.ExecRunnerWithArgs(flagSet.Args()[1:]...),
+	).Run(
+		ctx,
+		pluginrpc.Env{
+			Args:   []string{"--" + pluginrpc.ProtocolFlagName},
+			Stdout: output,
+			Stderr: stderr,
+		},
+	); err != nil {
+		return err
+	}
+	value := strings.TrimSpace(output.String())
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("plugin printed an invalid protocol version: %q", value)
+	}
+	_, err = fmt.Fprintln(stdout, version)
+	return err
+}
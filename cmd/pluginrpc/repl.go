@@ -0,0 +1,424 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"pluginrpc.com/pluginrpc"
+)
+
+const (
+	replUsage = `Usage: pluginrpc repl [flags] <plugin> [plugin args...]
+
+Start an interactive session with a plugin.
+
+The Spec of the plugin is loaded on startup. Procedures can only be called if the
+descriptors for their request and response types are available, as given by
+--descriptor-set. A FileDescriptorSet can be produced with i.e. "buf build -o".
+
+Flags:`
+	replHelp = `Commands:
+  list                          List the procedures of the plugin.
+  describe <procedure>          Describe the request and response of a procedure.
+  call <procedure> [json]       Call a procedure with an optional JSON request.
+  complete <line>               List the completions for a partial line.
+  help                          Print this help.
+  exit                          Exit the session.`
+	replPrompt = "pluginrpc> "
+
+	descriptorSetFlagName = "descriptor-set"
+)
+
+var replCommands = []string{"call", "complete", "describe", "exit", "help", "list"}
+
+func runRepl(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("repl", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	// Everything after the plugin name is an argument to the plugin.
+	flagSet.SetInterspersed(false)
+	var descriptorSetFilePaths []string
+	flagSet.StringSliceVar(
+		&descriptorSetFilePaths,
+		descriptorSetFlagName,
+		nil,
+		"A binary FileDescriptorSet containing the request and response types of the plugin.",
+	)
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", replUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return errUsage
+	}
+	files, err := readDescriptorSets(descriptorSetFilePaths)
+	if err != nil {
+		return err
+	}
+	repl, err := newRepl(ctx, newJSONClient(flagSet.Arg(0), flagSet.Args()[1:], stderr), files, stdout)
+	if err != nil {
+		return err
+	}
+	return repl.run(ctx, stdin)
+}
+
+type repl struct {
+	client pluginrpc.Client
+	spec   pluginrpc.Spec
+	// files may be nil if no descriptors were given.
+	files  *protoregistry.Files
+	stdout io.Writer
+}
+
+func newRepl(ctx context.Context, client pluginrpc.Client, files *protoregistry.Files, stdout io.Writer) (*repl, error) {
+	spec, err := client.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &repl{
+		client: client,
+		spec:   spec,
+		files:  files,
+		stdout: stdout,
+	}, nil
+}
+
+func (r *repl) run(ctx context.Context, stdin io.Reader) error {
+	scanner := bufio.NewScanner(stdin)
+	// Requests may be large.
+	scanner.Buffer(nil, 16*1024*1024)
+	for {
+		if _, err := fmt.Fprint(r.stdout, replPrompt); err != nil {
+			return err
+		}
+		if !scanner.Scan() {
+			// Finish the prompt line.
+			_, _ = fmt.Fprintln(r.stdout)
+			return scanner.Err()
+		}
+		exit, err := r.handleLine(ctx, scanner.Text())
+		if err != nil {
+			// Errors are reported, but do not end the session.
+			if _, err := fmt.Fprintf(r.stdout, "error: %v\n", err); err != nil {
+				return err
+			}
+		}
+		if exit {
+			return nil
+		}
+	}
+}
+
+// handleLine handles a single line of input, returning true if the session should end.
+func (r *repl) handleLine(ctx context.Context, line string) (bool, error) {
+	command, rest := splitFirstWord(line)
+	switch command {
+	case "":
+		return false, nil
+	case "exit", "quit":
+		return true, nil
+	case "help":
+		_, err := fmt.Fprintln(r.stdout, replHelp)
+		return false, err
+	case "list":
+		return false, r.list()
+	case "describe":
+		return false, r.describe(strings.TrimSpace(rest))
+	case "call":
+		path, data := splitFirstWord(rest)
+		return false, r.call(ctx, path, strings.TrimSpace(data))
+	case "complete":
+		for _, completion := range r.complete(rest) {
+			if _, err := fmt.Fprintln(r.stdout, completion); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown command %q, see help", command)
+	}
+}
+
+func (r *repl) list() error {
+	for _, procedure := range r.spec.Procedures() {
+		line := procedure.Path()
+		if args := procedure.Args(); len(args) > 0 {
+			line += " (" + strings.Join(args, " ") + ")"
+		}
+		if _, err := fmt.Fprintln(r.stdout, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repl) describe(path string) error {
+	methodDescriptor, err := r.methodDescriptor(path)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(r.stdout, "request %s\n", methodDescriptor.Input().FullName()); err != nil {
+		return err
+	}
+	if err := r.describeFields(methodDescriptor.Input()); err != nil {
+		return err
+	}
+	responsePrefix := "response"
+	if methodDescriptor.IsStreamingServer() {
+		responsePrefix = "response stream"
+	}
+	if _, err := fmt.Fprintf(r.stdout, "%s %s\n", responsePrefix, methodDescriptor.Output().FullName()); err != nil {
+		return err
+	}
+	return r.describeFields(methodDescriptor.Output())
+}
+
+func (r *repl) describeFields(messageDescriptor protoreflect.MessageDescriptor) error {
+	fields := messageDescriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if _, err := fmt.Fprintf(r.stdout, "  %s: %s\n", fields.Get(i).JSONName(), fieldTypeString(fields.Get(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repl) call(ctx context.Context, path string, data string) error {
+	methodDescriptor, err := r.methodDescriptor(path)
+	if err != nil {
+		return err
+	}
+	return callProcedure(ctx, r.client, methodDescriptor, path, data, r.printMap)
+}
+
+// complete returns the completions for the last word of the line.
+//
+// Commands are completed for the first word, procedure paths for the second word of
+// describe and call, and the JSON names of request fields for the remainder of call.
+func (r *repl) complete(line string) []string {
+	words := strings.Fields(line)
+	if len(words) == 0 || strings.HasSuffix(line, " ") {
+		words = append(words, "")
+	}
+	prefix := words[len(words)-1]
+	var candidates []string
+	switch {
+	case len(words) == 1:
+		candidates = replCommands
+	case len(words) == 2 && (words[0] == "describe" || words[0] == "call"):
+		for _, procedure := range r.spec.Procedures() {
+			candidates = append(candidates, procedure.Path())
+		}
+	case len(words) > 2 && words[0] == "call":
+		methodDescriptor, err := r.methodDescriptor(words[1])
+		if err != nil {
+			return nil
+		}
+		// Allow completing i.e. {"mes to {"message":
+		trimmed := strings.TrimLeft(prefix, `{"`)
+		fieldPrefix := prefix[:len(prefix)-len(trimmed)]
+		if !strings.HasSuffix(fieldPrefix, `"`) {
+			fieldPrefix += `"`
+		}
+		fields := methodDescriptor.Input().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			candidates = append(candidates, fieldPrefix+fields.Get(i).JSONName()+`":`)
+		}
+	}
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			completions = append(completions, candidate)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+func (r *repl) methodDescriptor(path string) (protoreflect.MethodDescriptor, error) {
+	return methodDescriptorForPath(r.spec, r.files, path)
+}
+
+func (r *repl) printMap(m map[string]any) error {
+	return printMap(r.stdout, m)
+}
+
+// methodDescriptorForPath returns the MethodDescriptor for the Procedure with the given
+// path from the given files.
+//
+// files may be nil if no descriptors were given.
+func methodDescriptorForPath(spec pluginrpc.Spec, files *protoregistry.Files, path string) (protoreflect.MethodDescriptor, error) {
+	if path == "" {
+		return nil, errors.New("no procedure specified")
+	}
+	if spec.ProcedureForPath(path) == nil {
+		return nil, fmt.Errorf("unknown procedure: %q", path)
+	}
+	if files == nil {
+		return nil, fmt.Errorf("no descriptors available for procedure %q, specify --%s", path, descriptorSetFlagName)
+	}
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("procedure %q does not have a path of the form /package.Service/Method", path)
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: %w", path, err)
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a service", serviceName)
+	}
+	methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(methodName))
+	if methodDescriptor == nil {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: method %q not found", path, methodName)
+	}
+	if methodDescriptor.IsStreamingClient() {
+		return nil, fmt.Errorf("procedure %q is client-streaming, which is not supported", path)
+	}
+	return methodDescriptor, nil
+}
+
+// callProcedure calls the Procedure with the given JSON request, calling onResponse
+// for every response.
+//
+// Partial results of failed calls are given to onResponse as well.
+func callProcedure(
+	ctx context.Context,
+	client pluginrpc.Client,
+	methodDescriptor protoreflect.MethodDescriptor,
+	path string,
+	data string,
+	onResponse func(map[string]any) error,
+) error {
+	request, err := pluginrpc.NewRequestForJSON([]byte(data), methodDescriptor.Input())
+	if err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	if methodDescriptor.IsStreamingServer() {
+		return client.CallServerStream(
+			ctx,
+			path,
+			request,
+			func() any { return dynamicpb.NewMessage(methodDescriptor.Output()) },
+			func(response any) error {
+				m, err := pluginrpc.ProtoMessageToMap(response.(proto.Message))
+				if err != nil {
+					return err
+				}
+				return onResponse(m)
+			},
+		)
+	}
+	m, err := pluginrpc.CallForMap(ctx, client, path, request, methodDescriptor.Output())
+	if m != nil {
+		if err := onResponse(m); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// printMap prints the map as indented JSON.
+func printMap(writer io.Writer, m map[string]any) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(writer, string(data))
+	return err
+}
+
+// readDescriptorSets reads the binary FileDescriptorSets at the given paths.
+//
+// Returns nil if no paths are given.
+func readDescriptorSets(filePaths []string) (*protoregistry.Files, error) {
+	if len(filePaths) == 0 {
+		return nil, nil
+	}
+	fileDescriptorSet := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]struct{})
+	for _, filePath := range filePaths {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		iFileDescriptorSet := &descriptorpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(data, iFileDescriptorSet); err != nil {
+			return nil, fmt.Errorf("could not parse FileDescriptorSet %q: %w", filePath, err)
+		}
+		for _, file := range iFileDescriptorSet.GetFile() {
+			if _, ok := seen[file.GetName()]; ok {
+				continue
+			}
+			seen[file.GetName()] = struct{}{}
+			fileDescriptorSet.File = append(fileDescriptorSet.File, file)
+		}
+	}
+	return protodesc.NewFiles(fileDescriptorSet)
+}
+
+func fieldTypeString(fieldDescriptor protoreflect.FieldDescriptor) string {
+	var typeString string
+	switch {
+	case fieldDescriptor.IsMap():
+		return fmt.Sprintf(
+			"map<%s, %s>",
+			fieldTypeString(fieldDescriptor.MapKey()),
+			fieldTypeString(fieldDescriptor.MapValue()),
+		)
+	case fieldDescriptor.Message() != nil:
+		typeString = string(fieldDescriptor.Message().FullName())
+	case fieldDescriptor.Enum() != nil:
+		typeString = string(fieldDescriptor.Enum().FullName())
+	default:
+		typeString = fieldDescriptor.Kind().String()
+	}
+	if fieldDescriptor.IsList() {
+		return "repeated " + typeString
+	}
+	return typeString
+}
+
+// splitFirstWord splits the line into its first word and the remainder of the line.
+func splitFirstWord(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	index := strings.IndexAny(line, " \t")
+	if index < 0 {
+		return line, ""
+	}
+	return line[:index], line[index+1:]
+}
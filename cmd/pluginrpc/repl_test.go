@@ -0,0 +1,120 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+)
+
+const echoPluginProgramName = "echo-plugin"
+
+func TestRepl(t *testing.T) {
+	t.Parallel()
+
+	data, err := proto.Marshal(newFileDescriptorSet(examplev1.File_pluginrpc_example_v1_example_proto))
+	require.NoError(t, err)
+	descriptorSetFilePath := filepath.Join(t.TempDir(), "image.binpb")
+	require.NoError(t, os.WriteFile(descriptorSetFilePath, data, 0o600))
+
+	stdin := strings.NewReader(
+		strings.Join(
+			[]string{
+				"list",
+				"complete call /pluginrpc.example.v1.EchoService/EchoRe",
+				`complete call /pluginrpc.example.v1.EchoService/EchoRequest {"me`,
+				"describe /pluginrpc.example.v1.EchoService/EchoStream",
+				`call /pluginrpc.example.v1.EchoService/EchoRequest {"message":"hello"}`,
+				`call /pluginrpc.example.v1.EchoService/EchoStream {"messages":["foo","bar"]}`,
+				"call /pluginrpc.example.v1.EchoService/EchoError",
+				"exit",
+				"list",
+			},
+			"\n",
+		),
+	)
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	require.NoError(
+		t,
+		run(
+			context.Background(),
+			[]string{"repl", "--descriptor-set", descriptorSetFilePath, echoPluginProgramName},
+			stdin,
+			stdout,
+			stderr,
+		),
+	)
+	output := stdout.String()
+	require.Contains(t, output, "/pluginrpc.example.v1.EchoService/EchoRequest (echo request)\n")
+	require.Contains(t, output, "/pluginrpc.example.v1.EchoService/EchoList\n")
+	require.Contains(t, output, replPrompt+"/pluginrpc.example.v1.EchoService/EchoRequest\n")
+	require.Contains(t, output, replPrompt+`{"message":`+"\n")
+	require.Contains(t, output, "response stream pluginrpc.example.v1.EchoStreamResponse\n  message: string\n")
+	require.Contains(t, output, "{\n  \"message\": \"hello\"\n}\n")
+	require.Contains(t, output, "{\n  \"message\": \"foo\"\n}\n{\n  \"message\": \"bar\"\n}\n")
+	require.Contains(t, output, "error: ")
+	// Nothing should be handled after exit.
+	require.True(t, strings.HasSuffix(output, replPrompt))
+}
+
+func TestReplNoDescriptors(t *testing.T) {
+	t.Parallel()
+
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(
+		t,
+		run(
+			context.Background(),
+			[]string{"repl", echoPluginProgramName},
+			strings.NewReader("call /pluginrpc.example.v1.EchoService/EchoList\n"),
+			stdout,
+			bytes.NewBuffer(nil),
+		),
+	)
+	require.Contains(t, stdout.String(), "error: no descriptors available")
+}
+
+// newFileDescriptorSet returns a FileDescriptorSet containing the file and all of its
+// imports, with imports before the files that import them.
+func newFileDescriptorSet(fileDescriptor protoreflect.FileDescriptor) *descriptorpb.FileDescriptorSet {
+	fileDescriptorSet := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]struct{})
+	var add func(protoreflect.FileDescriptor)
+	add = func(fileDescriptor protoreflect.FileDescriptor) {
+		if _, ok := seen[fileDescriptor.Path()]; ok {
+			return
+		}
+		seen[fileDescriptor.Path()] = struct{}{}
+		imports := fileDescriptor.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fileDescriptorSet.File = append(fileDescriptorSet.File, protodesc.ToFileDescriptorProto(fileDescriptor))
+	}
+	add(fileDescriptor)
+	return fileDescriptorSet
+}
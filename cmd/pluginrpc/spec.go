@@ -0,0 +1,124 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/encoding/protojson"
+	"pluginrpc.com/pluginrpc"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+const (
+	specUsage = `Usage: pluginrpc spec [flags] <plugin> [plugin args...]
+
+Print the Spec of a plugin as JSON.
+
+The output can be given to the breaking command with --against.
+
+Flags:`
+
+	descriptorsFlagName = "descriptors"
+	docsFlagName        = "docs"
+)
+
+func runSpec(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flagSet := pflag.NewFlagSet("spec", pflag.ContinueOnError)
+	flagSet.SetOutput(stderr)
+	// Everything after the plugin is an argument to the plugin.
+	flagSet.SetInterspersed(false)
+	descriptors := flagSet.Bool(descriptorsFlagName, false, "Include the descriptors of the plugin, if the plugin supports --descriptors.")
+	docs := flagSet.Bool(docsFlagName, false, "Include the documentation of procedures, if the plugin supports --docs.")
+	flagSet.Usage = func() {
+		fmt.Fprintf(stderr, "%s\n%s", specUsage, flagSet.FlagUsages())
+	}
+	if err := flagSet.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return errUsage
+	}
+	if flagSet.NArg() < 1 {
+		flagSet.Usage()
+		return errUsage
+	}
+	var clientOptions []pluginrpc.ClientOption
+	if *descriptors {
+		clientOptions = append(clientOptions, pluginrpc.ClientWithSpecDescriptors())
+	}
+	if *docs {
+		clientOptions = append(clientOptions, pluginrpc.ClientWithSpecDocs())
+	}
+	spec, err := newJSONClient(flagSet.Arg(0), flagSet.Args()[1:], stderr, clientOptions...).Spec(ctx)
+	if err != nil {
+		return err
+	}
+	extProtoSpec := &extv1.Spec{
+		FileDescriptorSet: spec.FileDescriptorSet(),
+	}
+	for _, protoProcedure := range pluginrpc.NewProtoSpec(spec).GetProcedures() {
+		extProtoSpec.Procedures = append(
+			extProtoSpec.Procedures,
+			&extv1.Procedure{
+				Path: protoProcedure.GetPath(),
+				Args: protoProcedure.GetArgs(),
+				Doc:  spec.ProcedureForPath(protoProcedure.GetPath()).Doc(),
+			},
+		)
+	}
+	m, err := pluginrpc.ProtoMessageToMap(extProtoSpec)
+	if err != nil {
+		return err
+	}
+	return printMap(stdout, m)
+}
+
+// readSpec reads a Spec printed by the spec command from the file at the given path.
+func readSpec(filePath string) (pluginrpc.Spec, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	extProtoSpec := &extv1.Spec{}
+	if err := protojson.Unmarshal(data, extProtoSpec); err != nil {
+		return nil, fmt.Errorf("invalid spec in %q: %w", filePath, err)
+	}
+	procedures := make([]pluginrpc.Procedure, len(extProtoSpec.GetProcedures()))
+	for i, extProtoProcedure := range extProtoSpec.GetProcedures() {
+		procedure, err := pluginrpc.NewProcedure(
+			extProtoProcedure.GetPath(),
+			pluginrpc.ProcedureWithArgs(extProtoProcedure.GetArgs()...),
+			pluginrpc.ProcedureWithDoc(extProtoProcedure.GetDoc()),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spec in %q: %w", filePath, err)
+		}
+		procedures[i] = procedure
+	}
+	spec, err := pluginrpc.NewSpecWithOptions(
+		procedures,
+		pluginrpc.SpecWithFileDescriptorSet(extProtoSpec.GetFileDescriptorSet()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec in %q: %w", filePath, err)
+	}
+	return spec, nil
+}
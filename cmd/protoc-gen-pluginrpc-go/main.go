@@ -17,26 +17,32 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"go/token"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
 	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/pluginpb"
 	"pluginrpc.com/pluginrpc"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
 )
 
 const (
 	contextPackage   = protogen.GoImportPath("context")
+	errorsPackage    = protogen.GoImportPath("errors")
 	fmtPackage       = protogen.GoImportPath("fmt")
 	pluginrpcPackage = protogen.GoImportPath("pluginrpc.com/pluginrpc")
 
-	generatedFilenameExtension = ".pluginrpc.go"
-	generatedPackageSuffix     = "pluginrpc"
+	defaultFileExtension = ".pluginrpc.go"
+	defaultPackageSuffix = "pluginrpc"
+	protocGenGoExtension = ".pb.go"
 
 	usage = "Flags:\n  -h, --help\tPrint this help and exit.\n      --version\tPrint the version and exit."
 
@@ -45,6 +51,19 @@ const (
 	optionStreamingValueWarn   = "warn"
 	optionStreamingValueIgnore = "ignore"
 
+	optionEmptyKey        = "empty"
+	optionEmptyValueKeep  = "keep"
+	optionEmptyValueElide = "elide"
+	emptyMessageFullName  = "google.protobuf.Empty"
+
+	optionGenerateKey         = "generate"
+	optionGenerateValueClient = "client"
+	optionGenerateValueServer = "server"
+	optionGenerateValueBoth   = "both"
+
+	optionPackageSuffixKey = "package_suffix"
+	optionFileExtensionKey = "file_extension"
+
 	commentWidth = 97 // leave room for "// "
 
 	// To propagate top-level comments, we need the field number of the syntax
@@ -76,17 +95,27 @@ func main() {
 			if err := validate(plugin, flags); err != nil {
 				return err
 			}
-			return generate(plugin)
+			return generate(plugin, flags)
 		},
 	)
 }
 
 type flags struct {
-	streaming string
+	streaming      string
+	elideEmpty     bool
+	generateClient bool
+	generateServer bool
+	packageSuffix  string
+	fileExtension  string
 }
 
 func newFlags() *flags {
-	return &flags{}
+	return &flags{
+		generateClient: true,
+		generateServer: true,
+		packageSuffix:  defaultPackageSuffix,
+		fileExtension:  defaultFileExtension,
+	}
 }
 
 func (f *flags) Set(name string, value string) error {
@@ -99,6 +128,44 @@ func (f *flags) Set(name string, value string) error {
 		default:
 			return fmt.Errorf("unknown value for parameter %q: %q", name, value)
 		}
+	case optionEmptyKey:
+		switch value {
+		case optionEmptyValueKeep:
+			f.elideEmpty = false
+			return nil
+		case optionEmptyValueElide:
+			f.elideEmpty = true
+			return nil
+		default:
+			return fmt.Errorf("unknown value for parameter %q: %q", name, value)
+		}
+	case optionGenerateKey:
+		switch value {
+		case optionGenerateValueClient:
+			f.generateClient, f.generateServer = true, false
+			return nil
+		case optionGenerateValueServer:
+			f.generateClient, f.generateServer = false, true
+			return nil
+		case optionGenerateValueBoth:
+			f.generateClient, f.generateServer = true, true
+			return nil
+		default:
+			return fmt.Errorf("unknown value for parameter %q: %q", name, value)
+		}
+	case optionPackageSuffixKey:
+		// An empty suffix results in code being generated into the package of protoc-gen-go.
+		if value != "" && !token.IsIdentifier("_"+value) {
+			return fmt.Errorf("invalid value for parameter %q: %q is not a valid Go package name suffix", name, value)
+		}
+		f.packageSuffix = value
+		return nil
+	case optionFileExtensionKey:
+		if !strings.HasSuffix(value, ".go") || value == ".go" || value == protocGenGoExtension {
+			return fmt.Errorf("invalid value for parameter %q: %q must end with .go, and must not be %q", name, value, protocGenGoExtension)
+		}
+		f.fileExtension = value
+		return nil
 	default:
 		return fmt.Errorf("unknown parameter: %q", name)
 	}
@@ -121,7 +188,9 @@ func validate(plugin *protogen.Plugin, flags *flags) error {
 	var streamingMethods []*protogen.Method
 	for _, file := range plugin.Files {
 		if file.Generate {
-			streamingMethods = append(streamingMethods, getStreamingMethodsForFile(file)...)
+			// Server-streaming and bidirectional streaming methods are supported, so this
+			// is only client-streaming methods.
+			streamingMethods = append(streamingMethods, getUnsupportedMethodsForFile(file)...)
 		}
 	}
 	if len(streamingMethods) == 0 {
@@ -133,7 +202,7 @@ func validate(plugin *protogen.Plugin, flags *flags) error {
 	}
 	if streamingError {
 		// optionStreamingValueError
-		return fmt.Errorf("streaming methods are not supported: %s", strings.Join(streamingMethodStrings, ", "))
+		return fmt.Errorf("client-streaming methods are not supported: %s", strings.Join(streamingMethodStrings, ", "))
 	}
 
 	// We're now in optionStreamingValueWarn territory.
@@ -142,11 +211,11 @@ func validate(plugin *protogen.Plugin, flags *flags) error {
 	}
 	_, err := fmt.Fprintf(
 		os.Stderr,
-		`Warning: streaming methods are not supported, these methods will be skipped and not part of generated interfaces:
+		`Warning: client-streaming methods are not supported, these methods will be skipped and not part of generated interfaces:
 
 %s
 
-To error on streaming methods, set the parameter "%s=%s".
+To error on these methods, set the parameter "%s=%s".
 `,
 		strings.Join(streamingMethodStrings, "\n"),
 		optionStreamingKey,
@@ -155,10 +224,10 @@ To error on streaming methods, set the parameter "%s=%s".
 	return err
 }
 
-func generate(plugin *protogen.Plugin) error {
+func generate(plugin *protogen.Plugin, flags *flags) error {
 	for _, file := range plugin.Files {
 		if file.Generate {
-			if err := generateFile(plugin, file); err != nil {
+			if err := generateFile(plugin, file, flags); err != nil {
 				return err
 			}
 		}
@@ -166,46 +235,66 @@ func generate(plugin *protogen.Plugin) error {
 	return nil
 }
 
-func generateFile(plugin *protogen.Plugin, file *protogen.File) error {
-	if len(getUnaryMethodsForFile(file)) == 0 {
+func generateFile(plugin *protogen.Plugin, file *protogen.File, flags *flags) error {
+	if len(getSupportedMethodsForFile(file)) == 0 {
 		return nil
 	}
 
-	file.GoPackageName += generatedPackageSuffix
-
-	generatedFilenamePrefixToSlash := filepath.ToSlash(file.GeneratedFilenamePrefix)
-	file.GeneratedFilenamePrefix = path.Join(
-		path.Dir(generatedFilenamePrefixToSlash),
-		string(file.GoPackageName),
-		path.Base(generatedFilenamePrefixToSlash),
-	)
-	generatedFile := plugin.NewGeneratedFile(
-		file.GeneratedFilenamePrefix+generatedFilenameExtension,
-		protogen.GoImportPath(path.Join(
+	goImportPath := file.GoImportPath
+	if flags.packageSuffix != "" {
+		// The code is generated into a separate package, nested within the directory of the
+		// package generated by protoc-gen-go.
+		file.GoPackageName += protogen.GoPackageName(flags.packageSuffix)
+		generatedFilenamePrefixToSlash := filepath.ToSlash(file.GeneratedFilenamePrefix)
+		file.GeneratedFilenamePrefix = path.Join(
+			path.Dir(generatedFilenamePrefixToSlash),
+			string(file.GoPackageName),
+			path.Base(generatedFilenamePrefixToSlash),
+		)
+		goImportPath = protogen.GoImportPath(path.Join(
 			string(file.GoImportPath),
 			string(file.GoPackageName),
-		)),
+		))
+	}
+	generatedFile := plugin.NewGeneratedFile(
+		file.GeneratedFilenamePrefix+flags.fileExtension,
+		goImportPath,
 	)
-	generatedFile.Import(file.GoImportPath)
+	if goImportPath != file.GoImportPath {
+		generatedFile.Import(file.GoImportPath)
+	}
 
 	generatePreamble(generatedFile, file)
 	generatePathConstants(generatedFile, file)
 	for _, service := range file.Services {
 		names := newNames(service)
 		generateSpecBuilder(generatedFile, service, names)
-		generateClientInterface(generatedFile, service, names)
-		generateClientConstructor(generatedFile, service, names)
-		generateHandlerInterface(generatedFile, service, names)
-		generateServerInterface(generatedFile, service, names)
-		generateServerConstructor(generatedFile, service, names)
-		generateServerRegister(generatedFile, service, names)
+		generateDefaultSpec(generatedFile, service, names)
+		generateSpecDescriptor(generatedFile, service, names)
+		if flags.generateClient {
+			generateClientInterface(generatedFile, service, names, flags)
+			generateClientConstructor(generatedFile, service, names)
+		}
+		if flags.generateServer {
+			generateHandlerInterface(generatedFile, service, names, flags)
+			generateUnimplementedHandler(generatedFile, service, names, flags)
+			generateServerInterface(generatedFile, service, names)
+			generateServerConstructor(generatedFile, service, names)
+			generateServerRegister(generatedFile, service, names)
+			generateServerForHandlerConstructor(generatedFile, service, names)
+			generateRegistration(generatedFile, service, names)
+		}
 	}
 	generatedFile.P("// *** PRIVATE ***")
 	generatedFile.P()
 	for _, service := range file.Services {
 		names := newNames(service)
-		generateClientImplementation(generatedFile, service, names)
-		generateServerImplementation(generatedFile, service, names)
+		if flags.generateClient {
+			generateClientImplementation(generatedFile, service, names, flags)
+		}
+		if flags.generateServer {
+			generateServerImplementation(generatedFile, service, names, flags)
+		}
 	}
 	return nil
 }
@@ -255,12 +344,12 @@ func generatePreamble(g *protogen.GeneratedFile, file *protogen.File) {
 }
 
 func generatePathConstants(g *protogen.GeneratedFile, file *protogen.File) {
-	unaryMethods := getUnaryMethodsForFile(file)
-	if len(unaryMethods) == 0 {
+	supportedMethods := getSupportedMethodsForFile(file)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	g.P("const (")
-	for _, method := range unaryMethods {
+	for _, method := range supportedMethods {
 		wrapComments(g, pathConstName(method), " is the path of the ",
 			method.Parent.Desc.Name(), "'s ", method.Desc.Name(), " RPC.")
 		g.P(pathConstName(method), ` = "`, fmt.Sprintf("/%s/%s", method.Parent.Desc.FullName(), method.Desc.Name()), `"`)
@@ -270,8 +359,8 @@ func generatePathConstants(g *protogen.GeneratedFile, file *protogen.File) {
 }
 
 func generateSpecBuilder(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	wrapComments(g, names.SpecBuilder, " builds a Spec for the ", service.Desc.FullName(), " service.")
@@ -281,32 +370,123 @@ func generateSpecBuilder(g *protogen.GeneratedFile, service *protogen.Service, n
 	}
 	g.AnnotateSymbol(names.SpecBuilder, protogen.Annotation{Location: service.Location})
 	g.P("type ", names.SpecBuilder, " struct {")
-	for _, method := range unaryMethods {
+	for _, method := range supportedMethods {
 		g.P(method.GoName, " []", pluginrpcPackage.Ident("ProcedureOption"))
 	}
 	g.P("}")
 	g.P()
 	wrapComments(g, "Build builds a Spec for the ", service.Desc.FullName(), " service.")
-	g.P("func (s ", names.SpecBuilder, ") Build() (", pluginrpcPackage.Ident("Spec"), ", error) {")
-	g.P("procedures := make([]", pluginrpcPackage.Ident("Procedure"), ", 0, ", len(unaryMethods), ")")
-	for i, method := range unaryMethods {
+	g.P("func (s ", names.SpecBuilder, ") Build(options ...", pluginrpcPackage.Ident("SpecOption"), ") (", pluginrpcPackage.Ident("Spec"), ", error) {")
+	g.P("procedures := make([]", pluginrpcPackage.Ident("Procedure"), ", 0, ", len(supportedMethods), ")")
+	for i, method := range supportedMethods {
 		equals := "="
 		if i == 0 {
 			equals = ":="
 		}
-		g.P("procedure, err ", equals, " ", pluginrpcPackage.Ident("NewProcedure"), "(", pathConstName(method), ", s.", method.GoName, "...)")
+		var defaultOptions []string
+		if doc := getMethodDoc(method); doc != "" {
+			defaultOptions = append(defaultOptions, g.QualifiedGoIdent(pluginrpcPackage.Ident("ProcedureWithDoc"))+"("+strconv.Quote(doc)+")")
+		}
+		if args := getMethodArgs(method); len(args) > 0 {
+			quotedArgs := make([]string, len(args))
+			for i, arg := range args {
+				quotedArgs[i] = strconv.Quote(arg)
+			}
+			defaultOptions = append(defaultOptions, g.QualifiedGoIdent(pluginrpcPackage.Ident("ProcedureWithArgs"))+"("+strings.Join(quotedArgs, ", ")+")")
+		}
+		if len(defaultOptions) > 0 {
+			// The defaults are specified first so that they can be overridden by the options of the field.
+			g.P("procedure, err ", equals, " ", pluginrpcPackage.Ident("NewProcedure"), "(")
+			g.P(pathConstName(method), ",")
+			g.P("append([]", pluginrpcPackage.Ident("ProcedureOption"), "{", strings.Join(defaultOptions, ", "), "}, s.", method.GoName, "...)...,")
+			g.P(")")
+		} else {
+			g.P("procedure, err ", equals, " ", pluginrpcPackage.Ident("NewProcedure"), "(", pathConstName(method), ", s.", method.GoName, "...)")
+		}
 		g.P("if err != nil {")
 		g.P("return nil, err")
 		g.P("}")
 		g.P("procedures = append(procedures, procedure)")
 	}
-	g.P("return ", pluginrpcPackage.Ident("NewSpec"), "(procedures...)")
+	g.P("return ", pluginrpcPackage.Ident("NewSpecWithOptions"), "(procedures, options...)")
 	g.P("}")
 	g.P()
 }
-func generateClientInterface(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+
+func generateDefaultSpec(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+	if len(getSupportedMethodsForService(service)) == 0 {
+		return
+	}
+	wrapComments(g, names.DefaultSpec, " returns the default Spec for the ", service.Desc.FullName(), " service.")
+	g.P("//")
+	wrapComments(g, "This is equivalent to building an empty ", names.SpecBuilder, ", that is the Procedures ",
+		"have the docs and args declared in the proto file. Use this in both the plugin and its tests ",
+		"so that they cannot drift apart.")
+	if isDeprecatedService(service) {
+		g.P("//")
+		deprecated(g)
+	}
+	g.P("func ", names.DefaultSpec, "(options ...", pluginrpcPackage.Ident("SpecOption"), ") (", pluginrpcPackage.Ident("Spec"), ", error) {")
+	g.P("return ", names.SpecBuilder, "{}.Build(options...)")
+	g.P("}")
+	g.P()
+}
+
+// getMethodDoc returns the leading comments of the method, with the leading space of
+// each line removed.
+func getMethodDoc(method *protogen.Method) string {
+	lines := strings.Split(strings.TrimSuffix(string(method.Comments.Leading), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// getMethodArgs returns the args of the method from the pluginrpc.ext.v1.method option.
+func getMethodArgs(method *protogen.Method) []string {
+	methodOptions, ok := method.Desc.Options().(*descriptorpb.MethodOptions)
+	if !ok {
+		return nil
+	}
+	extMethodOptions, ok := proto.GetExtension(methodOptions, extv1.E_Method).(*extv1.MethodOptions)
+	if !ok {
+		return nil
+	}
+	return strings.Fields(extMethodOptions.GetArgs())
+}
+
+func generateSpecDescriptor(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
+		return
+	}
+	wrapComments(g, names.SpecDescriptor, " describes the ", service.Desc.FullName(), " service.")
+	if isDeprecatedService(service) {
+		g.P("//")
+		deprecated(g)
+	}
+	g.AnnotateSymbol(names.SpecDescriptor, protogen.Annotation{Location: service.Location})
+	g.P("var ", names.SpecDescriptor, " = ", pluginrpcPackage.Ident("ServiceSpecDescriptor"), "{")
+	g.P(`FullName: "`, service.Desc.FullName(), `",`)
+	g.P("Methods: []", pluginrpcPackage.Ident("MethodSpecDescriptor"), "{")
+	for _, method := range supportedMethods {
+		g.P("{")
+		g.P(`Name: "`, method.Desc.Name(), `",`)
+		g.P("Path: ", pathConstName(method), ",")
+		g.P("InputType: (&", method.Input.GoIdent, "{}).ProtoReflect().Type(),")
+		g.P("OutputType: (&", method.Output.GoIdent, "{}).ProtoReflect().Type(),")
+		g.P("ClientStreaming: ", method.Desc.IsStreamingClient(), ",")
+		g.P("ServerStreaming: ", method.Desc.IsStreamingServer(), ",")
+		g.P("},")
+	}
+	g.P("},")
+	g.P("}")
+	g.P()
+}
+
+func generateClientInterface(g *protogen.GeneratedFile, service *protogen.Service, names names, flags *flags) {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	wrapComments(g, names.Client, " is a client for the ", service.Desc.FullName(), " service.")
@@ -316,22 +496,22 @@ func generateClientInterface(g *protogen.GeneratedFile, service *protogen.Servic
 	}
 	g.AnnotateSymbol(names.Client, protogen.Annotation{Location: service.Location})
 	g.P("type ", names.Client, " interface {")
-	for _, method := range unaryMethods {
+	for _, method := range supportedMethods {
 		g.AnnotateSymbol(names.Client+"."+method.GoName, protogen.Annotation{Location: method.Location})
 		leadingComments(
 			g,
 			method.Comments.Leading,
 			isDeprecatedMethod(method),
 		)
-		g.P(clientSignature(g, method, false /* named */))
+		g.P(clientSignature(g, method, false /* named */, flags))
 	}
 	g.P("}")
 	g.P()
 }
 
 func generateClientConstructor(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	// Client constructor.
@@ -350,9 +530,9 @@ func generateClientConstructor(g *protogen.GeneratedFile, service *protogen.Serv
 	g.P()
 }
 
-func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names, flags *flags) {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	// Client struct.
@@ -361,21 +541,61 @@ func generateClientImplementation(g *protogen.GeneratedFile, service *protogen.S
 	g.P("client ", pluginrpcPackage.Ident("Client"))
 	g.P("}")
 	g.P()
-	for _, method := range unaryMethods {
-		generateClientMethod(g, method, names)
+	for _, method := range supportedMethods {
+		generateClientMethod(g, method, names, flags)
 	}
 }
 
-func generateClientMethod(g *protogen.GeneratedFile, method *protogen.Method, names names) {
+func generateClientMethod(g *protogen.GeneratedFile, method *protogen.Method, names names, flags *flags) {
 	receiver := names.ClientImpl
 	wrapComments(g, method.GoName, " calls ", method.Desc.FullName(), ".")
 	if isDeprecatedMethod(method) {
 		g.P("//")
 		deprecated(g)
 	}
-	g.P("func (c *", receiver, ") ", clientSignature(g, method, true /* named */), " {")
+	req := "req"
+	if isElidedMessage(method.Input, flags) {
+		req = "nil"
+	}
+	g.P("func (c *", receiver, ") ", clientSignature(g, method, true /* named */, flags), " {")
+	if isBidiStreamingMethod(method) {
+		g.P("return c.client.CallBidiStream(ctx, ", pathConstName(method), ", opts...)")
+		g.P("}")
+		g.P()
+		return
+	}
+	if isServerStreamingMethod(method) {
+		g.P("return c.client.CallServerStream(")
+		g.P("ctx,")
+		g.P(pathConstName(method), ",")
+		g.P(req, ",")
+		g.P("func() any {")
+		g.P("return &", g.QualifiedGoIdent(method.Output.GoIdent), "{}")
+		g.P("},")
+		g.P("func(anyRes any) error {")
+		g.P("res, ok := anyRes.(*", g.QualifiedGoIdent(method.Output.GoIdent), ")")
+		g.P("if !ok {")
+		g.P("return ", fmtPackage.Ident("Errorf"), `("could not cast %T to a *`, g.QualifiedGoIdent(method.Output.GoIdent), `", anyRes)`)
+		g.P("}")
+		g.P("return onResponse(res)")
+		g.P("},")
+		g.P("opts...,")
+		g.P(")")
+		g.P("}")
+		g.P()
+		return
+	}
 	g.P("res := &", g.QualifiedGoIdent(method.Output.GoIdent), "{}")
-	g.P("if err := c.client.Call(ctx, ", pathConstName(method), ", req, res, opts...); err != nil {")
+	if isElidedMessage(method.Output, flags) {
+		g.P("return c.client.Call(ctx, ", pathConstName(method), ", ", req, ", res, opts...)")
+		g.P("}")
+		g.P()
+		return
+	}
+	g.P("if err := c.client.Call(ctx, ", pathConstName(method), ", ", req, ", res, opts...); err != nil {")
+	g.P("if ", pluginrpcPackage.Ident("HasPartialResult"), "(err) {")
+	g.P("return res, err")
+	g.P("}")
 	g.P("return nil, err")
 	g.P("}")
 	g.P("return res, nil")
@@ -383,9 +603,9 @@ func generateClientMethod(g *protogen.GeneratedFile, method *protogen.Method, na
 	g.P()
 }
 
-func generateHandlerInterface(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+func generateHandlerInterface(g *protogen.GeneratedFile, service *protogen.Service, names names, flags *flags) {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	wrapComments(g, names.Handler, " is an implementation of the ", service.Desc.FullName(), " service.")
@@ -395,22 +615,53 @@ func generateHandlerInterface(g *protogen.GeneratedFile, service *protogen.Servi
 	}
 	g.AnnotateSymbol(names.Handler, protogen.Annotation{Location: service.Location})
 	g.P("type ", names.Handler, " interface {")
-	for _, method := range unaryMethods {
+	for _, method := range supportedMethods {
 		leadingComments(
 			g,
 			method.Comments.Leading,
 			isDeprecatedMethod(method),
 		)
 		g.AnnotateSymbol(names.Handler+"."+method.GoName, protogen.Annotation{Location: method.Location})
-		g.P(handlerSignature(g, method))
+		g.P(handlerSignature(g, method, flags))
 	}
 	g.P("}")
 	g.P()
 }
 
+func generateUnimplementedHandler(g *protogen.GeneratedFile, service *protogen.Service, names names, flags *flags) {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
+		return
+	}
+	wrapComments(g, names.UnimplementedHandler, " returns CodeUnimplemented from all methods.")
+	g.P("//")
+	wrapComments(g, "Embed ", names.UnimplementedHandler, " in implementations of ", names.Handler,
+		" so that adding methods to the ", service.Desc.FullName(), " service does not break compilation.")
+	if isDeprecatedService(service) {
+		g.P("//")
+		deprecated(g)
+	}
+	g.AnnotateSymbol(names.UnimplementedHandler, protogen.Annotation{Location: service.Location})
+	g.P("type ", names.UnimplementedHandler, " struct{}")
+	g.P()
+	for _, method := range supportedMethods {
+		g.AnnotateSymbol(names.UnimplementedHandler+"."+method.GoName, protogen.Annotation{Location: method.Location})
+		g.P("func (", names.UnimplementedHandler, ") ", handlerSignature(g, method, flags), " {")
+		returnValues := g.QualifiedGoIdent(pluginrpcPackage.Ident("NewErrorf")) + "(" +
+			g.QualifiedGoIdent(pluginrpcPackage.Ident("CodeUnimplemented")) +
+			`, "procedure not implemented: %q", ` + pathConstName(method) + ")"
+		if !isServerStreamingMethod(method) && !isBidiStreamingMethod(method) && !isElidedMessage(method.Output, flags) {
+			returnValues = "nil, " + returnValues
+		}
+		g.P("return ", returnValues)
+		g.P("}")
+		g.P()
+	}
+}
+
 func generateServerInterface(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	wrapComments(g, names.Server, " serves the ", service.Desc.FullName(), " service.")
@@ -420,7 +671,7 @@ func generateServerInterface(g *protogen.GeneratedFile, service *protogen.Servic
 	}
 	g.AnnotateSymbol(names.Server, protogen.Annotation{Location: service.Location})
 	g.P("type ", names.Server, " interface {")
-	for _, method := range unaryMethods {
+	for _, method := range supportedMethods {
 		leadingComments(
 			g,
 			method.Comments.Leading,
@@ -434,8 +685,8 @@ func generateServerInterface(g *protogen.GeneratedFile, service *protogen.Servic
 }
 
 func generateServerConstructor(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	wrapComments(g, names.ServerConstructor, " constructs a server for the ", service.Desc.FullName(), " service.")
@@ -455,8 +706,8 @@ func generateServerConstructor(g *protogen.GeneratedFile, service *protogen.Serv
 }
 
 func generateServerRegister(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	wrapComments(g, names.ServerRegister, " registers the server for the ", service.Desc.FullName(), " service.")
@@ -467,16 +718,83 @@ func generateServerRegister(g *protogen.GeneratedFile, service *protogen.Service
 	}
 	g.P("func ", names.ServerRegister, " (serverRegistrar ", pluginrpcPackage.Ident("ServerRegistrar"),
 		", ", unexport(names.Server), " ", names.Server, ") {")
-	for _, method := range unaryMethods {
-		g.P("serverRegistrar.Register(", pathConstName(method), ", ", unexport(names.Server), ".", method.GoName, ")")
+	for _, method := range supportedMethods {
+		g.P("serverRegistrar.RegisterTyped(")
+		g.P(pathConstName(method), ",")
+		g.P(`"`, method.Input.Desc.FullName(), `",`)
+		g.P(`"`, method.Output.Desc.FullName(), `",`)
+		g.P(unexport(names.Server), ".", method.GoName, ",")
+		g.P(")")
 	}
 	g.P("}")
 	g.P()
 }
 
-func generateServerImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names) {
-	unaryMethods := getUnaryMethodsForService(service)
-	if len(unaryMethods) == 0 {
+func generateServerForHandlerConstructor(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+	if len(getSupportedMethodsForService(service)) == 0 {
+		return
+	}
+	wrapComments(g, names.ServerForHandler, " returns a new Server for the ", service.Desc.FullName(), " service.")
+	g.P("//")
+	wrapComments(g, "The Spec is built with ", names.DefaultSpec, ", and the handler is registered with ",
+		names.ServerConstructor, " and ", names.ServerRegister, ". Use these directly to serve ",
+		"multiple services, or to override the defaults of the Spec.")
+	if isDeprecatedService(service) {
+		g.P("//")
+		deprecated(g)
+	}
+	g.P("func ", names.ServerForHandler, "(", unexport(names.Handler), " ", names.Handler,
+		", options ...", pluginrpcPackage.Ident("ServerForHandlerOption"), ") (", pluginrpcPackage.Ident("Server"), ", error) {")
+	g.P("return ", pluginrpcPackage.Ident("NewServerForHandler"), "(")
+	g.P(names.DefaultSpec, ",")
+	g.P("func(serverRegistrar ", pluginrpcPackage.Ident("ServerRegistrar"), ", handler ", pluginrpcPackage.Ident("Handler"), ") {")
+	g.P(names.ServerRegister, "(serverRegistrar, ", names.ServerConstructor, "(handler, ", unexport(names.Handler), "))")
+	g.P("},")
+	g.P("options...,")
+	g.P(")")
+	g.P("}")
+	g.P()
+}
+
+func generateRegistration(g *protogen.GeneratedFile, service *protogen.Service, names names) {
+	if len(getSupportedMethodsForService(service)) == 0 {
+		return
+	}
+	wrapComments(g, names.Registration, " is the registration of the ", service.Desc.FullName(),
+		" service for pluginrpc.MainService and pluginrpc.NewServerForServices.")
+	if isDeprecatedService(service) {
+		g.P("//")
+		deprecated(g)
+	}
+	g.AnnotateSymbol(names.Registration, protogen.Annotation{Location: service.Location})
+	g.P("type ", names.Registration, " struct {")
+	g.P("// Handler is the implementation of the service.")
+	g.P("//")
+	g.P("// Required.")
+	g.P("Handler ", names.Handler)
+	g.P("// Spec overrides the defaults of the Procedures of the service, for example their args.")
+	g.P("Spec ", names.SpecBuilder)
+	g.P("}")
+	g.P()
+	g.P("// BuildSpec implements pluginrpc.ServiceRegistration.")
+	g.P("func (r ", names.Registration, ") BuildSpec() (", pluginrpcPackage.Ident("Spec"), ", error) {")
+	g.P("if r.Handler == nil {")
+	g.P("return nil, ", errorsPackage.Ident("New"), "(\"", names.Registration, ": Handler is required\")")
+	g.P("}")
+	g.P("return r.Spec.Build()")
+	g.P("}")
+	g.P()
+	g.P("// Register implements pluginrpc.ServiceRegistration.")
+	g.P("func (r ", names.Registration, ") Register(serverRegistrar ", pluginrpcPackage.Ident("ServerRegistrar"),
+		", handler ", pluginrpcPackage.Ident("Handler"), ") {")
+	g.P(names.ServerRegister, "(serverRegistrar, ", names.ServerConstructor, "(handler, r.Handler))")
+	g.P("}")
+	g.P()
+}
+
+func generateServerImplementation(g *protogen.GeneratedFile, service *protogen.Service, names names, flags *flags) {
+	supportedMethods := getSupportedMethodsForService(service)
+	if len(supportedMethods) == 0 {
 		return
 	}
 	wrapComments(g, names.ServerImpl, " implements ", names.Server, ".")
@@ -485,12 +803,12 @@ func generateServerImplementation(g *protogen.GeneratedFile, service *protogen.S
 	g.P(unexport(names.Handler), " ", names.Handler)
 	g.P("}")
 	g.P()
-	for _, method := range unaryMethods {
-		generateServerMethod(g, method, names)
+	for _, method := range supportedMethods {
+		generateServerMethod(g, method, names, flags)
 	}
 }
 
-func generateServerMethod(g *protogen.GeneratedFile, method *protogen.Method, names names) {
+func generateServerMethod(g *protogen.GeneratedFile, method *protogen.Method, names names, flags *flags) {
 	receiver := names.ServerImpl
 	wrapComments(g, method.GoName, " calls ", method.Desc.FullName(), ".")
 	if isDeprecatedMethod(method) {
@@ -498,16 +816,107 @@ func generateServerMethod(g *protogen.GeneratedFile, method *protogen.Method, na
 		deprecated(g)
 	}
 	g.P("func (c *", receiver, ") ", serverSignature(g, method, true /* named */), " {")
+	if isBidiStreamingMethod(method) {
+		generateBidiStreamingMethodBody(g, method, names)
+		return
+	}
+	if isServerStreamingMethod(method) {
+		generateServerStreamingMethodBody(g, method, names, flags)
+		return
+	}
 	g.P("return c.handler.Handle(")
 	g.P("ctx,")
 	g.P("handleEnv,")
 	g.P("&", g.QualifiedGoIdent(method.Input.GoIdent), "{},")
-	g.P("func(ctx ", contextPackage.Ident("Context"), ", anyReq any) (any, error) {")
+	if isElidedMessage(method.Input, flags) {
+		g.P("func(ctx ", contextPackage.Ident("Context"), ", _ any) (any, error) {")
+	} else {
+		g.P("func(ctx ", contextPackage.Ident("Context"), ", anyReq any) (any, error) {")
+		g.P("req, ok := anyReq.(*", g.QualifiedGoIdent(method.Input.GoIdent), ")")
+		g.P("if !ok {")
+		g.P("return nil, ", fmtPackage.Ident("Errorf"), `("could not cast %T to a *`, g.QualifiedGoIdent(method.Input.GoIdent), `", anyReq)`)
+		g.P("}")
+	}
+	handlerArgs := "ctx, req"
+	if isElidedMessage(method.Input, flags) {
+		handlerArgs = "ctx"
+	}
+	if isElidedMessage(method.Output, flags) {
+		g.P("if err := c.", unexport(names.Handler), ".", method.GoName, "(", handlerArgs, "); err != nil {")
+		g.P("return nil, err")
+		g.P("}")
+		g.P("return &", g.QualifiedGoIdent(method.Output.GoIdent), "{}, nil")
+	} else {
+		g.P("return c.", unexport(names.Handler), ".", method.GoName, "(", handlerArgs, ")")
+	}
+	g.P("},")
+	g.P("options...,")
+	g.P(")")
+	g.P("}")
+	g.P()
+}
+
+func generateServerStreamingMethodBody(g *protogen.GeneratedFile, method *protogen.Method, names names, flags *flags) {
+	g.P("return c.handler.HandleServerStream(")
+	g.P("ctx,")
+	g.P("handleEnv,")
+	g.P("&", g.QualifiedGoIdent(method.Input.GoIdent), "{},")
+	if isElidedMessage(method.Input, flags) {
+		g.P("func(ctx ", contextPackage.Ident("Context"), ", _ any, send func(any) error) error {")
+	} else {
+		g.P("func(ctx ", contextPackage.Ident("Context"), ", anyReq any, send func(any) error) error {")
+		g.P("req, ok := anyReq.(*", g.QualifiedGoIdent(method.Input.GoIdent), ")")
+		g.P("if !ok {")
+		g.P("return ", fmtPackage.Ident("Errorf"), `("could not cast %T to a *`, g.QualifiedGoIdent(method.Input.GoIdent), `", anyReq)`)
+		g.P("}")
+	}
+	handlerArgs := "ctx, req"
+	if isElidedMessage(method.Input, flags) {
+		handlerArgs = "ctx"
+	}
+	g.P("return c.", unexport(names.Handler), ".", method.GoName, "(")
+	g.P(handlerArgs, ",")
+	g.P("func(res *", g.QualifiedGoIdent(method.Output.GoIdent), ") error {")
+	g.P("return send(res)")
+	g.P("},")
+	g.P(")")
+	g.P("},")
+	g.P("options...,")
+	g.P(")")
+	g.P("}")
+	g.P()
+}
+
+// generateBidiStreamingMethodBody generates the body of a server method for a
+// bidirectional streaming method.
+//
+// Messages are never elided for bidirectional streaming methods, as every request and
+// response is a separate message on the stream.
+func generateBidiStreamingMethodBody(g *protogen.GeneratedFile, method *protogen.Method, names names) {
+	g.P("return c.handler.HandleBidiStream(")
+	g.P("ctx,")
+	g.P("handleEnv,")
+	g.P("func() any {")
+	g.P("return &", g.QualifiedGoIdent(method.Input.GoIdent), "{}")
+	g.P("},")
+	g.P("func(ctx ", contextPackage.Ident("Context"), ", receive func() (any, error), send func(any) error) error {")
+	g.P("return c.", unexport(names.Handler), ".", method.GoName, "(")
+	g.P("ctx,")
+	g.P("func() (*", g.QualifiedGoIdent(method.Input.GoIdent), ", error) {")
+	g.P("anyReq, err := receive()")
+	g.P("if err != nil {")
+	g.P("return nil, err")
+	g.P("}")
 	g.P("req, ok := anyReq.(*", g.QualifiedGoIdent(method.Input.GoIdent), ")")
 	g.P("if !ok {")
 	g.P("return nil, ", fmtPackage.Ident("Errorf"), `("could not cast %T to a *`, g.QualifiedGoIdent(method.Input.GoIdent), `", anyReq)`)
 	g.P("}")
-	g.P("return c.", unexport(names.Handler), ".", method.GoName, "(ctx, req)")
+	g.P("return req, nil")
+	g.P("},")
+	g.P("func(res *", g.QualifiedGoIdent(method.Output.GoIdent), ") error {")
+	g.P("return send(res)")
+	g.P("},")
+	g.P(")")
 	g.P("},")
 	g.P("options...,")
 	g.P(")")
@@ -515,39 +924,82 @@ func generateServerMethod(g *protogen.GeneratedFile, method *protogen.Method, na
 	g.P()
 }
 
-func clientSignature(g *protogen.GeneratedFile, method *protogen.Method, named bool) string {
+func clientSignature(g *protogen.GeneratedFile, method *protogen.Method, named bool, flags *flags) string {
 	// unary; symmetric so we can re-use server templating
-	return method.GoName + clientSignatureParams(g, method, named)
+	return method.GoName + clientSignatureParams(g, method, named, flags)
 }
 
-func clientSignatureParams(g *protogen.GeneratedFile, method *protogen.Method, named bool) string {
+func clientSignatureParams(g *protogen.GeneratedFile, method *protogen.Method, named bool, flags *flags) string {
 	ctxName := "ctx "
 	reqName := "req "
 	optsName := "opts "
 	if !named {
 		ctxName, reqName, optsName = "", "", ""
 	}
+	params := "(" + ctxName + g.QualifiedGoIdent(contextPackage.Ident("Context"))
+	if isBidiStreamingMethod(method) {
+		params += ", " + optsName + "..." + g.QualifiedGoIdent(pluginrpcPackage.Ident("CallOption")) + ") "
+		return params + "(" + g.QualifiedGoIdent(pluginrpcPackage.Ident("BidiStream")) + ", error)"
+	}
 	// unary
-	return "(" + ctxName + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
-		", " + reqName + "*" + g.QualifiedGoIdent(method.Input.GoIdent) +
-		", " + optsName + "..." + g.QualifiedGoIdent(pluginrpcPackage.Ident("CallOption")) + ") " +
-		"(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+	if !isElidedMessage(method.Input, flags) {
+		params += ", " + reqName + "*" + g.QualifiedGoIdent(method.Input.GoIdent)
+	}
+	if isServerStreamingMethod(method) {
+		onResponseName := "onResponse "
+		if !named {
+			onResponseName = ""
+		}
+		params += ", " + onResponseName + "func(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ") error"
+		params += ", " + optsName + "..." + g.QualifiedGoIdent(pluginrpcPackage.Ident("CallOption")) + ") "
+		return params + "error"
+	}
+	params += ", " + optsName + "..." + g.QualifiedGoIdent(pluginrpcPackage.Ident("CallOption")) + ") "
+	return params + resultsSignature(g, method, flags)
 }
 
-func handlerSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
-	return method.GoName + handlerSignatureParams(g, method, false)
+func handlerSignature(g *protogen.GeneratedFile, method *protogen.Method, flags *flags) string {
+	return method.GoName + handlerSignatureParams(g, method, false, flags)
 }
 
-func handlerSignatureParams(g *protogen.GeneratedFile, method *protogen.Method, named bool) string {
+func handlerSignatureParams(g *protogen.GeneratedFile, method *protogen.Method, named bool, flags *flags) string {
 	ctxName := "ctx "
 	reqName := "req "
 	if !named {
 		ctxName, reqName = "", ""
 	}
+	params := "(" + ctxName + g.QualifiedGoIdent(contextPackage.Ident("Context"))
+	if isBidiStreamingMethod(method) {
+		receiveName := "receive "
+		sendName := "send "
+		if !named {
+			receiveName, sendName = "", ""
+		}
+		params += ", " + receiveName + "func() (*" + g.QualifiedGoIdent(method.Input.GoIdent) + ", error)"
+		params += ", " + sendName + "func(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ") error) "
+		return params + "error"
+	}
 	// unary
-	return "(" + ctxName + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
-		", " + reqName + "*" + g.QualifiedGoIdent(method.Input.GoIdent) + ") " +
-		"(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
+	if !isElidedMessage(method.Input, flags) {
+		params += ", " + reqName + "*" + g.QualifiedGoIdent(method.Input.GoIdent)
+	}
+	if isServerStreamingMethod(method) {
+		sendName := "send "
+		if !named {
+			sendName = ""
+		}
+		params += ", " + sendName + "func(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ") error) "
+		return params + "error"
+	}
+	params += ") "
+	return params + resultsSignature(g, method, flags)
+}
+
+func resultsSignature(g *protogen.GeneratedFile, method *protogen.Method, flags *flags) string {
+	if isElidedMessage(method.Output, flags) {
+		return "error"
+	}
+	return "(*" + g.QualifiedGoIdent(method.Output.GoIdent) + ", error)"
 }
 
 func serverSignature(g *protogen.GeneratedFile, method *protogen.Method, named bool) string {
@@ -561,7 +1013,7 @@ func serverSignatureParams(g *protogen.GeneratedFile, _ *protogen.Method, named
 	if !named {
 		ctxName, handleEnvName, optionsName = "", "", ""
 	}
-	// unary
+	// all supported methods share the same server signature
 	return "(" + ctxName + g.QualifiedGoIdent(contextPackage.Ident("Context")) +
 		", " + handleEnvName + g.QualifiedGoIdent(pluginrpcPackage.Ident("HandleEnv")) +
 		", " + optionsName + " ..." + g.QualifiedGoIdent(pluginrpcPackage.Ident("HandleOption")) +
@@ -572,6 +1024,13 @@ func pathConstName(m *protogen.Method) string {
 	return fmt.Sprintf("%s%sPath", m.Parent.GoName, m.GoName)
 }
 
+// isElidedMessage returns true if the given message is google.protobuf.Empty and
+// the empty=elide parameter was set, in which case the message is dropped
+// from generated signatures.
+func isElidedMessage(message *protogen.Message, flags *flags) bool {
+	return flags.elideEmpty && message.Desc.FullName() == emptyMessageFullName
+}
+
 func isDeprecatedService(service *protogen.Service) bool {
 	serviceOptions, ok := service.Desc.Options().(*descriptorpb.ServiceOptions)
 	return ok && serviceOptions.GetDeprecated()
@@ -582,44 +1041,56 @@ func isDeprecatedMethod(method *protogen.Method) bool {
 	return ok && methodOptions.GetDeprecated()
 }
 
-func getUnaryMethodsForFile(file *protogen.File) []*protogen.Method {
+func getSupportedMethodsForFile(file *protogen.File) []*protogen.Method {
 	var methods []*protogen.Method
 	for _, service := range file.Services {
-		methods = append(methods, getUnaryMethodsForService(service)...)
+		methods = append(methods, getSupportedMethodsForService(service)...)
 	}
 	return methods
 }
 
-func getUnaryMethodsForService(service *protogen.Service) []*protogen.Method {
+func getSupportedMethodsForService(service *protogen.Service) []*protogen.Method {
 	var methods []*protogen.Method
 	for _, method := range service.Methods {
-		if isUnaryMethod(method) {
+		if isSupportedMethod(method) {
 			methods = append(methods, method)
 		}
 	}
 	return methods
 }
 
-func getStreamingMethodsForFile(file *protogen.File) []*protogen.Method {
+func getUnsupportedMethodsForFile(file *protogen.File) []*protogen.Method {
 	var methods []*protogen.Method
 	for _, service := range file.Services {
-		methods = append(methods, getStreamingMethodsForService(service)...)
+		methods = append(methods, getUnsupportedMethodsForService(service)...)
 	}
 	return methods
 }
 
-func getStreamingMethodsForService(service *protogen.Service) []*protogen.Method {
+func getUnsupportedMethodsForService(service *protogen.Service) []*protogen.Method {
 	var methods []*protogen.Method
 	for _, method := range service.Methods {
-		if !isUnaryMethod(method) {
+		if !isSupportedMethod(method) {
 			methods = append(methods, method)
 		}
 	}
 	return methods
 }
 
-func isUnaryMethod(method *protogen.Method) bool {
-	return !(method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer())
+// isSupportedMethod returns true if the method is unary, server-streaming, or
+// bidirectional streaming.
+//
+// Client-streaming methods are not supported.
+func isSupportedMethod(method *protogen.Method) bool {
+	return !method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+func isServerStreamingMethod(method *protogen.Method) bool {
+	return !method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer()
+}
+
+func isBidiStreamingMethod(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer()
 }
 
 // Raggedy comments in the generated code are driving me insane. This
@@ -688,30 +1159,40 @@ func unexport(s string) string {
 }
 
 type names struct {
-	Base              string
-	SpecBuilder       string
-	Client            string
-	ClientConstructor string
-	ClientImpl        string
-	Handler           string
-	Server            string
-	ServerConstructor string
-	ServerRegister    string
-	ServerImpl        string
+	Base                 string
+	SpecBuilder          string
+	DefaultSpec          string
+	SpecDescriptor       string
+	Client               string
+	ClientConstructor    string
+	ClientImpl           string
+	Handler              string
+	UnimplementedHandler string
+	Server               string
+	ServerConstructor    string
+	ServerRegister       string
+	ServerForHandler     string
+	Registration         string
+	ServerImpl           string
 }
 
 func newNames(service *protogen.Service) names {
 	base := service.GoName
 	return names{
-		Base:              base,
-		SpecBuilder:       base + "SpecBuilder",
-		Client:            base + "Client",
-		ClientConstructor: "New" + base + "Client",
-		ClientImpl:        unexport(base) + "Client",
-		Handler:           base + "Handler",
-		Server:            base + "Server",
-		ServerConstructor: "New" + base + "Server",
-		ServerRegister:    "Register" + base + "Server",
-		ServerImpl:        unexport(base) + "Server",
+		Base:                 base,
+		SpecBuilder:          base + "SpecBuilder",
+		DefaultSpec:          "Default" + base + "Spec",
+		SpecDescriptor:       base + "SpecDescriptor",
+		Client:               base + "Client",
+		ClientConstructor:    "New" + base + "Client",
+		ClientImpl:           unexport(base) + "Client",
+		Handler:              base + "Handler",
+		UnimplementedHandler: "Unimplemented" + base + "Handler",
+		Server:               base + "Server",
+		ServerConstructor:    "New" + base + "Server",
+		ServerRegister:       "Register" + base + "Server",
+		ServerForHandler:     "New" + base + "ServerForHandler",
+		Registration:         base + "Registration",
+		ServerImpl:           unexport(base) + "Server",
 	}
 }
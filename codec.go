@@ -15,35 +15,164 @@
 package pluginrpc
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"unicode"
 
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
 
 var (
-	binaryCodec = &codec{
-		Marshal:   proto.Marshal,
-		Unmarshal: proto.Unmarshal,
+	binaryCodec = NewCodec(
+		proto.Marshal,
+		proto.Unmarshal,
+	)
+	jsonCodec = NewCodec(
+		protojson.MarshalOptions{UseProtoNames: true}.Marshal,
+		protojson.Unmarshal,
+	)
+
+	globalCodecRegistry = newCodecRegistry()
+)
+
+// Codec marshals and unmarshals Protobuf messages for a Format.
+//
+// Codecs must be safe for concurrent use.
+type Codec interface {
+	// Marshal marshals the message.
+	Marshal(message proto.Message) ([]byte, error)
+	// Unmarshal unmarshals the data into the message.
+	Unmarshal(data []byte, message proto.Message) error
+
+	isCodec()
+}
+
+// NewCodec returns a new Codec for the given marshal and unmarshal functions.
+func NewCodec(
+	marshal func(message proto.Message) ([]byte, error),
+	unmarshal func(data []byte, message proto.Message) error,
+) Codec {
+	return &codec{
+		marshal:   marshal,
+		unmarshal: unmarshal,
 	}
-	jsonCodec = &codec{
-		Marshal:   protojson.MarshalOptions{UseProtoNames: true}.Marshal,
-		Unmarshal: protojson.Unmarshal,
+}
+
+// RegisterFormat registers a Codec for a new Format with the given name, and returns the new Format.
+//
+// The name is what is passed to the --format flag, and what Format.String and FormatForString
+// use. It is case-insensitive, and must not be empty, contain whitespace, or collide with
+// a Format that is already registered, including the built-in "binary" and "json" Formats.
+//
+// Both the client and the plugin must register a Format with the same name for it to be
+// used. Formats are typically registered within an init function.
+func RegisterFormat(name string, codec Codec) (Format, error) {
+	return globalCodecRegistry.register(name, codec)
+}
+
+// *** PRIVATE ***
+
+type codec struct {
+	marshal   func(message proto.Message) ([]byte, error)
+	unmarshal func(data []byte, message proto.Message) error
+}
+
+func (c *codec) Marshal(message proto.Message) ([]byte, error) {
+	return c.marshal(message)
+}
+
+func (c *codec) Unmarshal(data []byte, message proto.Message) error {
+	return c.unmarshal(data, message)
+}
+
+func (*codec) isCodec() {}
+
+type codecRegistry struct {
+	formatToCodec map[Format]Codec
+	formatToName  map[Format]string
+	nameToFormat  map[string]Format
+	nextFormat    Format
+	lock          sync.RWMutex
+}
+
+func newCodecRegistry() *codecRegistry {
+	return &codecRegistry{
+		formatToCodec: map[Format]Codec{
+			FormatBinary: binaryCodec,
+			FormatJSON:   jsonCodec,
+		},
+		formatToName: map[Format]string{
+			FormatBinary: formatBinaryString,
+			FormatJSON:   formatJSONString,
+		},
+		nameToFormat: map[string]Format{
+			formatBinaryString: FormatBinary,
+			formatJSONString:   FormatJSON,
+		},
+		nextFormat: maxFormat + 1,
 	}
+}
 
-	formatToCodec = map[Format]*codec{
-		FormatBinary: binaryCodec,
-		FormatJSON:   jsonCodec,
+func (c *codecRegistry) register(name string, codec Codec) (Format, error) {
+	if codec == nil {
+		return 0, errors.New("nil Codec")
 	}
-)
+	if name == "" {
+		return 0, errors.New("empty Format name")
+	}
+	if strings.ContainsFunc(name, unicode.IsSpace) {
+		return 0, fmt.Errorf("name for Format contains whitespace: %q", name)
+	}
+	name = strings.ToLower(name)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.nameToFormat[name]; ok {
+		return 0, fmt.Errorf("duplicate Format registered: %q", name)
+	}
+	format := c.nextFormat
+	c.nextFormat++
+	c.formatToCodec[format] = codec
+	c.formatToName[format] = name
+	c.nameToFormat[name] = format
+	return format, nil
+}
 
-type codec struct {
-	Marshal   func(message proto.Message) ([]byte, error)
-	Unmarshal func(data []byte, message proto.Message) error
+func (c *codecRegistry) codec(format Format) (Codec, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	codec, ok := c.formatToCodec[format]
+	return codec, ok
+}
+
+func (c *codecRegistry) name(format Format) (string, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	name, ok := c.formatToName[format]
+	return name, ok
+}
+
+func (c *codecRegistry) format(name string) (Format, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	format, ok := c.nameToFormat[name]
+	return format, ok
+}
+
+func (c *codecRegistry) names() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	names := make([]string, 0, len(c.formatToName))
+	for format := minFormat; format < c.nextFormat; format++ {
+		names = append(names, c.formatToName[format])
+	}
+	return names
 }
 
-func codecForFormat(format Format) (*codec, error) {
-	codec, ok := formatToCodec[format]
+func codecForFormat(format Format) (Codec, error) {
+	codec, ok := globalCodecRegistry.codec(format)
 	if !ok {
 		return nil, fmt.Errorf("unknown Format: %v", format)
 	}
@@ -0,0 +1,70 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/prototext"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestRegisterFormat(t *testing.T) {
+	t.Parallel()
+
+	format, err := pluginrpc.RegisterFormat(
+		"TestText",
+		pluginrpc.NewCodec(
+			prototext.Marshal,
+			prototext.Unmarshal,
+		),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "testtext", format.String())
+	require.Equal(t, format, pluginrpc.FormatForString("testtext"))
+	require.Equal(t, format, pluginrpc.FormatForString(" TESTTEXT "))
+
+	client, err := newServerRunnerClient(t, pluginrpc.ClientWithFormat(format))
+	require.NoError(t, err)
+	spec, err := client.Spec(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoRequestPath))
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoRequest(
+		context.Background(),
+		&examplev1.EchoRequestRequest{
+			Message: "hello",
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.GetMessage())
+
+	codec := pluginrpc.NewCodec(prototext.Marshal, prototext.Unmarshal)
+	_, err = pluginrpc.RegisterFormat("testtext", codec)
+	require.Error(t, err)
+	_, err = pluginrpc.RegisterFormat("JSON", codec)
+	require.Error(t, err)
+	_, err = pluginrpc.RegisterFormat("test text", codec)
+	require.Error(t, err)
+	_, err = pluginrpc.RegisterFormat("", codec)
+	require.Error(t, err)
+	_, err = pluginrpc.RegisterFormat("testnil", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,276 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Incompatibility is an incompatibility between a host and a plugin that will result in
+// calls to a Procedure failing or losing data at runtime.
+type Incompatibility struct {
+	// Procedure is the path of the Procedure.
+	Procedure string
+	// Field is the full name of the field that is incompatible, for example
+	// "pluginrpc.example.v1.EchoRequestRequest.message".
+	//
+	// This is empty if the incompatibility is not with a field.
+	Field string
+	// Message is a human-readable message describing the incompatibility.
+	Message string
+}
+
+// String implements fmt.Stringer.
+func (i Incompatibility) String() string {
+	return i.Message
+}
+
+// CheckSpecCompatibility returns the incompatibilities of a new Spec of a plugin with an old
+// Spec, that is the changes that will break hosts that were integrated with the old Spec.
+//
+// This flags deleted and disabled Procedures, including Procedures that were renamed without
+// ProcedureWithRenamedFrom, changed args, and if both Specs have descriptors, changed request
+// and response types and streaming. This is CheckBreaking with all BreakingRules, for hosts
+// that verify an upgrade of a plugin. Use CheckBreaking to select the BreakingRules to check.
+//
+// If the descriptors of either Spec are invalid, this is returned as an Incompatibility.
+func CheckSpecCompatibility(oldSpec Spec, newSpec Spec) []Incompatibility {
+	breakingChanges, err := CheckBreaking(oldSpec, newSpec)
+	if err != nil {
+		return []Incompatibility{{Message: err.Error()}}
+	}
+	incompatibilities := make([]Incompatibility, len(breakingChanges))
+	for i, breakingChange := range breakingChanges {
+		incompatibilities[i] = Incompatibility{
+			Procedure: breakingChange.Procedure,
+			Message:   breakingChange.Message,
+		}
+	}
+	return incompatibilities
+}
+
+// CheckDescriptorCompatibility returns the field-level incompatibilities between the
+// request and response types of the given Procedures as known by the host, and as known
+// by the plugin.
+//
+// Requests are checked as sent by the host and received by the plugin, and responses are
+// checked as sent by the plugin and received by the host. Fields are matched by number,
+// and a field that is sent but unknown to the receiver, or that has a different type,
+// cardinality, or name, is an incompatibility. Fields that are known to the receiver
+// but not sent are compatible. Message fields are checked recursively.
+//
+// If hostFiles is nil, protoregistry.GlobalFiles is used, which contains the descriptors
+// of the generated packages linked into the host. The descriptors of a plugin are
+// available from the FileDescriptorSet of its Spec, see ClientWithSpecDescriptors.
+//
+// Returns error if the host has no descriptors for a Procedure.
+func CheckDescriptorCompatibility(
+	hostFiles *protoregistry.Files,
+	pluginFiles *protoregistry.Files,
+	procedurePaths ...string,
+) ([]Incompatibility, error) {
+	if hostFiles == nil {
+		hostFiles = protoregistry.GlobalFiles
+	}
+	var incompatibilities []Incompatibility
+	for _, procedurePath := range procedurePaths {
+		hostMethod := getMethodDescriptorForPath(hostFiles, procedurePath)
+		if hostMethod == nil {
+			return nil, fmt.Errorf("no host descriptors available for procedure %q", procedurePath)
+		}
+		pluginMethod := getMethodDescriptorForPath(pluginFiles, procedurePath)
+		if pluginMethod == nil {
+			incompatibilities = append(
+				incompatibilities,
+				Incompatibility{
+					Procedure: procedurePath,
+					Message:   fmt.Sprintf("procedure %q is unknown to the plugin", procedurePath),
+				},
+			)
+			continue
+		}
+		if hostMethod.IsStreamingServer() != pluginMethod.IsStreamingServer() {
+			incompatibilities = append(
+				incompatibilities,
+				Incompatibility{
+					Procedure: procedurePath,
+					Message: fmt.Sprintf(
+						"procedure %q is %s for the host but %s for the plugin",
+						procedurePath,
+						getStreamingString(hostMethod),
+						getStreamingString(pluginMethod),
+					),
+				},
+			)
+		}
+		checker := &compatibilityChecker{
+			procedurePath: procedurePath,
+			checked:       make(map[[2]protoreflect.FullName]struct{}),
+		}
+		checker.checkMessage(hostMethod.Input(), pluginMethod.Input(), "host", "plugin")
+		checker.checkMessage(pluginMethod.Output(), hostMethod.Output(), "plugin", "host")
+		incompatibilities = append(incompatibilities, checker.incompatibilities...)
+	}
+	return incompatibilities, nil
+}
+
+// CheckClientDescriptorCompatibility returns the field-level incompatibilities between the
+// request and response types of the given Procedures as known by the host, and as known
+// by the plugin of the Client.
+//
+// The descriptors of the plugin are read from the Spec of the Client, so the Client must
+// be created with ClientWithSpecDescriptors, and the plugin must be built with
+// SpecWithDescriptors. This allows hosts to warn about a plugin before calls fail at runtime.
+// See CheckDescriptorCompatibility for the checks that are performed.
+//
+// Returns error if the plugin has no descriptors, or if the host has no descriptors for a
+// Procedure.
+func CheckClientDescriptorCompatibility(
+	ctx context.Context,
+	client Client,
+	hostFiles *protoregistry.Files,
+	procedurePaths ...string,
+) ([]Incompatibility, error) {
+	spec, err := client.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pluginFiles, err := newFilesForFileDescriptorSet(spec.FileDescriptorSet())
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptors of plugin: %w", err)
+	}
+	if pluginFiles == nil {
+		return nil, fmt.Errorf("plugin did not return descriptors with --%s", DescriptorsFlagName)
+	}
+	return CheckDescriptorCompatibility(hostFiles, pluginFiles, procedurePaths...)
+}
+
+// *** PRIVATE ***
+
+type compatibilityChecker struct {
+	procedurePath     string
+	incompatibilities []Incompatibility
+	// checked are the pairs of sent and received messages that have been checked, as
+	// messages may be recursive.
+	checked map[[2]protoreflect.FullName]struct{}
+}
+
+// checkMessage checks that the received message can receive the sent message.
+//
+// sender and receiver are "host" or "plugin".
+func (c *compatibilityChecker) checkMessage(
+	sent protoreflect.MessageDescriptor,
+	received protoreflect.MessageDescriptor,
+	sender string,
+	receiver string,
+) {
+	key := [2]protoreflect.FullName{sent.FullName(), received.FullName()}
+	if _, ok := c.checked[key]; ok {
+		return
+	}
+	c.checked[key] = struct{}{}
+	sentFields := sent.Fields()
+	for i := 0; i < sentFields.Len(); i++ {
+		sentField := sentFields.Get(i)
+		receivedField := received.Fields().ByNumber(sentField.Number())
+		if receivedField == nil {
+			c.addIncompatibility(
+				sentField,
+				"field %q sent by the %s is unknown to the %s",
+				sentField.FullName(),
+				sender,
+				receiver,
+			)
+			continue
+		}
+		// Fields are identified by name in FormatJSON.
+		if sentField.Name() != receivedField.Name() {
+			c.addIncompatibility(
+				sentField,
+				"field %q is named %q by the %s but %q by the %s",
+				sentField.FullName(),
+				sentField.Name(),
+				sender,
+				receivedField.Name(),
+				receiver,
+			)
+		}
+		sentType := getFieldTypeString(sentField)
+		receivedType := getFieldTypeString(receivedField)
+		if sentType != receivedType {
+			c.addIncompatibility(
+				sentField,
+				"field %q has type %s for the %s but %s for the %s",
+				sentField.FullName(),
+				sentType,
+				sender,
+				receivedType,
+				receiver,
+			)
+			continue
+		}
+		switch {
+		case sentField.IsMap():
+			if sentField.MapValue().Kind() == protoreflect.MessageKind {
+				c.checkMessage(sentField.MapValue().Message(), receivedField.MapValue().Message(), sender, receiver)
+			}
+		case sentField.Kind() == protoreflect.MessageKind || sentField.Kind() == protoreflect.GroupKind:
+			c.checkMessage(sentField.Message(), receivedField.Message(), sender, receiver)
+		}
+	}
+}
+
+func (c *compatibilityChecker) addIncompatibility(field protoreflect.FieldDescriptor, format string, args ...any) {
+	c.incompatibilities = append(
+		c.incompatibilities,
+		Incompatibility{
+			Procedure: c.procedurePath,
+			Field:     string(field.FullName()),
+			Message:   fmt.Sprintf(format, args...),
+		},
+	)
+}
+
+// getFieldTypeString returns a string describing the type and cardinality of the field,
+// for example "repeated string" or "map<string, message>".
+//
+// Message types are compared field by field, so the names of message types are not included.
+// Enum values are encoded by number in binary and by name in JSON, so the names of enum
+// types are included.
+func getFieldTypeString(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.IsMap():
+		return "map<" + getKindString(field.MapKey()) + ", " + getKindString(field.MapValue()) + ">"
+	case field.IsList():
+		return "repeated " + getKindString(field)
+	default:
+		return getKindString(field)
+	}
+}
+
+func getKindString(field protoreflect.FieldDescriptor) string {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "message"
+	case protoreflect.EnumKind:
+		return "enum " + string(field.Enum().FullName())
+	default:
+		return field.Kind().String()
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+)
+
+func TestCheckSpecCompatibility(t *testing.T) {
+	t.Parallel()
+
+	oldSpec := newTestBreakingSpec(
+		t,
+		nil,
+		newTestProcedure(t, testEchoRequestPath, ProcedureWithArgs("echo", "request")),
+		newTestProcedure(t, testEchoListPath),
+		newTestProcedure(t, testEchoErrorPath, ProcedureWithArgs("echo", "error")),
+	)
+	newSpec := newTestBreakingSpec(
+		t,
+		nil,
+		newTestProcedure(t, testEchoRequestPath, ProcedureWithArgs("echo", "req")),
+		newTestProcedure(t, "/pluginrpc.example.v2.EchoService/EchoList"),
+		newTestProcedure(t, "/pluginrpc.example.v1.EchoService/EchoFailure", ProcedureWithArgs("echo", "error")),
+	)
+	require.Equal(
+		t,
+		[]Incompatibility{
+			{
+				Procedure: testEchoRequestPath,
+				Message:   `args of procedure "/pluginrpc.example.v1.EchoService/EchoRequest" changed from "echo request" to "echo req"`,
+			},
+			{
+				Procedure: testEchoListPath,
+				Message:   `procedure "/pluginrpc.example.v1.EchoService/EchoList" was deleted, it may have been renamed to "/pluginrpc.example.v2.EchoService/EchoList" without ProcedureWithRenamedFrom`,
+			},
+			{
+				Procedure: testEchoErrorPath,
+				Message:   `procedure "/pluginrpc.example.v1.EchoService/EchoError" was deleted, it may have been renamed to "/pluginrpc.example.v1.EchoService/EchoFailure" without ProcedureWithRenamedFrom`,
+			},
+		},
+		CheckSpecCompatibility(oldSpec, newSpec),
+	)
+	require.Empty(t, CheckSpecCompatibility(oldSpec, oldSpec))
+}
+
+func TestCheckDescriptorCompatibility(t *testing.T) {
+	t.Parallel()
+
+	pluginFileDescriptorSet := newFileDescriptorSet(nil, []protoreflect.FileDescriptor{examplev1.File_pluginrpc_example_v1_example_proto})
+	for _, file := range pluginFileDescriptorSet.GetFile() {
+		for _, message := range file.GetMessageType() {
+			switch message.GetName() {
+			case "EchoRequestRequest":
+				message.GetField()[0].Number = proto.Int32(2)
+			case "EchoRequestResponse":
+				message.GetField()[0].Type = descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum()
+			case "EchoListResponse":
+				message.GetField()[0].Name = proto.String("items")
+				message.GetField()[0].JsonName = proto.String("items")
+			}
+		}
+		for _, service := range file.GetService() {
+			for _, method := range service.GetMethod() {
+				if method.GetName() == "EchoStream" {
+					method.ServerStreaming = proto.Bool(false)
+				}
+			}
+		}
+	}
+	pluginFiles, err := protodesc.NewFiles(pluginFileDescriptorSet)
+	require.NoError(t, err)
+
+	incompatibilities, err := CheckDescriptorCompatibility(
+		nil,
+		pluginFiles,
+		testEchoRequestPath,
+		testEchoListPath,
+		"/pluginrpc.example.v1.EchoService/EchoStream",
+		testEchoErrorPath,
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]Incompatibility{
+			{
+				Procedure: testEchoRequestPath,
+				Field:     "pluginrpc.example.v1.EchoRequestRequest.message",
+				Message:   `field "pluginrpc.example.v1.EchoRequestRequest.message" sent by the host is unknown to the plugin`,
+			},
+			{
+				Procedure: testEchoRequestPath,
+				Field:     "pluginrpc.example.v1.EchoRequestResponse.message",
+				Message:   `field "pluginrpc.example.v1.EchoRequestResponse.message" has type bytes for the plugin but string for the host`,
+			},
+			{
+				Procedure: testEchoListPath,
+				Field:     "pluginrpc.example.v1.EchoListResponse.items",
+				Message:   `field "pluginrpc.example.v1.EchoListResponse.items" is named "items" by the plugin but "list" by the host`,
+			},
+			{
+				Procedure: "/pluginrpc.example.v1.EchoService/EchoStream",
+				Message:   `procedure "/pluginrpc.example.v1.EchoService/EchoStream" is server-streaming for the host but unary for the plugin`,
+			},
+		},
+		incompatibilities,
+	)
+
+	incompatibilities, err = CheckDescriptorCompatibility(nil, new(protoregistry.Files), testEchoErrorPath)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		[]Incompatibility{
+			{
+				Procedure: testEchoErrorPath,
+				Message:   `procedure "/pluginrpc.example.v1.EchoService/EchoError" is unknown to the plugin`,
+			},
+		},
+		incompatibilities,
+	)
+
+	_, err = CheckDescriptorCompatibility(nil, pluginFiles, "/foo.Bar/Baz")
+	require.ErrorContains(t, err, "no host descriptors")
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is a compression algorithm for requests and responses.
+type Compression uint32
+
+const (
+	// CompressionGzip is gzip compression.
+	CompressionGzip Compression = 1
+	// CompressionZstd is zstd compression.
+	CompressionZstd Compression = 2
+
+	compressionGzipString = "gzip"
+	compressionZstdString = "zstd"
+)
+
+var (
+	// AllCompressions are all Compressions.
+	AllCompressions = []Compression{
+		CompressionGzip,
+		CompressionZstd,
+	}
+
+	compressionToString = map[Compression]string{
+		CompressionGzip: compressionGzipString,
+		CompressionZstd: compressionZstdString,
+	}
+	stringToCompression = map[string]Compression{
+		compressionGzipString: CompressionGzip,
+		compressionZstdString: CompressionZstd,
+	}
+)
+
+// String implements fmt.Stringer.
+func (c Compression) String() string {
+	if name, ok := compressionToString[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("compression_%d", c)
+}
+
+// CompressionForString returns the Compression for the given string.
+//
+// Returns 0 if the Compression is unknown or s is empty.
+func CompressionForString(s string) Compression {
+	return stringToCompression[strings.ToLower(strings.TrimSpace(s))]
+}
+
+// *** PRIVATE ***
+
+const (
+	// compressedEnvelopeVersion is the version of the binary header that prefixes compressed
+	// envelopes. The full header is binaryHeaderMagic followed by compressedEnvelopeVersion,
+	// followed by a byte for the Compression, followed by the compressed envelope.
+	//
+	// As the magic starts with 0x00, compressed envelopes are distinguished from envelopes
+	// in both FormatBinary and FormatJSON.
+	compressedEnvelopeVersion byte = 2
+)
+
+func validateCompression(compression Compression) error {
+	if _, ok := compressionToString[compression]; !ok {
+		return fmt.Errorf("unknown Compression: %v", compression)
+	}
+	return nil
+}
+
+// compressionsForStrings returns the Compressions for the given names, ignoring unknown names.
+func compressionsForStrings(names []string) []Compression {
+	var compressions []Compression
+	for _, name := range names {
+		if compression := CompressionForString(name); compression != 0 {
+			compressions = append(compressions, compression)
+		}
+	}
+	return compressions
+}
+
+func compressionsToStrings(compressions []Compression) []string {
+	names := make([]string, len(compressions))
+	for i, compression := range compressions {
+		names[i] = compression.String()
+	}
+	return names
+}
+
+// compressEnvelope compresses the envelope with the Compression, prefixed with the header
+// of compressed envelopes, see compressedEnvelopeVersion.
+func compressEnvelope(compression Compression, data []byte) ([]byte, error) {
+	buffer := bytes.NewBuffer(append(bytes.Clone(binaryHeaderMagic), compressedEnvelopeVersion, byte(compression)))
+	writer, err := newCompressWriter(compression, buffer)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompressEnvelope decompresses the data if it is prefixed with the header of compressed
+// envelopes, and returns the Compression it was compressed with.
+//
+// If the data is not prefixed, it is returned as-is with a zero Compression. The decompressed
+// data must not be larger than maxBytes, or than maxRatio times the size of the compressed data
+// if maxRatio is positive.
+func decompressEnvelope(data []byte, maxBytes int64, maxRatio int64) ([]byte, Compression, error) {
+	header := append(bytes.Clone(binaryHeaderMagic), compressedEnvelopeVersion)
+	if !bytes.HasPrefix(data, header) {
+		return data, 0, nil
+	}
+	data = data[len(header):]
+	if len(data) == 0 {
+		return nil, 0, errors.New("compressed envelope is missing a compression")
+	}
+	compression := Compression(data[0])
+	if err := validateCompression(compression); err != nil {
+		return nil, 0, err
+	}
+	reader, err := newDecompressReader(compression, data[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+	decompressed, err := readDecompressed(reader, len(data), maxBytes, maxRatio)
+	if err != nil {
+		return nil, 0, err
+	}
+	return decompressed, compression, nil
+}
+
+// readDecompressed reads all decompressed data from the reader and closes it.
+//
+// The decompressed data must not be larger than maxBytes, or than maxRatio times
+// compressedSize if maxRatio is positive. If the data exceeds a limit, an error with
+// CodeResourceExhausted is returned.
+func readDecompressed(reader io.ReadCloser, compressedSize int, maxBytes int64, maxRatio int64) ([]byte, error) {
+	limit := maxBytes
+	// Dividing avoids overflowing when multiplying by the ratio.
+	limitedByRatio := maxRatio > 0 && maxRatio < maxBytes/int64(compressedSize)
+	if limitedByRatio {
+		limit = maxRatio * int64(compressedSize)
+	}
+	// Read one more byte than the limit to detect data that exceeds the limit.
+	decompressed, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		_ = reader.Close()
+		return nil, err
+	}
+	if int64(len(decompressed)) > limit {
+		_ = reader.Close()
+		if limitedByRatio {
+			return nil, NewErrorf(CodeResourceExhausted, "decompressed data exceeds %d times the size of the compressed data", maxRatio)
+		}
+		return nil, NewErrorf(CodeResourceExhausted, "decompressed data exceeds %d bytes", maxBytes)
+	}
+	if err := reader.Close(); err != nil {
+		return nil, err
+	}
+	return decompressed, nil
+}
+
+func newCompressWriter(compression Compression, writer io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(writer), nil
+	case CompressionZstd:
+		return zstd.NewWriter(writer, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("unknown Compression: %v", compression)
+	}
+}
+
+func newDecompressReader(compression Compression, data []byte) (io.ReadCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(bytes.NewReader(data))
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown Compression: %v", compression)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressEnvelope(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("hello"), 100)
+	for _, compression := range AllCompressions {
+		compressed, err := compressEnvelope(compression, data)
+		require.NoError(t, err)
+		require.Less(t, len(compressed), len(data))
+		decompressed, decompressedCompression, err := decompressEnvelope(compressed, defaultMaxDecompressedBytes, 0)
+		require.NoError(t, err)
+		require.Equal(t, data, decompressed)
+		require.Equal(t, compression, decompressedCompression)
+		_, _, err = decompressEnvelope(compressed, 256, 0)
+		require.ErrorContains(t, err, "exceeds 256 bytes")
+		_, _, err = decompressEnvelope(compressed, defaultMaxDecompressedBytes, 2)
+		require.ErrorContains(t, err, "exceeds 2 times")
+		require.Equal(t, CodeResourceExhausted, WrapError(err).Code())
+	}
+
+	// Highly compressible data is only limited by size by default.
+	data = bytes.Repeat([]byte("a"), 1<<20)
+	for _, compression := range AllCompressions {
+		compressed, err := compressEnvelope(compression, data)
+		require.NoError(t, err)
+		require.Greater(t, len(data)/len(compressed), 100)
+		decompressed, _, err := decompressEnvelope(compressed, defaultMaxDecompressedBytes, 0)
+		require.NoError(t, err)
+		require.Equal(t, data, decompressed)
+	}
+
+	// Envelopes that are not compressed are returned as-is.
+	data = bytes.Repeat([]byte("hello"), 100)
+	decompressed, compression, err := decompressEnvelope(addBinaryHeader(data), defaultMaxDecompressedBytes, 0)
+	require.NoError(t, err)
+	require.Equal(t, addBinaryHeader(data), decompressed)
+	require.Zero(t, compression)
+
+	header := append(bytes.Clone(binaryHeaderMagic), compressedEnvelopeVersion)
+	_, _, err = decompressEnvelope(header, defaultMaxDecompressedBytes, 0)
+	require.Error(t, err)
+	_, _, err = decompressEnvelope(append(header, 0xff), defaultMaxDecompressedBytes, 0)
+	require.ErrorContains(t, err, "unknown Compression")
+}
+
+func TestCompressionForString(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range AllCompressions {
+		require.Equal(t, compression, CompressionForString(compression.String()))
+	}
+	require.Equal(t, CompressionZstd, CompressionForString(" ZSTD "))
+	require.Zero(t, CompressionForString("brotli"))
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// *** PRIVATE ***
+
+// debugRunner is a Runner that dumps the args, stdin, stdout, and exit code of every
+// invocation of the plugin to a writer.
+//
+// The values of requests and responses are redacted with the redactor.
+//
+// See ClientWithDebugWriter.
+type debugRunner struct {
+	runner   Runner
+	writer   io.Writer
+	redactor Redactor
+	lock     sync.Mutex
+}
+
+func newDebugRunner(runner Runner, writer io.Writer, redactor Redactor) *debugRunner {
+	return &debugRunner{
+		runner:   runner,
+		writer:   writer,
+		redactor: redactor,
+	}
+}
+
+func (d *debugRunner) Run(ctx context.Context, env Env) error {
+	env = env.withDefaults()
+	stdin := bytes.NewBuffer(nil)
+	stdout := bytes.NewBuffer(nil)
+	args := env.Args
+	env.Stdin = io.TeeReader(env.Stdin, stdin)
+	env.Stdout = io.MultiWriter(env.Stdout, stdout)
+	err := d.runner.Run(ctx, env)
+	// The dump is best-effort, and never fails the invocation.
+	_ = d.write(args, stdin.Bytes(), stdout.Bytes(), err)
+	return err
+}
+
+// write writes the dump for a single invocation with a single write, so that dumps
+// of concurrent invocations are not interleaved.
+func (d *debugRunner) write(args []string, stdin []byte, stdout []byte, err error) error {
+	var sb strings.Builder
+	_, _ = sb.WriteString("--- pluginrpc invocation ---\nargv:")
+	argv := args
+	if programRunner, ok := d.runner.(programRunner); ok {
+		programPath, pathErr := programRunner.programPath()
+		if pathErr != nil {
+			programPath = "<unknown>"
+		}
+		argv = append(append([]string{programPath}, programRunner.programArgs()...), args...)
+	}
+	for _, arg := range maskMetadataArgs(argv) {
+		_, _ = sb.WriteString(" ")
+		_, _ = sb.WriteString(quoteDebugArg(arg))
+	}
+	_, _ = sb.WriteString("\n")
+	redactedStdin, ok := d.redact(args, stdin, false)
+	writeDebugData(&sb, "stdin", stdin, redactedStdin, ok)
+	redactedStdout, ok := d.redact(args, stdout, true)
+	writeDebugData(&sb, "stdout", stdout, redactedStdout, ok)
+	exitCode := 0
+	if err != nil {
+		exitCode = -1
+		exitError := &ExitError{}
+		if errors.As(err, &exitError) {
+			exitCode = exitError.ExitCode()
+		}
+	}
+	_, _ = fmt.Fprintf(&sb, "exit code: %d\n", exitCode)
+	if err != nil {
+		_, _ = fmt.Fprintf(&sb, "error: %v\n", err)
+	}
+	if programRunner, ok := d.runner.(programRunner); ok {
+		if reproCommand, ok := newReproCommand(programRunner, d.redactor, args, stdin); ok {
+			_, _ = fmt.Fprintf(&sb, "reproduce: %s\n", reproCommand)
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, writeErr := io.WriteString(d.writer, sb.String())
+	return writeErr
+}
+
+// redact returns stdin or stdout of the invocation with the values of requests or responses
+// redacted, see redactEnvelopes.
+//
+// Invocations that do not call a Procedure, for example to retrieve the Spec, do not contain
+// values, and are returned as-is. Returns false if the data could not be redacted.
+func (d *debugRunner) redact(args []string, data []byte, response bool) ([]byte, bool) {
+	for _, arg := range args {
+		switch arg {
+		case "--" + ProtocolFlagName, "--" + SpecFlagName, "--" + InfoFlagName:
+			return data, true
+		}
+	}
+	return redactEnvelopes(d.redactor, formatForArgs(args), data, response)
+}
+
+// formatForArgs returns the Format given with --format in the args, or FormatBinary
+// if not given.
+func formatForArgs(args []string) Format {
+	for i, arg := range args {
+		var formatString string
+		switch {
+		case arg == "--"+FormatFlagName && i+1 < len(args):
+			formatString = args[i+1]
+		case strings.HasPrefix(arg, "--"+FormatFlagName+"="):
+			formatString = strings.TrimPrefix(arg, "--"+FormatFlagName+"=")
+		default:
+			continue
+		}
+		if format := FormatForString(formatString); format != 0 {
+			return format
+		}
+	}
+	return FormatBinary
+}
+
+// writeDebugData writes the redacted data as text if it is printable, and as a hex dump
+// otherwise.
+//
+// If ok is false, the data could not be redacted, and only the size of the data is written.
+func writeDebugData(sb *strings.Builder, name string, data []byte, redactedData []byte, ok bool) {
+	if !ok {
+		_, _ = fmt.Fprintf(sb, "%s (%d bytes): not shown, as it could not be redacted\n", name, len(data))
+		return
+	}
+	if len(redactedData) != len(data) {
+		_, _ = fmt.Fprintf(sb, "%s (%d bytes, %d bytes after redaction):\n", name, len(data), len(redactedData))
+	} else {
+		_, _ = fmt.Fprintf(sb, "%s (%d bytes):\n", name, len(data))
+	}
+	data = redactedData
+	if len(data) == 0 {
+		return
+	}
+	if !isDebugText(data) {
+		_, _ = sb.WriteString(hex.Dump(data))
+		return
+	}
+	_, _ = sb.Write(data)
+	if data[len(data)-1] != '\n' {
+		_, _ = sb.WriteString("\n")
+	}
+}
+
+func isDebugText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteDebugArg quotes the arg if it would not be read as a single arg by a shell.
+func quoteDebugArg(arg string) string {
+	if arg == "" || strings.ContainsFunc(arg, isDebugArgSpecialRune) {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
+func isDebugArgSpecialRune(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("\"'\\$`", r)
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestClientWithDebugWriter(t *testing.T) {
+	t.Parallel()
+
+	echoPluginProgramPath, err := exec.LookPath(echoPluginProgramName)
+	require.NoError(t, err)
+	for _, format := range []pluginrpc.Format{pluginrpc.FormatJSON, pluginrpc.FormatBinary} {
+		debugWriter := bytes.NewBuffer(nil)
+		client := pluginrpc.NewClient(
+			pluginrpc.NewExecRunner(echoPluginProgramName),
+			pluginrpc.ClientWithFormat(format),
+			pluginrpc.ClientWithDebugWriter(debugWriter),
+		)
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+		require.NoError(t, err)
+		_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello world"})
+		require.NoError(t, err)
+
+		dumps := strings.Split(debugWriter.String(), "--- pluginrpc invocation ---\n")
+		// The protocol, the spec, and the call.
+		require.Len(t, dumps, 4)
+		require.Empty(t, dumps[0])
+		require.True(t, strings.HasPrefix(dumps[1], "argv: "+echoPluginProgramPath+" --protocol\nstdin (0 bytes):\nstdout (2 bytes):\n1\nexit code: 0\n"), dumps[1])
+		require.Contains(t, dumps[3], "argv: "+echoPluginProgramPath+" echo request --format "+format.String()+"\n")
+		require.Contains(t, dumps[3], "exit code: 0\n")
+		switch format {
+		case pluginrpc.FormatJSON:
+			require.Contains(t, dumps[3], `"message":"hello world"`)
+		case pluginrpc.FormatBinary:
+			// Binary envelopes are hex dumps.
+			require.Contains(t, dumps[3], "00000000  ")
+			require.Contains(t, dumps[3], "hello world|")
+		}
+	}
+}
+
+func TestClientWithDebugWriterRedactor(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []pluginrpc.Format{pluginrpc.FormatJSON, pluginrpc.FormatBinary} {
+		debugWriter := bytes.NewBuffer(nil)
+		client := pluginrpc.NewClient(
+			pluginrpc.NewExecRunner(echoPluginProgramName),
+			pluginrpc.ClientWithFormat(format),
+			pluginrpc.ClientWithDebugWriter(debugWriter),
+			pluginrpc.ClientWithRedactor(
+				pluginrpc.NewRedactor(
+					pluginrpc.RedactorWithFieldNames(
+						"pluginrpc.example.v1.EchoRequestRequest.message",
+						"pluginrpc.example.v1.EchoRequestResponse.message",
+					),
+				),
+			),
+		)
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+		require.NoError(t, err)
+		response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "secret"})
+		require.NoError(t, err)
+		// Only the dump is redacted.
+		require.Equal(t, "secret", response.GetMessage())
+
+		dumps := strings.Split(debugWriter.String(), "--- pluginrpc invocation ---\n")
+		require.Len(t, dumps, 4)
+		require.Contains(t, dumps[3], "after redaction")
+		require.NotContains(t, dumps[3], "secret")
+		require.NotContains(t, dumps[3], "not shown")
+	}
+}
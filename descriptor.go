@@ -0,0 +1,176 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+// ServiceSpecDescriptor describes a Protobuf service generated with protoc-gen-pluginrpc-go.
+//
+// Generated packages expose a ServiceSpecDescriptor for each service as a <Service>SpecDescriptor
+// variable, allowing generic tooling to be built over generated packages without parsing
+// descriptors.
+type ServiceSpecDescriptor struct {
+	// FullName is the fully-qualified name of the service, for example "pluginrpc.example.v1.EchoService".
+	FullName string
+	// Methods are the supported methods of the service, in the order they are declared.
+	//
+	// Client-streaming methods are not supported and are not included.
+	Methods []MethodSpecDescriptor
+}
+
+// MethodForPath returns the MethodSpecDescriptor for the given path.
+//
+// Returns false if no method has the path.
+func (s ServiceSpecDescriptor) MethodForPath(path string) (MethodSpecDescriptor, bool) {
+	for _, method := range s.Methods {
+		if method.Path == path {
+			return method, true
+		}
+	}
+	return MethodSpecDescriptor{}, false
+}
+
+// MethodSpecDescriptor describes a method of a Protobuf service generated with protoc-gen-pluginrpc-go.
+type MethodSpecDescriptor struct {
+	// Name is the name of the method, for example "EchoRequest".
+	Name string
+	// Path is the path of the Procedure for the method, for example
+	// "/pluginrpc.example.v1.EchoService/EchoRequest".
+	Path string
+	// InputType is the type of the request message.
+	InputType protoreflect.MessageType
+	// OutputType is the type of the response message.
+	OutputType protoreflect.MessageType
+	// ClientStreaming is whether the method accepts a stream of requests.
+	ClientStreaming bool
+	// ServerStreaming is whether the method returns a stream of responses.
+	ServerStreaming bool
+}
+
+// NewSpecForServiceDescriptor returns a new Spec for the given ServiceDescriptor, with a
+// Procedure for each method of the service.
+//
+// This allows servers built with dynamicpb or existing descriptors to construct a Spec
+// without running protoc-gen-pluginrpc-go. The Spec is equivalent to the Spec built by the
+// generated <Service>SpecBuilder. Client-streaming methods are not supported and are skipped.
+// If the descriptors include source code info, the leading comments of methods are used as
+// the documentation of their Procedures, see ProcedureWithDoc.
+// The args declared with the pluginrpc.ext.v1.method option of methods are used as the args
+// of their Procedures, see ProcedureWithArgs.
+func NewSpecForServiceDescriptor(
+	serviceDescriptor protoreflect.ServiceDescriptor,
+	options ...SpecForServiceDescriptorOption,
+) (Spec, error) {
+	specForServiceDescriptorOptions := newSpecForServiceDescriptorOptions()
+	for _, option := range options {
+		option(specForServiceDescriptorOptions)
+	}
+	methods := serviceDescriptor.Methods()
+	for methodName := range specForServiceDescriptorOptions.methodNameToProcedureOptions {
+		if methods.ByName(protoreflect.Name(methodName)) == nil {
+			return nil, fmt.Errorf("service %q has no method %q", serviceDescriptor.FullName(), methodName)
+		}
+	}
+	procedures := make([]Procedure, 0, methods.Len())
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		if method.IsStreamingClient() && !method.IsStreamingServer() {
+			continue
+		}
+		// The doc and args are specified first so that they can be overridden by the given ProcedureOptions.
+		procedureOptions := []ProcedureOption{ProcedureWithDoc(getLeadingComments(method))}
+		if args := getMethodArgs(method); len(args) > 0 {
+			procedureOptions = append(procedureOptions, ProcedureWithArgs(args...))
+		}
+		procedure, err := NewProcedure(
+			fmt.Sprintf("/%s/%s", serviceDescriptor.FullName(), method.Name()),
+			append(
+				procedureOptions,
+				specForServiceDescriptorOptions.methodNameToProcedureOptions[string(method.Name())]...,
+			)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		procedures = append(procedures, procedure)
+	}
+	return NewSpec(procedures...)
+}
+
+// SpecForServiceDescriptorOption is an option for NewSpecForServiceDescriptor.
+type SpecForServiceDescriptorOption func(*specForServiceDescriptorOptions)
+
+// SpecForServiceDescriptorWithProcedureOptions returns a new SpecForServiceDescriptorOption
+// that applies the given ProcedureOptions to the Procedure for the method with the given
+// name, for example "EchoRequest".
+//
+// This is equivalent to setting the field for the method on a generated <Service>SpecBuilder.
+// This option can be specified multiple times, in which case the ProcedureOptions are appended.
+func SpecForServiceDescriptorWithProcedureOptions(methodName string, options ...ProcedureOption) SpecForServiceDescriptorOption {
+	return func(specForServiceDescriptorOptions *specForServiceDescriptorOptions) {
+		specForServiceDescriptorOptions.methodNameToProcedureOptions[methodName] = append(
+			specForServiceDescriptorOptions.methodNameToProcedureOptions[methodName],
+			options...,
+		)
+	}
+}
+
+// *** PRIVATE ***
+
+type specForServiceDescriptorOptions struct {
+	methodNameToProcedureOptions map[string][]ProcedureOption
+}
+
+func newSpecForServiceDescriptorOptions() *specForServiceDescriptorOptions {
+	return &specForServiceDescriptorOptions{
+		methodNameToProcedureOptions: make(map[string][]ProcedureOption),
+	}
+}
+
+// getLeadingComments returns the leading comments of the descriptor, with the leading space
+// of each line removed.
+//
+// Returns empty if the descriptor has no source code info.
+func getLeadingComments(descriptor protoreflect.Descriptor) string {
+	leadingComments := descriptor.ParentFile().SourceLocations().ByDescriptor(descriptor).LeadingComments
+	lines := strings.Split(strings.TrimSuffix(leadingComments, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// getMethodArgs returns the args of the method from the pluginrpc.ext.v1.method option.
+//
+// Returns empty if the option is not set.
+func getMethodArgs(method protoreflect.MethodDescriptor) []string {
+	methodOptions, ok := method.Options().(*descriptorpb.MethodOptions)
+	if !ok {
+		return nil
+	}
+	extMethodOptions, ok := proto.GetExtension(methodOptions, extv1.E_Method).(*extv1.MethodOptions)
+	if !ok {
+		return nil
+	}
+	return strings.Fields(extMethodOptions.GetArgs())
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"time"
+)
+
+// NewDockerRunner returns a new Runner that runs the plugin inside a container created
+// from the given image, for hosts that distribute plugins as OCI images rather than
+// native binaries.
+//
+// Each call is run in a new container with `docker run --rm -i`, with the stdin, stdout,
+// and stderr of the call attached to the container, and the args of the call appended to
+// the image. The entrypoint of the image must be the plugin.
+//
+// The container runtime CLI inherits the environment of the current process, so that
+// variables such as DOCKER_HOST are respected. The container itself only has access to
+// environment variables explicitly configured with DockerRunnerWithEnv.
+func NewDockerRunner(image string, options ...DockerRunnerOption) Runner {
+	return newDockerRunner(image, options...)
+}
+
+// DockerRunnerOption is an option for a new DockerRunner.
+type DockerRunnerOption func(*dockerRunnerOptions)
+
+// DockerRunnerWithRuntime returns a new DockerRunnerOption that specifies the program
+// name of the container runtime CLI, for example "podman".
+//
+// The CLI must be compatible with `docker run`. The default is "docker".
+func DockerRunnerWithRuntime(runtime string) DockerRunnerOption {
+	return func(dockerRunnerOptions *dockerRunnerOptions) {
+		dockerRunnerOptions.runtime = runtime
+	}
+}
+
+// DockerRunnerWithArgs returns a new DockerRunnerOption that specifies a sub-command to invoke
+// on the plugin.
+//
+// See ExecRunnerWithArgs for more details.
+func DockerRunnerWithArgs(args ...string) DockerRunnerOption {
+	return func(dockerRunnerOptions *dockerRunnerOptions) {
+		dockerRunnerOptions.args = args
+	}
+}
+
+// DockerRunnerWithRunArgs returns a new DockerRunnerOption that specifies additional
+// flags for `docker run`, for example "--network=none", "--memory=256m", or "--pull=never".
+//
+// This option can be specified multiple times, in which case the flags are appended.
+func DockerRunnerWithRunArgs(runArgs ...string) DockerRunnerOption {
+	return func(dockerRunnerOptions *dockerRunnerOptions) {
+		dockerRunnerOptions.runArgs = append(dockerRunnerOptions.runArgs, runArgs...)
+	}
+}
+
+// DockerRunnerWithEnv returns a new DockerRunnerOption that sets the given environment
+// variables within the container.
+//
+// The values are passed to the container runtime CLI through its environment rather than
+// its arguments, so that they are not visible in the process list.
+// This option can be specified multiple times, in which case the environment variables are merged.
+func DockerRunnerWithEnv(env map[string]string) DockerRunnerOption {
+	return func(dockerRunnerOptions *dockerRunnerOptions) {
+		if dockerRunnerOptions.env == nil {
+			dockerRunnerOptions.env = make(map[string]string, len(env))
+		}
+		for key, value := range env {
+			dockerRunnerOptions.env[key] = value
+		}
+	}
+}
+
+// *** PRIVATE ***
+
+const (
+	defaultDockerRuntime = "docker"
+	// dockerWaitDelay is the time to wait for the container runtime CLI to stop the
+	// container after it is interrupted, before it is killed.
+	dockerWaitDelay = 10 * time.Second
+)
+
+type dockerRunner struct {
+	execRunner *execRunner
+}
+
+func newDockerRunner(image string, options ...DockerRunnerOption) *dockerRunner {
+	dockerRunnerOptions := newDockerRunnerOptions()
+	for _, option := range options {
+		option(dockerRunnerOptions)
+	}
+	if dockerRunnerOptions.runtime == "" {
+		dockerRunnerOptions.runtime = defaultDockerRuntime
+	}
+	envKeys := make([]string, 0, len(dockerRunnerOptions.env))
+	for key := range dockerRunnerOptions.env {
+		envKeys = append(envKeys, key)
+	}
+	sort.Strings(envKeys)
+	args := []string{"run", "--rm", "-i"}
+	cmdEnv := os.Environ()
+	for _, key := range envKeys {
+		// Only the key is given, the value is taken from the environment of the CLI.
+		args = append(args, "--env", key)
+		cmdEnv = append(cmdEnv, key+"="+dockerRunnerOptions.env[key])
+	}
+	args = append(args, dockerRunnerOptions.runArgs...)
+	args = append(args, image)
+	args = append(args, dockerRunnerOptions.args...)
+	return &dockerRunner{
+		execRunner: newExecRunner(
+			dockerRunnerOptions.runtime,
+			ExecRunnerWithArgs(args...),
+			ExecRunnerWithCmdOption(
+				func(cmd *exec.Cmd) {
+					cmd.Env = slices.Clone(cmdEnv)
+					// Killing the CLI does not stop the container, so interrupt the CLI instead,
+					// which forwards the signal to the container.
+					cmd.Cancel = func() error {
+						return cmd.Process.Signal(os.Interrupt)
+					}
+					cmd.WaitDelay = dockerWaitDelay
+				},
+			),
+		),
+	}
+}
+
+func (d *dockerRunner) Run(ctx context.Context, env Env) error {
+	return d.execRunner.Run(ctx, env)
+}
+
+type dockerRunnerOptions struct {
+	runtime string
+	args    []string
+	runArgs []string
+	env     map[string]string
+}
+
+func newDockerRunnerOptions() *dockerRunnerOptions {
+	return &dockerRunnerOptions{}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerRunner(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on Windows")
+	}
+	// A fake container runtime that prints its args and the value of FOO.
+	runtimePath := filepath.Join(t.TempDir(), "fake-docker")
+	require.NoError(
+		t,
+		os.WriteFile(
+			runtimePath,
+			[]byte("#!/bin/sh\nprintf '%s\\n' \"$@\"\necho \"FOO=$FOO\"\n"),
+			0o700, //nolint:gosec // the script must be executable
+		),
+	)
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(
+		t,
+		NewDockerRunner(
+			"example.com/plugin:v1",
+			DockerRunnerWithRuntime(runtimePath),
+			DockerRunnerWithArgs("foo"),
+			DockerRunnerWithRunArgs("--network=none"),
+			DockerRunnerWithEnv(map[string]string{"FOO": "bar"}),
+		).Run(
+			context.Background(),
+			Env{
+				Args:   []string{"--spec"},
+				Stdout: stdout,
+			},
+		),
+	)
+	require.Equal(
+		t,
+		[]string{
+			"run",
+			"--rm",
+			"-i",
+			"--env",
+			"FOO",
+			"--network=none",
+			"example.com/plugin:v1",
+			"foo",
+			"--spec",
+			"FOO=bar",
+		},
+		strings.Fields(stdout.String()),
+	)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ExecCommand is a command that a Runner created with NewExecRunner would run.
+type ExecCommand struct {
+	// Path is the absolute path of the program, as resolved from the PATH of the
+	// current process.
+	Path string
+	// Args are the args of the command, not including the program.
+	Args []string
+	// Env is the environment of the command, as key=value pairs sorted by key.
+	//
+	// This only contains the environment variables that are explicitly configured, see
+	// ExecRunnerWithEnv and ExecRunnerWithInheritedEnvVars.
+	Env []string
+	// Dir is the working directory of the command.
+	//
+	// If empty, the command is run in the current working directory.
+	Dir string
+}
+
+// DryRunError is returned from Runners created with NewExecRunner and ExecRunnerWithDryRun
+// instead of running a command.
+//
+// Clients return errors that wrap the DryRunError, use errors.As to retrieve it.
+type DryRunError struct {
+	// Command is the command that would have been run.
+	Command ExecCommand
+}
+
+// Error implements error.
+func (e *DryRunError) Error() string {
+	return "dry run: " + strings.Join(append([]string{e.Command.Path}, e.Command.Args...), " ")
+}
+
+// *** PRIVATE ***
+
+// newDryRunError returns a new *DryRunError for the configured command.
+//
+// Returns an error if the program could not be resolved.
+func newDryRunError(cmd *exec.Cmd) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	programPath, err := filepath.Abs(cmd.Path)
+	if err != nil {
+		return err
+	}
+	var env []string
+	if !slices.Equal(cmd.Env, emptyEnv) {
+		env = slices.Clone(cmd.Env)
+	}
+	return &DryRunError{
+		Command: ExecCommand{
+			Path: programPath,
+			Args: slices.Clone(cmd.Args[1:]),
+			Env:  env,
+			Dir:  cmd.Dir,
+		},
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestExecRunnerWithDryRun(t *testing.T) {
+	t.Parallel()
+
+	shProgramPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh program not found")
+	}
+	shProgramPath, err = filepath.Abs(shProgramPath)
+	require.NoError(t, err)
+	runner := NewExecRunner(
+		"sh",
+		ExecRunnerWithArgs("-c", "exit 1"),
+		ExecRunnerWithEnv(map[string]string{"FOO": "bar"}),
+		ExecRunnerWithDryRun(),
+	)
+	err = runner.Run(context.Background(), Env{Args: []string{"baz"}})
+	dryRunError := &DryRunError{}
+	require.ErrorAs(t, err, &dryRunError)
+	require.Equal(
+		t,
+		ExecCommand{
+			Path: shProgramPath,
+			Args: []string{"-c", "exit 1", "baz"},
+			Env:  []string{"FOO=bar"},
+		},
+		dryRunError.Command,
+	)
+
+	err = NewExecRunner("pluginrpc-test-not-found", ExecRunnerWithDryRun()).Run(context.Background(), Env{})
+	require.ErrorIs(t, err, exec.ErrNotFound)
+}
+
+func TestExecRunnerWithDryRunClient(t *testing.T) {
+	t.Parallel()
+
+	shProgramPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh program not found")
+	}
+	procedure, err := NewProcedure("/foo.v1.FooService/Bar", ProcedureWithArgs("foo", "bar"))
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	client := NewClient(
+		NewExecRunner(shProgramPath, ExecRunnerWithDryRun()),
+		ClientWithSpec(spec),
+	)
+	err = client.Call(context.Background(), "/foo.v1.FooService/Bar", &emptypb.Empty{}, &emptypb.Empty{})
+	dryRunError := &DryRunError{}
+	require.ErrorAs(t, err, &dryRunError)
+	require.Equal(t, []string{"foo", "bar"}, dryRunError.Command.Args[:2])
+}
@@ -0,0 +1,296 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// DynamicClient calls Procedures with dynamic messages, using the descriptors of the
+// request and response types instead of generated code.
+//
+// This is useful for generic tooling such as CLIs and gateways that cannot import the
+// generated packages of every plugin. The path of a Procedure must be of the form
+// "/package.Service/Method" for its descriptors to be found.
+type DynamicClient interface {
+	// MethodDescriptor returns the MethodDescriptor for the Procedure with the given path.
+	//
+	// Returns an error if the Procedure is not in the Spec of the plugin, if no descriptors
+	// are available for the Procedure, or if the method is client-streaming.
+	MethodDescriptor(ctx context.Context, procedurePath string) (protoreflect.MethodDescriptor, error)
+	// Call calls the unary Procedure with the given request, and returns the response as a
+	// dynamic message.
+	//
+	// The request must be of the input type of the method. A nil request results in an
+	// empty request.
+	//
+	// If the call results in an error with a partial result, both the response and the
+	// error are returned. See ErrorWithPartialResult.
+	Call(ctx context.Context, procedurePath string, request proto.Message, options ...CallOption) (proto.Message, error)
+	// CallJSON calls the unary Procedure with the given JSON request, and returns the
+	// response as JSON.
+	//
+	// The request is validated with NewRequestForJSON. Empty data results in an empty request.
+	//
+	// If the call results in an error with a partial result, both the response and the
+	// error are returned. See ErrorWithPartialResult.
+	CallJSON(ctx context.Context, procedurePath string, request []byte, options ...CallOption) ([]byte, error)
+	// CallServerStream calls the server-streaming Procedure with the given request, and
+	// calls onResponse for every response as a dynamic message.
+	//
+	// The request must be of the input type of the method. A nil request results in an
+	// empty request.
+	CallServerStream(
+		ctx context.Context,
+		procedurePath string,
+		request proto.Message,
+		onResponse func(proto.Message) error,
+		options ...CallOption,
+	) error
+	// CallServerStreamJSON calls the server-streaming Procedure with the given JSON request,
+	// and calls onResponse for every response as JSON.
+	CallServerStreamJSON(
+		ctx context.Context,
+		procedurePath string,
+		request []byte,
+		onResponse func([]byte) error,
+		options ...CallOption,
+	) error
+
+	isDynamicClient()
+}
+
+// NewDynamicClient returns a new DynamicClient for the Client, which finds the descriptors
+// of Procedures in the given Files.
+//
+// If files is nil, protoregistry.GlobalFiles is used.
+func NewDynamicClient(client Client, files *protoregistry.Files) DynamicClient {
+	return newDynamicClient(client, files)
+}
+
+// NewDynamicClientForFileDescriptorSet returns a new DynamicClient for the Client, which
+// finds the descriptors of Procedures in the given FileDescriptorSet.
+//
+// The FileDescriptorSet must contain all dependencies of its files, as produced for example
+// by "buf build" or "protoc --include_imports".
+func NewDynamicClientForFileDescriptorSet(client Client, fileDescriptorSet *descriptorpb.FileDescriptorSet) (DynamicClient, error) {
+	files, err := protodesc.NewFiles(fileDescriptorSet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid FileDescriptorSet: %w", err)
+	}
+	return newDynamicClient(client, files), nil
+}
+
+// *** PRIVATE ***
+
+type dynamicClient struct {
+	client Client
+	files  *protoregistry.Files
+	// types resolves the types of Any values within responses when marshaling to JSON.
+	types *dynamicpb.Types
+}
+
+func newDynamicClient(client Client, files *protoregistry.Files) *dynamicClient {
+	if files == nil {
+		files = protoregistry.GlobalFiles
+	}
+	return &dynamicClient{
+		client: client,
+		files:  files,
+		types:  dynamicpb.NewTypes(files),
+	}
+}
+
+func (d *dynamicClient) MethodDescriptor(ctx context.Context, procedurePath string) (protoreflect.MethodDescriptor, error) {
+	spec, err := d.client.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if spec.ProcedureForPath(procedurePath) == nil {
+		return nil, NewErrorf(CodeUnimplemented, "procedure unimplemented: %q", procedurePath)
+	}
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(procedurePath, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("procedure %q does not have a path of the form /package.Service/Method", procedurePath)
+	}
+	descriptor, err := d.files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: %w", procedurePath, err)
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: %q is not a service", procedurePath, serviceName)
+	}
+	methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(methodName))
+	if methodDescriptor == nil {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: method %q not found", procedurePath, methodName)
+	}
+	if methodDescriptor.IsStreamingClient() {
+		return nil, fmt.Errorf("procedure %q is client-streaming, which is not supported", procedurePath)
+	}
+	return methodDescriptor, nil
+}
+
+func (d *dynamicClient) Call(
+	ctx context.Context,
+	procedurePath string,
+	request proto.Message,
+	options ...CallOption,
+) (proto.Message, error) {
+	methodDescriptor, err := d.methodDescriptor(ctx, procedurePath, false)
+	if err != nil {
+		return nil, err
+	}
+	request, err = newDynamicRequest(methodDescriptor, request)
+	if err != nil {
+		return nil, err
+	}
+	response := dynamicpb.NewMessage(methodDescriptor.Output())
+	if err := d.client.Call(ctx, procedurePath, request, response, options...); err != nil {
+		if HasPartialResult(err) {
+			return response, err
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+func (d *dynamicClient) CallJSON(
+	ctx context.Context,
+	procedurePath string,
+	request []byte,
+	options ...CallOption,
+) ([]byte, error) {
+	methodDescriptor, err := d.methodDescriptor(ctx, procedurePath, false)
+	if err != nil {
+		return nil, err
+	}
+	protoRequest, err := NewRequestForJSON(request, methodDescriptor.Input())
+	if err != nil {
+		return nil, err
+	}
+	response, err := d.Call(ctx, procedurePath, protoRequest, options...)
+	if response == nil {
+		return nil, err
+	}
+	data, marshalErr := d.marshalJSON(response)
+	if marshalErr != nil {
+		return nil, errors.Join(err, marshalErr)
+	}
+	return data, err
+}
+
+func (d *dynamicClient) CallServerStream(
+	ctx context.Context,
+	procedurePath string,
+	request proto.Message,
+	onResponse func(proto.Message) error,
+	options ...CallOption,
+) error {
+	methodDescriptor, err := d.methodDescriptor(ctx, procedurePath, true)
+	if err != nil {
+		return err
+	}
+	request, err = newDynamicRequest(methodDescriptor, request)
+	if err != nil {
+		return err
+	}
+	return d.client.CallServerStream(
+		ctx,
+		procedurePath,
+		request,
+		func() any { return dynamicpb.NewMessage(methodDescriptor.Output()) },
+		func(response any) error {
+			return onResponse(response.(proto.Message))
+		},
+		options...,
+	)
+}
+
+func (d *dynamicClient) CallServerStreamJSON(
+	ctx context.Context,
+	procedurePath string,
+	request []byte,
+	onResponse func([]byte) error,
+	options ...CallOption,
+) error {
+	methodDescriptor, err := d.methodDescriptor(ctx, procedurePath, true)
+	if err != nil {
+		return err
+	}
+	protoRequest, err := NewRequestForJSON(request, methodDescriptor.Input())
+	if err != nil {
+		return err
+	}
+	return d.CallServerStream(
+		ctx,
+		procedurePath,
+		protoRequest,
+		func(response proto.Message) error {
+			data, err := d.marshalJSON(response)
+			if err != nil {
+				return err
+			}
+			return onResponse(data)
+		},
+		options...,
+	)
+}
+
+func (*dynamicClient) isDynamicClient() {}
+
+// methodDescriptor returns the MethodDescriptor for the Procedure, checking that the
+// method is server-streaming if and only if serverStreaming is true.
+func (d *dynamicClient) methodDescriptor(ctx context.Context, procedurePath string, serverStreaming bool) (protoreflect.MethodDescriptor, error) {
+	methodDescriptor, err := d.MethodDescriptor(ctx, procedurePath)
+	if err != nil {
+		return nil, err
+	}
+	if methodDescriptor.IsStreamingServer() != serverStreaming {
+		if serverStreaming {
+			return nil, fmt.Errorf("procedure %q is not server-streaming", procedurePath)
+		}
+		return nil, fmt.Errorf("procedure %q is server-streaming", procedurePath)
+	}
+	return methodDescriptor, nil
+}
+
+func (d *dynamicClient) marshalJSON(message proto.Message) ([]byte, error) {
+	return protojson.MarshalOptions{Resolver: d.types}.Marshal(message)
+}
+
+// newDynamicRequest returns the request, or an empty request if the request is nil.
+//
+// Returns an error if the request is not of the input type of the method.
+func newDynamicRequest(methodDescriptor protoreflect.MethodDescriptor, request proto.Message) (proto.Message, error) {
+	if isNilProtoMessage(request) {
+		return dynamicpb.NewMessage(methodDescriptor.Input()), nil
+	}
+	if fullName := request.ProtoReflect().Descriptor().FullName(); fullName != methodDescriptor.Input().FullName() {
+		return nil, fmt.Errorf("request of type %s is not of the input type %s", fullName, methodDescriptor.Input().FullName())
+	}
+	return request, nil
+}
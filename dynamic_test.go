@@ -0,0 +1,101 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestDynamicClient(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			dynamicClient, err := pluginrpc.NewDynamicClientForFileDescriptorSet(
+				client,
+				newFileDescriptorSet(examplev1.File_pluginrpc_example_v1_example_proto),
+			)
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			data, err := dynamicClient.CallJSON(ctx, examplev1pluginrpc.EchoServiceEchoRequestPath, []byte(`{"message":"hello"}`))
+			require.NoError(t, err)
+			require.JSONEq(t, `{"message":"hello"}`, string(data))
+
+			response, err := dynamicClient.Call(ctx, examplev1pluginrpc.EchoServiceEchoListPath, nil)
+			require.NoError(t, err)
+			m, err := pluginrpc.ProtoMessageToMap(response)
+			require.NoError(t, err)
+			require.Equal(t, map[string]any{"list": []any{"foo", "bar"}}, m)
+
+			var messages []string
+			require.NoError(
+				t,
+				dynamicClient.CallServerStreamJSON(
+					ctx,
+					examplev1pluginrpc.EchoServiceEchoStreamPath,
+					[]byte(`{"messages":["foo","bar"]}`),
+					func(data []byte) error {
+						messages = append(messages, string(data))
+						return nil
+					},
+				),
+			)
+			require.Len(t, messages, 2)
+			require.JSONEq(t, `{"message":"foo"}`, messages[0])
+			require.JSONEq(t, `{"message":"bar"}`, messages[1])
+
+			_, err = dynamicClient.CallJSON(ctx, examplev1pluginrpc.EchoServiceEchoRequestPath, []byte(`{"unknown":1}`))
+			require.ErrorContains(t, err, "unknown")
+			_, err = dynamicClient.Call(ctx, examplev1pluginrpc.EchoServiceEchoRequestPath, &examplev1.EchoListRequest{})
+			require.ErrorContains(t, err, "not of the input type")
+			_, err = dynamicClient.Call(ctx, examplev1pluginrpc.EchoServiceEchoStreamPath, nil)
+			require.ErrorContains(t, err, "is server-streaming")
+			_, err = dynamicClient.Call(ctx, "/pluginrpc.example.v1.EchoService/Unknown", nil)
+			pluginrpcError := &pluginrpc.Error{}
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, pluginrpc.CodeUnimplemented, pluginrpcError.Code())
+		},
+	)
+}
+
+// newFileDescriptorSet returns a FileDescriptorSet containing the file and all its dependencies.
+func newFileDescriptorSet(fileDescriptor protoreflect.FileDescriptor) *descriptorpb.FileDescriptorSet {
+	fileDescriptorSet := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]struct{})
+	var add func(protoreflect.FileDescriptor)
+	add = func(fileDescriptor protoreflect.FileDescriptor) {
+		if _, ok := seen[fileDescriptor.Path()]; ok {
+			return
+		}
+		seen[fileDescriptor.Path()] = struct{}{}
+		imports := fileDescriptor.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fileDescriptorSet.File = append(fileDescriptorSet.File, protodesc.ToFileDescriptorProto(fileDescriptor))
+	}
+	add(fileDescriptor)
+	return fileDescriptorSet
+}
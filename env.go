@@ -15,13 +15,15 @@
 package pluginrpc
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // OSEnv is an Env using os.Args, os.Stdin, os.Stdout, and os.Stderr.
 var OSEnv = Env{
-	Args:   os.Args[1:],
+	Args:   osArgs(),
 	Stdin:  os.Stdin,
 	Stdout: os.Stdout,
 	Stderr: os.Stderr,
@@ -37,3 +39,162 @@ type Env struct {
 	Stdout io.Writer
 	Stderr io.Writer
 }
+
+// NewEnv returns a new Env.
+//
+// Any stdio not specified is given the equivalent of /dev/null, so the returned
+// Env will never have nil stdio. The returned Env is validated with Validate.
+func NewEnv(options ...EnvOption) (Env, error) {
+	envOptions := newEnvOptions()
+	for _, option := range options {
+		option(envOptions)
+	}
+	env := Env{
+		Args:   envOptions.args,
+		Stdin:  envOptions.stdin,
+		Stdout: envOptions.stdout,
+		Stderr: envOptions.stderr,
+	}.withDefaults()
+	if err := env.Validate(); err != nil {
+		return Env{}, err
+	}
+	return env, nil
+}
+
+// EnvOption is an option for a new Env.
+type EnvOption func(*envOptions)
+
+// EnvWithArgs returns a new EnvOption that specifies the args.
+//
+// The default is no args.
+func EnvWithArgs(args ...string) EnvOption {
+	return func(envOptions *envOptions) {
+		envOptions.args = args
+	}
+}
+
+// EnvWithStdin returns a new EnvOption that specifies stdin.
+//
+// If stdin is nil, this has no effect. The default is an empty reader.
+func EnvWithStdin(stdin io.Reader) EnvOption {
+	return func(envOptions *envOptions) {
+		if stdin != nil {
+			envOptions.stdin = stdin
+		}
+	}
+}
+
+// EnvWithStdout returns a new EnvOption that specifies stdout.
+//
+// If stdout is nil, this has no effect. The default is to discard stdout.
+func EnvWithStdout(stdout io.Writer) EnvOption {
+	return func(envOptions *envOptions) {
+		if stdout != nil {
+			envOptions.stdout = stdout
+		}
+	}
+}
+
+// EnvWithStderr returns a new EnvOption that specifies stderr.
+//
+// If stderr is nil, this has no effect. The default is to discard stderr.
+func EnvWithStderr(stderr io.Writer) EnvOption {
+	return func(envOptions *envOptions) {
+		if stderr != nil {
+			envOptions.stderr = stderr
+		}
+	}
+}
+
+// Validate validates the Env.
+//
+// Stdin, stdout, and stderr must be non-nil, and no arg may contain a NUL byte.
+// If the Env is invalid, a *EnvError is returned.
+func (e Env) Validate() error {
+	if e.Stdin == nil {
+		return newEnvError("Stdin", "is nil")
+	}
+	if e.Stdout == nil {
+		return newEnvError("Stdout", "is nil")
+	}
+	if e.Stderr == nil {
+		return newEnvError("Stderr", "is nil")
+	}
+	for i, arg := range e.Args {
+		if strings.IndexByte(arg, 0) >= 0 {
+			return newEnvError("Args", fmt.Sprintf("has NUL byte in arg %d: %q", i, arg))
+		}
+	}
+	return nil
+}
+
+// EnvError is an error returned when an Env is invalid.
+type EnvError struct {
+	field   string
+	message string
+}
+
+// Field returns the name of the invalid field of the Env, for example "Stdin".
+//
+// If e is nil, this returns the empty string.
+func (e *EnvError) Field() string {
+	if e == nil {
+		return ""
+	}
+	return e.field
+}
+
+// Error implements error.
+//
+// If e is nil, this returns the empty string.
+func (e *EnvError) Error() string {
+	if e == nil {
+		return ""
+	}
+	return "invalid Env: " + e.field + " " + e.message
+}
+
+// *** PRIVATE ***
+
+func newEnvError(field string, message string) *EnvError {
+	return &EnvError{
+		field:   field,
+		message: message,
+	}
+}
+
+// withDefaults returns a copy of the Env with the equivalent of /dev/null
+// given for any nil stdio.
+func (e Env) withDefaults() Env {
+	if e.Stdin == nil {
+		e.Stdin = discardReader{}
+	}
+	if e.Stdout == nil {
+		e.Stdout = io.Discard
+	}
+	if e.Stderr == nil {
+		e.Stderr = io.Discard
+	}
+	return e
+}
+
+// osArgs returns os.Args without the program name.
+//
+// This is safe to call if os.Args is empty.
+func osArgs() []string {
+	if len(os.Args) == 0 {
+		return nil
+	}
+	return os.Args[1:]
+}
+
+type envOptions struct {
+	args   []string
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func newEnvOptions() *envOptions {
+	return &envOptions{}
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FormatEnvVarName is the name of the environment variable that specifies the default
+	// Format, see ClientWithEnvDefaults and ServerWithEnvDefaults.
+	FormatEnvVarName = "PLUGINRPC_FORMAT"
+	// ProtocolEnvVarName is the name of the environment variable that specifies the
+	// protocol version, see ClientWithEnvDefaults and ServerWithEnvDefaults.
+	ProtocolEnvVarName = "PLUGINRPC_PROTOCOL"
+)
+
+// *** PRIVATE ***
+
+// envDefaults are the defaults read from FormatEnvVarName and ProtocolEnvVarName.
+type envDefaults struct {
+	// format is 0 if not set.
+	format Format
+	// protocolVersion is 0 if not set.
+	protocolVersion int
+}
+
+// getEnvDefaults reads the envDefaults from the environment of the current process.
+//
+// Values are validated, and only protocolVersion is supported as a protocol version.
+func getEnvDefaults() (envDefaults, error) {
+	var envDefaults envDefaults
+	if value, ok := os.LookupEnv(FormatEnvVarName); ok && value != "" {
+		envDefaults.format = FormatForString(value)
+		if envDefaults.format == 0 {
+			return envDefaults, fmt.Errorf("invalid value for %s: %q", FormatEnvVarName, value)
+		}
+	}
+	if value, ok := os.LookupEnv(ProtocolEnvVarName); ok && value != "" {
+		version, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return envDefaults, fmt.Errorf("invalid value for %s: %q", ProtocolEnvVarName, value)
+		}
+		if version != protocolVersion {
+			return envDefaults, fmt.Errorf("unsupported protocol version for %s: %d", ProtocolEnvVarName, version)
+		}
+		envDefaults.protocolVersion = version
+	}
+	return envDefaults, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEnv(t *testing.T) {
+	t.Parallel()
+
+	env, err := NewEnv()
+	require.NoError(t, err)
+	require.NoError(t, env.Validate())
+	require.Empty(t, env.Args)
+
+	stdout := bytes.NewBuffer(nil)
+	env, err = NewEnv(EnvWithArgs("foo", "bar"), EnvWithStdout(stdout), EnvWithStderr(nil))
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar"}, env.Args)
+	require.Equal(t, stdout, env.Stdout)
+	require.NotNil(t, env.Stderr)
+
+	_, err = NewEnv(EnvWithArgs("foo\x00"))
+	envError := &EnvError{}
+	require.ErrorAs(t, err, &envError)
+	require.Equal(t, "Args", envError.Field())
+}
+
+func TestEnvValidate(t *testing.T) {
+	t.Parallel()
+
+	err := Env{Stdin: bytes.NewReader(nil), Stderr: bytes.NewBuffer(nil)}.Validate()
+	envError := &EnvError{}
+	require.ErrorAs(t, err, &envError)
+	require.Equal(t, "Stdout", envError.Field())
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return errors.New("should not be called")
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	// Servers require a valid Env, while Runners give nil stdio the equivalent of /dev/null.
+	err = server.Serve(context.Background(), Env{Args: []string{"--" + SpecFlagName}})
+	require.ErrorAs(t, err, &envError)
+	require.Equal(t, "Stdin", envError.Field())
+	require.NoError(t, NewServerRunner(server).Run(context.Background(), Env{Args: []string{"--" + SpecFlagName}}))
+}
@@ -15,11 +15,18 @@
 package pluginrpc
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
 )
 
 // TODO: Figure out when and where to wrap errors created by this package with Errors.
@@ -28,6 +35,14 @@ import (
 type Error struct {
 	code       Code
 	underlying error
+	retryAfter time.Duration
+	// localizedMessages are keyed by locale.
+	localizedMessages map[string]string
+	// locale is the preferred locale of the client that received the Error.
+	locale string
+	// partialResult is true if a partial result accompanies the Error.
+	partialResult bool
+	details       []*anypb.Any
 }
 
 // NewError returns a new Error.
@@ -36,15 +51,61 @@ type Error struct {
 //
 // An Error will never have an invalid Code or nil underlying error
 // when returned from this function.
-func NewError(code Code, underlying error) *Error {
+func NewError(code Code, underlying error, options ...ErrorOption) *Error {
+	errorOptions := newErrorOptions()
+	for _, option := range options {
+		option(errorOptions)
+	}
 	return validateError(
 		&Error{
-			code:       code,
-			underlying: underlying,
+			code:              code,
+			underlying:        underlying,
+			retryAfter:        errorOptions.retryAfter,
+			localizedMessages: errorOptions.localizedMessages,
+			partialResult:     errorOptions.partialResult,
+			details:           errorOptions.details,
 		},
 	)
 }
 
+// ErrorOption is an option for a new Error.
+type ErrorOption func(*errorOptions)
+
+// ErrorWithRetryAfter returns a new ErrorOption that hints to the client that it
+// should wait for the given duration before retrying the call.
+//
+// This allows plugins to apply backpressure to hosts. The hint is only sent to
+// clients that specify the --error-details flag, see ClientWithErrorDetails.
+//
+// The default is no hint. Negative durations are treated as no hint.
+func ErrorWithRetryAfter(retryAfter time.Duration) ErrorOption {
+	return func(errorOptions *errorOptions) {
+		if retryAfter > 0 {
+			errorOptions.retryAfter = retryAfter
+		}
+	}
+}
+
+// ErrorWithDetails returns a new ErrorOption that attaches the given messages to the Error
+// as structured details, analogous to gRPC status details, for example to describe
+// validation failures.
+//
+// Details are only sent to clients that specify the --error-details flag, see
+// ClientWithErrorDetails. This option can be specified multiple times, in which case
+// the details are appended. Messages that cannot be marshaled are ignored.
+//
+// With FormatJSON, the types of the details must be registered in protoregistry.GlobalTypes
+// of both the plugin and the client.
+func ErrorWithDetails(details ...proto.Message) ErrorOption {
+	return func(errorOptions *errorOptions) {
+		for _, detail := range details {
+			if anyDetail, err := anypb.New(detail); err == nil {
+				errorOptions.details = append(errorOptions.details, anyDetail)
+			}
+		}
+	}
+}
+
 // NewErrorf returns a new Error.
 
 // Code and a non-empty message are required.
@@ -79,6 +140,10 @@ func NewErrorForProto(protoError *pluginrpcv1.Error) *Error {
 //
 // If the given error is nil, this returns nil.
 // If the given error is already a Error, this is returned.
+// If the given error was created by joining multiple errors, for example via errors.Join,
+// it is not unwrapped to the first joined Error, so that the other joined errors are kept.
+// If the given error is context.Canceled or context.DeadlineExceeded, an error
+// with code CodeCanceled or CodeDeadlineExceeded respectively is returned.
 // Otherwise, an error with code CodeUnknown is returned.
 //
 // An Error will never have an invalid Code when returned from this function.
@@ -87,9 +152,15 @@ func WrapError(err error) *Error {
 		return nil
 	}
 	pluginrpcError := &Error{}
-	if errors.As(err, &pluginrpcError) {
+	if !isJoinedError(err) && errors.As(err, &pluginrpcError) {
 		return validateError(pluginrpcError)
 	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return NewError(CodeCanceled, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewError(CodeDeadlineExceeded, err)
+	}
 	return NewError(CodeUnknown, err)
 }
 
@@ -103,6 +174,123 @@ func (e *Error) Code() Code {
 	return e.code
 }
 
+// RetryAfter returns the duration the plugin asked the client to wait before
+// retrying the call.
+//
+// If e is nil or the plugin gave no hint, this returns 0.
+func (e *Error) RetryAfter() time.Duration {
+	if e == nil {
+		return 0
+	}
+	return e.retryAfter
+}
+
+// Details returns the structured details attached to the Error with ErrorWithDetails.
+//
+// Details whose types are registered in protoregistry.GlobalTypes, for example by
+// importing their generated Go packages, are returned as their concrete types. Other
+// details are returned as *anypb.Any.
+//
+// If e is nil or has no details, this returns nil.
+func (e *Error) Details() []proto.Message {
+	if e == nil || len(e.details) == 0 {
+		return nil
+	}
+	details := make([]proto.Message, len(e.details))
+	for i, anyDetail := range e.details {
+		detail, err := anyDetail.UnmarshalNew()
+		if err != nil {
+			detail = proto.Clone(anyDetail)
+		}
+		details[i] = detail
+	}
+	return details
+}
+
+// ErrorWithLocalizedMessage returns a new ErrorOption that attaches a message for the
+// given locale to the Error, for hosts that present plugin errors directly to end users.
+//
+// The locale should be a BCP 47 language tag, for example "en-US". This option can be
+// specified multiple times for different locales. Localized messages are only sent to
+// clients that specify the --error-details flag, see ClientWithLocale.
+//
+// Empty locales or messages are ignored.
+func ErrorWithLocalizedMessage(locale string, message string) ErrorOption {
+	return func(errorOptions *errorOptions) {
+		if locale == "" || message == "" {
+			return
+		}
+		if errorOptions.localizedMessages == nil {
+			errorOptions.localizedMessages = make(map[string]string)
+		}
+		errorOptions.localizedMessages[locale] = message
+	}
+}
+
+// ErrorWithPartialResult returns a new ErrorOption that says that the response returned
+// alongside the Error is a partial result.
+//
+// This allows batch-style plugins to return what they accomplished alongside the error.
+// When a handler returns both a non-nil response and an Error with a partial result,
+// both are sent to the client, and generated clients return both. If this option is
+// not specified, any response returned alongside an error is dropped.
+//
+// See HasPartialResult.
+func ErrorWithPartialResult() ErrorOption {
+	return func(errorOptions *errorOptions) {
+		errorOptions.partialResult = true
+	}
+}
+
+// HasPartialResult returns true if the given error is or wraps an Error that
+// is accompanied by a partial result.
+//
+// On the client side, this is true if the plugin returned a response alongside the
+// error, in which case the response given to Client.Call was populated.
+func HasPartialResult(err error) bool {
+	pluginrpcError := &Error{}
+	if errors.As(err, &pluginrpcError) {
+		return pluginrpcError.partialResult
+	}
+	return false
+}
+
+// LocalizedMessages returns a copy of the localized messages attached to the Error,
+// keyed by locale.
+//
+// If e is nil or there are no localized messages, this returns nil.
+func (e *Error) LocalizedMessages() map[string]string {
+	if e == nil || len(e.localizedMessages) == 0 {
+		return nil
+	}
+	localizedMessages := make(map[string]string, len(e.localizedMessages))
+	for locale, message := range e.localizedMessages {
+		localizedMessages[locale] = message
+	}
+	return localizedMessages
+}
+
+// LocalizedMessage returns the message for the preferred locale of the Client that
+// returned the Error, as set by ClientWithLocale.
+//
+// If there is no message for the exact locale, a message for the base language
+// (for example "fr" for "fr-CA") is used. If no localized message matches, the
+// unlocalized message is returned.
+//
+// If e is nil, this returns the empty string.
+func (e *Error) LocalizedMessage() string {
+	if e == nil {
+		return ""
+	}
+	if message := localizedMessageForLocale(e.localizedMessages, e.locale); message != "" {
+		return message
+	}
+	if e.underlying == nil {
+		return ""
+	}
+	return e.underlying.Error()
+}
+
 // ToProto converts the Error to a pluginrpcv1.Error.
 //
 // If e is nil, this returns nil.
@@ -153,6 +341,173 @@ func (e *Error) Unwrap() error {
 
 // *** PRIVATE ***
 
+type errorOptions struct {
+	retryAfter        time.Duration
+	localizedMessages map[string]string
+	partialResult     bool
+	details           []*anypb.Any
+}
+
+func newErrorOptions() *errorOptions {
+	return &errorOptions{}
+}
+
+// toProtoErrorDetails returns the extv1.ErrorDetails for the Error.
+//
+// If the Error has no details, this returns nil.
+func (e *Error) toProtoErrorDetails() *extv1.ErrorDetails {
+	if e == nil {
+		return nil
+	}
+	protoJoinedErrors := newProtoJoinedErrors(e.underlying)
+	if e.retryAfter <= 0 && len(e.localizedMessages) == 0 && len(protoJoinedErrors) == 0 && len(e.details) == 0 {
+		return nil
+	}
+	protoErrorDetails := &extv1.ErrorDetails{
+		LocalizedMessages: e.LocalizedMessages(),
+		JoinedErrors:      protoJoinedErrors,
+		Details:           e.details,
+	}
+	if e.retryAfter > 0 {
+		protoErrorDetails.RetryAfter = durationpb.New(e.retryAfter)
+	}
+	return protoErrorDetails
+}
+
+// withProtoErrorDetails returns a copy of the Error with the given extv1.ErrorDetails applied.
+func (e *Error) withProtoErrorDetails(protoErrorDetails *extv1.ErrorDetails) *Error {
+	if e == nil || protoErrorDetails == nil {
+		return e
+	}
+	clone := *e
+	if retryAfter := protoErrorDetails.GetRetryAfter(); retryAfter != nil {
+		if duration := retryAfter.AsDuration(); duration > 0 {
+			clone.retryAfter = duration
+		}
+	}
+	clone.localizedMessages = e.LocalizedMessages()
+	for locale, message := range protoErrorDetails.GetLocalizedMessages() {
+		if locale == "" || message == "" {
+			continue
+		}
+		if clone.localizedMessages == nil {
+			clone.localizedMessages = make(map[string]string)
+		}
+		clone.localizedMessages[locale] = message
+	}
+	clone.details = append(slices.Clone(e.details), protoErrorDetails.GetDetails()...)
+	if protoJoinedErrors := protoErrorDetails.GetJoinedErrors(); len(protoJoinedErrors) > 0 && clone.underlying != nil {
+		clone.underlying = newJoinedErrorForProto(clone.underlying.Error(), protoJoinedErrors)
+	}
+	return &clone
+}
+
+// withLocale returns a copy of the Error with the given preferred locale.
+func (e *Error) withLocale(locale string) *Error {
+	if e == nil || locale == "" {
+		return e
+	}
+	clone := *e
+	clone.locale = locale
+	return &clone
+}
+
+// localizedMessageForLocale returns the message for the locale, falling back to
+// a case-insensitive match and then to the base language of the locale.
+//
+// Returns the empty string if there is no match.
+func localizedMessageForLocale(localizedMessages map[string]string, locale string) string {
+	if len(localizedMessages) == 0 || locale == "" {
+		return ""
+	}
+	if message, ok := localizedMessages[locale]; ok {
+		return message
+	}
+	normalizedLocale := normalizeLocale(locale)
+	baseLanguage, _, _ := strings.Cut(normalizedLocale, "-")
+	var baseLanguageMessage string
+	for candidateLocale, message := range localizedMessages {
+		normalizedCandidateLocale := normalizeLocale(candidateLocale)
+		if normalizedCandidateLocale == normalizedLocale {
+			return message
+		}
+		if normalizedCandidateLocale == baseLanguage {
+			baseLanguageMessage = message
+		}
+	}
+	return baseLanguageMessage
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// newProtoJoinedErrors returns the extv1.JoinedErrors for the error if it
+// was created by joining other errors, for example via errors.Join.
+//
+// Returns nil if the error was not created by joining other errors.
+func newProtoJoinedErrors(err error) []*extv1.JoinedError {
+	multiErr, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil
+	}
+	var protoJoinedErrors []*extv1.JoinedError
+	for _, joinedErr := range multiErr.Unwrap() {
+		if joinedErr == nil {
+			continue
+		}
+		protoJoinedError := &extv1.JoinedError{
+			Message: joinedErr.Error(),
+		}
+		pluginrpcError := &Error{}
+		if errors.As(joinedErr, &pluginrpcError) {
+			pluginrpcError = validateError(pluginrpcError)
+			protoJoinedError.Code = uint32(pluginrpcError.Code())
+			protoJoinedError.Message = pluginrpcError.Unwrap().Error()
+		}
+		protoJoinedErrors = append(protoJoinedErrors, protoJoinedError)
+	}
+	return protoJoinedErrors
+}
+
+// isJoinedError returns true if the error was created by joining multiple errors.
+func isJoinedError(err error) bool {
+	multiErr, ok := err.(interface{ Unwrap() []error })
+	return ok && len(multiErr.Unwrap()) > 1
+}
+
+// joinedError is an error reconstructed from extv1.JoinedErrors.
+//
+// The message is preserved as given on the wire, while the joined errors are
+// available via errors.Is and errors.As.
+type joinedError struct {
+	message string
+	errs    []error
+}
+
+func newJoinedErrorForProto(message string, protoJoinedErrors []*extv1.JoinedError) *joinedError {
+	errs := make([]error, 0, len(protoJoinedErrors))
+	for _, protoJoinedError := range protoJoinedErrors {
+		var err error = errors.New(protoJoinedError.GetMessage())
+		if code := Code(protoJoinedError.GetCode()); code != 0 {
+			err = NewError(code, err)
+		}
+		errs = append(errs, err)
+	}
+	return &joinedError{
+		message: message,
+		errs:    errs,
+	}
+}
+
+func (j *joinedError) Error() string {
+	return j.message
+}
+
+func (j *joinedError) Unwrap() []error {
+	return j.errs
+}
+
 func validateError(pluginrpcError *Error) *Error {
 	code := pluginrpcError.Code()
 	underlying := pluginrpcError.Unwrap()
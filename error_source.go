@@ -0,0 +1,148 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorSource is the origin of an error returned by a Client.
+//
+// This allows hosts to distinguish a plugin that failed from a plugin that could not
+// be run, for example to present them differently to users.
+type ErrorSource uint32
+
+const (
+	// ErrorSourceMarshal indicates that the request could not be prepared by the client,
+	// for example because it could not be marshaled or the procedure is not in the Spec.
+	ErrorSourceMarshal ErrorSource = 1
+	// ErrorSourceSpawn indicates that the plugin could not be run, for example because
+	// the program was not found. This includes all errors from Runners other than *ExitErrors.
+	ErrorSourceSpawn ErrorSource = 2
+	// ErrorSourceTransport indicates that the plugin was run, but exited without producing
+	// a response, for example because it crashed or was killed.
+	ErrorSourceTransport ErrorSource = 3
+	// ErrorSourcePlugin indicates that the plugin ran and reported the error in its response.
+	ErrorSourcePlugin ErrorSource = 4
+	// ErrorSourceDecode indicates that the plugin ran, but its response could not be decoded.
+	ErrorSourceDecode ErrorSource = 5
+)
+
+// String implements fmt.Stringer.
+func (s ErrorSource) String() string {
+	switch s {
+	case ErrorSourceMarshal:
+		return "marshal"
+	case ErrorSourceSpawn:
+		return "spawn"
+	case ErrorSourceTransport:
+		return "transport"
+	case ErrorSourcePlugin:
+		return "plugin"
+	case ErrorSourceDecode:
+		return "decode"
+	}
+	return fmt.Sprintf("error_source_%d", s)
+}
+
+// SourceError wraps an error returned by a Client with its ErrorSource.
+//
+// The underlying error is unchanged, and is available with errors.As and errors.Is.
+type SourceError struct {
+	source     ErrorSource
+	underlying error
+}
+
+// Source returns the ErrorSource.
+//
+// If e is nil, this returns 0.
+func (e *SourceError) Source() ErrorSource {
+	if e == nil {
+		return 0
+	}
+	return e.source
+}
+
+// Error implements error.
+//
+// This is the message of the underlying error.
+// If e is nil, this returns the empty string.
+func (e *SourceError) Error() string {
+	if e == nil || e.underlying == nil {
+		return ""
+	}
+	return e.underlying.Error()
+}
+
+// Unwrap implements error.
+//
+// If e is nil, this returns nil.
+func (e *SourceError) Unwrap() error {
+	if e == nil {
+		return nil
+	}
+	return e.underlying
+}
+
+// ErrorSourceOf returns the ErrorSource of the error.
+//
+// If the error is nil or was not returned by a Client, this returns 0.
+func ErrorSourceOf(err error) ErrorSource {
+	sourceError := &SourceError{}
+	if errors.As(err, &sourceError) {
+		return sourceError.Source()
+	}
+	return 0
+}
+
+// *** PRIVATE ***
+
+// withErrorSource wraps the error with the ErrorSource.
+//
+// If the error is nil, this returns nil. If the error already has an ErrorSource, it is
+// returned as-is.
+func withErrorSource(err error, source ErrorSource) error {
+	if err == nil || ErrorSourceOf(err) != 0 {
+		return err
+	}
+	return &SourceError{
+		source:     source,
+		underlying: err,
+	}
+}
+
+// errorSourceForRunError returns the ErrorSource for an error returned by a Runner.
+//
+// *ExitErrors indicate that the plugin was run, all other errors indicate the plugin
+// could not be run.
+func errorSourceForRunError(err error) ErrorSource {
+	if errors.As(err, new(*ExitError)) {
+		return ErrorSourceTransport
+	}
+	return ErrorSourceSpawn
+}
+
+// withResponseErrorSource wraps the error returned when unmarshaling a response with
+// the ErrorSource.
+//
+// *Errors are reported by the plugin, all other errors indicate the response could
+// not be decoded.
+func withResponseErrorSource(err error) error {
+	if errors.As(err, new(*Error)) {
+		return withErrorSource(err, ErrorSourcePlugin)
+	}
+	return withErrorSource(err, ErrorSourceDecode)
+}
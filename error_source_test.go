@@ -0,0 +1,90 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorSource(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					return nil, NewErrorf(CodeNotFound, "not found")
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	testErrorSource := func(expected ErrorSource, runner Runner, procedurePath string) {
+		err := NewClient(runner, ClientWithSpec(spec)).Call(context.Background(), procedurePath, nil, nil)
+		require.Error(t, err)
+		require.Equal(t, expected, ErrorSourceOf(err), err.Error())
+	}
+
+	testErrorSource(ErrorSourceMarshal, NewServerRunner(server), "/foo/baz")
+	testErrorSource(
+		ErrorSourceSpawn,
+		runnerFunc(func(context.Context, Env) error { return errors.New("not found") }),
+		"/foo/bar",
+	)
+	testErrorSource(
+		ErrorSourceTransport,
+		runnerFunc(func(context.Context, Env) error { return NewExitError(2, errors.New("crashed")) }),
+		"/foo/bar",
+	)
+	testErrorSource(ErrorSourcePlugin, NewServerRunner(server), "/foo/bar")
+	testErrorSource(
+		ErrorSourceDecode,
+		runnerFunc(
+			func(_ context.Context, env Env) error {
+				_, err := env.Stdout.Write([]byte("not a response"))
+				return err
+			},
+		),
+		"/foo/bar",
+	)
+
+	// The underlying errors are unchanged.
+	err = NewClient(NewServerRunner(server)).Call(context.Background(), "/foo/bar", nil, nil)
+	pluginrpcError := &Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeNotFound, pluginrpcError.Code())
+	require.Equal(t, pluginrpcError.Error(), err.Error())
+
+	// Errors from getting the Spec are attributed as well.
+	_, err = NewClient(runnerFunc(func(context.Context, Env) error { return errors.New("not found") })).Spec(context.Background())
+	require.Equal(t, ErrorSourceSpawn, ErrorSourceOf(err))
+	require.Equal(t, ErrorSource(0), ErrorSourceOf(errors.New("foo")))
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestErrorLocalizedMessage(t *testing.T) {
+	t.Parallel()
+
+	inputErr := NewError(
+		CodeInvalidArgument,
+		errors.New("invalid input"),
+		ErrorWithLocalizedMessage("en-US", "Invalid input."),
+		ErrorWithLocalizedMessage("fr", "Entrée invalide."),
+		ErrorWithLocalizedMessage("", "ignored"),
+	)
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		data, err := marshalResponse(format, nil, inputErr, true)
+		require.NoError(t, err)
+		err = unmarshalResponse(format, data, nil)
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, CodeInvalidArgument, pluginrpcError.Code())
+		require.Equal(t, map[string]string{"en-US": "Invalid input.", "fr": "Entrée invalide."}, pluginrpcError.LocalizedMessages())
+		require.Equal(t, "invalid input", pluginrpcError.LocalizedMessage())
+		require.Equal(t, "Invalid input.", pluginrpcError.withLocale("en-US").LocalizedMessage())
+		require.Equal(t, "Invalid input.", pluginrpcError.withLocale("en_us").LocalizedMessage())
+		require.Equal(t, "Entrée invalide.", pluginrpcError.withLocale("fr-CA").LocalizedMessage())
+		require.Equal(t, "invalid input", pluginrpcError.withLocale("de").LocalizedMessage())
+	}
+}
+
+func TestErrorJoinedErrors(t *testing.T) {
+	t.Parallel()
+
+	inputErr := NewError(
+		CodeInvalidArgument,
+		errors.Join(
+			NewErrorf(CodeNotFound, "foo not found"),
+			errors.New("bar is invalid"),
+		),
+	)
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		data, err := marshalResponse(format, nil, inputErr, true)
+		require.NoError(t, err)
+		err = unmarshalResponse(format, data, nil)
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, CodeInvalidArgument, pluginrpcError.Code())
+		require.Equal(t, inputErr.Error(), pluginrpcError.Error())
+		multiErr, ok := pluginrpcError.Unwrap().(interface{ Unwrap() []error })
+		require.True(t, ok)
+		joinedErrs := multiErr.Unwrap()
+		require.Len(t, joinedErrs, 2)
+		joinedPluginrpcError := &Error{}
+		require.ErrorAs(t, joinedErrs[0], &joinedPluginrpcError)
+		require.Equal(t, CodeNotFound, joinedPluginrpcError.Code())
+		require.Equal(t, "foo not found", joinedPluginrpcError.Unwrap().Error())
+		_, ok = joinedErrs[1].(*Error)
+		require.False(t, ok)
+		require.Equal(t, "bar is invalid", joinedErrs[1].Error())
+
+		// Without error details, the message is flattened.
+		data, err = marshalResponse(format, nil, inputErr, false)
+		require.NoError(t, err)
+		err = unmarshalResponse(format, data, nil)
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, inputErr.Error(), pluginrpcError.Error())
+		_, ok = pluginrpcError.Unwrap().(interface{ Unwrap() []error })
+		require.False(t, ok)
+	}
+}
+
+func TestErrorPlainJoinedErrors(t *testing.T) {
+	t.Parallel()
+
+	// Errors joined without an Error at the top are not flattened to the first Error.
+	inputErr := errors.Join(
+		NewErrorf(CodeInvalidArgument, "a"),
+		errors.New("b"),
+		NewErrorf(CodeNotFound, "c"),
+	)
+	require.Equal(t, CodeUnknown, WrapError(inputErr).Code())
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		data, err := marshalResponse(format, nil, inputErr, true)
+		require.NoError(t, err)
+		err = unmarshalResponse(format, data, nil)
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, CodeUnknown, pluginrpcError.Code())
+		require.Equal(t, inputErr.Error(), pluginrpcError.Unwrap().Error())
+		multiErr, ok := pluginrpcError.Unwrap().(interface{ Unwrap() []error })
+		require.True(t, ok)
+		joinedErrs := multiErr.Unwrap()
+		require.Len(t, joinedErrs, 3)
+		require.Equal(t, CodeInvalidArgument, WrapError(joinedErrs[0]).Code())
+		require.Equal(t, "b", joinedErrs[1].Error())
+		require.Equal(t, CodeNotFound, WrapError(joinedErrs[2]).Code())
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	t.Parallel()
+
+	inputErr := NewError(
+		CodeInvalidArgument,
+		errors.New("invalid input"),
+		ErrorWithDetails(wrapperspb.String("foo")),
+		ErrorWithDetails(durationpb.New(time.Second)),
+	)
+	require.Len(t, inputErr.Details(), 2)
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		data, err := marshalResponse(format, nil, inputErr, true)
+		require.NoError(t, err)
+		err = unmarshalResponse(format, data, nil)
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		details := pluginrpcError.Details()
+		require.Len(t, details, 2)
+		require.True(t, proto.Equal(wrapperspb.String("foo"), details[0]))
+		require.True(t, proto.Equal(durationpb.New(time.Second), details[1]))
+
+		// Without error details, the details are not sent.
+		data, err = marshalResponse(format, nil, inputErr, false)
+		require.NoError(t, err)
+		err = unmarshalResponse(format, data, nil)
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Nil(t, pluginrpcError.Details())
+	}
+
+	// Details of unknown types are returned as Anys.
+	unknownDetail := &anypb.Any{TypeUrl: "type.googleapis.com/foo.v1.Unknown", Value: []byte{0x08, 0x01}}
+	unknownErr := NewError(CodeInternal, errors.New("internal"))
+	unknownErr.details = []*anypb.Any{unknownDetail}
+	details := unknownErr.Details()
+	require.Len(t, details, 1)
+	require.True(t, proto.Equal(unknownDetail, details[0]))
+}
@@ -15,51 +15,288 @@
 package pluginrpc
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
 	// ProtocolFlagName is the name of the protocol bool flag.
 	ProtocolFlagName = "protocol"
 	// SpecFlagName is the name of the spec bool flag.
+	//
+	// When specified with the protocol flag, the protocol version is printed on the first
+	// line, followed by the spec, see ClientWithCombinedHandshake.
 	SpecFlagName = "spec"
+	// InfoFlagName is the name of the info bool flag.
+	InfoFlagName = "info"
 	// FormatFlagName is the name of the format string flag.
 	FormatFlagName = "format"
+	// TimeoutFlagName is the name of the timeout duration flag.
+	TimeoutFlagName = "timeout"
+	// CompressFlagName is the name of the compress bool flag.
+	//
+	// This is only valid when used with the spec flag.
+	CompressFlagName = "compress"
+	// DescriptorsFlagName is the name of the descriptors bool flag.
+	//
+	// This is only valid when used with the spec flag. When specified, the plugin includes
+	// its descriptors with the spec, see SpecWithDescriptors.
+	DescriptorsFlagName = "descriptors"
+	// DocsFlagName is the name of the docs bool flag.
+	//
+	// This is only valid when used with the spec flag. When specified, the plugin includes
+	// the documentation of its Procedures with the spec, see ProcedureWithDoc.
+	DocsFlagName = "docs"
+	// VersionFlagName is the name of the version bool flag.
+	//
+	// When specified alone, the plugin prints its version to stdout, followed by the build
+	// metadata of pluginrpc-go on a second line, see pluginrpcinfo. When specified with
+	// the spec flag, the plugin includes its version with the spec, see ServerWithVersion.
+	VersionFlagName = "version"
+	// ErrorDetailsFlagName is the name of the error details bool flag.
+	//
+	// When specified, the plugin may include details such as retry hints in error responses.
+	ErrorDetailsFlagName = "error-details"
+	// NonceFlagName is the name of the nonce string flag.
+	//
+	// This is used for replay protection, see ProcedureWithReplayProtection.
+	NonceFlagName = "nonce"
+	// TimestampFlagName is the name of the timestamp string flag, in RFC 3339 format.
+	//
+	// This is used for replay protection, see ProcedureWithReplayProtection.
+	TimestampFlagName = "timestamp"
+	// MetadataFlagName is the name of the metadata string array flag, with each
+	// value of the form key=value.
+	//
+	// This is used to send request metadata, see CallWithMetadata. The values are visible
+	// in process listings, and are masked in audit records, logs, and debug dumps.
+	MetadataFlagName = "metadata"
+	// ResponseMetadataFlagName is the name of the response metadata bool flag.
+	//
+	// When specified, the plugin may include response metadata in responses, see CallWithResponseMetadata.
+	ResponseMetadataFlagName = "response-metadata"
+	// WarningsFlagName is the name of the warnings bool flag.
+	//
+	// When specified, the plugin includes warnings such as deprecations in responses,
+	// see ClientWithWarningHandler.
+	WarningsFlagName = "warnings"
+	// ServeFlagName is the name of the serve bool flag.
+	//
+	// When specified, the plugin stays alive and serves calls multiplexed over stdin and
+	// stdout until stdin is closed, see NewExecServeRunner.
+	ServeFlagName = "serve"
+	// CompressionFlagName is the name of the compression string flag.
+	//
+	// When specified, the plugin compresses the response with the given Compression, see
+	// ClientWithCompression. Compressed requests are detected regardless of this flag.
+	CompressionFlagName = "compression"
+	// CompressionsFlagName is the name of the compressions bool flag.
+	//
+	// This is only valid when used with the spec flag. When specified, the plugin includes
+	// the Compressions it supports with the spec, see ClientWithCompression.
+	CompressionsFlagName = "compressions"
+	// PayloadFlagName is the name of the payload bool flag.
+	//
+	// When specified, stdin and stdout consist of frames, so that payloads can be streamed
+	// after the request and before the response, see CallWithRequestPayload and
+	// CallWithResponsePayload.
+	PayloadFlagName = "payload"
+	// HelpFormatFlagName is the name of the help format string flag.
+	//
+	// When specified as "json" with --help, the help is printed to stdout as JSON, including
+	// the doc, procedures, and flags of the plugin, for consumption by host UIs.
+	HelpFormatFlagName = "help-format"
 
 	protocolVersion = 1
 	flagWrapping    = 140
+
+	// compressedSpecHeaderByte is the byte that prefixes spec output when --compress
+	// is specified, followed by the gzipped spec.
+	//
+	// This byte is never a valid first byte of either a binary or JSON-encoded spec.
+	compressedSpecHeaderByte byte = 0x01
+	// defaultMaxDecompressedBytes is the default maximum size of decompressed data, see
+	// ClientWithDecompressionLimits and HandlerWithDecompressionLimits.
+	//
+	// There is no default maximum ratio of the size of decompressed data to the size of the
+	// compressed data, as repetitive data such as source files and descriptors routinely
+	// compresses by far more than any ratio that would stop zip bombs early.
+	defaultMaxDecompressedBytes = 64 << 20
+
+	helpFlagName   = "help"
+	helpFormatText = "text"
+	helpFormatJSON = "json"
 )
 
 type flags struct {
-	printProtocol bool
-	printSpec     bool
-	format        Format
+	printProtocol    bool
+	printSpec        bool
+	printInfo        bool
+	compress         bool
+	descriptors      bool
+	docs             bool
+	printVersion     bool
+	errorDetails     bool
+	serve            bool
+	format           Format
+	timeout          time.Duration
+	nonce            string
+	timestamp        time.Time
+	metadata         map[string]string
+	responseMetadata bool
+	warnings         bool
+	compression      Compression
+	compressions     bool
+	payload          bool
 }
 
-func parseFlags(output io.Writer, args []string, spec Spec, doc string) (*flags, []string, error) {
+// parseFlags parses the flags.
+//
+// The default of --format is the given Format.
+//
+// If --help is specified, the help is printed, and pflag.ErrHelp is returned. Help in the
+// text format is printed to output, and help in the JSON format is printed to stdout. If
+// --help is specified with the args of a Procedure, the help for the Procedure is printed
+// instead of the help for the plugin.
+func parseFlags(stdout io.Writer, output io.Writer, args []string, spec Spec, doc string, defaultFormat Format) (*flags, []string, error) {
 	flags := &flags{}
+	var help bool
+	var helpFormat string
 	var formatString string
+	var timestampString string
+	var metadataStrings []string
+	var compressionString string
 	flagSet := pflag.NewFlagSet("plugin", pflag.ContinueOnError)
 	flagSet.Usage = func() {
 		_, _ = fmt.Fprint(output, getFlagUsage(flagSet, spec, doc))
 	}
 	flagSet.SetOutput(output)
 	flagSet.BoolVar(&flags.printProtocol, ProtocolFlagName, false, "Print the protocol to stdout and exit.")
-	flagSet.BoolVar(&flags.printSpec, SpecFlagName, false, "Print the spec to stdout in the specified format and exit.")
-	flagSet.StringVar(&formatString, FormatFlagName, formatBinaryString, fmt.Sprintf("The format to use for requests, responses, and specs. Must be one of [%q, %q].", formatBinaryString, formatJSONString))
+	flagSet.BoolVar(&flags.printSpec, SpecFlagName, false, fmt.Sprintf("Print the spec to stdout in the specified format and exit. If --%s is specified, the spec follows the protocol.", ProtocolFlagName))
+	flagSet.BoolVar(&flags.printInfo, InfoFlagName, false, "Print the plugin info to stdout in the specified format and exit.")
+	flagSet.BoolVar(&flags.compress, CompressFlagName, false, fmt.Sprintf("Gzip the output of --%s, prefixed with a header byte.", SpecFlagName))
+	flagSet.BoolVar(&flags.descriptors, DescriptorsFlagName, false, fmt.Sprintf("Include the descriptors of the plugin in the output of --%s.", SpecFlagName))
+	flagSet.BoolVar(&flags.docs, DocsFlagName, false, fmt.Sprintf("Include the documentation of procedures in the output of --%s.", SpecFlagName))
+	flagSet.BoolVar(&flags.printVersion, VersionFlagName, false, fmt.Sprintf("Print the version of the plugin and the build metadata of pluginrpc-go to stdout and exit. If --%s is specified, include the version in the output of --%s instead.", SpecFlagName, SpecFlagName))
+	flagSet.StringVar(&formatString, FormatFlagName, defaultFormat.String(), fmt.Sprintf("The format to use for requests, responses, and specs. Must be one of [%s].", getFormatNamesString()))
+	flagSet.BoolVar(&flags.errorDetails, ErrorDetailsFlagName, false, "Include error details such as retry hints in error responses.")
+	flagSet.DurationVar(&flags.timeout, TimeoutFlagName, 0, "The maximum duration to allow the procedure to run. Zero means no timeout.")
+	flagSet.StringVar(&flags.nonce, NonceFlagName, "", "A unique value for the request, used to detect replays of requests to replay-protected procedures.")
+	flagSet.BoolVar(&flags.serve, ServeFlagName, false, "Serve calls multiplexed over stdin and stdout until stdin is closed.")
+	flagSet.StringVar(&timestampString, TimestampFlagName, "", "The time the request was created in RFC 3339 format, used with --nonce.")
+	flagSet.StringArrayVar(&metadataStrings, MetadataFlagName, nil, "Request metadata of the form key=value. May be specified multiple times.")
+	flagSet.BoolVar(&flags.responseMetadata, ResponseMetadataFlagName, false, "Include response metadata in responses.")
+	flagSet.BoolVar(&flags.warnings, WarningsFlagName, false, "Include warnings such as deprecations in responses.")
+	flagSet.StringVar(&compressionString, CompressionFlagName, "", fmt.Sprintf("Compress the response with the specified compression. Must be one of [%s].", getCompressionNamesString()))
+	flagSet.BoolVar(&flags.payload, PayloadFlagName, false, "Stream payloads after the request on stdin and before the response on stdout, as length-prefixed frames.")
+	flagSet.BoolVar(&flags.compressions, CompressionsFlagName, false, fmt.Sprintf("Include the compressions supported by the plugin in the output of --%s.", SpecFlagName))
+	flagSet.StringVar(&helpFormat, HelpFormatFlagName, helpFormatText, fmt.Sprintf("The format of --%s. Must be one of [%q, %q].", helpFlagName, helpFormatText, helpFormatJSON))
+	// We handle --help ourselves so that --help-format is parsed regardless of its position.
+	// The flag is hidden as it is documented separately in the usage.
+	flagSet.BoolVarP(&help, helpFlagName, "h", false, "Show this help.")
+	_ = flagSet.MarkHidden(helpFlagName)
 	if err := flagSet.Parse(args); err != nil {
 		return nil, nil, err
 	}
-	if flags.printProtocol && flags.printSpec {
-		return nil, nil, fmt.Errorf("cannot specify both --%s and --%s", ProtocolFlagName, SpecFlagName)
+	if help {
+		// If the args invoke a Procedure, the help is specific to that Procedure.
+		procedure := getProcedureForHelpArgs(spec, flagSet.Args())
+		switch helpFormat {
+		case helpFormatText:
+			if procedure == nil {
+				flagSet.Usage()
+				break
+			}
+			usage, err := getProcedureUsage(flagSet, spec, procedure)
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, err := fmt.Fprint(output, usage); err != nil {
+				return nil, nil, err
+			}
+		case helpFormatJSON:
+			var data []byte
+			var err error
+			if procedure == nil {
+				data, err = getFlagUsageJSON(flagSet, spec, doc)
+			} else {
+				data, err = getProcedureUsageJSON(flagSet, spec, procedure)
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, err := stdout.Write(data); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, fmt.Errorf("invalid value for --%s: %q", HelpFormatFlagName, helpFormat)
+		}
+		return nil, nil, pflag.ErrHelp
+	}
+	if flags.printInfo && (flags.printProtocol || flags.printSpec) {
+		return nil, nil, fmt.Errorf("cannot specify --%s with --%s or --%s", InfoFlagName, ProtocolFlagName, SpecFlagName)
+	}
+	if flags.serve && (flags.printProtocol || flags.printSpec || flags.printInfo) {
+		return nil, nil, fmt.Errorf("cannot specify --%s with --%s, --%s, or --%s", ServeFlagName, ProtocolFlagName, SpecFlagName, InfoFlagName)
+	}
+	if flags.printVersion && !flags.printSpec && (flags.printProtocol || flags.printInfo || flags.serve) {
+		return nil, nil, fmt.Errorf("--%s can only be specified alone or with --%s", VersionFlagName, SpecFlagName)
+	}
+	if flags.compress && !flags.printSpec {
+		return nil, nil, fmt.Errorf("--%s can only be specified with --%s", CompressFlagName, SpecFlagName)
+	}
+	if flags.descriptors && !flags.printSpec {
+		return nil, nil, fmt.Errorf("--%s can only be specified with --%s", DescriptorsFlagName, SpecFlagName)
+	}
+	if flags.docs && !flags.printSpec {
+		return nil, nil, fmt.Errorf("--%s can only be specified with --%s", DocsFlagName, SpecFlagName)
+	}
+	if flags.compressions && !flags.printSpec {
+		return nil, nil, fmt.Errorf("--%s can only be specified with --%s", CompressionsFlagName, SpecFlagName)
+	}
+	if compressionString != "" {
+		flags.compression = CompressionForString(compressionString)
+		if flags.compression == 0 {
+			return nil, nil, fmt.Errorf("invalid value for --%s: %q", CompressionFlagName, compressionString)
+		}
+	}
+	if flags.timeout < 0 {
+		return nil, nil, fmt.Errorf("invalid value for --%s: %v", TimeoutFlagName, flags.timeout)
+	}
+	if err := validateNonce(flags.nonce); err != nil {
+		return nil, nil, err
 	}
-	format := FormatBinary
+	if timestampString != "" {
+		timestamp, err := time.Parse(time.RFC3339Nano, timestampString)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value for --%s: %w", TimestampFlagName, err)
+		}
+		flags.timestamp = timestamp
+	}
+	for _, metadataString := range metadataStrings {
+		key, value, ok := strings.Cut(metadataString, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid value for --%s: %q is not of the form key=value", MetadataFlagName, metadataString)
+		}
+		if err := validateMetadataKey(key); err != nil {
+			return nil, nil, fmt.Errorf("invalid value for --%s: %w", MetadataFlagName, err)
+		}
+		if flags.metadata == nil {
+			flags.metadata = make(map[string]string)
+		}
+		flags.metadata[key] = value
+	}
+	format := defaultFormat
 	if formatString != "" {
 		format = FormatForString(formatString)
 		if format == 0 {
@@ -82,18 +319,34 @@ func getFlagUsage(flagSet *pflag.FlagSet, spec Spec, doc string) string {
 	_, _ = sb.WriteString("Commands:\n\n")
 	var argBasedProcedureStrings []string
 	var pathBasedProcedureStrings []string
+	// The summaries are the first lines of the docs of the Procedures.
+	procedureStringToSummary := make(map[string]string)
+	maxProcedureStringLength := 0
 	for _, procedure := range spec.Procedures() {
+		if procedure.Disabled() {
+			continue
+		}
+		var procedureString string
 		if args := procedure.Args(); len(args) > 0 {
-			argBasedProcedureStrings = append(argBasedProcedureStrings, strings.Join(args, " "))
+			procedureString = strings.Join(args, " ")
+			argBasedProcedureStrings = append(argBasedProcedureStrings, procedureString)
 		} else {
-			pathBasedProcedureStrings = append(pathBasedProcedureStrings, procedure.Path())
+			procedureString = procedure.Path()
+			pathBasedProcedureStrings = append(pathBasedProcedureStrings, procedureString)
 		}
+		summary, _, _ := strings.Cut(procedure.Doc(), "\n")
+		procedureStringToSummary[procedureString] = strings.TrimSpace(summary)
+		maxProcedureStringLength = max(maxProcedureStringLength, len(procedureString))
 	}
 	sort.Strings(argBasedProcedureStrings)
 	sort.Strings(pathBasedProcedureStrings)
 	for _, procedureString := range append(argBasedProcedureStrings, pathBasedProcedureStrings...) {
 		_, _ = sb.WriteString("  ")
 		_, _ = sb.WriteString(procedureString)
+		if summary := procedureStringToSummary[procedureString]; summary != "" {
+			_, _ = sb.WriteString(strings.Repeat(" ", maxProcedureStringLength-len(procedureString)+4))
+			_, _ = sb.WriteString(summary)
+		}
 		_, _ = sb.WriteString("\n")
 	}
 	_, _ = sb.WriteString("\nFlags:\n\n")
@@ -102,6 +355,220 @@ func getFlagUsage(flagSet *pflag.FlagSet, spec Spec, doc string) string {
 	return sb.String()
 }
 
+// jsonHelp is the help printed with --help-format json.
+type jsonHelp struct {
+	Doc        string              `json:"doc,omitempty"`
+	Procedures []jsonHelpProcedure `json:"procedures"`
+	Flags      []jsonHelpFlag      `json:"flags"`
+}
+
+type jsonHelpProcedure struct {
+	Path            string   `json:"path"`
+	Args            []string `json:"args,omitempty"`
+	ReplayProtected bool     `json:"replay_protected,omitempty"`
+	Serialized      bool     `json:"serialized,omitempty"`
+	Deprecation     string   `json:"deprecation,omitempty"`
+	RenamedFrom     []string `json:"renamed_from,omitempty"`
+	Doc             string   `json:"doc,omitempty"`
+}
+
+// jsonProcedureHelp is the help printed with --help-format json for a single Procedure.
+type jsonProcedureHelp struct {
+	Procedure      jsonHelpProcedure `json:"procedure"`
+	RequestType    string            `json:"request_type,omitempty"`
+	ResponseType   string            `json:"response_type,omitempty"`
+	ExampleRequest json.RawMessage   `json:"example_request,omitempty"`
+	Flags          []jsonHelpFlag    `json:"flags"`
+}
+
+type jsonHelpFlag struct {
+	Name      string `json:"name"`
+	Shorthand string `json:"shorthand,omitempty"`
+	Type      string `json:"type"`
+	Default   string `json:"default,omitempty"`
+	Usage     string `json:"usage"`
+}
+
+// renamedFromPaths returns the paths of the Procedures that the Procedure was renamed from.
+func renamedFromPaths(procedure Procedure) []string {
+	var paths []string
+	for _, renamedFrom := range procedure.RenamedFrom() {
+		paths = append(paths, renamedFrom.Path())
+	}
+	return paths
+}
+
+// getFlagUsageJSON returns the equivalent of getFlagUsage as JSON.
+//
+// Procedures are sorted by path, and flags by name.
+func getFlagUsageJSON(flagSet *pflag.FlagSet, spec Spec, doc string) ([]byte, error) {
+	help := jsonHelp{
+		Doc:        doc,
+		Procedures: []jsonHelpProcedure{},
+		Flags:      getJSONHelpFlags(flagSet),
+	}
+	for _, procedure := range spec.Procedures() {
+		if procedure.Disabled() {
+			continue
+		}
+		help.Procedures = append(help.Procedures, newJSONHelpProcedure(procedure))
+	}
+	sort.Slice(help.Procedures, func(i int, j int) bool { return help.Procedures[i].Path < help.Procedures[j].Path })
+	data, err := json.MarshalIndent(help, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// getProcedureForHelpArgs returns the Procedure invoked by the args given with --help.
+//
+// Returns nil if the args do not invoke an enabled Procedure.
+func getProcedureForHelpArgs(spec Spec, args []string) Procedure {
+	if len(args) == 0 {
+		return nil
+	}
+	for _, procedure := range spec.Procedures() {
+		if procedure.Disabled() {
+			continue
+		}
+		if _, ok := matchProcedureArgs(procedure, args); ok {
+			return procedure
+		}
+	}
+	return nil
+}
+
+// getProcedureUsage returns the help for a single Procedure.
+//
+// If the Spec has descriptors for the Procedure, the help includes the request and response
+// message names, and an example request in the JSON format.
+func getProcedureUsage(flagSet *pflag.FlagSet, spec Spec, procedure Procedure) (string, error) {
+	methodDescriptor, err := getMethodDescriptorForHelp(spec, procedure)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if doc := procedure.Doc(); doc != "" {
+		_, _ = sb.WriteString(doc)
+		_, _ = sb.WriteString("\n\n")
+	}
+	if deprecation := procedure.Deprecation(); deprecation != "" {
+		_, _ = sb.WriteString("Deprecated: ")
+		_, _ = sb.WriteString(deprecation)
+		_, _ = sb.WriteString("\n\n")
+	}
+	_, _ = sb.WriteString("Usage:\n\n  ")
+	if args := procedure.Args(); len(args) > 0 {
+		_, _ = sb.WriteString(strings.Join(args, " "))
+	} else {
+		_, _ = sb.WriteString(procedure.Path())
+	}
+	_, _ = sb.WriteString(" [flags]\n")
+	if methodDescriptor != nil {
+		_, _ = sb.WriteString("\nRequest:   ")
+		_, _ = sb.WriteString(string(methodDescriptor.Input().FullName()))
+		_, _ = sb.WriteString(getStreamingHelpSuffix(methodDescriptor.IsStreamingClient()))
+		_, _ = sb.WriteString("\nResponse:  ")
+		_, _ = sb.WriteString(string(methodDescriptor.Output().FullName()))
+		_, _ = sb.WriteString(getStreamingHelpSuffix(methodDescriptor.IsStreamingServer()))
+		_, _ = sb.WriteString("\n")
+		exampleRequest, err := newExampleRequestJSON(methodDescriptor.Input())
+		if err != nil {
+			return "", err
+		}
+		var buffer bytes.Buffer
+		if err := json.Indent(&buffer, exampleRequest, "  ", "  "); err != nil {
+			return "", err
+		}
+		_, _ = sb.WriteString(fmt.Sprintf("\nExample request (--%s json):\n\n  ", FormatFlagName))
+		_, _ = sb.WriteString(buffer.String())
+		_, _ = sb.WriteString("\n")
+	}
+	_, _ = sb.WriteString("\nFlags:\n\n")
+	_, _ = sb.WriteString(flagSet.FlagUsagesWrapped(flagWrapping))
+	_, _ = sb.WriteString("  -h, --help            Show this help.\n")
+	return sb.String(), nil
+}
+
+// getProcedureUsageJSON returns the equivalent of getProcedureUsage as JSON.
+func getProcedureUsageJSON(flagSet *pflag.FlagSet, spec Spec, procedure Procedure) ([]byte, error) {
+	methodDescriptor, err := getMethodDescriptorForHelp(spec, procedure)
+	if err != nil {
+		return nil, err
+	}
+	help := jsonProcedureHelp{
+		Procedure: newJSONHelpProcedure(procedure),
+		Flags:     getJSONHelpFlags(flagSet),
+	}
+	if methodDescriptor != nil {
+		help.RequestType = string(methodDescriptor.Input().FullName())
+		help.ResponseType = string(methodDescriptor.Output().FullName())
+		exampleRequest, err := newExampleRequestJSON(methodDescriptor.Input())
+		if err != nil {
+			return nil, err
+		}
+		help.ExampleRequest = exampleRequest
+	}
+	data, err := json.MarshalIndent(help, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func newJSONHelpProcedure(procedure Procedure) jsonHelpProcedure {
+	return jsonHelpProcedure{
+		Path:            procedure.Path(),
+		Args:            procedure.Args(),
+		ReplayProtected: procedure.ReplayProtected(),
+		Serialized:      procedure.Serialized(),
+		Deprecation:     procedure.Deprecation(),
+		RenamedFrom:     renamedFromPaths(procedure),
+		Doc:             procedure.Doc(),
+	}
+}
+
+func getJSONHelpFlags(flagSet *pflag.FlagSet) []jsonHelpFlag {
+	var jsonHelpFlags []jsonHelpFlag
+	flagSet.VisitAll(
+		func(flag *pflag.Flag) {
+			jsonHelpFlags = append(
+				jsonHelpFlags,
+				jsonHelpFlag{
+					Name:      flag.Name,
+					Shorthand: flag.Shorthand,
+					Type:      flag.Value.Type(),
+					Default:   flag.DefValue,
+					Usage:     flag.Usage,
+				},
+			)
+		},
+	)
+	return jsonHelpFlags
+}
+
+// getMethodDescriptorForHelp returns the MethodDescriptor for the Procedure.
+//
+// Returns nil if the Spec does not have descriptors for the Procedure, see SpecWithDescriptors.
+func getMethodDescriptorForHelp(spec Spec, procedure Procedure) (protoreflect.MethodDescriptor, error) {
+	files, err := newFilesForFileDescriptorSet(spec.FileDescriptorSet())
+	if err != nil {
+		return nil, err
+	}
+	if files == nil {
+		return nil, nil
+	}
+	return getMethodDescriptorForPath(files, procedure.Path()), nil
+}
+
+func getStreamingHelpSuffix(streaming bool) string {
+	if streaming {
+		return " (stream)"
+	}
+	return ""
+}
+
 func marshalProtocol(value int) []byte {
 	return []byte(strconv.Itoa(value) + "\n")
 }
@@ -116,6 +583,22 @@ func unmarshalProtocol(data []byte) (int, error) {
 }
 
 func marshalSpec(format Format, value any) ([]byte, error) {
+	return marshalMessage(format, value)
+}
+
+func unmarshalSpec(format Format, data []byte, value any) error {
+	return unmarshalMessage(format, data, value)
+}
+
+func marshalInfo(format Format, value any) ([]byte, error) {
+	return marshalMessage(format, value)
+}
+
+func unmarshalInfo(format Format, data []byte, value any) error {
+	return unmarshalMessage(format, data, value)
+}
+
+func marshalMessage(format Format, value any) ([]byte, error) {
 	protoValue, err := toProtoMessage(value)
 	if err != nil {
 		return nil, err
@@ -127,7 +610,7 @@ func marshalSpec(format Format, value any) ([]byte, error) {
 	return codec.Marshal(protoValue)
 }
 
-func unmarshalSpec(format Format, data []byte, value any) error {
+func unmarshalMessage(format Format, data []byte, value any) error {
 	if len(data) == 0 {
 		return nil
 	}
@@ -141,3 +624,49 @@ func unmarshalSpec(format Format, data []byte, value any) error {
 	}
 	return codec.Unmarshal(data, protoValue)
 }
+
+func getFormatNamesString() string {
+	names := globalCodecRegistry.names()
+	quotedNames := make([]string, len(names))
+	for i, name := range names {
+		quotedNames[i] = strconv.Quote(name)
+	}
+	return strings.Join(quotedNames, ", ")
+}
+
+func getCompressionNamesString() string {
+	names := compressionsToStrings(AllCompressions)
+	quotedNames := make([]string, len(names))
+	for i, name := range names {
+		quotedNames[i] = strconv.Quote(name)
+	}
+	return strings.Join(quotedNames, ", ")
+}
+
+func compressSpec(data []byte) ([]byte, error) {
+	buffer := bytes.NewBuffer([]byte{compressedSpecHeaderByte})
+	gzipWriter := gzip.NewWriter(buffer)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompressSpec decompresses the data if it is prefixed with the compressed spec header byte.
+//
+// If the data is not prefixed, it is returned as-is. The decompressed data must not be
+// larger than maxBytes, or than maxRatio times the size of the compressed data if maxRatio
+// is positive.
+func decompressSpec(data []byte, maxBytes int64, maxRatio int64) ([]byte, error) {
+	if len(data) == 0 || data[0] != compressedSpecHeaderByte {
+		return data, nil
+	}
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return nil, err
+	}
+	return readDecompressed(gzipReader, len(data), maxBytes, maxRatio)
+}
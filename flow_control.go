@@ -0,0 +1,138 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultFlowControlWindow is the default window of the plugin for the stdin of each call
+// within a session started with --serve, when the client enables flow control.
+//
+// See ServerWithFlowControlWindow.
+const defaultFlowControlWindow = 1 << 20
+
+// flowControlWindow is the number of bytes that may be sent for a call before the receiver
+// grants more.
+type flowControlWindow struct {
+	available int64
+	// closed is true if the window no longer applies, for example because the session ended.
+	closed bool
+	// waitDuration is the total time spent waiting for the window.
+	waitDuration time.Duration
+	lock         sync.Mutex
+	cond         *sync.Cond
+}
+
+func newFlowControlWindow(window uint32) *flowControlWindow {
+	flowControlWindow := &flowControlWindow{
+		available: int64(window),
+	}
+	flowControlWindow.cond = sync.NewCond(&flowControlWindow.lock)
+	return flowControlWindow
+}
+
+// acquire waits until the window is open, and then takes up to n bytes from the window.
+//
+// Returns the number of bytes that may be sent. If the window is closed, n is returned.
+func (f *flowControlWindow) acquire(ctx context.Context, n int) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.available <= 0 && !f.closed {
+		// Wake up the waiter if the context is done while waiting.
+		stop := context.AfterFunc(
+			ctx,
+			func() {
+				f.lock.Lock()
+				defer f.lock.Unlock()
+				f.cond.Broadcast()
+			},
+		)
+		defer stop()
+		start := time.Now()
+		for f.available <= 0 && !f.closed && ctx.Err() == nil {
+			f.cond.Wait()
+		}
+		f.waitDuration += time.Since(start)
+	}
+	if f.closed {
+		return n, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	n = int(min(int64(n), f.available))
+	f.available -= int64(n)
+	return n, nil
+}
+
+// release grants n more bytes to the window.
+func (f *flowControlWindow) release(n uint32) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.available += int64(n)
+	f.cond.Broadcast()
+}
+
+// close stops applying the window, waking up all waiters.
+func (f *flowControlWindow) close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = true
+	f.cond.Broadcast()
+}
+
+func (f *flowControlWindow) getWaitDuration() time.Duration {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.waitDuration
+}
+
+// windowUpdater batches the bytes consumed by a receiver into window updates for the sender.
+//
+// Updates are sent once half of the window has been consumed, so that the sender is not
+// blocked while a frame is sent for every read.
+type windowUpdater struct {
+	threshold uint32
+	pending   uint32
+	send      func(uint32)
+	lock      sync.Mutex
+}
+
+func newWindowUpdater(window uint32, send func(uint32)) *windowUpdater {
+	return &windowUpdater{
+		threshold: max(window/2, 1),
+		send:      send,
+	}
+}
+
+// consumed records that n bytes were consumed, sending an update if the threshold is reached.
+func (w *windowUpdater) consumed(n int) {
+	if n <= 0 {
+		return
+	}
+	w.lock.Lock()
+	w.pending += uint32(n)
+	if w.pending < w.threshold {
+		w.lock.Unlock()
+		return
+	}
+	pending := w.pending
+	w.pending = 0
+	w.lock.Unlock()
+	w.send(pending)
+}
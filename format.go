@@ -36,7 +36,9 @@ const (
 )
 
 var (
-	// AllFormats are all Formsts.
+	// AllFormats are all built-in Formats.
+	//
+	// This does not include Formats registered with RegisterFormat.
 	AllFormats = []Format{
 		FormatJSON,
 		FormatBinary,
@@ -45,27 +47,23 @@ var (
 
 // String implements fmt.Stringer.
 func (f Format) String() string {
-	switch f {
-	case FormatBinary:
-		return formatBinaryString
-	case FormatJSON:
-		return formatJSONString
+	if name, ok := globalCodecRegistry.name(f); ok {
+		return name
 	}
 	return fmt.Sprintf("format_%d", f)
 }
 
 // FormatForString returns the Format for the given string.
 //
+// This includes Formats registered with RegisterFormat.
+//
 // Returns 0 if the Format is unknown or s is empty.
 func FormatForString(s string) Format {
-	switch strings.ToLower(strings.TrimSpace(s)) {
-	case formatBinaryString:
-		return FormatBinary
-	case formatJSONString:
-		return FormatJSON
-	default:
+	format, ok := globalCodecRegistry.format(strings.ToLower(strings.TrimSpace(s)))
+	if !ok {
 		return 0
 	}
+	return format
 }
 
 // *** PRIVATE ***
@@ -78,5 +76,6 @@ func validateFormat(format Format) error {
 }
 
 func isValidFormat(format Format) bool {
-	return format >= minFormat && format <= maxFormat
+	_, ok := globalCodecRegistry.codec(format)
+	return ok
 }
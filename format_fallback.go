@@ -0,0 +1,134 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// *** PRIVATE ***
+
+// maxFormatFallbackStderrBytes is the maximum number of bytes of stderr of the plugin
+// that are kept to detect if the plugin does not support a Format.
+const maxFormatFallbackStderrBytes = 4096
+
+// formatFallback falls back to FormatJSON if the plugin does not support FormatBinary.
+//
+// See ClientWithFormatFallback. A nil *formatFallback never falls back.
+type formatFallback struct {
+	fellBack atomic.Bool
+}
+
+func newFormatFallback(enabled bool) *formatFallback {
+	if !enabled {
+		return nil
+	}
+	return &formatFallback{}
+}
+
+// format returns the Format to use for invocations of the plugin, given the Format
+// of the client.
+func (f *formatFallback) format(format Format) Format {
+	if f != nil && f.fellBack.Load() {
+		return FormatJSON
+	}
+	return format
+}
+
+// start starts an invocation of the plugin with the Format.
+//
+// The returned writer should be used as the stderr of the plugin. The returned function
+// should be called with the error of the invocation and the stdout of the plugin. If the
+// invocation failed because the plugin does not support the Format, FormatJSON is used
+// for all further invocations, and the error is wrapped so that isFormatFallbackError
+// returns true, in which case the invocation should be retried.
+func (f *formatFallback) start(format Format, stderr io.Writer) (io.Writer, func(err error, stdout []byte) error) {
+	if f == nil || format != FormatBinary {
+		return stderr, func(err error, _ []byte) error { return err }
+	}
+	stderrPrefix := &prefixWriter{limit: maxFormatFallbackStderrBytes}
+	return io.MultiWriter(stderr, stderrPrefix), func(err error, stdout []byte) error {
+		if err == nil || !isUnsupportedFormatError(err, stdout, stderrPrefix.bytes()) {
+			return err
+		}
+		f.fellBack.Store(true)
+		return &formatFallbackError{underlying: err}
+	}
+}
+
+// formatFallbackError wraps the error from an invocation of the plugin with a Format
+// that the plugin does not support.
+//
+// The message of the underlying error is unchanged.
+type formatFallbackError struct {
+	underlying error
+}
+
+func (e *formatFallbackError) Error() string {
+	return e.underlying.Error()
+}
+
+func (e *formatFallbackError) Unwrap() error {
+	return e.underlying
+}
+
+// isFormatFallbackError returns true if the invocation should be retried with FormatJSON.
+func isFormatFallbackError(err error) bool {
+	return errors.As(err, new(*formatFallbackError))
+}
+
+// isUnsupportedFormatError returns true if the error from an invocation with FormatBinary
+// indicates that the plugin does not support FormatBinary.
+//
+// This is the case if the plugin exited with an error that refers to --format on stderr,
+// for example because it does not know the flag or the value, or if the plugin ignored
+// --format and responded with JSON.
+func isUnsupportedFormatError(err error, stdout []byte, stderr []byte) bool {
+	if errors.As(err, new(*Error)) {
+		return false
+	}
+	if errors.As(err, new(*ExitError)) {
+		return bytes.Contains(stderr, []byte("--"+FormatFlagName))
+	}
+	stdout = bytes.TrimSpace(stdout)
+	return bytes.HasPrefix(stdout, []byte("{")) && json.Valid(stdout)
+}
+
+// prefixWriter keeps the first bytes written to it, up to a limit.
+type prefixWriter struct {
+	limit  int
+	buffer bytes.Buffer
+	lock   sync.Mutex
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if remaining := p.limit - p.buffer.Len(); remaining > 0 {
+		_, _ = p.buffer.Write(data[:min(len(data), remaining)])
+	}
+	return len(data), nil
+}
+
+func (p *prefixWriter) bytes() []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.buffer.Bytes()
+}
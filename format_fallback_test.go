@@ -0,0 +1,191 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestClientWithFormatFallback(t *testing.T) {
+	t.Parallel()
+
+	for _, ignoreFormat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ignore_format_%t", ignoreFormat), func(t *testing.T) {
+			t.Parallel()
+
+			server, err := newServer()
+			require.NoError(t, err)
+			runner := &jsonOnlyRunner{
+				runner:       pluginrpc.NewServerRunner(server),
+				ignoreFormat: ignoreFormat,
+			}
+
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner))
+			require.NoError(t, err)
+			_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+			require.Error(t, err)
+
+			runner.reset()
+			echoServiceClient, err = examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner, pluginrpc.ClientWithFormatFallback()))
+			require.NoError(t, err)
+			for i := 0; i < 2; i++ {
+				response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+				require.NoError(t, err)
+				require.Equal(t, "hello", response.GetMessage())
+			}
+			// Only the first invocation of the Spec is in the binary format.
+			require.Equal(
+				t,
+				[]pluginrpc.Format{pluginrpc.FormatBinary, pluginrpc.FormatJSON, pluginrpc.FormatJSON, pluginrpc.FormatJSON},
+				runner.getFormats(),
+			)
+		})
+	}
+}
+
+func TestClientWithFormatFallbackCall(t *testing.T) {
+	t.Parallel()
+
+	server, err := newServer()
+	require.NoError(t, err)
+	spec, err := examplev1pluginrpc.DefaultEchoServiceSpec()
+	require.NoError(t, err)
+	runner := &jsonOnlyRunner{runner: pluginrpc.NewServerRunner(server)}
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(
+		pluginrpc.NewClient(
+			runner,
+			pluginrpc.ClientWithSpec(spec),
+			pluginrpc.ClientWithFormatFallback(),
+		),
+	)
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoList(context.Background(), &examplev1.EchoListRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar"}, response.GetList())
+	require.Equal(t, []pluginrpc.Format{pluginrpc.FormatBinary, pluginrpc.FormatJSON}, runner.getFormats())
+
+	// Errors from the plugin do not result in a fallback.
+	_, err = echoServiceClient.EchoError(context.Background(), &examplev1.EchoErrorRequest{Message: "foo"})
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, []pluginrpc.Format{pluginrpc.FormatBinary, pluginrpc.FormatJSON, pluginrpc.FormatJSON}, runner.getFormats())
+}
+
+func TestClientWithFormatFallbackRequestPayload(t *testing.T) {
+	t.Parallel()
+
+	server, err := newServer()
+	require.NoError(t, err)
+	spec, err := examplev1pluginrpc.DefaultEchoServiceSpec()
+	require.NoError(t, err)
+	runner := &jsonOnlyRunner{runner: pluginrpc.NewServerRunner(server)}
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(
+		pluginrpc.NewClient(
+			runner,
+			pluginrpc.ClientWithSpec(spec),
+			pluginrpc.ClientWithFormatFallback(),
+		),
+	)
+	require.NoError(t, err)
+
+	// The request payload may have been read by the first invocation, so the call is not retried.
+	_, err = echoServiceClient.EchoRequest(
+		context.Background(),
+		&examplev1.EchoRequestRequest{Message: "hello"},
+		pluginrpc.CallWithRequestPayload(bytes.NewReader([]byte("payload"))),
+		pluginrpc.CallWithResponsePayload(bytes.NewBuffer(nil)),
+	)
+	exitError := &pluginrpc.ExitError{}
+	require.ErrorAs(t, err, &exitError)
+	require.Equal(t, []pluginrpc.Format{pluginrpc.FormatBinary}, runner.getFormats())
+
+	// Further calls use FormatJSON.
+	responsePayload := bytes.NewBuffer(nil)
+	response, err := echoServiceClient.EchoRequest(
+		context.Background(),
+		&examplev1.EchoRequestRequest{Message: "hello"},
+		pluginrpc.CallWithRequestPayload(bytes.NewReader([]byte("payload"))),
+		pluginrpc.CallWithResponsePayload(responsePayload),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.GetMessage())
+	require.Equal(t, "payload", responsePayload.String())
+	require.Equal(t, []pluginrpc.Format{pluginrpc.FormatBinary, pluginrpc.FormatJSON}, runner.getFormats())
+}
+
+// jsonOnlyRunner is a Runner for a plugin that only supports FormatJSON.
+//
+// If ignoreFormat is true, the plugin ignores --format and always uses FormatJSON, otherwise
+// the plugin rejects --format binary.
+type jsonOnlyRunner struct {
+	runner       pluginrpc.Runner
+	ignoreFormat bool
+
+	formats []pluginrpc.Format
+	lock    sync.Mutex
+}
+
+func (j *jsonOnlyRunner) Run(ctx context.Context, env pluginrpc.Env) error {
+	index := slices.Index(env.Args, "--"+pluginrpc.FormatFlagName)
+	if index < 0 || index+1 >= len(env.Args) {
+		return j.runner.Run(ctx, env)
+	}
+	format := pluginrpc.FormatForString(env.Args[index+1])
+	j.lock.Lock()
+	j.formats = append(j.formats, format)
+	j.lock.Unlock()
+	if format != pluginrpc.FormatBinary {
+		return j.runner.Run(ctx, env)
+	}
+	if !j.ignoreFormat {
+		_, _ = env.Stderr.Write([]byte("invalid value for --format: \"binary\"\n"))
+		return pluginrpc.NewExitError(1, errors.New("invalid value for --format"))
+	}
+	// The request is in the binary format, and cannot be read by the plugin.
+	stdin := bytes.NewBuffer(nil)
+	if env.Stdin != nil {
+		_, _ = stdin.ReadFrom(env.Stdin)
+	}
+	if stdin.Len() > 0 {
+		_, _ = env.Stderr.Write([]byte("invalid request\n"))
+		return pluginrpc.NewExitError(1, errors.New("invalid request"))
+	}
+	env.Args = slices.Clone(env.Args)
+	env.Args[index+1] = pluginrpc.FormatJSON.String()
+	return j.runner.Run(ctx, env)
+}
+
+func (j *jsonOnlyRunner) reset() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.formats = nil
+}
+
+func (j *jsonOnlyRunner) getFormats() []pluginrpc.Format {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return slices.Clone(j.formats)
+}
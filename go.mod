@@ -1,14 +1,16 @@
 module pluginrpc.com/pluginrpc
 
-go 1.21
+go 1.22
 
 toolchain go1.23.0
 
 require (
 	buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go v1.34.2-20240828222655-5345c0a56177.2
+	github.com/klauspost/compress v1.18.0
 	github.com/mattn/go-isatty v0.0.20
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.9.0
+	github.com/tetratelabs/wazero v1.8.2
 	google.golang.org/protobuf v1.34.2
 )
 
@@ -16,19 +16,27 @@ package pluginrpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/mattn/go-isatty"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
 )
 
 // Handler handles requests on the server side.
 //
 // This is used within generated code when registering an implementation of a service.
 //
-// Currently, Handlers do not have any customization, however this type is exposes
-// so that customization can be provided in the future.
+// Handlers are customized with HandlerOptions that apply to every call handled, such as
+// interceptors, response validation, tenant validation, and limits on the size of
+// requests, see NewHandler. HandleOptions customize a single call, and are typically set
+// by the Server, for example the Format and whether to include error details.
 type Handler interface {
 	Handle(
 		ctx context.Context,
@@ -37,18 +45,106 @@ type Handler interface {
 		handle func(context.Context, any) (any, error),
 		options ...HandleOption,
 	) error
+	// HandleServerStream handles a request to a server-streaming Procedure.
+	//
+	// The handle function calls send for every response. Each response is written
+	// to stdout as a separate frame as soon as it is sent.
+	HandleServerStream(
+		ctx context.Context,
+		handleEnv HandleEnv,
+		request any,
+		handle func(ctx context.Context, request any, send func(any) error) error,
+		options ...HandleOption,
+	) error
+	// HandleBidiStream handles a bidirectional streaming Procedure.
+	//
+	// Requests are read from stdin as a sequence of frames. The handle function calls
+	// receive for every request, which calls newRequest to create a value to populate,
+	// and returns io.EOF once the client has closed stdin. The handle function calls
+	// send for every response. Each response is written to stdout as a separate frame
+	// as soon as it is sent.
+	//
+	// Neither receive nor send may be called concurrently with themselves.
+	//
+	// HandleWithStdinMode and HandleWithStdinTimeout do not apply to bidirectional
+	// streams, and HandleWithMaxStdinBytes limits the size of each request.
+	HandleBidiStream(
+		ctx context.Context,
+		handleEnv HandleEnv,
+		newRequest func() any,
+		handle func(ctx context.Context, receive func() (any, error), send func(any) error) error,
+		options ...HandleOption,
+	) error
 
 	isHandler()
 }
 
-// NewHandler returns a new Handler.
-func NewHandler(spec Spec, _ ...HandlerOption) Handler {
-	return newHandler(spec)
+// NewHandler returns a new Handler for the Procedures of the Spec.
+func NewHandler(spec Spec, options ...HandlerOption) Handler {
+	return newHandler(spec, options...)
 }
 
 // HandlerOption is an option for a new Handler.
 type HandlerOption func(*handlerOptions)
 
+// HandlerWithInterceptors returns a new HandlerOption that wraps every request
+// handled by Handle with the given interceptors.
+//
+// The first interceptor is the outermost. This option can be specified multiple
+// times, in which case the interceptors are appended.
+func HandlerWithInterceptors(interceptors ...HandlerInterceptor) HandlerOption {
+	return func(handlerOptions *handlerOptions) {
+		handlerOptions.interceptors = append(handlerOptions.interceptors, interceptors...)
+	}
+}
+
+// HandlerWithResponseValidation returns a new HandlerOption that verifies that the type of
+// every response returned by a handle function matches the output type of the method for
+// the Procedure being handled, catching handlers that return the wrong message type.
+//
+// The method is resolved from the Procedure path, which must be of the form
+// "/package.Service/Method", using protoregistry.GlobalFiles. Responses for Procedures whose
+// method cannot be resolved are not validated. Responses that do not match are not sent,
+// and an error with CodeInternal is returned to the client instead.
+//
+// The default is to not validate responses.
+func HandlerWithResponseValidation() HandlerOption {
+	return func(handlerOptions *handlerOptions) {
+		handlerOptions.responseValidation = true
+	}
+}
+
+// HandlerWithMaxRequestSize returns a new HandlerOption that limits the size of requests
+// read from stdin to the given number of bytes, for every call handled.
+//
+// If a request exceeds the limit, an error with CodeResourceExhausted is returned to the
+// client. For bidirectional streams, the limit applies to each request. If
+// HandleWithMaxStdinBytes is also given for a call, the smaller limit applies.
+//
+// The default is no limit.
+func HandlerWithMaxRequestSize(maxRequestSize int64) HandlerOption {
+	return func(handlerOptions *handlerOptions) {
+		handlerOptions.maxRequestSize = maxRequestSize
+	}
+}
+
+// HandlerWithDecompressionLimits returns a new HandlerOption that limits the size that
+// compressed requests decompress to, to the given number of bytes, and to the given multiple
+// of the size of the compressed request, for every call handled.
+//
+// If a request exceeds a limit, an error with CodeResourceExhausted is returned to the
+// client. If HandleWithMaxStdinBytes or HandlerWithMaxRequestSize is also given, the
+// smaller limit on the number of bytes applies.
+//
+// The default is a maximum of 64 MiB, and no maximum ratio. A value that is not positive
+// results in the default being used.
+func HandlerWithDecompressionLimits(maxBytes int64, maxRatio int64) HandlerOption {
+	return func(handlerOptions *handlerOptions) {
+		handlerOptions.maxDecompressedBytes = maxBytes
+		handlerOptions.maxDecompressionRatio = maxRatio
+	}
+}
+
 // HandleOption is an option for handler.Handle.
 type HandleOption func(*handleOptions)
 
@@ -62,6 +158,122 @@ func HandleWithFormat(format Format) HandleOption {
 	}
 }
 
+// HandleWithErrorDetails returns a new HandleOption that says to include error details,
+// such as retry hints, in error responses.
+//
+// This should only be specified if the client specified the --error-details flag.
+//
+// The default is to not include error details.
+func HandleWithErrorDetails() HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.errorDetails = true
+	}
+}
+
+// HandleWithStdinMode returns a new HandleOption that says how to read requests from stdin.
+//
+// The default is StdinModeAuto.
+func HandleWithStdinMode(stdinMode StdinMode) HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.stdinMode = stdinMode
+	}
+}
+
+// HandleWithStdinTimeout returns a new HandleOption that bounds the time spent reading
+// requests from stdin.
+//
+// If no data arrives on stdin within the timeout, there is assumed to be no request data.
+// If some data arrives but stdin is not closed within the timeout, the request is
+// incomplete and an error with CodeInvalidArgument is returned.
+//
+// The default is no timeout.
+func HandleWithStdinTimeout(timeout time.Duration) HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.stdinTimeout = timeout
+	}
+}
+
+// HandleWithMaxStdinBytes returns a new HandleOption that limits the size of requests
+// read from stdin to the given number of bytes.
+//
+// If stdin exceeds the limit, an error with CodeResourceExhausted is returned
+// without buffering the remainder of stdin.
+//
+// The default is no limit.
+func HandleWithMaxStdinBytes(maxStdinBytes int64) HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.maxStdinBytes = maxStdinBytes
+	}
+}
+
+// HandleWithCompression returns a new HandleOption that says to compress responses with
+// the given Compression.
+//
+// This should only be specified if the client specified the --compression flag. This
+// applies to Handle, the responses of streaming Procedures are not compressed. Compressed
+// requests are decompressed regardless of this option.
+//
+// The default is to not compress responses.
+func HandleWithCompression(compression Compression) HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.compression = compression
+	}
+}
+
+// HandleWithPayloads returns a new HandleOption that says that stdin and stdout consist of
+// frames, so that payloads can be streamed after the request and before the response, see
+// RequestPayload and ResponsePayload.
+//
+// This should only be specified if the client specified the --payload flag. This applies
+// to Handle, and is ignored for streaming Procedures.
+//
+// The default is to read the request from all of stdin, and write the response as all of stdout.
+func HandleWithPayloads() HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.payloads = true
+	}
+}
+
+// handleWithProcedurePath returns a new HandleOption that specifies the path of the
+// Procedure being handled, for use by HandlerInterceptors.
+//
+// This is set by Servers.
+func handleWithProcedurePath(procedurePath string) HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.procedurePath = procedurePath
+	}
+}
+
+// handleWithResponseMetadata returns a new HandleOption that says to include response
+// metadata set with SetResponseMetadata in responses.
+//
+// This is set by Servers if the client specified the --response-metadata flag.
+func handleWithResponseMetadata() HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.responseMetadata = true
+	}
+}
+
+// handleWithWarnings returns a new HandleOption that says to include the given warnings
+// in responses.
+//
+// This is set by Servers if the client specified the --warnings flag.
+func handleWithWarnings(warnings []*extv1.Warning) HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.warnings = warnings
+	}
+}
+
+// handleWithRedactor returns a new HandleOption that says to redact the details of
+// errors with the given Redactor before they are sent.
+//
+// This is set by Servers, see ServerWithRedactor.
+func handleWithRedactor(redactor Redactor) HandleOption {
+	return func(handleOptions *handleOptions) {
+		handleOptions.redactor = redactor
+	}
+}
+
 // HandleEnv is the part of the environment that Handlers can have access to.
 type HandleEnv struct {
 	Stdin  io.Reader
@@ -72,12 +284,32 @@ type HandleEnv struct {
 // *** PRIVATE ***
 
 type handler struct {
-	spec Spec
+	spec               Spec
+	interceptors       []HandlerInterceptor
+	responseValidation bool
+	tenantValidators   []TenantValidator
+	maxRequestSize     int64
+	// maxDecompressedBytes and maxDecompressionRatio bound decompressed requests.
+	maxDecompressedBytes  int64
+	maxDecompressionRatio int64
 }
 
-func newHandler(spec Spec) *handler {
+func newHandler(spec Spec, options ...HandlerOption) *handler {
+	handlerOptions := newHandlerOptions()
+	for _, option := range options {
+		option(handlerOptions)
+	}
+	if handlerOptions.maxDecompressedBytes <= 0 {
+		handlerOptions.maxDecompressedBytes = defaultMaxDecompressedBytes
+	}
 	return &handler{
-		spec: spec,
+		spec:                  spec,
+		interceptors:          handlerOptions.interceptors,
+		responseValidation:    handlerOptions.responseValidation,
+		tenantValidators:      handlerOptions.tenantValidators,
+		maxRequestSize:        handlerOptions.maxRequestSize,
+		maxDecompressedBytes:  handlerOptions.maxDecompressedBytes,
+		maxDecompressionRatio: handlerOptions.maxDecompressionRatio,
 	}
 }
 
@@ -88,86 +320,353 @@ func (h *handler) Handle(
 	handle func(context.Context, any) (any, error),
 	options ...HandleOption,
 ) (retErr error) {
-	handleOptions := newHandleOptions()
-	for _, option := range options {
-		option(handleOptions)
-	}
+	handleOptions := h.newHandleOptions(options)
 	if err := validateFormat(handleOptions.format); err != nil {
 		return err
 	}
+	if err := validateStdinMode(handleOptions.stdinMode); err != nil {
+		return err
+	}
+	if handleOptions.compression != 0 {
+		if err := validateCompression(handleOptions.compression); err != nil {
+			return err
+		}
+	}
 
+	// The response metadata set by the handle function, if the client requested it.
+	var responseMetadata *responseMetadata
+	if handleOptions.responseMetadata {
+		responseMetadata = newResponseMetadata()
+		ctx = withResponseMetadata(ctx, responseMetadata)
+	}
+	// The payloads of the call, if the client specified --payload.
+	var callPayloads *callPayloads
+	if handleOptions.payloads {
+		callPayloads = newCallPayloads(handleEnv.Stdin, handleEnv.Stdout, handleOptions.maxRequestFrameSize())
+		ctx = withCallPayloads(ctx, callPayloads)
+	}
+	// Whether the request was prefixed with the binary header, in which case the
+	// client supports the binary header and we respond with it as well.
+	var binaryHeader bool
 	defer func() {
 		if retErr != nil {
-			retErr = h.writeError(handleOptions.format, handleEnv, retErr)
+			setServedCallHandleErr(ctx, retErr)
+			retErr = h.writeError(
+				handleOptions.format,
+				handleOptions.errorDetails,
+				responseMetadata.get(),
+				handleOptions.warnings,
+				binaryHeader,
+				handleOptions.compression,
+				callPayloads,
+				handleEnv,
+				redactErrorDetails(handleOptions.redactor, retErr),
+			)
 		}
 	}()
 
-	data, err := readStdin(handleEnv.Stdin)
+	binaryHeader, err := h.readRequest(ctx, handleEnv, handleOptions, callPayloads, request)
 	if err != nil {
 		return err
 	}
-	if err := unmarshalRequest(handleOptions.format, data, request); err != nil {
+	if err := validateTenant(ctx, handleOptions.procedurePath, h.tenantValidators); err != nil {
 		return err
 	}
-	response, err := handle(ctx, request)
+	handleFunc := chainHandlerInterceptors(
+		func(ctx context.Context, _ string, request any) (any, error) {
+			return handle(ctx, request)
+		},
+		h.interceptors,
+	)
+	response, err := handleFunc(ctx, handleOptions.procedurePath, request)
+	if err != nil {
+		// The protocol allows a non-nil response and non-nil error together, however we
+		// only send the response if the handler explicitly said it is a partial result.
+		if !HasPartialResult(err) || isNilProtoMessage(response) {
+			return err
+		}
+	}
+	if validateErr := h.validateResponse(handleOptions.procedurePath, response); validateErr != nil {
+		return validateErr
+	}
+	data, err := marshalResponseWithMetadata(
+		handleOptions.format,
+		response,
+		redactErrorDetails(handleOptions.redactor, err),
+		handleOptions.errorDetails,
+		responseMetadata.get(),
+		handleOptions.warnings,
+	)
 	if err != nil {
-		// TODO: This results in writeError being called, but ignores marshaling
-		// the response, so we will never have a non-nil response and non-nil
-		// error together, which the protocol says we can have.
-		//
-		// This just needs some refactoring.
 		return err
 	}
-	data, err = marshalResponse(handleOptions.format, response, nil)
+	data, err = newResponseEnvelope(data, binaryHeader, handleOptions.compression)
 	if err != nil {
 		return err
 	}
-	if _, err = handleEnv.Stdout.Write(data); err != nil {
+	if err = callPayloads.writeResponse(handleEnv.Stdout, data); err != nil {
 		return fmt.Errorf("failed to write response to stdout: %w", err)
 	}
 	return err
 }
 
-func (h *handler) writeError(format Format, handleEnv HandleEnv, inputErr error) error {
+func (h *handler) HandleServerStream(
+	ctx context.Context,
+	handleEnv HandleEnv,
+	request any,
+	handle func(context.Context, any, func(any) error) error,
+	options ...HandleOption,
+) (retErr error) {
+	handleOptions := h.newHandleOptions(options)
+	if err := validateFormat(handleOptions.format); err != nil {
+		return err
+	}
+	if err := validateStdinMode(handleOptions.stdinMode); err != nil {
+		return err
+	}
+
+	// Warnings are included in the first frame.
+	streamWarnings := newStreamWarnings(handleOptions.warnings)
+	defer func() {
+		if retErr != nil {
+			setServedCallHandleErr(ctx, retErr)
+			retErr = h.writeErrorFrame(handleOptions.format, handleOptions.errorDetails, streamWarnings.take(), handleEnv, redactErrorDetails(handleOptions.redactor, retErr))
+		}
+	}()
+
+	// The binary header is not used for frames.
+	if _, err := h.readRequest(ctx, handleEnv, handleOptions, nil, request); err != nil {
+		return err
+	}
+	if err := validateTenant(ctx, handleOptions.procedurePath, h.tenantValidators); err != nil {
+		return err
+	}
+	return handle(ctx, request, h.newSend(handleOptions, streamWarnings, handleEnv))
+}
+
+func (h *handler) HandleBidiStream(
+	ctx context.Context,
+	handleEnv HandleEnv,
+	newRequest func() any,
+	handle func(context.Context, func() (any, error), func(any) error) error,
+	options ...HandleOption,
+) (retErr error) {
+	handleOptions := h.newHandleOptions(options)
+	if err := validateFormat(handleOptions.format); err != nil {
+		return err
+	}
+
+	// Warnings are included in the first frame.
+	streamWarnings := newStreamWarnings(handleOptions.warnings)
+	defer func() {
+		if retErr != nil {
+			setServedCallHandleErr(ctx, retErr)
+			retErr = h.writeErrorFrame(handleOptions.format, handleOptions.errorDetails, streamWarnings.take(), handleEnv, redactErrorDetails(handleOptions.redactor, retErr))
+		}
+	}()
+
+	if err := validateTenant(ctx, handleOptions.procedurePath, h.tenantValidators); err != nil {
+		return err
+	}
+	frameReader := newFrameReader(handleEnv.Stdin, handleOptions.maxRequestFrameSize())
+	defer frameReader.close()
+	return handle(
+		ctx,
+		func() (any, error) {
+			data, err := frameReader.next(ctx)
+			if err != nil {
+				return nil, err
+			}
+			request := newRequest()
+			if err := unmarshalRequest(handleOptions.format, data, request); err != nil {
+				return nil, err
+			}
+			return request, nil
+		},
+		h.newSend(handleOptions, streamWarnings, handleEnv),
+	)
+}
+
+// newHandleOptions returns the handleOptions for a call, limiting the size of requests to
+// the maximum request size of the handler.
+func (h *handler) newHandleOptions(options []HandleOption) *handleOptions {
+	handleOptions := newHandleOptions()
+	for _, option := range options {
+		option(handleOptions)
+	}
+	if h.maxRequestSize > 0 && (handleOptions.maxStdinBytes <= 0 || h.maxRequestSize < handleOptions.maxStdinBytes) {
+		handleOptions.maxStdinBytes = h.maxRequestSize
+	}
+	return handleOptions
+}
+
+// readRequest reads the request from stdin, decompressing it if compressed.
+//
+// If callPayloads is non-nil, the request is read from the first frame of stdin, and the
+// rest of stdin is the request payload.
+//
+// Returns true if the request was prefixed with the binary header, in which case the
+// client supports the binary header. If request is nil, the request is read but not
+// unmarshaled, see writeErrorResponse.
+func (h *handler) readRequest(
+	ctx context.Context,
+	handleEnv HandleEnv,
+	handleOptions *handleOptions,
+	callPayloads *callPayloads,
+	request any,
+) (bool, error) {
+	var data []byte
+	var err error
+	if callPayloads != nil {
+		data, err = readFrame(handleEnv.Stdin, handleOptions.maxRequestFrameSize())
+		if errors.Is(err, io.EOF) {
+			err = NewErrorf(CodeInvalidArgument, "stdin ended before the request")
+		}
+	} else {
+		data, err = readStdin(
+			ctx,
+			handleEnv.Stdin,
+			stdinReadOptions{
+				mode:     handleOptions.stdinMode,
+				timeout:  handleOptions.stdinTimeout,
+				maxBytes: handleOptions.maxStdinBytes,
+			},
+		)
+	}
+	if err != nil {
+		return false, err
+	}
+	maxDecompressedBytes := h.maxDecompressedBytes
+	if handleOptions.maxStdinBytes > 0 && handleOptions.maxStdinBytes < maxDecompressedBytes {
+		maxDecompressedBytes = handleOptions.maxStdinBytes
+	}
+	data, _, err = decompressEnvelope(data, maxDecompressedBytes, h.maxDecompressionRatio)
+	if err != nil {
+		// Requests that exceed the limits are not invalid, see readDecompressed.
+		pluginrpcError := &Error{}
+		if errors.As(err, &pluginrpcError) {
+			return false, NewErrorf(pluginrpcError.Code(), "stdin is not a properly-compressed pluginrpc request: %w", pluginrpcError.Unwrap())
+		}
+		return false, NewErrorf(CodeInvalidArgument, "stdin is not a properly-compressed pluginrpc request: %w", err)
+	}
+	var binaryHeader bool
+	if handleOptions.format == FormatBinary {
+		data, binaryHeader, err = stripBinaryHeader(data)
+		if err != nil {
+			return false, NewError(CodeInvalidArgument, err)
+		}
+	}
+	if request == nil {
+		return binaryHeader, nil
+	}
+	if err := unmarshalRequest(handleOptions.format, data, request); err != nil {
+		return false, err
+	}
+	return binaryHeader, nil
+}
+
+func (h *handler) writeError(
+	format Format,
+	errorDetails bool,
+	responseMetadata map[string]string,
+	warnings []*extv1.Warning,
+	binaryHeader bool,
+	compression Compression,
+	callPayloads *callPayloads,
+	handleEnv HandleEnv,
+	inputErr error,
+) error {
 	if inputErr == nil {
 		return nil
 	}
-	// TODO: Format doesn't matter here, as we don't marshal any response.
-	// However, if we fix the above and do marshal responses with errors, it will matter.
-	data, err := marshalResponse(format, nil, inputErr)
+	data, err := marshalResponseWithMetadata(format, nil, inputErr, errorDetails, responseMetadata, warnings)
+	if err != nil {
+		return err
+	}
+	data, err = newResponseEnvelope(data, binaryHeader, compression)
 	if err != nil {
 		return err
 	}
-	if _, err := handleEnv.Stdout.Write(data); err != nil {
+	if err := callPayloads.writeResponse(handleEnv.Stdout, data); err != nil {
 		return fmt.Errorf("failed to write error to stdout: %w", err)
 	}
 	return nil
 }
 
-func (*handler) isHandler() {}
-
-// readStdin handles stdin specially to determine if stdin is a *os.File (likely os.Stdin)
-// and is itself a terminal. If so, we don't block on io.ReadAll, as we know that there
-// is no data in stdin and we can return.
-//
-// This allows server-side implementations of services to not require i.e.:
-//
-//	echo '{}' | plugin-server /pkg.Service/Method
+// newResponseEnvelope returns the response to write to stdout for the marshaled response.
 //
-// Instead allowing to just invoke the following if there is no request data:
-//
-//	plugin-server /pkg.Service/Method
-func readStdin(stdin io.Reader) ([]byte, error) {
-	file, ok := stdin.(*os.File)
-	if ok {
-		if isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd()) {
-			// Nothing on stdin
-			return nil, nil
+// If the client asked for the Compression, the response is compressed. Otherwise, if the
+// client sent the binary header, the response is prefixed with the binary header.
+func newResponseEnvelope(data []byte, binaryHeader bool, compression Compression) ([]byte, error) {
+	if compression != 0 && len(data) > 0 {
+		return compressEnvelope(compression, data)
+	}
+	if binaryHeader {
+		return addBinaryHeader(data), nil
+	}
+	return data, nil
+}
+
+// newSend returns a function that writes each response to stdout as a separate frame.
+func (h *handler) newSend(handleOptions *handleOptions, streamWarnings *streamWarnings, handleEnv HandleEnv) func(any) error {
+	return func(response any) error {
+		if isNilProtoMessage(response) {
+			return errors.New("cannot send a nil response")
+		}
+		if err := h.validateResponse(handleOptions.procedurePath, response); err != nil {
+			return err
+		}
+		data, err := marshalResponseWithMetadata(handleOptions.format, response, nil, false, nil, streamWarnings.take())
+		if err != nil {
+			return err
 		}
+		if err := writeFrame(handleEnv.Stdout, data); err != nil {
+			return fmt.Errorf("failed to write response to stdout: %w", err)
+		}
+		return nil
+	}
+}
+
+func (h *handler) writeErrorFrame(
+	format Format,
+	errorDetails bool,
+	warnings []*extv1.Warning,
+	handleEnv HandleEnv,
+	inputErr error,
+) error {
+	data, err := marshalResponseWithMetadata(format, nil, inputErr, errorDetails, nil, warnings)
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(handleEnv.Stdout, data); err != nil {
+		return fmt.Errorf("failed to write error to stdout: %w", err)
+	}
+	return nil
+}
+
+// validateResponse validates that the type of the response matches the output type
+// of the method for the Procedure, if response validation is enabled.
+func (h *handler) validateResponse(procedurePath string, response any) error {
+	if !h.responseValidation || procedurePath == "" || isNilProtoMessage(response) {
+		return nil
+	}
+	methodDescriptor := methodDescriptorForProcedurePath(procedurePath)
+	if methodDescriptor == nil {
+		return nil
 	}
-	return io.ReadAll(stdin)
+	expectedFullName := methodDescriptor.Output().FullName()
+	protoResponse, ok := response.(proto.Message)
+	if !ok {
+		return NewErrorf(CodeInternal, "procedure %q returned a response of type %T, expected %q", procedurePath, response, expectedFullName)
+	}
+	if fullName := protoResponse.ProtoReflect().Descriptor().FullName(); fullName != expectedFullName {
+		return NewErrorf(CodeInternal, "procedure %q returned a response of type %q, expected %q", procedurePath, fullName, expectedFullName)
+	}
+	return nil
 }
 
+func (*handler) isHandler() {}
+
 func handleEnvForEnv(env Env) HandleEnv {
 	return HandleEnv{
 		Stdin:  env.Stdin,
@@ -176,14 +675,88 @@ func handleEnvForEnv(env Env) HandleEnv {
 	}
 }
 
-type handlerOptions struct{}
+type handlerOptions struct {
+	interceptors          []HandlerInterceptor
+	responseValidation    bool
+	tenantValidators      []TenantValidator
+	maxRequestSize        int64
+	maxDecompressedBytes  int64
+	maxDecompressionRatio int64
+}
+
+func newHandlerOptions() *handlerOptions {
+	return &handlerOptions{}
+}
 
 type handleOptions struct {
-	format Format
+	format        Format
+	errorDetails  bool
+	stdinMode     StdinMode
+	stdinTimeout  time.Duration
+	maxStdinBytes int64
+	compression   Compression
+	payloads      bool
+	// procedurePath is the path of the Procedure being handled, if invoked by a Server.
+	procedurePath    string
+	responseMetadata bool
+	warnings         []*extv1.Warning
+	// redactor redacts the details of errors, if invoked by a Server.
+	redactor Redactor
+}
+
+// maxRequestFrameSize returns the maximum size of frames read from stdin, limited by the
+// maximum size of stdin.
+func (h *handleOptions) maxRequestFrameSize() uint32 {
+	if h.maxStdinBytes > 0 && h.maxStdinBytes < maxFrameSize {
+		return uint32(h.maxStdinBytes)
+	}
+	return maxFrameSize
+}
+
+// methodDescriptorForProcedurePath resolves the method for a Procedure path of the form
+// "/package.Service/Method" using protoregistry.GlobalFiles.
+//
+// Returns nil if the method cannot be resolved.
+func methodDescriptorForProcedurePath(procedurePath string) protoreflect.MethodDescriptor {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(procedurePath, "/"), "/")
+	if !ok {
+		return nil
+	}
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	return serviceDescriptor.Methods().ByName(protoreflect.Name(methodName))
+}
+
+// streamWarnings holds the warnings to include in the first frame of a stream.
+type streamWarnings struct {
+	lock     sync.Mutex
+	warnings []*extv1.Warning
+}
+
+func newStreamWarnings(warnings []*extv1.Warning) *streamWarnings {
+	return &streamWarnings{
+		warnings: warnings,
+	}
+}
+
+// take returns the warnings on the first call, and nil on all further calls.
+func (s *streamWarnings) take() []*extv1.Warning {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	warnings := s.warnings
+	s.warnings = nil
+	return warnings
 }
 
 func newHandleOptions() *handleOptions {
 	return &handleOptions{
-		format: FormatBinary,
+		format:    FormatBinary,
+		stdinMode: StdinModeAuto,
 	}
 }
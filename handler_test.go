@@ -0,0 +1,73 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestHandlerWithResponseValidation(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(t *testing.T, options ...pluginrpc.HandlerOption) pluginrpc.Client {
+		procedure, err := pluginrpc.NewProcedure(examplev1pluginrpc.EchoServiceEchoRequestPath)
+		require.NoError(t, err)
+		spec, err := pluginrpc.NewSpec(procedure)
+		require.NoError(t, err)
+		handler := pluginrpc.NewHandler(spec, options...)
+		serverRegistrar := pluginrpc.NewServerRegistrar()
+		serverRegistrar.Register(
+			examplev1pluginrpc.EchoServiceEchoRequestPath,
+			func(ctx context.Context, handleEnv pluginrpc.HandleEnv, options ...pluginrpc.HandleOption) error {
+				return handler.Handle(
+					ctx,
+					handleEnv,
+					&examplev1.EchoRequestRequest{},
+					func(context.Context, any) (any, error) {
+						// Wired to the wrong response type.
+						return &examplev1.EchoListResponse{List: []string{"foo"}}, nil
+					},
+					options...,
+				)
+			},
+		)
+		server, err := pluginrpc.NewServer(spec, serverRegistrar)
+		require.NoError(t, err)
+		return pluginrpc.NewClient(pluginrpc.NewServerRunner(server))
+	}
+
+	response := &examplev1.EchoRequestResponse{}
+	err := newClient(t).Call(context.Background(), examplev1pluginrpc.EchoServiceEchoRequestPath, nil, response)
+	require.Error(t, err)
+	pluginrpcError := &pluginrpc.Error{}
+	require.False(t, errors.As(err, &pluginrpcError))
+
+	err = newClient(t, pluginrpc.HandlerWithResponseValidation()).Call(
+		context.Background(),
+		examplev1pluginrpc.EchoServiceEchoRequestPath,
+		nil,
+		response,
+	)
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeInternal, pluginrpcError.Code())
+	require.Contains(t, pluginrpcError.Error(), "pluginrpc.example.v1.EchoListResponse")
+}
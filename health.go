@@ -0,0 +1,72 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+// HealthCheckPath is the path of the health check Procedure that every Server serves.
+//
+// The health check Procedure is invoked with its path as the only arg, and responds with
+// an empty response if the plugin is able to serve calls, see Client.Ping. It is not
+// included in the Spec of plugins. If the Spec of a plugin has a Procedure with this
+// path, that Procedure is served instead.
+const HealthCheckPath = "/pluginrpc.ext.v1.HealthService/Check"
+
+// *** PRIVATE ***
+
+// writeHealthCheckResponse writes the response of the health check Procedure.
+func writeHealthCheckResponse(format Format, env Env) error {
+	data, err := marshalResponse(format, &extv1.HealthCheckResponse{}, nil, false)
+	if err != nil {
+		return err
+	}
+	_, err = env.Stdout.Write(data)
+	return err
+}
+
+func (c *client) Ping(ctx context.Context) (retErr error) {
+	if err := c.checkProtocolVersion(ctx); err != nil {
+		return err
+	}
+	format := c.formatFallback.format(c.format)
+	args := []string{HealthCheckPath, "--" + FormatFlagName, format.String()}
+	stdout := bytes.NewBuffer(nil)
+	loggedCall := c.callLogger.start(ctx, HealthCheckPath, args)
+	auditInvocation, env := c.auditLog.start(
+		HealthCheckPath,
+		Env{
+			Args:   args,
+			Stdout: stdout,
+			Stderr: c.stderr,
+		},
+	)
+	defer func() {
+		loggedCall.finish(retErr)
+		retErr = auditInvocation.finish(retErr)
+	}()
+	if err := c.runner.Run(ctx, env); err != nil {
+		return wrapRunError(ctx, err)
+	}
+	if stdout.Len() == 0 {
+		return withErrorSource(errors.New("health check did not return a response"), ErrorSourceDecode)
+	}
+	return withResponseErrorSource(c.unmarshalStdoutResponse(ctx, format, stdout.Bytes(), &extv1.HealthCheckResponse{}, nil, nil))
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// HostServicesEnvVarName is the name of the environment variable that tells a plugin
+// which file descriptors to call host services over, see ClientWithHostServices.
+//
+// This is set by Runners created with NewExecRunner, and should not be set otherwise.
+const HostServicesEnvVarName = "PLUGINRPC_HOST_SERVICES"
+
+// HostServicesClient returns a new Client that calls the host services of the host, if
+// the host provided host services for the call, see ClientWithHostServices.
+//
+// This allows handlers to ask the host for files, config, or secrets in the middle of
+// a call. Host services are served by the host with a Server, so procedures of host
+// services are called with the generated clients like procedures of plugins:
+//
+//	client, ok := pluginrpc.HostServicesClient(ctx)
+//	if !ok {
+//		return nil, pluginrpc.NewErrorf(pluginrpc.CodeFailedPrecondition, "host services required")
+//	}
+//	secretServiceClient, err := secretv1pluginrpc.NewSecretServiceClient(client)
+//
+// Host services can only be called while the call of the host is in progress. Each
+// returned Client gets the Spec of the host services when first used, so reuse the
+// Client within a call.
+func HostServicesClient(ctx context.Context, options ...ClientOption) (Client, bool) {
+	runner, ok := ctx.Value(hostServicesRunnerContextKey{}).(Runner)
+	if !ok || runner == nil {
+		return nil, false
+	}
+	return NewClient(runner, options...), true
+}
+
+// ClientWithHostServices returns a new ClientOption that provides the given host services
+// to the plugin for each call, which the plugin can call with HostServicesClient.
+//
+// Host services are served with a Server, for example created with the generated
+// NewFooServiceServerForHandler function, and the plugin calls them as if the host was a
+// plugin. Calls of host services are multiplexed over an extra pair of pipes, as with
+// --serve, so the plugin can make any number of concurrent calls of host services.
+//
+// Host services are supported by Runners created with NewExecRunner on unix-like
+// platforms, where calls fail otherwise, and by Runners created with NewServerRunner.
+// Other Runners ignore host services, including ServeRunners.
+//
+// The default is to not provide host services.
+func ClientWithHostServices(hostServices Server) ClientOption {
+	return func(clientOptions *clientOptions) {
+		clientOptions.hostServices = hostServices
+	}
+}
+
+// CallWithHostServices returns a new CallOption that provides the given host services to
+// the plugin for the call, instead of the host services given with ClientWithHostServices.
+//
+// See ClientWithHostServices.
+func CallWithHostServices(hostServices Server) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.hostServices = hostServices
+	}
+}
+
+// *** PRIVATE ***
+
+type hostServicesContextKey struct{}
+
+type hostServicesRunnerContextKey struct{}
+
+// getProcessHostServicesRunner returns the Runner for the host services that the host
+// of the current process provided with HostServicesEnvVarName, if any.
+var getProcessHostServicesRunner = sync.OnceValues(
+	func() (Runner, error) {
+		value, ok := os.LookupEnv(HostServicesEnvVarName)
+		if !ok || value == "" {
+			return nil, nil
+		}
+		// Child processes of the plugin must not call the host services of the plugin.
+		if err := os.Unsetenv(HostServicesEnvVarName); err != nil {
+			return nil, err
+		}
+		readFd, writeFd, err := parseHostServicesFds(value)
+		if err != nil {
+			return nil, err
+		}
+		setCloseOnExec(readFd)
+		setCloseOnExec(writeFd)
+		return newHostServicesRunner(
+			os.NewFile(readFd, "host-services-read"),
+			os.NewFile(writeFd, "host-services-write"),
+		), nil
+	},
+)
+
+// withHostServices returns a context that provides the host services to the plugin
+// when the plugin is run, see ClientWithHostServices.
+func withHostServices(ctx context.Context, hostServices Server) context.Context {
+	if hostServices == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, hostServicesContextKey{}, hostServices)
+}
+
+// hostServicesFromContext returns the host services to provide to the plugin, or nil.
+func hostServicesFromContext(ctx context.Context) Server {
+	hostServices, _ := ctx.Value(hostServicesContextKey{}).(Server)
+	return hostServices
+}
+
+// withHostServicesRunner returns a context that handlers can call the host services
+// with, see HostServicesClient. The runner may be nil if there are no host services.
+//
+// The host services provided by the host are removed from the context, so that plugins
+// called by handlers do not get them.
+func withHostServicesRunner(ctx context.Context, runner Runner) context.Context {
+	ctx = context.WithValue(ctx, hostServicesContextKey{}, nil)
+	return context.WithValue(ctx, hostServicesRunnerContextKey{}, runner)
+}
+
+// withProcessHostServicesRunner returns a context with the host services that the host of
+// the current process provided, unless the context already has host services, as given
+// by Runners created with NewServerRunner.
+func withProcessHostServicesRunner(ctx context.Context) (context.Context, error) {
+	if runner, _ := ctx.Value(hostServicesRunnerContextKey{}).(Runner); runner != nil {
+		return ctx, nil
+	}
+	runner, err := getProcessHostServicesRunner()
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", HostServicesEnvVarName, err)
+	}
+	if runner == nil {
+		return ctx, nil
+	}
+	return withHostServicesRunner(ctx, runner), nil
+}
+
+// startHostServices serves the host services to the command over an extra pair of pipes,
+// and sets HostServicesEnvVarName for the command.
+//
+// This must be called before the command is started. The returned function must be called
+// once the command has exited.
+func startHostServices(ctx context.Context, cmd *exec.Cmd, hostServices Server) (func(), error) {
+	if !hostServicesSupported {
+		return nil, fmt.Errorf("host services are not supported on %s", runtime.GOOS)
+	}
+	// The plugin writes requests to requestWriter, and reads responses from responseReader.
+	requestReader, requestWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	responseReader, responseWriter, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Join(err, requestReader.Close(), requestWriter.Close())
+	}
+	// Files in ExtraFiles are file descriptors 3 and up in the command.
+	readFd := 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, responseReader, requestWriter)
+	cmd.Env = append(cmd.Env, HostServicesEnvVarName+"="+strconv.Itoa(readFd)+","+strconv.Itoa(readFd+1))
+	ctx, cancel := context.WithCancel(ctx)
+	doneC := make(chan struct{})
+	go func() {
+		defer close(doneC)
+		// Errors are not actionable, the plugin reports failed calls of host services.
+		_ = hostServices.Serve(
+			ctx,
+			Env{
+				Args:   []string{"--" + ServeFlagName},
+				Stdin:  requestReader,
+				Stdout: responseWriter,
+				Stderr: io.Discard,
+			},
+		)
+	}()
+	return func() {
+		// Host services can only be called while the plugin is running.
+		_ = responseReader.Close()
+		_ = requestWriter.Close()
+		cancel()
+		<-doneC
+		_ = requestReader.Close()
+		_ = responseWriter.Close()
+	}, nil
+}
+
+// parseHostServicesFds parses the value of HostServicesEnvVarName, which is the file
+// descriptor to read from, and the file descriptor to write to, separated by a comma.
+func parseHostServicesFds(value string) (uintptr, uintptr, error) {
+	readFdString, writeFdString, ok := strings.Cut(value, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected two file descriptors separated by a comma: %q", value)
+	}
+	readFd, err := strconv.ParseUint(readFdString, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	writeFd, err := strconv.ParseUint(writeFdString, 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uintptr(readFd), uintptr(writeFd), nil
+}
+
+// hostServicesRunner is a Runner that calls the host services of the host within the
+// session that the host serves them in.
+type hostServicesRunner struct {
+	session *execServeSession
+}
+
+func newHostServicesRunner(reader io.ReadCloser, writer io.WriteCloser) *hostServicesRunner {
+	return &hostServicesRunner{
+		session: startExecServeSession(nil, writer, reader, 0),
+	}
+}
+
+func (h *hostServicesRunner) Run(ctx context.Context, env Env) error {
+	env = env.withDefaults()
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	return h.session.run(ctx, env)
+}
@@ -12,19 +12,16 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build unix || js || wasip1
+//go:build unix
 
 package pluginrpc
 
-import (
-	"os"
-	"syscall"
-)
+import "syscall"
 
-// extraInterruptSignals are signals beyond os.Interrupt that we want to be handled
-// as interrupts.
-//
-// For unix-like platforms, this adds syscall.SIGTERM.
-var extraInterruptSignals = []os.Signal{
-	syscall.SIGTERM,
+// hostServicesSupported is true if host services can be provided to commands, see
+// ClientWithHostServices.
+const hostServicesSupported = true
+
+func setCloseOnExec(fd uintptr) {
+	syscall.CloseOnExec(int(fd))
 }
@@ -0,0 +1,306 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+// Info is information about a plugin, such as its license and third-party notices.
+//
+// Info is returned on stdout when `--info` is called.
+type Info interface {
+	// License returns the license of the plugin.
+	//
+	// If no license was specified, this returns nil.
+	License() *License
+	// Notices returns the notices for third-party components included within the plugin.
+	Notices() []Notice
+	// Platform returns the platform the plugin was built for.
+	//
+	// Servers report the platform they are running on by default. If the plugin did not
+	// report a platform, this returns nil.
+	Platform() *Platform
+
+	isInfo()
+}
+
+// License is a license of a plugin or a third-party component.
+type License struct {
+	// SPDXID is the SPDX license identifier, for example "Apache-2.0".
+	SPDXID string
+	// URL is the URL of the full license text.
+	URL string
+}
+
+// Notice is a notice for a third-party component included within a plugin.
+type Notice struct {
+	// Name is the name of the component.
+	//
+	// Required.
+	Name string
+	// License is the license of the component.
+	//
+	// Optional.
+	License *License
+	// Text is the attribution text for the component.
+	Text string
+}
+
+// Platform is the platform a plugin was built for.
+type Platform struct {
+	// OS is the operating system, using the values of GOOS, for example "linux".
+	OS string
+	// Arch is the architecture, using the values of GOARCH, for example "amd64".
+	Arch string
+	// GoVersion is the version of the Go runtime, for example "go1.21.0".
+	//
+	// This is empty for plugins not written in Go.
+	GoVersion string
+}
+
+// String returns the platform in the form os/arch.
+func (p Platform) String() string {
+	return p.OS + "/" + p.Arch
+}
+
+// CheckPlatform returns an error if the Info reports a platform whose OS or architecture
+// differs from that of the current process.
+//
+// This allows hosts to reject plugins built for the wrong platform with a clear message,
+// for example when a binary was copied from another machine. If the Info does not report
+// a platform, this returns nil. Plugins run with a pluginrpcwasm.Runner report wasip1/wasm,
+// so this should not be used for them.
+func CheckPlatform(info Info) error {
+	platform := info.Platform()
+	if platform == nil {
+		return nil
+	}
+	if current := currentPlatform(); platform.OS != current.OS || platform.Arch != current.Arch {
+		return fmt.Errorf("plugin was built for %s, but the host is %s", platform.String(), current.String())
+	}
+	return nil
+}
+
+// NewInfo returns a new validated Info.
+func NewInfo(options ...InfoOption) (Info, error) {
+	return newInfo(options...)
+}
+
+// InfoOption is an option for a new Info.
+type InfoOption func(*infoOptions)
+
+// InfoWithLicense specifies the license of the plugin.
+//
+// At least one of SPDXID or URL must be set.
+func InfoWithLicense(license License) InfoOption {
+	return func(infoOptions *infoOptions) {
+		infoOptions.license = &license
+	}
+}
+
+// InfoWithNotices specifies notices for third-party components included within the plugin.
+//
+// Each Notice must have a Name.
+func InfoWithNotices(notices ...Notice) InfoOption {
+	return func(infoOptions *infoOptions) {
+		infoOptions.notices = append(infoOptions.notices, notices...)
+	}
+}
+
+// InfoWithPlatform specifies the platform the plugin was built for.
+//
+// Servers report the platform they are running on by default, so this is only needed to
+// override it. OS and Arch must be set.
+func InfoWithPlatform(platform Platform) InfoOption {
+	return func(infoOptions *infoOptions) {
+		infoOptions.platform = &platform
+	}
+}
+
+// *** PRIVATE ***
+
+type info struct {
+	license  *License
+	notices  []Notice
+	platform *Platform
+}
+
+func newInfo(options ...InfoOption) (*info, error) {
+	infoOptions := newInfoOptions()
+	for _, option := range options {
+		option(infoOptions)
+	}
+	info := &info{
+		license:  infoOptions.license,
+		notices:  infoOptions.notices,
+		platform: infoOptions.platform,
+	}
+	if err := validateInfo(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (i *info) License() *License {
+	if i.license == nil {
+		return nil
+	}
+	license := *i.license
+	return &license
+}
+
+func (i *info) Notices() []Notice {
+	return slices.Clone(i.notices)
+}
+
+func (i *info) Platform() *Platform {
+	if i.platform == nil {
+		return nil
+	}
+	platform := *i.platform
+	return &platform
+}
+
+func (*info) isInfo() {}
+
+func newInfoForProto(protoInfo *extv1.Info) (Info, error) {
+	var options []InfoOption
+	if protoLicense := protoInfo.GetLicense(); protoLicense != nil {
+		options = append(options, InfoWithLicense(licenseForProto(protoLicense)))
+	}
+	for _, protoNotice := range protoInfo.GetNotices() {
+		notice := Notice{
+			Name: protoNotice.GetName(),
+			Text: protoNotice.GetText(),
+		}
+		if protoLicense := protoNotice.GetLicense(); protoLicense != nil {
+			license := licenseForProto(protoLicense)
+			notice.License = &license
+		}
+		options = append(options, InfoWithNotices(notice))
+	}
+	if protoPlatform := protoInfo.GetPlatform(); protoPlatform != nil {
+		options = append(options, InfoWithPlatform(platformForProto(protoPlatform)))
+	}
+	return NewInfo(options...)
+}
+
+func newProtoInfo(info Info) *extv1.Info {
+	protoInfo := &extv1.Info{}
+	if info == nil {
+		return protoInfo
+	}
+	if license := info.License(); license != nil {
+		protoInfo.License = newProtoLicense(*license)
+	}
+	for _, notice := range info.Notices() {
+		protoNotice := &extv1.Notice{
+			Name: notice.Name,
+			Text: notice.Text,
+		}
+		if notice.License != nil {
+			protoNotice.License = newProtoLicense(*notice.License)
+		}
+		protoInfo.Notices = append(protoInfo.Notices, protoNotice)
+	}
+	if platform := info.Platform(); platform != nil {
+		protoInfo.Platform = newProtoPlatform(*platform)
+	}
+	return protoInfo
+}
+
+func licenseForProto(protoLicense *extv1.License) License {
+	return License{
+		SPDXID: protoLicense.GetSpdxId(),
+		URL:    protoLicense.GetUrl(),
+	}
+}
+
+func newProtoLicense(license License) *extv1.License {
+	return &extv1.License{
+		SpdxId: license.SPDXID,
+		Url:    license.URL,
+	}
+}
+
+func platformForProto(protoPlatform *extv1.Platform) Platform {
+	return Platform{
+		OS:        protoPlatform.GetOs(),
+		Arch:      protoPlatform.GetArch(),
+		GoVersion: protoPlatform.GetGoVersion(),
+	}
+}
+
+func newProtoPlatform(platform Platform) *extv1.Platform {
+	return &extv1.Platform{
+		Os:        platform.OS,
+		Arch:      platform.Arch,
+		GoVersion: platform.GoVersion,
+	}
+}
+
+// currentPlatform returns the platform of the current process.
+func currentPlatform() Platform {
+	return Platform{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+	}
+}
+
+func validateInfo(info *info) error {
+	if info.license != nil {
+		if err := validateLicense(*info.license); err != nil {
+			return err
+		}
+	}
+	for _, notice := range info.notices {
+		if notice.Name == "" {
+			return errors.New("notice name is empty")
+		}
+		if notice.License != nil {
+			if err := validateLicense(*notice.License); err != nil {
+				return fmt.Errorf("invalid license for notice %q: %w", notice.Name, err)
+			}
+		}
+	}
+	if info.platform != nil && (info.platform.OS == "" || info.platform.Arch == "") {
+		return errors.New("platform must have an OS and architecture")
+	}
+	return nil
+}
+
+func validateLicense(license License) error {
+	if license.SPDXID == "" && license.URL == "" {
+		return errors.New("license must have at least one of an SPDX ID or URL")
+	}
+	return nil
+}
+
+type infoOptions struct {
+	license  *License
+	notices  []Notice
+	platform *Platform
+}
+
+func newInfoOptions() *infoOptions {
+	return &infoOptions{}
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	info, err := NewInfo(
+		InfoWithLicense(License{SPDXID: "Apache-2.0"}),
+		InfoWithNotices(
+			Notice{
+				Name:    "foo",
+				License: &License{URL: "https://example.com/LICENSE"},
+				Text:    "Copyright Foo",
+			},
+			Notice{
+				Name: "bar",
+			},
+		),
+		InfoWithPlatform(Platform{OS: "linux", Arch: "amd64", GoVersion: "go1.21.0"}),
+	)
+	require.NoError(t, err)
+	roundTripInfo, err := newInfoForProto(newProtoInfo(info))
+	require.NoError(t, err)
+	require.Equal(t, info.License(), roundTripInfo.License())
+	require.Equal(t, info.Notices(), roundTripInfo.Notices())
+	require.Equal(t, info.Platform(), roundTripInfo.Platform())
+
+	emptyInfo, err := newInfoForProto(newProtoInfo(nil))
+	require.NoError(t, err)
+	require.Nil(t, emptyInfo.License())
+	require.Empty(t, emptyInfo.Notices())
+	require.Nil(t, emptyInfo.Platform())
+}
+
+func TestCheckPlatform(t *testing.T) {
+	t.Parallel()
+
+	info, err := NewInfo()
+	require.NoError(t, err)
+	require.NoError(t, CheckPlatform(info))
+	info, err = NewInfo(InfoWithPlatform(currentPlatform()))
+	require.NoError(t, err)
+	require.NoError(t, CheckPlatform(info))
+	info, err = NewInfo(InfoWithPlatform(Platform{OS: "plan9", Arch: "arm"}))
+	require.NoError(t, err)
+	require.ErrorContains(t, CheckPlatform(info), "plugin was built for plan9/arm")
+}
+
+func TestInfoValidation(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewInfo(InfoWithLicense(License{}))
+	require.Error(t, err)
+	_, err = NewInfo(InfoWithNotices(Notice{Text: "foo"}))
+	require.Error(t, err)
+	_, err = NewInfo(InfoWithNotices(Notice{Name: "foo", License: &License{}}))
+	require.Error(t, err)
+	_, err = NewInfo(InfoWithPlatform(Platform{OS: "linux"}))
+	require.Error(t, err)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+)
+
+// CallFunc calls a Procedure.
+//
+// CallFuncs are wrapped by ClientInterceptors.
+type CallFunc func(ctx context.Context, procedurePath string, request any, response any, options ...CallOption) error
+
+// HandleFunc handles a request to a Procedure, returning the response.
+//
+// HandleFuncs are wrapped by HandlerInterceptors. The procedure path is empty if the
+// Handler is not invoked by a Server.
+type HandleFunc func(ctx context.Context, procedurePath string, request any) (any, error)
+
+// ClientInterceptor intercepts calls made by a Client.
+//
+// Interceptors are used to implement cross-cutting concerns such as logging, metrics,
+// authentication, and validation.
+//
+// Interceptors only apply to Client.Call. Streaming calls are not intercepted.
+type ClientInterceptor interface {
+	// InterceptCall wraps the given CallFunc.
+	InterceptCall(next CallFunc) CallFunc
+}
+
+// ClientInterceptorFunc is a function that implements ClientInterceptor.
+type ClientInterceptorFunc func(next CallFunc) CallFunc
+
+// InterceptCall implements ClientInterceptor.
+func (f ClientInterceptorFunc) InterceptCall(next CallFunc) CallFunc {
+	return f(next)
+}
+
+// HandlerInterceptor intercepts requests handled by a Handler.
+//
+// Interceptors are used to implement cross-cutting concerns such as logging, metrics,
+// authentication, and validation.
+//
+// Interceptors only apply to Handler.Handle. Streaming requests are not intercepted.
+type HandlerInterceptor interface {
+	// InterceptHandle wraps the given HandleFunc.
+	InterceptHandle(next HandleFunc) HandleFunc
+}
+
+// HandlerInterceptorFunc is a function that implements HandlerInterceptor.
+type HandlerInterceptorFunc func(next HandleFunc) HandleFunc
+
+// InterceptHandle implements HandlerInterceptor.
+func (f HandlerInterceptorFunc) InterceptHandle(next HandleFunc) HandleFunc {
+	return f(next)
+}
+
+// *** PRIVATE ***
+
+// chainClientInterceptors wraps the CallFunc with the interceptors, with the
+// first interceptor being the outermost.
+func chainClientInterceptors(callFunc CallFunc, interceptors []ClientInterceptor) CallFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		callFunc = interceptors[i].InterceptCall(callFunc)
+	}
+	return callFunc
+}
+
+// chainHandlerInterceptors wraps the HandleFunc with the interceptors, with the
+// first interceptor being the outermost.
+func chainHandlerInterceptors(handleFunc HandleFunc, interceptors []HandlerInterceptor) HandleFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		handleFunc = interceptors[i].InterceptHandle(handleFunc)
+	}
+	return handleFunc
+}
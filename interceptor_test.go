@@ -0,0 +1,95 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestInterceptors(t *testing.T) {
+	t.Parallel()
+
+	var events []string
+	newClientInterceptor := func(name string) pluginrpc.ClientInterceptor {
+		return pluginrpc.ClientInterceptorFunc(
+			func(next pluginrpc.CallFunc) pluginrpc.CallFunc {
+				return func(ctx context.Context, procedurePath string, request any, response any, options ...pluginrpc.CallOption) error {
+					events = append(events, "client "+name+" "+procedurePath)
+					return next(ctx, procedurePath, request, response, options...)
+				}
+			},
+		)
+	}
+	newHandlerInterceptor := func(name string) pluginrpc.HandlerInterceptor {
+		return pluginrpc.HandlerInterceptorFunc(
+			func(next pluginrpc.HandleFunc) pluginrpc.HandleFunc {
+				return func(ctx context.Context, procedurePath string, request any) (any, error) {
+					events = append(events, "handler "+name+" "+procedurePath)
+					if echoRequest, ok := request.(*examplev1.EchoRequestRequest); ok && echoRequest.GetMessage() == "forbidden" {
+						return nil, pluginrpc.NewErrorf(pluginrpc.CodePermissionDenied, "forbidden")
+					}
+					return next(ctx, procedurePath, request)
+				}
+			},
+		)
+	}
+
+	spec, err := examplev1pluginrpc.DefaultEchoServiceSpec()
+	require.NoError(t, err)
+	serverRegistrar := pluginrpc.NewServerRegistrar()
+	handler := pluginrpc.NewHandler(
+		spec,
+		pluginrpc.HandlerWithInterceptors(newHandlerInterceptor("a")),
+		pluginrpc.HandlerWithInterceptors(newHandlerInterceptor("b")),
+	)
+	examplev1pluginrpc.RegisterEchoServiceServer(
+		serverRegistrar,
+		examplev1pluginrpc.NewEchoServiceServer(handler, newEchoServiceHandler()),
+	)
+	server, err := pluginrpc.NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(
+		pluginrpc.NewServerRunner(server),
+		pluginrpc.ClientWithInterceptors(newClientInterceptor("a"), newClientInterceptor("b")),
+	)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+
+	response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.GetMessage())
+	path := examplev1pluginrpc.EchoServiceEchoRequestPath
+	require.Equal(
+		t,
+		[]string{
+			"client a " + path,
+			"client b " + path,
+			"handler a " + path,
+			"handler b " + path,
+		},
+		events,
+	)
+
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "forbidden"})
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodePermissionDenied, pluginrpcError.Code())
+}
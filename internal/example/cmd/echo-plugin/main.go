@@ -21,6 +21,7 @@ package main
 import (
 	"context"
 	"errors"
+	"io"
 
 	"pluginrpc.com/pluginrpc"
 	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
@@ -32,33 +33,54 @@ func main() {
 }
 
 func newServer() (pluginrpc.Server, error) {
-	spec, err := examplev1pluginrpc.EchoServiceSpecBuilder{
-		// Note that EchoList does not have optional args and will default to path being the only arg.
-		//
-		// This means that the following commands will invoke their respective procedures:
-		//
-		//   echo-plugin echo request
-		//   echo-plugin /pluginrpc.example.v1.EchoService/EchoList
-		//   echo-plugin echo error
-		EchoRequest: []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("echo", "request")},
-		EchoError:   []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("echo", "error")},
-	}.Build()
+	// The args of EchoRequest and EchoError are declared with the pluginrpc.ext.v1.method option in
+	// example.proto. Note that EchoList does not have optional args and will default to path being
+	// the only arg.
+	//
+	// This means that the following commands will invoke their respective procedures:
+	//
+	//   echo-plugin echo request
+	//   echo-plugin /pluginrpc.example.v1.EchoService/EchoList
+	//   echo-plugin echo error
+	info, err := pluginrpc.NewInfo(
+		pluginrpc.InfoWithLicense(
+			pluginrpc.License{
+				SPDXID: "Apache-2.0",
+				URL:    "https://github.com/pluginrpc/pluginrpc-go/blob/main/LICENSE",
+			},
+		),
+	)
 	if err != nil {
 		return nil, err
 	}
-	serverRegistrar := pluginrpc.NewServerRegistrar()
-	echoServiceServer := examplev1pluginrpc.NewEchoServiceServer(pluginrpc.NewHandler(spec), echoServiceHandler{})
-	examplev1pluginrpc.RegisterEchoServiceServer(serverRegistrar, echoServiceServer)
-	return pluginrpc.NewServer(
-		spec,
-		serverRegistrar,
-		pluginrpc.ServerWithDoc("An example plugin that implements the EchoService."),
+	return examplev1pluginrpc.NewEchoServiceServerForHandler(
+		echoServiceHandler{},
+		pluginrpc.ServerForHandlerWithSpecOptions(
+			// This allows clients to call procedures without the generated code, see ClientWithSpecDescriptors.
+			pluginrpc.SpecWithDescriptors(examplev1.File_pluginrpc_example_v1_example_proto),
+		),
+		pluginrpc.ServerForHandlerWithServerOptions(
+			pluginrpc.ServerWithDoc("An example plugin that implements the EchoService."),
+			pluginrpc.ServerWithInfo(info),
+		),
 	)
 }
 
 type echoServiceHandler struct{}
 
-func (echoServiceHandler) EchoRequest(_ context.Context, request *examplev1.EchoRequestRequest) (*examplev1.EchoRequestResponse, error) {
+func (echoServiceHandler) EchoRequest(ctx context.Context, request *examplev1.EchoRequestRequest) (*examplev1.EchoRequestResponse, error) {
+	// The payload of the request, if any, is echoed as the payload of the response.
+	if _, err := io.Copy(pluginrpc.ResponsePayload(ctx), pluginrpc.RequestPayload(ctx)); err != nil {
+		return nil, err
+	}
+	// If the host provides host services, the host echoes the message.
+	if client, ok := pluginrpc.HostServicesClient(ctx); ok {
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+		if err != nil {
+			return nil, err
+		}
+		return echoServiceClient.EchoRequest(ctx, request)
+	}
 	return &examplev1.EchoRequestResponse{Message: request.GetMessage()}, nil
 }
 
@@ -69,3 +91,27 @@ func (echoServiceHandler) EchoList(context.Context, *examplev1.EchoListRequest)
 func (echoServiceHandler) EchoError(_ context.Context, request *examplev1.EchoErrorRequest) (*examplev1.EchoErrorResponse, error) {
 	return nil, pluginrpc.NewError(pluginrpc.Code(request.GetCode()), errors.New(request.GetMessage()))
 }
+
+func (echoServiceHandler) EchoStream(_ context.Context, request *examplev1.EchoStreamRequest, send func(*examplev1.EchoStreamResponse) error) error {
+	for _, message := range request.GetMessages() {
+		if err := send(&examplev1.EchoStreamResponse{Message: message}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (echoServiceHandler) EchoBidi(_ context.Context, receive func() (*examplev1.EchoBidiRequest, error), send func(*examplev1.EchoBidiResponse) error) error {
+	for {
+		request, err := receive()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := send(&examplev1.EchoBidiResponse{Message: request.GetMessage()}); err != nil {
+			return err
+		}
+	}
+}
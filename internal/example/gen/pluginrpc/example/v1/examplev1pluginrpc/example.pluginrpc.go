@@ -20,6 +20,7 @@ package examplev1pluginrpc
 
 import (
 	context "context"
+	errors "errors"
 	fmt "fmt"
 	pluginrpc "pluginrpc.com/pluginrpc"
 	v1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
@@ -39,6 +40,10 @@ const (
 	EchoServiceEchoErrorPath = "/pluginrpc.example.v1.EchoService/EchoError"
 	// EchoServiceEchoListPath is the path of the EchoService's EchoList RPC.
 	EchoServiceEchoListPath = "/pluginrpc.example.v1.EchoService/EchoList"
+	// EchoServiceEchoStreamPath is the path of the EchoService's EchoStream RPC.
+	EchoServiceEchoStreamPath = "/pluginrpc.example.v1.EchoService/EchoStream"
+	// EchoServiceEchoBidiPath is the path of the EchoService's EchoBidi RPC.
+	EchoServiceEchoBidiPath = "/pluginrpc.example.v1.EchoService/EchoBidi"
 )
 
 // EchoServiceSpecBuilder builds a Spec for the pluginrpc.example.v1.EchoService service.
@@ -46,27 +51,110 @@ type EchoServiceSpecBuilder struct {
 	EchoRequest []pluginrpc.ProcedureOption
 	EchoError   []pluginrpc.ProcedureOption
 	EchoList    []pluginrpc.ProcedureOption
+	EchoStream  []pluginrpc.ProcedureOption
+	EchoBidi    []pluginrpc.ProcedureOption
 }
 
 // Build builds a Spec for the pluginrpc.example.v1.EchoService service.
-func (s EchoServiceSpecBuilder) Build() (pluginrpc.Spec, error) {
-	procedures := make([]pluginrpc.Procedure, 0, 3)
-	procedure, err := pluginrpc.NewProcedure(EchoServiceEchoRequestPath, s.EchoRequest...)
+func (s EchoServiceSpecBuilder) Build(options ...pluginrpc.SpecOption) (pluginrpc.Spec, error) {
+	procedures := make([]pluginrpc.Procedure, 0, 5)
+	procedure, err := pluginrpc.NewProcedure(
+		EchoServiceEchoRequestPath,
+		append([]pluginrpc.ProcedureOption{pluginrpc.ProcedureWithDoc("Echo the request back."), pluginrpc.ProcedureWithArgs("echo", "request")}, s.EchoRequest...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	procedures = append(procedures, procedure)
+	procedure, err = pluginrpc.NewProcedure(
+		EchoServiceEchoErrorPath,
+		append([]pluginrpc.ProcedureOption{pluginrpc.ProcedureWithDoc("Echo the error specified back as an error."), pluginrpc.ProcedureWithArgs("echo", "error")}, s.EchoError...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	procedures = append(procedures, procedure)
+	procedure, err = pluginrpc.NewProcedure(
+		EchoServiceEchoListPath,
+		append([]pluginrpc.ProcedureOption{pluginrpc.ProcedureWithDoc("Echo a static list [\"foo\", \"bar\"] back given an empty request.")}, s.EchoList...)...,
+	)
 	if err != nil {
 		return nil, err
 	}
 	procedures = append(procedures, procedure)
-	procedure, err = pluginrpc.NewProcedure(EchoServiceEchoErrorPath, s.EchoError...)
+	procedure, err = pluginrpc.NewProcedure(
+		EchoServiceEchoStreamPath,
+		append([]pluginrpc.ProcedureOption{pluginrpc.ProcedureWithDoc("Echo each message in the request back as a separate response.")}, s.EchoStream...)...,
+	)
 	if err != nil {
 		return nil, err
 	}
 	procedures = append(procedures, procedure)
-	procedure, err = pluginrpc.NewProcedure(EchoServiceEchoListPath, s.EchoList...)
+	procedure, err = pluginrpc.NewProcedure(
+		EchoServiceEchoBidiPath,
+		append([]pluginrpc.ProcedureOption{pluginrpc.ProcedureWithDoc("Echo each request back as a response as soon as it is received.")}, s.EchoBidi...)...,
+	)
 	if err != nil {
 		return nil, err
 	}
 	procedures = append(procedures, procedure)
-	return pluginrpc.NewSpec(procedures...)
+	return pluginrpc.NewSpecWithOptions(procedures, options...)
+}
+
+// DefaultEchoServiceSpec returns the default Spec for the pluginrpc.example.v1.EchoService service.
+//
+// This is equivalent to building an empty EchoServiceSpecBuilder, that is the Procedures have the
+// docs and args declared in the proto file. Use this in both the plugin and its tests so that they
+// cannot drift apart.
+func DefaultEchoServiceSpec(options ...pluginrpc.SpecOption) (pluginrpc.Spec, error) {
+	return EchoServiceSpecBuilder{}.Build(options...)
+}
+
+// EchoServiceSpecDescriptor describes the pluginrpc.example.v1.EchoService service.
+var EchoServiceSpecDescriptor = pluginrpc.ServiceSpecDescriptor{
+	FullName: "pluginrpc.example.v1.EchoService",
+	Methods: []pluginrpc.MethodSpecDescriptor{
+		{
+			Name:            "EchoRequest",
+			Path:            EchoServiceEchoRequestPath,
+			InputType:       (&v1.EchoRequestRequest{}).ProtoReflect().Type(),
+			OutputType:      (&v1.EchoRequestResponse{}).ProtoReflect().Type(),
+			ClientStreaming: false,
+			ServerStreaming: false,
+		},
+		{
+			Name:            "EchoError",
+			Path:            EchoServiceEchoErrorPath,
+			InputType:       (&v1.EchoErrorRequest{}).ProtoReflect().Type(),
+			OutputType:      (&v1.EchoErrorResponse{}).ProtoReflect().Type(),
+			ClientStreaming: false,
+			ServerStreaming: false,
+		},
+		{
+			Name:            "EchoList",
+			Path:            EchoServiceEchoListPath,
+			InputType:       (&v1.EchoListRequest{}).ProtoReflect().Type(),
+			OutputType:      (&v1.EchoListResponse{}).ProtoReflect().Type(),
+			ClientStreaming: false,
+			ServerStreaming: false,
+		},
+		{
+			Name:            "EchoStream",
+			Path:            EchoServiceEchoStreamPath,
+			InputType:       (&v1.EchoStreamRequest{}).ProtoReflect().Type(),
+			OutputType:      (&v1.EchoStreamResponse{}).ProtoReflect().Type(),
+			ClientStreaming: false,
+			ServerStreaming: true,
+		},
+		{
+			Name:            "EchoBidi",
+			Path:            EchoServiceEchoBidiPath,
+			InputType:       (&v1.EchoBidiRequest{}).ProtoReflect().Type(),
+			OutputType:      (&v1.EchoBidiResponse{}).ProtoReflect().Type(),
+			ClientStreaming: true,
+			ServerStreaming: true,
+		},
+	},
 }
 
 // EchoServiceClient is a client for the pluginrpc.example.v1.EchoService service.
@@ -77,6 +165,10 @@ type EchoServiceClient interface {
 	EchoError(context.Context, *v1.EchoErrorRequest, ...pluginrpc.CallOption) (*v1.EchoErrorResponse, error)
 	// Echo a static list ["foo", "bar"] back given an empty request.
 	EchoList(context.Context, *v1.EchoListRequest, ...pluginrpc.CallOption) (*v1.EchoListResponse, error)
+	// Echo each message in the request back as a separate response.
+	EchoStream(context.Context, *v1.EchoStreamRequest, func(*v1.EchoStreamResponse) error, ...pluginrpc.CallOption) error
+	// Echo each request back as a response as soon as it is received.
+	EchoBidi(context.Context, ...pluginrpc.CallOption) (pluginrpc.BidiStream, error)
 }
 
 // NewEchoServiceClient constructs a client for the pluginrpc.example.v1.EchoService service.
@@ -94,6 +186,36 @@ type EchoServiceHandler interface {
 	EchoError(context.Context, *v1.EchoErrorRequest) (*v1.EchoErrorResponse, error)
 	// Echo a static list ["foo", "bar"] back given an empty request.
 	EchoList(context.Context, *v1.EchoListRequest) (*v1.EchoListResponse, error)
+	// Echo each message in the request back as a separate response.
+	EchoStream(context.Context, *v1.EchoStreamRequest, func(*v1.EchoStreamResponse) error) error
+	// Echo each request back as a response as soon as it is received.
+	EchoBidi(context.Context, func() (*v1.EchoBidiRequest, error), func(*v1.EchoBidiResponse) error) error
+}
+
+// UnimplementedEchoServiceHandler returns CodeUnimplemented from all methods.
+//
+// Embed UnimplementedEchoServiceHandler in implementations of EchoServiceHandler so that adding
+// methods to the pluginrpc.example.v1.EchoService service does not break compilation.
+type UnimplementedEchoServiceHandler struct{}
+
+func (UnimplementedEchoServiceHandler) EchoRequest(context.Context, *v1.EchoRequestRequest) (*v1.EchoRequestResponse, error) {
+	return nil, pluginrpc.NewErrorf(pluginrpc.CodeUnimplemented, "procedure not implemented: %q", EchoServiceEchoRequestPath)
+}
+
+func (UnimplementedEchoServiceHandler) EchoError(context.Context, *v1.EchoErrorRequest) (*v1.EchoErrorResponse, error) {
+	return nil, pluginrpc.NewErrorf(pluginrpc.CodeUnimplemented, "procedure not implemented: %q", EchoServiceEchoErrorPath)
+}
+
+func (UnimplementedEchoServiceHandler) EchoList(context.Context, *v1.EchoListRequest) (*v1.EchoListResponse, error) {
+	return nil, pluginrpc.NewErrorf(pluginrpc.CodeUnimplemented, "procedure not implemented: %q", EchoServiceEchoListPath)
+}
+
+func (UnimplementedEchoServiceHandler) EchoStream(context.Context, *v1.EchoStreamRequest, func(*v1.EchoStreamResponse) error) error {
+	return pluginrpc.NewErrorf(pluginrpc.CodeUnimplemented, "procedure not implemented: %q", EchoServiceEchoStreamPath)
+}
+
+func (UnimplementedEchoServiceHandler) EchoBidi(context.Context, func() (*v1.EchoBidiRequest, error), func(*v1.EchoBidiResponse) error) error {
+	return pluginrpc.NewErrorf(pluginrpc.CodeUnimplemented, "procedure not implemented: %q", EchoServiceEchoBidiPath)
 }
 
 // EchoServiceServer serves the pluginrpc.example.v1.EchoService service.
@@ -104,6 +226,10 @@ type EchoServiceServer interface {
 	EchoError(context.Context, pluginrpc.HandleEnv, ...pluginrpc.HandleOption) error
 	// Echo a static list ["foo", "bar"] back given an empty request.
 	EchoList(context.Context, pluginrpc.HandleEnv, ...pluginrpc.HandleOption) error
+	// Echo each message in the request back as a separate response.
+	EchoStream(context.Context, pluginrpc.HandleEnv, ...pluginrpc.HandleOption) error
+	// Echo each request back as a response as soon as it is received.
+	EchoBidi(context.Context, pluginrpc.HandleEnv, ...pluginrpc.HandleOption) error
 }
 
 // NewEchoServiceServer constructs a server for the pluginrpc.example.v1.EchoService service.
@@ -116,9 +242,76 @@ func NewEchoServiceServer(handler pluginrpc.Handler, echoServiceHandler EchoServ
 
 // RegisterEchoServiceServer registers the server for the pluginrpc.example.v1.EchoService service.
 func RegisterEchoServiceServer(serverRegistrar pluginrpc.ServerRegistrar, echoServiceServer EchoServiceServer) {
-	serverRegistrar.Register(EchoServiceEchoRequestPath, echoServiceServer.EchoRequest)
-	serverRegistrar.Register(EchoServiceEchoErrorPath, echoServiceServer.EchoError)
-	serverRegistrar.Register(EchoServiceEchoListPath, echoServiceServer.EchoList)
+	serverRegistrar.RegisterTyped(
+		EchoServiceEchoRequestPath,
+		"pluginrpc.example.v1.EchoRequestRequest",
+		"pluginrpc.example.v1.EchoRequestResponse",
+		echoServiceServer.EchoRequest,
+	)
+	serverRegistrar.RegisterTyped(
+		EchoServiceEchoErrorPath,
+		"pluginrpc.example.v1.EchoErrorRequest",
+		"pluginrpc.example.v1.EchoErrorResponse",
+		echoServiceServer.EchoError,
+	)
+	serverRegistrar.RegisterTyped(
+		EchoServiceEchoListPath,
+		"pluginrpc.example.v1.EchoListRequest",
+		"pluginrpc.example.v1.EchoListResponse",
+		echoServiceServer.EchoList,
+	)
+	serverRegistrar.RegisterTyped(
+		EchoServiceEchoStreamPath,
+		"pluginrpc.example.v1.EchoStreamRequest",
+		"pluginrpc.example.v1.EchoStreamResponse",
+		echoServiceServer.EchoStream,
+	)
+	serverRegistrar.RegisterTyped(
+		EchoServiceEchoBidiPath,
+		"pluginrpc.example.v1.EchoBidiRequest",
+		"pluginrpc.example.v1.EchoBidiResponse",
+		echoServiceServer.EchoBidi,
+	)
+}
+
+// NewEchoServiceServerForHandler returns a new Server for the pluginrpc.example.v1.EchoService
+// service.
+//
+// The Spec is built with DefaultEchoServiceSpec, and the handler is registered with
+// NewEchoServiceServer and RegisterEchoServiceServer. Use these directly to serve multiple
+// services, or to override the defaults of the Spec.
+func NewEchoServiceServerForHandler(echoServiceHandler EchoServiceHandler, options ...pluginrpc.ServerForHandlerOption) (pluginrpc.Server, error) {
+	return pluginrpc.NewServerForHandler(
+		DefaultEchoServiceSpec,
+		func(serverRegistrar pluginrpc.ServerRegistrar, handler pluginrpc.Handler) {
+			RegisterEchoServiceServer(serverRegistrar, NewEchoServiceServer(handler, echoServiceHandler))
+		},
+		options...,
+	)
+}
+
+// EchoServiceRegistration is the registration of the pluginrpc.example.v1.EchoService service for
+// pluginrpc.MainService and pluginrpc.NewServerForServices.
+type EchoServiceRegistration struct {
+	// Handler is the implementation of the service.
+	//
+	// Required.
+	Handler EchoServiceHandler
+	// Spec overrides the defaults of the Procedures of the service, for example their args.
+	Spec EchoServiceSpecBuilder
+}
+
+// BuildSpec implements pluginrpc.ServiceRegistration.
+func (r EchoServiceRegistration) BuildSpec() (pluginrpc.Spec, error) {
+	if r.Handler == nil {
+		return nil, errors.New("EchoServiceRegistration: Handler is required")
+	}
+	return r.Spec.Build()
+}
+
+// Register implements pluginrpc.ServiceRegistration.
+func (r EchoServiceRegistration) Register(serverRegistrar pluginrpc.ServerRegistrar, handler pluginrpc.Handler) {
+	RegisterEchoServiceServer(serverRegistrar, NewEchoServiceServer(handler, r.Handler))
 }
 
 // *** PRIVATE ***
@@ -132,6 +325,9 @@ type echoServiceClient struct {
 func (c *echoServiceClient) EchoRequest(ctx context.Context, req *v1.EchoRequestRequest, opts ...pluginrpc.CallOption) (*v1.EchoRequestResponse, error) {
 	res := &v1.EchoRequestResponse{}
 	if err := c.client.Call(ctx, EchoServiceEchoRequestPath, req, res, opts...); err != nil {
+		if pluginrpc.HasPartialResult(err) {
+			return res, err
+		}
 		return nil, err
 	}
 	return res, nil
@@ -141,6 +337,9 @@ func (c *echoServiceClient) EchoRequest(ctx context.Context, req *v1.EchoRequest
 func (c *echoServiceClient) EchoError(ctx context.Context, req *v1.EchoErrorRequest, opts ...pluginrpc.CallOption) (*v1.EchoErrorResponse, error) {
 	res := &v1.EchoErrorResponse{}
 	if err := c.client.Call(ctx, EchoServiceEchoErrorPath, req, res, opts...); err != nil {
+		if pluginrpc.HasPartialResult(err) {
+			return res, err
+		}
 		return nil, err
 	}
 	return res, nil
@@ -150,11 +349,39 @@ func (c *echoServiceClient) EchoError(ctx context.Context, req *v1.EchoErrorRequ
 func (c *echoServiceClient) EchoList(ctx context.Context, req *v1.EchoListRequest, opts ...pluginrpc.CallOption) (*v1.EchoListResponse, error) {
 	res := &v1.EchoListResponse{}
 	if err := c.client.Call(ctx, EchoServiceEchoListPath, req, res, opts...); err != nil {
+		if pluginrpc.HasPartialResult(err) {
+			return res, err
+		}
 		return nil, err
 	}
 	return res, nil
 }
 
+// EchoStream calls pluginrpc.example.v1.EchoService.EchoStream.
+func (c *echoServiceClient) EchoStream(ctx context.Context, req *v1.EchoStreamRequest, onResponse func(*v1.EchoStreamResponse) error, opts ...pluginrpc.CallOption) error {
+	return c.client.CallServerStream(
+		ctx,
+		EchoServiceEchoStreamPath,
+		req,
+		func() any {
+			return &v1.EchoStreamResponse{}
+		},
+		func(anyRes any) error {
+			res, ok := anyRes.(*v1.EchoStreamResponse)
+			if !ok {
+				return fmt.Errorf("could not cast %T to a *v1.EchoStreamResponse", anyRes)
+			}
+			return onResponse(res)
+		},
+		opts...,
+	)
+}
+
+// EchoBidi calls pluginrpc.example.v1.EchoService.EchoBidi.
+func (c *echoServiceClient) EchoBidi(ctx context.Context, opts ...pluginrpc.CallOption) (pluginrpc.BidiStream, error) {
+	return c.client.CallBidiStream(ctx, EchoServiceEchoBidiPath, opts...)
+}
+
 // echoServiceServer implements EchoServiceServer.
 type echoServiceServer struct {
 	handler            pluginrpc.Handler
@@ -211,3 +438,56 @@ func (c *echoServiceServer) EchoList(ctx context.Context, handleEnv pluginrpc.Ha
 		options...,
 	)
 }
+
+// EchoStream calls pluginrpc.example.v1.EchoService.EchoStream.
+func (c *echoServiceServer) EchoStream(ctx context.Context, handleEnv pluginrpc.HandleEnv, options ...pluginrpc.HandleOption) error {
+	return c.handler.HandleServerStream(
+		ctx,
+		handleEnv,
+		&v1.EchoStreamRequest{},
+		func(ctx context.Context, anyReq any, send func(any) error) error {
+			req, ok := anyReq.(*v1.EchoStreamRequest)
+			if !ok {
+				return fmt.Errorf("could not cast %T to a *v1.EchoStreamRequest", anyReq)
+			}
+			return c.echoServiceHandler.EchoStream(
+				ctx, req,
+				func(res *v1.EchoStreamResponse) error {
+					return send(res)
+				},
+			)
+		},
+		options...,
+	)
+}
+
+// EchoBidi calls pluginrpc.example.v1.EchoService.EchoBidi.
+func (c *echoServiceServer) EchoBidi(ctx context.Context, handleEnv pluginrpc.HandleEnv, options ...pluginrpc.HandleOption) error {
+	return c.handler.HandleBidiStream(
+		ctx,
+		handleEnv,
+		func() any {
+			return &v1.EchoBidiRequest{}
+		},
+		func(ctx context.Context, receive func() (any, error), send func(any) error) error {
+			return c.echoServiceHandler.EchoBidi(
+				ctx,
+				func() (*v1.EchoBidiRequest, error) {
+					anyReq, err := receive()
+					if err != nil {
+						return nil, err
+					}
+					req, ok := anyReq.(*v1.EchoBidiRequest)
+					if !ok {
+						return nil, fmt.Errorf("could not cast %T to a *v1.EchoBidiRequest", anyReq)
+					}
+					return req, nil
+				},
+				func(res *v1.EchoBidiResponse) error {
+					return send(res)
+				},
+			)
+		},
+		options...,
+	)
+}
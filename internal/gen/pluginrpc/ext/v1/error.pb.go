@@ -0,0 +1,416 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: pluginrpc/ext/v1/error.proto
+
+package extv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	anypb "google.golang.org/protobuf/types/known/anypb"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Additional details for an error.
+//
+// When the `--error-details` flag is passed to the plugin, the plugin may set
+// the value of an error Response to an ErrorDetails, or to a PartialResultValue
+// if the error is accompanied by a partial result.
+type ErrorDetails struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The duration the client should wait before retrying the call.
+	//
+	// This is optional.
+	RetryAfter *durationpb.Duration `protobuf:"bytes,1,opt,name=retry_after,json=retryAfter,proto3" json:"retry_after,omitempty"`
+	// Localized messages for the error, keyed by BCP 47 language tag, for example `en-US`.
+	LocalizedMessages map[string]string `protobuf:"bytes,2,rep,name=localized_messages,json=localizedMessages,proto3" json:"localized_messages,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// The errors that were joined to create the error, for example via `errors.Join` in Go.
+	//
+	// The message of the error is the concatenation of the messages of the joined errors.
+	JoinedErrors []*JoinedError `protobuf:"bytes,3,rep,name=joined_errors,json=joinedErrors,proto3" json:"joined_errors,omitempty"`
+	// Structured details for the error, analogous to gRPC status details, for example
+	// validation failures.
+	Details []*anypb.Any `protobuf:"bytes,4,rep,name=details,proto3" json:"details,omitempty"`
+}
+
+func (x *ErrorDetails) Reset() {
+	*x = ErrorDetails{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pluginrpc_ext_v1_error_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ErrorDetails) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorDetails) ProtoMessage() {}
+
+func (x *ErrorDetails) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginrpc_ext_v1_error_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorDetails.ProtoReflect.Descriptor instead.
+func (*ErrorDetails) Descriptor() ([]byte, []int) {
+	return file_pluginrpc_ext_v1_error_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ErrorDetails) GetRetryAfter() *durationpb.Duration {
+	if x != nil {
+		return x.RetryAfter
+	}
+	return nil
+}
+
+func (x *ErrorDetails) GetLocalizedMessages() map[string]string {
+	if x != nil {
+		return x.LocalizedMessages
+	}
+	return nil
+}
+
+func (x *ErrorDetails) GetJoinedErrors() []*JoinedError {
+	if x != nil {
+		return x.JoinedErrors
+	}
+	return nil
+}
+
+func (x *ErrorDetails) GetDetails() []*anypb.Any {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+// A partial result of an error Response, together with the details of the error.
+//
+// When the `--error-details` flag is passed to the plugin, and the error accompanying a
+// partial result has details, the plugin may set the value of the Response to a
+// PartialResultValue, wrapping the value that would otherwise have been set.
+type PartialResultValue struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The value that would otherwise have been set on the Response.
+	Value *anypb.Any `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	// The details of the error.
+	ErrorDetails *ErrorDetails `protobuf:"bytes,2,opt,name=error_details,json=errorDetails,proto3" json:"error_details,omitempty"`
+}
+
+func (x *PartialResultValue) Reset() {
+	*x = PartialResultValue{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pluginrpc_ext_v1_error_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PartialResultValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PartialResultValue) ProtoMessage() {}
+
+func (x *PartialResultValue) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginrpc_ext_v1_error_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PartialResultValue.ProtoReflect.Descriptor instead.
+func (*PartialResultValue) Descriptor() ([]byte, []int) {
+	return file_pluginrpc_ext_v1_error_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PartialResultValue) GetValue() *anypb.Any {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *PartialResultValue) GetErrorDetails() *ErrorDetails {
+	if x != nil {
+		return x.ErrorDetails
+	}
+	return nil
+}
+
+// An error that was joined with other errors.
+type JoinedError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The code of the joined error, matching the values of `pluginrpc.v1.Code`.
+	//
+	// This is zero if the joined error did not have a code.
+	Code uint32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	// The message of the joined error.
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *JoinedError) Reset() {
+	*x = JoinedError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pluginrpc_ext_v1_error_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *JoinedError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JoinedError) ProtoMessage() {}
+
+func (x *JoinedError) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginrpc_ext_v1_error_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JoinedError.ProtoReflect.Descriptor instead.
+func (*JoinedError) Descriptor() ([]byte, []int) {
+	return file_pluginrpc_ext_v1_error_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *JoinedError) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *JoinedError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_pluginrpc_ext_v1_error_proto protoreflect.FileDescriptor
+
+var file_pluginrpc_ext_v1_error_proto_rawDesc = []byte{
+	0x0a, 0x1c, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x74, 0x2f,
+	0x76, 0x31, 0x2f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10,
+	0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e, 0x76, 0x31,
+	0x1a, 0x19, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2f, 0x61, 0x6e, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x75, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xea, 0x02, 0x0a, 0x0c,
+	0x45, 0x72, 0x72, 0x6f, 0x72, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x12, 0x3a, 0x0a, 0x0b,
+	0x72, 0x65, 0x74, 0x72, 0x79, 0x5f, 0x61, 0x66, 0x74, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0a, 0x72, 0x65,
+	0x74, 0x72, 0x79, 0x41, 0x66, 0x74, 0x65, 0x72, 0x12, 0x64, 0x0a, 0x12, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x69, 0x7a, 0x65, 0x64, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x18, 0x02,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x35, 0x2e, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63,
+	0x2e, 0x65, 0x78, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x44, 0x65, 0x74,
+	0x61, 0x69, 0x6c, 0x73, 0x2e, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x64, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x11, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x69, 0x7a, 0x65, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x42,
+	0x0a, 0x0d, 0x6a, 0x6f, 0x69, 0x6e, 0x65, 0x64, 0x5f, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70,
+	0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x4a, 0x6f, 0x69, 0x6e, 0x65, 0x64, 0x45,
+	0x72, 0x72, 0x6f, 0x72, 0x52, 0x0c, 0x6a, 0x6f, 0x69, 0x6e, 0x65, 0x64, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x73, 0x12, 0x2e, 0x0a, 0x07, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x41, 0x6e, 0x79, 0x52, 0x07, 0x64, 0x65, 0x74, 0x61, 0x69,
+	0x6c, 0x73, 0x1a, 0x44, 0x0a, 0x16, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x69, 0x7a, 0x65, 0x64, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x85, 0x01, 0x0a, 0x12, 0x50, 0x61, 0x72,
+	0x74, 0x69, 0x61, 0x6c, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12,
+	0x2a, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x41, 0x6e, 0x79, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x43, 0x0a, 0x0d, 0x65,
+	0x72, 0x72, 0x6f, 0x72, 0x5f, 0x64, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65,
+	0x78, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x44, 0x65, 0x74, 0x61, 0x69,
+	0x6c, 0x73, 0x52, 0x0c, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x44, 0x65, 0x74, 0x61, 0x69, 0x6c, 0x73,
+	0x22, 0x3b, 0x0a, 0x0b, 0x4a, 0x6f, 0x69, 0x6e, 0x65, 0x64, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12,
+	0x12, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x63,
+	0x6f, 0x64, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x42, 0xc1, 0x01,
+	0x0a, 0x14, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e,
+	0x65, 0x78, 0x74, 0x2e, 0x76, 0x31, 0x42, 0x0a, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x50, 0x72, 0x6f,
+	0x74, 0x6f, 0x50, 0x01, 0x5a, 0x3b, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x70, 0x6c, 0x75, 0x67, 0x69,
+	0x6e, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x74, 0x2f, 0x76, 0x31, 0x3b, 0x65, 0x78, 0x74, 0x76,
+	0x31, 0xa2, 0x02, 0x03, 0x50, 0x45, 0x58, 0xaa, 0x02, 0x10, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e,
+	0x72, 0x70, 0x63, 0x2e, 0x45, 0x78, 0x74, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x10, 0x50, 0x6c, 0x75,
+	0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x5c, 0x45, 0x78, 0x74, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x1c,
+	0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x5c, 0x45, 0x78, 0x74, 0x5c, 0x56, 0x31,
+	0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x12, 0x50,
+	0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x3a, 0x3a, 0x45, 0x78, 0x74, 0x3a, 0x3a, 0x56,
+	0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pluginrpc_ext_v1_error_proto_rawDescOnce sync.Once
+	file_pluginrpc_ext_v1_error_proto_rawDescData = file_pluginrpc_ext_v1_error_proto_rawDesc
+)
+
+func file_pluginrpc_ext_v1_error_proto_rawDescGZIP() []byte {
+	file_pluginrpc_ext_v1_error_proto_rawDescOnce.Do(func() {
+		file_pluginrpc_ext_v1_error_proto_rawDescData = protoimpl.X.CompressGZIP(file_pluginrpc_ext_v1_error_proto_rawDescData)
+	})
+	return file_pluginrpc_ext_v1_error_proto_rawDescData
+}
+
+var file_pluginrpc_ext_v1_error_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_pluginrpc_ext_v1_error_proto_goTypes = []any{
+	(*ErrorDetails)(nil),        // 0: pluginrpc.ext.v1.ErrorDetails
+	(*PartialResultValue)(nil),  // 1: pluginrpc.ext.v1.PartialResultValue
+	(*JoinedError)(nil),         // 2: pluginrpc.ext.v1.JoinedError
+	nil,                         // 3: pluginrpc.ext.v1.ErrorDetails.LocalizedMessagesEntry
+	(*durationpb.Duration)(nil), // 4: google.protobuf.Duration
+	(*anypb.Any)(nil),           // 5: google.protobuf.Any
+}
+var file_pluginrpc_ext_v1_error_proto_depIdxs = []int32{
+	4, // 0: pluginrpc.ext.v1.ErrorDetails.retry_after:type_name -> google.protobuf.Duration
+	3, // 1: pluginrpc.ext.v1.ErrorDetails.localized_messages:type_name -> pluginrpc.ext.v1.ErrorDetails.LocalizedMessagesEntry
+	2, // 2: pluginrpc.ext.v1.ErrorDetails.joined_errors:type_name -> pluginrpc.ext.v1.JoinedError
+	5, // 3: pluginrpc.ext.v1.ErrorDetails.details:type_name -> google.protobuf.Any
+	5, // 4: pluginrpc.ext.v1.PartialResultValue.value:type_name -> google.protobuf.Any
+	0, // 5: pluginrpc.ext.v1.PartialResultValue.error_details:type_name -> pluginrpc.ext.v1.ErrorDetails
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_pluginrpc_ext_v1_error_proto_init() }
+func file_pluginrpc_ext_v1_error_proto_init() {
+	if File_pluginrpc_ext_v1_error_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pluginrpc_ext_v1_error_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ErrorDetails); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pluginrpc_ext_v1_error_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*PartialResultValue); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pluginrpc_ext_v1_error_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*JoinedError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pluginrpc_ext_v1_error_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_pluginrpc_ext_v1_error_proto_goTypes,
+		DependencyIndexes: file_pluginrpc_ext_v1_error_proto_depIdxs,
+		MessageInfos:      file_pluginrpc_ext_v1_error_proto_msgTypes,
+	}.Build()
+	File_pluginrpc_ext_v1_error_proto = out.File
+	file_pluginrpc_ext_v1_error_proto_rawDesc = nil
+	file_pluginrpc_ext_v1_error_proto_goTypes = nil
+	file_pluginrpc_ext_v1_error_proto_depIdxs = nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: pluginrpc/ext/v1/options.proto
+
+package extv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	descriptorpb "google.golang.org/protobuf/types/descriptorpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// The pluginrpc options of a method.
+type MethodOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The args of the procedure for the method, separated by spaces.
+	//
+	// If empty, the procedure is called by its path, see pluginrpc.v1.Procedure.
+	Args string `protobuf:"bytes,1,opt,name=args,proto3" json:"args,omitempty"`
+}
+
+func (x *MethodOptions) Reset() {
+	*x = MethodOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pluginrpc_ext_v1_options_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MethodOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MethodOptions) ProtoMessage() {}
+
+func (x *MethodOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginrpc_ext_v1_options_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MethodOptions.ProtoReflect.Descriptor instead.
+func (*MethodOptions) Descriptor() ([]byte, []int) {
+	return file_pluginrpc_ext_v1_options_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MethodOptions) GetArgs() string {
+	if x != nil {
+		return x.Args
+	}
+	return ""
+}
+
+var file_pluginrpc_ext_v1_options_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptorpb.MethodOptions)(nil),
+		ExtensionType: (*MethodOptions)(nil),
+		Field:         51820,
+		Name:          "pluginrpc.ext.v1.method",
+		Tag:           "bytes,51820,opt,name=method",
+		Filename:      "pluginrpc/ext/v1/options.proto",
+	},
+}
+
+// Extension fields to descriptorpb.MethodOptions.
+var (
+	// The pluginrpc options of the method.
+	//
+	// These are read by protoc-gen-pluginrpc-go to populate the defaults of the
+	// generated SpecBuilder, for example:
+	//
+	//   option (pluginrpc.ext.v1.method).args = "echo request";
+	//
+	// optional pluginrpc.ext.v1.MethodOptions method = 51820;
+	E_Method = &file_pluginrpc_ext_v1_options_proto_extTypes[0]
+)
+
+var File_pluginrpc_ext_v1_options_proto protoreflect.FileDescriptor
+
+var file_pluginrpc_ext_v1_options_proto_rawDesc = []byte{
+	0x0a, 0x1e, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x74, 0x2f,
+	0x76, 0x31, 0x2f, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x12, 0x10, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e,
+	0x76, 0x31, 0x1a, 0x20, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2f, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x22, 0x23, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x61, 0x72, 0x67, 0x73, 0x3a, 0x59, 0x0a, 0x06, 0x6d, 0x65, 0x74,
+	0x68, 0x6f, 0x64, 0x12, 0x1e, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x73, 0x18, 0xec, 0x94, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x70, 0x6c,
+	0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x65, 0x74, 0x68, 0x6f, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x52, 0x06, 0x6d, 0x65,
+	0x74, 0x68, 0x6f, 0x64, 0x42, 0xc3, 0x01, 0x0a, 0x14, 0x63, 0x6f, 0x6d, 0x2e, 0x70, 0x6c, 0x75,
+	0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e, 0x76, 0x31, 0x42, 0x0c, 0x4f,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x3b, 0x70,
+	0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x70, 0x6c, 0x75,
+	0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f,
+	0x67, 0x65, 0x6e, 0x2f, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78,
+	0x74, 0x2f, 0x76, 0x31, 0x3b, 0x65, 0x78, 0x74, 0x76, 0x31, 0xa2, 0x02, 0x03, 0x50, 0x45, 0x58,
+	0xaa, 0x02, 0x10, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x45, 0x78, 0x74,
+	0x2e, 0x56, 0x31, 0xca, 0x02, 0x10, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x5c,
+	0x45, 0x78, 0x74, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x1c, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72,
+	0x70, 0x63, 0x5c, 0x45, 0x78, 0x74, 0x5c, 0x56, 0x31, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74,
+	0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x12, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70,
+	0x63, 0x3a, 0x3a, 0x45, 0x78, 0x74, 0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
+}
+
+var (
+	file_pluginrpc_ext_v1_options_proto_rawDescOnce sync.Once
+	file_pluginrpc_ext_v1_options_proto_rawDescData = file_pluginrpc_ext_v1_options_proto_rawDesc
+)
+
+func file_pluginrpc_ext_v1_options_proto_rawDescGZIP() []byte {
+	file_pluginrpc_ext_v1_options_proto_rawDescOnce.Do(func() {
+		file_pluginrpc_ext_v1_options_proto_rawDescData = protoimpl.X.CompressGZIP(file_pluginrpc_ext_v1_options_proto_rawDescData)
+	})
+	return file_pluginrpc_ext_v1_options_proto_rawDescData
+}
+
+var file_pluginrpc_ext_v1_options_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_pluginrpc_ext_v1_options_proto_goTypes = []any{
+	(*MethodOptions)(nil),              // 0: pluginrpc.ext.v1.MethodOptions
+	(*descriptorpb.MethodOptions)(nil), // 1: google.protobuf.MethodOptions
+}
+var file_pluginrpc_ext_v1_options_proto_depIdxs = []int32{
+	1, // 0: pluginrpc.ext.v1.method:extendee -> google.protobuf.MethodOptions
+	0, // 1: pluginrpc.ext.v1.method:type_name -> pluginrpc.ext.v1.MethodOptions
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	1, // [1:2] is the sub-list for extension type_name
+	0, // [0:1] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_pluginrpc_ext_v1_options_proto_init() }
+func file_pluginrpc_ext_v1_options_proto_init() {
+	if File_pluginrpc_ext_v1_options_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pluginrpc_ext_v1_options_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*MethodOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pluginrpc_ext_v1_options_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 1,
+			NumServices:   0,
+		},
+		GoTypes:           file_pluginrpc_ext_v1_options_proto_goTypes,
+		DependencyIndexes: file_pluginrpc_ext_v1_options_proto_depIdxs,
+		MessageInfos:      file_pluginrpc_ext_v1_options_proto_msgTypes,
+		ExtensionInfos:    file_pluginrpc_ext_v1_options_proto_extTypes,
+	}.Build()
+	File_pluginrpc_ext_v1_options_proto = out.File
+	file_pluginrpc_ext_v1_options_proto_rawDesc = nil
+	file_pluginrpc_ext_v1_options_proto_goTypes = nil
+	file_pluginrpc_ext_v1_options_proto_depIdxs = nil
+}
@@ -0,0 +1,466 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: pluginrpc/ext/v1/serve.proto
+
+package extv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// A frame sent from the client to the plugin when the plugin is run with `--serve`.
+//
+// In serve mode, the plugin stays alive and multiplexes calls over stdin and stdout. Each
+// frame is prefixed by its length as a 4-byte big-endian unsigned integer.
+//
+// Each call is equivalent to a single invocation of the plugin. A call is started by the
+// first ServeRequest with a new id, and the plugin responds with ServeResponses with the
+// same id.
+type ServeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The id of the call, unique to the session.
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The arguments of the call, equivalent to the arguments of a single invocation of the plugin.
+	//
+	// This is only read on the first ServeRequest for a call.
+	Args []string `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	// Data to append to the stdin of the call.
+	Stdin []byte `protobuf:"bytes,3,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	// Whether stdin of the call is closed. No more data may be sent to stdin for the call afterwards.
+	CloseStdin bool `protobuf:"varint,4,opt,name=close_stdin,json=closeStdin,proto3" json:"close_stdin,omitempty"`
+	// Whether the call is cancelled.
+	Cancel bool `protobuf:"varint,5,opt,name=cancel,proto3" json:"cancel,omitempty"`
+	// A heartbeat ping, with a sequence number that is greater than zero.
+	//
+	// If set, the id and all other fields are ignored, and the plugin responds with a
+	// ServeResponse with pong set to the same sequence number.
+	Ping uint64 `protobuf:"varint,6,opt,name=ping,proto3" json:"ping,omitempty"`
+	// The priority of the call.
+	//
+	// If the plugin bounds the number of calls handled concurrently, calls with a higher
+	// priority are handled before waiting calls with a lower priority. Calls with the same
+	// priority are handled in the order they were started.
+	//
+	// This is only read on the first ServeRequest for a call.
+	Priority int32 `protobuf:"varint,7,opt,name=priority,proto3" json:"priority,omitempty"`
+	// Metadata for the session, merged into the request metadata of every call started
+	// after this frame, see `--metadata`.
+	//
+	// Metadata specified with `--metadata` for a call takes precedence. This avoids sending
+	// the same metadata, such as credentials, with every call. A later ServeRequest with
+	// session_metadata replaces the metadata of the session.
+	//
+	// If set, the id and all other fields are ignored.
+	SessionMetadata map[string]string `protobuf:"bytes,8,rep,name=session_metadata,json=sessionMetadata,proto3" json:"session_metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Enables flow control for the session, with the window of the client in bytes.
+	//
+	// The window is the number of bytes of stdout and stderr that the plugin may send for
+	// each call before the client grants more with window_update. The plugin responds with
+	// a ServeResponse with flow_control_window set to its own window for the stdin of each
+	// call. Flow control only applies to calls started after this frame.
+	//
+	// If set, the id and all other fields are ignored.
+	FlowControlWindow uint32 `protobuf:"varint,9,opt,name=flow_control_window,json=flowControlWindow,proto3" json:"flow_control_window,omitempty"`
+	// The number of bytes of stdout and stderr of the call that the client has consumed,
+	// granting the plugin that many more bytes to send for the call.
+	//
+	// If set, all other fields except the id are ignored.
+	WindowUpdate uint32 `protobuf:"varint,10,opt,name=window_update,json=windowUpdate,proto3" json:"window_update,omitempty"`
+}
+
+func (x *ServeRequest) Reset() {
+	*x = ServeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pluginrpc_ext_v1_serve_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServeRequest) ProtoMessage() {}
+
+func (x *ServeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginrpc_ext_v1_serve_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServeRequest.ProtoReflect.Descriptor instead.
+func (*ServeRequest) Descriptor() ([]byte, []int) {
+	return file_pluginrpc_ext_v1_serve_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ServeRequest) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ServeRequest) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ServeRequest) GetStdin() []byte {
+	if x != nil {
+		return x.Stdin
+	}
+	return nil
+}
+
+func (x *ServeRequest) GetCloseStdin() bool {
+	if x != nil {
+		return x.CloseStdin
+	}
+	return false
+}
+
+func (x *ServeRequest) GetCancel() bool {
+	if x != nil {
+		return x.Cancel
+	}
+	return false
+}
+
+func (x *ServeRequest) GetPing() uint64 {
+	if x != nil {
+		return x.Ping
+	}
+	return 0
+}
+
+func (x *ServeRequest) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *ServeRequest) GetSessionMetadata() map[string]string {
+	if x != nil {
+		return x.SessionMetadata
+	}
+	return nil
+}
+
+func (x *ServeRequest) GetFlowControlWindow() uint32 {
+	if x != nil {
+		return x.FlowControlWindow
+	}
+	return 0
+}
+
+func (x *ServeRequest) GetWindowUpdate() uint32 {
+	if x != nil {
+		return x.WindowUpdate
+	}
+	return 0
+}
+
+// A frame sent from the plugin to the client when the plugin is run with `--serve`.
+type ServeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The id of the call.
+	Id uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Data written to the stdout of the call.
+	Stdout []byte `protobuf:"bytes,2,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	// Data written to the stderr of the call.
+	Stderr []byte `protobuf:"bytes,3,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	// Whether the call is done. This is the last ServeResponse for the call.
+	Done bool `protobuf:"varint,4,opt,name=done,proto3" json:"done,omitempty"`
+	// The exit code of the call, if done.
+	//
+	// This is equivalent to the exit code of a single invocation of the plugin.
+	ExitCode uint32 `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	// The response to a heartbeat ping, with the sequence number of the ping.
+	//
+	// If set, the id and all other fields are unset.
+	Pong uint64 `protobuf:"varint,6,opt,name=pong,proto3" json:"pong,omitempty"`
+	// The response to a ServeRequest with flow_control_window set, with the window of the
+	// plugin in bytes.
+	//
+	// The window is the number of bytes of stdin that the client may send for each call
+	// before the plugin grants more with window_update.
+	//
+	// If set, the id and all other fields are unset.
+	FlowControlWindow uint32 `protobuf:"varint,7,opt,name=flow_control_window,json=flowControlWindow,proto3" json:"flow_control_window,omitempty"`
+	// The number of bytes of stdin of the call that the plugin has consumed, granting the
+	// client that many more bytes to send for the call.
+	//
+	// If set, all other fields except the id are unset.
+	WindowUpdate uint32 `protobuf:"varint,8,opt,name=window_update,json=windowUpdate,proto3" json:"window_update,omitempty"`
+}
+
+func (x *ServeResponse) Reset() {
+	*x = ServeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pluginrpc_ext_v1_serve_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ServeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServeResponse) ProtoMessage() {}
+
+func (x *ServeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pluginrpc_ext_v1_serve_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServeResponse.ProtoReflect.Descriptor instead.
+func (*ServeResponse) Descriptor() ([]byte, []int) {
+	return file_pluginrpc_ext_v1_serve_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ServeResponse) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ServeResponse) GetStdout() []byte {
+	if x != nil {
+		return x.Stdout
+	}
+	return nil
+}
+
+func (x *ServeResponse) GetStderr() []byte {
+	if x != nil {
+		return x.Stderr
+	}
+	return nil
+}
+
+func (x *ServeResponse) GetDone() bool {
+	if x != nil {
+		return x.Done
+	}
+	return false
+}
+
+func (x *ServeResponse) GetExitCode() uint32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *ServeResponse) GetPong() uint64 {
+	if x != nil {
+		return x.Pong
+	}
+	return 0
+}
+
+func (x *ServeResponse) GetFlowControlWindow() uint32 {
+	if x != nil {
+		return x.FlowControlWindow
+	}
+	return 0
+}
+
+func (x *ServeResponse) GetWindowUpdate() uint32 {
+	if x != nil {
+		return x.WindowUpdate
+	}
+	return 0
+}
+
+var File_pluginrpc_ext_v1_serve_proto protoreflect.FileDescriptor
+
+var file_pluginrpc_ext_v1_serve_proto_rawDesc = []byte{
+	0x0a, 0x1c, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x65, 0x78, 0x74, 0x2f,
+	0x76, 0x31, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10,
+	0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e, 0x76, 0x31,
+	0x22, 0xaa, 0x03, 0x0a, 0x0c, 0x53, 0x65, 0x72, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x72, 0x67, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x04, 0x61, 0x72, 0x67, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x12, 0x1f, 0x0a, 0x0b, 0x63,
+	0x6c, 0x6f, 0x73, 0x65, 0x5f, 0x73, 0x74, 0x64, 0x69, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x0a, 0x63, 0x6c, 0x6f, 0x73, 0x65, 0x53, 0x74, 0x64, 0x69, 0x6e, 0x12, 0x16, 0x0a, 0x06,
+	0x63, 0x61, 0x6e, 0x63, 0x65, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x63, 0x61,
+	0x6e, 0x63, 0x65, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x69, 0x6f,
+	0x72, 0x69, 0x74, 0x79, 0x18, 0x07, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x72, 0x69, 0x6f,
+	0x72, 0x69, 0x74, 0x79, 0x12, 0x5e, 0x0a, 0x10, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f,
+	0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x33,
+	0x2e, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x53,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x0f, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x12, 0x2e, 0x0a, 0x13, 0x66, 0x6c, 0x6f, 0x77, 0x5f, 0x63, 0x6f, 0x6e,
+	0x74, 0x72, 0x6f, 0x6c, 0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x09, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x11, 0x66, 0x6c, 0x6f, 0x77, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x57, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x12, 0x23, 0x0a, 0x0d, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x75,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x1a, 0x42, 0x0a, 0x14, 0x53, 0x65, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xe9, 0x01,
+	0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x16, 0x0a, 0x06, 0x73, 0x74, 0x64, 0x6f, 0x75, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x73, 0x74, 0x64, 0x6f, 0x75, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x64, 0x65, 0x72,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x73, 0x74, 0x64, 0x65, 0x72, 0x72, 0x12,
+	0x12, 0x0a, 0x04, 0x64, 0x6f, 0x6e, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x64,
+	0x6f, 0x6e, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x65, 0x78, 0x69, 0x74, 0x5f, 0x63, 0x6f, 0x64, 0x65,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x65, 0x78, 0x69, 0x74, 0x43, 0x6f, 0x64, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x70, 0x6f, 0x6e, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04,
+	0x70, 0x6f, 0x6e, 0x67, 0x12, 0x2e, 0x0a, 0x13, 0x66, 0x6c, 0x6f, 0x77, 0x5f, 0x63, 0x6f, 0x6e,
+	0x74, 0x72, 0x6f, 0x6c, 0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x11, 0x66, 0x6c, 0x6f, 0x77, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x57, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x12, 0x23, 0x0a, 0x0d, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x5f, 0x75,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0xc1, 0x01, 0x0a, 0x14, 0x63, 0x6f,
+	0x6d, 0x2e, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x65, 0x78, 0x74, 0x2e,
+	0x76, 0x31, 0x42, 0x0a, 0x53, 0x65, 0x72, 0x76, 0x65, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01,
+	0x5a, 0x3b, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e, 0x63, 0x6f, 0x6d, 0x2f,
+	0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e,
+	0x61, 0x6c, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x70, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63,
+	0x2f, 0x65, 0x78, 0x74, 0x2f, 0x76, 0x31, 0x3b, 0x65, 0x78, 0x74, 0x76, 0x31, 0xa2, 0x02, 0x03,
+	0x50, 0x45, 0x58, 0xaa, 0x02, 0x10, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72, 0x70, 0x63, 0x2e,
+	0x45, 0x78, 0x74, 0x2e, 0x56, 0x31, 0xca, 0x02, 0x10, 0x50, 0x6c, 0x75, 0x67, 0x69, 0x6e, 0x72,
+	0x70, 0x63, 0x5c, 0x45, 0x78, 0x74, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x1c, 0x50, 0x6c, 0x75, 0x67,
+	0x69, 0x6e, 0x72, 0x70, 0x63, 0x5c, 0x45, 0x78, 0x74, 0x5c, 0x56, 0x31, 0x5c, 0x47, 0x50, 0x42,
+	0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x12, 0x50, 0x6c, 0x75, 0x67, 0x69,
+	0x6e, 0x72, 0x70, 0x63, 0x3a, 0x3a, 0x45, 0x78, 0x74, 0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pluginrpc_ext_v1_serve_proto_rawDescOnce sync.Once
+	file_pluginrpc_ext_v1_serve_proto_rawDescData = file_pluginrpc_ext_v1_serve_proto_rawDesc
+)
+
+func file_pluginrpc_ext_v1_serve_proto_rawDescGZIP() []byte {
+	file_pluginrpc_ext_v1_serve_proto_rawDescOnce.Do(func() {
+		file_pluginrpc_ext_v1_serve_proto_rawDescData = protoimpl.X.CompressGZIP(file_pluginrpc_ext_v1_serve_proto_rawDescData)
+	})
+	return file_pluginrpc_ext_v1_serve_proto_rawDescData
+}
+
+var file_pluginrpc_ext_v1_serve_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_pluginrpc_ext_v1_serve_proto_goTypes = []any{
+	(*ServeRequest)(nil),  // 0: pluginrpc.ext.v1.ServeRequest
+	(*ServeResponse)(nil), // 1: pluginrpc.ext.v1.ServeResponse
+	nil,                   // 2: pluginrpc.ext.v1.ServeRequest.SessionMetadataEntry
+}
+var file_pluginrpc_ext_v1_serve_proto_depIdxs = []int32{
+	2, // 0: pluginrpc.ext.v1.ServeRequest.session_metadata:type_name -> pluginrpc.ext.v1.ServeRequest.SessionMetadataEntry
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_pluginrpc_ext_v1_serve_proto_init() }
+func file_pluginrpc_ext_v1_serve_proto_init() {
+	if File_pluginrpc_ext_v1_serve_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pluginrpc_ext_v1_serve_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ServeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pluginrpc_ext_v1_serve_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*ServeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pluginrpc_ext_v1_serve_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_pluginrpc_ext_v1_serve_proto_goTypes,
+		DependencyIndexes: file_pluginrpc_ext_v1_serve_proto_depIdxs,
+		MessageInfos:      file_pluginrpc_ext_v1_serve_proto_msgTypes,
+	}.Build()
+	File_pluginrpc_ext_v1_serve_proto = out.File
+	file_pluginrpc_ext_v1_serve_proto_rawDesc = nil
+	file_pluginrpc_ext_v1_serve_proto_goTypes = nil
+	file_pluginrpc_ext_v1_serve_proto_depIdxs = nil
+}
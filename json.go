@@ -0,0 +1,348 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// NewRequestForJSON returns a new request for the given hand-written JSON, using the given
+// MessageDescriptor of the request type.
+//
+// This is useful for exploratory tooling that does not have generated types for a
+// Procedure, but does have its descriptors, for example from a FileDescriptorSet. The JSON
+// is validated against the descriptor before it is unmarshaled, and every unknown field
+// and value of the wrong type is reported with its path within the request, for example
+// `.items[1].count: expected a number, got a string`. This surfaces mistakes before the
+// request is sent, instead of as an unmarshal error from the plugin.
+//
+// Empty data results in an empty request.
+func NewRequestForJSON(data []byte, requestDescriptor protoreflect.MessageDescriptor) (proto.Message, error) {
+	if requestDescriptor == nil {
+		return nil, errors.New("request MessageDescriptor is nil")
+	}
+	request := dynamicpb.NewMessage(requestDescriptor)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return request, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON for %s: %w", requestDescriptor.FullName(), err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid JSON for %s: unexpected data after the top-level value", requestDescriptor.FullName())
+	}
+	var jsonErrs []error
+	validateJSONMessage(value, requestDescriptor, "", &jsonErrs)
+	if len(jsonErrs) > 0 {
+		return nil, fmt.Errorf("invalid JSON for %s: %w", requestDescriptor.FullName(), errors.Join(jsonErrs...))
+	}
+	// Validation does not cover everything, for example the formats of well-known types,
+	// so protojson may still return an error.
+	if err := protojson.Unmarshal(data, request); err != nil {
+		return nil, fmt.Errorf("invalid JSON for %s: %w", requestDescriptor.FullName(), err)
+	}
+	return request, nil
+}
+
+// *** PRIVATE ***
+
+// validateJSONMessage validates the decoded JSON value against the MessageDescriptor,
+// appending errors to jsonErrs.
+//
+// Well-known types have special JSON representations, and are left to protojson.
+func validateJSONMessage(value any, messageDescriptor protoreflect.MessageDescriptor, path string, jsonErrs *[]error) {
+	if value == nil || messageDescriptor.FullName().Parent() == "google.protobuf" {
+		return
+	}
+	object, ok := value.(map[string]any)
+	if !ok {
+		*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected an object for %s, got %s", messageDescriptor.FullName(), jsonTypeString(value)))
+		return
+	}
+	fields := messageDescriptor.Fields()
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fieldDescriptor := fields.ByJSONName(key)
+		if fieldDescriptor == nil {
+			fieldDescriptor = fields.ByTextName(key)
+		}
+		fieldPath := path + "." + key
+		if fieldDescriptor == nil {
+			*jsonErrs = append(*jsonErrs, newJSONPathError(fieldPath, "unknown field, expected one of [%s]", jsonFieldNamesString(fields)))
+			continue
+		}
+		validateJSONField(object[key], fieldDescriptor, fieldPath, jsonErrs)
+	}
+}
+
+func validateJSONField(value any, fieldDescriptor protoreflect.FieldDescriptor, path string, jsonErrs *[]error) {
+	if value == nil {
+		return
+	}
+	switch {
+	case fieldDescriptor.IsMap():
+		object, ok := value.(map[string]any)
+		if !ok {
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected an object, got %s", jsonTypeString(value)))
+			return
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			validateJSONSingular(object[key], fieldDescriptor.MapValue(), path+"["+strconv.Quote(key)+"]", jsonErrs)
+		}
+	case fieldDescriptor.IsList():
+		array, ok := value.([]any)
+		if !ok {
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected an array, got %s", jsonTypeString(value)))
+			return
+		}
+		for i, element := range array {
+			validateJSONSingular(element, fieldDescriptor, path+"["+strconv.Itoa(i)+"]", jsonErrs)
+		}
+	default:
+		validateJSONSingular(value, fieldDescriptor, path, jsonErrs)
+	}
+}
+
+func validateJSONSingular(value any, fieldDescriptor protoreflect.FieldDescriptor, path string, jsonErrs *[]error) {
+	if value == nil {
+		return
+	}
+	switch kind := fieldDescriptor.Kind(); kind {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		validateJSONMessage(value, fieldDescriptor.Message(), path, jsonErrs)
+	case protoreflect.EnumKind:
+		if fieldDescriptor.Enum().FullName() == "google.protobuf.NullValue" {
+			return
+		}
+		switch typedValue := value.(type) {
+		case string:
+			if fieldDescriptor.Enum().Values().ByName(protoreflect.Name(typedValue)) == nil {
+				*jsonErrs = append(*jsonErrs, newJSONPathError(path, "unknown value %q for enum %s", typedValue, fieldDescriptor.Enum().FullName()))
+			}
+		case json.Number:
+			if _, err := typedValue.Int64(); err != nil {
+				*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected an integer for enum %s, got %s", fieldDescriptor.Enum().FullName(), typedValue))
+			}
+		default:
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected a string or number for enum %s, got %s", fieldDescriptor.Enum().FullName(), jsonTypeString(value)))
+		}
+	case protoreflect.BoolKind:
+		if _, ok := value.(bool); !ok {
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected a boolean, got %s", jsonTypeString(value)))
+		}
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		if _, ok := value.(string); !ok {
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected a string, got %s", jsonTypeString(value)))
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		switch typedValue := value.(type) {
+		case json.Number:
+		case string:
+			// Special values and numbers are allowed as strings.
+			if _, err := strconv.ParseFloat(typedValue, 64); err != nil {
+				*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected a number, got string %q", typedValue))
+			}
+		default:
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected a number, got %s", jsonTypeString(value)))
+		}
+	default:
+		// All remaining kinds are integers, which may also be given as strings.
+		var numberString string
+		switch typedValue := value.(type) {
+		case json.Number:
+			numberString = typedValue.String()
+		case string:
+			numberString = typedValue
+		default:
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "expected an integer, got %s", jsonTypeString(value)))
+			return
+		}
+		if err := validateJSONInteger(numberString, kind); err != nil {
+			*jsonErrs = append(*jsonErrs, newJSONPathError(path, "%v", err))
+		}
+	}
+}
+
+// validateJSONInteger validates that the string is an integer within the range of the kind.
+//
+// Integers may be given in exponent notation such as 1e3, as long as they are whole numbers.
+func validateJSONInteger(numberString string, kind protoreflect.Kind) error {
+	bitSize := 64
+	switch kind {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		bitSize = 32
+	}
+	unsigned := kind == protoreflect.Uint32Kind || kind == protoreflect.Fixed32Kind ||
+		kind == protoreflect.Uint64Kind || kind == protoreflect.Fixed64Kind
+	var err error
+	if unsigned {
+		_, err = strconv.ParseUint(numberString, 10, bitSize)
+	} else {
+		_, err = strconv.ParseInt(numberString, 10, bitSize)
+	}
+	if err == nil {
+		return nil
+	}
+	if floatValue, floatErr := strconv.ParseFloat(numberString, 64); floatErr == nil && floatValue == float64(int64(floatValue)) {
+		// Let protojson validate the range of whole numbers in exponent notation.
+		return nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("integer %s is out of range for %s", numberString, kind)
+	}
+	return fmt.Errorf("expected an integer, got %q", numberString)
+}
+
+func newJSONPathError(path string, format string, args ...any) error {
+	if path == "" {
+		path = "."
+	}
+	return fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...))
+}
+
+func jsonFieldNamesString(fields protoreflect.FieldDescriptors) string {
+	names := make([]string, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		names[i] = strconv.Quote(fields.Get(i).JSONName())
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// jsonTypeString returns a description of the type of the decoded JSON value for errors.
+func jsonTypeString(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// newExampleRequestJSON returns an example request in the JSON format for the
+// MessageDescriptor, with every field set to an example value.
+//
+// This is used in the help of Procedures, see getProcedureUsage.
+func newExampleRequestJSON(requestDescriptor protoreflect.MessageDescriptor) ([]byte, error) {
+	message := dynamicpb.NewMessage(requestDescriptor)
+	populateExampleMessage(message, make(map[protoreflect.FullName]struct{}))
+	return protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(message)
+}
+
+// populateExampleMessage sets the fields of the message to example values. Scalar fields
+// without presence are left unset, and are emitted with their zero values.
+//
+// Only the first field of each oneof is set. Recursive messages are set until a message
+// is seen again, and well-known types are left to their JSON representations of their
+// empty values.
+func populateExampleMessage(message protoreflect.Message, seen map[protoreflect.FullName]struct{}) {
+	messageDescriptor := message.Descriptor()
+	if messageDescriptor.FullName().Parent() == "google.protobuf" {
+		return
+	}
+	seen[messageDescriptor.FullName()] = struct{}{}
+	defer delete(seen, messageDescriptor.FullName())
+	fields := messageDescriptor.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() && oneof.Fields().Get(0) != field {
+			continue
+		}
+		switch {
+		case field.IsMap():
+			mapValue := message.Mutable(field).Map()
+			mapKey := newExampleScalarValue(field.MapKey()).MapKey()
+			if field.MapValue().Message() == nil {
+				mapValue.Set(mapKey, newExampleScalarValue(field.MapValue()))
+			} else if isExampleMessageAllowed(field.MapValue().Message(), seen) {
+				populateExampleMessage(mapValue.Mutable(mapKey).Message(), seen)
+			}
+		case field.IsList():
+			listValue := message.Mutable(field).List()
+			if field.Message() == nil {
+				listValue.Append(newExampleScalarValue(field))
+			} else if isExampleMessageAllowed(field.Message(), seen) {
+				populateExampleMessage(listValue.AppendMutable().Message(), seen)
+			}
+		case field.Message() != nil:
+			if isExampleMessageAllowed(field.Message(), seen) {
+				populateExampleMessage(message.Mutable(field).Message(), seen)
+			}
+		case field.HasPresence():
+			// Unset fields with presence, such as oneof fields, are not emitted as their zero
+			// values, so they are set explicitly.
+			message.Set(field, newExampleScalarValue(field))
+		}
+	}
+}
+
+// isExampleMessageAllowed returns true if an example of the message can be set.
+//
+// google.protobuf.Value has no valid JSON representation when empty.
+func isExampleMessageAllowed(messageDescriptor protoreflect.MessageDescriptor, seen map[protoreflect.FullName]struct{}) bool {
+	if messageDescriptor.FullName() == "google.protobuf.Value" {
+		return false
+	}
+	_, ok := seen[messageDescriptor.FullName()]
+	return !ok
+}
+
+// newExampleScalarValue returns the example value for a scalar field.
+//
+// This is the default value of the field, or the first value of an enum.
+func newExampleScalarValue(field protoreflect.FieldDescriptor) protoreflect.Value {
+	if field.Kind() == protoreflect.EnumKind {
+		if values := field.Enum().Values(); values.Len() > 0 {
+			return protoreflect.ValueOfEnum(values.Get(0).Number())
+		}
+	}
+	if field.Kind() == protoreflect.BytesKind {
+		return protoreflect.ValueOfBytes([]byte{})
+	}
+	return field.Default()
+}
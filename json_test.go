@@ -0,0 +1,65 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"testing"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+)
+
+func TestNewRequestForJSON(t *testing.T) {
+	t.Parallel()
+
+	specDescriptor := (&pluginrpcv1.Spec{}).ProtoReflect().Descriptor()
+	request, err := pluginrpc.NewRequestForJSON(
+		[]byte(`{"procedures":[{"path":"/foo/bar","args":["foo"]}]}`),
+		specDescriptor,
+	)
+	require.NoError(t, err)
+	spec := &pluginrpcv1.Spec{}
+	data, err := proto.Marshal(request)
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(data, spec))
+	require.Equal(t, "/foo/bar", spec.GetProcedures()[0].GetPath())
+	require.Equal(t, []string{"foo"}, spec.GetProcedures()[0].GetArgs())
+
+	request, err = pluginrpc.NewRequestForJSON(nil, specDescriptor)
+	require.NoError(t, err)
+	require.Equal(t, 0, proto.Size(request))
+
+	_, err = pluginrpc.NewRequestForJSON(
+		[]byte(`{"procedures":[{"path":"/foo/bar"},{"path":1,"arg":["foo"]}],"extra":true}`),
+		specDescriptor,
+	)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `.extra: unknown field, expected one of ["procedures"]`)
+	require.Contains(t, err.Error(), `.procedures[1].arg: unknown field, expected one of ["args", "path"]`)
+	require.Contains(t, err.Error(), `.procedures[1].path: expected a string, got a number`)
+
+	echoErrorRequestDescriptor := (&examplev1.EchoErrorRequest{}).ProtoReflect().Descriptor()
+	_, err = pluginrpc.NewRequestForJSON([]byte(`{"code":"CODE_NOT_FOUND","message":"foo"}`), echoErrorRequestDescriptor)
+	require.NoError(t, err)
+	_, err = pluginrpc.NewRequestForJSON([]byte(`{"code":"NOT_A_CODE"}`), echoErrorRequestDescriptor)
+	require.ErrorContains(t, err, `.code: unknown value "NOT_A_CODE" for enum pluginrpc.v1.Code`)
+	_, err = pluginrpc.NewRequestForJSON([]byte(`[]`), echoErrorRequestDescriptor)
+	require.ErrorContains(t, err, `.: expected an object for pluginrpc.example.v1.EchoErrorRequest, got an array`)
+	_, err = pluginrpc.NewRequestForJSON([]byte(`{} {}`), echoErrorRequestDescriptor)
+	require.ErrorContains(t, err, `unexpected data after the top-level value`)
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"errors"
+)
+
+// limitedBuffer is a buffer for the response of a plugin on stdout that fails writes once
+// the response exceeds maxSize, calling onExceeded so that the plugin can be stopped.
+//
+// If maxSize is not positive, the buffer is not limited.
+//
+// The buffer is not embedded, as os/exec would bypass Write with bytes.Buffer.ReadFrom.
+type limitedBuffer struct {
+	buffer     bytes.Buffer
+	maxSize    int64
+	onExceeded func()
+	exceeded   bool
+}
+
+func newLimitedBuffer(maxSize int64, onExceeded func()) *limitedBuffer {
+	return &limitedBuffer{
+		maxSize:    maxSize,
+		onExceeded: onExceeded,
+	}
+}
+
+func (l *limitedBuffer) Write(data []byte) (int, error) {
+	if l.exceeded {
+		return 0, errResponseSizeExceeded
+	}
+	if l.maxSize > 0 && int64(l.buffer.Len())+int64(len(data)) > l.maxSize {
+		l.exceeded = true
+		l.onExceeded()
+		return 0, errResponseSizeExceeded
+	}
+	return l.buffer.Write(data)
+}
+
+// Bytes returns the bytes written.
+func (l *limitedBuffer) Bytes() []byte {
+	return l.buffer.Bytes()
+}
+
+// exceededErr returns an error with CodeResourceExhausted if the response exceeded the
+// maximum size.
+//
+// Returns nil if the response did not exceed the maximum size.
+func (l *limitedBuffer) exceededErr() error {
+	if !l.exceeded {
+		return nil
+	}
+	return NewErrorf(CodeResourceExhausted, "response exceeds maximum size of %d bytes", l.maxSize)
+}
+
+// errResponseSizeExceeded is returned from writes to a limitedBuffer after the response
+// exceeded the maximum size.
+var errResponseSizeExceeded = errors.New("response exceeds maximum size")
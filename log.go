@@ -0,0 +1,183 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// *** PRIVATE ***
+
+// callLogger logs invocations of a plugin by a Client at debug level.
+//
+// A nil *callLogger does nothing.
+type callLogger struct {
+	logger   *slog.Logger
+	format   Format
+	redactor Redactor
+}
+
+func newCallLogger(logger *slog.Logger, format Format, redactor Redactor) *callLogger {
+	if logger == nil {
+		return nil
+	}
+	return &callLogger{
+		logger:   logger,
+		format:   format,
+		redactor: redactor,
+	}
+}
+
+// start logs the start of an invocation, and returns the loggedCall to finish.
+//
+// procedurePath is empty for invocations that do not call a Procedure, for example to
+// retrieve the Spec.
+func (c *callLogger) start(ctx context.Context, procedurePath string, args []string) *loggedCall {
+	if c == nil {
+		return nil
+	}
+	var attrs []any
+	if procedurePath != "" {
+		attrs = append(attrs, slog.String("procedure", procedurePath))
+	}
+	attrs = append(attrs, slog.Any("args", maskMetadataArgs(args)), slog.String("format", c.format.String()))
+	loggedCall := &loggedCall{
+		ctx:      ctx,
+		start:    time.Now(),
+		attrs:    attrs,
+		logger:   c.logger,
+		redactor: c.redactor,
+	}
+	c.logger.DebugContext(ctx, "pluginrpc call started", loggedCall.attrs...)
+	return loggedCall
+}
+
+// warnStdoutNoise logs a warning that the given description of noise written by a plugin
+// to stdout before its output was skipped.
+func (c *callLogger) warnStdoutNoise(ctx context.Context, noise string) {
+	if c == nil {
+		return
+	}
+	c.logger.WarnContext(ctx, "pluginrpc skipped unexpected prefix on plugin stdout", slog.String("prefix", noise))
+}
+
+// loggedCall is a single invocation being logged.
+//
+// A nil *loggedCall does nothing.
+type loggedCall struct {
+	ctx      context.Context
+	start    time.Time
+	attrs    []any
+	logger   *slog.Logger
+	redactor Redactor
+}
+
+// finish logs the end of the invocation given the error it resulted in.
+func (l *loggedCall) finish(err error) {
+	if l == nil {
+		return
+	}
+	l.logger.DebugContext(l.ctx, "pluginrpc call finished", append(l.attrs, resultLogAttrs(time.Since(l.start), err, l.redactor)...)...)
+}
+
+// servedCall records a single invocation of a Server being logged.
+//
+// The servedCall is contained within the context of the invocation, so that the
+// Procedure and the errors returned by Handlers can be recorded.
+type servedCall struct {
+	procedurePath string
+	format        Format
+	// handleErr is the error written to the response, if any.
+	handleErr error
+}
+
+type servedCallContextKey struct{}
+
+func withServedCall(ctx context.Context, servedCall *servedCall) context.Context {
+	return context.WithValue(ctx, servedCallContextKey{}, servedCall)
+}
+
+// setServedCallProcedure records the Procedure being handled, if the invocation is logged.
+func setServedCallProcedure(ctx context.Context, procedurePath string, format Format) {
+	if servedCall, ok := ctx.Value(servedCallContextKey{}).(*servedCall); ok {
+		servedCall.procedurePath = procedurePath
+		servedCall.format = format
+	}
+}
+
+// setServedCallHandleErr records the error written to the response, if the invocation is logged.
+func setServedCallHandleErr(ctx context.Context, handleErr error) {
+	if servedCall, ok := ctx.Value(servedCallContextKey{}).(*servedCall); ok {
+		servedCall.handleErr = handleErr
+	}
+}
+
+// logServe serves the invocation with the given function, logging the invocation at
+// debug level if the logger is not nil.
+//
+// The details of errors are redacted with the given Redactor.
+func logServe(ctx context.Context, logger *slog.Logger, redactor Redactor, env Env, serve func(context.Context) error) error {
+	if logger == nil {
+		return serve(ctx)
+	}
+	logger.DebugContext(ctx, "pluginrpc serve started", slog.Any("args", maskMetadataArgs(env.Args)))
+	servedCall := &servedCall{}
+	start := time.Now()
+	err := serve(withServedCall(ctx, servedCall))
+	attrs := []any{
+		slog.Any("args", maskMetadataArgs(env.Args)),
+	}
+	if servedCall.procedurePath != "" {
+		attrs = append(
+			attrs,
+			slog.String("procedure", servedCall.procedurePath),
+			slog.String("format", servedCall.format.String()),
+		)
+	}
+	resultErr := err
+	if resultErr == nil {
+		resultErr = servedCall.handleErr
+	}
+	logger.DebugContext(ctx, "pluginrpc serve finished", append(attrs, resultLogAttrs(time.Since(start), resultErr, redactor)...)...)
+	return err
+}
+
+// resultLogAttrs returns the attributes for the result of an invocation.
+//
+// The details of the error, if any, are redacted with the given Redactor.
+func resultLogAttrs(duration time.Duration, err error, redactor Redactor) []any {
+	attrs := []any{
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("code", WrapError(err).Code().String()))
+		exitError := &ExitError{}
+		if errors.As(err, &exitError) {
+			attrs = append(attrs, slog.Int("exit_code", exitError.ExitCode()))
+		}
+		attrs = append(attrs, slog.String("error", err.Error()))
+		if details := redactedErrorDetailsJSON(redactor, err); len(details) > 0 {
+			detailStrings := make([]string, len(details))
+			for i, detail := range details {
+				detailStrings[i] = string(detail)
+			}
+			attrs = append(attrs, slog.Any("details", detailStrings))
+		}
+	}
+	return attrs
+}
@@ -20,46 +20,126 @@ import (
 	"os/signal"
 )
 
-var interruptSignals = append(
-	[]os.Signal{
-		os.Interrupt,
-	},
-	extraInterruptSignals...,
-)
-
 // Main is a convenience function that will run the server within a main
 // function with the proper semantics.
 //
 // All registration should already be complete before passing the Server to this function.
 //
+// Main calls os.Exit on error, and therefore never runs deferred functions of the caller.
+// Use Run to embed the server loop in larger programs, or to test it.
+//
 //	func main() {
 //		pluginrpc.Main(newServer)
 //	}
 //
 //	func newServer() (pluginrpc.Server, error) {
-//		spec, err := examplev1pluginrpc.EchoServiceSpecBuilder{
-//			EchoRequest: []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("echo", "request")},
-//			EchoError:   []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("echo", "error")},
-//		}.Build()
-//		if err != nil {
-//			return nil, err
-//		}
-//		serverRegistrar := pluginrpc.NewServerRegistrar()
-//		echoServiceServer := examplev1pluginrpc.NewEchoServiceServer(pluginrpc.NewHandler(spec), echoServiceHandler{})
-//		examplev1pluginrpc.RegisterEchoServiceServer(serverRegistrar, echoServiceServer)
-//		return pluginrpc.NewServer(spec, serverRegistrar)
+//		return examplev1pluginrpc.NewEchoServiceServerForHandler(echoServiceHandler{})
 //	}
-func Main(newServer func() (Server, error), _ ...MainOption) {
-	ctx, cancel := withCancelInterruptSignal(context.Background())
+func Main(newServer func() (Server, error), options ...MainOption) {
+	mainOptions := newMainOptions()
+	for _, option := range options {
+		option(mainOptions)
+	}
+	ctx, cancel := withCancelSignals(mainOptions.ctx, mainOptions.signalStrategy.Signals())
 	defer cancel()
+	handleServerMainError(Run(ctx, newServer, mainOptions.env))
+}
+
+// MainService is a convenience function that will run a Server for the given service
+// within a main function, see Main.
+//
+// The Spec and ServerRegistrar are assembled from the registration, see
+// NewServerForServices. A plugin with a single service is then written as:
+//
+//	func main() {
+//		pluginrpc.MainService(examplev1pluginrpc.EchoServiceRegistration{Handler: echoServiceHandler{}})
+//	}
+//
+// Use Main with NewServerForServices to serve multiple services, or to customize the Spec,
+// Handler, or Server.
+func MainService(registration ServiceRegistration, options ...MainOption) {
+	Main(
+		func() (Server, error) {
+			return NewServerForServices([]ServiceRegistration{registration})
+		},
+		options...,
+	)
+}
+
+// Run constructs the Server with newServer and serves it with the given Env.
+//
+// This is what Main does, however Run returns the error instead of exiting, and does not
+// handle interrupt signals. Callers should cancel ctx as appropriate. The exit code
+// that Main would use for a returned error is available via WrapExitError.
+func Run(ctx context.Context, newServer func() (Server, error), env Env) error {
 	server, err := newServer()
-	handleServerMainError(err)
-	handleServerMainError(server.Serve(ctx, OSEnv))
+	if err != nil {
+		return err
+	}
+	return server.Serve(ctx, env)
 }
 
 // MainOption is an option for Main.
 type MainOption func(*mainOptions)
 
+// MainWithContext returns a new MainOption that serves the plugin with the given context.
+//
+// This allows programs that embed a plugin to tie serving to their own lifecycle. The
+// context passed to the Server is also cancelled if interrupt signals are sent.
+//
+// The default is context.Background().
+func MainWithContext(ctx context.Context) MainOption {
+	return func(mainOptions *mainOptions) {
+		if ctx != nil {
+			mainOptions.ctx = ctx
+		}
+	}
+}
+
+// MainWithSignals returns a new MainOption that cancels the context passed to the Server
+// when any of the given signals are sent.
+//
+// If no signals are given, signals are not handled, for example to integrate with a
+// signal manager of the program that cancels the context given with MainWithContext.
+//
+// This is equivalent to MainWithSignalStrategy(SignalStrategyForSignals(signals...)).
+//
+// The default is NewOSSignalStrategy().
+func MainWithSignals(signals ...os.Signal) MainOption {
+	return MainWithSignalStrategy(SignalStrategyForSignals(signals...))
+}
+
+// MainWithSignalStrategy returns a new MainOption that cancels the context passed to the
+// Server when any of the signals selected by the SignalStrategy are sent.
+//
+// This allows programs that embed a plugin to align its shutdown with their own signal
+// semantics, for example to also shut down on Windows console control events:
+//
+//	pluginrpc.Main(
+//		newServer,
+//		pluginrpc.MainWithSignalStrategy(
+//			pluginrpc.NewOSSignalStrategy(pluginrpc.OSSignalStrategyWithConsoleCtrlEvents()),
+//		),
+//	)
+//
+// The default is NewOSSignalStrategy().
+func MainWithSignalStrategy(signalStrategy SignalStrategy) MainOption {
+	return func(mainOptions *mainOptions) {
+		if signalStrategy != nil {
+			mainOptions.signalStrategy = signalStrategy
+		}
+	}
+}
+
+// MainWithEnv returns a new MainOption that serves the plugin with the given Env.
+//
+// The default is OSEnv.
+func MainWithEnv(env Env) MainOption {
+	return func(mainOptions *mainOptions) {
+		mainOptions.env = env
+	}
+}
+
 // *** PRIVATE ***
 
 func handleServerMainError(err error) {
@@ -71,28 +151,49 @@ func handleServerMainError(err error) {
 	}
 }
 
-// withCancelInterruptSignal returns a context that is cancelled if interrupt signals are sent.
-func withCancelInterruptSignal(ctx context.Context) (context.Context, context.CancelFunc) {
-	interruptSignalC, closer := newInterruptSignalChannel()
+// withCancelSignals returns a context that is cancelled if any of the signals are sent.
+//
+// If there are no signals, this returns a context that is only cancelled by the returned
+// function.
+func withCancelSignals(ctx context.Context, signals []os.Signal) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(ctx)
+	if len(signals) == 0 {
+		return ctx, cancel
+	}
+	signalC, closer := newSignalChannel(signals)
 	go func() {
-		<-interruptSignalC
+		select {
+		case <-signalC:
+		case <-ctx.Done():
+		}
 		closer()
 		cancel()
 	}()
 	return ctx, cancel
 }
 
-// newInterruptSignalChannel returns a new channel for interrupt signals.
+// newSignalChannel returns a new channel for the signals.
 //
 // Call the returned function to cancel sending to this channel.
-func newInterruptSignalChannel() (<-chan os.Signal, func()) {
+func newSignalChannel(signals []os.Signal) (<-chan os.Signal, func()) {
 	signalC := make(chan os.Signal, 1)
-	signal.Notify(signalC, interruptSignals...)
+	signal.Notify(signalC, signals...)
 	return signalC, func() {
 		signal.Stop(signalC)
 		close(signalC)
 	}
 }
 
-type mainOptions struct{}
+type mainOptions struct {
+	ctx            context.Context
+	signalStrategy SignalStrategy
+	env            Env
+}
+
+func newMainOptions() *mainOptions {
+	return &mainOptions{
+		ctx:            context.Background(),
+		signalStrategy: NewOSSignalStrategy(),
+		env:            OSEnv,
+	}
+}
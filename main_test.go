@@ -0,0 +1,149 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	env, err := NewEnv(EnvWithArgs("--"+SpecFlagName), EnvWithStdout(bytes.NewBuffer(nil)))
+	require.NoError(t, err)
+	require.NoError(t, Run(context.Background(), newMainTestServer, env))
+	env, err = NewEnv(EnvWithArgs("/foo/bar"))
+	require.NoError(t, err)
+	err = Run(context.Background(), newMainTestServer, env)
+	require.Error(t, err)
+	require.Equal(t, 3, WrapExitError(err).ExitCode())
+
+	err = Run(
+		context.Background(),
+		func() (Server, error) {
+			return nil, errors.New("bar")
+		},
+		env,
+	)
+	require.EqualError(t, err, "bar")
+}
+
+func TestMainWithEnv(t *testing.T) {
+	t.Parallel()
+
+	stdout := bytes.NewBuffer(nil)
+	env, err := NewEnv(EnvWithArgs("--"+ProtocolFlagName), EnvWithStdout(stdout))
+	require.NoError(t, err)
+	// Main only exits on error.
+	Main(
+		newMainTestServer,
+		MainWithContext(context.Background()),
+		MainWithSignals(),
+		MainWithEnv(env),
+	)
+	require.Equal(t, "1\n", stdout.String())
+}
+
+func TestMainServiceWithEnv(t *testing.T) {
+	t.Parallel()
+
+	stdout := bytes.NewBuffer(nil)
+	env, err := NewEnv(EnvWithArgs("--"+ProtocolFlagName), EnvWithStdout(stdout))
+	require.NoError(t, err)
+	MainService(
+		mainTestServiceRegistration{},
+		MainWithSignals(),
+		MainWithEnv(env),
+	)
+	require.Equal(t, "1\n", stdout.String())
+}
+
+func TestWithCancelSignals(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := withCancelSignals(context.Background(), nil)
+	require.NoError(t, ctx.Err())
+	cancel()
+	require.Error(t, ctx.Err())
+
+	ctx, cancel = withCancelSignals(context.Background(), NewOSSignalStrategy().Signals())
+	require.NoError(t, ctx.Err())
+	cancel()
+	<-ctx.Done()
+}
+
+func TestSignalStrategy(t *testing.T) {
+	t.Parallel()
+
+	signals := NewOSSignalStrategy().Signals()
+	require.Contains(t, signals, os.Interrupt)
+	if runtime.GOOS == "windows" {
+		require.Equal(t, []os.Signal{os.Interrupt}, signals)
+	} else {
+		require.Contains(t, signals, syscall.SIGTERM)
+	}
+	require.Contains(t, NewOSSignalStrategy(OSSignalStrategyWithConsoleCtrlEvents()).Signals(), syscall.SIGTERM)
+	require.Contains(t, NewOSSignalStrategy(OSSignalStrategyWithExtraSignals(os.Kill)).Signals(), os.Kill)
+	require.Empty(t, SignalStrategyForSignals().Signals())
+	require.Equal(t, []os.Signal{os.Kill}, SignalStrategyForSignals(os.Kill).Signals())
+}
+
+// mainTestServiceRegistration is a ServiceRegistration for a service with a single Procedure
+// that does nothing.
+type mainTestServiceRegistration struct{}
+
+func (mainTestServiceRegistration) BuildSpec() (Spec, error) {
+	procedure, err := NewProcedure("/foo/bar")
+	if err != nil {
+		return nil, err
+	}
+	return NewSpec(procedure)
+}
+
+func (mainTestServiceRegistration) Register(serverRegistrar ServerRegistrar, _ Handler) {
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return nil
+		},
+	)
+}
+
+func newMainTestServer() (Server, error) {
+	procedure, err := NewProcedure("/foo/bar")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := NewSpec(procedure)
+	if err != nil {
+		return nil, err
+	}
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return NewExitError(3, errors.New("foo"))
+		},
+	)
+	return NewServer(spec, serverRegistrar)
+}
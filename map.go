@@ -0,0 +1,83 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// CallForMap calls the given Procedure path and decodes the response into a map.
+//
+// This is useful for exploratory tooling that does not have generated types for a
+// Procedure, but does have its descriptors, for example from a FileDescriptorSet.
+// The response is decoded using the given MessageDescriptor, and then converted to
+// a map with ProtoMessageToMap.
+//
+// If the call results in an error with a partial result, both the map and the error
+// are returned. See ErrorWithPartialResult.
+func CallForMap(
+	ctx context.Context,
+	client Client,
+	procedurePath string,
+	request any,
+	responseDescriptor protoreflect.MessageDescriptor,
+	options ...CallOption,
+) (map[string]any, error) {
+	if responseDescriptor == nil {
+		return nil, errors.New("response MessageDescriptor is nil")
+	}
+	response := dynamicpb.NewMessage(responseDescriptor)
+	if err := client.Call(ctx, procedurePath, request, response, options...); err != nil {
+		if HasPartialResult(err) {
+			m, mapErr := ProtoMessageToMap(response)
+			if mapErr != nil {
+				return nil, errors.Join(err, mapErr)
+			}
+			return m, err
+		}
+		return nil, err
+	}
+	return ProtoMessageToMap(response)
+}
+
+// ProtoMessageToMap converts the proto.Message into a map.
+//
+// The map has the same structure as the protojson representation of the message,
+// as decoded by encoding/json. That is, keys are the JSON names of fields, 64-bit
+// integers are strings, other numbers are float64s, and so on. Fields with default
+// values are omitted.
+//
+// A nil message results in an empty map.
+func ProtoMessageToMap(message proto.Message) (map[string]any, error) {
+	m := make(map[string]any)
+	if isNilProtoMessage(message) {
+		return m, nil
+	}
+	data, err := protojson.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MetadataFromContext returns the request metadata sent by the client with CallWithMetadata.
+//
+// Within a session started with --serve, this includes the metadata of the session, see
+// ExecRunnerWithSessionMetadata.
+//
+// This is for use within handlers. The returned map is a copy and may be modified.
+// Returns nil if the client did not send any metadata.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(requestMetadataContextKey{}).(map[string]string)
+	return maps.Clone(metadata)
+}
+
+// SetResponseMetadata sets response metadata to send back to the client alongside
+// the response or error.
+//
+// This is for use within handlers of unary Procedures. Response metadata is only sent if
+// the client requested it with CallWithResponseMetadata, otherwise this is a no-op.
+func SetResponseMetadata(ctx context.Context, key string, value string) {
+	if responseMetadata, ok := ctx.Value(responseMetadataContextKey{}).(*responseMetadata); ok {
+		responseMetadata.set(key, value)
+	}
+}
+
+// *** PRIVATE ***
+
+// maskedMetadataValue replaces the values of metadata in args, see maskMetadataArgs.
+const maskedMetadataValue = "***"
+
+type requestMetadataContextKey struct{}
+
+type responseMetadataContextKey struct{}
+
+type sessionMetadataContextKey struct{}
+
+type responseMetadata struct {
+	metadata map[string]string
+	lock     sync.Mutex
+}
+
+func newResponseMetadata() *responseMetadata {
+	return &responseMetadata{}
+}
+
+func (r *responseMetadata) set(key string, value string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.metadata == nil {
+		r.metadata = make(map[string]string)
+	}
+	r.metadata[key] = value
+}
+
+// get returns a copy of the response metadata.
+//
+// Safe to call on a nil responseMetadata, in which case nil is returned.
+func (r *responseMetadata) get() map[string]string {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return maps.Clone(r.metadata)
+}
+
+func withRequestMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	if len(metadata) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestMetadataContextKey{}, metadata)
+}
+
+// withSessionMetadata returns a new context with the metadata of the --serve session
+// that a call is part of, see ExecRunnerWithSessionMetadata.
+func withSessionMetadata(ctx context.Context, sessionMetadata map[string]string) context.Context {
+	if len(sessionMetadata) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionMetadataContextKey{}, sessionMetadata)
+}
+
+func sessionMetadataFromContext(ctx context.Context) map[string]string {
+	sessionMetadata, _ := ctx.Value(sessionMetadataContextKey{}).(map[string]string)
+	return sessionMetadata
+}
+
+// mergeSessionMetadata returns the request metadata of a call merged with the metadata
+// of its session, with the request metadata taking precedence.
+//
+// Returns nil if both are empty.
+func mergeSessionMetadata(sessionMetadata map[string]string, metadata map[string]string) map[string]string {
+	if len(sessionMetadata) == 0 {
+		return metadata
+	}
+	merged := maps.Clone(sessionMetadata)
+	maps.Copy(merged, metadata)
+	return merged
+}
+
+func withResponseMetadata(ctx context.Context, responseMetadata *responseMetadata) context.Context {
+	return context.WithValue(ctx, responseMetadataContextKey{}, responseMetadata)
+}
+
+func validateMetadataKey(key string) error {
+	if key == "" {
+		return errors.New("metadata key is empty")
+	}
+	if strings.Contains(key, "=") {
+		return fmt.Errorf("metadata key %q contains \"=\"", key)
+	}
+	return nil
+}
+
+// maskMetadataArgs returns a copy of the args with the values of the --metadata flag
+// masked, keeping their keys, so that the args can be written to logs, audit logs,
+// debug dumps, and repro commands without exposing metadata such as credentials.
+func maskMetadataArgs(args []string) []string {
+	masked := slices.Clone(args)
+	for i, arg := range masked {
+		switch {
+		case arg == "--"+MetadataFlagName && i+1 < len(masked):
+			masked[i+1] = maskMetadataKeyValue(masked[i+1])
+		case strings.HasPrefix(arg, "--"+MetadataFlagName+"="):
+			masked[i] = "--" + MetadataFlagName + "=" + maskMetadataKeyValue(strings.TrimPrefix(arg, "--"+MetadataFlagName+"="))
+		}
+	}
+	return masked
+}
+
+// maskMetadataKeyValue returns the key=value with its value masked.
+func maskMetadataKeyValue(keyValue string) string {
+	key, _, _ := strings.Cut(keyValue, "=")
+	return key + "=" + maskedMetadataValue
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := pluginrpc.NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := pluginrpc.NewSpec(procedure)
+	require.NoError(t, err)
+	handler := pluginrpc.NewHandler(spec)
+	serverRegistrar := pluginrpc.NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv pluginrpc.HandleEnv, options ...pluginrpc.HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				&examplev1.EchoRequestRequest{},
+				func(ctx context.Context, _ any) (any, error) {
+					metadata := pluginrpc.MetadataFromContext(ctx)
+					for key, value := range metadata {
+						pluginrpc.SetResponseMetadata(ctx, "echo-"+key, value)
+					}
+					if _, ok := metadata["fail"]; ok {
+						return nil, pluginrpc.NewErrorf(pluginrpc.CodeInternal, "failed")
+					}
+					return &examplev1.EchoRequestResponse{Message: metadata["message"]}, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := pluginrpc.NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(pluginrpc.NewServerRunner(server))
+
+	response := &examplev1.EchoRequestResponse{}
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, response))
+	require.Equal(t, "", response.GetMessage())
+
+	response = &examplev1.EchoRequestResponse{}
+	require.NoError(
+		t,
+		client.Call(
+			context.Background(),
+			"/foo/bar",
+			nil,
+			response,
+			pluginrpc.CallWithMetadata(map[string]string{"message": "hello, world"}),
+		),
+	)
+	require.Equal(t, "hello, world", response.GetMessage())
+
+	response = &examplev1.EchoRequestResponse{}
+	responseMetadata := make(map[string]string)
+	require.NoError(
+		t,
+		client.Call(
+			context.Background(),
+			"/foo/bar",
+			nil,
+			response,
+			pluginrpc.CallWithMetadata(map[string]string{"message": "hello"}),
+			pluginrpc.CallWithMetadata(map[string]string{"foo": "a=b"}),
+			pluginrpc.CallWithResponseMetadata(responseMetadata),
+		),
+	)
+	require.Equal(t, "hello", response.GetMessage())
+	require.Equal(t, map[string]string{"echo-message": "hello", "echo-foo": "a=b"}, responseMetadata)
+
+	responseMetadata = make(map[string]string)
+	err = client.Call(
+		context.Background(),
+		"/foo/bar",
+		nil,
+		response,
+		pluginrpc.CallWithMetadata(map[string]string{"fail": ""}),
+		pluginrpc.CallWithResponseMetadata(responseMetadata),
+	)
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeInternal, pluginrpcError.Code())
+	require.Equal(t, map[string]string{"echo-fail": ""}, responseMetadata)
+
+	err = client.Call(
+		context.Background(),
+		"/foo/bar",
+		nil,
+		response,
+		pluginrpc.CallWithMetadata(map[string]string{"a=b": "c"}),
+	)
+	require.Error(t, err)
+}
+
+func TestMetadataMasked(t *testing.T) {
+	t.Parallel()
+
+	// Clients mask metadata values in audit records, logs, debug dumps, and repro commands.
+	clientLogs := bytes.NewBuffer(nil)
+	auditLog := bytes.NewBuffer(nil)
+	debugWriter := bytes.NewBuffer(nil)
+	client := pluginrpc.NewClient(
+		pluginrpc.NewExecRunner(echoPluginProgramName),
+		pluginrpc.ClientWithLogger(slog.New(slog.NewJSONHandler(clientLogs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		pluginrpc.ClientWithAuditLog(auditLog),
+		pluginrpc.ClientWithDebugWriter(debugWriter),
+	)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	_, err = echoServiceClient.EchoError(
+		context.Background(),
+		&examplev1.EchoErrorRequest{Code: pluginrpcv1.Code_CODE_NOT_FOUND, Message: "not found"},
+		pluginrpc.CallWithMetadata(map[string]string{"token": "secret-token"}),
+	)
+	require.Equal(t, pluginrpc.CodeNotFound, pluginrpc.WrapError(err).Code(), err.Error())
+	reproCommand := pluginrpc.ReproCommandOf(err)
+	require.NotEmpty(t, reproCommand)
+	for name, output := range map[string]string{
+		"logs":   clientLogs.String(),
+		"audit":  auditLog.String(),
+		"debug":  debugWriter.String(),
+		"repro":  reproCommand,
+		"errors": err.Error(),
+	} {
+		require.NotContains(t, output, "secret-token", name)
+	}
+	for name, output := range map[string]string{
+		"logs":  clientLogs.String(),
+		"audit": auditLog.String(),
+		"debug": debugWriter.String(),
+		"repro": reproCommand,
+	} {
+		require.Contains(t, output, "token=***", name)
+	}
+
+	// Servers mask metadata values in logs, for both forms of the flag.
+	procedure, err := pluginrpc.NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := pluginrpc.NewSpec(procedure)
+	require.NoError(t, err)
+	handler := pluginrpc.NewHandler(spec)
+	serverRegistrar := pluginrpc.NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv pluginrpc.HandleEnv, options ...pluginrpc.HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				&examplev1.EchoRequestRequest{},
+				func(ctx context.Context, _ any) (any, error) {
+					return &examplev1.EchoRequestResponse{Message: pluginrpc.MetadataFromContext(ctx)["token"]}, nil
+				},
+				options...,
+			)
+		},
+	)
+	serverLogs := bytes.NewBuffer(nil)
+	server, err := pluginrpc.NewServer(
+		spec,
+		serverRegistrar,
+		pluginrpc.ServerWithLogger(slog.New(slog.NewJSONHandler(serverLogs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	require.NoError(t, err)
+	for _, metadataArgs := range [][]string{
+		{"--" + pluginrpc.MetadataFlagName, "token=secret-token"},
+		{"--" + pluginrpc.MetadataFlagName + "=token=secret-token"},
+	} {
+		serverLogs.Reset()
+		stdout := bytes.NewBuffer(nil)
+		require.NoError(
+			t,
+			server.Serve(
+				context.Background(),
+				pluginrpc.Env{
+					Args:   append(procedure.Args(), metadataArgs...),
+					Stdin:  bytes.NewReader(nil),
+					Stdout: stdout,
+					Stderr: io.Discard,
+				},
+			),
+		)
+		// The handler still receives the value.
+		require.Contains(t, stdout.String(), "secret-token")
+		require.Contains(t, serverLogs.String(), "token=***")
+		require.NotContains(t, serverLogs.String(), "secret-token")
+	}
+}
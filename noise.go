@@ -0,0 +1,167 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+// *** PRIVATE ***
+
+const (
+	// maxStdoutNoiseLines is the maximum number of lines of noise that are looked past for
+	// the output of a plugin.
+	maxStdoutNoiseLines = 64
+	// maxStdoutNoiseDescriptionSize is the maximum number of bytes of noise included in errors
+	// and logs.
+	maxStdoutNoiseDescriptionSize = 128
+)
+
+var utf8ByteOrderMark = []byte("\xef\xbb\xbf")
+
+// decodeStdout decodes the output of the plugin on stdout with decode, skipping a leading
+// prefix of noise if the output cannot be decoded, see skipStdoutNoise.
+//
+// decode must not have side effects, as it is called to find the output after the noise.
+func decodeStdout[T any](ctx context.Context, c *client, data []byte, decode func([]byte) (T, error)) (T, error) {
+	value, err := decode(data)
+	if err == nil {
+		return value, nil
+	}
+	data, err = c.skipStdoutNoise(
+		ctx,
+		data,
+		err,
+		func(data []byte) bool {
+			_, err := decode(data)
+			return err == nil
+		},
+	)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode(data)
+}
+
+// unmarshalStdoutResponse unmarshals the response of a call from the output of the plugin on
+// stdout, skipping a leading prefix of noise if the output cannot be unmarshaled, see
+// skipStdoutNoise.
+func (c *client) unmarshalStdoutResponse(
+	ctx context.Context,
+	format Format,
+	data []byte,
+	response any,
+	responseMetadata map[string]string,
+	handleWarning func(*extv1.Warning),
+) error {
+	err := unmarshalResponseWithMetadata(format, data, response, responseMetadata, handleWarning)
+	if err == nil || errors.As(err, new(*Error)) {
+		return err
+	}
+	data, err = c.skipStdoutNoise(
+		ctx,
+		data,
+		err,
+		func(data []byte) bool {
+			return isResponse(format, data)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return unmarshalResponseWithMetadata(format, data, response, responseMetadata, handleWarning)
+}
+
+// skipStdoutNoise looks for a leading prefix of noise in the output of the plugin on stdout
+// that could not be decoded with decodeErr, such as a byte order mark, or lines written by
+// a wrapper script. The output is valid after the prefix if isValid returns true.
+//
+// If there is such a prefix, the output after the prefix is returned with a warning logged
+// if the client tolerates noise, see ClientWithStdoutNoiseTolerance, and an error describing
+// the prefix is returned otherwise. If there is no such prefix, decodeErr is returned.
+func (c *client) skipStdoutNoise(ctx context.Context, data []byte, decodeErr error, isValid func([]byte) bool) ([]byte, error) {
+	start := findStdoutOutputStart(data, isValid)
+	if start == 0 {
+		return nil, decodeErr
+	}
+	noise := describeStdoutNoise(data[:start])
+	if !c.stdoutNoiseTolerance {
+		return nil, fmt.Errorf("plugin wrote unexpected prefix %s to stdout before its output, use ClientWithStdoutNoiseTolerance to skip it: %w", noise, decodeErr)
+	}
+	c.callLogger.warnStdoutNoise(ctx, noise)
+	return data[start:], nil
+}
+
+// findStdoutOutputStart returns the offset after a prefix of noise at which the output
+// of the plugin is valid, looking after a byte order mark and after each of the first
+// maxStdoutNoiseLines lines.
+//
+// Returns 0 if there is no such offset.
+func findStdoutOutputStart(data []byte, isValid func([]byte) bool) int {
+	var start int
+	if bytes.HasPrefix(data, utf8ByteOrderMark) {
+		start = len(utf8ByteOrderMark)
+		if isValid(data[start:]) {
+			return start
+		}
+	}
+	for i := 0; i < maxStdoutNoiseLines; i++ {
+		index := bytes.IndexByte(data[start:], '\n')
+		if index < 0 {
+			return 0
+		}
+		start += index + 1
+		if start < len(data) && isValid(data[start:]) {
+			return start
+		}
+	}
+	return 0
+}
+
+// describeStdoutNoise returns a description of noise for errors and logs.
+func describeStdoutNoise(noise []byte) string {
+	if bytes.Equal(noise, utf8ByteOrderMark) {
+		return "(a UTF-8 byte order mark)"
+	}
+	if len(noise) > maxStdoutNoiseDescriptionSize {
+		return fmt.Sprintf("%q... (%d bytes)", noise[:maxStdoutNoiseDescriptionSize], len(noise))
+	}
+	return fmt.Sprintf("%q", noise)
+}
+
+// isResponse returns true if data is a non-empty Response in the given Format.
+func isResponse(format Format, data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	codec, err := codecForFormat(format)
+	if err != nil {
+		return false
+	}
+	if format == FormatBinary {
+		data, _, err = stripBinaryHeader(data)
+		if err != nil {
+			return false
+		}
+	}
+	return codec.Unmarshal(data, &pluginrpcv1.Response{}) == nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestClientWithStdoutNoiseTolerance(t *testing.T) {
+	t.Parallel()
+
+	for _, noise := range []string{"\xef\xbb\xbf", "starting plugin\nusing cache\n"} {
+		server, err := newServer()
+		require.NoError(t, err)
+		runner := &noisyRunner{
+			runner: pluginrpc.NewServerRunner(server),
+			noise:  noise,
+		}
+
+		client := pluginrpc.NewClient(runner, pluginrpc.ClientWithFormat(pluginrpc.FormatJSON))
+		_, err = client.Spec(context.Background())
+		require.ErrorContains(t, err, "unexpected prefix")
+		require.ErrorContains(t, err, "ClientWithStdoutNoiseTolerance")
+		require.Equal(t, pluginrpc.ErrorSourceDecode, pluginrpc.ErrorSourceOf(err))
+
+		logs := bytes.NewBuffer(nil)
+		client = pluginrpc.NewClient(
+			runner,
+			pluginrpc.ClientWithFormat(pluginrpc.FormatJSON),
+			pluginrpc.ClientWithStdoutNoiseTolerance(),
+			pluginrpc.ClientWithLogger(slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+		)
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+		require.NoError(t, err)
+		response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+		require.NoError(t, err)
+		require.Equal(t, "hello", response.GetMessage())
+		// Errors from the plugin are still returned.
+		_, err = echoServiceClient.EchoError(context.Background(), &examplev1.EchoErrorRequest{Code: pluginrpcv1.Code_CODE_NOT_FOUND, Message: "foo"})
+		pluginrpcError := &pluginrpc.Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, pluginrpc.CodeNotFound, pluginrpcError.Code())
+		info, err := client.Info(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "Apache-2.0", info.License().SPDXID)
+		require.NoError(t, client.Ping(context.Background()))
+		require.Contains(t, logs.String(), "skipped unexpected prefix")
+	}
+}
+
+func TestClientWithStdoutNoiseToleranceInvalidOutput(t *testing.T) {
+	t.Parallel()
+
+	server, err := newServer()
+	require.NoError(t, err)
+	// Noise that is not followed by valid output is not skipped.
+	runner := &noisyRunner{
+		runner:  pluginrpc.NewServerRunner(server),
+		noise:   "starting plugin\n",
+		discard: true,
+	}
+	client := pluginrpc.NewClient(
+		runner,
+		pluginrpc.ClientWithFormat(pluginrpc.FormatJSON),
+		pluginrpc.ClientWithStdoutNoiseTolerance(),
+	)
+	_, err = client.Spec(context.Background())
+	require.ErrorContains(t, err, "did not return a properly-formed")
+	require.NotContains(t, err.Error(), "unexpected prefix")
+}
+
+// noisyRunner is a Runner for a plugin run by a wrapper script that writes noise to
+// stdout before the output of the plugin.
+//
+// If discard is true, the output of the plugin is discarded.
+type noisyRunner struct {
+	runner  pluginrpc.Runner
+	noise   string
+	discard bool
+}
+
+func (n *noisyRunner) Run(ctx context.Context, env pluginrpc.Env) error {
+	if _, err := env.Stdout.Write([]byte(n.noise)); err != nil {
+		return err
+	}
+	if n.discard {
+		env.Stdout = bytes.NewBuffer(nil)
+	}
+	return n.runner.Run(ctx, env)
+}
@@ -0,0 +1,316 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// CallWithRequestPayload returns a new CallOption that streams the given payload to the
+// plugin after the request, see RequestPayload.
+//
+// The payload is read as the plugin consumes it, so that payloads larger than memory, such
+// as the contents of large files, can be sent with bounded memory. The plugin must support
+// the --payload flag. Calls with payloads are not retried, as payloads cannot be read twice.
+// This is only available for unary calls made with Call.
+func CallWithRequestPayload(requestPayload io.Reader) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.requestPayload = requestPayload
+	}
+}
+
+// CallWithResponsePayload returns a new CallOption that writes the payload that the plugin
+// streams with the response to the given writer as it arrives, see ResponsePayload.
+//
+// The payload is not buffered, so that payloads larger than memory can be received with
+// bounded memory. If ClientWithMaxResponseSize is specified, the limit applies to each frame
+// of the payload and to the response. The plugin must support the --payload flag. If the
+// call fails, part of the payload may have been written. This is only available for unary
+// calls made with Call.
+func CallWithResponsePayload(responsePayload io.Writer) CallOption {
+	return func(callOptions *callOptions) {
+		callOptions.responsePayload = responsePayload
+	}
+}
+
+// RequestPayload returns the payload that the client streams after the request of the
+// call being handled, see CallWithRequestPayload.
+//
+// The payload is read from stdin as it is read from the returned reader. If the client did
+// not stream a payload, the reader is empty.
+func RequestPayload(ctx context.Context) io.Reader {
+	if callPayloads, ok := ctx.Value(callPayloadsContextKey{}).(*callPayloads); ok {
+		return callPayloads.requestPayload
+	}
+	return bytes.NewReader(nil)
+}
+
+// ResponsePayload returns a writer for the payload that is streamed to the client before
+// the response of the call being handled, see CallWithResponsePayload.
+//
+// Data written is sent to the client right away, in frames of bounded size. If the client
+// did not ask for the --payload flag, writes fail. Writes fail once the handler returns.
+func ResponsePayload(ctx context.Context) io.Writer {
+	if callPayloads, ok := ctx.Value(callPayloadsContextKey{}).(*callPayloads); ok {
+		return callPayloads.responsePayload
+	}
+	return noResponsePayloadWriter{}
+}
+
+// *** PRIVATE ***
+
+const (
+	// payloadChunkSize is the maximum size of the frames of payloads.
+	payloadChunkSize = 64 << 10
+)
+
+// callPayloads are the payloads of a call being handled with the --payload flag.
+//
+// With the --payload flag, stdin consists of a frame with the request, followed by the
+// frames of the request payload, ending with an empty frame. Stdout consists of the frames
+// of the response payload, ending with an empty frame, followed by a frame with the response.
+// Frames are written with writeFrame.
+type callPayloads struct {
+	requestPayload  *payloadReader
+	responsePayload *payloadWriter
+}
+
+type callPayloadsContextKey struct{}
+
+func newCallPayloads(stdin io.Reader, stdout io.Writer, maxRequestFrameSize uint32) *callPayloads {
+	return &callPayloads{
+		requestPayload: &payloadReader{
+			reader:       stdin,
+			maxFrameSize: maxRequestFrameSize,
+		},
+		responsePayload: &payloadWriter{
+			writer: stdout,
+		},
+	}
+}
+
+func withCallPayloads(ctx context.Context, callPayloads *callPayloads) context.Context {
+	return context.WithValue(ctx, callPayloadsContextKey{}, callPayloads)
+}
+
+// writeResponse ends the response payload, and writes the marshaled response as a frame.
+//
+// If callPayloads is nil, the response is written as-is.
+func (c *callPayloads) writeResponse(stdout io.Writer, data []byte) error {
+	if c == nil {
+		_, err := stdout.Write(data)
+		return err
+	}
+	if err := c.responsePayload.close(); err != nil {
+		return err
+	}
+	return writeFrame(stdout, data)
+}
+
+// payloadReader reads a payload from a sequence of frames ending with an empty frame.
+type payloadReader struct {
+	reader       io.Reader
+	maxFrameSize uint32
+
+	frame []byte
+	err   error
+}
+
+func (p *payloadReader) Read(data []byte) (int, error) {
+	for len(p.frame) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		frame, err := readFrame(p.reader, p.maxFrameSize)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("stream ended before the end of the payload")
+			}
+			p.err = err
+			return 0, err
+		}
+		if len(frame) == 0 {
+			p.err = io.EOF
+			return 0, io.EOF
+		}
+		p.frame = frame
+	}
+	n := copy(data, p.frame)
+	p.frame = p.frame[n:]
+	return n, nil
+}
+
+// payloadWriter writes a payload as a sequence of frames of at most payloadChunkSize bytes.
+//
+// The payload ends with an empty frame written by close.
+type payloadWriter struct {
+	writer io.Writer
+	closed bool
+}
+
+func (p *payloadWriter) Write(data []byte) (int, error) {
+	if p.closed {
+		return 0, errors.New("cannot write to the response payload after the response")
+	}
+	for written := 0; written < len(data); {
+		chunk := data[written:min(len(data), written+payloadChunkSize)]
+		if err := writeFrame(p.writer, chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return len(data), nil
+}
+
+func (p *payloadWriter) close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return writeFrame(p.writer, nil)
+}
+
+type noResponsePayloadWriter struct{}
+
+func (noResponsePayloadWriter) Write([]byte) (int, error) {
+	return 0, errors.New("client did not specify --payload, see CallWithResponsePayload")
+}
+
+// payloadFramer is the stdin of a call with the --payload flag: a frame with the marshaled
+// request, followed by the frames of the request payload, ending with an empty frame.
+//
+// The request payload is read as stdin is read.
+type payloadFramer struct {
+	requestPayload io.Reader
+
+	// pending is the framed data that has not been read yet.
+	pending []byte
+	chunk   []byte
+	// payloadEOF is true once the request payload has ended.
+	payloadEOF bool
+	done       bool
+}
+
+func newPayloadFramer(data []byte, requestPayload io.Reader) *payloadFramer {
+	pending := make([]byte, frameLengthSize, frameLengthSize+len(data))
+	binary.BigEndian.PutUint32(pending, uint32(len(data)))
+	return &payloadFramer{
+		requestPayload: requestPayload,
+		pending:        append(pending, data...),
+		payloadEOF:     requestPayload == nil,
+	}
+}
+
+func (p *payloadFramer) Read(data []byte) (int, error) {
+	for len(p.pending) == 0 {
+		if p.done {
+			return 0, io.EOF
+		}
+		if p.payloadEOF {
+			// The empty frame that ends the payload.
+			p.pending = make([]byte, frameLengthSize)
+			p.done = true
+			break
+		}
+		if p.chunk == nil {
+			p.chunk = make([]byte, frameLengthSize+payloadChunkSize)
+		}
+		n, err := p.requestPayload.Read(p.chunk[frameLengthSize:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(p.chunk, uint32(n))
+			p.pending = p.chunk[:frameLengthSize+n]
+		}
+		if errors.Is(err, io.EOF) {
+			p.payloadEOF = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(data, p.pending)
+	p.pending = p.pending[n:]
+	return n, nil
+}
+
+// framedStdout is the stdout of a call with the --payload flag: the frames of the response
+// payload, which are written to responsePayload as they arrive, ending with an empty frame,
+// followed by a frame with the marshaled response.
+//
+// If stdout is not properly framed, a frame exceeds the maximum size, or a frame cannot be
+// written to responsePayload, onFailed is called so that the plugin can be stopped.
+type framedStdout struct {
+	frameWriter     *frameWriter
+	responsePayload io.Writer
+	onFailed        func()
+
+	payloadEnded bool
+	response     []byte
+	responseDone bool
+	// failedErr is the error that onFailed was called for, if any.
+	failedErr error
+}
+
+func newFramedStdout(responsePayload io.Writer, maxFrameSize uint32, onFailed func()) *framedStdout {
+	framedStdout := &framedStdout{
+		responsePayload: responsePayload,
+		onFailed:        onFailed,
+	}
+	framedStdout.frameWriter = newFrameWriter(framedStdout.onFrame).withMaxSize(maxFrameSize, nil)
+	return framedStdout
+}
+
+func (f *framedStdout) Write(data []byte) (int, error) {
+	n, err := f.frameWriter.Write(data)
+	if err != nil && f.failedErr == nil {
+		f.failedErr = err
+		f.onFailed()
+	}
+	return n, err
+}
+
+// responseData returns the marshaled response once the plugin has exited.
+func (f *framedStdout) responseData() ([]byte, error) {
+	if err := f.frameWriter.Close(); err != nil {
+		return nil, err
+	}
+	if !f.responseDone {
+		return nil, errors.New("plugin stdout ended before the response")
+	}
+	return f.response, nil
+}
+
+func (f *framedStdout) onFrame(frame []byte) error {
+	switch {
+	case !f.payloadEnded:
+		if len(frame) == 0 {
+			f.payloadEnded = true
+			return nil
+		}
+		if f.responsePayload == nil {
+			return errors.New("plugin returned a response payload that was not asked for, see CallWithResponsePayload")
+		}
+		_, err := f.responsePayload.Write(frame)
+		return err
+	case !f.responseDone:
+		f.response = bytes.Clone(frame)
+		f.responseDone = true
+		return nil
+	default:
+		return errors.New("plugin returned data after the response")
+	}
+}
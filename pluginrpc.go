@@ -15,9 +15,13 @@
 // Package pluginrpc implements an RPC framework for plugins.
 package pluginrpc // import "pluginrpc.com/pluginrpc"
 
+import "pluginrpc.com/pluginrpc/pluginrpcinfo"
+
 const (
 	// Version is the semantic version of the pluginrpc module.
-	Version = "0.6.0-dev"
+	//
+	// See pluginrpcinfo for further build metadata.
+	Version = pluginrpcinfo.Version
 
 	// IsAtLeastVersion0_1_0 is used in compile-time handshake's with pluginrpc's generated code.
 	IsAtLeastVersion0_1_0 = true
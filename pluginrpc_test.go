@@ -15,14 +15,28 @@
 package pluginrpc_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
 	"pluginrpc.com/pluginrpc"
 	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
 	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
@@ -53,6 +67,171 @@ func TestEchoRequest(t *testing.T) {
 	)
 }
 
+func TestPing(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			require.NoError(t, client.Ping(context.Background()))
+		},
+	)
+
+	err := pluginrpc.NewClient(pluginrpc.NewExecRunner("does-not-exist")).Ping(context.Background())
+	require.Error(t, err)
+	require.Equal(t, pluginrpc.ErrorSourceSpawn, pluginrpc.ErrorSourceOf(err))
+}
+
+func TestEchoRequestForMap(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			response, err := pluginrpc.CallForMap(
+				context.Background(),
+				client,
+				examplev1pluginrpc.EchoServiceEchoRequestPath,
+				&examplev1.EchoRequestRequest{
+					Message: "hello",
+				},
+				(&examplev1.EchoRequestResponse{}).ProtoReflect().Descriptor(),
+			)
+			require.NoError(t, err)
+			require.Equal(t, map[string]any{"message": "hello"}, response)
+		},
+	)
+}
+
+func TestSpecDescriptor(t *testing.T) {
+	t.Parallel()
+
+	descriptor := examplev1pluginrpc.EchoServiceSpecDescriptor
+	require.Equal(t, "pluginrpc.example.v1.EchoService", descriptor.FullName)
+	spec, err := examplev1pluginrpc.DefaultEchoServiceSpec()
+	require.NoError(t, err)
+	require.Len(t, descriptor.Methods, len(spec.Procedures()))
+	for _, procedure := range spec.Procedures() {
+		_, ok := descriptor.MethodForPath(procedure.Path())
+		require.True(t, ok, procedure.Path())
+	}
+	method, ok := descriptor.MethodForPath(examplev1pluginrpc.EchoServiceEchoStreamPath)
+	require.True(t, ok)
+	require.Equal(t, "EchoStream", method.Name)
+	require.Equal(t, (&examplev1.EchoStreamRequest{}).ProtoReflect().Descriptor(), method.InputType.Descriptor())
+	require.Equal(t, (&examplev1.EchoStreamResponse{}).ProtoReflect().Descriptor(), method.OutputType.Descriptor())
+	require.False(t, method.ClientStreaming)
+	require.True(t, method.ServerStreaming)
+	_, ok = descriptor.MethodForPath("/foo/bar")
+	require.False(t, ok)
+}
+
+func TestSpecDescriptors(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			spec, err := client.Spec(context.Background())
+			require.NoError(t, err)
+			fileDescriptorSet := spec.FileDescriptorSet()
+			require.NotNil(t, fileDescriptorSet)
+			files, err := protodesc.NewFiles(fileDescriptorSet)
+			require.NoError(t, err)
+			_, err = files.FindDescriptorByName("pluginrpc.example.v1.EchoService")
+			require.NoError(t, err)
+
+			dynamicClient, err := pluginrpc.NewDynamicClientForFileDescriptorSet(client, fileDescriptorSet)
+			require.NoError(t, err)
+			data, err := dynamicClient.CallJSON(context.Background(), examplev1pluginrpc.EchoServiceEchoRequestPath, []byte(`{"message":"hello"}`))
+			require.NoError(t, err)
+			require.JSONEq(t, `{"message":"hello"}`, string(data))
+
+			incompatibilities, err := pluginrpc.CheckClientDescriptorCompatibility(
+				context.Background(),
+				client,
+				nil,
+				examplev1pluginrpc.EchoServiceEchoRequestPath,
+				examplev1pluginrpc.EchoServiceEchoStreamPath,
+			)
+			require.NoError(t, err)
+			require.Empty(t, incompatibilities)
+		},
+		pluginrpc.ClientWithSpecDescriptors(),
+	)
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			spec, err := client.Spec(context.Background())
+			require.NoError(t, err)
+			require.Nil(t, spec.FileDescriptorSet())
+			require.Empty(t, spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoRequestPath).Doc())
+
+			_, err = pluginrpc.CheckClientDescriptorCompatibility(
+				context.Background(),
+				client,
+				nil,
+				examplev1pluginrpc.EchoServiceEchoRequestPath,
+			)
+			require.ErrorContains(t, err, "did not return descriptors")
+		},
+	)
+}
+
+func TestSpecDocs(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			spec, err := client.Spec(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, "Echo the request back.", spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoRequestPath).Doc())
+			require.Nil(t, spec.FileDescriptorSet())
+		},
+		pluginrpc.ClientWithSpecDocs(),
+	)
+}
+
+func TestSpecBuilderMethodArgs(t *testing.T) {
+	t.Parallel()
+
+	// The args of EchoRequest and EchoError are declared with the pluginrpc.ext.v1.method option.
+	spec, err := examplev1pluginrpc.EchoServiceSpecBuilder{
+		EchoError: []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("error")},
+	}.Build()
+	require.NoError(t, err)
+	require.Equal(t, []string{"echo", "request"}, spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoRequestPath).Args())
+	require.Equal(t, []string{"error"}, spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoErrorPath).Args())
+	require.Empty(t, spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoListPath).Args())
+
+	serviceDescriptor := examplev1.File_pluginrpc_example_v1_example_proto.Services().ByName("EchoService")
+	require.NotNil(t, serviceDescriptor)
+	spec, err = pluginrpc.NewSpecForServiceDescriptor(serviceDescriptor)
+	require.NoError(t, err)
+	require.Equal(t, []string{"echo", "request"}, spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoRequestPath).Args())
+}
+
+func TestNewSpecForServiceDescriptor(t *testing.T) {
+	t.Parallel()
+
+	serviceDescriptor := examplev1.File_pluginrpc_example_v1_example_proto.Services().ByName("EchoService")
+	require.NotNil(t, serviceDescriptor)
+	procedureOption := pluginrpc.ProcedureWithArgs("echo", "request")
+	spec, err := pluginrpc.NewSpecForServiceDescriptor(
+		serviceDescriptor,
+		pluginrpc.SpecForServiceDescriptorWithProcedureOptions("EchoRequest", procedureOption),
+	)
+	require.NoError(t, err)
+	expectedSpec, err := examplev1pluginrpc.EchoServiceSpecBuilder{
+		EchoRequest: []pluginrpc.ProcedureOption{procedureOption},
+	}.Build()
+	require.NoError(t, err)
+	require.True(t, proto.Equal(pluginrpc.NewProtoSpec(expectedSpec), pluginrpc.NewProtoSpec(spec)))
+
+	_, err = pluginrpc.NewSpecForServiceDescriptor(
+		serviceDescriptor,
+		pluginrpc.SpecForServiceDescriptorWithProcedureOptions("Unknown", procedureOption),
+	)
+	require.Error(t, err)
+}
+
 func TestEchoRequestNil(t *testing.T) {
 	t.Parallel()
 	forEachDimension(
@@ -108,6 +287,151 @@ func TestEchoError(t *testing.T) {
 	)
 }
 
+func TestEchoStream(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+			require.NoError(t, err)
+			var messages []string
+			err = echoServiceClient.EchoStream(
+				context.Background(),
+				&examplev1.EchoStreamRequest{
+					Messages: []string{"foo", "bar", "baz"},
+				},
+				func(response *examplev1.EchoStreamResponse) error {
+					messages = append(messages, response.GetMessage())
+					return nil
+				},
+			)
+			require.NoError(t, err)
+			require.Equal(t, []string{"foo", "bar", "baz"}, messages)
+
+			// Errors from the callback stop the stream.
+			messages = nil
+			errStop := errors.New("stop")
+			err = echoServiceClient.EchoStream(
+				context.Background(),
+				&examplev1.EchoStreamRequest{
+					Messages: []string{"foo", "bar", "baz"},
+				},
+				func(response *examplev1.EchoStreamResponse) error {
+					messages = append(messages, response.GetMessage())
+					return errStop
+				},
+			)
+			require.ErrorIs(t, err, errStop)
+			require.Equal(t, []string{"foo"}, messages)
+		},
+	)
+}
+
+func TestEchoBidi(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+			require.NoError(t, err)
+			stream, err := echoServiceClient.EchoBidi(context.Background())
+			require.NoError(t, err)
+			// Responses are received before the sending side is closed.
+			for _, message := range []string{"foo", "bar"} {
+				require.NoError(t, stream.Send(&examplev1.EchoBidiRequest{Message: message}))
+				response := &examplev1.EchoBidiResponse{}
+				require.NoError(t, stream.Receive(response))
+				require.Equal(t, message, response.GetMessage())
+			}
+			require.NoError(t, stream.CloseSend())
+			require.ErrorIs(t, stream.Receive(&examplev1.EchoBidiResponse{}), io.EOF)
+		},
+	)
+}
+
+func TestPreDispatchErrors(t *testing.T) {
+	t.Parallel()
+
+	disabled := []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithDisabled()}
+	for _, testCase := range []struct {
+		name          string
+		spec          examplev1pluginrpc.EchoServiceSpecBuilder
+		serverOptions []pluginrpc.ServerOption
+		expectedCode  pluginrpc.Code
+	}{
+		{
+			name: "denied",
+			serverOptions: []pluginrpc.ServerOption{
+				pluginrpc.ServerWithAuthorizer(
+					func(context.Context, string, map[string]string) error {
+						return errors.New("denied")
+					},
+				),
+			},
+			expectedCode: pluginrpc.CodePermissionDenied,
+		},
+		{
+			name: "disabled",
+			spec: examplev1pluginrpc.EchoServiceSpecBuilder{
+				EchoRequest: disabled,
+				EchoStream:  disabled,
+				EchoBidi:    disabled,
+			},
+			expectedCode: pluginrpc.CodeUnimplemented,
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			server, err := pluginrpc.NewServerForServices(
+				[]pluginrpc.ServiceRegistration{
+					examplev1pluginrpc.EchoServiceRegistration{
+						Handler: newEchoServiceHandler(),
+						Spec:    testCase.spec,
+					},
+				},
+				pluginrpc.ServerForHandlerWithServerOptions(testCase.serverOptions...),
+			)
+			require.NoError(t, err)
+			// The Spec of the client includes disabled Procedures, so that they are called.
+			spec, err := examplev1pluginrpc.DefaultEchoServiceSpec()
+			require.NoError(t, err)
+			requireCode := func(err error) {
+				pluginrpcError := &pluginrpc.Error{}
+				require.ErrorAs(t, err, &pluginrpcError)
+				require.Equal(t, testCase.expectedCode, pluginrpcError.Code())
+			}
+			for _, clientOptions := range [][]pluginrpc.ClientOption{
+				nil,
+				{pluginrpc.ClientWithBinaryHeader()},
+				{pluginrpc.ClientWithCompression(pluginrpc.CompressionGzip)},
+				{pluginrpc.ClientWithFormat(pluginrpc.FormatJSON)},
+			} {
+				echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(
+					pluginrpc.NewClient(
+						pluginrpc.NewServerRunner(server),
+						append(clientOptions, pluginrpc.ClientWithSpec(spec))...,
+					),
+				)
+				require.NoError(t, err)
+				_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+				requireCode(err)
+				err = echoServiceClient.EchoStream(
+					context.Background(),
+					&examplev1.EchoStreamRequest{Messages: []string{"hello"}},
+					func(*examplev1.EchoStreamResponse) error {
+						return errors.New("unexpected response")
+					},
+				)
+				requireCode(err)
+				stream, err := echoServiceClient.EchoBidi(context.Background())
+				require.NoError(t, err)
+				requireCode(stream.Receive(&examplev1.EchoBidiResponse{}))
+			}
+		})
+	}
+}
+
 func TestUnimplemented(t *testing.T) {
 	t.Parallel()
 	forEachDimension(
@@ -127,9 +451,599 @@ func TestUnimplemented(t *testing.T) {
 	)
 }
 
-func forEachDimension(t *testing.T, f func(*testing.T, pluginrpc.Client)) {
+func TestServerForHandler(t *testing.T) {
+	t.Parallel()
+
+	info, err := pluginrpc.NewInfo(pluginrpc.InfoWithLicense(pluginrpc.License{SPDXID: "Apache-2.0"}))
+	require.NoError(t, err)
+	server, err := examplev1pluginrpc.NewEchoServiceServerForHandler(
+		echoListServiceHandler{},
+		pluginrpc.ServerForHandlerWithServerOptions(pluginrpc.ServerWithInfo(info)),
+	)
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(pluginrpc.NewServerRunner(server))
+	spec, err := client.Spec(context.Background())
+	require.NoError(t, err)
+	procedure := spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoRequestPath)
+	require.NotNil(t, procedure)
+	require.Equal(t, []string{"echo", "request"}, procedure.Args())
+	clientInfo, err := client.Info(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "Apache-2.0", clientInfo.License().SPDXID)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoList(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo"}, response.GetList())
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	t.Parallel()
+
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+			require.NoError(t, err)
+			response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+			require.NoError(t, err)
+			require.Equal(t, "hello", response.GetMessage())
+			pluginrpcError := &pluginrpc.Error{}
+			_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: strings.Repeat("a", 1024)})
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, pluginrpc.CodeResourceExhausted, pluginrpcError.Code())
+			require.Equal(t, pluginrpc.ErrorSourceDecode, pluginrpc.ErrorSourceOf(err))
+
+			// The limit applies to each response of a stream.
+			var messages []string
+			err = echoServiceClient.EchoStream(
+				context.Background(),
+				&examplev1.EchoStreamRequest{Messages: []string{"hello", strings.Repeat("a", 1024)}},
+				func(response *examplev1.EchoStreamResponse) error {
+					messages = append(messages, response.GetMessage())
+					return nil
+				},
+			)
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, pluginrpc.CodeResourceExhausted, pluginrpcError.Code())
+			require.Equal(t, []string{"hello"}, messages)
+
+			stream, err := echoServiceClient.EchoBidi(context.Background())
+			require.NoError(t, err)
+			require.NoError(t, stream.Send(&examplev1.EchoBidiRequest{Message: strings.Repeat("a", 1024)}))
+			require.ErrorAs(t, stream.Receive(&examplev1.EchoBidiResponse{}), &pluginrpcError)
+			require.Equal(t, pluginrpc.CodeResourceExhausted, pluginrpcError.Code())
+		},
+		pluginrpc.ClientWithMaxResponseSize(256),
+	)
+}
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	for _, compression := range pluginrpc.AllCompressions {
+		t.Run(compression.String(), func(t *testing.T) {
+			t.Parallel()
+			forEachDimension(
+				t,
+				func(t *testing.T, client pluginrpc.Client) {
+					spec, err := client.Spec(context.Background())
+					require.NoError(t, err)
+					require.Equal(t, pluginrpc.AllCompressions, spec.Compressions())
+					echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+					require.NoError(t, err)
+					message := strings.Repeat("hello", 1024)
+					response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: message})
+					require.NoError(t, err)
+					require.Equal(t, message, response.GetMessage())
+					// Highly compressible requests and responses are not limited by their ratio.
+					for _, size := range []int{64 << 10, 1 << 20} {
+						message := strings.Repeat("a", size)
+						response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: message})
+						require.NoError(t, err)
+						require.Equal(t, message, response.GetMessage())
+					}
+					response, err = echoServiceClient.EchoRequest(context.Background(), nil)
+					require.NoError(t, err)
+					require.Equal(t, "", response.GetMessage())
+					_, err = echoServiceClient.EchoError(
+						context.Background(),
+						&examplev1.EchoErrorRequest{
+							Code:    pluginrpcv1.Code_CODE_NOT_FOUND,
+							Message: "hello",
+						},
+					)
+					pluginrpcError := &pluginrpc.Error{}
+					require.ErrorAs(t, err, &pluginrpcError)
+					require.Equal(t, pluginrpc.CodeNotFound, pluginrpcError.Code())
+					var messages []string
+					err = echoServiceClient.EchoStream(
+						context.Background(),
+						&examplev1.EchoStreamRequest{Messages: []string{message, "world"}},
+						func(response *examplev1.EchoStreamResponse) error {
+							messages = append(messages, response.GetMessage())
+							return nil
+						},
+					)
+					require.NoError(t, err)
+					require.Equal(t, []string{message, "world"}, messages)
+				},
+				pluginrpc.ClientWithCompression(compression),
+			)
+		})
+	}
+}
+
+func TestPayload(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+			require.NoError(t, err)
+			// The payload spans many frames.
+			requestPayload := bytes.Repeat([]byte("0123456789"), 16*1024)
+			responsePayload := bytes.NewBuffer(nil)
+			response, err := echoServiceClient.EchoRequest(
+				context.Background(),
+				&examplev1.EchoRequestRequest{Message: "hello"},
+				pluginrpc.CallWithRequestPayload(iotest.HalfReader(bytes.NewReader(requestPayload))),
+				pluginrpc.CallWithResponsePayload(responsePayload),
+			)
+			require.NoError(t, err)
+			require.Equal(t, "hello", response.GetMessage())
+			require.Equal(t, requestPayload, responsePayload.Bytes())
+
+			responsePayload.Reset()
+			response, err = echoServiceClient.EchoRequest(
+				context.Background(),
+				nil,
+				pluginrpc.CallWithResponsePayload(responsePayload),
+			)
+			require.NoError(t, err)
+			require.Equal(t, "", response.GetMessage())
+			require.Empty(t, responsePayload.Bytes())
+
+			// Errors are returned after the payload.
+			_, err = echoServiceClient.EchoError(
+				context.Background(),
+				&examplev1.EchoErrorRequest{
+					Code:    pluginrpcv1.Code_CODE_NOT_FOUND,
+					Message: "hello",
+				},
+				pluginrpc.CallWithRequestPayload(bytes.NewReader(requestPayload)),
+			)
+			pluginrpcError := &pluginrpc.Error{}
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, pluginrpc.CodeNotFound, pluginrpcError.Code())
+
+			// A response payload that was not asked for fails the call.
+			_, err = echoServiceClient.EchoRequest(
+				context.Background(),
+				&examplev1.EchoRequestRequest{Message: "hello"},
+				pluginrpc.CallWithRequestPayload(bytes.NewReader(requestPayload)),
+			)
+			require.ErrorContains(t, err, "not asked for")
+		},
+	)
+}
+
+func TestHostServices(t *testing.T) {
+	t.Parallel()
+
+	hostServices, err := examplev1pluginrpc.NewEchoServiceServerForHandler(upperEchoServiceHandler{})
+	require.NoError(t, err)
+	for _, newClient := range []func(*testing.T, ...pluginrpc.ClientOption) (pluginrpc.Client, error){
+		newExecRunnerClient,
+		newServerRunnerClient,
+	} {
+		client, err := newClient(t, pluginrpc.ClientWithHostServices(hostServices))
+		require.NoError(t, err)
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+		require.NoError(t, err)
+		// The plugin calls back into the host to echo the message.
+		response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+		require.NoError(t, err)
+		require.Equal(t, "HELLO", response.GetMessage())
+		// Errors of host services are returned by the plugin.
+		_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "secret"})
+		pluginrpcError := &pluginrpc.Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, pluginrpc.CodePermissionDenied, pluginrpcError.Code())
+
+		callHostServices, err := examplev1pluginrpc.NewEchoServiceServerForHandler(upperEchoServiceHandler{suffix: "!"})
+		require.NoError(t, err)
+		response, err = echoServiceClient.EchoRequest(
+			context.Background(),
+			&examplev1.EchoRequestRequest{Message: "hello"},
+			pluginrpc.CallWithHostServices(callHostServices),
+		)
+		require.NoError(t, err)
+		require.Equal(t, "HELLO!", response.GetMessage())
+
+		client, err = newClient(t)
+		require.NoError(t, err)
+		echoServiceClient, err = examplev1pluginrpc.NewEchoServiceClient(client)
+		require.NoError(t, err)
+		response, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+		require.NoError(t, err)
+		require.Equal(t, "hello", response.GetMessage())
+	}
+}
+
+func TestHandlerWithMaxRequestSize(t *testing.T) {
+	t.Parallel()
+
+	server, err := examplev1pluginrpc.NewEchoServiceServerForHandler(
+		newEchoServiceHandler(),
+		pluginrpc.ServerForHandlerWithHandlerOptions(pluginrpc.HandlerWithMaxRequestSize(256)),
+	)
+	require.NoError(t, err)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(pluginrpc.NewServerRunner(server)))
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.GetMessage())
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: strings.Repeat("a", 1024)})
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeResourceExhausted, pluginrpcError.Code())
+}
+
+func TestDecompressionLimits(t *testing.T) {
+	t.Parallel()
+
+	message := strings.Repeat("a", 64<<10)
+	server, err := examplev1pluginrpc.NewEchoServiceServerForHandler(
+		newEchoServiceHandler(),
+		pluginrpc.ServerForHandlerWithHandlerOptions(pluginrpc.HandlerWithDecompressionLimits(1024, 0)),
+	)
+	require.NoError(t, err)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(
+		pluginrpc.NewClient(
+			pluginrpc.NewServerRunner(server),
+			pluginrpc.ClientWithCompression(pluginrpc.CompressionZstd),
+		),
+	)
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.GetMessage())
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: message})
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeResourceExhausted, pluginrpcError.Code())
+
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+			require.NoError(t, err)
+			_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: message})
+			pluginrpcError := &pluginrpc.Error{}
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, pluginrpc.CodeResourceExhausted, pluginrpcError.Code())
+		},
+		pluginrpc.ClientWithCompression(pluginrpc.CompressionZstd),
+		pluginrpc.ClientWithDecompressionLimits(1024, 0),
+	)
+}
+
+func TestServerForServices(t *testing.T) {
+	t.Parallel()
+
+	server, err := pluginrpc.NewServerForServices(
+		[]pluginrpc.ServiceRegistration{
+			examplev1pluginrpc.EchoServiceRegistration{
+				Handler: echoListServiceHandler{},
+				Spec: examplev1pluginrpc.EchoServiceSpecBuilder{
+					EchoList: []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("echo", "list")},
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(pluginrpc.NewServerRunner(server))
+	spec, err := client.Spec(context.Background())
+	require.NoError(t, err)
+	procedure := spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoListPath)
+	require.NotNil(t, procedure)
+	require.Equal(t, []string{"echo", "list"}, procedure.Args())
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoList(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo"}, response.GetList())
+
+	_, err = pluginrpc.NewServerForServices(nil)
+	require.ErrorContains(t, err, "no services given")
+	_, err = pluginrpc.NewServerForServices([]pluginrpc.ServiceRegistration{examplev1pluginrpc.EchoServiceRegistration{}})
+	require.ErrorContains(t, err, "Handler is required")
+	_, err = pluginrpc.NewServerForServices(
+		[]pluginrpc.ServiceRegistration{
+			examplev1pluginrpc.EchoServiceRegistration{Handler: echoListServiceHandler{}},
+			examplev1pluginrpc.EchoServiceRegistration{Handler: echoListServiceHandler{}},
+		},
+	)
+	require.Error(t, err)
+}
+
+func TestUnimplementedHandler(t *testing.T) {
+	t.Parallel()
+
+	spec, err := examplev1pluginrpc.DefaultEchoServiceSpec()
+	require.NoError(t, err)
+	serverRegistrar := pluginrpc.NewServerRegistrar()
+	echoServiceServer := examplev1pluginrpc.NewEchoServiceServer(pluginrpc.NewHandler(spec), echoListServiceHandler{})
+	examplev1pluginrpc.RegisterEchoServiceServer(serverRegistrar, echoServiceServer)
+	server, err := pluginrpc.NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(pluginrpc.NewServerRunner(server)))
+	require.NoError(t, err)
+
+	response, err := echoServiceClient.EchoList(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo"}, response.GetList())
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{})
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeUnimplemented, pluginrpcError.Code())
+	require.Contains(t, pluginrpcError.Error(), examplev1pluginrpc.EchoServiceEchoRequestPath)
+}
+
+func TestInfo(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			info, err := client.Info(context.Background())
+			require.NoError(t, err)
+			license := info.License()
+			require.NotNil(t, license)
+			require.Equal(t, "Apache-2.0", license.SPDXID)
+			require.Empty(t, info.Notices())
+			platform := info.Platform()
+			require.NotNil(t, platform)
+			require.Equal(t, runtime.GOOS, platform.OS)
+			require.Equal(t, runtime.GOARCH, platform.Arch)
+			require.NoError(t, pluginrpc.CheckPlatform(info))
+		},
+	)
+}
+
+func TestSpecCompression(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			spec, err := client.Spec(context.Background())
+			require.NoError(t, err)
+			require.Len(t, spec.Procedures(), 5)
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+			require.NoError(t, err)
+			response, err := echoServiceClient.EchoRequest(
+				context.Background(),
+				&examplev1.EchoRequestRequest{
+					Message: "hello",
+				},
+			)
+			require.NoError(t, err)
+			require.Equal(t, "hello", response.GetMessage())
+		},
+		pluginrpc.ClientWithSpecCompression(),
+	)
+}
+
+func TestSpecCompressionLimits(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			_, err := client.Spec(context.Background())
+			require.ErrorContains(t, err, "decompressed data exceeds 16 bytes")
+		},
+		pluginrpc.ClientWithSpecCompression(),
+		pluginrpc.ClientWithDecompressionLimits(16, 0),
+	)
+}
+
+func TestBinaryHeader(t *testing.T) {
+	t.Parallel()
+	forEachDimension(
+		t,
+		func(t *testing.T, client pluginrpc.Client) {
+			echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+			require.NoError(t, err)
+			response, err := echoServiceClient.EchoRequest(
+				context.Background(),
+				&examplev1.EchoRequestRequest{
+					Message: "hello",
+				},
+			)
+			require.NoError(t, err)
+			require.Equal(t, "hello", response.GetMessage())
+			response, err = echoServiceClient.EchoRequest(context.Background(), nil)
+			require.NoError(t, err)
+			require.Equal(t, "", response.GetMessage())
+			_, err = echoServiceClient.EchoError(
+				context.Background(),
+				&examplev1.EchoErrorRequest{
+					Code:    pluginrpcv1.Code_CODE_NOT_FOUND,
+					Message: "hello",
+				},
+			)
+			pluginrpcError := &pluginrpc.Error{}
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, pluginrpc.CodeNotFound, pluginrpcError.Code())
+		},
+		pluginrpc.ClientWithBinaryHeader(),
+	)
+}
+
+func TestExecRunnerWithCmdOption(t *testing.T) {
+	t.Parallel()
+	var programNames []string
+	runner := pluginrpc.NewExecRunner(
+		echoPluginProgramName,
+		pluginrpc.ExecRunnerWithCmdOption(
+			func(cmd *exec.Cmd) {
+				programNames = append(programNames, filepath.Base(cmd.Path))
+			},
+		),
+	)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner))
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoRequest(
+		context.Background(),
+		&examplev1.EchoRequestRequest{
+			Message: "hello",
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.GetMessage())
+	// --protocol, --spec, and the call itself.
+	require.Len(t, programNames, 3)
+	for _, programName := range programNames {
+		require.Equal(t, echoPluginProgramName, strings.TrimSuffix(programName, ".exe"))
+	}
+}
+
+func TestExecServeRunner(t *testing.T) {
+	t.Parallel()
+
+	var starts int
+	runner := pluginrpc.NewExecServeRunner(
+		echoPluginProgramName,
+		pluginrpc.ExecRunnerWithCmdOption(func(*exec.Cmd) { starts++ }),
+		pluginrpc.ExecRunnerWithHeartbeat(time.Millisecond, 10*time.Second),
+	)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner))
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	errs := make([]error, 16)
+	for i := 0; i < len(errs); i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			message := strconv.Itoa(i)
+			response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: message})
+			if err == nil && response.GetMessage() != message {
+				err = fmt.Errorf("expected %q, got %q", message, response.GetMessage())
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	// Let heartbeats flow between calls.
+	time.Sleep(20 * time.Millisecond)
+	_, err = echoServiceClient.EchoError(
+		context.Background(),
+		&examplev1.EchoErrorRequest{
+			Code:    pluginrpcv1.Code_CODE_DEADLINE_EXCEEDED,
+			Message: "foo",
+		},
+	)
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeDeadlineExceeded, pluginrpcError.Code())
+	// All calls were served by a single process.
+	require.Equal(t, 1, starts)
+
+	require.NoError(t, runner.Close())
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{})
+	require.Error(t, err)
+}
+
+func TestSpecCache(t *testing.T) {
+	t.Parallel()
+
+	cacheDirPath := t.TempDir()
+	var starts atomic.Int64
+	runner := pluginrpc.NewExecRunner(
+		echoPluginProgramName,
+		pluginrpc.ExecRunnerWithCmdOption(func(*exec.Cmd) { starts.Add(1) }),
+	)
+	getSpec := func(client pluginrpc.Client) {
+		spec, err := client.Spec(context.Background())
+		require.NoError(t, err)
+		require.Len(t, spec.Procedures(), 5)
+	}
+
+	client := pluginrpc.NewClient(runner, pluginrpc.ClientWithSpecCache(cacheDirPath))
+	getSpec(client)
+	require.Equal(t, int64(2), starts.Load())
+	entries, err := os.ReadDir(cacheDirPath)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// A new client uses the cached Spec.
+	getSpec(pluginrpc.NewClient(runner, pluginrpc.ClientWithSpecCache(cacheDirPath)))
+	require.Equal(t, int64(2), starts.Load())
+
+	// Invalidating gets the Spec from the plugin again.
+	client.InvalidateSpec()
+	entries, err = os.ReadDir(cacheDirPath)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	getSpec(client)
+	require.Equal(t, int64(4), starts.Load())
+}
+
+func TestExecServeRunnerRecycle(t *testing.T) {
+	t.Parallel()
+
+	testRecycle := func(t *testing.T, expectedStarts int, options ...pluginrpc.ExecRunnerOption) {
+		var starts int
+		runner := pluginrpc.NewExecServeRunner(
+			echoPluginProgramName,
+			append(options, pluginrpc.ExecRunnerWithCmdOption(func(*exec.Cmd) { starts++ }))...,
+		)
+		t.Cleanup(func() { require.NoError(t, runner.Close()) })
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner))
+		require.NoError(t, err)
+		// The first call also gets the protocol version and Spec, for a total of 7 calls.
+		for i := 0; i < 5; i++ {
+			message := strconv.Itoa(i)
+			response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: message})
+			require.NoError(t, err)
+			require.Equal(t, message, response.GetMessage())
+		}
+		require.Equal(t, expectedStarts, starts)
+	}
+
+	t.Run("none", func(t *testing.T) {
+		t.Parallel()
+		testRecycle(t, 1)
+	})
+	t.Run("calls", func(t *testing.T) {
+		t.Parallel()
+		testRecycle(t, 4, pluginrpc.ExecRunnerWithRecycleAfterCalls(2))
+	})
+	t.Run("duration", func(t *testing.T) {
+		t.Parallel()
+		testRecycle(t, 7, pluginrpc.ExecRunnerWithRecycleAfterDuration(time.Nanosecond))
+	})
+	t.Run("rss", func(t *testing.T) {
+		t.Parallel()
+		if runtime.GOOS != "linux" {
+			t.Skip("RSS is only supported on Linux")
+		}
+		testRecycle(t, 7, pluginrpc.ExecRunnerWithRecycleAfterRSSBytes(1))
+	})
+}
+
+func forEachDimension(t *testing.T, f func(*testing.T, pluginrpc.Client), clientOptions ...pluginrpc.ClientOption) {
 	for _, format := range allTestFormats {
-		for j, newClient := range []func(...pluginrpc.ClientOption) (pluginrpc.Client, error){newExecRunnerClient, newServerRunnerClient} {
+		for j, newClient := range []func(*testing.T, ...pluginrpc.ClientOption) (pluginrpc.Client, error){
+			newExecRunnerClient,
+			newServerRunnerClient,
+			newExecServeRunnerClient,
+			newFlowControlExecServeRunnerClient,
+		} {
 			j := j
 			format := format
 			newClient := newClient
@@ -137,7 +1051,7 @@ func forEachDimension(t *testing.T, f func(*testing.T, pluginrpc.Client)) {
 				format.String()+strconv.Itoa(j),
 				func(t *testing.T) {
 					t.Parallel()
-					client, err := newClient(pluginrpc.ClientWithFormat(format))
+					client, err := newClient(t, append(slices.Clone(clientOptions), pluginrpc.ClientWithFormat(format))...)
 					require.NoError(t, err)
 					f(t, client)
 				},
@@ -146,11 +1060,24 @@ func forEachDimension(t *testing.T, f func(*testing.T, pluginrpc.Client)) {
 	}
 }
 
-func newExecRunnerClient(clientOptions ...pluginrpc.ClientOption) (pluginrpc.Client, error) {
+func newExecRunnerClient(_ *testing.T, clientOptions ...pluginrpc.ClientOption) (pluginrpc.Client, error) {
 	return pluginrpc.NewClient(pluginrpc.NewExecRunner(echoPluginProgramName), clientOptions...), nil
 }
 
-func newServerRunnerClient(clientOptions ...pluginrpc.ClientOption) (pluginrpc.Client, error) {
+func newExecServeRunnerClient(t *testing.T, clientOptions ...pluginrpc.ClientOption) (pluginrpc.Client, error) {
+	runner := pluginrpc.NewExecServeRunner(echoPluginProgramName)
+	t.Cleanup(func() { require.NoError(t, runner.Close()) })
+	return pluginrpc.NewClient(runner, clientOptions...), nil
+}
+
+func newFlowControlExecServeRunnerClient(t *testing.T, clientOptions ...pluginrpc.ClientOption) (pluginrpc.Client, error) {
+	// A small window results in many window updates within a single call.
+	runner := pluginrpc.NewExecServeRunner(echoPluginProgramName, pluginrpc.ExecRunnerWithFlowControlWindow(16))
+	t.Cleanup(func() { require.NoError(t, runner.Close()) })
+	return pluginrpc.NewClient(runner, clientOptions...), nil
+}
+
+func newServerRunnerClient(_ *testing.T, clientOptions ...pluginrpc.ClientOption) (pluginrpc.Client, error) {
 	server, err := newServer()
 	if err != nil {
 		return nil, err
@@ -159,11 +1086,20 @@ func newServerRunnerClient(clientOptions ...pluginrpc.ClientOption) (pluginrpc.C
 }
 
 func newServer() (pluginrpc.Server, error) {
-	spec, err := examplev1pluginrpc.EchoServiceSpecBuilder{
-		// Note that EchoList does not have a ProcedureBuilder and will default to path being the only arg.
-		EchoRequest: []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("echo", "request")},
-		EchoError:   []pluginrpc.ProcedureOption{pluginrpc.ProcedureWithArgs("echo", "error")},
-	}.Build()
+	spec, err := examplev1pluginrpc.DefaultEchoServiceSpec(
+		pluginrpc.SpecWithDescriptors(examplev1.File_pluginrpc_example_v1_example_proto),
+	)
+	if err != nil {
+		return nil, err
+	}
+	info, err := pluginrpc.NewInfo(
+		pluginrpc.InfoWithLicense(
+			pluginrpc.License{
+				SPDXID: "Apache-2.0",
+				URL:    "https://github.com/pluginrpc/pluginrpc-go/blob/main/LICENSE",
+			},
+		),
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -172,7 +1108,35 @@ func newServer() (pluginrpc.Server, error) {
 	echoServiceHandler := newEchoServiceHandler()
 	echoServiceServer := examplev1pluginrpc.NewEchoServiceServer(handler, echoServiceHandler)
 	examplev1pluginrpc.RegisterEchoServiceServer(serverRegistrar, echoServiceServer)
-	return pluginrpc.NewServer(spec, serverRegistrar)
+	return pluginrpc.NewServer(spec, serverRegistrar, pluginrpc.ServerWithInfo(info))
+}
+
+// echoListServiceHandler only implements EchoList.
+type echoListServiceHandler struct {
+	examplev1pluginrpc.UnimplementedEchoServiceHandler
+}
+
+func (echoListServiceHandler) EchoList(context.Context, *examplev1.EchoListRequest) (*examplev1.EchoListResponse, error) {
+	return &examplev1.EchoListResponse{List: []string{"foo"}}, nil
+}
+
+// upperEchoServiceHandler echoes messages in upper case, as the host services of TestHostServices.
+type upperEchoServiceHandler struct {
+	examplev1pluginrpc.UnimplementedEchoServiceHandler
+
+	suffix string
+}
+
+func (u upperEchoServiceHandler) EchoRequest(
+	_ context.Context,
+	request *examplev1.EchoRequestRequest,
+) (*examplev1.EchoRequestResponse, error) {
+	if request.GetMessage() == "secret" {
+		return nil, pluginrpc.NewErrorf(pluginrpc.CodePermissionDenied, "no secrets")
+	}
+	return &examplev1.EchoRequestResponse{
+		Message: strings.ToUpper(request.GetMessage()) + u.suffix,
+	}, nil
 }
 
 type echoServiceHandler struct{}
@@ -182,9 +1146,20 @@ func newEchoServiceHandler() *echoServiceHandler {
 }
 
 func (*echoServiceHandler) EchoRequest(
-	_ context.Context,
+	ctx context.Context,
 	request *examplev1.EchoRequestRequest,
 ) (*examplev1.EchoRequestResponse, error) {
+	if _, err := io.Copy(pluginrpc.ResponsePayload(ctx), pluginrpc.RequestPayload(ctx)); err != nil {
+		return nil, err
+	}
+	// If the host provides host services, the host echoes the message.
+	if client, ok := pluginrpc.HostServicesClient(ctx); ok {
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+		if err != nil {
+			return nil, err
+		}
+		return echoServiceClient.EchoRequest(ctx, request)
+	}
 	return &examplev1.EchoRequestResponse{
 		Message: request.GetMessage(),
 	}, nil
@@ -208,3 +1183,35 @@ func (*echoServiceHandler) EchoError(
 ) (*examplev1.EchoErrorResponse, error) {
 	return nil, pluginrpc.NewError(pluginrpc.Code(request.GetCode()), errors.New(request.GetMessage()))
 }
+
+func (*echoServiceHandler) EchoBidi(
+	_ context.Context,
+	receive func() (*examplev1.EchoBidiRequest, error),
+	send func(*examplev1.EchoBidiResponse) error,
+) error {
+	for {
+		request, err := receive()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := send(&examplev1.EchoBidiResponse{Message: request.GetMessage()}); err != nil {
+			return err
+		}
+	}
+}
+
+func (*echoServiceHandler) EchoStream(
+	_ context.Context,
+	request *examplev1.EchoStreamRequest,
+	send func(*examplev1.EchoStreamResponse) error,
+) error {
+	for _, message := range request.GetMessages() {
+		if err := send(&examplev1.EchoStreamResponse{Message: message}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pluginrpcconformance checks that a plugin conforms to the PluginRPC protocol.
+//
+// The checks only rely on the wire protocol, and can be run against a plugin written
+// in any language. See the conformance command of the pluginrpc CLI to run the checks
+// from the command line.
+package pluginrpcconformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"pluginrpc.com/pluginrpc"
+)
+
+// Result is the result of a single check.
+type Result struct {
+	// Name is the name of the check, for example "spec/json" or
+	// "empty-request/binary/foo.v1.FooService/Foo".
+	Name string
+	// Violation describes how the plugin violated the protocol.
+	//
+	// This is empty if the check passed or was skipped.
+	Violation string
+	// SkipReason describes why the check was skipped.
+	//
+	// This is empty if the check was run.
+	SkipReason string
+}
+
+// Passed returns true if the check was run and the plugin did not violate the protocol.
+func (r Result) Passed() bool {
+	return r.Violation == "" && r.SkipReason == ""
+}
+
+// RunOption is an option for Run.
+type RunOption func(*runOptions)
+
+// RunWithInvocationTimeout returns a new RunOption that fails a check if a single
+// invocation of the plugin does not complete within the given duration.
+//
+// The default is 10 seconds.
+func RunWithInvocationTimeout(timeout time.Duration) RunOption {
+	return func(runOptions *runOptions) {
+		runOptions.invocationTimeout = timeout
+	}
+}
+
+// RunWithoutProcedureCalls returns a new RunOption that skips the checks that call the
+// Procedures of the plugin.
+//
+// By default, every Procedure in the Spec is called with an empty request and with a
+// malformed request. Use this option if calling Procedures of the plugin has side effects.
+func RunWithoutProcedureCalls() RunOption {
+	return func(runOptions *runOptions) {
+		runOptions.withoutProcedureCalls = true
+	}
+}
+
+// Run runs all checks against the plugin run by the Runner, and returns their Results
+// in the order they were run.
+//
+// The checks cover:
+//
+//   - --protocol prints a supported protocol version.
+//   - --spec prints a valid Spec in every Format, and the Specs are equal.
+//   - Unknown Formats and unknown args are rejected with a non-zero exit code.
+//   - Every Procedure responds to an empty request with valid Responses.
+//   - Every Procedure responds to a malformed request with an error Response.
+//   - Every error in a Response has a valid Code.
+//
+// Procedures that are replay protected are skipped, as they cannot be called without
+// a nonce.
+//
+// An error is returned if the plugin could not be run at all, or if the context is done.
+// Protocol violations are not errors, and are reported in the Results.
+func Run(ctx context.Context, runner pluginrpc.Runner, options ...RunOption) ([]Result, error) {
+	runOptions := newRunOptions()
+	for _, option := range options {
+		option(runOptions)
+	}
+	checker := &checker{
+		runner:            runner,
+		invocationTimeout: runOptions.invocationTimeout,
+	}
+	if err := checker.checkProtocol(ctx); err != nil {
+		return nil, err
+	}
+	spec, err := checker.checkSpec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checker.checkRejected(ctx, "unknown-format", "--spec", "--format", "pluginrpc-conformance-unknown"); err != nil {
+		return nil, err
+	}
+	if err := checker.checkRejected(ctx, "unknown-args", "pluginrpc-conformance-unknown"); err != nil {
+		return nil, err
+	}
+	if spec == nil {
+		checker.skip("procedures", "no valid Spec")
+		return checker.results, nil
+	}
+	for _, procedure := range spec.Procedures() {
+		for _, format := range []pluginrpc.Format{pluginrpc.FormatBinary, pluginrpc.FormatJSON} {
+			if err := checker.checkProcedure(ctx, procedure, format, runOptions.withoutProcedureCalls); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return checker.results, nil
+}
+
+// *** PRIVATE ***
+
+const protocolVersion = 1
+
+var (
+	formatArgs = map[pluginrpc.Format][]string{
+		pluginrpc.FormatBinary: {"--format", "binary"},
+		pluginrpc.FormatJSON:   {"--format", "json"},
+	}
+	// malformedRequests are requests that are not valid in the given Format.
+	malformedRequests = map[pluginrpc.Format][]byte{
+		// An invalid field number of zero.
+		pluginrpc.FormatBinary: {0x00, 0xff, 0xff},
+		pluginrpc.FormatJSON:   []byte("{"),
+	}
+)
+
+type runOptions struct {
+	invocationTimeout     time.Duration
+	withoutProcedureCalls bool
+}
+
+func newRunOptions() *runOptions {
+	return &runOptions{
+		invocationTimeout: 10 * time.Second,
+	}
+}
+
+type checker struct {
+	runner            pluginrpc.Runner
+	invocationTimeout time.Duration
+	results           []Result
+}
+
+func (c *checker) checkProtocol(ctx context.Context) error {
+	invocation, err := c.invoke(ctx, nil, "--protocol")
+	if err != nil {
+		return err
+	}
+	c.report("protocol", func() string {
+		if violation := invocation.violation(); violation != "" {
+			return violation
+		}
+		value := strings.TrimSpace(string(invocation.stdout))
+		version, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Sprintf("--protocol printed %q, expected an integer", value)
+		}
+		if version != protocolVersion {
+			return fmt.Sprintf("--protocol printed unsupported version %d, expected %d", version, protocolVersion)
+		}
+		return ""
+	}())
+	return nil
+}
+
+// checkSpec checks the Spec in every Format, returning the Spec if it is valid.
+func (c *checker) checkSpec(ctx context.Context) (pluginrpc.Spec, error) {
+	var firstProtoSpec *pluginrpcv1.Spec
+	var spec pluginrpc.Spec
+	for _, format := range []pluginrpc.Format{pluginrpc.FormatBinary, pluginrpc.FormatJSON} {
+		invocation, err := c.invoke(ctx, nil, append([]string{"--spec"}, formatArgs[format]...)...)
+		if err != nil {
+			return nil, err
+		}
+		c.report("spec/"+format.String(), func() string {
+			if violation := invocation.violation(); violation != "" {
+				return violation
+			}
+			protoSpec := &pluginrpcv1.Spec{}
+			if err := unmarshal(format, invocation.stdout, protoSpec); err != nil {
+				return fmt.Sprintf("--spec printed an invalid Spec: %v", err)
+			}
+			formatSpec, err := pluginrpc.NewSpecForProto(protoSpec)
+			if err != nil {
+				return fmt.Sprintf("--spec printed an invalid Spec: %v", err)
+			}
+			if firstProtoSpec == nil {
+				firstProtoSpec = protoSpec
+				spec = formatSpec
+			} else if !proto.Equal(firstProtoSpec, protoSpec) {
+				return "--spec printed a different Spec than for format binary"
+			}
+			return ""
+		}())
+	}
+	return spec, nil
+}
+
+// checkRejected checks that the plugin exits with a non-zero exit code for the given args.
+func (c *checker) checkRejected(ctx context.Context, name string, args ...string) error {
+	invocation, err := c.invoke(ctx, nil, args...)
+	if err != nil {
+		return err
+	}
+	c.report(name, func() string {
+		if invocation.timedOut {
+			return invocation.violation()
+		}
+		if invocation.exitCode == 0 {
+			return fmt.Sprintf("%s exited with code 0, expected a non-zero exit code", strings.Join(args, " "))
+		}
+		return ""
+	}())
+	return nil
+}
+
+func (c *checker) checkProcedure(
+	ctx context.Context,
+	procedure pluginrpc.Procedure,
+	format pluginrpc.Format,
+	withoutProcedureCalls bool,
+) error {
+	emptyName := "empty-request/" + format.String() + procedure.Path()
+	malformedName := "malformed-request/" + format.String() + procedure.Path()
+	var skipReason string
+	switch {
+	case withoutProcedureCalls:
+		skipReason = "procedure calls disabled"
+	case procedure.ReplayProtected():
+		skipReason = "procedure is replay protected"
+	}
+	if skipReason != "" {
+		c.skip(emptyName, skipReason)
+		c.skip(malformedName, skipReason)
+		return nil
+	}
+	args := procedure.Args()
+	if len(args) == 0 {
+		args = []string{procedure.Path()}
+	}
+	args = append(slices.Clone(formatArgs[format]), args...)
+
+	invocation, err := c.invoke(ctx, nil, args...)
+	if err != nil {
+		return err
+	}
+	c.report(emptyName, func() string {
+		if violation := invocation.violation(); violation != "" {
+			return violation
+		}
+		_, violation := parseResponses(format, invocation.stdout)
+		return violation
+	}())
+
+	invocation, err = c.invoke(ctx, malformedRequests[format], args...)
+	if err != nil {
+		return err
+	}
+	c.report(malformedName, func() string {
+		if violation := invocation.violation(); violation != "" {
+			return violation
+		}
+		responses, violation := parseResponses(format, invocation.stdout)
+		if violation != "" {
+			return violation
+		}
+		if len(responses) == 0 || responses[len(responses)-1].GetError() == nil {
+			return "the malformed request was accepted, expected an error Response"
+		}
+		return ""
+	}())
+	return nil
+}
+
+func (c *checker) report(name string, violation string) {
+	c.results = append(c.results, Result{Name: name, Violation: violation})
+}
+
+func (c *checker) skip(name string, skipReason string) {
+	c.results = append(c.results, Result{Name: name, SkipReason: skipReason})
+}
+
+type invocation struct {
+	args     []string
+	stdout   []byte
+	exitCode int
+	timedOut bool
+}
+
+// violation returns a violation if the invocation timed out or exited with a non-zero exit code.
+func (i *invocation) violation() string {
+	if i.timedOut {
+		return fmt.Sprintf("%s did not exit within the invocation timeout", strings.Join(i.args, " "))
+	}
+	if i.exitCode != 0 {
+		return fmt.Sprintf("%s exited with code %d", strings.Join(i.args, " "), i.exitCode)
+	}
+	return ""
+}
+
+// invoke invokes the plugin with the given args and stdin.
+//
+// An error is returned if the plugin could not be run, or if the context is done.
+func (c *checker) invoke(ctx context.Context, stdin []byte, args ...string) (*invocation, error) {
+	invokeCtx, cancel := context.WithTimeout(ctx, c.invocationTimeout)
+	defer cancel()
+	stdout := bytes.NewBuffer(nil)
+	err := c.runner.Run(
+		invokeCtx,
+		pluginrpc.Env{
+			Args:   args,
+			Stdin:  bytes.NewReader(stdin),
+			Stdout: stdout,
+			Stderr: io.Discard,
+		},
+	)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	invocation := &invocation{
+		args:   args,
+		stdout: stdout.Bytes(),
+	}
+	if err != nil {
+		exitError := &pluginrpc.ExitError{}
+		switch {
+		case invokeCtx.Err() != nil:
+			invocation.timedOut = true
+		case errors.As(err, &exitError):
+			invocation.exitCode = exitError.ExitCode()
+		default:
+			return nil, err
+		}
+	}
+	return invocation, nil
+}
+
+// parseResponses parses either a single Response, or a sequence of frames containing
+// Responses as written by streaming Procedures, and checks that every Response is valid.
+func parseResponses(format pluginrpc.Format, data []byte) ([]*pluginrpcv1.Response, string) {
+	response := &pluginrpcv1.Response{}
+	if err := unmarshal(format, data, response); err == nil && len(data) > 0 {
+		return []*pluginrpcv1.Response{response}, checkResponse(response)
+	}
+	var responses []*pluginrpcv1.Response
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, "stdout is neither a valid Response nor a sequence of frames"
+		}
+		size := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(size) {
+			return nil, "stdout is neither a valid Response nor a sequence of frames"
+		}
+		response := &pluginrpcv1.Response{}
+		if err := unmarshal(format, data[:size], response); err != nil {
+			return nil, fmt.Sprintf("stdout contains a frame that is not a valid Response: %v", err)
+		}
+		if violation := checkResponse(response); violation != "" {
+			return nil, violation
+		}
+		responses = append(responses, response)
+		data = data[size:]
+	}
+	return responses, ""
+}
+
+func checkResponse(response *pluginrpcv1.Response) string {
+	protoError := response.GetError()
+	if protoError == nil {
+		return ""
+	}
+	if _, err := pluginrpc.CodeForProto(protoError.GetCode()); err != nil {
+		return fmt.Sprintf("the Response contains an error with an invalid code: %v", err)
+	}
+	return ""
+}
+
+func unmarshal(format pluginrpc.Format, data []byte, message proto.Message) error {
+	if format == pluginrpc.FormatJSON {
+		return protojson.UnmarshalOptions{
+			Resolver:       anyResolver{Types: protoregistry.GlobalTypes},
+			DiscardUnknown: true,
+		}.Unmarshal(data, message)
+	}
+	return proto.Unmarshal(data, message)
+}
+
+// unknownMessageType is a message type without fields, used for the values of Any
+// messages of unknown types. Unlike google.protobuf.Empty, it has no special JSON
+// representation.
+var unknownMessageType = newUnknownMessageType()
+
+// anyResolver resolves the types of Any values that are not known to the checker to
+// unknownMessageType, so that Responses of any plugin can be parsed from JSON.
+type anyResolver struct {
+	*protoregistry.Types
+}
+
+func (a anyResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	messageType, err := a.Types.FindMessageByURL(url)
+	if errors.Is(err, protoregistry.NotFound) {
+		return unknownMessageType, nil
+	}
+	return messageType, err
+}
+
+func newUnknownMessageType() protoreflect.MessageType {
+	fileDescriptor, err := protodesc.NewFile(
+		&descriptorpb.FileDescriptorProto{
+			Name:    proto.String("pluginrpcconformance/unknown.proto"),
+			Package: proto.String("pluginrpcconformance"),
+			MessageType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("Unknown"),
+				},
+			},
+		},
+		nil,
+	)
+	if err != nil {
+		panic(err)
+	}
+	return dynamicpb.NewMessageType(fileDescriptor.Messages().Get(0))
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpcconformance_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	"pluginrpc.com/pluginrpc/pluginrpcconformance"
+)
+
+func TestRunEchoPlugin(t *testing.T) {
+	t.Parallel()
+
+	results, err := pluginrpcconformance.Run(context.Background(), pluginrpc.NewExecRunner("echo-plugin"))
+	require.NoError(t, err)
+	// protocol, two specs, two rejections, and four checks for each of the five procedures.
+	require.Len(t, results, 25)
+	for _, result := range results {
+		require.True(t, result.Passed(), result)
+	}
+
+	results, err = pluginrpcconformance.Run(
+		context.Background(),
+		pluginrpc.NewExecRunner("echo-plugin"),
+		pluginrpcconformance.RunWithoutProcedureCalls(),
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 25)
+	require.Equal(t, "procedure calls disabled", results[len(results)-1].SkipReason)
+}
+
+func TestRunViolations(t *testing.T) {
+	t.Parallel()
+
+	// A plugin that prints the same output for any args.
+	results, err := pluginrpcconformance.Run(
+		context.Background(),
+		runnerFunc(func(_ context.Context, env pluginrpc.Env) error {
+			_, err := env.Stdout.Write([]byte("hello\n"))
+			return err
+		}),
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		map[string]bool{
+			"protocol":       true,
+			"spec/binary":    true,
+			"spec/json":      true,
+			"unknown-format": true,
+			"unknown-args":   true,
+			"procedures":     false,
+		},
+		violations(results),
+	)
+
+	// A plugin that never exits.
+	results, err = pluginrpcconformance.Run(
+		context.Background(),
+		runnerFunc(func(ctx context.Context, _ pluginrpc.Env) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		pluginrpcconformance.RunWithInvocationTimeout(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+	require.Contains(t, results[0].Violation, "did not exit")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pluginrpcconformance.Run(ctx, pluginrpc.NewExecRunner("echo-plugin"))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+type runnerFunc func(context.Context, pluginrpc.Env) error
+
+func (r runnerFunc) Run(ctx context.Context, env pluginrpc.Env) error {
+	return r(ctx, env)
+}
+
+// violations returns whether each check found a violation, by name.
+func violations(results []pluginrpcconformance.Result) map[string]bool {
+	violations := make(map[string]bool, len(results))
+	for _, result := range results {
+		violations[result.Name] = result.Violation != ""
+	}
+	return violations
+}
@@ -0,0 +1,394 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pluginrpchttp exposes plugins as network services over HTTP.
+//
+// NewHandler returns an http.Handler that serves each unary Procedure of a plugin at its
+// path with the unary protocol of Connect, so that plugins can be called with Connect
+// clients and tools such as curl and buf curl without rewriting them:
+//
+//	curl --header "Content-Type: application/json" --data '{"message":"hello"}' \
+//	  http://localhost:8080/pluginrpc.example.v1.EchoService/EchoRequest
+package pluginrpchttp
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"pluginrpc.com/pluginrpc"
+)
+
+// NewHandler returns a new http.Handler that serves the Procedures of the Spec by calling
+// them with the Client.
+//
+// Each unary Procedure is served at its path with the unary protocol of Connect, with
+// requests and responses encoded as application/proto or application/json. Errors are
+// returned as Connect errors, with their Code translated to an HTTP status code, see
+// HTTPStatusForCode. Only errors returned by the plugin as a pluginrpc.Error have their
+// message and details returned, other errors, such as errors running the plugin, only have
+// their Code returned so that internal details of the host are not exposed. Procedures that are streaming are not served, and calls to them
+// return an error with pluginrpc.CodeUnimplemented.
+//
+// The request and response types of Procedures are resolved with the descriptors of the
+// Spec, see pluginrpc.ClientWithSpecDescriptors, or with protoregistry.GlobalFiles if the
+// Spec has no descriptors. Returns an error if the types of a Procedure cannot be resolved.
+//
+// The Spec is typically the Spec of the Client:
+//
+//	spec, err := client.Spec(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	handler, err := pluginrpchttp.NewHandler(client, spec)
+//	if err != nil {
+//		return err
+//	}
+//	return http.ListenAndServe(":8080", handler)
+func NewHandler(client pluginrpc.Client, spec pluginrpc.Spec, options ...HandlerOption) (http.Handler, error) {
+	return newHandler(client, spec, options...)
+}
+
+// HandlerOption is an option for NewHandler.
+type HandlerOption func(*handlerOptions)
+
+// HandlerWithMaxRequestSize returns a new HandlerOption that limits the size of request
+// bodies to the given number of bytes, after decompression.
+//
+// If a request exceeds the limit, an error with pluginrpc.CodeResourceExhausted is returned.
+// This protects the host from running out of memory, including with small gzip requests
+// that decompress to large bodies.
+//
+// The default is 4 MiB. A value that is not positive results in the default being used.
+func HandlerWithMaxRequestSize(maxRequestSize int64) HandlerOption {
+	return func(handlerOptions *handlerOptions) {
+		handlerOptions.maxRequestSize = maxRequestSize
+	}
+}
+
+// HTTPStatusForCode returns the HTTP status code for the given Code, as defined by the
+// Connect protocol.
+//
+// Returns http.StatusInternalServerError for unknown Codes.
+func HTTPStatusForCode(code pluginrpc.Code) int {
+	switch code {
+	case pluginrpc.CodeCanceled:
+		// Client Closed Request, as used by Connect.
+		return 499
+	case pluginrpc.CodeInvalidArgument, pluginrpc.CodeFailedPrecondition, pluginrpc.CodeOutOfRange:
+		return http.StatusBadRequest
+	case pluginrpc.CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case pluginrpc.CodeNotFound:
+		return http.StatusNotFound
+	case pluginrpc.CodeAlreadyExists, pluginrpc.CodeAborted:
+		return http.StatusConflict
+	case pluginrpc.CodePermissionDenied:
+		return http.StatusForbidden
+	case pluginrpc.CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case pluginrpc.CodeUnimplemented:
+		return http.StatusNotImplemented
+	case pluginrpc.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case pluginrpc.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// *** PRIVATE ***
+
+const (
+	contentTypeProto = "application/proto"
+	contentTypeJSON  = "application/json"
+
+	// maxTimeoutDigits is the maximum number of digits of the Connect-Timeout-Ms header.
+	maxTimeoutDigits = 10
+	// defaultMaxRequestSize is the default maximum size of request bodies after decompression,
+	// which is the default of Connect.
+	defaultMaxRequestSize = 4 * 1024 * 1024
+)
+
+type handler struct {
+	client pluginrpc.Client
+	// pathToMethodDescriptor has the unary Procedures. Streaming Procedures map to nil.
+	pathToMethodDescriptor map[string]protoreflect.MethodDescriptor
+	// types resolves the types of Any values when marshaling and unmarshaling JSON.
+	types          *dynamicpb.Types
+	maxRequestSize int64
+}
+
+func newHandler(client pluginrpc.Client, spec pluginrpc.Spec, options ...HandlerOption) (*handler, error) {
+	handlerOptions := newHandlerOptions()
+	for _, option := range options {
+		option(handlerOptions)
+	}
+	if handlerOptions.maxRequestSize <= 0 {
+		handlerOptions.maxRequestSize = defaultMaxRequestSize
+	}
+	files := protoregistry.GlobalFiles
+	if fileDescriptorSet := spec.FileDescriptorSet(); fileDescriptorSet != nil {
+		var err error
+		files, err = protodesc.NewFiles(fileDescriptorSet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FileDescriptorSet in Spec: %w", err)
+		}
+	}
+	pathToMethodDescriptor := make(map[string]protoreflect.MethodDescriptor)
+	for _, procedure := range spec.Procedures() {
+		methodDescriptor, err := findMethodDescriptor(files, procedure.Path())
+		if err != nil {
+			return nil, err
+		}
+		if methodDescriptor.IsStreamingClient() || methodDescriptor.IsStreamingServer() {
+			methodDescriptor = nil
+		}
+		pathToMethodDescriptor[procedure.Path()] = methodDescriptor
+	}
+	return &handler{
+		client:                 client,
+		pathToMethodDescriptor: pathToMethodDescriptor,
+		types:                  dynamicpb.NewTypes(files),
+		maxRequestSize:         handlerOptions.maxRequestSize,
+	}, nil
+}
+
+func (h *handler) ServeHTTP(responseWriter http.ResponseWriter, request *http.Request) {
+	methodDescriptor, ok := h.pathToMethodDescriptor[request.URL.Path]
+	if !ok {
+		http.NotFound(responseWriter, request)
+		return
+	}
+	if request.Method != http.MethodPost {
+		responseWriter.Header().Set("Allow", http.MethodPost)
+		http.Error(responseWriter, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	contentType := parseContentType(request.Header.Get("Content-Type"))
+	if contentType != contentTypeProto && contentType != contentTypeJSON {
+		responseWriter.Header().Set("Accept-Post", contentTypeProto+", "+contentTypeJSON)
+		http.Error(responseWriter, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	data, err := h.call(request, contentType, methodDescriptor)
+	if err != nil {
+		writeError(responseWriter, err)
+		return
+	}
+	responseWriter.Header().Set("Content-Type", contentType)
+	responseWriter.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	responseWriter.WriteHeader(http.StatusOK)
+	_, _ = responseWriter.Write(data)
+}
+
+// call calls the Procedure with the request, and returns the marshaled response.
+func (h *handler) call(
+	request *http.Request,
+	contentType string,
+	methodDescriptor protoreflect.MethodDescriptor,
+) ([]byte, error) {
+	if methodDescriptor == nil {
+		return nil, pluginrpc.NewErrorf(pluginrpc.CodeUnimplemented, "procedure %q is streaming, only unary procedures are served", request.URL.Path)
+	}
+	ctx, cancel, err := withConnectTimeout(request.Context(), request.Header.Get("Connect-Timeout-Ms"))
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	data, err := h.readBody(request)
+	if err != nil {
+		return nil, err
+	}
+	var protoRequest proto.Message
+	if contentType == contentTypeJSON {
+		protoRequest, err = pluginrpc.NewRequestForJSON(data, methodDescriptor.Input())
+		if err != nil {
+			return nil, pluginrpc.NewErrorf(pluginrpc.CodeInvalidArgument, "invalid request: %w", err)
+		}
+	} else {
+		protoRequest = dynamicpb.NewMessage(methodDescriptor.Input())
+		if err := proto.Unmarshal(data, protoRequest); err != nil {
+			return nil, pluginrpc.NewErrorf(pluginrpc.CodeInvalidArgument, "invalid request: %w", err)
+		}
+	}
+	protoResponse := dynamicpb.NewMessage(methodDescriptor.Output())
+	if err := h.client.Call(ctx, request.URL.Path, protoRequest, protoResponse); err != nil {
+		return nil, err
+	}
+	if contentType == contentTypeJSON {
+		return protojson.MarshalOptions{Resolver: h.types}.Marshal(protoResponse)
+	}
+	return proto.Marshal(protoResponse)
+}
+
+// readBody reads the body of the request, decompressing it if needed.
+func (h *handler) readBody(request *http.Request) ([]byte, error) {
+	var body io.Reader = request.Body
+	switch contentEncoding := request.Header.Get("Content-Encoding"); contentEncoding {
+	case "", "identity":
+	case "gzip":
+		gzipReader, err := gzip.NewReader(request.Body)
+		if err != nil {
+			return nil, pluginrpc.NewErrorf(pluginrpc.CodeInvalidArgument, "invalid gzip request: %w", err)
+		}
+		defer func() { _ = gzipReader.Close() }()
+		body = gzipReader
+	default:
+		return nil, pluginrpc.NewErrorf(pluginrpc.CodeUnimplemented, "unsupported Content-Encoding %q, supported are identity and gzip", contentEncoding)
+	}
+	// Read one more byte to detect requests that exceed the limit.
+	data, err := io.ReadAll(io.LimitReader(body, h.maxRequestSize+1))
+	if err != nil {
+		return nil, pluginrpc.NewErrorf(pluginrpc.CodeInvalidArgument, "failed to read request: %w", err)
+	}
+	if int64(len(data)) > h.maxRequestSize {
+		return nil, pluginrpc.NewErrorf(pluginrpc.CodeResourceExhausted, "request exceeds the maximum size of %d bytes", h.maxRequestSize)
+	}
+	return data, nil
+}
+
+// connectError is the JSON body of an error in the unary protocol of Connect.
+type connectError struct {
+	Code    string                `json:"code"`
+	Message string                `json:"message,omitempty"`
+	Details []*connectErrorDetail `json:"details,omitempty"`
+}
+
+type connectErrorDetail struct {
+	// Type is the fully-qualified name of the type of the detail.
+	Type string `json:"type"`
+	// Value is the base64-encoded binary of the detail.
+	Value string `json:"value"`
+}
+
+// writeError writes the error as a Connect error.
+//
+// Errors that are not a *pluginrpc.Error, such as errors running the plugin, only have their
+// Code written, as their message may expose internal details of the host.
+func writeError(responseWriter http.ResponseWriter, err error) {
+	pluginrpcError := pluginrpc.WrapError(err)
+	connectError := &connectError{
+		Code: pluginrpcError.Code().String(),
+	}
+	if !errors.As(err, new(*pluginrpc.Error)) {
+		writeConnectError(responseWriter, pluginrpcError.Code(), connectError)
+		return
+	}
+	connectError.Message = pluginrpcError.ToProto().GetMessage()
+	for _, detail := range pluginrpcError.Details() {
+		anyDetail, ok := detail.(*anypb.Any)
+		if !ok {
+			var err error
+			if anyDetail, err = anypb.New(detail); err != nil {
+				continue
+			}
+		}
+		connectError.Details = append(
+			connectError.Details,
+			&connectErrorDetail{
+				Type:  string(anyDetail.MessageName()),
+				Value: base64.RawStdEncoding.EncodeToString(anyDetail.GetValue()),
+			},
+		)
+	}
+	writeConnectError(responseWriter, pluginrpcError.Code(), connectError)
+}
+
+func writeConnectError(responseWriter http.ResponseWriter, code pluginrpc.Code, connectError *connectError) {
+	data, err := json.Marshal(connectError)
+	if err != nil {
+		http.Error(responseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	responseWriter.Header().Set("Content-Type", contentTypeJSON)
+	responseWriter.WriteHeader(HTTPStatusForCode(code))
+	_, _ = responseWriter.Write(data)
+}
+
+// withConnectTimeout returns a context with the timeout of the Connect-Timeout-Ms header,
+// if set.
+func withConnectTimeout(ctx context.Context, value string) (context.Context, context.CancelFunc, error) {
+	if value == "" {
+		return ctx, func() {}, nil
+	}
+	if len(value) > maxTimeoutDigits {
+		return nil, nil, pluginrpc.NewErrorf(pluginrpc.CodeInvalidArgument, "invalid Connect-Timeout-Ms %q: more than %d digits", value, maxTimeoutDigits)
+	}
+	timeoutMillis, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, nil, pluginrpc.NewErrorf(pluginrpc.CodeInvalidArgument, "invalid Connect-Timeout-Ms %q", value)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMillis)*time.Millisecond)
+	return ctx, cancel, nil
+}
+
+// parseContentType returns the media type of the Content-Type header, or empty if invalid.
+func parseContentType(value string) string {
+	mediaType, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return ""
+	}
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return ""
+	}
+	return mediaType
+}
+
+// findMethodDescriptor returns the MethodDescriptor for the Procedure path, which must be
+// of the form "/package.Service/Method".
+func findMethodDescriptor(files *protoregistry.Files, procedurePath string) (protoreflect.MethodDescriptor, error) {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(procedurePath, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("procedure %q does not have a path of the form /package.Service/Method", procedurePath)
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: %w", procedurePath, err)
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: %q is not a service", procedurePath, serviceName)
+	}
+	methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(methodName))
+	if methodDescriptor == nil {
+		return nil, fmt.Errorf("no descriptors available for procedure %q: method %q not found", procedurePath, methodName)
+	}
+	return methodDescriptor, nil
+}
+
+type handlerOptions struct {
+	maxRequestSize int64
+}
+
+func newHandlerOptions() *handlerOptions {
+	return &handlerOptions{}
+}
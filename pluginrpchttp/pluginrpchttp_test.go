@@ -0,0 +1,224 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpchttp_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+	"pluginrpc.com/pluginrpc/pluginrpchttp"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	httpServer := newHTTPServer(t)
+	requestURL := httpServer.URL + examplev1pluginrpc.EchoServiceEchoRequestPath
+
+	response := post(t, requestURL, "application/json", []byte(`{"message":"hello"}`), nil)
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.Equal(t, "application/json", response.contentType)
+	require.JSONEq(t, `{"message":"hello"}`, string(response.body))
+
+	requestData, err := proto.Marshal(&examplev1.EchoRequestRequest{Message: "hello"})
+	require.NoError(t, err)
+	buffer := bytes.NewBuffer(nil)
+	gzipWriter := gzip.NewWriter(buffer)
+	_, err = gzipWriter.Write(requestData)
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+	response = post(t, requestURL, "application/proto", buffer.Bytes(), map[string]string{"Content-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.Equal(t, "application/proto", response.contentType)
+	echoRequestResponse := &examplev1.EchoRequestResponse{}
+	require.NoError(t, proto.Unmarshal(response.body, echoRequestResponse))
+	require.Equal(t, "hello", echoRequestResponse.GetMessage())
+
+	// Empty requests are valid.
+	response = post(t, requestURL, "application/json; charset=utf-8", nil, nil)
+	require.Equal(t, http.StatusOK, response.statusCode)
+	require.JSONEq(t, `{}`, string(response.body))
+}
+
+func TestHandlerErrors(t *testing.T) {
+	t.Parallel()
+
+	httpServer := newHTTPServer(t)
+	requestURL := httpServer.URL + examplev1pluginrpc.EchoServiceEchoRequestPath
+
+	response := post(t, httpServer.URL+examplev1pluginrpc.EchoServiceEchoErrorPath, "application/json", []byte(`{"code":"CODE_NOT_FOUND","message":"hello"}`), nil)
+	require.Equal(t, http.StatusNotFound, response.statusCode)
+	require.Equal(t, "application/json", response.contentType)
+	require.JSONEq(t, `{"code":"not_found","message":"hello"}`, string(response.body))
+
+	response = post(t, requestURL, "application/json", []byte(`{"unknown":1}`), nil)
+	require.Equal(t, http.StatusBadRequest, response.statusCode)
+	require.Contains(t, string(response.body), `"code":"invalid_argument"`)
+	response = post(t, requestURL, "application/json", nil, map[string]string{"Connect-Timeout-Ms": "soon"})
+	require.Equal(t, http.StatusBadRequest, response.statusCode)
+	response = post(t, requestURL, "application/json", []byte(`{"message":"hello"}`), map[string]string{"Content-Encoding": "br"})
+	require.Equal(t, http.StatusNotImplemented, response.statusCode)
+	require.Contains(t, string(response.body), `"code":"unimplemented"`)
+	response = post(t, requestURL, "text/plain", nil, nil)
+	require.Equal(t, http.StatusUnsupportedMediaType, response.statusCode)
+
+	// Only unary procedures are served.
+	response = post(t, httpServer.URL+examplev1pluginrpc.EchoServiceEchoStreamPath, "application/json", nil, nil)
+	require.Equal(t, http.StatusNotImplemented, response.statusCode)
+	response = post(t, httpServer.URL+"/foo.v1.FooService/Foo", "application/json", nil, nil)
+	require.Equal(t, http.StatusNotFound, response.statusCode)
+
+	httpResponse, err := http.Get(requestURL)
+	require.NoError(t, err)
+	require.NoError(t, httpResponse.Body.Close())
+	require.Equal(t, http.StatusMethodNotAllowed, httpResponse.StatusCode)
+}
+
+func TestHandlerInternalErrors(t *testing.T) {
+	t.Parallel()
+
+	spec, err := examplev1pluginrpc.DefaultEchoServiceSpec()
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(failingRunner{}, pluginrpc.ClientWithSpec(spec))
+	handler, err := pluginrpchttp.NewHandler(client, spec)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(httpServer.Close)
+
+	// Errors that are not returned by the plugin do not expose their message.
+	response := post(t, httpServer.URL+examplev1pluginrpc.EchoServiceEchoRequestPath, "application/json", []byte(`{"message":"hello"}`), nil)
+	require.Equal(t, http.StatusInternalServerError, response.statusCode)
+	require.JSONEq(t, `{"code":"unknown"}`, string(response.body))
+}
+
+func TestHandlerMaxRequestSize(t *testing.T) {
+	t.Parallel()
+
+	// The request decompresses to more than the default maximum of 4 MiB.
+	requestData, err := proto.Marshal(&examplev1.EchoRequestRequest{Message: strings.Repeat("a", 5*1024*1024)})
+	require.NoError(t, err)
+	buffer := bytes.NewBuffer(nil)
+	gzipWriter := gzip.NewWriter(buffer)
+	_, err = gzipWriter.Write(requestData)
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+	header := map[string]string{"Content-Encoding": "gzip"}
+
+	httpServer := newHTTPServer(t)
+	response := post(t, httpServer.URL+examplev1pluginrpc.EchoServiceEchoRequestPath, "application/proto", buffer.Bytes(), header)
+	require.Equal(t, http.StatusTooManyRequests, response.statusCode)
+	require.Contains(t, string(response.body), `"code":"resource_exhausted"`)
+
+	httpServer = newHTTPServer(t, pluginrpchttp.HandlerWithMaxRequestSize(8*1024*1024))
+	response = post(t, httpServer.URL+examplev1pluginrpc.EchoServiceEchoRequestPath, "application/proto", buffer.Bytes(), header)
+	require.Equal(t, http.StatusOK, response.statusCode)
+}
+
+func TestNewHandlerErrors(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := pluginrpc.NewProcedure("/foo.v1.FooService/Foo")
+	require.NoError(t, err)
+	spec, err := pluginrpc.NewSpec(procedure)
+	require.NoError(t, err)
+	_, err = pluginrpchttp.NewHandler(nil, spec)
+	require.ErrorContains(t, err, `no descriptors available for procedure "/foo.v1.FooService/Foo"`)
+}
+
+func TestHTTPStatusForCode(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, 499, pluginrpchttp.HTTPStatusForCode(pluginrpc.CodeCanceled))
+	require.Equal(t, http.StatusBadRequest, pluginrpchttp.HTTPStatusForCode(pluginrpc.CodeFailedPrecondition))
+	require.Equal(t, http.StatusTooManyRequests, pluginrpchttp.HTTPStatusForCode(pluginrpc.CodeResourceExhausted))
+	require.Equal(t, http.StatusInternalServerError, pluginrpchttp.HTTPStatusForCode(pluginrpc.CodeDataLoss))
+	require.Equal(t, http.StatusInternalServerError, pluginrpchttp.HTTPStatusForCode(pluginrpc.Code(100)))
+}
+
+type echoServiceHandler struct {
+	examplev1pluginrpc.UnimplementedEchoServiceHandler
+}
+
+func (echoServiceHandler) EchoRequest(_ context.Context, request *examplev1.EchoRequestRequest) (*examplev1.EchoRequestResponse, error) {
+	return &examplev1.EchoRequestResponse{Message: request.GetMessage()}, nil
+}
+
+func (echoServiceHandler) EchoError(_ context.Context, request *examplev1.EchoErrorRequest) (*examplev1.EchoErrorResponse, error) {
+	return nil, pluginrpc.NewError(pluginrpc.Code(request.GetCode()), errors.New(request.GetMessage()))
+}
+
+// failingRunner is a Runner that fails without running a plugin, like an ExecRunner for a
+// program that does not exist.
+type failingRunner struct{}
+
+func (failingRunner) Run(context.Context, pluginrpc.Env) error {
+	return errors.New("fork/exec /internal/path/to/plugin: no such file or directory")
+}
+
+type httpResponse struct {
+	statusCode  int
+	contentType string
+	body        []byte
+}
+
+func newHTTPServer(t *testing.T, options ...pluginrpchttp.HandlerOption) *httptest.Server {
+	server, err := examplev1pluginrpc.NewEchoServiceServerForHandler(
+		echoServiceHandler{},
+		pluginrpc.ServerForHandlerWithSpecOptions(
+			pluginrpc.SpecWithDescriptors(examplev1.File_pluginrpc_example_v1_example_proto),
+		),
+	)
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(pluginrpc.NewServerRunner(server), pluginrpc.ClientWithSpecDescriptors())
+	spec, err := client.Spec(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, spec.FileDescriptorSet())
+	handler, err := pluginrpchttp.NewHandler(client, spec, options...)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(httpServer.Close)
+	return httpServer
+}
+
+func post(t *testing.T, url string, contentType string, body []byte, header map[string]string) *httpResponse {
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", contentType)
+	for key, value := range header {
+		request.Header.Set(key, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, response.Body.Close()) }()
+	data, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return &httpResponse{
+		statusCode:  response.StatusCode,
+		contentType: response.Header.Get("Content-Type"),
+		body:        data,
+	}
+}
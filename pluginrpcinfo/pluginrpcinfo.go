@@ -0,0 +1,156 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pluginrpcinfo exposes build metadata about the pluginrpc-go library compiled into
+// the current binary, whether the binary is a host or a plugin.
+//
+// The metadata is printed by plugins on `--version`, and is meant to be included in bug reports.
+package pluginrpcinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Version is the semantic version of pluginrpc-go.
+const Version = "0.6.0-dev"
+
+const (
+	// CapabilityFormatBinary is the capability to use the binary format.
+	CapabilityFormatBinary = "format-binary"
+	// CapabilityFormatJSON is the capability to use the JSON format.
+	CapabilityFormatJSON = "format-json"
+	// CapabilityStreaming is the capability to call and serve streaming Procedures.
+	CapabilityStreaming = "streaming"
+	// CapabilityServe is the capability to serve many calls within a single process with --serve.
+	CapabilityServe = "serve"
+	// CapabilitySpecCompression is the capability to compress the Spec.
+	CapabilitySpecCompression = "spec-compression"
+	// CapabilitySpecDescriptors is the capability to include descriptors in the Spec.
+	CapabilitySpecDescriptors = "spec-descriptors"
+	// CapabilityHealthCheck is the capability to serve and call the health check procedure.
+	CapabilityHealthCheck = "health-check"
+	// CapabilityCompression is the capability to compress requests and responses with gzip or zstd.
+	CapabilityCompression = "compression"
+	// CapabilityPayload is the capability to stream payloads with requests and responses with --payload.
+	CapabilityPayload = "payload"
+	// CapabilityHostServices is the capability to call host services provided by the host.
+	CapabilityHostServices = "host-services"
+)
+
+// BuildInfo is build metadata about pluginrpc-go within the current binary.
+type BuildInfo struct {
+	// Version is the semantic version of pluginrpc-go.
+	Version string
+	// ProtocolVersions are the protocol versions supported, in ascending order.
+	ProtocolVersions []int
+	// Capabilities are the capabilities enabled, in sorted order.
+	Capabilities []string
+	// GoVersion is the version of the Go toolchain that built the binary, for example "go1.23.0".
+	GoVersion string
+	// Revision is the VCS revision the binary was built from.
+	//
+	// This is empty if the Go toolchain did not stamp the binary with VCS information.
+	Revision string
+}
+
+// Get returns the build metadata of the current binary.
+func Get() BuildInfo {
+	buildInfo := BuildInfo{
+		Version:          Version,
+		ProtocolVersions: ProtocolVersions(),
+		Capabilities:     Capabilities(),
+		GoVersion:        runtime.Version(),
+	}
+	if debugBuildInfo, ok := debug.ReadBuildInfo(); ok {
+		if debugBuildInfo.GoVersion != "" {
+			buildInfo.GoVersion = debugBuildInfo.GoVersion
+		}
+		for _, setting := range debugBuildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				buildInfo.Revision = setting.Value
+			}
+		}
+	}
+	return buildInfo
+}
+
+// String returns the build metadata on a single line, for example:
+//
+//	pluginrpc-go 0.6.0 (protocols: 1; capabilities: format-binary, format-json; go1.23.0)
+func (b BuildInfo) String() string {
+	protocolVersions := make([]string, len(b.ProtocolVersions))
+	for i, protocolVersion := range b.ProtocolVersions {
+		protocolVersions[i] = strconv.Itoa(protocolVersion)
+	}
+	details := []string{
+		"protocols: " + strings.Join(protocolVersions, ", "),
+		"capabilities: " + strings.Join(b.Capabilities, ", "),
+	}
+	if b.GoVersion != "" {
+		details = append(details, b.GoVersion)
+	}
+	if b.Revision != "" {
+		details = append(details, "revision "+b.Revision)
+	}
+	return fmt.Sprintf("pluginrpc-go %s (%s)", b.Version, strings.Join(details, "; "))
+}
+
+// ProtocolVersions returns the protocol versions supported, in ascending order.
+func ProtocolVersions() []int {
+	return slices.Clone(protocolVersions)
+}
+
+// Capabilities returns the capabilities enabled, in sorted order.
+func Capabilities() []string {
+	return slices.Clone(capabilities)
+}
+
+// SupportsProtocolVersion returns true if the given protocol version is supported.
+func SupportsProtocolVersion(protocolVersion int) bool {
+	return slices.Contains(protocolVersions, protocolVersion)
+}
+
+// HasCapability returns true if the given capability is enabled.
+func HasCapability(capability string) bool {
+	_, found := slices.BinarySearch(capabilities, capability)
+	return found
+}
+
+// *** PRIVATE ***
+
+var (
+	protocolVersions = []int{1}
+	capabilities     = sortedCapabilities(
+		CapabilityFormatBinary,
+		CapabilityFormatJSON,
+		CapabilityStreaming,
+		CapabilityServe,
+		CapabilitySpecCompression,
+		CapabilitySpecDescriptors,
+		CapabilityHealthCheck,
+		CapabilityCompression,
+		CapabilityPayload,
+		CapabilityHostServices,
+	)
+)
+
+func sortedCapabilities(capabilities ...string) []string {
+	slices.Sort(capabilities)
+	return capabilities
+}
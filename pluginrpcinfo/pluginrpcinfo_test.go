@@ -0,0 +1,58 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpcinfo_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	"pluginrpc.com/pluginrpc/pluginrpcinfo"
+)
+
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	buildInfo := pluginrpcinfo.Get()
+	require.Equal(t, pluginrpc.Version, buildInfo.Version)
+	require.Equal(t, []int{1}, buildInfo.ProtocolVersions)
+	require.True(t, slices.IsSorted(buildInfo.Capabilities))
+	require.Contains(t, buildInfo.Capabilities, pluginrpcinfo.CapabilityFormatBinary)
+	require.NotEmpty(t, buildInfo.GoVersion)
+	require.True(t, pluginrpcinfo.HasCapability(pluginrpcinfo.CapabilityHealthCheck))
+	require.False(t, pluginrpcinfo.HasCapability("unknown"))
+	require.True(t, pluginrpcinfo.SupportsProtocolVersion(1))
+	require.False(t, pluginrpcinfo.SupportsProtocolVersion(2))
+
+	// Callers cannot modify the build metadata.
+	buildInfo.Capabilities[0] = "unknown"
+	require.NotContains(t, pluginrpcinfo.Capabilities(), "unknown")
+	require.True(t, strings.HasPrefix(pluginrpcinfo.Get().String(), "pluginrpc-go "+pluginrpc.Version+" (protocols: 1; capabilities: "))
+}
+
+func TestBuildInfoString(t *testing.T) {
+	t.Parallel()
+
+	buildInfo := pluginrpcinfo.BuildInfo{
+		Version:          "0.6.0",
+		ProtocolVersions: []int{1},
+		Capabilities:     []string{"format-binary", "format-json"},
+		GoVersion:        "go1.23.0",
+		Revision:         "abc123",
+	}
+	require.Equal(t, "pluginrpc-go 0.6.0 (protocols: 1; capabilities: format-binary, format-json; go1.23.0; revision abc123)", buildInfo.String())
+}
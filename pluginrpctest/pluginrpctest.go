@@ -0,0 +1,408 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pluginrpctest provides utilities for testing code that calls plugins.
+//
+// NewScriptedRunner returns a pluginrpc.Runner that answers calls from a script of
+// expectations instead of invoking a plugin, and reports expectations that were not met.
+package pluginrpctest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"pluginrpc.com/pluginrpc"
+)
+
+// Expectation is an expected call to a Procedure.
+//
+// Expectations are created with NewExpectation, and grouped so that they must be
+// met in order with NewOrderedExpectation.
+type Expectation interface {
+	isExpectation()
+}
+
+// NewExpectation returns a new Expectation of a call to the Procedure with the given path.
+//
+// By default, the call is expected exactly once, matches any args and any request,
+// and returns an empty response.
+func NewExpectation(path string, options ...ExpectationOption) Expectation {
+	expectationOptions := newExpectationOptions()
+	for _, option := range options {
+		option(expectationOptions)
+	}
+	return &callExpectation{
+		path:    path,
+		options: expectationOptions,
+	}
+}
+
+// NewOrderedExpectation returns a new Expectation that expects the given Expectations
+// to be met in order.
+//
+// A call only matches an Expectation once all Expectations before it have been met.
+// Expectations that are not part of the same NewOrderedExpectation may be met in any order.
+func NewOrderedExpectation(expectations ...Expectation) Expectation {
+	return &orderedExpectation{
+		expectations: expectations,
+	}
+}
+
+// ExpectationOption is an option for a new Expectation.
+type ExpectationOption func(*expectationOptions)
+
+// ExpectationWithArgs returns a new ExpectationOption that sets the args of the
+// Procedure in the Spec returned by the ScriptedRunner.
+//
+// All Expectations for the same path must have the same args.
+//
+// The default is to have no args, that is to call the Procedure by its path.
+func ExpectationWithArgs(args ...string) ExpectationOption {
+	return func(expectationOptions *expectationOptions) {
+		expectationOptions.args = args
+	}
+}
+
+// ExpectationWithArgsMatcher returns a new ExpectationOption that only matches calls
+// for which the given function returns true for Env.Args.
+//
+// Env.Args contains all the args of the invocation, including flags such as --format.
+func ExpectationWithArgsMatcher(matchArgs func(args []string) bool) ExpectationOption {
+	return func(expectationOptions *expectationOptions) {
+		expectationOptions.matchArgs = matchArgs
+	}
+}
+
+// ExpectationWithRequest returns a new ExpectationOption that only matches calls
+// whose request is equal to the given request, as determined by proto.Equal.
+//
+// The type of the request is also used to unmarshal requests for the Procedure.
+func ExpectationWithRequest(request proto.Message) ExpectationOption {
+	return func(expectationOptions *expectationOptions) {
+		expectationOptions.request = request
+		expectationOptions.matchRequest = func(actualRequest proto.Message) bool {
+			return proto.Equal(request, actualRequest)
+		}
+	}
+}
+
+// ExpectationWithRequestMatcher returns a new ExpectationOption that only matches calls
+// for which the given function returns true for the request.
+func ExpectationWithRequestMatcher(matchRequest func(request proto.Message) bool) ExpectationOption {
+	return func(expectationOptions *expectationOptions) {
+		expectationOptions.matchRequest = matchRequest
+	}
+}
+
+// ExpectationWithResponse returns a new ExpectationOption that returns the given
+// response from matching calls.
+//
+// The default is to return an empty response.
+func ExpectationWithResponse(response proto.Message) ExpectationOption {
+	return func(expectationOptions *expectationOptions) {
+		expectationOptions.response = response
+	}
+}
+
+// ExpectationWithError returns a new ExpectationOption that returns the given error
+// from matching calls.
+//
+// Use pluginrpc.NewError to control the Code that the caller receives.
+func ExpectationWithError(err error) ExpectationOption {
+	return func(expectationOptions *expectationOptions) {
+		expectationOptions.err = err
+	}
+}
+
+// ExpectationWithTimes returns a new ExpectationOption that expects the given
+// number of matching calls.
+//
+// The default is 1.
+func ExpectationWithTimes(times int) ExpectationOption {
+	return func(expectationOptions *expectationOptions) {
+		expectationOptions.times = times
+	}
+}
+
+// ScriptedRunner is a pluginrpc.Runner that answers calls from a script of Expectations.
+//
+// The ScriptedRunner behaves like a plugin built with pluginrpc.Server: it responds to
+// --protocol and --spec, and supports all Formats. Calls that do not match any
+// Expectation fail with CodeFailedPrecondition.
+//
+// Only unary Procedures are supported.
+type ScriptedRunner interface {
+	pluginrpc.Runner
+
+	// Verify returns an error if an Expectation was not met, or if there was a call
+	// that did not match any Expectation.
+	Verify() error
+
+	isScriptedRunner()
+}
+
+// NewScriptedRunner returns a new ScriptedRunner for the given Expectations.
+//
+// The request type of each Procedure is taken from ExpectationWithRequest if set, and
+// otherwise resolved from the global registry, where the path of the Procedure is
+// expected to be of the form /package.Service/Method.
+func NewScriptedRunner(expectations ...Expectation) (ScriptedRunner, error) {
+	return newScriptedRunner(expectations...)
+}
+
+// *** PRIVATE ***
+
+type argsContextKey struct{}
+
+type callExpectation struct {
+	path    string
+	options *expectationOptions
+}
+
+func (*callExpectation) isExpectation() {}
+
+type orderedExpectation struct {
+	expectations []Expectation
+}
+
+func (*orderedExpectation) isExpectation() {}
+
+type scriptedRunner struct {
+	runner pluginrpc.Runner
+
+	lock            sync.Mutex
+	states          []*expectationState
+	unexpectedCalls []string
+}
+
+func newScriptedRunner(expectations ...Expectation) (*scriptedRunner, error) {
+	var states []*expectationState
+	for _, expectation := range expectations {
+		expectationStates, err := newExpectationStates(expectation)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, expectationStates...)
+	}
+	scriptedRunner := &scriptedRunner{
+		states: states,
+	}
+	var paths []string
+	pathToStates := make(map[string][]*expectationState)
+	for _, state := range states {
+		if _, ok := pathToStates[state.path]; !ok {
+			paths = append(paths, state.path)
+		}
+		pathToStates[state.path] = append(pathToStates[state.path], state)
+	}
+	procedures := make([]pluginrpc.Procedure, 0, len(paths))
+	serverRegistrar := pluginrpc.NewServerRegistrar()
+	var handler pluginrpc.Handler
+	for _, path := range paths {
+		path := path
+		pathStates := pathToStates[path]
+		args := pathStates[0].options.args
+		for _, state := range pathStates[1:] {
+			if !slices.Equal(args, state.options.args) {
+				return nil, fmt.Errorf("expectations for path %q have different args: %v and %v", path, args, state.options.args)
+			}
+		}
+		procedure, err := pluginrpc.NewProcedure(path, pluginrpc.ProcedureWithArgs(args...))
+		if err != nil {
+			return nil, err
+		}
+		procedures = append(procedures, procedure)
+		requestType, err := getRequestType(path, pathStates)
+		if err != nil {
+			return nil, err
+		}
+		serverRegistrar.Register(
+			path,
+			func(ctx context.Context, handleEnv pluginrpc.HandleEnv, options ...pluginrpc.HandleOption) error {
+				return handler.Handle(
+					ctx,
+					handleEnv,
+					requestType.New().Interface(),
+					func(ctx context.Context, request any) (any, error) {
+						args, _ := ctx.Value(argsContextKey{}).([]string)
+						return scriptedRunner.handle(path, args, request.(proto.Message))
+					},
+					options...,
+				)
+			},
+		)
+	}
+	spec, err := pluginrpc.NewSpec(procedures...)
+	if err != nil {
+		return nil, err
+	}
+	handler = pluginrpc.NewHandler(spec)
+	server, err := pluginrpc.NewServer(spec, serverRegistrar)
+	if err != nil {
+		return nil, err
+	}
+	scriptedRunner.runner = pluginrpc.NewServerRunner(server)
+	return scriptedRunner, nil
+}
+
+func (s *scriptedRunner) Run(ctx context.Context, env pluginrpc.Env) error {
+	return s.runner.Run(context.WithValue(ctx, argsContextKey{}, env.Args), env)
+}
+
+func (s *scriptedRunner) Verify() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var errs []error
+	for _, unexpectedCall := range s.unexpectedCalls {
+		errs = append(errs, errors.New(unexpectedCall))
+	}
+	for _, state := range s.states {
+		if state.calls < state.options.times {
+			errs = append(errs, fmt.Errorf("expected %d call(s) to %q, got %d", state.options.times, state.path, state.calls))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *scriptedRunner) handle(path string, args []string, request proto.Message) (any, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, state := range s.states {
+		if !state.matches(path, args, request) {
+			continue
+		}
+		state.calls++
+		if state.options.err != nil {
+			return nil, state.options.err
+		}
+		return state.options.response, nil
+	}
+	unexpectedCall := fmt.Sprintf("unexpected call to %q with args %v and request %v", path, args, request)
+	s.unexpectedCalls = append(s.unexpectedCalls, unexpectedCall)
+	return nil, pluginrpc.NewError(pluginrpc.CodeFailedPrecondition, errors.New(unexpectedCall))
+}
+
+func (*scriptedRunner) isScriptedRunner() {}
+
+type expectationState struct {
+	path    string
+	options *expectationOptions
+	// prerequisites are the expectations that must be met before this expectation matches.
+	prerequisites []*expectationState
+
+	calls int
+}
+
+// newExpectationStates returns the states for the call expectations within the Expectation.
+func newExpectationStates(expectation Expectation) ([]*expectationState, error) {
+	switch t := expectation.(type) {
+	case *callExpectation:
+		if t.options.times < 1 {
+			return nil, fmt.Errorf("expectation for path %q must be expected at least once, got %d times", t.path, t.options.times)
+		}
+		return []*expectationState{
+			{
+				path:    t.path,
+				options: t.options,
+			},
+		}, nil
+	case *orderedExpectation:
+		var states []*expectationState
+		var previousStates []*expectationState
+		for _, child := range t.expectations {
+			childStates, err := newExpectationStates(child)
+			if err != nil {
+				return nil, err
+			}
+			for _, childState := range childStates {
+				childState.prerequisites = append(childState.prerequisites, previousStates...)
+			}
+			states = append(states, childStates...)
+			previousStates = childStates
+		}
+		return states, nil
+	default:
+		return nil, fmt.Errorf("unknown Expectation: %T", expectation)
+	}
+}
+
+func (e *expectationState) matches(path string, args []string, request proto.Message) bool {
+	if e.path != path || e.calls >= e.options.times {
+		return false
+	}
+	for _, prerequisite := range e.prerequisites {
+		if prerequisite.calls < prerequisite.options.times {
+			return false
+		}
+	}
+	if e.options.matchArgs != nil && !e.options.matchArgs(args) {
+		return false
+	}
+	if e.options.matchRequest != nil && !e.options.matchRequest(request) {
+		return false
+	}
+	return true
+}
+
+// getRequestType returns the request type for the Procedure with the given path.
+func getRequestType(path string, states []*expectationState) (protoreflect.MessageType, error) {
+	for _, state := range states {
+		if state.options.request != nil {
+			return state.options.request.ProtoReflect().Type(), nil
+		}
+	}
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("cannot resolve request type for path %q, use ExpectationWithRequest", path)
+	}
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve request type for path %q, use ExpectationWithRequest: %w", path, err)
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("cannot resolve request type for path %q: %q is not a service", path, serviceName)
+	}
+	methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(methodName))
+	if methodDescriptor == nil {
+		return nil, fmt.Errorf("cannot resolve request type for path %q: method %q not found", path, methodName)
+	}
+	if methodDescriptor.IsStreamingClient() || methodDescriptor.IsStreamingServer() {
+		return nil, fmt.Errorf("path %q is a streaming Procedure, only unary Procedures are supported", path)
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(methodDescriptor.Input().FullName())
+}
+
+type expectationOptions struct {
+	args         []string
+	matchArgs    func([]string) bool
+	request      proto.Message
+	matchRequest func(proto.Message) bool
+	response     proto.Message
+	err          error
+	times        int
+}
+
+func newExpectationOptions() *expectationOptions {
+	return &expectationOptions{
+		times: 1,
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpctest_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+	"pluginrpc.com/pluginrpc/pluginrpctest"
+)
+
+func TestScriptedRunner(t *testing.T) {
+	t.Parallel()
+
+	runner, err := pluginrpctest.NewScriptedRunner(
+		pluginrpctest.NewExpectation(
+			examplev1pluginrpc.EchoServiceEchoRequestPath,
+			pluginrpctest.ExpectationWithRequest(&examplev1.EchoRequestRequest{Message: "hello"}),
+			pluginrpctest.ExpectationWithResponse(&examplev1.EchoRequestResponse{Message: "world"}),
+			pluginrpctest.ExpectationWithTimes(2),
+		),
+		pluginrpctest.NewExpectation(
+			examplev1pluginrpc.EchoServiceEchoErrorPath,
+			pluginrpctest.ExpectationWithError(pluginrpc.NewErrorf(pluginrpc.CodeNotFound, "not found")),
+		),
+	)
+	require.NoError(t, err)
+	echoServiceClient := newEchoServiceClient(t, runner)
+
+	_, err = echoServiceClient.EchoError(context.Background(), &examplev1.EchoErrorRequest{})
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeNotFound, pluginrpcError.Code())
+	for i := 0; i < 2; i++ {
+		response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+		require.NoError(t, err)
+		require.Equal(t, "world", response.GetMessage())
+	}
+	require.NoError(t, runner.Verify())
+
+	// The expectation was met, so further calls are unexpected.
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeFailedPrecondition, pluginrpcError.Code())
+	require.ErrorContains(t, runner.Verify(), "unexpected call")
+}
+
+func TestScriptedRunnerOrdered(t *testing.T) {
+	t.Parallel()
+
+	runner, err := pluginrpctest.NewScriptedRunner(
+		pluginrpctest.NewOrderedExpectation(
+			pluginrpctest.NewExpectation(
+				examplev1pluginrpc.EchoServiceEchoListPath,
+				pluginrpctest.ExpectationWithResponse(&examplev1.EchoListResponse{List: []string{"first"}}),
+			),
+			pluginrpctest.NewExpectation(
+				examplev1pluginrpc.EchoServiceEchoRequestPath,
+				pluginrpctest.ExpectationWithRequestMatcher(
+					func(request proto.Message) bool {
+						return request.(*examplev1.EchoRequestRequest).GetMessage() != ""
+					},
+				),
+				pluginrpctest.ExpectationWithResponse(&examplev1.EchoRequestResponse{Message: "second"}),
+			),
+		),
+	)
+	require.NoError(t, err)
+	echoServiceClient := newEchoServiceClient(t, runner)
+
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "foo"})
+	require.Error(t, err)
+	listResponse, err := echoServiceClient.EchoList(context.Background(), &examplev1.EchoListRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"first"}, listResponse.GetList())
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{})
+	require.Error(t, err)
+	requestResponse, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "foo"})
+	require.NoError(t, err)
+	require.Equal(t, "second", requestResponse.GetMessage())
+
+	// The two calls that did not match are reported.
+	err = runner.Verify()
+	require.Error(t, err)
+	require.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 2)
+}
+
+func TestScriptedRunnerArgs(t *testing.T) {
+	t.Parallel()
+
+	runner, err := pluginrpctest.NewScriptedRunner(
+		pluginrpctest.NewExpectation(
+			examplev1pluginrpc.EchoServiceEchoRequestPath,
+			pluginrpctest.ExpectationWithArgs("echo", "request"),
+			pluginrpctest.ExpectationWithArgsMatcher(
+				func(args []string) bool {
+					return slices.Contains(args, "json")
+				},
+			),
+			pluginrpctest.ExpectationWithResponse(&examplev1.EchoRequestResponse{Message: "json"}),
+		),
+		pluginrpctest.NewExpectation(
+			examplev1pluginrpc.EchoServiceEchoListPath,
+		),
+	)
+	require.NoError(t, err)
+	client := pluginrpc.NewClient(runner, pluginrpc.ClientWithFormat(pluginrpc.FormatJSON))
+	spec, err := client.Spec(context.Background())
+	require.NoError(t, err)
+	procedure := spec.ProcedureForPath(examplev1pluginrpc.EchoServiceEchoRequestPath)
+	require.NotNil(t, procedure)
+	require.Equal(t, []string{"echo", "request"}, procedure.Args())
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "json", response.GetMessage())
+
+	// The expectation for EchoList was not met.
+	require.ErrorContains(t, runner.Verify(), `expected 1 call(s) to "/pluginrpc.example.v1.EchoService/EchoList", got 0`)
+}
+
+func TestNewScriptedRunnerErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := pluginrpctest.NewScriptedRunner(
+		pluginrpctest.NewExpectation(examplev1pluginrpc.EchoServiceEchoRequestPath, pluginrpctest.ExpectationWithArgs("foo")),
+		pluginrpctest.NewExpectation(examplev1pluginrpc.EchoServiceEchoRequestPath, pluginrpctest.ExpectationWithArgs("bar")),
+	)
+	require.ErrorContains(t, err, "different args")
+	_, err = pluginrpctest.NewScriptedRunner(pluginrpctest.NewExpectation("/foo.v1.FooService/Foo"))
+	require.ErrorContains(t, err, "use ExpectationWithRequest")
+	_, err = pluginrpctest.NewScriptedRunner(pluginrpctest.NewExpectation(examplev1pluginrpc.EchoServiceEchoStreamPath))
+	require.ErrorContains(t, err, "only unary Procedures are supported")
+	_, err = pluginrpctest.NewScriptedRunner(
+		pluginrpctest.NewExpectation(examplev1pluginrpc.EchoServiceEchoRequestPath, pluginrpctest.ExpectationWithTimes(0)),
+	)
+	require.Error(t, err)
+}
+
+func newEchoServiceClient(t *testing.T, runner pluginrpc.Runner) examplev1pluginrpc.EchoServiceClient {
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner))
+	require.NoError(t, err)
+	return echoServiceClient
+}
@@ -0,0 +1,264 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pluginrpcwasm runs plugins compiled to WebAssembly in-process.
+//
+// This is a separate package so that hosts that do not run WebAssembly plugins do not
+// depend on wazero.
+package pluginrpcwasm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"pluginrpc.com/pluginrpc"
+)
+
+// Runner is a pluginrpc.Runner that runs a plugin compiled to WebAssembly in-process.
+//
+// Runners must be closed to release the compiled plugin.
+type Runner interface {
+	pluginrpc.Runner
+
+	// Close releases the compiled plugin.
+	//
+	// Calls that are in flight are terminated. The Runner cannot be used afterwards.
+	Close() error
+
+	isRunner()
+}
+
+// NewRunner returns a new Runner that runs the given plugin compiled to
+// WebAssembly targeting WASI preview 1, for example with GOOS=wasip1 GOARCH=wasm.
+//
+// The plugin is run in-process with wazero, and each call is run in a new instance
+// of the plugin. The plugin has no access to the filesystem, network, or environment
+// variables of the host, which allows untrusted plugins to be run without spawning
+// native binaries. The plugin does have access to the system clock and a
+// cryptographically-secure random source.
+//
+// The plugin is compiled on the first call.
+func NewRunner(wasm []byte, options ...RunnerOption) Runner {
+	return newRunner(wasm, options...)
+}
+
+// RunnerOption is an option for a new Runner.
+type RunnerOption func(*runnerOptions)
+
+// RunnerWithProgramName returns a new RunnerOption that specifies the program
+// name the plugin sees as its first argument.
+//
+// The default is "plugin".
+func RunnerWithProgramName(programName string) RunnerOption {
+	return func(runnerOptions *runnerOptions) {
+		runnerOptions.programName = programName
+	}
+}
+
+// RunnerWithArgs returns a new RunnerOption that specifies a sub-command to invoke
+// on the plugin.
+//
+// See pluginrpc.ExecRunnerWithArgs for more details.
+func RunnerWithArgs(args ...string) RunnerOption {
+	return func(runnerOptions *runnerOptions) {
+		runnerOptions.args = args
+	}
+}
+
+// RunnerWithEnv returns a new RunnerOption that sets the given environment
+// variables for the plugin.
+//
+// This option can be specified multiple times, in which case the environment variables are merged.
+func RunnerWithEnv(env map[string]string) RunnerOption {
+	return func(runnerOptions *runnerOptions) {
+		if runnerOptions.env == nil {
+			runnerOptions.env = make(map[string]string, len(env))
+		}
+		for key, value := range env {
+			runnerOptions.env[key] = value
+		}
+	}
+}
+
+// RunnerWithMemoryLimitPages returns a new RunnerOption that limits the memory
+// of each instance of the plugin to the given number of 64 KiB pages.
+//
+// The default is the wazero default of 65536 pages, or 4 GiB.
+func RunnerWithMemoryLimitPages(memoryLimitPages uint32) RunnerOption {
+	return func(runnerOptions *runnerOptions) {
+		runnerOptions.memoryLimitPages = memoryLimitPages
+	}
+}
+
+// *** PRIVATE ***
+
+const defaultProgramName = "plugin"
+
+type runner struct {
+	wasm             []byte
+	programName      string
+	programBaseArgs  []string
+	env              map[string]string
+	memoryLimitPages uint32
+
+	runtime        wazero.Runtime
+	compiledModule wazero.CompiledModule
+	compileErr     error
+	closed         bool
+	lock           sync.Mutex
+}
+
+func newRunner(wasm []byte, options ...RunnerOption) *runner {
+	runnerOptions := newRunnerOptions()
+	for _, option := range options {
+		option(runnerOptions)
+	}
+	if runnerOptions.programName == "" {
+		runnerOptions.programName = defaultProgramName
+	}
+	return &runner{
+		wasm:             wasm,
+		programName:      runnerOptions.programName,
+		programBaseArgs:  runnerOptions.args,
+		env:              runnerOptions.env,
+		memoryLimitPages: runnerOptions.memoryLimitPages,
+	}
+}
+
+func (r *runner) Run(ctx context.Context, env pluginrpc.Env) error {
+	env = envWithDefaults(env)
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	runtime, compiledModule, err := r.getCompiledModule()
+	if err != nil {
+		return err
+	}
+	moduleConfig := wazero.NewModuleConfig().
+		// Anonymous modules can be instantiated concurrently.
+		WithName("").
+		WithArgs(append(append([]string{r.programName}, r.programBaseArgs...), env.Args...)...).
+		WithStdin(env.Stdin).
+		WithStdout(env.Stdout).
+		WithStderr(env.Stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	envKeys := make([]string, 0, len(r.env))
+	for key := range r.env {
+		envKeys = append(envKeys, key)
+	}
+	sort.Strings(envKeys)
+	for _, key := range envKeys {
+		moduleConfig = moduleConfig.WithEnv(key, r.env[key])
+	}
+	module, err := runtime.InstantiateModule(ctx, compiledModule, moduleConfig)
+	if module != nil {
+		_ = module.Close(ctx)
+	}
+	if err != nil {
+		exitError := &sys.ExitError{}
+		if errors.As(err, &exitError) {
+			if exitCode := exitError.ExitCode(); exitCode != 0 {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				return pluginrpc.NewExitError(int(exitCode), exitError)
+			}
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *runner) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+	if r.runtime == nil {
+		return nil
+	}
+	return r.runtime.Close(context.Background())
+}
+
+func (*runner) isRunner() {}
+
+// getCompiledModule returns the runtime and compiled plugin, compiling the plugin if
+// this has not been done yet.
+func (r *runner) getCompiledModule() (wazero.Runtime, wazero.CompiledModule, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return nil, nil, errors.New("pluginrpcwasm.Runner is closed")
+	}
+	if r.runtime != nil || r.compileErr != nil {
+		return r.runtime, r.compiledModule, r.compileErr
+	}
+	runtimeConfig := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if r.memoryLimitPages > 0 {
+		runtimeConfig = runtimeConfig.WithMemoryLimitPages(r.memoryLimitPages)
+	}
+	// Use a background context, as the runtime and compiled plugin outlive the call that created them.
+	runtime := wazero.NewRuntimeWithConfig(context.Background(), runtimeConfig)
+	if _, err := wasi_snapshot_preview1.Instantiate(context.Background(), runtime); err != nil {
+		_ = runtime.Close(context.Background())
+		r.compileErr = err
+		return nil, nil, err
+	}
+	compiledModule, err := runtime.CompileModule(context.Background(), r.wasm)
+	if err != nil {
+		_ = runtime.Close(context.Background())
+		r.compileErr = err
+		return nil, nil, err
+	}
+	r.runtime = runtime
+	r.compiledModule = compiledModule
+	return runtime, compiledModule, nil
+}
+
+type runnerOptions struct {
+	programName      string
+	args             []string
+	env              map[string]string
+	memoryLimitPages uint32
+}
+
+func newRunnerOptions() *runnerOptions {
+	return &runnerOptions{}
+}
+
+// envWithDefaults returns a copy of the Env with the equivalent of /dev/null
+// given for any nil stdio.
+func envWithDefaults(env pluginrpc.Env) pluginrpc.Env {
+	if env.Stdin == nil {
+		env.Stdin = bytes.NewReader(nil)
+	}
+	if env.Stdout == nil {
+		env.Stdout = io.Discard
+	}
+	if env.Stderr == nil {
+		env.Stderr = io.Discard
+	}
+	return env
+}
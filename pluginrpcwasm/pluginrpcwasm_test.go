@@ -0,0 +1,72 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpcwasm_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+	"pluginrpc.com/pluginrpc/pluginrpcwasm"
+)
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+
+	if testing.Short() {
+		t.Skip("skipping compilation of echo-plugin to WebAssembly in short mode")
+	}
+	goProgramPath, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go program not found")
+	}
+	wasmFilePath := filepath.Join(t.TempDir(), "echo-plugin.wasm")
+	cmd := exec.Command(goProgramPath, "build", "-o", wasmFilePath, "../internal/example/cmd/echo-plugin")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	wasm, err := os.ReadFile(wasmFilePath)
+	require.NoError(t, err)
+
+	runner := pluginrpcwasm.NewRunner(wasm, pluginrpcwasm.RunnerWithProgramName("echo-plugin"))
+	t.Cleanup(func() { require.NoError(t, runner.Close()) })
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(runner))
+	require.NoError(t, err)
+	response, err := echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{Message: "hello"})
+	require.NoError(t, err)
+	require.Equal(t, "hello", response.GetMessage())
+	_, err = echoServiceClient.EchoError(
+		context.Background(),
+		&examplev1.EchoErrorRequest{
+			Code:    pluginrpcv1.Code_CODE_DEADLINE_EXCEEDED,
+			Message: "foo",
+		},
+	)
+	pluginrpcError := &pluginrpc.Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeDeadlineExceeded, pluginrpcError.Code())
+
+	err = runner.Run(context.Background(), pluginrpc.Env{Args: []string{"--unknown-flag"}})
+	exitError := &pluginrpc.ExitError{}
+	require.ErrorAs(t, err, &exitError)
+	require.NotZero(t, exitError.ExitCode())
+}
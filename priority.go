@@ -0,0 +1,149 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// *** PRIVATE ***
+
+type callPriorityContextKey struct{}
+
+func withCallPriority(ctx context.Context, priority int32) context.Context {
+	if priority == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, callPriorityContextKey{}, priority)
+}
+
+// callPriorityFromContext returns the priority of the call, or zero if not set.
+func callPriorityFromContext(ctx context.Context) int32 {
+	priority, _ := ctx.Value(callPriorityContextKey{}).(int32)
+	return priority
+}
+
+// prioritySemaphore is a semaphore where waiters with a higher priority acquire the
+// semaphore before waiters with a lower priority.
+//
+// Waiters with the same priority acquire the semaphore in the order they started waiting.
+type prioritySemaphore struct {
+	lock      sync.Mutex
+	available int
+	waiters   priorityWaiters
+	// nextSeq is the sequence number of the next waiter.
+	nextSeq uint64
+}
+
+func newPrioritySemaphore(size int) *prioritySemaphore {
+	return &prioritySemaphore{
+		available: size,
+	}
+}
+
+// acquire acquires the semaphore, waiting until it is available or the context is done.
+func (p *prioritySemaphore) acquire(ctx context.Context, priority int32) error {
+	p.lock.Lock()
+	if p.available > 0 && len(p.waiters) == 0 {
+		p.available--
+		p.lock.Unlock()
+		return nil
+	}
+	waiter := &priorityWaiter{
+		priority: priority,
+		seq:      p.nextSeq,
+		readyC:   make(chan struct{}),
+	}
+	p.nextSeq++
+	heap.Push(&p.waiters, waiter)
+	p.lock.Unlock()
+
+	select {
+	case <-waiter.readyC:
+		return nil
+	case <-ctx.Done():
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if waiter.index < 0 {
+			// The semaphore was acquired concurrently, pass it on.
+			p.releaseLocked()
+		} else {
+			heap.Remove(&p.waiters, waiter.index)
+		}
+		return ctx.Err()
+	}
+}
+
+// release releases the semaphore, handing it to the waiter with the highest priority, if any.
+func (p *prioritySemaphore) release() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.releaseLocked()
+}
+
+func (p *prioritySemaphore) releaseLocked() {
+	if len(p.waiters) == 0 {
+		p.available++
+		return
+	}
+	waiter := heap.Pop(&p.waiters).(*priorityWaiter)
+	close(waiter.readyC)
+}
+
+type priorityWaiter struct {
+	priority int32
+	seq      uint64
+	readyC   chan struct{}
+	// index is the index of the waiter in the heap, or -1 if the waiter was removed.
+	index int
+}
+
+// priorityWaiters implements heap.Interface.
+type priorityWaiters []*priorityWaiter
+
+func (p priorityWaiters) Len() int {
+	return len(p)
+}
+
+func (p priorityWaiters) Less(i int, j int) bool {
+	if p[i].priority != p[j].priority {
+		return p[i].priority > p[j].priority
+	}
+	return p[i].seq < p[j].seq
+}
+
+func (p priorityWaiters) Swap(i int, j int) {
+	p[i], p[j] = p[j], p[i]
+	p[i].index = i
+	p[j].index = j
+}
+
+func (p *priorityWaiters) Push(x any) {
+	waiter := x.(*priorityWaiter)
+	waiter.index = len(*p)
+	*p = append(*p, waiter)
+}
+
+func (p *priorityWaiters) Pop() any {
+	old := *p
+	n := len(old)
+	waiter := old[n-1]
+	old[n-1] = nil
+	waiter.index = -1
+	*p = old[:n-1]
+	return waiter
+}
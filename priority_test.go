@@ -0,0 +1,68 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrioritySemaphore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	semaphore := newPrioritySemaphore(1)
+	require.NoError(t, semaphore.acquire(ctx, 0))
+
+	// Queue waiters one at a time so that their order of arrival is deterministic.
+	orderC := make(chan int32, 4)
+	for _, priority := range []int32{-1, 0, 5, 0} {
+		queued := len(semaphore.waiters)
+		go func(priority int32) {
+			if err := semaphore.acquire(ctx, priority); err != nil {
+				orderC <- 100
+				return
+			}
+			orderC <- priority
+			semaphore.release()
+		}(priority)
+		require.Eventually(
+			t,
+			func() bool {
+				semaphore.lock.Lock()
+				defer semaphore.lock.Unlock()
+				return len(semaphore.waiters) == queued+1
+			},
+			time.Second,
+			time.Millisecond,
+		)
+	}
+
+	// A waiter whose context is done stops waiting.
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, semaphore.acquire(cancelCtx, 10), context.Canceled)
+
+	semaphore.release()
+	var order []int32
+	for i := 0; i < 4; i++ {
+		order = append(order, <-orderC)
+	}
+	require.Equal(t, []int32{5, 0, 0, -1}, order)
+	require.NoError(t, semaphore.acquire(ctx, 0))
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicksPerSecond is the number of clock ticks per second used by /proc/<pid>/stat.
+//
+// This is USER_HZ, which is 100 on all supported architectures.
+const clockTicksPerSecond = 100
+
+// processRSSBytes returns the resident set size of the process in bytes.
+//
+// Returns false if the resident set size cannot be determined.
+func processRSSBytes(pid int) (uint64, bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/status")
+	if err != nil {
+		return 0, false
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		// The line is of the form "VmRSS:	    1234 kB".
+		value, ok := strings.CutPrefix(scanner.Text(), "VmRSS:")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) != 2 || fields[1] != "kB" {
+			return 0, false
+		}
+		kilobytes, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return kilobytes * 1024, true
+	}
+	return 0, false
+}
+
+// processCPUTime returns the user and system CPU time used by the process so far.
+//
+// Returns false if the CPU time cannot be determined.
+func processCPUTime(pid int) (time.Duration, bool) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, false
+	}
+	// The second field is the program name in parentheses, which may itself contain
+	// spaces or parentheses, so start after the last closing parenthesis.
+	index := bytes.LastIndexByte(data, ')')
+	if index < 0 {
+		return 0, false
+	}
+	// The remaining fields start at the third field, and utime and stime are the
+	// 14th and 15th fields.
+	fields := strings.Fields(string(data[index+1:]))
+	if len(fields) < 13 {
+		return 0, false
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicksPerSecond, true
+}
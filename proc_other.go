@@ -0,0 +1,33 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package pluginrpc
+
+import "time"
+
+// processRSSBytes returns the resident set size of the process in bytes.
+//
+// This is only supported on Linux, and always returns false on other platforms.
+func processRSSBytes(int) (uint64, bool) {
+	return 0, false
+}
+
+// processCPUTime returns the user and system CPU time used by the process so far.
+//
+// This is only supported on Linux, and always returns false on other platforms.
+func processCPUTime(int) (time.Duration, bool) {
+	return 0, false
+}
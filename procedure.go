@@ -41,6 +41,39 @@ type Procedure interface {
 	// Arg values may only use the characters [a-zA-Z0-9-_], and never start or end with a dash
 	// or underscore.
 	Args() []string
+	// Disabled returns true if the Procedure is disabled.
+	//
+	// Disabled Procedures are still registered with a Server, but are not exposed
+	// to clients via the Spec, and invoking them results in a CodeUnimplemented error.
+	Disabled() bool
+	// ReplayProtected returns true if the Procedure requires replay protection.
+	//
+	// Invoking a replay-protected Procedure requires a nonce and timestamp, which
+	// are verified by the Server against its NonceStore. See ServerWithNonceStore.
+	ReplayProtected() bool
+	// Serialized returns true if the Procedure is not safe for concurrent execution.
+	//
+	// A Server never executes a serialized Procedure concurrently with itself, allowing
+	// handler authors to skip their own locking when a Server serves multiple calls
+	// within one process.
+	Serialized() bool
+	// Deprecation returns a message describing the deprecation of the Procedure, or
+	// empty if the Procedure is not deprecated.
+	//
+	// Clients are warned when calling a deprecated Procedure, see ClientWithWarningHandler.
+	Deprecation() string
+	// RenamedFrom returns the Procedures that this Procedure was renamed from, if any.
+	//
+	// Servers accept calls to the paths and args of these Procedures as calls to this
+	// Procedure, and warn clients that the Procedure was renamed, see ClientWithWarningHandler.
+	// The returned Procedures only have a path and args.
+	RenamedFrom() []Procedure
+	// Doc returns the documentation of the Procedure, describing what it does, or empty
+	// if the Procedure is not documented.
+	//
+	// The first line of the documentation is a summary that is shown in --help. Clients
+	// receive the documentation with the Spec, see ClientWithSpecDocs.
+	Doc() string
 
 	isProcedure()
 }
@@ -76,11 +109,90 @@ func ProcedureWithArgs(args ...string) ProcedureOption {
 	}
 }
 
+// ProcedureWithDisabled marks the Procedure as disabled.
+//
+// This allows plugins to conditionally expose Procedures at runtime, for example
+// based on feature flags or licensing, while still registering all Procedures
+// with a ServerRegistrar. NewServer returns an error if all Procedures are disabled.
+func ProcedureWithDisabled() ProcedureOption {
+	return func(procedureOptions *procedureOptions) {
+		procedureOptions.disabled = true
+	}
+}
+
+// ProcedureWithReplayProtection marks the Procedure as requiring replay protection.
+//
+// This is intended for Procedures that perform sensitive side effects, to prevent
+// accidental duplicate execution when hosts retry aggressively. Clients must call
+// the Procedure with a nonce, see ClientWithReplayProtection and CallWithNonce.
+func ProcedureWithReplayProtection() ProcedureOption {
+	return func(procedureOptions *procedureOptions) {
+		procedureOptions.replayProtected = true
+	}
+}
+
+// ProcedureWithSerialized declares that the Procedure is not safe for concurrent execution.
+//
+// The Server will serialize calls to the Procedure within a single process.
+// By default, Procedures are assumed to be safe for concurrent execution.
+func ProcedureWithSerialized() ProcedureOption {
+	return func(procedureOptions *procedureOptions) {
+		procedureOptions.serialized = true
+	}
+}
+
+// ProcedureWithDeprecation marks the Procedure as deprecated, with a message describing
+// the deprecation, for example what to use instead.
+//
+// If the message is empty, a default message is used.
+func ProcedureWithDeprecation(message string) ProcedureOption {
+	return func(procedureOptions *procedureOptions) {
+		procedureOptions.deprecated = true
+		procedureOptions.deprecation = message
+	}
+}
+
+// ProcedureWithRenamedFrom declares that the Procedure was renamed from the given path
+// and optional args.
+//
+// This allows plugins to rename Procedures without breaking hosts that still call the old
+// path or args. The old path and args are included in the Spec sent to clients, calls to
+// them are handled by the Procedure, and clients are warned that the Procedure was renamed.
+//
+// This option may be specified multiple times for Procedures renamed more than once.
+func ProcedureWithRenamedFrom(path string, args ...string) ProcedureOption {
+	return func(procedureOptions *procedureOptions) {
+		procedureOptions.renamedFrom = append(
+			procedureOptions.renamedFrom,
+			procedureAlias{
+				path: path,
+				args: args,
+			},
+		)
+	}
+}
+
+// ProcedureWithDoc specifies the documentation of the Procedure, describing what it does.
+//
+// The first line of the documentation should be a summary, which is shown in --help.
+// Generated <Service>SpecBuilders specify the leading comments of methods as documentation.
+func ProcedureWithDoc(doc string) ProcedureOption {
+	return func(procedureOptions *procedureOptions) {
+		procedureOptions.doc = doc
+	}
+}
+
 // *** PRIVATE ***
 
 type procedure struct {
-	path string
-	args []string
+	path            string
+	args            []string
+	disabled        bool
+	replayProtected bool
+	serialized      bool
+	deprecation     string
+	renamedFrom     []Procedure
+	doc             string
 }
 
 func newProcedure(path string, options ...ProcedureOption) (*procedure, error) {
@@ -89,12 +201,27 @@ func newProcedure(path string, options ...ProcedureOption) (*procedure, error) {
 		option(procedureOptions)
 	}
 	procedure := &procedure{
-		path: path,
-		args: procedureOptions.args,
+		path:            path,
+		args:            procedureOptions.args,
+		disabled:        procedureOptions.disabled,
+		replayProtected: procedureOptions.replayProtected,
+		serialized:      procedureOptions.serialized,
+		deprecation:     procedureOptions.deprecation,
+		doc:             strings.TrimSpace(procedureOptions.doc),
+	}
+	if procedureOptions.deprecated && procedure.deprecation == "" {
+		procedure.deprecation = fmt.Sprintf("procedure %q is deprecated", path)
 	}
 	if err := validateProcedure(procedure); err != nil {
 		return nil, err
 	}
+	for _, alias := range procedureOptions.renamedFrom {
+		renamedFrom, err := newProcedure(alias.path, ProcedureWithArgs(alias.args...))
+		if err != nil {
+			return nil, fmt.Errorf("invalid procedure that %q was renamed from: %w", path, err)
+		}
+		procedure.renamedFrom = append(procedure.renamedFrom, renamedFrom)
+	}
 	return procedure, nil
 }
 
@@ -106,9 +233,45 @@ func (p *procedure) Args() []string {
 	return slices.Clone(p.args)
 }
 
+func (p *procedure) Disabled() bool {
+	return p.disabled
+}
+
+func (p *procedure) ReplayProtected() bool {
+	return p.replayProtected
+}
+
+func (p *procedure) Serialized() bool {
+	return p.serialized
+}
+
+func (p *procedure) Deprecation() string {
+	return p.deprecation
+}
+
+func (p *procedure) RenamedFrom() []Procedure {
+	return slices.Clone(p.renamedFrom)
+}
+
+func (p *procedure) Doc() string {
+	return p.doc
+}
+
 func (*procedure) isProcedure() {}
 
 type procedureOptions struct {
+	args            []string
+	disabled        bool
+	replayProtected bool
+	serialized      bool
+	deprecated      bool
+	deprecation     string
+	renamedFrom     []procedureAlias
+	doc             string
+}
+
+type procedureAlias struct {
+	path string
 	args []string
 }
 
@@ -119,7 +282,12 @@ func newProcedureOptions() *procedureOptions {
 func validateProcedures(procedures []Procedure) error {
 	usedPathMap := make(map[string]struct{})
 	usedArgsMap := make(map[string]struct{})
+	var allProcedures []Procedure
 	for _, procedure := range procedures {
+		allProcedures = append(allProcedures, procedure)
+		allProcedures = append(allProcedures, procedure.RenamedFrom()...)
+	}
+	for _, procedure := range allProcedures {
 		path := procedure.Path()
 		if _, ok := usedPathMap[path]; ok {
 			return fmt.Errorf("duplicate procedure path: %q", path)
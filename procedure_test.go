@@ -32,6 +32,11 @@ func TestProcedureBasic(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "/foo/bar", procedure.Path())
 	require.Equal(t, []string{"foo", "bar"}, procedure.Args())
+	require.False(t, procedure.Disabled())
+
+	procedure, err = NewProcedure("/foo/bar", ProcedureWithDisabled())
+	require.NoError(t, err)
+	require.True(t, procedure.Disabled())
 
 	_, err = NewProcedure("foo/bar")
 	require.Error(t, err)
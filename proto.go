@@ -37,3 +37,12 @@ func toProtoMessage(value any) (proto.Message, error) {
 	}
 	return message, nil
 }
+
+// isNilProtoMessage returns true if the value is nil or a nil proto.Message pointer.
+func isNilProtoMessage(value any) bool {
+	message, err := toProtoMessage(value)
+	if err != nil {
+		return false
+	}
+	return message == nil || !message.ProtoReflect().IsValid()
+}
@@ -0,0 +1,392 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+// Redactor redacts sensitive fields from request and response values before they
+// are written anywhere outside of the plugin invocation itself, such as debug dumps,
+// logs, and error details.
+type Redactor interface {
+	// Redact returns a redacted copy of the given value.
+	//
+	// The given value is never modified. If the value is not a proto.Message,
+	// the value is returned as-is.
+	Redact(value any) any
+
+	isRedactor()
+}
+
+// NewRedactor returns a new Redactor.
+//
+// By default, the Redactor clears all fields that have the debug_redact field
+// option set to true, recursively.
+func NewRedactor(options ...RedactorOption) Redactor {
+	return newRedactor(options...)
+}
+
+// RedactorOption is an option for a new Redactor.
+type RedactorOption func(*redactorOptions)
+
+// RedactorWithFieldNames returns a new RedactorOption that additionally clears
+// the fields with the given fully-qualified names, for example
+// "acme.foo.v1.LoginRequest.password".
+//
+// This is useful for messages that are not under the control of the caller and
+// therefore cannot have debug_redact set.
+func RedactorWithFieldNames(fieldNames ...string) RedactorOption {
+	return func(redactorOptions *redactorOptions) {
+		redactorOptions.fieldNames = append(redactorOptions.fieldNames, fieldNames...)
+	}
+}
+
+// *** PRIVATE ***
+
+type redactor struct {
+	fieldNames map[protoreflect.FullName]struct{}
+}
+
+func newRedactor(options ...RedactorOption) *redactor {
+	redactorOptions := newRedactorOptions()
+	for _, option := range options {
+		option(redactorOptions)
+	}
+	fieldNames := make(map[protoreflect.FullName]struct{}, len(redactorOptions.fieldNames))
+	for _, fieldName := range redactorOptions.fieldNames {
+		fieldNames[protoreflect.FullName(fieldName)] = struct{}{}
+	}
+	return &redactor{
+		fieldNames: fieldNames,
+	}
+}
+
+func (r *redactor) Redact(value any) any {
+	message, ok := value.(proto.Message)
+	if !ok || message == nil {
+		return value
+	}
+	message = proto.Clone(message)
+	r.redactMessage(message.ProtoReflect())
+	return message
+}
+
+func (*redactor) isRedactor() {}
+
+func (r *redactor) redactMessage(message protoreflect.Message) {
+	message.Range(
+		func(fieldDescriptor protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+			if r.shouldRedact(fieldDescriptor) {
+				message.Clear(fieldDescriptor)
+				return true
+			}
+			switch {
+			case fieldDescriptor.IsMap():
+				if isMessageKind(fieldDescriptor.MapValue().Kind()) {
+					value.Map().Range(
+						func(_ protoreflect.MapKey, mapValue protoreflect.Value) bool {
+							r.redactMessage(mapValue.Message())
+							return true
+						},
+					)
+				}
+			case fieldDescriptor.IsList():
+				if isMessageKind(fieldDescriptor.Kind()) {
+					list := value.List()
+					for i := 0; i < list.Len(); i++ {
+						r.redactMessage(list.Get(i).Message())
+					}
+				}
+			case isMessageKind(fieldDescriptor.Kind()):
+				r.redactMessage(value.Message())
+			}
+			return true
+		},
+	)
+}
+
+func (r *redactor) shouldRedact(fieldDescriptor protoreflect.FieldDescriptor) bool {
+	if _, ok := r.fieldNames[fieldDescriptor.FullName()]; ok {
+		return true
+	}
+	fieldOptions, ok := fieldDescriptor.Options().(*descriptorpb.FieldOptions)
+	return ok && fieldOptions.GetDebugRedact()
+}
+
+// redactErrorDetails returns the error with the details of its Error redacted, see
+// ErrorWithDetails.
+//
+// Details whose types are not registered in protoregistry.GlobalTypes cannot be redacted,
+// and are kept as-is. If the error has no details, the error is returned as-is.
+func redactErrorDetails(redactor Redactor, err error) error {
+	pluginrpcError := WrapError(err)
+	if redactor == nil || pluginrpcError == nil || len(pluginrpcError.details) == 0 {
+		return err
+	}
+	clone := *pluginrpcError
+	clone.details = make([]*anypb.Any, len(pluginrpcError.details))
+	for i, anyDetail := range pluginrpcError.details {
+		redactedAnyDetail, ok := redactAny(redactor, anyDetail)
+		if !ok {
+			redactedAnyDetail = anyDetail
+		}
+		clone.details[i] = redactedAnyDetail
+	}
+	return &clone
+}
+
+// redactedErrorDetailsJSON returns the redacted details of the Error within the error
+// as JSON, for logs.
+//
+// Details whose types are not registered in protoregistry.GlobalTypes cannot be redacted,
+// and are omitted. Returns nil if the error has no details.
+func redactedErrorDetailsJSON(redactor Redactor, err error) []json.RawMessage {
+	pluginrpcError := &Error{}
+	if !errors.As(err, &pluginrpcError) {
+		return nil
+	}
+	var details []json.RawMessage
+	for _, anyDetail := range pluginrpcError.details {
+		redactedAnyDetail, ok := redactAny(redactor, anyDetail)
+		if !ok {
+			continue
+		}
+		data, err := protojson.Marshal(redactedAnyDetail)
+		if err != nil {
+			continue
+		}
+		details = append(details, data)
+	}
+	return details
+}
+
+// redactEnvelopes returns the data with the values of the requests or responses within it
+// redacted, where the data is either a single envelope or a sequence of frames that each
+// contain an envelope, see writeFrame.
+//
+// If nothing was redacted, the data is returned as-is. Otherwise, envelopes are returned
+// uncompressed and without the binary header.
+//
+// Returns false if the data could not be decoded, in which case it cannot be redacted.
+func redactEnvelopes(redactor Redactor, format Format, data []byte, response bool) ([]byte, bool) {
+	if len(data) == 0 {
+		return data, true
+	}
+	if redactedData, ok := redactEnvelope(redactor, format, data, response); ok {
+		return redactedData, true
+	}
+	reader := bytes.NewReader(data)
+	buffer := bytes.NewBuffer(nil)
+	for {
+		frame, err := readFrame(reader, maxFrameSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		redactedFrame, ok := redactEnvelope(redactor, format, frame, response)
+		if !ok {
+			return nil, false
+		}
+		if err := writeFrame(buffer, redactedFrame); err != nil {
+			return nil, false
+		}
+	}
+	return buffer.Bytes(), true
+}
+
+// redactEnvelope returns the envelope with the value of the request or response redacted.
+//
+// Returns false if the data is not a single envelope, or if the value could not be decoded.
+func redactEnvelope(redactor Redactor, format Format, data []byte, response bool) ([]byte, bool) {
+	if len(data) == 0 {
+		return data, true
+	}
+	envelopeData, _, err := decompressEnvelope(data, defaultMaxDecompressedBytes, 0)
+	if err != nil {
+		return nil, false
+	}
+	if format == FormatBinary {
+		envelopeData, _, err = stripBinaryHeader(envelopeData)
+		if err != nil {
+			return nil, false
+		}
+	}
+	codec, err := codecForFormat(format)
+	if err != nil {
+		return nil, false
+	}
+	var envelope interface {
+		proto.Message
+		GetValue() *anypb.Any
+	}
+	if response {
+		envelope = &pluginrpcv1.Response{}
+	} else {
+		envelope = &pluginrpcv1.Request{}
+	}
+	// Unknown fields cannot be redacted.
+	if err := codec.Unmarshal(envelopeData, envelope); err != nil || len(envelope.ProtoReflect().GetUnknown()) > 0 {
+		return nil, false
+	}
+	anyValue := envelope.GetValue()
+	redactedAnyValue, ok := redactEnvelopeValue(redactor, anyValue)
+	if !ok {
+		return nil, false
+	}
+	if redactedAnyValue == anyValue {
+		return data, true
+	}
+	switch envelope := envelope.(type) {
+	case *pluginrpcv1.Request:
+		envelope.Value = redactedAnyValue
+	case *pluginrpcv1.Response:
+		envelope.Value = redactedAnyValue
+	}
+	redactedData, err := codec.Marshal(envelope)
+	if err != nil {
+		return nil, false
+	}
+	return redactedData, true
+}
+
+// redactEnvelopeValue returns the value of an envelope redacted, including values wrapped
+// in an extv1.MetadataValue, and the details within an extv1.ErrorDetails.
+//
+// If nothing was redacted, the value is returned as-is. Returns false if the value could
+// not be decoded.
+func redactEnvelopeValue(redactor Redactor, anyValue *anypb.Any) (*anypb.Any, bool) {
+	switch {
+	case anyValue == nil:
+		return nil, true
+	case anyValue.MessageIs(&extv1.MetadataValue{}):
+		protoMetadataValue := &extv1.MetadataValue{}
+		if err := anypb.UnmarshalTo(anyValue, protoMetadataValue, proto.UnmarshalOptions{}); err != nil {
+			return nil, false
+		}
+		redactedValue, ok := redactEnvelopeValue(redactor, protoMetadataValue.GetValue())
+		if !ok {
+			return nil, false
+		}
+		if redactedValue == protoMetadataValue.GetValue() {
+			return anyValue, true
+		}
+		protoMetadataValue.Value = redactedValue
+		redactedAnyValue, err := anypb.New(protoMetadataValue)
+		return redactedAnyValue, err == nil
+	case anyValue.MessageIs(&extv1.PartialResultValue{}):
+		protoPartialResultValue := &extv1.PartialResultValue{}
+		if err := anypb.UnmarshalTo(anyValue, protoPartialResultValue, proto.UnmarshalOptions{}); err != nil {
+			return nil, false
+		}
+		redactedValue, ok := redactEnvelopeValue(redactor, protoPartialResultValue.GetValue())
+		if !ok {
+			return nil, false
+		}
+		redactedDetails, ok := redactProtoErrorDetails(redactor, protoPartialResultValue.GetErrorDetails())
+		if !ok {
+			return nil, false
+		}
+		if redactedValue == protoPartialResultValue.GetValue() && !redactedDetails {
+			return anyValue, true
+		}
+		protoPartialResultValue.Value = redactedValue
+		redactedAnyValue, err := anypb.New(protoPartialResultValue)
+		return redactedAnyValue, err == nil
+	case anyValue.MessageIs(&extv1.ErrorDetails{}):
+		protoErrorDetails := &extv1.ErrorDetails{}
+		if err := anypb.UnmarshalTo(anyValue, protoErrorDetails, proto.UnmarshalOptions{}); err != nil {
+			return nil, false
+		}
+		redactedDetails, ok := redactProtoErrorDetails(redactor, protoErrorDetails)
+		if !ok {
+			return nil, false
+		}
+		if !redactedDetails {
+			return anyValue, true
+		}
+		redactedAnyValue, err := anypb.New(protoErrorDetails)
+		return redactedAnyValue, err == nil
+	default:
+		return redactAny(redactor, anyValue)
+	}
+}
+
+// redactProtoErrorDetails redacts the details within the extv1.ErrorDetails in place.
+//
+// Returns true as the first value if any detail was redacted. Returns false as the second
+// value if a detail could not be decoded.
+func redactProtoErrorDetails(redactor Redactor, protoErrorDetails *extv1.ErrorDetails) (bool, bool) {
+	var redacted bool
+	for i, anyDetail := range protoErrorDetails.GetDetails() {
+		redactedAnyDetail, ok := redactAny(redactor, anyDetail)
+		if !ok {
+			return false, false
+		}
+		if redactedAnyDetail != anyDetail {
+			protoErrorDetails.Details[i] = redactedAnyDetail
+			redacted = true
+		}
+	}
+	return redacted, true
+}
+
+// redactAny returns a redacted copy of the message within the Any.
+//
+// Returns false if the type of the message is not registered in protoregistry.GlobalTypes.
+func redactAny(redactor Redactor, anyValue *anypb.Any) (*anypb.Any, bool) {
+	message, err := anyValue.UnmarshalNew()
+	if err != nil {
+		return nil, false
+	}
+	redactedMessage, ok := redactor.Redact(message).(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	if proto.Equal(message, redactedMessage) {
+		return anyValue, true
+	}
+	redactedAnyValue, err := anypb.New(redactedMessage)
+	if err != nil {
+		return nil, false
+	}
+	return redactedAnyValue, true
+}
+
+func isMessageKind(kind protoreflect.Kind) bool {
+	return kind == protoreflect.MessageKind || kind == protoreflect.GroupKind
+}
+
+type redactorOptions struct {
+	fieldNames []string
+}
+
+func newRedactorOptions() *redactorOptions {
+	return &redactorOptions{}
+}
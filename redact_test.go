@@ -0,0 +1,181 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+)
+
+func TestRedactorDebugRedact(t *testing.T) {
+	t.Parallel()
+
+	messageDescriptor := newTestRedactMessageDescriptor(t)
+	message := dynamicpb.NewMessage(messageDescriptor)
+	message.Set(messageDescriptor.Fields().ByName("public"), protoreflect.ValueOfString("foo"))
+	message.Set(messageDescriptor.Fields().ByName("secret"), protoreflect.ValueOfString("bar"))
+	child := dynamicpb.NewMessage(messageDescriptor)
+	child.Set(messageDescriptor.Fields().ByName("public"), protoreflect.ValueOfString("baz"))
+	child.Set(messageDescriptor.Fields().ByName("secret"), protoreflect.ValueOfString("bat"))
+	children := message.Mutable(messageDescriptor.Fields().ByName("children")).List()
+	children.Append(protoreflect.ValueOfMessage(child))
+
+	redacted, ok := NewRedactor().Redact(message).(proto.Message)
+	require.True(t, ok)
+	redactedMessage := redacted.ProtoReflect()
+	require.Equal(t, "foo", redactedMessage.Get(messageDescriptor.Fields().ByName("public")).String())
+	require.False(t, redactedMessage.Has(messageDescriptor.Fields().ByName("secret")))
+	redactedChild := redactedMessage.Get(messageDescriptor.Fields().ByName("children")).List().Get(0).Message()
+	require.Equal(t, "baz", redactedChild.Get(messageDescriptor.Fields().ByName("public")).String())
+	require.False(t, redactedChild.Has(messageDescriptor.Fields().ByName("secret")))
+
+	// The original message is not modified.
+	require.Equal(t, "bar", message.Get(messageDescriptor.Fields().ByName("secret")).String())
+	require.Equal(t, "bat", child.Get(messageDescriptor.Fields().ByName("secret")).String())
+}
+
+func TestRedactorFieldNames(t *testing.T) {
+	t.Parallel()
+
+	request := &examplev1.EchoRequestRequest{Message: "hello"}
+	redacted := NewRedactor(
+		RedactorWithFieldNames("pluginrpc.example.v1.EchoRequestRequest.message"),
+	).Redact(request)
+	require.Empty(t, redacted.(*examplev1.EchoRequestRequest).GetMessage())
+	require.Equal(t, "hello", request.GetMessage())
+
+	require.Equal(t, "foo", NewRedactor().Redact("foo"))
+}
+
+func TestRedactorSinks(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					return nil, NewError(
+						CodeInvalidArgument,
+						errors.New("invalid"),
+						ErrorWithDetails(
+							&examplev1.EchoRequestRequest{Message: "server-secret"},
+							&examplev1.EchoErrorRequest{Message: "client-secret"},
+						),
+					)
+				},
+				options...,
+			)
+		},
+	)
+	serverLogs := bytes.NewBuffer(nil)
+	server, err := NewServer(
+		spec,
+		serverRegistrar,
+		ServerWithLogger(slog.New(slog.NewJSONHandler(serverLogs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		ServerWithRedactor(NewRedactor(RedactorWithFieldNames("pluginrpc.example.v1.EchoRequestRequest.message"))),
+	)
+	require.NoError(t, err)
+	clientLogs := bytes.NewBuffer(nil)
+	auditLog := bytes.NewBuffer(nil)
+	client := NewClient(
+		NewServerRunner(server),
+		ClientWithErrorDetails(),
+		ClientWithLogger(slog.New(slog.NewJSONHandler(clientLogs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		ClientWithAuditLog(auditLog),
+		ClientWithRedactor(NewRedactor(RedactorWithFieldNames("pluginrpc.example.v1.EchoErrorRequest.message"))),
+	)
+	err = client.Call(context.Background(), "/foo/bar", nil, nil)
+	require.Equal(t, CodeInvalidArgument, WrapError(err).Code())
+
+	// The server redacts the details it sends, but the client does not redact the details
+	// it returns, as they are not written anywhere outside of the call.
+	details := WrapError(err).Details()
+	require.Len(t, details, 2)
+	require.Empty(t, details[0].(*examplev1.EchoRequestRequest).GetMessage())
+	require.Equal(t, "client-secret", details[1].(*examplev1.EchoErrorRequest).GetMessage())
+
+	require.Contains(t, serverLogs.String(), `"details"`)
+	require.NotContains(t, serverLogs.String(), "server-secret")
+	require.Contains(t, clientLogs.String(), `"details"`)
+	require.NotContains(t, clientLogs.String(), "client-secret")
+	require.Contains(t, auditLog.String(), `"error_details"`)
+	require.NotContains(t, auditLog.String(), "client-secret")
+}
+
+func newTestRedactMessageDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	fileDescriptor, err := protodesc.NewFile(
+		&descriptorpb.FileDescriptorProto{
+			Name:    proto.String("redact.proto"),
+			Package: proto.String("redact"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("Message"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						{
+							Name:     proto.String("public"),
+							Number:   proto.Int32(1),
+							Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+							Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+							JsonName: proto.String("public"),
+						},
+						{
+							Name:     proto.String("secret"),
+							Number:   proto.Int32(2),
+							Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+							Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+							JsonName: proto.String("secret"),
+							Options: &descriptorpb.FieldOptions{
+								DebugRedact: proto.Bool(true),
+							},
+						},
+						{
+							Name:     proto.String("children"),
+							Number:   proto.Int32(3),
+							Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+							Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+							TypeName: proto.String(".redact.Message"),
+							JsonName: proto.String("children"),
+						},
+					},
+				},
+			},
+		},
+		nil,
+	)
+	require.NoError(t, err)
+	return fileDescriptor.Messages().ByName("Message")
+}
@@ -0,0 +1,215 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultReplayWindow is the default window within which request timestamps are accepted.
+	defaultReplayWindow = 5 * time.Minute
+	// maxNonceLength is the maximum length of a nonce.
+	maxNonceLength = 128
+	// nonceByteLength is the number of random bytes in a generated nonce.
+	nonceByteLength = 16
+)
+
+// NonceStore stores nonces of requests to replay-protected Procedures.
+//
+// As plugins are typically invoked as a new process for every call, a NonceStore
+// must persist nonces across processes to be effective, for example by using
+// NewDirNonceStore.
+type NonceStore interface {
+	// Add adds the nonce to the store.
+	//
+	// The nonce only needs to be retained until the given expiration. Returns false
+	// if the nonce was already present and not expired.
+	Add(ctx context.Context, nonce string, expiration time.Time) (bool, error)
+}
+
+// NewMemoryNonceStore returns a new NonceStore that stores nonces in memory.
+//
+// This is only effective if the same Server serves multiple calls within a single
+// process, and is primarily used for testing.
+func NewMemoryNonceStore() NonceStore {
+	return newMemoryNonceStore()
+}
+
+// NewDirNonceStore returns a new NonceStore that stores nonces as files within the given
+// directory, which is created if it does not exist.
+//
+// This allows replay protection across plugin processes on the same machine. Expired
+// nonces are removed when new nonces are added.
+func NewDirNonceStore(dirPath string) NonceStore {
+	return newDirNonceStore(dirPath)
+}
+
+// *** PRIVATE ***
+
+// verifyReplay verifies that the nonce and timestamp are valid and that the nonce
+// has not been used before within the window.
+func verifyReplay(
+	ctx context.Context,
+	nonceStore NonceStore,
+	window time.Duration,
+	now time.Time,
+	procedure Procedure,
+	nonce string,
+	timestamp time.Time,
+) error {
+	if nonce == "" {
+		return NewErrorf(CodeInvalidArgument, "procedure %q requires --%s", procedure.Path(), NonceFlagName)
+	}
+	if timestamp.IsZero() {
+		return NewErrorf(CodeInvalidArgument, "procedure %q requires --%s", procedure.Path(), TimestampFlagName)
+	}
+	if skew := now.Sub(timestamp); skew > window || skew < -window {
+		return NewErrorf(CodeInvalidArgument, "request timestamp %s is outside of the allowed window of %v", timestamp.Format(time.RFC3339Nano), window)
+	}
+	added, err := nonceStore.Add(ctx, nonce, timestamp.Add(window))
+	if err != nil {
+		return NewError(CodeInternal, fmt.Errorf("failed to add nonce: %w", err))
+	}
+	if !added {
+		return NewErrorf(CodeAlreadyExists, "request with nonce %q to procedure %q was already received", nonce, procedure.Path())
+	}
+	return nil
+}
+
+func validateNonce(nonce string) error {
+	if len(nonce) > maxNonceLength {
+		return fmt.Errorf("--%s must be at most %d characters", NonceFlagName, maxNonceLength)
+	}
+	for _, c := range nonce {
+		if c < 0x21 || c > 0x7e {
+			return fmt.Errorf("--%s must only consist of printable ASCII characters", NonceFlagName)
+		}
+	}
+	return nil
+}
+
+// newNonce returns a new random nonce.
+func newNonce() (string, error) {
+	data := make([]byte, nonceByteLength)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+type memoryNonceStore struct {
+	nonceToExpiration map[string]time.Time
+	lock              sync.Mutex
+}
+
+func newMemoryNonceStore() *memoryNonceStore {
+	return &memoryNonceStore{
+		nonceToExpiration: make(map[string]time.Time),
+	}
+}
+
+func (m *memoryNonceStore) Add(_ context.Context, nonce string, expiration time.Time) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := time.Now()
+	for existingNonce, existingExpiration := range m.nonceToExpiration {
+		if now.After(existingExpiration) {
+			delete(m.nonceToExpiration, existingNonce)
+		}
+	}
+	if _, ok := m.nonceToExpiration[nonce]; ok {
+		return false, nil
+	}
+	m.nonceToExpiration[nonce] = expiration
+	return true, nil
+}
+
+type dirNonceStore struct {
+	dirPath string
+}
+
+func newDirNonceStore(dirPath string) *dirNonceStore {
+	return &dirNonceStore{
+		dirPath: dirPath,
+	}
+}
+
+func (d *dirNonceStore) Add(_ context.Context, nonce string, expiration time.Time) (bool, error) {
+	if err := os.MkdirAll(d.dirPath, 0o700); err != nil {
+		return false, err
+	}
+	if err := d.removeExpired(time.Now()); err != nil {
+		return false, err
+	}
+	// Hash the nonce so that it is always a valid file name.
+	sum := sha256.Sum256([]byte(nonce))
+	file, err := os.OpenFile(
+		filepath.Join(d.dirPath, hex.EncodeToString(sum[:])),
+		os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		0o600,
+	)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	_, err = file.WriteString(strconv.FormatInt(expiration.UnixNano(), 10))
+	return true, errors.Join(err, file.Close())
+}
+
+func (d *dirNonceStore) removeExpired(now time.Time) error {
+	dirEntries, err := os.ReadDir(d.dirPath)
+	if err != nil {
+		return err
+	}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+		filePath := filepath.Join(d.dirPath, dirEntry.Name())
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// Removed concurrently.
+				continue
+			}
+			return err
+		}
+		expirationUnixNano, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			// Partially written by a concurrent Add, or not a nonce file.
+			continue
+		}
+		if now.After(time.Unix(0, expirationUnixNano)) {
+			if err := os.Remove(filePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayProtection(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar", ProcedureWithReplayProtection())
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	var calls int
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					calls++
+					return nil, nil
+				},
+				options...,
+			)
+		},
+	)
+	_, err = NewServer(spec, serverRegistrar)
+	require.Error(t, err)
+	server, err := NewServer(spec, serverRegistrar, ServerWithNonceStore(NewMemoryNonceStore()))
+	require.NoError(t, err)
+
+	client := NewClient(NewServerRunner(server))
+	pluginrpcError := &Error{}
+	err = client.Call(context.Background(), "/foo/bar", nil, nil)
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeInvalidArgument, pluginrpcError.Code())
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, nil, CallWithNonce("foo")))
+	err = client.Call(context.Background(), "/foo/bar", nil, nil, CallWithNonce("foo"))
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeAlreadyExists, pluginrpcError.Code())
+	require.Equal(t, 1, calls)
+
+	client = NewClient(NewServerRunner(server), ClientWithReplayProtection())
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, nil))
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, nil))
+	require.Equal(t, 3, calls)
+
+	// Timestamps outside of the window are rejected.
+	err = verifyReplay(
+		context.Background(),
+		NewMemoryNonceStore(),
+		time.Minute,
+		time.Now(),
+		procedure,
+		"bar",
+		time.Now().Add(-2*time.Minute),
+	)
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeInvalidArgument, pluginrpcError.Code())
+}
+
+func TestDirNonceStore(t *testing.T) {
+	t.Parallel()
+
+	dirPath := t.TempDir()
+	nonceStore := NewDirNonceStore(dirPath)
+	added, err := nonceStore.Add(context.Background(), "foo", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, added)
+	// A separate NonceStore for the same directory, as used by a separate plugin process.
+	added, err = NewDirNonceStore(dirPath).Add(context.Background(), "foo", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.False(t, added)
+
+	added, err = nonceStore.Add(context.Background(), "bar", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.True(t, added)
+	// The expired nonce is removed when the next nonce is added.
+	added, err = nonceStore.Add(context.Background(), "bar", time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, added)
+}
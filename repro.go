@@ -0,0 +1,183 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"errors"
+	"strings"
+)
+
+// ReproCommandOf returns a shell command that reproduces the call that returned the error
+// outside of the host, for example to debug a failing plugin.
+//
+// The command runs the program with the args of the call, an environment that only contains
+// the configured environment variables, and the request piped to stdin, for example:
+//
+//	printf '%s' '{"message":"hello"}' | env -i TOKEN="$TOKEN" /usr/local/bin/echo-plugin echo request --format json
+//
+// The values of the environment variables are not part of the command, as they may contain
+// secrets, and are instead taken from the shell that runs the command. Likewise, the values
+// of --metadata flags are masked, see CallWithMetadata. The request is
+// redacted with the Redactor given by ClientWithRedactor. If the request cannot be redacted,
+// for example because the type of its value is not registered in protoregistry.GlobalTypes,
+// it is not piped to stdin.
+//
+// The command is only available for errors returned from unary and server streaming calls
+// to plugins run with NewExecRunner or NewExecServeRunner, after the plugin was run.
+// Otherwise, this returns the empty string.
+func ReproCommandOf(err error) string {
+	reproCommandError := &reproCommandError{}
+	if errors.As(err, &reproCommandError) {
+		return reproCommandError.command
+	}
+	return ""
+}
+
+// *** PRIVATE ***
+
+// reproCommandError wraps an error with the shell command that reproduces it.
+//
+// The message of the underlying error is unchanged.
+type reproCommandError struct {
+	underlying error
+	command    string
+}
+
+func (e *reproCommandError) Error() string {
+	return e.underlying.Error()
+}
+
+func (e *reproCommandError) Unwrap() error {
+	return e.underlying
+}
+
+// withReproCommand wraps the error with the shell command that reproduces the invocation
+// of the plugin with the given args and stdin.
+//
+// If the error is nil, or programRunner is nil, the error is returned as-is.
+func withReproCommand(err error, programRunner programRunner, redactor Redactor, args []string, stdin []byte) error {
+	if err == nil || programRunner == nil {
+		return err
+	}
+	command, ok := newReproCommand(programRunner, redactor, args, stdin)
+	if !ok {
+		return err
+	}
+	return &reproCommandError{
+		underlying: err,
+		command:    command,
+	}
+}
+
+// newReproCommand returns the shell command that invokes the program of the Runner with
+// the given args and stdin.
+//
+// The values of environment variables are masked, and stdin is redacted with the redactor.
+//
+// Returns false if the program could not be resolved.
+func newReproCommand(programRunner programRunner, redactor Redactor, args []string, stdin []byte) (string, bool) {
+	programPath, err := programRunner.programPath()
+	if err != nil {
+		return "", false
+	}
+	// If stdin cannot be redacted, it is not piped to the program.
+	stdin, _ = redactEnvelopes(redactor, formatForArgs(args), stdin, false)
+	var sb strings.Builder
+	if len(stdin) > 0 {
+		_, _ = sb.WriteString("printf ")
+		_, _ = sb.WriteString(shellPrintfArgs(stdin))
+		_, _ = sb.WriteString(" | ")
+	}
+	// The program is run with an environment that only contains the configured
+	// environment variables, see newCmdEnv.
+	_, _ = sb.WriteString("env -i")
+	for _, keyValue := range programRunner.programEnv() {
+		_, _ = sb.WriteString(" ")
+		_, _ = sb.WriteString(shellEnvVar(keyValue))
+	}
+	for _, arg := range maskMetadataArgs(append(append([]string{programPath}, programRunner.programArgs()...), args...)) {
+		_, _ = sb.WriteString(" ")
+		_, _ = sb.WriteString(shellQuote(arg))
+	}
+	return sb.String(), true
+}
+
+// shellEnvVar returns the assignment of the environment variable with its value masked,
+// such that the value is taken from the environment of the shell.
+//
+// Names that cannot be expanded by a shell are assigned the empty string.
+func shellEnvVar(keyValue string) string {
+	key, _, _ := strings.Cut(keyValue, "=")
+	if key == "" || !isShellName(key) {
+		return shellQuote(key + "=")
+	}
+	return key + `="$` + key + `"`
+}
+
+// isShellName returns true if the name is a valid name of a POSIX shell variable.
+func isShellName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// shellPrintfArgs returns the args to printf that write the data exactly.
+//
+// Text is passed as an arg to the %s verb, so that it is readable. Other data is
+// written as a format with octal escapes, as printf does not support hexadecimal
+// escapes in all shells.
+func shellPrintfArgs(data []byte) string {
+	if isDebugText(data) {
+		return "'%s' " + shellQuote(string(data))
+	}
+	var sb strings.Builder
+	_, _ = sb.WriteString("'")
+	for _, b := range data {
+		if b >= 0x20 && b < 0x7f && b != '\'' && b != '\\' && b != '%' {
+			_ = sb.WriteByte(b)
+			continue
+		}
+		_ = sb.WriteByte('\\')
+		_ = sb.WriteByte('0' + (b>>6)&7)
+		_ = sb.WriteByte('0' + (b>>3)&7)
+		_ = sb.WriteByte('0' + b&7)
+	}
+	_, _ = sb.WriteString("'")
+	return sb.String()
+}
+
+// shellQuote quotes the arg with single quotes if it would not be read as a single
+// literal arg by a POSIX shell.
+func shellQuote(arg string) string {
+	if arg != "" && !strings.ContainsFunc(arg, isShellSpecialRune) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+func isShellSpecialRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	default:
+		return !strings.ContainsRune("_-./:=@,+%", r)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestReproCommandOf(t *testing.T) {
+	t.Parallel()
+
+	echoPluginProgramPath, err := exec.LookPath(echoPluginProgramName)
+	require.NoError(t, err)
+	for _, format := range []pluginrpc.Format{pluginrpc.FormatJSON, pluginrpc.FormatBinary} {
+		debugWriter := bytes.NewBuffer(nil)
+		client := pluginrpc.NewClient(
+			pluginrpc.NewExecRunner(echoPluginProgramName, pluginrpc.ExecRunnerWithEnv(map[string]string{"FOO": "it's"})),
+			pluginrpc.ClientWithFormat(format),
+			pluginrpc.ClientWithDebugWriter(debugWriter),
+		)
+		echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+		require.NoError(t, err)
+		_, err = echoServiceClient.EchoError(
+			context.Background(),
+			&examplev1.EchoErrorRequest{
+				Code:    pluginrpcv1.Code_CODE_NOT_FOUND,
+				Message: "it's a 100% failure",
+			},
+		)
+		require.Error(t, err)
+		reproCommand := pluginrpc.ReproCommandOf(err)
+		// The values of environment variables are masked.
+		require.Contains(t, reproCommand, `env -i FOO="$FOO" `+echoPluginProgramPath+" echo error --format "+format.String())
+		require.Contains(t, debugWriter.String(), "reproduce: "+reproCommand+"\n")
+
+		// The command reproduces the response of the plugin.
+		stdout := bytes.NewBuffer(nil)
+		cmd := exec.Command("sh", "-c", reproCommand)
+		cmd.Stdout = stdout
+		_ = cmd.Run()
+		require.True(t, strings.Contains(stdout.String(), "it's a 100% failure"), stdout.String())
+	}
+
+	// Requests are redacted.
+	client := pluginrpc.NewClient(
+		pluginrpc.NewExecRunner(echoPluginProgramName),
+		pluginrpc.ClientWithRedactor(
+			pluginrpc.NewRedactor(pluginrpc.RedactorWithFieldNames("pluginrpc.example.v1.EchoErrorRequest.message")),
+		),
+	)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	_, err = echoServiceClient.EchoError(
+		context.Background(),
+		&examplev1.EchoErrorRequest{
+			Code:    pluginrpcv1.Code_CODE_NOT_FOUND,
+			Message: "secret",
+		},
+	)
+	require.Error(t, err)
+	require.Contains(t, pluginrpc.ReproCommandOf(err), "printf ")
+	require.NotContains(t, pluginrpc.ReproCommandOf(err), "secret")
+
+	// Errors from Runners that do not run a program on disk have no command.
+	client, err = newServerRunnerClient(t)
+	require.NoError(t, err)
+	echoServiceClient, err = examplev1pluginrpc.NewEchoServiceClient(client)
+	require.NoError(t, err)
+	_, err = echoServiceClient.EchoError(context.Background(), &examplev1.EchoErrorRequest{Code: pluginrpcv1.Code_CODE_NOT_FOUND})
+	require.Error(t, err)
+	require.Empty(t, pluginrpc.ReproCommandOf(err))
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// RetryOption is an option for ClientWithRetry.
+type RetryOption func(*retryOptions)
+
+// RetryWithInitialBackoff returns a new RetryOption that specifies the backoff before
+// the first retry. The backoff doubles for every subsequent retry.
+//
+// The default is 100 milliseconds.
+func RetryWithInitialBackoff(initialBackoff time.Duration) RetryOption {
+	return func(retryOptions *retryOptions) {
+		retryOptions.initialBackoff = initialBackoff
+	}
+}
+
+// RetryWithMaxBackoff returns a new RetryOption that specifies the maximum backoff
+// between retries.
+//
+// The default is 5 seconds.
+func RetryWithMaxBackoff(maxBackoff time.Duration) RetryOption {
+	return func(retryOptions *retryOptions) {
+		retryOptions.maxBackoff = maxBackoff
+	}
+}
+
+// RetryWithCodes returns a new RetryOption that specifies the Codes of errors returned
+// by the plugin that are retried.
+//
+// The default is CodeUnavailable and CodeAborted.
+func RetryWithCodes(codes ...Code) RetryOption {
+	return func(retryOptions *retryOptions) {
+		retryOptions.codes = codes
+	}
+}
+
+// *** PRIVATE ***
+
+const (
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+)
+
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	codes          []Code
+}
+
+// newRetryPolicy returns a new retryPolicy.
+//
+// Returns nil if maxAttempts is less than two, as there is nothing to retry.
+func newRetryPolicy(maxAttempts int, options ...RetryOption) *retryPolicy {
+	if maxAttempts < 2 {
+		return nil
+	}
+	retryOptions := newRetryOptions()
+	for _, option := range options {
+		option(retryOptions)
+	}
+	if retryOptions.initialBackoff <= 0 {
+		retryOptions.initialBackoff = defaultRetryInitialBackoff
+	}
+	if retryOptions.maxBackoff <= 0 {
+		retryOptions.maxBackoff = defaultRetryMaxBackoff
+	}
+	if retryOptions.codes == nil {
+		retryOptions.codes = []Code{CodeUnavailable, CodeAborted}
+	}
+	return &retryPolicy{
+		maxAttempts:    maxAttempts,
+		initialBackoff: retryOptions.initialBackoff,
+		maxBackoff:     retryOptions.maxBackoff,
+		codes:          retryOptions.codes,
+	}
+}
+
+// shouldRetry returns true if the error is transient.
+//
+// Errors reported by the plugin with a retryable Code and errors where the plugin could
+// not be run are transient.
+func (r *retryPolicy) shouldRetry(err error) bool {
+	if ErrorSourceOf(err) == ErrorSourceSpawn {
+		return true
+	}
+	pluginrpcError := &Error{}
+	return errors.As(err, &pluginrpcError) && slices.Contains(r.codes, pluginrpcError.Code())
+}
+
+// backoff returns the duration to wait before the given retry, starting at 1.
+//
+// If the plugin gave a RetryAfter hint, the hint is used instead. Otherwise, the
+// backoff is exponential with jitter.
+func (r *retryPolicy) backoff(retry int, err error) time.Duration {
+	pluginrpcError := &Error{}
+	if errors.As(err, &pluginrpcError) && pluginrpcError.RetryAfter() > 0 {
+		return pluginrpcError.RetryAfter()
+	}
+	backoff := r.initialBackoff
+	for i := 1; i < retry && backoff < r.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > r.maxBackoff {
+		backoff = r.maxBackoff
+	}
+	// Full jitter between half of the backoff and the backoff.
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)) //nolint:gosec // jitter does not need a secure source
+}
+
+// retryCallFunc wraps the CallFunc to retry transient errors.
+//
+// Calls to replay-protected Procedures use the same nonce for each attempt, so that
+// a call that was executed by the plugin is not executed again. The response is reset
+// before each retry. If the Spec could not be retrieved, it is retrieved again.
+func (c *client) retryCallFunc(callFunc CallFunc) CallFunc {
+	if c.retryPolicy == nil {
+		return callFunc
+	}
+	return func(ctx context.Context, procedurePath string, request any, response any, options ...CallOption) error {
+		callOptions := newCallOptions()
+		for _, option := range options {
+			option(callOptions)
+		}
+		// Payloads cannot be read twice, see CallWithRequestPayload.
+		if callOptions.requestPayload != nil || callOptions.responsePayload != nil {
+			return callFunc(ctx, procedurePath, request, response, options...)
+		}
+		if c.replayProtection && callOptions.nonce == "" {
+			nonce, err := newNonce()
+			if err != nil {
+				return err
+			}
+			options = append(slices.Clone(options), CallWithNonce(nonce))
+		}
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = callFunc(ctx, procedurePath, request, response, options...)
+			if err == nil || attempt >= c.retryPolicy.maxAttempts || !c.retryPolicy.shouldRetry(err) {
+				return err
+			}
+			timer := time.NewTimer(c.retryPolicy.backoff(attempt, err))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			resetResponse(response)
+			c.clearSpecErr()
+		}
+	}
+}
+
+// resetResponse resets the response before it is used for another invocation of the plugin.
+func resetResponse(response any) {
+	if protoResponse, ok := response.(proto.Message); ok && !isNilProtoMessage(protoResponse) {
+		proto.Reset(protoResponse)
+	}
+}
+
+type retryOptions struct {
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	codes          []Code
+}
+
+func newRetryOptions() *retryOptions {
+	return &retryOptions{}
+}
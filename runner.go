@@ -18,19 +18,25 @@ import (
 	"context"
 	"errors"
 	"io"
+	"maps"
+	"os"
 	"os/exec"
 	"slices"
+	"sort"
+	"time"
 )
 
 var emptyEnv = []string{"__EMPTY_ENV=1"}
 
 // Runner runs external commands.
 //
-// Runners should not proxy any environment variables to the commands they run.
+// Runners should not proxy any environment variables to the commands they run, unless
+// explicitly configured to, see ExecRunnerWithEnv and ExecRunnerWithInheritedEnvVars.
 type Runner interface {
 	// Run runs the external command with the given environment.
 	//
-	// The environment variables are always cleared before running the command.
+	// The environment variables are always cleared before running the command, and only
+	// explicitly-configured environment variables are set.
 	// If no stdin, stdout, or stderr are provided, the equivalent of /dev/null are given to the command.
 	// The command is run in the context of the current working directory.
 	//
@@ -40,6 +46,11 @@ type Runner interface {
 
 // NewExecRunner returns a new Runner that uses os/exec to call the given
 // external command given by the program name.
+//
+// The program can be a script. If a script cannot be run, for example because its
+// interpreter does not exist or its shebang line ends in a carriage return, the returned
+// error explains why. On Windows, batch files (.bat and .cmd) are run with cmd.exe, with
+// args escaped for cmd.exe.
 func NewExecRunner(programName string, options ...ExecRunnerOption) Runner {
 	return newExecRunner(programName, options...)
 }
@@ -61,8 +72,187 @@ func ExecRunnerWithArgs(args ...string) ExecRunnerOption {
 	}
 }
 
+// ExecRunnerWithCmdOption returns a new ExecRunnerOption that calls the given function
+// on each *exec.Cmd after it has been configured and before it is run.
+//
+// This is an escape hatch for platform-specific configuration that is not otherwise
+// modeled, for example setting SysProcAttr to drop credentials, chroot, or adjust niceness.
+// Modifying Args, Env, Stdin, Stdout, or Stderr will override the behavior of the Runner
+// and is not recommended.
+//
+// This option can be specified multiple times, in which case the functions are called in order.
+func ExecRunnerWithCmdOption(cmdOption func(*exec.Cmd)) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		if cmdOption != nil {
+			execRunnerOptions.cmdOptions = append(execRunnerOptions.cmdOptions, cmdOption)
+		}
+	}
+}
+
+// ExecRunnerWithEnv returns a new ExecRunnerOption that sets the given environment
+// variables for the command.
+//
+// These take precedence over environment variables specified with ExecRunnerWithInheritedEnvVars.
+// This option can be specified multiple times, in which case the environment variables are merged.
+func ExecRunnerWithEnv(env map[string]string) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		if execRunnerOptions.env == nil {
+			execRunnerOptions.env = make(map[string]string, len(env))
+		}
+		for key, value := range env {
+			execRunnerOptions.env[key] = value
+		}
+	}
+}
+
+// ExecRunnerWithInheritedEnvVars returns a new ExecRunnerOption that passes through the
+// given environment variables from the current process to the command, for example
+// "PATH", "HOME", or "TMPDIR".
+//
+// Environment variables are read each time the command is run. Environment variables
+// that are not set in the current process are not set for the command.
+// This option can be specified multiple times, in which case the names are appended.
+func ExecRunnerWithInheritedEnvVars(names ...string) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.inheritedEnvVars = append(execRunnerOptions.inheritedEnvVars, names...)
+	}
+}
+
+// ExecRunnerWithHeartbeat returns a new ExecRunnerOption that pings the plugin at the
+// given interval, and kills the plugin if it does not respond within the given timeout.
+//
+// This only applies to ServeRunners created with NewExecServeRunner, where the plugin is
+// long-lived. Calls that are in flight when the plugin is killed fail, and the plugin is
+// restarted on the next call. The plugin must support heartbeats within --serve sessions.
+//
+// The default is to not send heartbeats. An interval or timeout that is not positive
+// disables heartbeats.
+func ExecRunnerWithHeartbeat(interval time.Duration, timeout time.Duration) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.heartbeatInterval = interval
+		execRunnerOptions.heartbeatTimeout = timeout
+	}
+}
+
+// ExecRunnerWithRecycleAfterCalls returns a new ExecRunnerOption that restarts the
+// plugin after it has served the given number of calls.
+//
+// This only applies to ServeRunners created with NewExecServeRunner, where the plugin is
+// long-lived, and is useful to bound memory leaks in plugins. Calls that are in flight
+// complete on the old plugin process, which then exits.
+//
+// The default is to never restart the plugin based on the number of calls.
+func ExecRunnerWithRecycleAfterCalls(calls int) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.recycleAfterCalls = calls
+	}
+}
+
+// ExecRunnerWithRecycleAfterDuration returns a new ExecRunnerOption that restarts the
+// plugin once it has been running for the given duration.
+//
+// See ExecRunnerWithRecycleAfterCalls for the semantics of restarts.
+//
+// The default is to never restart the plugin based on its age.
+func ExecRunnerWithRecycleAfterDuration(duration time.Duration) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.recycleAfterDuration = duration
+	}
+}
+
+// ExecRunnerWithRecycleAfterRSSBytes returns a new ExecRunnerOption that restarts the
+// plugin once its resident set size exceeds the given number of bytes.
+//
+// The resident set size is checked before each call. This is only supported on Linux,
+// and has no effect on other platforms. See ExecRunnerWithRecycleAfterCalls for the
+// semantics of restarts.
+//
+// The default is to never restart the plugin based on its memory usage.
+func ExecRunnerWithRecycleAfterRSSBytes(rssBytes uint64) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.recycleAfterRSSBytes = rssBytes
+	}
+}
+
+// ExecRunnerWithSessionMetadata returns a new ExecRunnerOption that sends the given
+// metadata once per plugin process, instead of with every call.
+//
+// This only applies to ServeRunners created with NewExecServeRunner, where the plugin is
+// long-lived. The plugin merges the metadata into the request metadata of every call, see
+// MetadataFromContext, with metadata given with CallWithMetadata taking precedence. This
+// avoids sending the same metadata, such as credentials, with every call of high-frequency
+// callers. The metadata is sent again whenever the plugin is restarted.
+//
+// The plugin must support session metadata within --serve sessions, which all plugins using
+// a Server from this package of a version that includes this option do. Keys must not be
+// empty or contain "=".
+//
+// The default is to not send session metadata.
+func ExecRunnerWithSessionMetadata(metadata map[string]string) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.sessionMetadata = maps.Clone(metadata)
+	}
+}
+
+// ExecRunnerWithFlowControlWindow returns a new ExecRunnerOption that enables flow control
+// for calls, allowing the plugin to send the given number of bytes of stdout and stderr for
+// each call before the call has consumed them.
+//
+// This only applies to ServeRunners created with NewExecServeRunner. Without flow control,
+// a call with a slow consumer of its stdout blocks all other calls within the session,
+// and the plugin buffers the stdin of calls without bound. With flow control, the plugin
+// waits for the stdout and stderr of a call to be written to the Env of the call before
+// sending more than the window, and the stdin of a call is only sent as fast as the call
+// reads it, up to the window of the plugin, see ServerWithFlowControlWindow.
+//
+// The plugin must support flow control within --serve sessions, which all plugins using a
+// Server from this package of a version that includes this option do. Older plugins are
+// not flow controlled.
+//
+// The default is to not use flow control. A window of zero disables flow control.
+func ExecRunnerWithFlowControlWindow(window uint32) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.flowControlWindow = window
+	}
+}
+
+// ExecRunnerWithTerminationGracePeriod returns a new ExecRunnerOption that specifies how
+// long the command has to exit after it is asked to exit because the context of the call
+// is done, after which it is killed.
+//
+// The command is asked to exit with SIGTERM on unix-like platforms, and with
+// CTRL_BREAK_EVENT on Windows, where the command is started in a new process group.
+// This gives plugins the chance to flush state when a call is canceled. On other
+// platforms, the command is killed right away.
+//
+// The default is 5 seconds. A negative grace period results in the command being
+// killed right away when the context is done.
+func ExecRunnerWithTerminationGracePeriod(gracePeriod time.Duration) ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.terminationGracePeriod = gracePeriod
+	}
+}
+
+// ExecRunnerWithDryRun returns a new ExecRunnerOption that resolves the program and
+// constructs the command for each call, and returns it as a *DryRunError instead of
+// running it.
+//
+// This allows hosts to show users exactly what would be run, for example to implement
+// a --dry-run flag. The command reflects all other options, including ExecRunnerWithCmdOption.
+// Note that a Client invokes the plugin to discover its Spec before the first call, and
+// this invocation fails as well, unless the Spec is given with ClientWithSpec.
+//
+// This only applies to Runners created with NewExecRunner.
+func ExecRunnerWithDryRun() ExecRunnerOption {
+	return func(execRunnerOptions *execRunnerOptions) {
+		execRunnerOptions.dryRun = true
+	}
+}
+
 // NewServerRunner returns a new Runner that directly calls the server.
 //
+// Host services given with ClientWithHostServices are also called directly.
+//
 // This is primarily used for testing.
 func NewServerRunner(server Server, _ ...ServerRunnerOption) Runner {
 	return newServerRunner(server)
@@ -73,9 +263,21 @@ type ServerRunnerOption func(*serverRunnerOptions)
 
 // *** PRIVATE ***
 
+// defaultExecRunnerTerminationGracePeriod is the default time the command has to exit
+// after it is asked to exit because the context is done, after which it is killed.
+//
+// See ExecRunnerWithTerminationGracePeriod.
+const defaultExecRunnerTerminationGracePeriod = 5 * time.Second
+
 type execRunner struct {
-	programName     string
-	programBaseArgs []string
+	programName      string
+	programBaseArgs  []string
+	cmdOptions       []func(*exec.Cmd)
+	env              map[string]string
+	inheritedEnvVars []string
+	// terminationGracePeriod is negative if the command should be killed right away.
+	terminationGracePeriod time.Duration
+	dryRun                 bool
 }
 
 func newExecRunner(programName string, options ...ExecRunnerOption) *execRunner {
@@ -83,37 +285,53 @@ func newExecRunner(programName string, options ...ExecRunnerOption) *execRunner
 	for _, option := range options {
 		option(execRunnerOptions)
 	}
+	terminationGracePeriod := execRunnerOptions.terminationGracePeriod
+	if terminationGracePeriod == 0 {
+		terminationGracePeriod = defaultExecRunnerTerminationGracePeriod
+	}
 	return &execRunner{
-		programName:     programName,
-		programBaseArgs: execRunnerOptions.args,
+		programName:            programName,
+		programBaseArgs:        execRunnerOptions.args,
+		cmdOptions:             execRunnerOptions.cmdOptions,
+		env:                    execRunnerOptions.env,
+		inheritedEnvVars:       execRunnerOptions.inheritedEnvVars,
+		terminationGracePeriod: terminationGracePeriod,
+		dryRun:                 execRunnerOptions.dryRun,
 	}
 }
 
 func (e *execRunner) Run(ctx context.Context, env Env) error {
-	cmd := exec.CommandContext(ctx, e.programName, append(slices.Clone(e.programBaseArgs), env.Args...)...)
-	// We want to make sure the command has access to no env vars, as the default is the current env.
-	cmd.Env = emptyEnv
 	// If the user did not specify various stdio, we want to make sure
 	// the command has access to no stdio.
-	if env.Stdin == nil {
-		cmd.Stdin = discardReader{}
-	} else {
-		cmd.Stdin = env.Stdin
+	env = env.withDefaults()
+	if err := env.Validate(); err != nil {
+		return err
 	}
-	if env.Stdout == nil {
-		cmd.Stdout = io.Discard
-	} else {
-		cmd.Stdout = env.Stdout
+	cmd := e.newCmd(ctx, env)
+	if e.dryRun {
+		return newDryRunError(cmd)
 	}
-	if env.Stderr == nil {
-		cmd.Stderr = io.Discard
-	} else {
-		cmd.Stderr = env.Stderr
+	programPath := cmd.Path
+	prepareScriptCmd(cmd)
+	if hostServices := hostServicesFromContext(ctx); hostServices != nil {
+		stopHostServices, err := startHostServices(ctx, cmd, hostServices)
+		if err != nil {
+			return err
+		}
+		defer stopHostServices()
 	}
-	// The default behavior for dir is what we want already, i.e. the current
-	// working directory.
 
-	if err := cmd.Run(); err != nil {
+	var err error
+	if budget, ok := resourceBudgetFromContext(ctx); ok {
+		err = runWithResourceBudget(cmd, budget)
+	} else {
+		err = cmd.Run()
+	}
+	if err != nil {
+		err = diagnoseScriptError(programPath, cmd.Env, err)
+		if errors.As(err, new(*ExitError)) {
+			return err
+		}
 		exitError := &exec.ExitError{}
 		if errors.As(err, &exitError) {
 			return NewExitError(exitError.ExitCode(), exitError)
@@ -123,6 +341,32 @@ func (e *execRunner) Run(ctx context.Context, env Env) error {
 	return nil
 }
 
+// newCmd returns the configured command for the given Env.
+func (e *execRunner) newCmd(ctx context.Context, env Env) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, e.programName, append(slices.Clone(e.programBaseArgs), env.Args...)...)
+	// We want to make sure the command has access to no env vars other than those explicitly
+	// configured, as the default is the current env.
+	cmd.Env = newCmdEnv(e.env, e.inheritedEnvVars)
+	cmd.Stdin = env.Stdin
+	cmd.Stdout = env.Stdout
+	cmd.Stderr = env.Stderr
+	// The default behavior for dir is what we want already, i.e. the current
+	// working directory.
+	// Ask the command to exit when the context is done instead of killing it right away,
+	// and kill it if it does not exit within the grace period.
+	if e.terminationGracePeriod > 0 {
+		prepareTerminate(cmd)
+		cmd.Cancel = func() error {
+			return terminateProcess(cmd.Process)
+		}
+		cmd.WaitDelay = e.terminationGracePeriod
+	}
+	for _, cmdOption := range e.cmdOptions {
+		cmdOption(cmd)
+	}
+	return cmd
+}
+
 type serverRunner struct {
 	server Server
 	errs   []error
@@ -138,10 +382,43 @@ func (s *serverRunner) Run(ctx context.Context, env Env) error {
 	if len(s.errs) > 0 {
 		return errors.Join(s.errs...)
 	}
+	env = env.withDefaults()
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	// The server only gets the host services of this call, if any.
+	var hostServicesRunner Runner
+	if hostServices := hostServicesFromContext(ctx); hostServices != nil {
+		hostServicesRunner = newServerRunner(hostServices)
+	}
+	ctx = withHostServicesRunner(ctx, hostServicesRunner)
 	// Servers directly return ExitErrors, so this fulfills the contract.
 	return s.server.Serve(ctx, env)
 }
 
+// concurrencyLimitedRunner is a Runner that runs at most a fixed number of commands at once.
+//
+// Waiting commands are run in order of the priority of their calls, see CallWithPriority.
+type concurrencyLimitedRunner struct {
+	runner    Runner
+	semaphore *prioritySemaphore
+}
+
+func newConcurrencyLimitedRunner(runner Runner, maxConcurrency int) *concurrencyLimitedRunner {
+	return &concurrencyLimitedRunner{
+		runner:    runner,
+		semaphore: newPrioritySemaphore(maxConcurrency),
+	}
+}
+
+func (c *concurrencyLimitedRunner) Run(ctx context.Context, env Env) error {
+	if err := c.semaphore.acquire(ctx, callPriorityFromContext(ctx)); err != nil {
+		return err
+	}
+	defer c.semaphore.release()
+	return c.runner.Run(ctx, env)
+}
+
 type discardReader struct{}
 
 func (discardReader) Read([]byte) (int, error) {
@@ -149,7 +426,20 @@ func (discardReader) Read([]byte) (int, error) {
 }
 
 type execRunnerOptions struct {
-	args []string
+	args                 []string
+	cmdOptions           []func(*exec.Cmd)
+	env                  map[string]string
+	inheritedEnvVars     []string
+	heartbeatInterval    time.Duration
+	heartbeatTimeout     time.Duration
+	recycleAfterCalls    int
+	recycleAfterDuration time.Duration
+	recycleAfterRSSBytes uint64
+	sessionMetadata      map[string]string
+	flowControlWindow    uint32
+	// terminationGracePeriod is zero if not set.
+	terminationGracePeriod time.Duration
+	dryRun                 bool
 }
 
 func newExecRunnerOptions() *execRunnerOptions {
@@ -157,3 +447,33 @@ func newExecRunnerOptions() *execRunnerOptions {
 }
 
 type serverRunnerOptions struct{}
+
+// newCmdEnv returns the environment for a command with the given environment variables
+// and the given environment variables inherited from the current process.
+//
+// If there are no environment variables, emptyEnv is returned, as a nil or empty
+// environment results in the command inheriting the current environment.
+func newCmdEnv(env map[string]string, inheritedEnvVars []string) []string {
+	keyToValue := make(map[string]string, len(env)+len(inheritedEnvVars))
+	for _, key := range inheritedEnvVars {
+		if value, ok := os.LookupEnv(key); ok {
+			keyToValue[key] = value
+		}
+	}
+	for key, value := range env {
+		keyToValue[key] = value
+	}
+	if len(keyToValue) == 0 {
+		return emptyEnv
+	}
+	keys := make([]string, 0, len(keyToValue))
+	for key := range keyToValue {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmdEnv := make([]string, len(keys))
+	for i, key := range keys {
+		cmdEnv[i] = key + "=" + keyToValue[key]
+	}
+	return cmdEnv
+}
@@ -0,0 +1,144 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRunnerEnv(t *testing.T) { //nolint:paralleltest // t.Setenv cannot be used with t.Parallel
+	envProgramPath, err := exec.LookPath("env")
+	if err != nil {
+		t.Skip("env program not found")
+	}
+	t.Setenv("PLUGINRPC_TEST_INHERITED", "inherited")
+	t.Setenv("PLUGINRPC_TEST_OVERRIDDEN", "inherited")
+	t.Setenv("PLUGINRPC_TEST_NOT_INHERITED", "inherited")
+
+	runEnv := func(options ...ExecRunnerOption) []string {
+		stdout := bytes.NewBuffer(nil)
+		require.NoError(
+			t,
+			NewExecRunner(envProgramPath, options...).Run(
+				context.Background(),
+				Env{Stdout: stdout},
+			),
+		)
+		return strings.Fields(stdout.String())
+	}
+
+	require.Equal(t, emptyEnv, runEnv())
+	require.Equal(
+		t,
+		[]string{
+			"PLUGINRPC_TEST_INHERITED=inherited",
+			"PLUGINRPC_TEST_OVERRIDDEN=explicit",
+			"PLUGINRPC_TEST_SET=explicit",
+		},
+		runEnv(
+			ExecRunnerWithInheritedEnvVars("PLUGINRPC_TEST_INHERITED", "PLUGINRPC_TEST_UNSET"),
+			ExecRunnerWithInheritedEnvVars("PLUGINRPC_TEST_OVERRIDDEN"),
+			ExecRunnerWithEnv(map[string]string{"PLUGINRPC_TEST_OVERRIDDEN": "explicit"}),
+			ExecRunnerWithEnv(map[string]string{"PLUGINRPC_TEST_SET": "explicit"}),
+		),
+	)
+}
+
+func TestExecRunnerResourceBudget(t *testing.T) {
+	t.Parallel()
+
+	shProgramPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh program not found")
+	}
+	runScript := func(script string, budget resourceBudget) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		return NewExecRunner(shProgramPath, ExecRunnerWithArgs("-c", script)).Run(
+			withResourceBudget(ctx, budget),
+			Env{},
+		)
+	}
+	requireResourceExhausted := func(err error) {
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, CodeResourceExhausted, pluginrpcError.Code())
+	}
+
+	require.NoError(t, runScript("exit 0", resourceBudget{maxCPUTime: time.Minute, maxMemoryBytes: 1 << 30}))
+	requireResourceExhausted(runScript("while :; do :; done", resourceBudget{maxCPUTime: 100 * time.Millisecond}))
+	if runtime.GOOS == "linux" {
+		requireResourceExhausted(
+			runScript(
+				`x=$(head -c 67108864 /dev/zero | tr '\0' a); sleep 60`,
+				resourceBudget{maxMemoryBytes: 16 << 20},
+			),
+		)
+	}
+}
+
+func TestExecRunnerTerminate(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sh scripts cannot trap signals on windows")
+	}
+	shProgramPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh program not found")
+	}
+	runScript := func(script string, options ...ExecRunnerOption) (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		stdout := bytes.NewBuffer(nil)
+		err := NewExecRunner(
+			shProgramPath,
+			append([]ExecRunnerOption{ExecRunnerWithArgs("-c", script)}, options...)...,
+		).Run(ctx, Env{Stdout: stdout})
+		return stdout.String(), err
+	}
+	exitError := &ExitError{}
+
+	// The plugin is asked to exit.
+	output, err := runScript(`trap 'kill $!; echo terminated; exit 3' TERM; sleep 60 & wait`)
+	require.ErrorAs(t, err, &exitError)
+	require.Equal(t, 3, exitError.ExitCode())
+	require.Equal(t, "terminated\n", output)
+
+	// The plugin is killed if it does not exit within the grace period.
+	start := time.Now()
+	_, err = runScript(
+		`trap '' TERM; while :; do sleep 0.01; done`,
+		ExecRunnerWithTerminationGracePeriod(100*time.Millisecond),
+	)
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// The plugin is killed right away if the grace period is negative.
+	output, err = runScript(
+		`trap 'echo terminated; exit 3' TERM; while :; do sleep 0.01; done`,
+		ExecRunnerWithTerminationGracePeriod(-1),
+	)
+	require.Error(t, err)
+	require.Empty(t, output)
+}
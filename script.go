@@ -0,0 +1,205 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// *** PRIVATE ***
+
+const (
+	// maxShebangLineLength is the maximum length of a shebang line that is read
+	// to diagnose scripts.
+	maxShebangLineLength = 256
+	// exitCodeCommandNotFound is the exit code of shells and env(1) when a command
+	// is not found.
+	exitCodeCommandNotFound = 127
+)
+
+// batchSpecialChars are the characters that result in args being quoted in the command
+// line of batch files, as they are interpreted by cmd.exe otherwise.
+const batchSpecialChars = "\t &()[]{}^=;!'+,`~%|<>\""
+
+// diagnoseScriptError returns an error that explains why the program could not be run
+// if the program is a script that cannot be run, for example because its interpreter
+// does not exist, and the error otherwise.
+//
+// The program path is the path of the program as resolved by os/exec. The env is the
+// environment of the command.
+func diagnoseScriptError(programPath string, env []string, err error) error {
+	if err == nil || programPath == "" {
+		return err
+	}
+	exitError := &exec.ExitError{}
+	if errors.As(err, &exitError) {
+		if exitError.ExitCode() != exitCodeCommandNotFound {
+			return err
+		}
+		interpreter, _, ok := readShebang(programPath)
+		if !ok || filepath.Base(interpreter) != "env" || hasEnvVar(env, "PATH") {
+			return err
+		}
+		return NewExitError(
+			exitCodeCommandNotFound,
+			fmt.Errorf(
+				"script %q may have failed as it uses %s to find its interpreter, but PATH is not set, see ExecRunnerWithInheritedEnvVars: %w",
+				programPath,
+				interpreter,
+				exitError,
+			),
+		)
+	}
+	// Errors from starting the process are *fs.PathErrors with the op "fork/exec" on all platforms.
+	pathError := &fs.PathError{}
+	if !errors.As(err, &pathError) || pathError.Op != "fork/exec" {
+		return err
+	}
+	interpreter, line, ok := readShebang(programPath)
+	if !ok {
+		if isScript(programPath) {
+			return fmt.Errorf("program %q is not an executable binary and does not start with a shebang line such as #!/bin/sh: %w", programPath, err)
+		}
+		return err
+	}
+	if strings.HasSuffix(line, "\r") {
+		return fmt.Errorf("script %q has a shebang line ending in a carriage return, convert the script to LF line endings: %w", programPath, err)
+	}
+	if interpreter == "" {
+		return fmt.Errorf("script %q has a shebang line without an interpreter: %w", programPath, err)
+	}
+	if filepath.IsAbs(interpreter) {
+		if _, statErr := os.Stat(interpreter); statErr != nil {
+			return fmt.Errorf("interpreter %q of script %q does not exist: %w", interpreter, programPath, err)
+		}
+	}
+	return fmt.Errorf("script %q with interpreter %q could not be run: %w", programPath, interpreter, err)
+}
+
+// readShebang returns the interpreter and the shebang line of the script, without
+// the leading #! and the trailing newline.
+//
+// Returns false if the program could not be read or does not start with a shebang line.
+func readShebang(programPath string) (string, string, bool) {
+	file, err := os.Open(programPath)
+	if err != nil {
+		return "", "", false
+	}
+	defer func() { _ = file.Close() }()
+	line, err := bufio.NewReaderSize(file, maxShebangLineLength).ReadSlice('\n')
+	if err != nil && len(line) == 0 {
+		return "", "", false
+	}
+	if !bytes.HasPrefix(line, []byte("#!")) {
+		return "", "", false
+	}
+	shebang := strings.TrimSuffix(string(line[2:]), "\n")
+	fields := strings.Fields(shebang)
+	if len(fields) == 0 {
+		return "", shebang, true
+	}
+	return fields[0], shebang, true
+}
+
+// isScript returns true if the program starts with text rather than the magic number of
+// an executable binary.
+func isScript(programPath string) bool {
+	file, err := os.Open(programPath)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = file.Close() }()
+	data := make([]byte, maxShebangLineLength)
+	n, _ := file.Read(data)
+	return n > 0 && isDebugText(data[:n])
+}
+
+func hasEnvVar(env []string, key string) bool {
+	for _, keyValue := range env {
+		if strings.HasPrefix(keyValue, key+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// isBatchFile returns true if the program is a batch file, which is run by cmd.exe on Windows.
+func isBatchFile(programPath string) bool {
+	switch strings.ToLower(filepath.Ext(programPath)) {
+	case ".bat", ".cmd":
+		return true
+	default:
+		return false
+	}
+}
+
+// batchCommandLine returns the command line for cmd.exe to run the batch file with the args.
+//
+// cmd.exe does not parse args like other programs, and interprets special characters
+// in args unless they are quoted, and variables even if they are quoted, so args are
+// quoted and escaped for cmd.exe. Returns an error if an arg cannot be escaped.
+func batchCommandLine(programPath string, args []string) (string, error) {
+	var sb strings.Builder
+	_, _ = sb.WriteString(`/e:ON /v:OFF /d /c "`)
+	for i, arg := range append([]string{programPath}, args...) {
+		if i > 0 {
+			_, _ = sb.WriteString(" ")
+		}
+		if strings.ContainsAny(arg, "\x00\r\n") {
+			return "", fmt.Errorf("arg %q cannot be passed to batch file %q", arg, programPath)
+		}
+		quote := arg == "" || strings.ContainsAny(arg, batchSpecialChars) || strings.ContainsFunc(arg, isNonASCIIRune)
+		if quote {
+			_, _ = sb.WriteString(`"`)
+		}
+		backslashes := 0
+		for _, r := range arg {
+			switch r {
+			case '\\':
+				backslashes++
+			case '"':
+				// Backslashes before a quote are escaped, and the quote is escaped by doubling it.
+				_, _ = sb.WriteString(strings.Repeat(`\`, backslashes))
+				_, _ = sb.WriteString(`"`)
+				backslashes = 0
+			case '%':
+				// Expands to the empty string followed by %, which prevents variable expansion.
+				_, _ = sb.WriteString("%%cd:~,")
+				backslashes = 0
+			default:
+				backslashes = 0
+			}
+			_, _ = sb.WriteRune(r)
+		}
+		if quote {
+			_, _ = sb.WriteString(strings.Repeat(`\`, backslashes))
+			_, _ = sb.WriteString(`"`)
+		}
+	}
+	_, _ = sb.WriteString(`"`)
+	return sb.String(), nil
+}
+
+func isNonASCIIRune(r rune) bool {
+	return r > 0x7f
+}
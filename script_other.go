@@ -12,14 +12,13 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build windows
+//go:build !windows
 
 package pluginrpc
 
-import "os"
+import "os/exec"
 
-// extraInterruptSignals are signals beyond os.Interrupt that we want to be handled
-// as interrupts.
+// prepareScriptCmd configures the command to run scripts.
 //
-// For unix-like platforms, this adds syscall.SIGTERM.
-var extraInterruptSignals = []os.Signal{}
+// Scripts are run by the kernel according to their shebang line on this platform.
+func prepareScriptCmd(*exec.Cmd) {}
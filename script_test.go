@@ -0,0 +1,98 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRunnerScript(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("shebang lines are not supported on windows")
+	}
+	shPath, err := exec.LookPath("sh")
+	require.NoError(t, err)
+	dirPath := t.TempDir()
+
+	runner := NewExecRunner(writeScript(t, dirPath, "ok", "#!"+shPath+"\nexit 0\n"))
+	require.NoError(t, runner.Run(context.Background(), Env{}))
+
+	runner = NewExecRunner(writeScript(t, dirPath, "crlf", "#!"+shPath+"\r\nexit 0\r\n"))
+	require.ErrorContains(t, runner.Run(context.Background(), Env{}), "carriage return")
+
+	runner = NewExecRunner(writeScript(t, dirPath, "missing", "#!/does/not/exist\nexit 0\n"))
+	require.ErrorContains(t, runner.Run(context.Background(), Env{}), `interpreter "/does/not/exist"`)
+
+	runner = NewExecRunner(writeScript(t, dirPath, "no-shebang", "exit 0\n"))
+	require.ErrorContains(t, runner.Run(context.Background(), Env{}), "does not start with a shebang line")
+
+	runner = NewExecServeRunner(writeScript(t, dirPath, "serve-crlf", "#!"+shPath+"\r\nexit 0\r\n"))
+	t.Cleanup(func() { require.NoError(t, runner.(*execServeRunner).Close()) })
+	require.ErrorContains(t, runner.Run(context.Background(), Env{}), "carriage return")
+}
+
+func TestDiagnoseScriptErrorEnv(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("shebang lines are not supported on windows")
+	}
+	shPath, err := exec.LookPath("sh")
+	require.NoError(t, err)
+	programPath := writeScript(t, t.TempDir(), "env", "#!/usr/bin/env does-not-exist\n")
+	exitErr := exec.Command(shPath, "-c", "exit 127").Run()
+	require.Error(t, exitErr)
+
+	err = diagnoseScriptError(programPath, emptyEnv, exitErr)
+	require.ErrorContains(t, err, "PATH is not set")
+	exitError := &ExitError{}
+	require.ErrorAs(t, err, &exitError)
+	require.Equal(t, 127, exitError.ExitCode())
+	require.Equal(t, exitErr, diagnoseScriptError(programPath, []string{"PATH=/bin"}, exitErr))
+}
+
+func TestBatchCommandLine(t *testing.T) {
+	t.Parallel()
+
+	commandLine, err := batchCommandLine(`C:\plugins\foo.bat`, []string{"echo", "request", "--format", "json"})
+	require.NoError(t, err)
+	require.Equal(t, `/e:ON /v:OFF /d /c "C:\plugins\foo.bat echo request --format json"`, commandLine)
+	commandLine, err = batchCommandLine(`C:\my plugins\foo.cmd`, []string{"", "a&b", `say "hi"`, "100%", `C:\my dir\`})
+	require.NoError(t, err)
+	require.Equal(
+		t,
+		`/e:ON /v:OFF /d /c ""C:\my plugins\foo.cmd" "" "a&b" "say ""hi""" "100%%cd:~,%" "C:\my dir\\""`,
+		commandLine,
+	)
+	_, err = batchCommandLine(`C:\plugins\foo.bat`, []string{"foo\nbar"})
+	require.Error(t, err)
+	require.True(t, isBatchFile(`C:\plugins\FOO.CMD`))
+	require.False(t, isBatchFile(`C:\plugins\foo.exe`))
+}
+
+func writeScript(t *testing.T, dirPath string, name string, content string) string {
+	programPath := filepath.Join(dirPath, name)
+	require.NoError(t, os.WriteFile(programPath, []byte(content), 0o700))
+	return programPath
+}
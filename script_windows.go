@@ -0,0 +1,49 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package pluginrpc
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// prepareScriptCmd configures the command to run batch files with cmd.exe, with args
+// escaped for cmd.exe.
+//
+// CreateProcess runs batch files with cmd.exe implicitly, however args are then escaped
+// for regular programs, which cmd.exe does not parse the same way.
+func prepareScriptCmd(cmd *exec.Cmd) {
+	if cmd.Err != nil || !isBatchFile(cmd.Path) {
+		return
+	}
+	commandLine, err := batchCommandLine(cmd.Path, cmd.Args[1:])
+	if err != nil {
+		cmd.Err = err
+		return
+	}
+	comSpec := os.Getenv("ComSpec")
+	if comSpec == "" {
+		comSpec = filepath.Join(os.Getenv("SystemRoot"), "System32", "cmd.exe")
+	}
+	cmd.Path = comSpec
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = syscall.EscapeArg(comSpec) + " " + commandLine
+}
@@ -0,0 +1,1046 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+// ServeRunner is a Runner that runs a plugin as a single long-lived process,
+// multiplexing calls over the stdin and stdout of the process.
+//
+// This avoids a process spawn per call for high-frequency callers. Each call is
+// equivalent to a single invocation of the plugin, and calls may run concurrently.
+type ServeRunner interface {
+	Runner
+
+	// Close stops the plugin.
+	//
+	// In-flight calls are allowed to complete. No calls can be made after Close is called.
+	Close() error
+
+	isServeRunner()
+}
+
+// NewExecServeRunner returns a new ServeRunner that uses os/exec to run the external
+// command given by the program name with the --serve flag.
+//
+// The plugin is started on the first call. If the plugin exits, in-flight calls fail,
+// and the plugin is restarted on the next call.
+//
+// The plugin must support the --serve flag, which all plugins using a Server from this
+// package of a version that includes NewExecServeRunner do. The stderr of the plugin that
+// is not associated with a call is discarded.
+func NewExecServeRunner(programName string, options ...ExecRunnerOption) ServeRunner {
+	return newExecServeRunner(programName, options...)
+}
+
+// SessionCallStats are statistics for a single call within a session started with --serve.
+//
+// See ServerWithSessionCallObserver.
+type SessionCallStats struct {
+	// Args are the args of the call.
+	Args []string
+	// QueueDuration is the time the call spent waiting for a slot, see ServerWithSessionConcurrency.
+	QueueDuration time.Duration
+	// Duration is the time spent handling the call, excluding QueueDuration.
+	Duration time.Duration
+	// FlowControlWaitDuration is the time the call spent waiting for the client to consume
+	// its stdout and stderr, see ExecRunnerWithFlowControlWindow.
+	//
+	// This is included in Duration.
+	FlowControlWaitDuration time.Duration
+	// Err is the error the call exited with, if any, including recovered panics.
+	//
+	// Errors returned to the client in responses are not included.
+	Err error
+}
+
+// *** PRIVATE ***
+
+type execServeRunner struct {
+	programName          string
+	programBaseArgs      []string
+	cmdOptions           []func(*exec.Cmd)
+	env                  map[string]string
+	inheritedEnvVars     []string
+	heartbeatInterval    time.Duration
+	heartbeatTimeout     time.Duration
+	recycleAfterCalls    int
+	recycleAfterDuration time.Duration
+	recycleAfterRSSBytes uint64
+	sessionMetadata      map[string]string
+	flowControlWindow    uint32
+
+	session *execServeSession
+	closed  bool
+	lock    sync.Mutex
+}
+
+func newExecServeRunner(programName string, options ...ExecRunnerOption) *execServeRunner {
+	execRunnerOptions := newExecRunnerOptions()
+	for _, option := range options {
+		option(execRunnerOptions)
+	}
+	return &execServeRunner{
+		programName:          programName,
+		programBaseArgs:      execRunnerOptions.args,
+		cmdOptions:           execRunnerOptions.cmdOptions,
+		env:                  execRunnerOptions.env,
+		inheritedEnvVars:     execRunnerOptions.inheritedEnvVars,
+		heartbeatInterval:    execRunnerOptions.heartbeatInterval,
+		heartbeatTimeout:     execRunnerOptions.heartbeatTimeout,
+		recycleAfterCalls:    execRunnerOptions.recycleAfterCalls,
+		recycleAfterDuration: execRunnerOptions.recycleAfterDuration,
+		recycleAfterRSSBytes: execRunnerOptions.recycleAfterRSSBytes,
+		sessionMetadata:      execRunnerOptions.sessionMetadata,
+		flowControlWindow:    execRunnerOptions.flowControlWindow,
+	}
+}
+
+func (e *execServeRunner) Run(ctx context.Context, env Env) error {
+	env = env.withDefaults()
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	session, err := e.getSession()
+	if err != nil {
+		return err
+	}
+	defer session.activeCalls.Done()
+	return session.run(ctx, env)
+}
+
+func (e *execServeRunner) Close() error {
+	e.lock.Lock()
+	e.closed = true
+	session := e.session
+	e.lock.Unlock()
+	if session == nil {
+		return nil
+	}
+	return session.close()
+}
+
+func (*execServeRunner) isServeRunner() {}
+
+// getSession returns the current session, starting the plugin if it is not running.
+func (e *execServeRunner) getSession() (*execServeSession, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.closed {
+		return nil, errors.New("ServeRunner is closed")
+	}
+	for key := range e.sessionMetadata {
+		if err := validateMetadataKey(key); err != nil {
+			return nil, fmt.Errorf("invalid session metadata: %w", err)
+		}
+	}
+	if e.session != nil && !e.session.isDone() {
+		if !e.shouldRecycle(e.session) {
+			e.session.calls++
+			e.session.activeCalls.Add(1)
+			return e.session, nil
+		}
+		go e.session.retire()
+	}
+	cmd := exec.Command(e.programName, append(slices.Clone(e.programBaseArgs), "--"+ServeFlagName)...)
+	// We want to make sure the command has access to no env vars other than those explicitly
+	// configured, as the default is the current env.
+	cmd.Env = newCmdEnv(e.env, e.inheritedEnvVars)
+	for _, cmdOption := range e.cmdOptions {
+		cmdOption(cmd)
+	}
+	programPath := cmd.Path
+	prepareScriptCmd(cmd)
+	session, err := newExecServeSession(cmd, e.flowControlWindow)
+	if err != nil {
+		return nil, diagnoseScriptError(programPath, cmd.Env, err)
+	}
+	if len(e.sessionMetadata) > 0 {
+		if err := session.write(&extv1.ServeRequest{SessionMetadata: e.sessionMetadata}); err != nil {
+			return nil, errors.Join(err, session.close())
+		}
+	}
+	if e.flowControlWindow > 0 {
+		if err := session.write(&extv1.ServeRequest{FlowControlWindow: e.flowControlWindow}); err != nil {
+			return nil, errors.Join(err, session.close())
+		}
+	}
+	if e.heartbeatInterval > 0 && e.heartbeatTimeout > 0 {
+		go session.heartbeat(e.heartbeatInterval, e.heartbeatTimeout)
+	}
+	e.session = session
+	session.calls++
+	session.activeCalls.Add(1)
+	return session, nil
+}
+
+// shouldRecycle returns true if the session should be replaced by a new session
+// according to the recycling policies of the runner. Must be called with the lock held.
+func (e *execServeRunner) shouldRecycle(session *execServeSession) bool {
+	if e.recycleAfterCalls > 0 && session.calls >= e.recycleAfterCalls {
+		return true
+	}
+	if e.recycleAfterDuration > 0 && time.Since(session.startTime) >= e.recycleAfterDuration {
+		return true
+	}
+	if e.recycleAfterRSSBytes > 0 {
+		if rssBytes, ok := processRSSBytes(session.cmd.Process.Pid); ok && rssBytes > e.recycleAfterRSSBytes {
+			return true
+		}
+	}
+	return false
+}
+
+// execServeSession is the client side of a session with a plugin started with --serve.
+type execServeSession struct {
+	// cmd is nil if the session is with the host services of the host, see newHostServicesRunner.
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	// doneC is closed when the plugin has exited.
+	doneC chan struct{}
+	// err is the error that calls fail with once the plugin has exited.
+	//
+	// This can only be read after doneC is closed.
+	err error
+	// waitErr is the error that waiting for the plugin resulted in, if any.
+	//
+	// This can only be read after doneC is closed.
+	waitErr error
+	// lastReceivedUnixNano is the time the last frame was received from the plugin.
+	lastReceivedUnixNano atomic.Int64
+	// heartbeatErr is set before the plugin is killed for not responding to heartbeats.
+	heartbeatErr atomic.Pointer[error]
+	startTime    time.Time
+	// calls is the number of calls started on the session, guarded by the lock of the runner.
+	calls int
+	// activeCalls tracks the calls in flight, so that a retired session can exit once they complete.
+	activeCalls sync.WaitGroup
+	// flowControlWindow is the window of the client for the stdout and stderr of each call,
+	// or zero if flow control is disabled.
+	flowControlWindow uint32
+	// peerFlowControlWindow is the window of the plugin for the stdin of each call, or zero
+	// if the plugin has not acknowledged flow control yet.
+	peerFlowControlWindow atomic.Uint32
+
+	nextID   uint64
+	idToCall map[uint64]*execServeCall
+	lock     sync.Mutex
+	// writeLock guards writes to stdin.
+	writeLock sync.Mutex
+}
+
+type execServeCall struct {
+	stdout io.Writer
+	stderr io.Writer
+	// doneC is closed when the call is done.
+	doneC chan struct{}
+	// exitCode and writeErr can only be read after doneC is closed.
+	exitCode uint32
+	writeErr error
+	// sendWindow is the window of the plugin for the stdin of the call, if flow controlled.
+	sendWindow *flowControlWindow
+	// output is the stdout and stderr received for the call that has not been written
+	// yet, if flow controlled.
+	output *execServeCallOutput
+	// stopped is set once the call returns, after which nothing is written.
+	stopped bool
+	// writeLock guards writes to stdout and stderr.
+	writeLock sync.Mutex
+}
+
+// writeResponse writes the stdout and stderr of the ServeResponse, and completes the call
+// if it is done.
+//
+// This does nothing once the call is stopped.
+func (c *execServeCall) writeResponse(serveResponse *extv1.ServeResponse) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.stopped {
+		return
+	}
+	if c.writeErr == nil && len(serveResponse.GetStdout()) > 0 {
+		_, c.writeErr = c.stdout.Write(serveResponse.GetStdout())
+	}
+	if c.writeErr == nil && len(serveResponse.GetStderr()) > 0 {
+		_, c.writeErr = c.stderr.Write(serveResponse.GetStderr())
+	}
+	if serveResponse.GetDone() {
+		c.exitCode = serveResponse.GetExitCode()
+		close(c.doneC)
+	}
+}
+
+// stop makes sure that nothing is written to the stdout and stderr of the call once it
+// returns, waiting for any write in progress.
+func (c *execServeCall) stop() {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	c.stopped = true
+}
+
+func newExecServeSession(cmd *exec.Cmd, flowControlWindow uint32) (*execServeSession, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return startExecServeSession(cmd, stdin, stdout, flowControlWindow), nil
+}
+
+// startExecServeSession starts a session over the given stdin and stdout of the server.
+//
+// cmd is nil if the server was not started by this process, see newHostServicesRunner.
+func startExecServeSession(
+	cmd *exec.Cmd,
+	stdin io.WriteCloser,
+	stdout io.ReadCloser,
+	flowControlWindow uint32,
+) *execServeSession {
+	session := &execServeSession{
+		cmd:       cmd,
+		stdin:     stdin,
+		stdout:    stdout,
+		startTime: time.Now(),
+		doneC:     make(chan struct{}),
+		idToCall:  make(map[uint64]*execServeCall),
+		// This must be set before reading, as it determines how output is written.
+		flowControlWindow: flowControlWindow,
+	}
+	go session.readAll(stdout)
+	return session
+}
+
+func (s *execServeSession) run(ctx context.Context, env Env) error {
+	call := &execServeCall{
+		stdout: env.Stdout,
+		stderr: env.Stderr,
+		doneC:  make(chan struct{}),
+	}
+	if s.flowControlWindow > 0 {
+		call.output = newExecServeCallOutput()
+	}
+	if peerFlowControlWindow := s.peerFlowControlWindow.Load(); peerFlowControlWindow > 0 {
+		call.sendWindow = newFlowControlWindow(peerFlowControlWindow)
+		// Stop waiting for the window once the call is done.
+		defer call.sendWindow.close()
+	}
+	s.lock.Lock()
+	if s.isDone() {
+		s.lock.Unlock()
+		return s.err
+	}
+	s.nextID++
+	id := s.nextID
+	s.idToCall[id] = call
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.idToCall, id)
+		s.lock.Unlock()
+	}()
+	// The call may be removed from idToCall while its output is being written, so this
+	// is needed in addition to the removal.
+	defer call.stop()
+
+	if err := s.write(&extv1.ServeRequest{Id: id, Args: env.Args, Priority: callPriorityFromContext(ctx)}); err != nil {
+		return err
+	}
+	if call.output != nil {
+		outputDoneC := make(chan struct{})
+		go func() {
+			defer close(outputDoneC)
+			s.writeOutput(id, call)
+		}()
+		// Make sure that nothing is written to the Env after the call returns.
+		defer func() {
+			call.output.stop()
+			<-outputDoneC
+		}()
+	}
+	go s.copyStdin(id, env.Stdin, call)
+	select {
+	case <-call.doneC:
+		if call.writeErr != nil {
+			return call.writeErr
+		}
+		if call.exitCode != 0 {
+			return NewExitError(int(call.exitCode), fmt.Errorf("exit status %d", call.exitCode))
+		}
+		return nil
+	case <-s.doneC:
+		return s.err
+	case <-ctx.Done():
+		// The plugin will cancel the context of the call.
+		_ = s.write(&extv1.ServeRequest{Id: id, Cancel: true})
+		return ctx.Err()
+	}
+}
+
+// copyStdin sends the stdin of a call to the plugin until stdin ends or the call is done.
+func (s *execServeSession) copyStdin(id uint64, stdin io.Reader, call *execServeCall) {
+	chunk := make([]byte, stdinReadChunkSize)
+	for {
+		n, err := stdin.Read(chunk)
+		select {
+		case <-call.doneC:
+			return
+		default:
+		}
+		for data := chunk[:n]; len(data) > 0; {
+			sendSize := len(data)
+			if call.sendWindow != nil {
+				// The window is closed once the call returns, so this does not block forever.
+				sendSize, _ = call.sendWindow.acquire(context.Background(), sendSize)
+			}
+			if err := s.write(&extv1.ServeRequest{Id: id, Stdin: data[:sendSize]}); err != nil {
+				return
+			}
+			data = data[sendSize:]
+		}
+		if err != nil {
+			_ = s.write(&extv1.ServeRequest{Id: id, CloseStdin: true})
+			return
+		}
+	}
+}
+
+func (s *execServeSession) readAll(stdout io.Reader) {
+	defer close(s.doneC)
+	var readErr error
+	for {
+		data, err := readFrame(stdout, maxFrameSize)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+		s.lastReceivedUnixNano.Store(time.Now().UnixNano())
+		serveResponse := &extv1.ServeResponse{}
+		if err := proto.Unmarshal(data, serveResponse); err != nil {
+			readErr = err
+			break
+		}
+		if serveResponse.GetPong() != 0 {
+			continue
+		}
+		if flowControlWindow := serveResponse.GetFlowControlWindow(); flowControlWindow != 0 {
+			s.peerFlowControlWindow.Store(flowControlWindow)
+			continue
+		}
+		if windowUpdate := serveResponse.GetWindowUpdate(); windowUpdate != 0 {
+			s.lock.Lock()
+			call := s.idToCall[serveResponse.GetId()]
+			s.lock.Unlock()
+			if call != nil && call.sendWindow != nil {
+				call.sendWindow.release(windowUpdate)
+			}
+			continue
+		}
+		s.lock.Lock()
+		call := s.idToCall[serveResponse.GetId()]
+		if serveResponse.GetDone() {
+			delete(s.idToCall, serveResponse.GetId())
+		}
+		s.lock.Unlock()
+		if call == nil {
+			// The call was cancelled.
+			continue
+		}
+		if call.output != nil {
+			// The output is written separately, so that a slow consumer of the output of
+			// the call does not block other calls.
+			call.output.enqueue(serveResponse, s.flowControlWindow)
+			continue
+		}
+		call.writeResponse(serveResponse)
+	}
+	if s.cmd == nil {
+		s.err = errors.New("host services closed during call")
+		if readErr != nil {
+			s.err = fmt.Errorf("invalid output from host services: %w", readErr)
+		}
+		return
+	}
+	if readErr != nil {
+		// Make sure the plugin exits so that we can wait on it.
+		_ = s.cmd.Process.Kill()
+	}
+	s.waitErr = s.cmd.Wait()
+	s.err = errors.New("plugin exited during call")
+	if heartbeatErr := s.heartbeatErr.Load(); heartbeatErr != nil {
+		s.err = *heartbeatErr
+	} else if readErr != nil {
+		s.err = fmt.Errorf("invalid output from plugin: %w", readErr)
+	} else if s.waitErr != nil {
+		s.err = fmt.Errorf("plugin exited during call: %w", s.waitErr)
+	}
+}
+
+// writeOutput writes the stdout and stderr received for a flow controlled call until the
+// call is done, granting the plugin more bytes as they are written.
+func (s *execServeSession) writeOutput(id uint64, call *execServeCall) {
+	windowUpdater := newWindowUpdater(
+		s.flowControlWindow,
+		func(windowUpdate uint32) {
+			_ = s.write(&extv1.ServeRequest{Id: id, WindowUpdate: windowUpdate})
+		},
+	)
+	for {
+		serveResponse, ok := call.output.next()
+		if !ok {
+			return
+		}
+		call.writeResponse(serveResponse)
+		if serveResponse.GetDone() {
+			return
+		}
+		windowUpdater.consumed(len(serveResponse.GetStdout()) + len(serveResponse.GetStderr()))
+	}
+}
+
+// heartbeat pings the plugin at the interval until the plugin exits, and kills the plugin
+// if nothing is received from the plugin within the timeout of a ping.
+//
+// Any frame counts as a response, so that a plugin that is busy writing responses is not
+// considered hung.
+//
+// Pings are written separately, as writes block once a plugin that stopped reading stdin
+// has filled the pipe, and such a plugin must still be killed.
+func (s *execServeSession) heartbeat(interval time.Duration, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pingC := make(chan uint64, 1)
+	defer close(pingC)
+	go func() {
+		for seq := range pingC {
+			if err := s.write(&extv1.ServeRequest{Ping: seq}); err != nil {
+				// The plugin has exited or is exiting.
+				return
+			}
+		}
+	}()
+	var seq uint64
+	// pendingSince is the time the oldest unanswered ping was sent, or zero.
+	var pendingSince time.Time
+	for {
+		select {
+		case <-s.doneC:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		if !pendingSince.IsZero() {
+			if s.lastReceivedUnixNano.Load() >= pendingSince.UnixNano() {
+				pendingSince = time.Time{}
+			} else if now.Sub(pendingSince) >= timeout {
+				heartbeatErr := fmt.Errorf("plugin did not respond to heartbeat within %v", timeout)
+				s.heartbeatErr.Store(&heartbeatErr)
+				_ = s.cmd.Process.Kill()
+				// Unblock reading and writing in case a child process of the plugin holds
+				// stdout or stdin open.
+				_ = s.stdout.Close()
+				_ = s.stdin.Close()
+				return
+			}
+		}
+		seq++
+		select {
+		case pingC <- seq:
+		default:
+			// The previous ping is still being written, which counts as unanswered.
+		}
+		if pendingSince.IsZero() {
+			pendingSince = now
+		}
+	}
+}
+
+func (s *execServeSession) write(serveRequest *extv1.ServeRequest) error {
+	data, err := proto.Marshal(serveRequest)
+	if err != nil {
+		return err
+	}
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return writeFrame(s.stdin, data)
+}
+
+// close closes stdin of the plugin, and waits for the plugin to exit.
+func (s *execServeSession) close() error {
+	s.writeLock.Lock()
+	err := s.stdin.Close()
+	s.writeLock.Unlock()
+	<-s.doneC
+	if s.waitErr != nil {
+		return s.waitErr
+	}
+	return err
+}
+
+// retire closes the session once all calls in flight have completed.
+//
+// The session must no longer be used for new calls.
+func (s *execServeSession) retire() {
+	s.activeCalls.Wait()
+	_ = s.close()
+}
+
+func (s *execServeSession) isDone() bool {
+	select {
+	case <-s.doneC:
+		return true
+	default:
+		return false
+	}
+}
+
+// execServeCallOutput is a queue of the ServeResponses received for a flow controlled call.
+type execServeCallOutput struct {
+	serveResponses []*extv1.ServeResponse
+	// size is the number of bytes of stdout and stderr in the queue.
+	size    int
+	stopped bool
+	lock    sync.Mutex
+	cond    *sync.Cond
+}
+
+func newExecServeCallOutput() *execServeCallOutput {
+	execServeCallOutput := &execServeCallOutput{}
+	execServeCallOutput.cond = sync.NewCond(&execServeCallOutput.lock)
+	return execServeCallOutput
+}
+
+// enqueue adds the ServeResponse to the queue.
+//
+// A plugin that respects the window never has more than the window in the queue. If a plugin
+// sends more, enqueue waits until the queue has room, applying backpressure to the session.
+func (e *execServeCallOutput) enqueue(serveResponse *extv1.ServeResponse, window uint32) {
+	size := len(serveResponse.GetStdout()) + len(serveResponse.GetStderr())
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for e.size > 0 && e.size+size > int(window) && !e.stopped {
+		e.cond.Wait()
+	}
+	if e.stopped {
+		return
+	}
+	e.serveResponses = append(e.serveResponses, serveResponse)
+	e.size += size
+	e.cond.Broadcast()
+}
+
+// next waits for and removes the next ServeResponse from the queue.
+//
+// Returns false if the queue was stopped.
+func (e *execServeCallOutput) next() (*extv1.ServeResponse, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for len(e.serveResponses) == 0 && !e.stopped {
+		e.cond.Wait()
+	}
+	if e.stopped {
+		return nil, false
+	}
+	serveResponse := e.serveResponses[0]
+	e.serveResponses = e.serveResponses[1:]
+	e.size -= len(serveResponse.GetStdout()) + len(serveResponse.GetStderr())
+	e.cond.Broadcast()
+	return serveResponse, true
+}
+
+// stop discards the queue, and stops waiting for ServeResponses.
+func (e *execServeCallOutput) stop() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.serveResponses = nil
+	e.stopped = true
+	e.cond.Broadcast()
+}
+
+// serveSession runs a session for a Server started with --serve, until stdin is closed.
+//
+// Each call within the session is served concurrently, as if the plugin was invoked
+// with the args of the call.
+func (s *server) serveSession(ctx context.Context, env Env) (retErr error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if s.procedureTimingsWriter != nil {
+		procedureTimings := newProcedureTimings()
+		ctx = withProcedureTimings(ctx, procedureTimings)
+		defer func() {
+			retErr = errors.Join(retErr, procedureTimings.writeTo(s.procedureTimingsWriter))
+		}()
+	}
+	session := &serveServerSession{
+		server:   s,
+		stdout:   env.Stdout,
+		idToCall: make(map[uint64]*serveServerCall),
+	}
+	if s.sessionConcurrency > 0 {
+		session.semaphore = newPrioritySemaphore(s.sessionConcurrency)
+	}
+	frameReader := newFrameReader(env.Stdin, maxFrameSize)
+	defer frameReader.close()
+	var err error
+	for {
+		data, readErr := frameReader.next(ctx)
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) {
+				err = readErr
+			}
+			break
+		}
+		serveRequest := &extv1.ServeRequest{}
+		if err = proto.Unmarshal(data, serveRequest); err != nil {
+			break
+		}
+		if ping := serveRequest.GetPing(); ping != 0 {
+			if err = session.write(&extv1.ServeResponse{Pong: ping}); err != nil {
+				break
+			}
+			continue
+		}
+		if sessionMetadata := serveRequest.GetSessionMetadata(); len(sessionMetadata) > 0 {
+			// Frames are read in order, so calls started after this frame use the metadata.
+			session.sessionMetadata = sessionMetadata
+			continue
+		}
+		if flowControlWindow := serveRequest.GetFlowControlWindow(); flowControlWindow != 0 {
+			// Frames are read in order, so calls started after this frame are flow controlled.
+			session.flowControlWindow = flowControlWindow
+			if err = session.write(&extv1.ServeResponse{FlowControlWindow: s.flowControlWindow}); err != nil {
+				break
+			}
+			continue
+		}
+		if windowUpdate := serveRequest.GetWindowUpdate(); windowUpdate != 0 {
+			session.updateWindow(serveRequest.GetId(), windowUpdate)
+			continue
+		}
+		session.handle(ctx, serveRequest)
+	}
+	if err != nil {
+		// Abandon in-flight calls.
+		cancel()
+	}
+	session.closeCalls()
+	session.wg.Wait()
+	return err
+}
+
+type serveServerSession struct {
+	server *server
+	stdout io.Writer
+
+	// lastID is the largest id of any call started within the session.
+	lastID   uint64
+	idToCall map[uint64]*serveServerCall
+	lock     sync.Mutex
+	// writeLock guards writes to stdout.
+	writeLock sync.Mutex
+	wg        sync.WaitGroup
+	// semaphore bounds the number of calls handled concurrently, if set.
+	semaphore *prioritySemaphore
+	// sessionMetadata is merged into the request metadata of calls, see ExecRunnerWithSessionMetadata.
+	//
+	// This is only accessed by the goroutine reading frames.
+	sessionMetadata map[string]string
+	// flowControlWindow is the window of the client for the stdout and stderr of each call,
+	// or zero if the client did not enable flow control.
+	//
+	// This is only accessed by the goroutine reading frames.
+	flowControlWindow uint32
+}
+
+type serveServerCall struct {
+	stdin  *serveStdin
+	cancel context.CancelFunc
+	// sendWindow is the window for the stdout and stderr of the call, if flow controlled.
+	sendWindow *flowControlWindow
+}
+
+func (s *serveServerSession) handle(ctx context.Context, serveRequest *extv1.ServeRequest) {
+	id := serveRequest.GetId()
+	s.lock.Lock()
+	call, ok := s.idToCall[id]
+	if !ok {
+		if id <= s.lastID {
+			// The call is already done.
+			s.lock.Unlock()
+			return
+		}
+		s.lastID = id
+		ctx = withCallPriority(ctx, serveRequest.GetPriority())
+		ctx = withSessionMetadata(ctx, s.sessionMetadata)
+		call = s.startCall(ctx, id, serveRequest.GetArgs())
+		s.idToCall[id] = call
+	}
+	s.lock.Unlock()
+	if len(serveRequest.GetStdin()) > 0 {
+		call.stdin.write(serveRequest.GetStdin())
+	}
+	if serveRequest.GetCloseStdin() {
+		call.stdin.close()
+	}
+	if serveRequest.GetCancel() {
+		call.cancel()
+	}
+}
+
+// startCall starts the call. Must be called with the lock held.
+func (s *serveServerSession) startCall(ctx context.Context, id uint64, args []string) *serveServerCall {
+	ctx, cancel := context.WithCancel(ctx)
+	call := &serveServerCall{
+		stdin:  newServeStdin(),
+		cancel: cancel,
+	}
+	if s.flowControlWindow > 0 {
+		call.sendWindow = newFlowControlWindow(s.flowControlWindow)
+		call.stdin.windowUpdater = newWindowUpdater(
+			s.server.flowControlWindow,
+			func(windowUpdate uint32) {
+				_ = s.write(&extv1.ServeResponse{Id: id, WindowUpdate: windowUpdate})
+			},
+		)
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		stderr := &serveCallWriter{ctx: ctx, session: s, id: id, stderr: true, window: call.sendWindow}
+		err := s.serveCall(
+			ctx,
+			call,
+			Env{
+				Args:   args,
+				Stdin:  call.stdin,
+				Stdout: &serveCallWriter{ctx: ctx, session: s, id: id, window: call.sendWindow},
+				Stderr: stderr,
+			},
+		)
+		// Drop any further stdin for the call.
+		call.stdin.close()
+		if err != nil {
+			// This mirrors what Main does for a single invocation.
+			if errString := err.Error(); errString != "" {
+				_, _ = stderr.Write([]byte(errString + "\n"))
+			}
+		}
+		s.lock.Lock()
+		delete(s.idToCall, id)
+		s.lock.Unlock()
+		_ = s.write(
+			&extv1.ServeResponse{
+				Id:       id,
+				Done:     true,
+				ExitCode: uint32(WrapExitError(err).ExitCode()),
+			},
+		)
+	}()
+	return call
+}
+
+// serveCall serves a single call, waiting for a slot if the concurrency of the session is bounded.
+func (s *serveServerSession) serveCall(ctx context.Context, call *serveServerCall, env Env) (retErr error) {
+	start := time.Now()
+	var queueDuration time.Duration
+	if s.semaphore != nil {
+		if err := s.semaphore.acquire(ctx, callPriorityFromContext(ctx)); err != nil {
+			return err
+		}
+		defer s.semaphore.release()
+		queueDuration = time.Since(start)
+	}
+	if observer := s.server.sessionCallObserver; observer != nil {
+		defer func() {
+			var flowControlWaitDuration time.Duration
+			if call.sendWindow != nil {
+				flowControlWaitDuration = call.sendWindow.getWaitDuration()
+			}
+			observer(
+				SessionCallStats{
+					Args:                    env.Args,
+					QueueDuration:           queueDuration,
+					Duration:                time.Since(start) - queueDuration,
+					FlowControlWaitDuration: flowControlWaitDuration,
+					Err:                     retErr,
+				},
+			)
+		}()
+	}
+	// Recover panics so that a single call cannot take down every call within the session.
+	defer func() {
+		if r := recover(); r != nil {
+			retErr = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return logServe(
+		ctx,
+		s.server.logger,
+		s.server.redactor,
+		env,
+		func(ctx context.Context) error {
+			return s.server.serve(ctx, env, true)
+		},
+	)
+}
+
+// closeCalls closes the stdin of all calls, and stops applying flow control to their
+// stdout and stderr, as the client will not send any more frames.
+func (s *serveServerSession) closeCalls() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, call := range s.idToCall {
+		call.stdin.close()
+		if call.sendWindow != nil {
+			call.sendWindow.close()
+		}
+	}
+}
+
+// updateWindow grants the call more bytes to write to stdout and stderr.
+func (s *serveServerSession) updateWindow(id uint64, windowUpdate uint32) {
+	s.lock.Lock()
+	call := s.idToCall[id]
+	s.lock.Unlock()
+	if call != nil && call.sendWindow != nil {
+		call.sendWindow.release(windowUpdate)
+	}
+}
+
+func (s *serveServerSession) write(serveResponse *extv1.ServeResponse) error {
+	data, err := proto.Marshal(serveResponse)
+	if err != nil {
+		return err
+	}
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return writeFrame(s.stdout, data)
+}
+
+// serveCallWriter is the stdout or stderr of a call within a session.
+type serveCallWriter struct {
+	ctx     context.Context
+	session *serveServerSession
+	id      uint64
+	stderr  bool
+	// window is the window of the client for the call, if flow controlled.
+	window *flowControlWindow
+}
+
+func (s *serveCallWriter) Write(data []byte) (int, error) {
+	var written int
+	for written < len(data) {
+		n := len(data) - written
+		if s.window != nil {
+			var err error
+			n, err = s.window.acquire(s.ctx, n)
+			if err != nil {
+				return written, err
+			}
+		}
+		serveResponse := &extv1.ServeResponse{Id: s.id}
+		if s.stderr {
+			serveResponse.Stderr = data[written : written+n]
+		} else {
+			serveResponse.Stdout = data[written : written+n]
+		}
+		if err := s.session.write(serveResponse); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+// serveStdin is the stdin of a call within a session.
+//
+// Data is buffered, so that a call that does not read its stdin does not block other
+// calls within the session. Unless the client enables flow control, the buffer is not
+// bounded.
+type serveStdin struct {
+	buffer bytes.Buffer
+	closed bool
+	lock   sync.Mutex
+	cond   *sync.Cond
+	// windowUpdater grants the client more bytes as stdin is read, if flow controlled.
+	windowUpdater *windowUpdater
+}
+
+func newServeStdin() *serveStdin {
+	serveStdin := &serveStdin{}
+	serveStdin.cond = sync.NewCond(&serveStdin.lock)
+	return serveStdin
+}
+
+func (s *serveStdin) Read(data []byte) (int, error) {
+	n, err := s.read(data)
+	if s.windowUpdater != nil {
+		s.windowUpdater.consumed(n)
+	}
+	return n, err
+}
+
+func (s *serveStdin) read(data []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for s.buffer.Len() == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if s.buffer.Len() == 0 {
+		return 0, io.EOF
+	}
+	return s.buffer.Read(data)
+}
+
+func (s *serveStdin) write(data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	_, _ = s.buffer.Write(data)
+	s.cond.Broadcast()
+}
+
+func (s *serveStdin) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+}
@@ -0,0 +1,424 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+func TestServeSessionConcurrency(t *testing.T) {
+	t.Parallel()
+
+	slowProcedure, err := NewProcedure("/foo/slow")
+	require.NoError(t, err)
+	panicProcedure, err := NewProcedure("/foo/panic")
+	require.NoError(t, err)
+	spec, err := NewSpec(slowProcedure, panicProcedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	var active atomic.Int32
+	var maxActive atomic.Int32
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/slow",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					current := active.Add(1)
+					defer active.Add(-1)
+					if current > maxActive.Load() {
+						maxActive.Store(current)
+					}
+					time.Sleep(10 * time.Millisecond)
+					return nil, nil
+				},
+				options...,
+			)
+		},
+	)
+	serverRegistrar.Register(
+		"/foo/panic",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					panic("boom")
+				},
+				options...,
+			)
+		},
+	)
+	var statsLock sync.Mutex
+	var allStats []SessionCallStats
+	procedureTimings := bytes.NewBuffer(nil)
+	server, err := NewServer(
+		spec,
+		serverRegistrar,
+		ServerWithSessionConcurrency(1),
+		ServerWithProcedureTimings(procedureTimings),
+		ServerWithSessionCallObserver(
+			func(stats SessionCallStats) {
+				statsLock.Lock()
+				defer statsLock.Unlock()
+				allStats = append(allStats, stats)
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	stdin := bytes.NewBuffer(nil)
+	for id, path := range []string{"/foo/slow", "/foo/slow", "/foo/panic", "/foo/slow"} {
+		data, err := proto.Marshal(
+			&extv1.ServeRequest{
+				Id:         uint64(id + 1),
+				Args:       []string{path},
+				CloseStdin: true,
+			},
+		)
+		require.NoError(t, err)
+		require.NoError(t, writeFrame(stdin, data))
+	}
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(
+		t,
+		server.Serve(
+			context.Background(),
+			Env{
+				Args:   []string{"--" + ServeFlagName},
+				Stdin:  stdin,
+				Stdout: stdout,
+				Stderr: io.Discard,
+			},
+		),
+	)
+	idToExitCode := make(map[uint64]uint32)
+	for {
+		data, err := readFrame(stdout, maxFrameSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		serveResponse := &extv1.ServeResponse{}
+		require.NoError(t, proto.Unmarshal(data, serveResponse))
+		if serveResponse.GetDone() {
+			idToExitCode[serveResponse.GetId()] = serveResponse.GetExitCode()
+		}
+	}
+	require.Len(t, idToExitCode, 4)
+	require.Zero(t, idToExitCode[1])
+	require.Zero(t, idToExitCode[2])
+	require.NotZero(t, idToExitCode[3])
+	require.Zero(t, idToExitCode[4])
+	require.Equal(t, int32(1), maxActive.Load())
+	require.Len(t, allStats, 4)
+	var panicErrs int
+	for _, stats := range allStats {
+		if stats.Err != nil {
+			require.Equal(t, []string{"/foo/panic"}, stats.Args)
+			require.ErrorContains(t, stats.Err, "boom")
+			panicErrs++
+		}
+	}
+	require.Equal(t, 1, panicErrs)
+	procedureTimingsLines := strings.Split(procedureTimings.String(), "\n")
+	require.Regexp(t, `^PROCEDURE +CALLS +MEAN +P50 +P90 +P99 +MAX$`, procedureTimingsLines[0])
+	require.Regexp(t, `^/foo/panic +1 `, procedureTimingsLines[1])
+	require.Regexp(t, `^/foo/slow +3 `, procedureTimingsLines[2])
+	require.Empty(t, procedureTimingsLines[3])
+	require.Regexp(t, `^PROCEDURE +DURATION +CALLS$`, procedureTimingsLines[4])
+	require.Regexp(t, `^/foo/panic +<=\S+ +1$`, procedureTimingsLines[5])
+}
+
+func TestServeSessionMetadata(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	var lock sync.Mutex
+	idToMetadata := make(map[string]map[string]string)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(ctx context.Context, _ any) (any, error) {
+					metadata := MetadataFromContext(ctx)
+					lock.Lock()
+					defer lock.Unlock()
+					idToMetadata[metadata["id"]] = metadata
+					return nil, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	stdin := bytes.NewBuffer(nil)
+	for _, serveRequest := range []*extv1.ServeRequest{
+		{Id: 1, Args: []string{"/foo/bar", "--metadata", "id=1"}, CloseStdin: true},
+		{SessionMetadata: map[string]string{"token": "secret", "region": "us"}},
+		{Id: 2, Args: []string{"/foo/bar", "--metadata", "id=2", "--metadata", "region=eu"}, CloseStdin: true},
+	} {
+		data, err := proto.Marshal(serveRequest)
+		require.NoError(t, err)
+		require.NoError(t, writeFrame(stdin, data))
+	}
+	require.NoError(
+		t,
+		server.Serve(
+			context.Background(),
+			Env{
+				Args:   []string{"--" + ServeFlagName},
+				Stdin:  stdin,
+				Stdout: io.Discard,
+				Stderr: io.Discard,
+			},
+		),
+	)
+	require.Equal(
+		t,
+		map[string]map[string]string{
+			// The session metadata only applies to calls started after it was sent.
+			"1": {"id": "1"},
+			// The metadata of the call takes precedence.
+			"2": {"id": "2", "token": "secret", "region": "eu"},
+		},
+		idToMetadata,
+	)
+
+	runner := NewExecServeRunner("sh", ExecRunnerWithSessionMetadata(map[string]string{"a=b": "c"}))
+	err = runner.Run(context.Background(), Env{Args: []string{"/foo/bar"}, Stdout: io.Discard})
+	require.ErrorContains(t, err, "invalid session metadata")
+	require.NoError(t, runner.Close())
+}
+
+func TestServeSessionFlowControl(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(_ context.Context, handleEnv HandleEnv, _ ...HandleOption) error {
+			data, err := io.ReadAll(handleEnv.Stdin)
+			if err != nil {
+				return err
+			}
+			_, err = handleEnv.Stdout.Write(data)
+			return err
+		},
+	)
+	var allStats []SessionCallStats
+	server, err := NewServer(
+		spec,
+		serverRegistrar,
+		ServerWithFlowControlWindow(8),
+		ServerWithSessionCallObserver(
+			func(stats SessionCallStats) {
+				allStats = append(allStats, stats)
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	errC := make(chan error, 1)
+	go func() {
+		errC <- server.Serve(
+			context.Background(),
+			Env{
+				Args:   []string{"--" + ServeFlagName},
+				Stdin:  stdinReader,
+				Stdout: stdoutWriter,
+				Stderr: io.Discard,
+			},
+		)
+	}()
+	write := func(serveRequest *extv1.ServeRequest) {
+		data, err := proto.Marshal(serveRequest)
+		require.NoError(t, err)
+		require.NoError(t, writeFrame(stdinWriter, data))
+	}
+	read := func() *extv1.ServeResponse {
+		data, err := readFrame(stdoutReader, maxFrameSize)
+		require.NoError(t, err)
+		serveResponse := &extv1.ServeResponse{}
+		require.NoError(t, proto.Unmarshal(data, serveResponse))
+		return serveResponse
+	}
+
+	// The plugin acknowledges flow control with its window for stdin.
+	write(&extv1.ServeRequest{FlowControlWindow: 4})
+	require.Equal(t, uint32(8), read().GetFlowControlWindow())
+	write(&extv1.ServeRequest{Id: 1, Args: []string{"/foo/bar"}, Stdin: []byte("01234567")})
+	write(&extv1.ServeRequest{Id: 1, CloseStdin: true})
+	// The plugin grants more stdin once the call has read it.
+	serveResponse := read()
+	require.Equal(t, uint64(1), serveResponse.GetId())
+	require.Equal(t, uint32(8), serveResponse.GetWindowUpdate())
+	// The call can only write up to the window of the client.
+	require.Equal(t, []byte("0123"), read().GetStdout())
+	// Give the call time to wait for the window.
+	time.Sleep(50 * time.Millisecond)
+	write(&extv1.ServeRequest{Id: 1, WindowUpdate: 4})
+	require.Equal(t, []byte("4567"), read().GetStdout())
+	serveResponse = read()
+	require.True(t, serveResponse.GetDone())
+	require.Zero(t, serveResponse.GetExitCode())
+	require.NoError(t, stdinWriter.Close())
+	require.NoError(t, <-errC)
+	require.Len(t, allStats, 1)
+	require.Positive(t, allStats[0].FlowControlWaitDuration)
+}
+
+func TestExecServeRunnerHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	shProgramPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh program not found")
+	}
+	var starts atomic.Int32
+	// A plugin that never responds, including to heartbeats.
+	runner := NewExecServeRunner(
+		shProgramPath,
+		ExecRunnerWithArgs("-c", "exec sleep 60"),
+		ExecRunnerWithCmdOption(func(*exec.Cmd) { starts.Add(1) }),
+		ExecRunnerWithHeartbeat(5*time.Millisecond, 50*time.Millisecond),
+	)
+	t.Cleanup(func() { _ = runner.Close() })
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		err := runner.Run(context.Background(), Env{Args: []string{"/foo/bar"}})
+		require.ErrorContains(t, err, "heartbeat")
+		require.Less(t, time.Since(start), 10*time.Second)
+	}
+	// The hung plugin was restarted for the second call.
+	require.Equal(t, int32(2), starts.Load())
+}
+
+func TestExecServeSessionCanceledDuringWrite(t *testing.T) {
+	t.Parallel()
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	go func() { _, _ = io.Copy(io.Discard, stdinReader) }()
+	session := startExecServeSession(nil, stdinWriter, stdoutReader, 0)
+	t.Cleanup(func() {
+		_ = stdoutWriter.Close()
+		<-session.doneC
+		_ = stdinWriter.Close()
+	})
+	writeResponse := func(serveResponse *extv1.ServeResponse) {
+		data, err := proto.Marshal(serveResponse)
+		require.NoError(t, err)
+		require.NoError(t, writeFrame(stdoutWriter, data))
+	}
+
+	stdout := &blockingWriter{startedC: make(chan struct{}), releaseC: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	errC := make(chan error, 1)
+	go func() {
+		errC <- session.run(ctx, Env{Stdin: bytes.NewReader(nil), Stdout: stdout, Stderr: io.Discard})
+	}()
+	writeResponse(&extv1.ServeResponse{Id: 1, Stdout: []byte("foo")})
+	<-stdout.startedC
+	cancel()
+	// The call does not return while its stdout is being written.
+	select {
+	case err := <-errC:
+		close(stdout.releaseC)
+		t.Fatalf("call returned during write: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(stdout.releaseC)
+	require.ErrorIs(t, <-errC, context.Canceled)
+	// Nothing is written once the call returned.
+	writeResponse(&extv1.ServeResponse{Id: 1, Stdout: []byte("bar"), Done: true})
+	require.NoError(t, stdoutWriter.Close())
+	<-session.doneC
+	require.Equal(t, "foo", stdout.buffer.String())
+}
+
+// blockingWriter is a writer whose first write blocks until releaseC is closed.
+type blockingWriter struct {
+	startedC chan struct{}
+	releaseC chan struct{}
+	buffer   bytes.Buffer
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	if b.buffer.Len() == 0 {
+		close(b.startedC)
+		<-b.releaseC
+	}
+	return b.buffer.Write(p)
+}
+
+func TestExecServeRunnerHeartbeatStdinNotRead(t *testing.T) {
+	t.Parallel()
+
+	shProgramPath, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh program not found")
+	}
+	// A plugin that never reads stdin, so that writes to stdin block once the pipe is full.
+	runner := NewExecServeRunner(
+		shProgramPath,
+		ExecRunnerWithArgs("-c", "exec sleep 60"),
+		ExecRunnerWithHeartbeat(5*time.Millisecond, 50*time.Millisecond),
+	)
+	t.Cleanup(func() { _ = runner.Close() })
+	start := time.Now()
+	err = runner.Run(
+		context.Background(),
+		Env{
+			Args:  []string{"/foo/bar"},
+			Stdin: bytes.NewReader(make([]byte, 4*1024*1024)),
+		},
+	)
+	require.ErrorContains(t, err, "heartbeat")
+	require.Less(t, time.Since(start), 10*time.Second)
+}
@@ -18,9 +18,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"slices"
+	"time"
 
 	"github.com/spf13/pflag"
+	"pluginrpc.com/pluginrpc/pluginrpcinfo"
 )
 
 // Server is the server for plugin implementations.
@@ -28,6 +33,8 @@ import (
 // The easiest way to run a server for a plugin is to call ServerMain.
 type Server interface {
 	// Serve serves the plugin.
+	//
+	// The Env must be valid, see Env.Validate.
 	Serve(ctx context.Context, env Env) error
 
 	isServer()
@@ -36,7 +43,8 @@ type Server interface {
 // NewServer returns a new Server for a given Spec and ServerRegistrar.
 //
 // The Spec will be validated against the ServerRegistar to make sure there is a
-// 1-1 mapping between Procedures and registered paths.
+// 1-1 mapping between Procedures and registered paths. At least one Procedure of the
+// Spec must not be disabled, see ProcedureWithDisabled.
 //
 // Once passed to this constructor, the ServerRegistrar can no longer have new
 // paths registered to it.
@@ -44,24 +52,383 @@ func NewServer(spec Spec, serverRegistrar ServerRegistrar, options ...ServerOpti
 	return newServer(spec, serverRegistrar, options...)
 }
 
+// NewServerForHandler returns a new Server for a single service, building the Spec with
+// newSpec, and registering the service with register.
+//
+// This is used by the New<Service>ServerForHandler functions generated by
+// protoc-gen-pluginrpc-go, which collapse the construction of the Spec, ServerRegistrar,
+// Handler, and Server into one call. Use these instead of calling this function directly.
+func NewServerForHandler(
+	newSpec func(...SpecOption) (Spec, error),
+	register func(ServerRegistrar, Handler),
+	options ...ServerForHandlerOption,
+) (Server, error) {
+	serverForHandlerOptions := newServerForHandlerOptions()
+	for _, option := range options {
+		option(serverForHandlerOptions)
+	}
+	spec, err := newSpec(serverForHandlerOptions.specOptions...)
+	if err != nil {
+		return nil, err
+	}
+	serverRegistrar := NewServerRegistrar()
+	register(serverRegistrar, NewHandler(spec, serverForHandlerOptions.handlerOptions...))
+	return NewServer(spec, serverRegistrar, serverForHandlerOptions.serverOptions...)
+}
+
+// ServiceRegistration is the registration of a service for NewServerForServices and
+// MainService.
+//
+// ServiceRegistrations are generated by protoc-gen-pluginrpc-go as <Service>Registration
+// structs, which hold the handler of the service and overrides of its Spec.
+type ServiceRegistration interface {
+	// BuildSpec builds the Spec of the service.
+	BuildSpec() (Spec, error)
+	// Register registers the service with the ServerRegistrar, calling the handler of the
+	// service through the given Handler.
+	Register(serverRegistrar ServerRegistrar, handler Handler)
+}
+
+// NewServerForServices returns a new Server for the given services.
+//
+// The Spec is the union of the Procedures of all services, and must not contain duplicate
+// Procedures by path or args. The options apply to the combined Spec, Handler, and Server.
+func NewServerForServices(registrations []ServiceRegistration, options ...ServerForHandlerOption) (Server, error) {
+	if len(registrations) == 0 {
+		return nil, errors.New("no services given")
+	}
+	return NewServerForHandler(
+		func(specOptions ...SpecOption) (Spec, error) {
+			var procedures []Procedure
+			for _, registration := range registrations {
+				spec, err := registration.BuildSpec()
+				if err != nil {
+					return nil, err
+				}
+				procedures = append(procedures, spec.Procedures()...)
+			}
+			return NewSpecWithOptions(procedures, specOptions...)
+		},
+		func(serverRegistrar ServerRegistrar, handler Handler) {
+			for _, registration := range registrations {
+				registration.Register(serverRegistrar, handler)
+			}
+		},
+		options...,
+	)
+}
+
+// ServerForHandlerOption is an option for NewServerForHandler, NewServerForServices, and the
+// generated New<Service>ServerForHandler functions.
+type ServerForHandlerOption func(*serverForHandlerOptions)
+
+// ServerForHandlerWithSpecOptions returns a new ServerForHandlerOption that builds the
+// Spec with the given SpecOptions, for example SpecWithDescriptors.
+//
+// This option can be specified multiple times, in which case the SpecOptions are appended.
+func ServerForHandlerWithSpecOptions(options ...SpecOption) ServerForHandlerOption {
+	return func(serverForHandlerOptions *serverForHandlerOptions) {
+		serverForHandlerOptions.specOptions = append(serverForHandlerOptions.specOptions, options...)
+	}
+}
+
+// ServerForHandlerWithHandlerOptions returns a new ServerForHandlerOption that creates the
+// Handler with the given HandlerOptions, for example HandlerWithInterceptors.
+//
+// This option can be specified multiple times, in which case the HandlerOptions are appended.
+func ServerForHandlerWithHandlerOptions(options ...HandlerOption) ServerForHandlerOption {
+	return func(serverForHandlerOptions *serverForHandlerOptions) {
+		serverForHandlerOptions.handlerOptions = append(serverForHandlerOptions.handlerOptions, options...)
+	}
+}
+
+// ServerForHandlerWithServerOptions returns a new ServerForHandlerOption that creates the
+// Server with the given ServerOptions, for example ServerWithDoc.
+//
+// This option can be specified multiple times, in which case the ServerOptions are appended.
+func ServerForHandlerWithServerOptions(options ...ServerOption) ServerForHandlerOption {
+	return func(serverForHandlerOptions *serverForHandlerOptions) {
+		serverForHandlerOptions.serverOptions = append(serverForHandlerOptions.serverOptions, options...)
+	}
+}
+
 // ServerOption is an option for a new Server.
 type ServerOption func(*serverOptions)
 
 // ServerWithDoc will attach the given documentation to the server.
 //
-// This will add ths given docs as a prefix when the flag -h/--help is used.
+// This will add ths given docs as a prefix when the flag -h/--help is used. When -h/--help
+// is used with the args of a Procedure, the help for the Procedure is printed instead,
+// including the documentation of the Procedure, see ProcedureWithDoc.
 func ServerWithDoc(doc string) ServerOption {
 	return func(serverOptions *serverOptions) {
 		serverOptions.doc = doc
 	}
 }
 
+// ServerWithInfo will attach the given Info to the server.
+//
+// This will be returned to clients when the flag --info is used.
+func ServerWithInfo(info Info) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.info = info
+	}
+}
+
+// ServerWithVersion will attach the given version to the server.
+//
+// This will be printed to stdout when the flag --version is used, followed by the build
+// metadata of pluginrpc-go, see pluginrpcinfo. The version is also returned with the
+// Spec when --version is specified alongside --spec, see ClientWithSpecVersion.
+//
+// The version is typically the version of the release of the plugin, for example v1.2.3.
+func ServerWithVersion(version string) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.version = version
+	}
+}
+
+// ServerWithStdinMode will result in the given StdinMode being used by Handlers
+// to read requests from stdin.
+//
+// This is useful in environments where the default terminal detection of
+// StdinModeAuto is unreliable, for example some CI environments.
+//
+// The default is StdinModeAuto.
+func ServerWithStdinMode(stdinMode StdinMode) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.stdinMode = stdinMode
+	}
+}
+
+// ServerWithStdinTimeout will result in Handlers bounding the time spent reading
+// requests from stdin by the given timeout.
+//
+// See HandleWithStdinTimeout for the semantics of the timeout.
+//
+// The default is no timeout.
+func ServerWithStdinTimeout(timeout time.Duration) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.stdinTimeout = timeout
+	}
+}
+
+// ServerWithMaxStdinBytes will result in Handlers limiting the size of requests
+// read from stdin to the given number of bytes.
+//
+// See HandleWithMaxStdinBytes for the semantics of the limit.
+//
+// The default is no limit.
+func ServerWithMaxStdinBytes(maxStdinBytes int64) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.maxStdinBytes = maxStdinBytes
+	}
+}
+
+// ServerWithNonceStore will result in the given NonceStore being used to verify that
+// requests to replay-protected Procedures are not replays.
+//
+// This is required if any Procedure has ProcedureWithReplayProtection.
+func ServerWithNonceStore(nonceStore NonceStore) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.nonceStore = nonceStore
+	}
+}
+
+// ServerWithReplayWindow will result in requests to replay-protected Procedures being
+// rejected if their timestamp differs from the current time by more than the window.
+//
+// Nonces are retained by the NonceStore for the duration of the window.
+//
+// The default is 5 minutes.
+func ServerWithReplayWindow(window time.Duration) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.replayWindow = window
+	}
+}
+
+// ServerWithDeprecatedFormat marks the Format as deprecated, with a message describing
+// the deprecation, for example which Format to use instead.
+//
+// Clients that call a Procedure with the Format are warned, see ClientWithWarningHandler.
+// Calls with the Format are still handled as before.
+//
+// If the message is empty, a default message is used.
+func ServerWithDeprecatedFormat(format Format, message string) ServerOption {
+	return func(serverOptions *serverOptions) {
+		if message == "" {
+			message = fmt.Sprintf("format %q is deprecated", format.String())
+		}
+		if serverOptions.formatToDeprecation == nil {
+			serverOptions.formatToDeprecation = make(map[Format]string)
+		}
+		serverOptions.formatToDeprecation[format] = message
+	}
+}
+
+// ServerWithSessionConcurrency will result in at most the given number of calls being
+// handled concurrently within a session started with --serve, see NewExecServeRunner.
+//
+// Further calls are queued until a call completes, so that a burst of calls does not
+// overload the plugin. Queued calls are handled in order of the priority given by the
+// client, see CallWithPriority. Regardless of this option, each call within a session is
+// handled in its own goroutine, and a panic within a call is recovered and fails only
+// that call.
+//
+// The default is no limit.
+func ServerWithSessionConcurrency(concurrency int) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.sessionConcurrency = concurrency
+	}
+}
+
+// ServerWithFlowControlWindow will result in the server allowing clients to send the given
+// number of bytes of stdin for each call within a session started with --serve before
+// the call has read them.
+//
+// This only applies when the client enables flow control, see
+// ExecRunnerWithFlowControlWindow. The server then grants the client more bytes as calls
+// read their stdin, so that a fast client cannot overwhelm a slow call. In turn, calls
+// wait for the client to consume their stdout and stderr before writing more than the
+// window of the client, see SessionCallStats.
+//
+// The default is 1 MiB.
+func ServerWithFlowControlWindow(window uint32) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.flowControlWindow = window
+	}
+}
+
+// ServerWithSessionCallObserver will result in the given function being called with
+// SessionCallStats after each call within a session started with --serve completes.
+//
+// The function may be called concurrently.
+func ServerWithSessionCallObserver(observer func(SessionCallStats)) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.sessionCallObserver = observer
+	}
+}
+
+// ServerWithProcedureTimings will result in the server recording the time spent handling
+// each call to a Procedure within a session started with --serve, and writing a histogram
+// of these times for each Procedure to the given writer when the session ends.
+//
+// This is meant to help plugin authors find slow Procedures, for example by specifying
+// os.Stderr, as the histogram is then written to the stderr of the plugin.
+//
+// The default is to not record the time spent handling Procedures.
+func ServerWithProcedureTimings(writer io.Writer) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.procedureTimingsWriter = writer
+	}
+}
+
+// ServerWithEnvDefaults will result in the server honoring the FormatEnvVarName and
+// ProtocolEnvVarName environment variables of the current process.
+//
+// If FormatEnvVarName is set, it is used as the default of --format. If
+// ProtocolEnvVarName is set, the server fails unless it supports the given protocol
+// version. This is useful for debugging sessions, for example to invoke the plugin by
+// hand with JSON requests. Clients always specify --format, so this does not change the
+// Format of calls from clients. See ClientWithEnvDefaults.
+//
+// The default is to ignore these environment variables.
+func ServerWithEnvDefaults() ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.envDefaults = true
+	}
+}
+
+// ServerWithOnShutdown will result in the given function being called after Serve
+// completes, including if it was interrupted, for example to flush caches, close
+// databases, or emit final telemetry.
+//
+// The function is called with a context that has the values of the context given to
+// Serve, but is not cancelled when that context is. When run with Main, Serve is called
+// once per invocation of the plugin, and for sessions started with --serve, the function
+// is called once the session ends.
+//
+// This option can be specified multiple times, in which case the functions are called in order.
+func ServerWithOnShutdown(onShutdown func(context.Context)) ServerOption {
+	return func(serverOptions *serverOptions) {
+		if onShutdown != nil {
+			serverOptions.onShutdowns = append(serverOptions.onShutdowns, onShutdown)
+		}
+	}
+}
+
+// ServerWithLogger will result in the server logging the start and end of every
+// invocation at debug level to the given logger, including the Procedure, format,
+// duration, exit code, and error, if any.
+//
+// Calls within a session started with --serve are logged individually.
+//
+// The default is to not log.
+func ServerWithLogger(logger *slog.Logger) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.logger = logger
+	}
+}
+
+// ServerWithRedactor will result in the server redacting the details of errors with the
+// given Redactor before they are sent to the client or logged to the logger given by
+// ServerWithLogger, see ErrorWithDetails.
+//
+// Details whose types are not registered in protoregistry.GlobalTypes cannot be redacted.
+// These are sent as-is, and are not logged.
+//
+// The default is NewRedactor(), which redacts fields with the debug_redact field option.
+func ServerWithRedactor(redactor Redactor) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.redactor = redactor
+	}
+}
+
+// ServerWithAuthorizer will result in the given function being called before each
+// Procedure is handled, with the path of the Procedure and the request metadata sent
+// with --metadata, see CallWithMetadata. Within a session started with --serve, this
+// includes the metadata of the session, see ExecRunnerWithSessionMetadata.
+//
+// If the function returns an error, the Procedure is not handled, and the error is
+// returned to the client. Errors that are not *Errors are returned with
+// CodePermissionDenied. This allows plugins to restrict dangerous Procedures when invoked
+// by unknown hosts, for example hosts that did not send an expected token.
+//
+// The function receives a copy of the metadata, so changes to it are ignored. The
+// function is not called for --protocol, --spec, or --info.
+func ServerWithAuthorizer(authorize func(ctx context.Context, procedurePath string, metadata map[string]string) error) ServerOption {
+	return func(serverOptions *serverOptions) {
+		serverOptions.authorize = authorize
+	}
+}
+
 // *** PRIVATE ***
 
 type server struct {
 	spec             Spec
 	pathToHandleFunc map[string]func(context.Context, HandleEnv, ...HandleOption) error
 	doc              string
+	info             Info
+	version          string
+	stdinMode        StdinMode
+	stdinTimeout     time.Duration
+	maxStdinBytes    int64
+	nonceStore       NonceStore
+	replayWindow     time.Duration
+	// pathToSemaphore contains a semaphore of size one for every serialized Procedure.
+	pathToSemaphore     map[string]chan struct{}
+	sessionConcurrency  int
+	flowControlWindow   uint32
+	sessionCallObserver func(SessionCallStats)
+	authorize           func(context.Context, string, map[string]string) error
+	// procedureTimingsWriter is the writer to write procedure timings to at the end
+	// of a session, if any.
+	procedureTimingsWriter io.Writer
+	logger                 *slog.Logger
+	redactor               Redactor
+	envDefaults            bool
+	onShutdowns            []func(context.Context)
+	formatToDeprecation    map[Format]string
 }
 
 func newServer(spec Spec, serverRegistrar ServerRegistrar, options ...ServerOption) (*server, error) {
@@ -73,63 +440,371 @@ func newServer(spec Spec, serverRegistrar ServerRegistrar, options ...ServerOpti
 	if err != nil {
 		return nil, err
 	}
+	if serverOptions.stdinMode != 0 {
+		if err := validateStdinMode(serverOptions.stdinMode); err != nil {
+			return nil, err
+		}
+	}
+	if serverOptions.stdinTimeout < 0 {
+		return nil, fmt.Errorf("invalid stdin timeout: %v", serverOptions.stdinTimeout)
+	}
+	if serverOptions.maxStdinBytes < 0 {
+		return nil, fmt.Errorf("invalid max stdin bytes: %d", serverOptions.maxStdinBytes)
+	}
 	for path := range pathToHandleFunc {
 		if spec.ProcedureForPath(path) == nil {
 			return nil, fmt.Errorf("path %q not contained within spec", path)
 		}
 	}
+	for path, messageFullNames := range serverRegistrar.pathToMessageFullNames() {
+		if err := validateMessageFullNames(path, messageFullNames); err != nil {
+			return nil, err
+		}
+	}
+	if !slices.ContainsFunc(spec.Procedures(), func(procedure Procedure) bool { return !procedure.Disabled() }) {
+		// The Spec sent to clients would have no Procedures, which clients reject.
+		return nil, errors.New("all procedures are disabled, at least one procedure must be enabled")
+	}
 	for _, procedure := range spec.Procedures() {
 		if _, ok := pathToHandleFunc[procedure.Path()]; !ok {
 			return nil, fmt.Errorf("path %q not registered", procedure.Path())
 		}
+		if procedure.ReplayProtected() && serverOptions.nonceStore == nil {
+			return nil, fmt.Errorf("procedure %q requires replay protection but no NonceStore was specified", procedure.Path())
+		}
+	}
+	pathToSemaphore := make(map[string]chan struct{})
+	for _, procedure := range spec.Procedures() {
+		if procedure.Serialized() {
+			pathToSemaphore[procedure.Path()] = make(chan struct{}, 1)
+		}
+	}
+	if serverOptions.replayWindow < 0 {
+		return nil, fmt.Errorf("invalid replay window: %v", serverOptions.replayWindow)
+	}
+	if serverOptions.replayWindow == 0 {
+		serverOptions.replayWindow = defaultReplayWindow
+	}
+	if serverOptions.sessionConcurrency < 0 {
+		return nil, fmt.Errorf("invalid session concurrency: %d", serverOptions.sessionConcurrency)
+	}
+	if serverOptions.flowControlWindow == 0 {
+		serverOptions.flowControlWindow = defaultFlowControlWindow
+	}
+	if serverOptions.redactor == nil {
+		serverOptions.redactor = NewRedactor()
 	}
 	return &server{
-		spec:             spec,
-		pathToHandleFunc: pathToHandleFunc,
-		doc:              serverOptions.doc,
+		spec:                   spec,
+		pathToHandleFunc:       pathToHandleFunc,
+		doc:                    serverOptions.doc,
+		info:                   serverOptions.info,
+		version:                serverOptions.version,
+		stdinMode:              serverOptions.stdinMode,
+		stdinTimeout:           serverOptions.stdinTimeout,
+		maxStdinBytes:          serverOptions.maxStdinBytes,
+		nonceStore:             serverOptions.nonceStore,
+		replayWindow:           serverOptions.replayWindow,
+		pathToSemaphore:        pathToSemaphore,
+		sessionConcurrency:     serverOptions.sessionConcurrency,
+		flowControlWindow:      serverOptions.flowControlWindow,
+		sessionCallObserver:    serverOptions.sessionCallObserver,
+		authorize:              serverOptions.authorize,
+		procedureTimingsWriter: serverOptions.procedureTimingsWriter,
+		logger:                 serverOptions.logger,
+		redactor:               serverOptions.redactor,
+		envDefaults:            serverOptions.envDefaults,
+		onShutdowns:            serverOptions.onShutdowns,
+		formatToDeprecation:    serverOptions.formatToDeprecation,
 	}, nil
 }
 
 func (s *server) Serve(ctx context.Context, env Env) error {
-	flags, args, err := parseFlags(env.Stderr, env.Args, s.spec, s.doc)
+	ctx, err := withProcessHostServicesRunner(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		shutdownCtx := context.WithoutCancel(ctx)
+		for _, onShutdown := range s.onShutdowns {
+			onShutdown(shutdownCtx)
+		}
+	}()
+	return logServe(
+		ctx,
+		s.logger,
+		s.redactor,
+		env,
+		func(ctx context.Context) error {
+			return s.serve(ctx, env, false)
+		},
+	)
+}
+
+func (*server) isServer() {}
+
+// serve serves a single invocation of the plugin.
+//
+// If inSession is true, this is a call within a session started with --serve.
+func (s *server) serve(ctx context.Context, env Env, inSession bool) error {
+	if err := env.Validate(); err != nil {
+		return err
+	}
+	defaultFormat := FormatBinary
+	if s.envDefaults {
+		envDefaults, err := getEnvDefaults()
+		if err != nil {
+			return err
+		}
+		if envDefaults.format != 0 {
+			defaultFormat = envDefaults.format
+		}
+	}
+	flags, args, err := parseFlags(env.Stdout, env.Stderr, env.Args, s.spec, s.doc, defaultFormat)
 	if err != nil {
 		if errors.Is(err, pflag.ErrHelp) {
 			return nil
 		}
 		return err
 	}
-	if flags.printProtocol {
+	if flags.serve {
+		if inSession {
+			return fmt.Errorf("cannot specify --%s within a session", ServeFlagName)
+		}
+		if len(args) > 0 {
+			return fmt.Errorf("cannot specify args with --%s: %v", ServeFlagName, args)
+		}
+		return s.serveSession(ctx, env)
+	}
+	if flags.printProtocol && !flags.printSpec {
 		_, err := env.Stdout.Write(marshalProtocol(protocolVersion))
 		return err
 	}
 	if flags.printSpec {
-		data, err := marshalSpec(flags.format, NewProtoSpec(s.spec))
+		if flags.printProtocol {
+			if _, err := env.Stdout.Write(marshalProtocol(protocolVersion)); err != nil {
+				return err
+			}
+		}
+		var protoSpec any = NewProtoSpec(s.spec)
+		if flags.descriptors || flags.docs || flags.printVersion || flags.compressions {
+			extProtoSpec := newExtProtoSpec(s.spec, flags.descriptors, flags.docs)
+			if flags.printVersion {
+				extProtoSpec.Version = s.version
+			}
+			if flags.compressions {
+				extProtoSpec.Compressions = compressionsToStrings(AllCompressions)
+			}
+			protoSpec = extProtoSpec
+		}
+		data, err := marshalSpec(flags.format, protoSpec)
 		if err != nil {
 			return err
 		}
+		if flags.compress {
+			data, err = compressSpec(data)
+			if err != nil {
+				return err
+			}
+		}
 		_, err = env.Stdout.Write(data)
 		return err
 	}
-	for _, procedure := range s.spec.Procedures() {
-		if slices.Equal(args, []string{procedure.Path()}) {
-			handleFunc := s.pathToHandleFunc[procedure.Path()]
-			return handleFunc(ctx, handleEnvForEnv(env), HandleWithFormat(flags.format))
+	if flags.printVersion {
+		if s.version == "" {
+			return errors.New("plugin does not have a version")
 		}
-		// TODO: Make sure args do not overlap in procedures
-		if slices.Equal(args, procedure.Args()) {
+		_, err := fmt.Fprintf(env.Stdout, "%s\n%s\n", s.version, pluginrpcinfo.Get())
+		return err
+	}
+	if flags.printInfo {
+		protoInfo := newProtoInfo(s.info)
+		if protoInfo.GetPlatform() == nil {
+			protoInfo.Platform = newProtoPlatform(currentPlatform())
+		}
+		data, err := marshalInfo(flags.format, protoInfo)
+		if err != nil {
+			return err
+		}
+		_, err = env.Stdout.Write(data)
+		return err
+	}
+	if flags.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, flags.timeout)
+		defer cancel()
+	}
+	metadata := mergeSessionMetadata(sessionMetadataFromContext(ctx), flags.metadata)
+	for _, procedure := range s.spec.Procedures() {
+		renamedFrom, ok := matchProcedureArgs(procedure, args)
+		if ok {
+			setServedCallProcedure(ctx, procedure.Path(), flags.format)
+			handleOptions := []HandleOption{
+				HandleWithFormat(flags.format),
+				handleWithProcedurePath(procedure.Path()),
+				handleWithRedactor(s.redactor),
+			}
+			if flags.errorDetails {
+				handleOptions = append(handleOptions, HandleWithErrorDetails())
+			}
+			if flags.responseMetadata {
+				handleOptions = append(handleOptions, handleWithResponseMetadata())
+			}
+			if flags.compression != 0 {
+				handleOptions = append(handleOptions, HandleWithCompression(flags.compression))
+			}
+			if flags.payload {
+				handleOptions = append(handleOptions, HandleWithPayloads())
+			}
+			if s.stdinMode != 0 {
+				handleOptions = append(handleOptions, HandleWithStdinMode(s.stdinMode))
+			}
+			if s.stdinTimeout > 0 {
+				handleOptions = append(handleOptions, HandleWithStdinTimeout(s.stdinTimeout))
+			}
+			if s.maxStdinBytes > 0 {
+				handleOptions = append(handleOptions, HandleWithMaxStdinBytes(s.maxStdinBytes))
+			}
+			if flags.warnings {
+				if warnings := getWarnings(procedure, renamedFrom, flags.format, s.formatToDeprecation); len(warnings) > 0 {
+					handleOptions = append(handleOptions, handleWithWarnings(warnings))
+				}
+			}
+			if procedure.Disabled() {
+				return writeErrorResponse(ctx, procedure, env, handleOptions, NewErrorf(CodeUnimplemented, "procedure disabled: %q", procedure.Path()))
+			}
+			if s.authorize != nil {
+				if err := s.authorize(ctx, procedure.Path(), maps.Clone(metadata)); err != nil {
+					if !errors.As(err, new(*Error)) {
+						err = NewError(CodePermissionDenied, err)
+					}
+					return writeErrorResponse(ctx, procedure, env, handleOptions, err)
+				}
+			}
+			if procedure.ReplayProtected() {
+				if err := verifyReplay(ctx, s.nonceStore, s.replayWindow, time.Now(), procedure, flags.nonce, flags.timestamp); err != nil {
+					return writeErrorResponse(ctx, procedure, env, handleOptions, err)
+				}
+			}
+			if semaphore, ok := s.pathToSemaphore[procedure.Path()]; ok {
+				select {
+				case semaphore <- struct{}{}:
+				case <-ctx.Done():
+					return writeErrorResponse(ctx, procedure, env, handleOptions, ctx.Err())
+				}
+				defer func() { <-semaphore }()
+			}
+			if procedureTimings, ok := procedureTimingsFromContext(ctx); ok {
+				start := time.Now()
+				defer func() {
+					procedureTimings.record(procedure.Path(), time.Since(start))
+				}()
+			}
 			handleFunc := s.pathToHandleFunc[procedure.Path()]
-			return handleFunc(ctx, handleEnvForEnv(env), HandleWithFormat(flags.format))
+			return handleFunc(withRequestMetadata(ctx, metadata), handleEnvForEnv(env), handleOptions...)
 		}
 	}
+	if slices.Equal(args, []string{HealthCheckPath}) {
+		return writeHealthCheckResponse(flags.format, env)
+	}
 	return fmt.Errorf("args not recognized: %v", args)
 }
 
-func (*server) isServer() {}
+// matchProcedureArgs returns true if the args invoke the Procedure, either by its path or
+// args, or by the path or args of a Procedure it was renamed from.
+//
+// If the args invoke a Procedure that the Procedure was renamed from, that Procedure is returned.
+func matchProcedureArgs(procedure Procedure, args []string) (Procedure, bool) {
+	for _, candidate := range append([]Procedure{procedure}, procedure.RenamedFrom()...) {
+		if slices.Equal(args, []string{candidate.Path()}) || slices.Equal(args, candidate.Args()) {
+			if candidate == procedure {
+				return nil, true
+			}
+			return candidate, true
+		}
+	}
+	return nil, false
+}
+
+// writeErrorResponse writes an error response for errors that occur before a Procedure is handled.
+//
+// For example, clients will not see disabled Procedures in the Spec, however a disabled
+// Procedure may still be invoked directly, resulting in a CodeUnimplemented error.
+//
+// The error is written by a Handler in the same manner as the Handler of the Procedure
+// would, as an error frame for streaming Procedures, and as a response with the binary
+// header and Compression of the request otherwise. Procedures are streaming if their
+// method is resolved with protoregistry.GlobalFiles and is streaming. The handle function
+// of the Procedure is never called.
+func writeErrorResponse(ctx context.Context, procedure Procedure, env Env, handleOptions []HandleOption, inputErr error) error {
+	handler := newHandler(nil)
+	handleEnv := handleEnvForEnv(env)
+	methodDescriptor := methodDescriptorForProcedurePath(procedure.Path())
+	switch {
+	case methodDescriptor != nil && methodDescriptor.IsStreamingClient():
+		return handler.HandleBidiStream(
+			ctx,
+			handleEnv,
+			nil,
+			func(context.Context, func() (any, error), func(any) error) error {
+				return inputErr
+			},
+			handleOptions...,
+		)
+	case methodDescriptor != nil && methodDescriptor.IsStreamingServer():
+		return handler.HandleServerStream(
+			ctx,
+			handleEnv,
+			nil,
+			func(context.Context, any, func(any) error) error {
+				return inputErr
+			},
+			handleOptions...,
+		)
+	default:
+		return handler.Handle(
+			ctx,
+			handleEnv,
+			nil,
+			func(context.Context, any) (any, error) {
+				return nil, inputErr
+			},
+			handleOptions...,
+		)
+	}
+}
 
 type serverOptions struct {
-	doc string
+	doc                    string
+	info                   Info
+	version                string
+	stdinMode              StdinMode
+	stdinTimeout           time.Duration
+	maxStdinBytes          int64
+	nonceStore             NonceStore
+	replayWindow           time.Duration
+	sessionConcurrency     int
+	flowControlWindow      uint32
+	sessionCallObserver    func(SessionCallStats)
+	authorize              func(context.Context, string, map[string]string) error
+	procedureTimingsWriter io.Writer
+	logger                 *slog.Logger
+	redactor               Redactor
+	envDefaults            bool
+	onShutdowns            []func(context.Context)
+	formatToDeprecation    map[Format]string
 }
 
 func newServerOptions() *serverOptions {
 	return &serverOptions{}
 }
+
+type serverForHandlerOptions struct {
+	specOptions    []SpecOption
+	handlerOptions []HandlerOption
+	serverOptions  []ServerOption
+}
+
+func newServerForHandlerOptions() *serverForHandlerOptions {
+	return &serverForHandlerOptions{}
+}
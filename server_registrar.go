@@ -19,6 +19,9 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // ServerRegistrar is used to registered paths when constructing a server.
@@ -31,8 +34,42 @@ type ServerRegistrar interface {
 	//
 	// Paths must be unique.
 	Register(path string, handleFunc func(context.Context, HandleEnv, ...HandleOption) error)
+	// RegisterTyped registers the given handle function for the given path, recording the
+	// full names of the request and response messages the handle function expects.
+	//
+	// When the Server is created, the full names are validated against the input and output
+	// types of the method for the path, resolved from paths of the form "/package.Service/Method"
+	// using protoregistry.GlobalFiles. Paths whose method cannot be resolved are not validated.
+	//
+	// Generated code uses RegisterTyped. Paths must be unique.
+	RegisterTyped(
+		path string,
+		requestFullName protoreflect.FullName,
+		responseFullName protoreflect.FullName,
+		handleFunc func(context.Context, HandleEnv, ...HandleOption) error,
+	)
+
+	// RegisterDynamic registers the given function for the given unary path, with requests
+	// and responses of the given message types.
+	//
+	// This allows scripting layers and bridges to expose Procedures computed at runtime,
+	// for example with types created with dynamicpb. Requests are read into a new message of
+	// the input type. A nil response results in an empty message of the output type, and
+	// responses of any other type result in an error with CodeInternal. The HandlerOptions
+	// are used to construct the Handler for the path.
+	//
+	// The full names of the types are validated in the same manner as RegisterTyped.
+	// Paths must be unique.
+	RegisterDynamic(
+		path string,
+		inputType protoreflect.MessageType,
+		outputType protoreflect.MessageType,
+		handle func(context.Context, proto.Message) (proto.Message, error),
+		options ...HandlerOption,
+	)
 
 	pathToHandleFunc() (map[string]func(context.Context, HandleEnv, ...HandleOption) error, error)
+	pathToMessageFullNames() map[string]messageFullNames
 
 	isServerRegistrar()
 }
@@ -45,15 +82,17 @@ func NewServerRegistrar() ServerRegistrar {
 // *** PRIVATE ***
 
 type serverRegistrar struct {
-	pathToHandleFuncMap map[string]func(context.Context, HandleEnv, ...HandleOption) error
-	errs                []error
-	read                bool
-	lock                sync.Mutex
+	pathToHandleFuncMap       map[string]func(context.Context, HandleEnv, ...HandleOption) error
+	pathToMessageFullNamesMap map[string]messageFullNames
+	errs                      []error
+	read                      bool
+	lock                      sync.Mutex
 }
 
 func newServerRegistrar() *serverRegistrar {
 	return &serverRegistrar{
-		pathToHandleFuncMap: make(map[string]func(context.Context, HandleEnv, ...HandleOption) error),
+		pathToHandleFuncMap:       make(map[string]func(context.Context, HandleEnv, ...HandleOption) error),
+		pathToMessageFullNamesMap: make(map[string]messageFullNames),
 	}
 }
 
@@ -61,16 +100,78 @@ func (s *serverRegistrar) Register(path string, handleFunc func(context.Context,
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	if s.read {
-		s.errs = append(s.errs, errors.New("server registrar already used"))
+	s.register(path, handleFunc)
+}
+
+func (s *serverRegistrar) RegisterTyped(
+	path string,
+	requestFullName protoreflect.FullName,
+	responseFullName protoreflect.FullName,
+	handleFunc func(context.Context, HandleEnv, ...HandleOption) error,
+) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !requestFullName.IsValid() {
+		s.errs = append(s.errs, fmt.Errorf("invalid request message name %q for path %q", requestFullName, path))
 		return
 	}
+	if !responseFullName.IsValid() {
+		s.errs = append(s.errs, fmt.Errorf("invalid response message name %q for path %q", responseFullName, path))
+		return
+	}
+	if s.register(path, handleFunc) {
+		s.pathToMessageFullNamesMap[path] = messageFullNames{
+			request:  requestFullName,
+			response: responseFullName,
+		}
+	}
+}
 
-	if _, ok := s.pathToHandleFuncMap[path]; ok {
-		s.errs = append(s.errs, fmt.Errorf("path %q already registered", path))
+func (s *serverRegistrar) RegisterDynamic(
+	path string,
+	inputType protoreflect.MessageType,
+	outputType protoreflect.MessageType,
+	handle func(context.Context, proto.Message) (proto.Message, error),
+	options ...HandlerOption,
+) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if inputType == nil || outputType == nil {
+		s.errs = append(s.errs, fmt.Errorf("nil message type for path %q", path))
 		return
 	}
-	s.pathToHandleFuncMap[path] = handleFunc
+	if handle == nil {
+		s.errs = append(s.errs, fmt.Errorf("nil handle function for path %q", path))
+		return
+	}
+	handler := newHandler(nil, options...)
+	outputFullName := outputType.Descriptor().FullName()
+	handleFunc := func(ctx context.Context, handleEnv HandleEnv, handleOptions ...HandleOption) error {
+		return handler.Handle(
+			ctx,
+			handleEnv,
+			inputType.New().Interface(),
+			func(ctx context.Context, request any) (any, error) {
+				response, err := handle(ctx, request.(proto.Message))
+				if isNilProtoMessage(response) {
+					return outputType.New().Interface(), err
+				}
+				if responseFullName := response.ProtoReflect().Descriptor().FullName(); responseFullName != outputFullName {
+					return nil, NewErrorf(CodeInternal, "handler for %q returned response of type %q, expected %q", path, responseFullName, outputFullName)
+				}
+				return response, err
+			},
+			handleOptions...,
+		)
+	}
+	if s.register(path, handleFunc) {
+		s.pathToMessageFullNamesMap[path] = messageFullNames{
+			request:  inputType.Descriptor().FullName(),
+			response: outputFullName,
+		}
+	}
 }
 
 func (s *serverRegistrar) pathToHandleFunc() (map[string]func(context.Context, HandleEnv, ...HandleOption) error, error) {
@@ -86,4 +187,51 @@ func (s *serverRegistrar) pathToHandleFunc() (map[string]func(context.Context, H
 	return s.pathToHandleFuncMap, nil
 }
 
+func (s *serverRegistrar) pathToMessageFullNames() map[string]messageFullNames {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.pathToMessageFullNamesMap
+}
+
 func (*serverRegistrar) isServerRegistrar() {}
+
+// register registers the handle function for the path.
+//
+// Returns false if the handle function was not registered. Must be called with the lock held.
+func (s *serverRegistrar) register(path string, handleFunc func(context.Context, HandleEnv, ...HandleOption) error) bool {
+	if s.read {
+		s.errs = append(s.errs, errors.New("server registrar already used"))
+		return false
+	}
+
+	if _, ok := s.pathToHandleFuncMap[path]; ok {
+		s.errs = append(s.errs, fmt.Errorf("path %q already registered", path))
+		return false
+	}
+	s.pathToHandleFuncMap[path] = handleFunc
+	return true
+}
+
+// messageFullNames are the full names of the request and response messages
+// registered for a path with RegisterTyped.
+type messageFullNames struct {
+	request  protoreflect.FullName
+	response protoreflect.FullName
+}
+
+// validateMessageFullNames validates the registered message full names against the
+// input and output types of the method for the path, if the method can be resolved.
+func validateMessageFullNames(path string, messageFullNames messageFullNames) error {
+	methodDescriptor := methodDescriptorForProcedurePath(path)
+	if methodDescriptor == nil {
+		return nil
+	}
+	if inputFullName := methodDescriptor.Input().FullName(); inputFullName != messageFullNames.request {
+		return fmt.Errorf("path %q registered with request message %q but method %q has input %q", path, messageFullNames.request, methodDescriptor.FullName(), inputFullName)
+	}
+	if outputFullName := methodDescriptor.Output().FullName(); outputFullName != messageFullNames.response {
+		return fmt.Errorf("path %q registered with response message %q but method %q has output %q", path, messageFullNames.response, methodDescriptor.FullName(), outputFullName)
+	}
+	return nil
+}
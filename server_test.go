@@ -0,0 +1,1563 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+	"pluginrpc.com/pluginrpc/pluginrpcinfo"
+)
+
+func TestServeTimeout(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(ctx context.Context, _ any) (any, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	stdout := bytes.NewBuffer(nil)
+	err = server.Serve(
+		context.Background(),
+		Env{
+			Args:   []string{"/foo/bar", "--" + TimeoutFlagName, "10ms"},
+			Stdin:  bytes.NewReader(nil),
+			Stdout: stdout,
+			Stderr: bytes.NewBuffer(nil),
+		},
+	)
+	require.NoError(t, err)
+	err = unmarshalResponse(FormatBinary, stdout.Bytes(), nil)
+	pluginrpcError := &Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeDeadlineExceeded, pluginrpcError.Code())
+
+	err = server.Serve(
+		context.Background(),
+		Env{
+			Args:   []string{"/foo/bar", "--" + TimeoutFlagName, "-1s"},
+			Stdin:  bytes.NewReader(nil),
+			Stdout: bytes.NewBuffer(nil),
+			Stderr: bytes.NewBuffer(nil),
+		},
+	)
+	require.Error(t, err)
+}
+
+func TestClientDeadlinePropagation(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	var hasDeadline atomic.Bool
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(ctx context.Context, _ any) (any, error) {
+					_, ok := ctx.Deadline()
+					hasDeadline.Store(ok)
+					return nil, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	// Detach the context so that the deadline can only be propagated via --timeout.
+	serverRunner := NewServerRunner(server)
+	runner := runnerFunc(
+		func(_ context.Context, env Env) error {
+			return serverRunner.Run(context.Background(), env)
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	require.NoError(t, NewClient(runner).Call(ctx, "/foo/bar", nil, nil))
+	require.False(t, hasDeadline.Load())
+	client := NewClient(runner, ClientWithDeadlinePropagation())
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, nil))
+	require.False(t, hasDeadline.Load())
+	require.NoError(t, client.Call(ctx, "/foo/bar", nil, nil))
+	require.True(t, hasDeadline.Load())
+
+	expiredCtx, expiredCancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer expiredCancel()
+	err = client.Call(expiredCtx, "/foo/bar", nil, nil)
+	pluginrpcError := &Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeDeadlineExceeded, pluginrpcError.Code())
+}
+
+func TestClientMaxConcurrentProcesses(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					time.Sleep(10 * time.Millisecond)
+					return nil, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	serverRunner := NewServerRunner(server)
+	var running atomic.Int64
+	var maxRunning atomic.Int64
+	runner := runnerFunc(
+		func(ctx context.Context, env Env) error {
+			current := running.Add(1)
+			defer running.Add(-1)
+			for {
+				previous := maxRunning.Load()
+				if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			return serverRunner.Run(ctx, env)
+		},
+	)
+
+	client := NewClient(runner, ClientWithMaxConcurrentProcesses(2))
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = client.Call(context.Background(), "/foo/bar", nil, nil)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.LessOrEqual(t, maxRunning.Load(), int64(2))
+
+	// Calls waiting for an invocation respect their context.
+	startedC := make(chan struct{})
+	blockC := make(chan struct{})
+	var blocking atomic.Bool
+	blockingClient := NewClient(
+		runnerFunc(
+			func(ctx context.Context, env Env) error {
+				if blocking.CompareAndSwap(true, false) {
+					close(startedC)
+					<-blockC
+				}
+				return serverRunner.Run(ctx, env)
+			},
+		),
+		ClientWithMaxConcurrentProcesses(1),
+	)
+	// Get the Spec first, as concurrent calls wait for the first call to get the Spec.
+	_, err = blockingClient.Spec(context.Background())
+	require.NoError(t, err)
+	blocking.Store(true)
+	blockingErrC := make(chan error, 1)
+	go func() {
+		blockingErrC <- blockingClient.Call(context.Background(), "/foo/bar", nil, nil)
+	}()
+	<-startedC
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.Error(t, blockingClient.Call(ctx, "/foo/bar", nil, nil))
+	close(blockC)
+	require.NoError(t, <-blockingErrC)
+}
+
+func TestClientCallTimeout(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	client := NewClient(
+		runnerFunc(
+			func(ctx context.Context, _ Env) error {
+				<-ctx.Done()
+				return NewExitError(137, errors.New("signal: killed"))
+			},
+		),
+		ClientWithSpec(spec),
+	)
+	err = client.Call(context.Background(), "/foo/bar", nil, nil, CallWithTimeout(10*time.Millisecond))
+	require.Equal(t, CodeDeadlineExceeded, WrapError(err).Code())
+	require.Equal(t, ErrorSourceTransport, ErrorSourceOf(err))
+}
+
+func TestClientRetry(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	var handleCalls atomic.Int64
+	var handleErr atomic.Pointer[Error]
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					handleCalls.Add(1)
+					if err := handleErr.Swap(nil); err != nil {
+						return nil, err
+					}
+					return nil, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	serverRunner := NewServerRunner(server)
+	var spawnFailures atomic.Int64
+	runner := runnerFunc(
+		func(ctx context.Context, env Env) error {
+			if spawnFailures.Add(-1) >= 0 {
+				return errors.New("text file busy")
+			}
+			return serverRunner.Run(ctx, env)
+		},
+	)
+	client := NewClient(runner, ClientWithRetry(3, RetryWithInitialBackoff(time.Millisecond)))
+
+	// Spawn failures, including during discovery, are retried.
+	spawnFailures.Store(2)
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, nil))
+	require.Equal(t, int64(1), handleCalls.Load())
+
+	// Retryable Codes are retried, and the hint from the plugin is used as the backoff.
+	handleErr.Store(NewError(CodeUnavailable, errors.New("busy"), ErrorWithRetryAfter(time.Millisecond)))
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, nil))
+	require.Equal(t, int64(3), handleCalls.Load())
+
+	// Other Codes are not retried.
+	handleErr.Store(NewErrorf(CodeInvalidArgument, "bad"))
+	err = client.Call(context.Background(), "/foo/bar", nil, nil)
+	require.Equal(t, CodeInvalidArgument, WrapError(err).Code())
+	require.Equal(t, int64(4), handleCalls.Load())
+
+	// The last error is returned once attempts are exhausted.
+	spawnFailures.Store(3)
+	err = client.Call(context.Background(), "/foo/bar", nil, nil)
+	require.Equal(t, ErrorSourceSpawn, ErrorSourceOf(err))
+	require.Equal(t, int64(4), handleCalls.Load())
+}
+
+func TestClientSpecDiscovery(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return nil
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	serverRunner := NewServerRunner(server)
+	newRunner := func(invocations *int, supportsCombined bool) Runner {
+		return runnerFunc(
+			func(ctx context.Context, env Env) error {
+				*invocations++
+				if !supportsCombined && slices.Contains(env.Args, "--"+ProtocolFlagName) && slices.Contains(env.Args, "--"+SpecFlagName) {
+					return NewExitError(1, errors.New("cannot specify both --protocol and --spec"))
+				}
+				return serverRunner.Run(ctx, env)
+			},
+		)
+	}
+
+	for _, specCompression := range []bool{false, true} {
+		for _, format := range []Format{FormatBinary, FormatJSON} {
+			var invocations int
+			options := []ClientOption{ClientWithCombinedHandshake(), ClientWithFormat(format)}
+			if specCompression {
+				options = append(options, ClientWithSpecCompression())
+			}
+			clientSpec, err := NewClient(newRunner(&invocations, true), options...).Spec(context.Background())
+			require.NoError(t, err)
+			require.NotNil(t, clientSpec.ProcedureForPath("/foo/bar"))
+			require.Equal(t, 1, invocations)
+		}
+	}
+
+	var invocations int
+	client := NewClient(newRunner(&invocations, true), ClientWithSpec(spec))
+	clientSpec, err := client.Spec(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, spec, clientSpec)
+	require.NoError(t, client.Call(context.Background(), "/foo/bar", nil, nil))
+	require.Equal(t, 1, invocations)
+
+	invocations = 0
+	clientSpec, err = NewClient(newRunner(&invocations, true)).Spec(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, clientSpec.ProcedureForPath("/foo/bar"))
+	require.Equal(t, 2, invocations)
+
+	invocations = 0
+	clientSpec, err = NewClient(newRunner(&invocations, false), ClientWithCombinedHandshake()).Spec(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, clientSpec.ProcedureForPath("/foo/bar"))
+	require.Equal(t, 3, invocations)
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					return nil, NewErrorf(CodeNotFound, "not found")
+				},
+				options...,
+			)
+		},
+	)
+	serverLogs := bytes.NewBuffer(nil)
+	server, err := NewServer(
+		spec,
+		serverRegistrar,
+		ServerWithLogger(slog.New(slog.NewJSONHandler(serverLogs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	require.NoError(t, err)
+	clientLogs := bytes.NewBuffer(nil)
+	client := NewClient(
+		NewServerRunner(server),
+		ClientWithLogger(slog.New(slog.NewJSONHandler(clientLogs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	err = client.Call(context.Background(), "/foo/bar", nil, nil)
+	require.Equal(t, CodeNotFound, WrapError(err).Code())
+
+	readRecords := func(buffer *bytes.Buffer) []map[string]any {
+		var records []map[string]any
+		for _, line := range bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n")) {
+			record := make(map[string]any)
+			require.NoError(t, json.Unmarshal(line, &record))
+			records = append(records, record)
+		}
+		return records
+	}
+	// The protocol version and Spec are retrieved before the call.
+	clientRecords := readRecords(clientLogs)
+	require.Len(t, clientRecords, 6)
+	require.Equal(t, "pluginrpc call started", clientRecords[0]["msg"])
+	require.Equal(t, []any{"--protocol"}, clientRecords[0]["args"])
+	require.NotContains(t, clientRecords[0], "procedure")
+	require.Equal(t, "pluginrpc call finished", clientRecords[1]["msg"])
+	require.NotContains(t, clientRecords[1], "error")
+	require.Equal(t, "pluginrpc call started", clientRecords[4]["msg"])
+	require.Equal(t, "/foo/bar", clientRecords[4]["procedure"])
+	require.Equal(t, "binary", clientRecords[4]["format"])
+	require.Equal(t, "pluginrpc call finished", clientRecords[5]["msg"])
+	require.Equal(t, "/foo/bar", clientRecords[5]["procedure"])
+	require.Contains(t, clientRecords[5], "duration")
+	require.Equal(t, "not_found", clientRecords[5]["code"])
+	require.Contains(t, clientRecords[5]["error"], "not found")
+
+	serverRecords := readRecords(serverLogs)
+	require.Len(t, serverRecords, 6)
+	require.Equal(t, "pluginrpc serve started", serverRecords[4]["msg"])
+	require.Equal(t, "pluginrpc serve finished", serverRecords[5]["msg"])
+	require.Equal(t, "/foo/bar", serverRecords[5]["procedure"])
+	require.Equal(t, "binary", serverRecords[5]["format"])
+	require.Equal(t, "not_found", serverRecords[5]["code"])
+	require.Contains(t, serverRecords[5]["error"], "not found")
+}
+
+func TestServerHelp(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar", ProcedureWithArgs("foo", "bar"), ProcedureWithDoc("Bar the foo.\n\nThe foo is barred."))
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return nil
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar, ServerWithDoc("A plugin."))
+	require.NoError(t, err)
+	serve := func(args ...string) (string, string, error) {
+		stdout := bytes.NewBuffer(nil)
+		stderr := bytes.NewBuffer(nil)
+		err := server.Serve(context.Background(), Env{Args: args, Stdin: discardReader{}, Stdout: stdout, Stderr: stderr})
+		return stdout.String(), stderr.String(), err
+	}
+
+	stdout, stderr, err := serve("--help")
+	require.NoError(t, err)
+	require.Empty(t, stdout)
+	require.Contains(t, stderr, "A plugin.\n\nCommands:\n\n  foo bar    Bar the foo.\n")
+
+	// The help format applies regardless of the position of --help.
+	stdout, stderr, err = serve("--help", "--"+HelpFormatFlagName, "json")
+	require.NoError(t, err)
+	require.Empty(t, stderr)
+	help := make(map[string]any)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &help))
+	require.Equal(t, "A plugin.", help["doc"])
+	require.Equal(
+		t,
+		[]any{map[string]any{"path": "/foo/bar", "args": []any{"foo", "bar"}, "doc": "Bar the foo.\n\nThe foo is barred."}},
+		help["procedures"],
+	)
+	require.Contains(
+		t,
+		help["flags"],
+		map[string]any{
+			"name":    TimeoutFlagName,
+			"type":    "duration",
+			"default": "0s",
+			"usage":   "The maximum duration to allow the procedure to run. Zero means no timeout.",
+		},
+	)
+
+	_, _, err = serve("--help", "--"+HelpFormatFlagName, "xml")
+	require.ErrorContains(t, err, "invalid value for --help-format")
+
+	// Docs are only included in the Spec with --docs.
+	stdout, _, err = serve("--spec", "--format", "json")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"procedures":[{"path":"/foo/bar","args":["foo","bar"]}]}`, stdout)
+	stdout, _, err = serve("--spec", "--"+DocsFlagName, "--format", "json")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"procedures":[{"path":"/foo/bar","args":["foo","bar"],"doc":"Bar the foo.\n\nThe foo is barred."}]}`, stdout)
+	_, _, err = serve("--"+DocsFlagName, "foo", "bar")
+	require.ErrorContains(t, err, "--docs can only be specified with --spec")
+}
+
+func TestServerProcedureHelp(t *testing.T) {
+	t.Parallel()
+
+	echoRequestProcedure, err := NewProcedure(
+		testEchoRequestPath,
+		ProcedureWithArgs("echo", "request"),
+		ProcedureWithDoc("Echo the request back."),
+	)
+	require.NoError(t, err)
+	echoListProcedure, err := NewProcedure(testEchoListPath)
+	require.NoError(t, err)
+	spec, err := NewSpecWithOptions(
+		[]Procedure{echoRequestProcedure, echoListProcedure},
+		SpecWithDescriptors(examplev1.File_pluginrpc_example_v1_example_proto),
+	)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	for _, procedure := range spec.Procedures() {
+		serverRegistrar.Register(
+			procedure.Path(),
+			func(context.Context, HandleEnv, ...HandleOption) error {
+				return nil
+			},
+		)
+	}
+	server, err := NewServer(spec, serverRegistrar, ServerWithDoc("A plugin."))
+	require.NoError(t, err)
+	serve := func(args ...string) (string, string, error) {
+		stdout := bytes.NewBuffer(nil)
+		stderr := bytes.NewBuffer(nil)
+		err := server.Serve(context.Background(), Env{Args: args, Stdin: discardReader{}, Stdout: stdout, Stderr: stderr})
+		return stdout.String(), stderr.String(), err
+	}
+
+	_, stderr, err := serve("echo", "request", "--help")
+	require.NoError(t, err)
+	require.NotContains(t, stderr, "A plugin.")
+	require.Contains(
+		t,
+		stderr,
+		`Echo the request back.
+
+Usage:
+
+  echo request [flags]
+
+Request:   pluginrpc.example.v1.EchoRequestRequest
+Response:  pluginrpc.example.v1.EchoRequestResponse
+
+Example request (--format json):
+
+  {
+    "message": ""
+  }
+
+Flags:
+`,
+	)
+
+	// The path of a Procedure can be used instead of its args.
+	stdout, _, err := serve("--help", testEchoListPath, "--"+HelpFormatFlagName, "json")
+	require.NoError(t, err)
+	help := make(map[string]any)
+	require.NoError(t, json.Unmarshal([]byte(stdout), &help))
+	require.Equal(t, map[string]any{"path": testEchoListPath}, help["procedure"])
+	require.Equal(t, "pluginrpc.example.v1.EchoListRequest", help["request_type"])
+	require.Equal(t, "pluginrpc.example.v1.EchoListResponse", help["response_type"])
+	require.Equal(t, map[string]any{}, help["example_request"])
+	require.NotEmpty(t, help["flags"])
+
+	// Unknown args print the help for the plugin.
+	_, stderr, err = serve("echo", "unknown", "--help")
+	require.NoError(t, err)
+	require.Contains(t, stderr, "A plugin.\n\nCommands:\n\n")
+
+	// Without descriptors, the help does not include the messages.
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err = NewSpec(procedure)
+	require.NoError(t, err)
+	serverRegistrar = NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return nil
+		},
+	)
+	server, err = NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	_, stderr, err = serve("/foo/bar", "--help")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(stderr, "Usage:\n\n  /foo/bar [flags]\n\nFlags:\n"), stderr)
+}
+
+func TestNewExampleRequestJSON(t *testing.T) {
+	t.Parallel()
+
+	data, err := newExampleRequestJSON((&extv1.MetadataValue{}).ProtoReflect().Descriptor())
+	require.NoError(t, err)
+	// Enums are set to their first value, and maps and lists have a single example element.
+	require.JSONEq(
+		t,
+		`{"value":{},"metadata":{"":""},"warnings":[{"kind":"WARNING_KIND_UNSPECIFIED","message":""}]}`,
+		string(data),
+	)
+	data, err = newExampleRequestJSON((&extv1.ErrorDetails{}).ProtoReflect().Descriptor())
+	require.NoError(t, err)
+	// Well-known types use their JSON representations.
+	require.JSONEq(
+		t,
+		`{"retry_after":"0s","localized_messages":{"":""},"joined_errors":[{"code":0,"message":""}],"details":[{}]}`,
+		string(data),
+	)
+}
+
+func TestDecompressSpecLimits(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("a"), 1<<20)
+	compressed, err := compressSpec(data)
+	require.NoError(t, err)
+	decompressed, err := decompressSpec(compressed, int64(len(data)), 1<<20)
+	require.NoError(t, err)
+	require.Equal(t, data, decompressed)
+	_, err = decompressSpec(compressed, int64(len(data)-1), 1<<20)
+	require.EqualError(t, err, "Failed with code resource_exhausted: decompressed data exceeds 1048575 bytes")
+	// Repeated data compresses by far more than a ratio of 100.
+	_, err = decompressSpec(compressed, defaultMaxDecompressedBytes, 100)
+	require.EqualError(t, err, "Failed with code resource_exhausted: decompressed data exceeds 100 times the size of the compressed data")
+	// There is no ratio limit by default.
+	decompressed, err = decompressSpec(compressed, defaultMaxDecompressedBytes, 0)
+	require.NoError(t, err)
+	require.Equal(t, data, decompressed)
+	// Uncompressed data is not limited.
+	decompressed, err = decompressSpec(data, 1, 1)
+	require.NoError(t, err)
+	require.Equal(t, data, decompressed)
+}
+
+func TestEnvDefaults(t *testing.T) { //nolint:paralleltest // t.Setenv cannot be used with t.Parallel
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					return nil, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar, ServerWithEnvDefaults())
+	require.NoError(t, err)
+	serverRunner := NewServerRunner(server)
+	var allArgs [][]string
+	runner := runnerFunc(
+		func(ctx context.Context, env Env) error {
+			allArgs = append(allArgs, env.Args)
+			return serverRunner.Run(ctx, env)
+		},
+	)
+
+	t.Setenv(FormatEnvVarName, "json")
+	t.Setenv(ProtocolEnvVarName, "1")
+	require.NoError(t, NewClient(runner, ClientWithEnvDefaults()).Call(context.Background(), "/foo/bar", nil, nil))
+	// The protocol version is not checked.
+	require.Equal(
+		t,
+		[][]string{
+			{"--spec", "--format", "json"},
+			{"/foo/bar", "--format", "json"},
+		},
+		allArgs,
+	)
+	// Explicit options take precedence.
+	allArgs = nil
+	require.NoError(t, NewClient(runner, ClientWithEnvDefaults(), ClientWithFormat(FormatBinary)).Call(context.Background(), "/foo/bar", nil, nil))
+	require.Equal(t, []string{"/foo/bar", "--format", "binary"}, allArgs[len(allArgs)-1])
+	// The server uses the Format as the default of --format.
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(t, server.Serve(context.Background(), Env{Args: []string{"/foo/bar"}, Stdin: discardReader{}, Stdout: stdout, Stderr: io.Discard}))
+	require.Equal(t, "{}", stdout.String())
+
+	t.Setenv(ProtocolEnvVarName, "2")
+	err = NewClient(runner, ClientWithEnvDefaults()).Call(context.Background(), "/foo/bar", nil, nil)
+	require.ErrorContains(t, err, "unsupported protocol version for PLUGINRPC_PROTOCOL: 2")
+	err = server.Serve(context.Background(), Env{Args: []string{"/foo/bar"}, Stdin: discardReader{}, Stdout: io.Discard, Stderr: io.Discard})
+	require.ErrorContains(t, err, "unsupported protocol version for PLUGINRPC_PROTOCOL: 2")
+}
+
+func TestServerOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(ctx context.Context, _ any) (any, error) {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+				options...,
+			)
+		},
+	)
+	type contextKey struct{}
+	var shutdowns []string
+	server, err := NewServer(
+		spec,
+		serverRegistrar,
+		ServerWithOnShutdown(
+			func(ctx context.Context) {
+				// The context is not cancelled, but has the values of the context given to Serve.
+				require.NoError(t, ctx.Err())
+				shutdowns = append(shutdowns, ctx.Value(contextKey{}).(string))
+			},
+		),
+		ServerWithOnShutdown(
+			func(context.Context) {
+				shutdowns = append(shutdowns, "second")
+			},
+		),
+	)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "first"))
+	cancel()
+	_ = server.Serve(ctx, Env{Args: []string{"/foo/bar"}, Stdin: discardReader{}, Stdout: io.Discard, Stderr: io.Discard})
+	require.Equal(t, []string{"first", "second"}, shutdowns)
+}
+
+func TestServerAuthorizer(t *testing.T) {
+	t.Parallel()
+
+	newProcedure := func(path string) Procedure {
+		procedure, err := NewProcedure(path)
+		require.NoError(t, err)
+		return procedure
+	}
+	spec, err := NewSpec(newProcedure("/foo/safe"), newProcedure("/foo/dangerous"))
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	var handled atomic.Int64
+	for _, path := range []string{"/foo/safe", "/foo/dangerous"} {
+		serverRegistrar.Register(
+			path,
+			func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+				return handler.Handle(
+					ctx,
+					handleEnv,
+					nil,
+					func(context.Context, any) (any, error) {
+						handled.Add(1)
+						return nil, nil
+					},
+					options...,
+				)
+			},
+		)
+	}
+	server, err := NewServer(
+		spec,
+		serverRegistrar,
+		ServerWithAuthorizer(
+			func(_ context.Context, procedurePath string, metadata map[string]string) error {
+				switch {
+				case procedurePath == "/foo/safe":
+					return nil
+				case metadata["token"] == "secret":
+					return nil
+				case metadata["token"] == "":
+					return NewErrorf(CodeUnauthenticated, "no token")
+				default:
+					return errors.New("invalid token")
+				}
+			},
+		),
+	)
+	require.NoError(t, err)
+	client := NewClient(NewServerRunner(server))
+	requireCode := func(expected Code, err error) {
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, expected, pluginrpcError.Code())
+	}
+
+	require.NoError(t, client.Call(context.Background(), "/foo/safe", nil, nil))
+	requireCode(CodeUnauthenticated, client.Call(context.Background(), "/foo/dangerous", nil, nil))
+	requireCode(
+		CodePermissionDenied,
+		client.Call(context.Background(), "/foo/dangerous", nil, nil, CallWithMetadata(map[string]string{"token": "wrong"})),
+	)
+	require.Equal(t, int64(1), handled.Load())
+	require.NoError(
+		t,
+		client.Call(context.Background(), "/foo/dangerous", nil, nil, CallWithMetadata(map[string]string{"token": "secret"})),
+	)
+	require.Equal(t, int64(2), handled.Load())
+}
+
+func TestServerRegisterDynamic(t *testing.T) {
+	t.Parallel()
+
+	const echoRequestPath = "/pluginrpc.example.v1.EchoService/EchoRequest"
+	requestType := dynamicpb.NewMessageType((&examplev1.EchoRequestRequest{}).ProtoReflect().Descriptor())
+	responseType := dynamicpb.NewMessageType((&examplev1.EchoRequestResponse{}).ProtoReflect().Descriptor())
+	procedure, err := NewProcedure(echoRequestPath)
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	newClient := func(handle func(context.Context, proto.Message) (proto.Message, error)) Client {
+		serverRegistrar := NewServerRegistrar()
+		serverRegistrar.RegisterDynamic(echoRequestPath, requestType, responseType, handle)
+		server, err := NewServer(spec, serverRegistrar)
+		require.NoError(t, err)
+		return NewClient(NewServerRunner(server))
+	}
+
+	client := newClient(
+		func(_ context.Context, request proto.Message) (proto.Message, error) {
+			messageField := request.ProtoReflect().Descriptor().Fields().ByName("message")
+			response := responseType.New()
+			response.Set(
+				response.Descriptor().Fields().ByName("message"),
+				protoreflect.ValueOfString("dynamic "+request.ProtoReflect().Get(messageField).String()),
+			)
+			return response.Interface(), nil
+		},
+	)
+	response := &examplev1.EchoRequestResponse{}
+	require.NoError(t, client.Call(context.Background(), echoRequestPath, &examplev1.EchoRequestRequest{Message: "foo"}, response))
+	require.Equal(t, "dynamic foo", response.GetMessage())
+
+	client = newClient(
+		func(context.Context, proto.Message) (proto.Message, error) {
+			return nil, nil
+		},
+	)
+	response = &examplev1.EchoRequestResponse{}
+	require.NoError(t, client.Call(context.Background(), echoRequestPath, &examplev1.EchoRequestRequest{Message: "foo"}, response))
+	require.Equal(t, "", response.GetMessage())
+
+	client = newClient(
+		func(context.Context, proto.Message) (proto.Message, error) {
+			return &examplev1.EchoListResponse{}, nil
+		},
+	)
+	err = client.Call(context.Background(), echoRequestPath, &examplev1.EchoRequestRequest{}, &examplev1.EchoRequestResponse{})
+	pluginrpcError := &Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeInternal, pluginrpcError.Code())
+
+	// The types are validated against the method for the path.
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.RegisterDynamic(
+		echoRequestPath,
+		responseType,
+		responseType,
+		func(context.Context, proto.Message) (proto.Message, error) { return nil, nil },
+	)
+	_, err = NewServer(spec, serverRegistrar)
+	require.Error(t, err)
+}
+
+func TestServerRegisterTyped(t *testing.T) {
+	t.Parallel()
+
+	// Ensure the example descriptors are registered in protoregistry.GlobalFiles.
+	_ = examplev1.File_pluginrpc_example_v1_example_proto
+	const echoRequestPath = "/pluginrpc.example.v1.EchoService/EchoRequest"
+	newServer := func(path string, requestFullName, responseFullName protoreflect.FullName) error {
+		procedure, err := NewProcedure(path)
+		require.NoError(t, err)
+		spec, err := NewSpec(procedure)
+		require.NoError(t, err)
+		serverRegistrar := NewServerRegistrar()
+		serverRegistrar.RegisterTyped(
+			path,
+			requestFullName,
+			responseFullName,
+			func(context.Context, HandleEnv, ...HandleOption) error { return nil },
+		)
+		_, err = NewServer(spec, serverRegistrar)
+		return err
+	}
+
+	require.NoError(t, newServer(echoRequestPath, "pluginrpc.example.v1.EchoRequestRequest", "pluginrpc.example.v1.EchoRequestResponse"))
+	require.Error(t, newServer(echoRequestPath, "pluginrpc.example.v1.EchoListRequest", "pluginrpc.example.v1.EchoRequestResponse"))
+	require.Error(t, newServer(echoRequestPath, "pluginrpc.example.v1.EchoRequestRequest", "pluginrpc.example.v1.EchoListResponse"))
+	require.Error(t, newServer(echoRequestPath, "", "pluginrpc.example.v1.EchoRequestResponse"))
+	// Paths that cannot be resolved to a method are not validated.
+	require.NoError(t, newServer("/foo/bar", "foo.Request", "foo.Response"))
+}
+
+func TestServeSpecCompress(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return nil
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	stdout := bytes.NewBuffer(nil)
+	err = server.Serve(
+		context.Background(),
+		Env{
+			Args:   []string{"--" + SpecFlagName, "--" + CompressFlagName},
+			Stdin:  bytes.NewReader(nil),
+			Stdout: stdout,
+			Stderr: bytes.NewBuffer(nil),
+		},
+	)
+	require.NoError(t, err)
+	data := stdout.Bytes()
+	require.NotEmpty(t, data)
+	require.Equal(t, compressedSpecHeaderByte, data[0])
+	data, err = decompressSpec(data, defaultMaxDecompressedBytes, 0)
+	require.NoError(t, err)
+	protoSpec := &pluginrpcv1.Spec{}
+	require.NoError(t, unmarshalSpec(FormatBinary, data, protoSpec))
+	require.Equal(t, "/foo/bar", protoSpec.GetProcedures()[0].GetPath())
+
+	err = server.Serve(
+		context.Background(),
+		Env{
+			Args:   []string{"--" + ProtocolFlagName, "--" + CompressFlagName},
+			Stdin:  bytes.NewReader(nil),
+			Stdout: bytes.NewBuffer(nil),
+			Stderr: bytes.NewBuffer(nil),
+		},
+	)
+	require.Error(t, err)
+}
+
+func TestServeDisabled(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar", ProcedureWithArgs("foo", "bar"), ProcedureWithDisabled())
+	require.NoError(t, err)
+	enabledProcedure, err := NewProcedure("/foo/baz")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure, enabledProcedure)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	for _, path := range []string{"/foo/bar", "/foo/baz"} {
+		serverRegistrar.Register(
+			path,
+			func(context.Context, HandleEnv, ...HandleOption) error {
+				return errors.New("should not be called")
+			},
+		)
+	}
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	for _, args := range [][]string{{"/foo/bar"}, {"foo", "bar"}} {
+		for _, binaryHeader := range []bool{false, true} {
+			var stdin []byte
+			if binaryHeader {
+				stdin = addBinaryHeader(nil)
+			}
+			stdout := bytes.NewBuffer(nil)
+			err = server.Serve(
+				context.Background(),
+				Env{
+					Args:   args,
+					Stdin:  bytes.NewReader(stdin),
+					Stdout: stdout,
+					Stderr: bytes.NewBuffer(nil),
+				},
+			)
+			require.NoError(t, err)
+			// The response has the binary header if the request had it.
+			require.Equal(t, binaryHeader, bytes.HasPrefix(stdout.Bytes(), binaryHeaderMagic))
+			err = unmarshalResponse(FormatBinary, stdout.Bytes(), nil)
+			pluginrpcError := &Error{}
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, CodeUnimplemented, pluginrpcError.Code())
+		}
+	}
+
+	// Clients reject Specs without Procedures.
+	spec, err = NewSpec(procedure)
+	require.NoError(t, err)
+	require.Empty(t, NewProtoSpec(spec).GetProcedures())
+	serverRegistrar = NewServerRegistrar()
+	serverRegistrar.Register("/foo/bar", func(context.Context, HandleEnv, ...HandleOption) error { return nil })
+	_, err = NewServer(spec, serverRegistrar)
+	require.EqualError(t, err, "all procedures are disabled, at least one procedure must be enabled")
+}
+
+func TestServeErrorDetails(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					return nil, NewError(CodeUnavailable, errors.New("busy"), ErrorWithRetryAfter(time.Second))
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		for _, errorDetails := range []bool{true, false} {
+			args := []string{"/foo/bar", "--" + FormatFlagName, format.String()}
+			if errorDetails {
+				args = append(args, "--"+ErrorDetailsFlagName)
+			}
+			stdout := bytes.NewBuffer(nil)
+			err = server.Serve(
+				context.Background(),
+				Env{
+					Args:   args,
+					Stdin:  bytes.NewReader(nil),
+					Stdout: stdout,
+					Stderr: bytes.NewBuffer(nil),
+				},
+			)
+			require.NoError(t, err)
+			err = unmarshalResponse(format, stdout.Bytes(), nil)
+			pluginrpcError := &Error{}
+			require.ErrorAs(t, err, &pluginrpcError)
+			require.Equal(t, CodeUnavailable, pluginrpcError.Code())
+			if errorDetails {
+				require.Equal(t, time.Second, pluginrpcError.RetryAfter())
+			} else {
+				// Clients that did not ask for error details must not receive a response value.
+				require.Zero(t, pluginrpcError.RetryAfter())
+				protoResponse := &pluginrpcv1.Response{}
+				codec, err := codecForFormat(format)
+				require.NoError(t, err)
+				require.NoError(t, codec.Unmarshal(stdout.Bytes(), protoResponse))
+				require.Nil(t, protoResponse.GetValue())
+			}
+		}
+	}
+}
+
+func TestServeBinaryHeader(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				&pluginrpcv1.Procedure{},
+				func(_ context.Context, request any) (any, error) {
+					return request, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	requestData, err := marshalRequest(FormatBinary, &pluginrpcv1.Procedure{Path: "/foo/bar"})
+	require.NoError(t, err)
+	for _, binaryHeader := range []bool{true, false} {
+		stdinData := requestData
+		if binaryHeader {
+			stdinData = addBinaryHeader(requestData)
+		}
+		stdout := bytes.NewBuffer(nil)
+		err = server.Serve(
+			context.Background(),
+			Env{
+				Args:   []string{"/foo/bar"},
+				Stdin:  bytes.NewReader(stdinData),
+				Stdout: stdout,
+				Stderr: bytes.NewBuffer(nil),
+			},
+		)
+		require.NoError(t, err)
+		require.Equal(t, binaryHeader, bytes.HasPrefix(stdout.Bytes(), binaryHeaderMagic))
+		response := &pluginrpcv1.Procedure{}
+		require.NoError(t, unmarshalResponse(FormatBinary, stdout.Bytes(), response))
+		require.Equal(t, "/foo/bar", response.GetPath())
+	}
+
+	// Data that is not a pluginrpc payload results in a helpful error.
+	for _, stdinData := range [][]byte{{0x00, 0x01, 0x02}, append(bytes.Clone(binaryHeaderMagic), 0x02)} {
+		stdout := bytes.NewBuffer(nil)
+		err = server.Serve(
+			context.Background(),
+			Env{
+				Args:   []string{"/foo/bar"},
+				Stdin:  bytes.NewReader(stdinData),
+				Stdout: stdout,
+				Stderr: bytes.NewBuffer(nil),
+			},
+		)
+		require.NoError(t, err)
+		err = unmarshalResponse(FormatBinary, stdout.Bytes(), nil)
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, CodeInvalidArgument, pluginrpcError.Code())
+	}
+}
+
+func TestServeSerialized(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar", ProcedureWithSerialized())
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	var running int32
+	var maxRunning int32
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				previous := atomic.LoadInt32(&maxRunning)
+				if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			assert.NoError(
+				t,
+				server.Serve(
+					context.Background(),
+					Env{
+						Args:   []string{"/foo/bar"},
+						Stdin:  bytes.NewReader(nil),
+						Stdout: bytes.NewBuffer(nil),
+						Stderr: bytes.NewBuffer(nil),
+					},
+				),
+			)
+		}()
+	}
+	waitGroup.Wait()
+	require.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+}
+
+func TestServePartialResult(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				&pluginrpcv1.Procedure{},
+				func(_ context.Context, request any) (any, error) {
+					switch path := request.(*pluginrpcv1.Procedure).GetPath(); path {
+					case "partial":
+						return &pluginrpcv1.Procedure{Path: path}, NewError(CodeAborted, errors.New("partial"), ErrorWithPartialResult())
+					case "dropped":
+						return &pluginrpcv1.Procedure{Path: path}, NewError(CodeAborted, errors.New("dropped"))
+					case "details":
+						return &pluginrpcv1.Procedure{Path: path}, NewError(
+							CodeUnavailable,
+							errors.New("details"),
+							ErrorWithPartialResult(),
+							ErrorWithRetryAfter(5*time.Second),
+							ErrorWithDetails(&pluginrpcv1.Procedure{Path: "detail"}),
+						)
+					default:
+						var response *pluginrpcv1.Procedure
+						return response, NewError(CodeAborted, errors.New("nil"), ErrorWithPartialResult())
+					}
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		client := NewClient(NewServerRunner(server), ClientWithFormat(format))
+		response := &pluginrpcv1.Procedure{}
+		err = client.Call(context.Background(), "/foo/bar", &pluginrpcv1.Procedure{Path: "partial"}, response)
+		require.True(t, HasPartialResult(err))
+		require.Equal(t, CodeAborted, WrapError(err).Code())
+		require.Equal(t, "partial", response.GetPath())
+
+		response = &pluginrpcv1.Procedure{}
+		err = client.Call(context.Background(), "/foo/bar", &pluginrpcv1.Procedure{Path: "dropped"}, response)
+		require.Error(t, err)
+		require.False(t, HasPartialResult(err))
+		require.Empty(t, response.GetPath())
+
+		err = client.Call(context.Background(), "/foo/bar", &pluginrpcv1.Procedure{Path: "nil"}, response)
+		require.Error(t, err)
+		require.False(t, HasPartialResult(err))
+
+		// Error details are sent together with the partial result.
+		client = NewClient(NewServerRunner(server), ClientWithFormat(format), ClientWithErrorDetails())
+		response = &pluginrpcv1.Procedure{}
+		err = client.Call(context.Background(), "/foo/bar", &pluginrpcv1.Procedure{Path: "details"}, response)
+		require.True(t, HasPartialResult(err))
+		require.Equal(t, CodeUnavailable, WrapError(err).Code())
+		require.Equal(t, "details", response.GetPath())
+		require.Equal(t, 5*time.Second, WrapError(err).RetryAfter())
+		require.Len(t, WrapError(err).Details(), 1)
+	}
+}
+
+type runnerFunc func(ctx context.Context, env Env) error
+
+func (r runnerFunc) Run(ctx context.Context, env Env) error {
+	return r(ctx, env)
+}
+
+func TestWarnings(t *testing.T) {
+	t.Parallel()
+
+	unaryProcedure, err := NewProcedure("/foo/unary", ProcedureWithDeprecation("use /foo/new"))
+	require.NoError(t, err)
+	streamProcedure, err := NewProcedure("/foo/stream", ProcedureWithDeprecation(""))
+	require.NoError(t, err)
+	newProcedure, err := NewProcedure("/foo/new")
+	require.NoError(t, err)
+	spec, err := NewSpec(unaryProcedure, streamProcedure, newProcedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	for _, path := range []string{"/foo/unary", "/foo/new"} {
+		serverRegistrar.Register(
+			path,
+			func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+				return handler.Handle(
+					ctx,
+					handleEnv,
+					nil,
+					func(context.Context, any) (any, error) {
+						return &pluginrpcv1.Procedure{Path: "/foo"}, nil
+					},
+					options...,
+				)
+			},
+		)
+	}
+	serverRegistrar.Register(
+		"/foo/stream",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.HandleServerStream(
+				ctx,
+				handleEnv,
+				nil,
+				func(_ context.Context, _ any, send func(any) error) error {
+					for i := 0; i < 2; i++ {
+						if err := send(&pluginrpcv1.Procedure{Path: "/foo"}); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar, ServerWithDeprecatedFormat(FormatJSON, ""))
+	require.NoError(t, err)
+
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		var lock sync.Mutex
+		var warnings []Warning
+		client := NewClient(
+			NewServerRunner(server),
+			ClientWithFormat(format),
+			ClientWithWarningHandler(
+				func(warning Warning) {
+					lock.Lock()
+					defer lock.Unlock()
+					warnings = append(warnings, warning)
+				},
+			),
+		)
+		getWarnings := func() []Warning {
+			lock.Lock()
+			defer lock.Unlock()
+			result := warnings
+			warnings = nil
+			return result
+		}
+		var expectedFormatWarnings []Warning
+		if format == FormatJSON {
+			expectedFormatWarnings = []Warning{
+				{Kind: WarningKindDeprecatedFormat, Message: `format "json" is deprecated`},
+			}
+		}
+		withProcedure := func(procedurePath string, warnings ...Warning) []Warning {
+			var result []Warning
+			for _, warning := range append(warnings, expectedFormatWarnings...) {
+				warning.Procedure = procedurePath
+				result = append(result, warning)
+			}
+			return result
+		}
+
+		response := &pluginrpcv1.Procedure{}
+		require.NoError(t, client.Call(context.Background(), "/foo/unary", nil, response))
+		require.Equal(t, "/foo", response.GetPath())
+		require.Equal(
+			t,
+			withProcedure("/foo/unary", Warning{Kind: WarningKindDeprecatedProcedure, Message: "use /foo/new"}),
+			getWarnings(),
+		)
+
+		var paths []string
+		require.NoError(
+			t,
+			client.CallServerStream(
+				context.Background(),
+				"/foo/stream",
+				nil,
+				func() any { return &pluginrpcv1.Procedure{} },
+				func(response any) error {
+					paths = append(paths, response.(*pluginrpcv1.Procedure).GetPath())
+					return nil
+				},
+			),
+		)
+		require.Equal(t, []string{"/foo", "/foo"}, paths)
+		// Warnings are only sent once per call.
+		require.Equal(
+			t,
+			withProcedure("/foo/stream", Warning{Kind: WarningKindDeprecatedProcedure, Message: `procedure "/foo/stream" is deprecated`}),
+			getWarnings(),
+		)
+
+		require.NoError(t, client.Call(context.Background(), "/foo/new", nil, response))
+		require.Equal(t, withProcedure("/foo/new"), getWarnings())
+
+		// Warnings are not sent unless requested.
+		require.NoError(t, NewClient(NewServerRunner(server), ClientWithFormat(format)).Call(context.Background(), "/foo/unary", nil, response))
+		require.Equal(t, "/foo", response.GetPath())
+	}
+}
+
+func TestServeRenamedFrom(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure(
+		"/foo/new",
+		ProcedureWithArgs("new", "cmd"),
+		ProcedureWithRenamedFrom("/foo/old", "old", "cmd"),
+		ProcedureWithRenamedFrom("/foo/older"),
+	)
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	require.Len(t, spec.Procedures(), 1)
+	require.Equal(t, []string{"old", "cmd"}, spec.ProcedureForPath("/foo/old").Args())
+	var protoPaths []string
+	for _, protoProcedure := range NewProtoSpec(spec).GetProcedures() {
+		protoPaths = append(protoPaths, protoProcedure.GetPath())
+	}
+	require.Equal(t, []string{"/foo/new", "/foo/old", "/foo/older"}, protoPaths)
+	otherProcedure, err := NewProcedure("/foo/old")
+	require.NoError(t, err)
+	_, err = NewSpec(procedure, otherProcedure)
+	require.ErrorContains(t, err, "duplicate procedure path")
+	_, err = NewProcedure("/foo/new", ProcedureWithRenamedFrom("/foo/old", "-"))
+	require.Error(t, err)
+
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/new",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.Handle(
+				ctx,
+				handleEnv,
+				nil,
+				func(context.Context, any) (any, error) {
+					return &pluginrpcv1.Procedure{Path: "/foo"}, nil
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	var warnings []Warning
+	for _, client := range []Client{
+		NewClient(
+			NewServerRunner(server),
+			ClientWithWarningHandler(func(warning Warning) { warnings = append(warnings, warning) }),
+		),
+		NewClient(
+			NewServerRunner(server),
+			ClientWithSpec(spec),
+			ClientWithWarningHandler(func(warning Warning) { warnings = append(warnings, warning) }),
+		),
+	} {
+		warnings = nil
+		for _, path := range []string{"/foo/new", "/foo/old", "/foo/older"} {
+			response := &pluginrpcv1.Procedure{}
+			require.NoError(t, client.Call(context.Background(), path, nil, response))
+			require.Equal(t, "/foo", response.GetPath())
+		}
+		require.Equal(
+			t,
+			[]Warning{
+				{
+					Kind:      WarningKindDeprecatedProcedure,
+					Procedure: "/foo/old",
+					Message:   `procedure "/foo/old" was renamed to "/foo/new"`,
+				},
+				{
+					Kind:      WarningKindDeprecatedProcedure,
+					Procedure: "/foo/older",
+					Message:   `procedure "/foo/older" was renamed to "/foo/new"`,
+				},
+			},
+			warnings,
+		)
+	}
+}
+
+func TestServerWithVersion(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(context.Context, HandleEnv, ...HandleOption) error {
+			return errors.New("should not be called")
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar, ServerWithVersion("v1.2.3"))
+	require.NoError(t, err)
+
+	stdout := bytes.NewBuffer(nil)
+	require.NoError(t, server.Serve(context.Background(), Env{Args: []string{"--" + VersionFlagName}, Stdin: discardReader{}, Stdout: stdout, Stderr: io.Discard}))
+	require.Equal(t, "v1.2.3\n"+pluginrpcinfo.Get().String()+"\n", stdout.String())
+	require.True(t, pluginrpcinfo.SupportsProtocolVersion(protocolVersion))
+	err = server.Serve(context.Background(), Env{Args: []string{"--" + VersionFlagName, "--" + InfoFlagName}, Stdin: discardReader{}, Stdout: io.Discard, Stderr: io.Discard})
+	require.ErrorContains(t, err, "--version can only be specified alone or with --spec")
+
+	for _, format := range AllFormats {
+		clientSpec, err := NewClient(NewServerRunner(server), ClientWithFormat(format), ClientWithSpecVersion()).Spec(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "v1.2.3", clientSpec.Version())
+		require.NotNil(t, clientSpec.ProcedureForPath("/foo/bar"))
+		// The version is only returned if requested.
+		clientSpec, err = NewClient(NewServerRunner(server), ClientWithFormat(format)).Spec(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, clientSpec.Version())
+	}
+
+	server, err = NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+	err = server.Serve(context.Background(), Env{Args: []string{"--" + VersionFlagName}, Stdin: discardReader{}, Stdout: io.Discard, Stderr: io.Discard})
+	require.ErrorContains(t, err, "plugin does not have a version")
+	clientSpec, err := NewClient(NewServerRunner(server), ClientWithSpecVersion()).Spec(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, clientSpec.Version())
+}
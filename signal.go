@@ -0,0 +1,110 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"os"
+	"slices"
+)
+
+// SignalStrategy selects the signals that Main handles as interrupts.
+//
+// When any of the signals are sent, the context passed to the Server is cancelled.
+type SignalStrategy interface {
+	// Signals returns the signals to handle as interrupts.
+	Signals() []os.Signal
+
+	isSignalStrategy()
+}
+
+// SignalStrategyForSignals returns a new SignalStrategy that handles the given signals
+// on all platforms.
+//
+// If no signals are given, signals are not handled.
+func SignalStrategyForSignals(signals ...os.Signal) SignalStrategy {
+	return &signalStrategy{
+		signals: slices.Clone(signals),
+	}
+}
+
+// NewOSSignalStrategy returns a new SignalStrategy for the current platform.
+//
+// By default, os.Interrupt is handled on all platforms, which is SIGINT on unix-like
+// platforms and Ctrl+C or Ctrl+Break on Windows. On unix-like platforms, syscall.SIGTERM
+// is also handled.
+//
+// This is the SignalStrategy that Main uses by default.
+func NewOSSignalStrategy(options ...OSSignalStrategyOption) SignalStrategy {
+	osSignalStrategyOptions := newOSSignalStrategyOptions()
+	for _, option := range options {
+		option(osSignalStrategyOptions)
+	}
+	signals := append(
+		[]os.Signal{
+			os.Interrupt,
+		},
+		osExtraInterruptSignals(osSignalStrategyOptions.consoleCtrlEvents)...,
+	)
+	return &signalStrategy{
+		signals: append(signals, osSignalStrategyOptions.extraSignals...),
+	}
+}
+
+// OSSignalStrategyOption is an option for NewOSSignalStrategy.
+type OSSignalStrategyOption func(*osSignalStrategyOptions)
+
+// OSSignalStrategyWithConsoleCtrlEvents returns a new OSSignalStrategyOption that also
+// handles the Windows console control events that close the console, log off the user,
+// or shut down the system.
+//
+// These are CTRL_CLOSE_EVENT, CTRL_LOGOFF_EVENT, and CTRL_SHUTDOWN_EVENT, which Go
+// delivers as syscall.SIGTERM. Windows terminates the process shortly after these events,
+// so Servers should shut down promptly.
+//
+// This has no effect on other platforms, where syscall.SIGTERM is already handled.
+func OSSignalStrategyWithConsoleCtrlEvents() OSSignalStrategyOption {
+	return func(osSignalStrategyOptions *osSignalStrategyOptions) {
+		osSignalStrategyOptions.consoleCtrlEvents = true
+	}
+}
+
+// OSSignalStrategyWithExtraSignals returns a new OSSignalStrategyOption that also handles
+// the given signals, for example syscall.SIGHUP on unix-like platforms.
+func OSSignalStrategyWithExtraSignals(signals ...os.Signal) OSSignalStrategyOption {
+	return func(osSignalStrategyOptions *osSignalStrategyOptions) {
+		osSignalStrategyOptions.extraSignals = append(osSignalStrategyOptions.extraSignals, signals...)
+	}
+}
+
+// *** PRIVATE ***
+
+type signalStrategy struct {
+	signals []os.Signal
+}
+
+func (s *signalStrategy) Signals() []os.Signal {
+	return slices.Clone(s.signals)
+}
+
+func (*signalStrategy) isSignalStrategy() {}
+
+type osSignalStrategyOptions struct {
+	consoleCtrlEvents bool
+	extraSignals      []os.Signal
+}
+
+func newOSSignalStrategyOptions() *osSignalStrategyOptions {
+	return &osSignalStrategyOptions{}
+}
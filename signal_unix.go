@@ -0,0 +1,33 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix || js || wasip1
+
+package pluginrpc
+
+import (
+	"os"
+	"syscall"
+)
+
+// osExtraInterruptSignals returns the signals beyond os.Interrupt that are handled
+// as interrupts by default.
+//
+// For unix-like platforms, this is syscall.SIGTERM. Console control events only exist
+// on Windows, so consoleCtrlEvents has no effect.
+func osExtraInterruptSignals(bool) []os.Signal {
+	return []os.Signal{
+		syscall.SIGTERM,
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package pluginrpc
+
+import (
+	"os"
+	"syscall"
+)
+
+// osExtraInterruptSignals returns the signals beyond os.Interrupt that are handled
+// as interrupts by default.
+//
+// For Windows, os.Interrupt already covers Ctrl+C and Ctrl+Break. If consoleCtrlEvents
+// is set, this adds syscall.SIGTERM, which Go delivers for CTRL_CLOSE_EVENT,
+// CTRL_LOGOFF_EVENT, and CTRL_SHUTDOWN_EVENT.
+func osExtraInterruptSignals(consoleCtrlEvents bool) []os.Signal {
+	if consoleCtrlEvents {
+		return []os.Signal{
+			syscall.SIGTERM,
+		}
+	}
+	return nil
+}
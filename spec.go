@@ -19,6 +19,10 @@ import (
 	"slices"
 
 	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
 )
 
 // Spec specifies a set of Procedures that a plugin implements. This describes
@@ -30,19 +34,87 @@ import (
 type Spec interface {
 	// ProcedureForPath returns the Procedure for the given path.
 	//
+	// If the path is the path of a Procedure that a Procedure was renamed from, the
+	// Procedure that it was renamed from is returned, see ProcedureWithRenamedFrom.
+	//
 	// If no such procedure exists, this returns nil.
 	ProcedureForPath(path string) Procedure
-	// Procedures returns all Procedures.
+	// Procedures returns all Procedures, including disabled Procedures.
 	//
 	// Never empty.
 	Procedures() []Procedure
+	// FileDescriptorSet returns the files that define the request and response types of
+	// the Procedures, and all of their dependencies, in topological order.
+	//
+	// Plugins return this with the Spec when --descriptors is specified alongside --spec,
+	// see SpecWithDescriptors and ClientWithSpecDescriptors.
+	//
+	// If the Spec has no descriptors, this returns nil.
+	FileDescriptorSet() *descriptorpb.FileDescriptorSet
+	// Version returns the version of the plugin.
+	//
+	// Plugins return this with the Spec when --version is specified alongside --spec,
+	// see ServerWithVersion and ClientWithSpecVersion.
+	//
+	// If the version is unknown, this returns the empty string.
+	Version() string
+	// Compressions returns the Compressions that the plugin supports for requests and
+	// responses.
+	//
+	// Plugins return this with the Spec when --compressions is specified alongside --spec,
+	// see ClientWithCompression.
+	//
+	// If the Compressions are unknown, this returns nil.
+	Compressions() []Compression
 
 	isSpec()
 }
 
 // NewSpec returns a new validated Spec for the given Procedures.
 func NewSpec(procedures ...Procedure) (Spec, error) {
-	return newSpec(procedures)
+	return newSpec(procedures, nil)
+}
+
+// NewSpecWithOptions returns a new validated Spec for the given Procedures and SpecOptions.
+//
+// Generated <Service>SpecBuilders pass the SpecOptions given to Build to this function.
+func NewSpecWithOptions(procedures []Procedure, options ...SpecOption) (Spec, error) {
+	specOptions := newSpecOptions()
+	for _, option := range options {
+		option(specOptions)
+	}
+	return newSpec(procedures, newFileDescriptorSet(specOptions.fileDescriptorSet, specOptions.fileDescriptors))
+}
+
+// SpecOption is an option for a new Spec.
+type SpecOption func(*specOptions)
+
+// SpecWithDescriptors returns a new SpecOption that includes the given files, and all of
+// their dependencies, in the Spec.
+//
+// Plugins return the files with the Spec when --descriptors is specified alongside --spec,
+// which allows clients to call Procedures without the generated code or proto files of the
+// plugin, for example with a DynamicClient.
+//
+// The given files should define the request and response types of the Procedures, for
+// example the File_<name>_proto variable of a generated package. This option can be
+// specified multiple times, in which case the files are appended.
+func SpecWithDescriptors(fileDescriptors ...protoreflect.FileDescriptor) SpecOption {
+	return func(specOptions *specOptions) {
+		specOptions.fileDescriptors = append(specOptions.fileDescriptors, fileDescriptors...)
+	}
+}
+
+// SpecWithFileDescriptorSet returns a new SpecOption that includes the files of the given
+// FileDescriptorSet in the Spec.
+//
+// This is equivalent to SpecWithDescriptors for descriptors that are not linked into the
+// program, for example a FileDescriptorSet read from a file. The FileDescriptorSet must
+// include all dependencies of its files, in topological order.
+func SpecWithFileDescriptorSet(fileDescriptorSet *descriptorpb.FileDescriptorSet) SpecOption {
+	return func(specOptions *specOptions) {
+		specOptions.fileDescriptorSet = fileDescriptorSet
+	}
 }
 
 // NewSpecForProto returns a new validated Spec for the given pluginrpcv1.Spec.
@@ -59,11 +131,21 @@ func NewSpecForProto(protoSpec *pluginrpcv1.Spec) (Spec, error) {
 }
 
 // NewProtoSpec returns a new pluginrpcv1.Spec for the given Spec.
+//
+// Disabled Procedures are not included. The Procedures that a Procedure was renamed from
+// are included after the Procedure, so that clients can still call them. If all Procedures
+// are disabled, the returned Spec has no Procedures, which clients reject, see NewServer.
 func NewProtoSpec(spec Spec) *pluginrpcv1.Spec {
 	procedures := spec.Procedures()
-	protoProcedures := make([]*pluginrpcv1.Procedure, len(procedures))
-	for i, procedure := range procedures {
-		protoProcedures[i] = NewProtoProcedure(procedure)
+	protoProcedures := make([]*pluginrpcv1.Procedure, 0, len(procedures))
+	for _, procedure := range procedures {
+		if procedure.Disabled() {
+			continue
+		}
+		protoProcedures = append(protoProcedures, NewProtoProcedure(procedure))
+		for _, renamedFrom := range procedure.RenamedFrom() {
+			protoProcedures = append(protoProcedures, NewProtoProcedure(renamedFrom))
+		}
 	}
 	return &pluginrpcv1.Spec{
 		Procedures: protoProcedures,
@@ -75,26 +157,47 @@ func NewProtoSpec(spec Spec) *pluginrpcv1.Spec {
 // Input Specs can be nil. If all input Specs are nil, an error is returned
 // as Specs must have at least one Procedure..
 //
+// The FileDescriptorSets of the Specs are merged, with files of the same name only
+// included once.
+//
 // Returns error if any Procedures overlap by Path or Args.
 func MergeSpecs(specs ...Spec) (Spec, error) {
 	var procedures []Procedure
+	var fileDescriptorSet *descriptorpb.FileDescriptorSet
+	seenFileNames := make(map[string]struct{})
 	for _, spec := range specs {
 		if spec == nil {
 			continue
 		}
 		procedures = append(procedures, spec.Procedures()...)
+		for _, file := range spec.FileDescriptorSet().GetFile() {
+			if _, ok := seenFileNames[file.GetName()]; ok {
+				continue
+			}
+			seenFileNames[file.GetName()] = struct{}{}
+			if fileDescriptorSet == nil {
+				fileDescriptorSet = &descriptorpb.FileDescriptorSet{}
+			}
+			fileDescriptorSet.File = append(fileDescriptorSet.File, file)
+		}
 	}
-	return NewSpec(procedures...)
+	return newSpec(procedures, fileDescriptorSet)
 }
 
 // *** PRIVATE ***
 
 type spec struct {
-	procedures      []Procedure
-	pathToProcedure map[string]Procedure
+	procedures        []Procedure
+	pathToProcedure   map[string]Procedure
+	fileDescriptorSet *descriptorpb.FileDescriptorSet
+	version           string
+	compressions      []Compression
 }
 
-func newSpec(procedures []Procedure) (*spec, error) {
+// newSpec returns a new spec.
+//
+// The fileDescriptorSet may be nil.
+func newSpec(procedures []Procedure, fileDescriptorSet *descriptorpb.FileDescriptorSet) (*spec, error) {
 	if len(procedures) == 0 {
 		return nil, errors.New("no procedures specified")
 	}
@@ -104,10 +207,14 @@ func newSpec(procedures []Procedure) (*spec, error) {
 	pathToProcedure := make(map[string]Procedure)
 	for _, procedure := range procedures {
 		pathToProcedure[procedure.Path()] = procedure
+		for _, renamedFrom := range procedure.RenamedFrom() {
+			pathToProcedure[renamedFrom.Path()] = renamedFrom
+		}
 	}
 	return &spec{
-		procedures:      procedures,
-		pathToProcedure: pathToProcedure,
+		procedures:        procedures,
+		pathToProcedure:   pathToProcedure,
+		fileDescriptorSet: fileDescriptorSet,
 	}, nil
 }
 
@@ -119,4 +226,107 @@ func (s *spec) Procedures() []Procedure {
 	return slices.Clone(s.procedures)
 }
 
+func (s *spec) FileDescriptorSet() *descriptorpb.FileDescriptorSet {
+	return s.fileDescriptorSet
+}
+
+func (s *spec) Version() string {
+	return s.version
+}
+
+func (s *spec) Compressions() []Compression {
+	return slices.Clone(s.compressions)
+}
+
 func (*spec) isSpec() {}
+
+type specOptions struct {
+	fileDescriptorSet *descriptorpb.FileDescriptorSet
+	fileDescriptors   []protoreflect.FileDescriptor
+}
+
+func newSpecOptions() *specOptions {
+	return &specOptions{}
+}
+
+// newSpecForExtProtoSpec returns a new Spec for the given extv1.Spec, as returned
+// when --descriptors, --docs, --version, or --compressions is specified alongside --spec.
+func newSpecForExtProtoSpec(extProtoSpec *extv1.Spec) (Spec, error) {
+	procedures := make([]Procedure, len(extProtoSpec.GetProcedures()))
+	for i, extProtoProcedure := range extProtoSpec.GetProcedures() {
+		procedure, err := NewProcedure(
+			extProtoProcedure.GetPath(),
+			ProcedureWithArgs(extProtoProcedure.GetArgs()...),
+			ProcedureWithDoc(extProtoProcedure.GetDoc()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		procedures[i] = procedure
+	}
+	spec, err := newSpec(procedures, extProtoSpec.GetFileDescriptorSet())
+	if err != nil {
+		return nil, err
+	}
+	spec.version = extProtoSpec.GetVersion()
+	spec.compressions = compressionsForStrings(extProtoSpec.GetCompressions())
+	return spec, nil
+}
+
+// newExtProtoSpec returns a new extv1.Spec for the given Spec, including the descriptors
+// and docs of the Spec if specified.
+//
+// This is wire-compatible with the pluginrpcv1.Spec returned by NewProtoSpec.
+func newExtProtoSpec(spec Spec, includeDescriptors bool, includeDocs bool) *extv1.Spec {
+	extProtoSpec := &extv1.Spec{}
+	for _, procedure := range getCallableProcedures(spec) {
+		extProtoProcedure := &extv1.Procedure{
+			Path: procedure.procedure.Path(),
+			Args: procedure.procedure.Args(),
+		}
+		if includeDocs {
+			extProtoProcedure.Doc = procedure.procedure.Doc()
+		}
+		extProtoSpec.Procedures = append(extProtoSpec.Procedures, extProtoProcedure)
+	}
+	if includeDescriptors {
+		extProtoSpec.FileDescriptorSet = spec.FileDescriptorSet()
+	}
+	return extProtoSpec
+}
+
+// newFileDescriptorSet returns a new FileDescriptorSet for the files of the given
+// FileDescriptorSet followed by the given files and all of their dependencies, in
+// topological order.
+//
+// Returns nil if no files are given.
+func newFileDescriptorSet(
+	fileDescriptorSet *descriptorpb.FileDescriptorSet,
+	fileDescriptors []protoreflect.FileDescriptor,
+) *descriptorpb.FileDescriptorSet {
+	if len(fileDescriptorSet.GetFile()) == 0 && len(fileDescriptors) == 0 {
+		return nil
+	}
+	newFileDescriptorSet := &descriptorpb.FileDescriptorSet{}
+	seenPaths := make(map[string]struct{})
+	for _, file := range fileDescriptorSet.GetFile() {
+		seenPaths[file.GetName()] = struct{}{}
+		newFileDescriptorSet.File = append(newFileDescriptorSet.File, file)
+	}
+	var addFileDescriptor func(protoreflect.FileDescriptor)
+	addFileDescriptor = func(fileDescriptor protoreflect.FileDescriptor) {
+		if _, ok := seenPaths[fileDescriptor.Path()]; ok {
+			return
+		}
+		seenPaths[fileDescriptor.Path()] = struct{}{}
+		imports := fileDescriptor.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			addFileDescriptor(imports.Get(i).FileDescriptor)
+		}
+		newFileDescriptorSet.File = append(newFileDescriptorSet.File, protodesc.ToFileDescriptorProto(fileDescriptor))
+	}
+	for _, fileDescriptor := range fileDescriptors {
+		addFileDescriptor(fileDescriptor)
+	}
+	return newFileDescriptorSet
+}
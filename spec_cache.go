@@ -0,0 +1,149 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+// specCache is an on-disk cache of Specs for Runners that run a program on disk.
+//
+// Entries are keyed by the path, size, and modification time of the program, and the
+// base args given to the program, so that a rebuilt or replaced program results in a
+// cache miss. Errors reading or writing the cache are ignored, as the Spec can always
+// be retrieved from the plugin.
+//
+// A nil *specCache does nothing.
+type specCache struct {
+	dirPath       string
+	programRunner programRunner
+}
+
+// newSpecCache returns a new specCache for the Runner.
+//
+// Returns nil if dirPath is empty or the Runner does not run a program on disk.
+func newSpecCache(dirPath string, runner Runner) *specCache {
+	if dirPath == "" {
+		return nil
+	}
+	programRunner, ok := runner.(programRunner)
+	if !ok {
+		return nil
+	}
+	return &specCache{
+		dirPath:       dirPath,
+		programRunner: programRunner,
+	}
+}
+
+// load returns the cached Spec, if any.
+func (s *specCache) load() (Spec, bool) {
+	filePath, ok := s.filePath()
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false
+	}
+	// Entries are extv1.Specs, which are wire-compatible with pluginrpcv1.Specs, so that
+	// the descriptors, docs, version, and compressions of the plugin are cached if present.
+	extProtoSpec := &extv1.Spec{}
+	if err := proto.Unmarshal(data, extProtoSpec); err != nil {
+		return nil, false
+	}
+	spec, err := newSpecForExtProtoSpec(extProtoSpec)
+	if err != nil {
+		return nil, false
+	}
+	return spec, true
+}
+
+// store caches the Spec.
+func (s *specCache) store(spec Spec) {
+	filePath, ok := s.filePath()
+	if !ok {
+		return
+	}
+	extProtoSpec := newExtProtoSpec(spec, true, true)
+	extProtoSpec.Version = spec.Version()
+	extProtoSpec.Compressions = compressionsToStrings(spec.Compressions())
+	data, err := proto.Marshal(extProtoSpec)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(s.dirPath, 0o755); err != nil {
+		return
+	}
+	// Write to a temporary file and rename it so that concurrent readers never see a
+	// partially-written entry.
+	file, err := os.CreateTemp(s.dirPath, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, writeErr := file.Write(data)
+	closeErr := file.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(file.Name())
+		return
+	}
+	if err := os.Rename(file.Name(), filePath); err != nil {
+		_ = os.Remove(file.Name())
+	}
+}
+
+// remove removes the cached Spec, if any.
+func (s *specCache) remove() {
+	if filePath, ok := s.filePath(); ok {
+		_ = os.Remove(filePath)
+	}
+}
+
+// filePath returns the path of the cache entry for the current program.
+//
+// Returns false if the program cannot be found.
+func (s *specCache) filePath() (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	programPath, err := s.programRunner.programPath()
+	if err != nil {
+		return "", false
+	}
+	fileInfo, err := os.Stat(programPath)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.New()
+	for _, value := range append(
+		[]string{
+			programPath,
+			strconv.FormatInt(fileInfo.Size(), 10),
+			strconv.FormatInt(fileInfo.ModTime().UnixNano(), 10),
+		},
+		s.programRunner.programArgs()...,
+	) {
+		_, _ = hash.Write([]byte(value))
+		_, _ = hash.Write([]byte{0})
+	}
+	return filepath.Join(s.dirPath, hex.EncodeToString(hash.Sum(nil))+".binpb"), true
+}
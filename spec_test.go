@@ -18,6 +18,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestMergeSpecsSuccess(t *testing.T) {
@@ -40,6 +42,36 @@ func TestMergeSpecsSuccess(t *testing.T) {
 	)
 }
 
+func TestMergeSpecsDescriptors(t *testing.T) {
+	t.Parallel()
+
+	procedure1, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	procedure2, err := NewProcedure("/foo/baz")
+	require.NoError(t, err)
+	spec1, err := NewSpecWithOptions(
+		[]Procedure{procedure1},
+		SpecWithDescriptors(durationpb.File_google_protobuf_duration_proto),
+	)
+	require.NoError(t, err)
+	spec2, err := NewSpecWithOptions(
+		[]Procedure{procedure2},
+		SpecWithDescriptors(durationpb.File_google_protobuf_duration_proto, timestamppb.File_google_protobuf_timestamp_proto),
+	)
+	require.NoError(t, err)
+	spec, err := MergeSpecs(spec1, spec2)
+	require.NoError(t, err)
+	var fileNames []string
+	for _, file := range spec.FileDescriptorSet().GetFile() {
+		fileNames = append(fileNames, file.GetName())
+	}
+	require.Equal(t, []string{"google/protobuf/duration.proto", "google/protobuf/timestamp.proto"}, fileNames)
+
+	spec, err = NewSpec(procedure1)
+	require.NoError(t, err)
+	require.Nil(t, spec.FileDescriptorSet())
+}
+
 func TestMergeSpecsErrorOverlappingPaths(t *testing.T) {
 	t.Parallel()
 
@@ -69,3 +101,18 @@ func TestMergeSpecsErrorOverlappingArgs(t *testing.T) {
 	_, err = MergeSpecs(spec1, spec2)
 	require.Error(t, err)
 }
+
+func TestNewProtoSpecExcludesDisabled(t *testing.T) {
+	t.Parallel()
+
+	procedure1, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	procedure2, err := NewProcedure("/foo/baz", ProcedureWithDisabled())
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure1, procedure2)
+	require.NoError(t, err)
+	require.Len(t, spec.Procedures(), 2)
+	protoSpec := NewProtoSpec(spec)
+	require.Len(t, protoSpec.GetProcedures(), 1)
+	require.Equal(t, "/foo/bar", protoSpec.GetProcedures()[0].GetPath())
+}
@@ -0,0 +1,223 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// StdinMode determines how Handlers read requests from stdin.
+type StdinMode uint32
+
+const (
+	// StdinModeAuto reads stdin unless stdin is a terminal, in which case
+	// there is assumed to be no request data.
+	//
+	// This is the default.
+	StdinModeAuto StdinMode = 1
+	// StdinModeRead always reads stdin, even if stdin is a terminal.
+	StdinModeRead StdinMode = 2
+	// StdinModeSkip never reads stdin, and there is assumed to be no request data.
+	StdinModeSkip StdinMode = 3
+
+	minStdinMode = StdinModeAuto
+	maxStdinMode = StdinModeSkip
+)
+
+// String implements fmt.Stringer.
+func (s StdinMode) String() string {
+	switch s {
+	case StdinModeAuto:
+		return "auto"
+	case StdinModeRead:
+		return "read"
+	case StdinModeSkip:
+		return "skip"
+	}
+	return fmt.Sprintf("stdin_mode_%d", s)
+}
+
+// *** PRIVATE ***
+
+const stdinReadChunkSize = 32 * 1024
+
+type stdinReadOptions struct {
+	mode     StdinMode
+	timeout  time.Duration
+	maxBytes int64
+}
+
+func validateStdinMode(stdinMode StdinMode) error {
+	if stdinMode < minStdinMode || stdinMode > maxStdinMode {
+		return fmt.Errorf("unknown StdinMode: %v", stdinMode)
+	}
+	return nil
+}
+
+// readStdin reads the request data from stdin according to the stdinReadOptions.
+//
+// With StdinModeAuto, we handle stdin specially to determine if stdin is a *os.File
+// (likely os.Stdin) and is itself a terminal. If so, we don't block on io.ReadAll, as
+// we know that there is no data in stdin and we can return.
+//
+// This allows server-side implementations of services to not require i.e.:
+//
+//	echo '{}' | plugin-server /pkg.Service/Method
+//
+// Instead allowing to just invoke the following if there is no request data:
+//
+//	plugin-server /pkg.Service/Method
+//
+// If a timeout is set and no data arrives on stdin within the timeout, there is
+// assumed to be no request data. If some data arrives but stdin is not closed within
+// the timeout, the request is incomplete and an error with CodeInvalidArgument is returned.
+//
+// If a maximum number of bytes is set and stdin exceeds it, an error with
+// CodeResourceExhausted is returned without buffering the remainder of stdin.
+//
+// If the context is done before stdin is closed, the context error is returned.
+func readStdin(ctx context.Context, stdin io.Reader, stdinReadOptions stdinReadOptions) ([]byte, error) {
+	switch stdinReadOptions.mode {
+	case StdinModeSkip:
+		return nil, nil
+	case StdinModeAuto:
+		file, ok := stdin.(*os.File)
+		if ok {
+			if isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd()) {
+				// Nothing on stdin
+				return nil, nil
+			}
+		}
+	}
+	if stdinReadOptions.maxBytes > 0 {
+		// Read one more byte than the maximum so that we can detect exceeding it.
+		stdin = io.LimitReader(stdin, stdinReadOptions.maxBytes+1)
+	}
+	var data []byte
+	var err error
+	if stdinReadOptions.timeout <= 0 && ctx.Done() == nil {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = readStdinWithDeadline(ctx, stdin, stdinReadOptions.timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stdinReadOptions.maxBytes > 0 && int64(len(data)) > stdinReadOptions.maxBytes {
+		return nil, NewErrorf(CodeResourceExhausted, "request on stdin exceeds maximum size of %d bytes", stdinReadOptions.maxBytes)
+	}
+	return data, nil
+}
+
+// readStdinWithDeadline reads stdin until EOF, until the timeout elapses, or until the context is done.
+//
+// Reads cannot be interrupted, so on timeout the reading goroutine is left to
+// complete whenever stdin is closed.
+func readStdinWithDeadline(ctx context.Context, stdin io.Reader, timeout time.Duration) ([]byte, error) {
+	reader := &timeoutReader{
+		doneC: make(chan struct{}),
+	}
+	go reader.readAll(stdin)
+	var timerC <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timerC = timer.C
+	}
+	select {
+	case <-reader.doneC:
+		return reader.result()
+	case <-ctx.Done():
+		if _, done := reader.cancel(); done {
+			// Reading completed concurrently with the context being done.
+			return reader.result()
+		}
+		return nil, WrapError(ctx.Err())
+	case <-timerC:
+		received, done := reader.cancel()
+		if done {
+			// Reading completed concurrently with the timeout.
+			return reader.result()
+		}
+		if received > 0 {
+			return nil, NewErrorf(CodeInvalidArgument, "incomplete request: stdin was not closed within %v after receiving %d bytes", timeout, received)
+		}
+		return nil, nil
+	}
+}
+
+type timeoutReader struct {
+	doneC chan struct{}
+
+	buffer   bytes.Buffer
+	err      error
+	done     bool
+	canceled bool
+	lock     sync.Mutex
+}
+
+func (t *timeoutReader) readAll(stdin io.Reader) {
+	defer close(t.doneC)
+	chunk := make([]byte, stdinReadChunkSize)
+	for {
+		n, err := stdin.Read(chunk)
+		t.lock.Lock()
+		if t.canceled {
+			t.lock.Unlock()
+			return
+		}
+		_, _ = t.buffer.Write(chunk[:n])
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.err = err
+			}
+			t.done = true
+			t.lock.Unlock()
+			return
+		}
+		t.lock.Unlock()
+	}
+}
+
+func (t *timeoutReader) result() ([]byte, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.err != nil {
+		return nil, t.err
+	}
+	return t.buffer.Bytes(), nil
+}
+
+// cancel stops recording data, and returns the number of bytes received.
+//
+// If reading has already completed, this returns true and nothing is canceled.
+func (t *timeoutReader) cancel() (int, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.done {
+		return 0, true
+	}
+	t.canceled = true
+	return t.buffer.Len(), false
+}
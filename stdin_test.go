@@ -0,0 +1,93 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadStdinMode(t *testing.T) {
+	t.Parallel()
+
+	data, err := readStdin(context.Background(), bytes.NewReader([]byte("foo")), stdinReadOptions{mode: StdinModeAuto})
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), data)
+	data, err = readStdin(context.Background(), bytes.NewReader([]byte("foo")), stdinReadOptions{mode: StdinModeRead})
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), data)
+	data, err = readStdin(context.Background(), bytes.NewReader([]byte("foo")), stdinReadOptions{mode: StdinModeSkip})
+	require.NoError(t, err)
+	require.Empty(t, data)
+	data, err = readStdin(context.Background(), bytes.NewReader([]byte("foo")), stdinReadOptions{mode: StdinModeRead, timeout: time.Minute})
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), data)
+}
+
+func TestReadStdinTimeout(t *testing.T) {
+	t.Parallel()
+
+	// No data ever arrives, so there is assumed to be no request data.
+	pipeReader, pipeWriter := io.Pipe()
+	t.Cleanup(func() { _ = pipeWriter.Close() })
+	data, err := readStdin(context.Background(), pipeReader, stdinReadOptions{mode: StdinModeRead, timeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+	require.Empty(t, data)
+
+	// Some data arrives, but stdin is never closed.
+	pipeReader, pipeWriter = io.Pipe()
+	t.Cleanup(func() { _ = pipeWriter.Close() })
+	go func() {
+		_, _ = pipeWriter.Write([]byte("foo"))
+	}()
+	data, err = readStdin(context.Background(), pipeReader, stdinReadOptions{mode: StdinModeRead, timeout: 100 * time.Millisecond})
+	require.Empty(t, data)
+	pluginrpcError := &Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeInvalidArgument, pluginrpcError.Code())
+}
+
+func TestReadStdinMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	data, err := readStdin(context.Background(), bytes.NewReader([]byte("foo")), stdinReadOptions{mode: StdinModeRead, maxBytes: 3})
+	require.NoError(t, err)
+	require.Equal(t, []byte("foo"), data)
+	_, err = readStdin(context.Background(), bytes.NewReader([]byte("foobar")), stdinReadOptions{mode: StdinModeRead, maxBytes: 3})
+	pluginrpcError := &Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeResourceExhausted, pluginrpcError.Code())
+	_, err = readStdin(context.Background(), bytes.NewReader([]byte("foobar")), stdinReadOptions{mode: StdinModeRead, timeout: time.Minute, maxBytes: 3})
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeResourceExhausted, pluginrpcError.Code())
+}
+
+func TestReadStdinContext(t *testing.T) {
+	t.Parallel()
+
+	pipeReader, pipeWriter := io.Pipe()
+	t.Cleanup(func() { _ = pipeWriter.Close() })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := readStdin(ctx, pipeReader, stdinReadOptions{mode: StdinModeRead})
+	pluginrpcError := &Error{}
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, CodeDeadlineExceeded, pluginrpcError.Code())
+}
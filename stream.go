@@ -0,0 +1,377 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+const (
+	// frameLengthSize is the size of the length prefix of a frame.
+	frameLengthSize = 4
+	// maxFrameSize is the maximum size of a single frame.
+	//
+	// This guards against treating output that is not a frame sequence as a
+	// very large frame.
+	maxFrameSize = 256 * 1024 * 1024
+)
+
+// BidiStream is a bidirectional stream to a Procedure.
+//
+// BidiStreams are returned from Client.CallBidiStream. The stream ends once the
+// Procedure returns, which typically happens after CloseSend is called. To abort
+// the stream, cancel the context given to Client.CallBidiStream.
+type BidiStream interface {
+	// Send sends a request on the stream.
+	//
+	// If the stream has already ended, io.EOF is returned, and Receive will return
+	// the error the stream ended with.
+	//
+	// Send must not be called concurrently with itself or CloseSend.
+	Send(request any) error
+	// CloseSend closes the sending side of the stream.
+	//
+	// No more requests can be sent after CloseSend is called.
+	CloseSend() error
+	// Receive populates the response with the next response on the stream.
+	//
+	// If the stream ended successfully, io.EOF is returned. Otherwise, the error the
+	// stream ended with is returned.
+	//
+	// Receive must not be called concurrently with itself.
+	Receive(response any) error
+
+	isBidiStream()
+}
+
+// *** PRIVATE ***
+
+// writeFrame writes the data as a single frame, prefixed by its length as a
+// big-endian uint32.
+//
+// Server-streaming Procedures write a sequence of frames to stdout, each containing
+// a Response in the requested format. Every frame except for the last contains a
+// Response value. If the Procedure fails, the last frame contains a Response error.
+func writeFrame(writer io.Writer, data []byte) error {
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("frame of size %d exceeds maximum size of %d", len(data), maxFrameSize)
+	}
+	frame := make([]byte, frameLengthSize, frameLengthSize+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	_, err := writer.Write(append(frame, data...))
+	return err
+}
+
+// frameWriter is an io.Writer that splits written data into frames, calling onFrame
+// for every complete frame as soon as it is written.
+//
+// If onFrame returns an error, Write returns the error, and all further writes fail.
+type frameWriter struct {
+	onFrame func([]byte) error
+	maxSize uint32
+	// onExceeded is called if a frame exceeds maxSize, if non-nil.
+	onExceeded func()
+
+	buffer []byte
+	err    error
+	// exceededErr is the error if a frame exceeded maxSize, if any.
+	exceededErr error
+}
+
+func newFrameWriter(onFrame func([]byte) error) *frameWriter {
+	return &frameWriter{
+		onFrame: onFrame,
+		maxSize: maxFrameSize,
+	}
+}
+
+// withMaxSize limits the size of frames to maxSize, which must be at most maxFrameSize,
+// calling onExceeded if a frame exceeds the limit.
+//
+// Frames that exceed the limit fail with CodeResourceExhausted.
+func (f *frameWriter) withMaxSize(maxSize uint32, onExceeded func()) *frameWriter {
+	f.maxSize = maxSize
+	f.onExceeded = onExceeded
+	return f
+}
+
+func (f *frameWriter) Write(data []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.buffer = append(f.buffer, data...)
+	for len(f.buffer) >= frameLengthSize {
+		frameSize := binary.BigEndian.Uint32(f.buffer)
+		if frameSize > maxFrameSize {
+			f.err = fmt.Errorf("frame of size %d exceeds maximum size of %d, output is likely not a stream", frameSize, maxFrameSize)
+			return 0, f.err
+		}
+		if frameSize > f.maxSize {
+			f.exceededErr = NewErrorf(CodeResourceExhausted, "response of size %d exceeds maximum size of %d bytes", frameSize, f.maxSize)
+			f.err = f.exceededErr
+			if f.onExceeded != nil {
+				f.onExceeded()
+			}
+			return 0, f.err
+		}
+		if uint32(len(f.buffer)-frameLengthSize) < frameSize {
+			break
+		}
+		frame := f.buffer[frameLengthSize : frameLengthSize+int(frameSize)]
+		f.buffer = f.buffer[frameLengthSize+int(frameSize):]
+		if err := f.onFrame(frame); err != nil {
+			f.err = err
+			return 0, f.err
+		}
+	}
+	return len(data), nil
+}
+
+// Close returns an error if a partial frame was written.
+func (f *frameWriter) Close() error {
+	if f.err != nil {
+		return f.err
+	}
+	if len(f.buffer) > 0 {
+		return errors.New("stream ended with an incomplete frame")
+	}
+	return nil
+}
+
+// readFrame reads a single frame as written by writeFrame.
+//
+// Returns io.EOF if the reader ends before the frame begins. If the frame exceeds
+// maxSize, an error with CodeResourceExhausted is returned.
+func readFrame(reader io.Reader, maxSize uint32) ([]byte, error) {
+	var frameLength [frameLengthSize]byte
+	if _, err := io.ReadFull(reader, frameLength[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errors.New("stream ended with an incomplete frame")
+		}
+		return nil, err
+	}
+	frameSize := binary.BigEndian.Uint32(frameLength[:])
+	if frameSize > maxSize {
+		return nil, NewErrorf(CodeResourceExhausted, "frame of size %d exceeds maximum size of %d", frameSize, maxSize)
+	}
+	data := make([]byte, frameSize)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errors.New("stream ended with an incomplete frame")
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// frameReader reads frames in the background, so that waiting for the next frame
+// can be interrupted by a context.
+//
+// Reads cannot be interrupted, so on close the reading goroutine is left to complete
+// whenever the reader returns.
+type frameReader struct {
+	frameC chan frameResult
+	stopC  chan struct{}
+	// err is the error that reading ended with, if any.
+	err error
+}
+
+type frameResult struct {
+	data []byte
+	err  error
+}
+
+func newFrameReader(reader io.Reader, maxSize uint32) *frameReader {
+	frameReader := &frameReader{
+		frameC: make(chan frameResult),
+		stopC:  make(chan struct{}),
+	}
+	go frameReader.readAll(reader, maxSize)
+	return frameReader
+}
+
+// next returns the next frame, or io.EOF if there are no more frames.
+func (f *frameReader) next(ctx context.Context) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	select {
+	case result := <-f.frameC:
+		if result.err != nil {
+			f.err = result.err
+			return nil, f.err
+		}
+		return result.data, nil
+	case <-ctx.Done():
+		return nil, WrapError(ctx.Err())
+	}
+}
+
+func (f *frameReader) close() {
+	close(f.stopC)
+}
+
+func (f *frameReader) readAll(reader io.Reader, maxSize uint32) {
+	for {
+		data, err := readFrame(reader, maxSize)
+		select {
+		case f.frameC <- frameResult{data: data, err: err}:
+		case <-f.stopC:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+type bidiStream struct {
+	format        Format
+	localizeError func(error) error
+	// handleWarning is called for every warning about the call, if non-nil.
+	handleWarning func(*extv1.Warning)
+	stdinWriter   *io.PipeWriter
+	frameC        chan []byte
+	doneC         chan struct{}
+	// runErr is the error that running the plugin resulted in, if any.
+	//
+	// This can only be read after doneC is closed.
+	runErr error
+	// receiveErr is the error that Receive will return from now on, if any.
+	receiveErr error
+}
+
+func newBidiStream(
+	ctx context.Context,
+	runner Runner,
+	format Format,
+	procedurePath string,
+	args []string,
+	maxResponseSize uint32,
+	stderr io.Writer,
+	auditLog *auditLog,
+	callLogger *callLogger,
+	localizeError func(error) error,
+	handleWarning func(*extv1.Warning),
+) *bidiStream {
+	ctx, cancel := context.WithCancel(ctx)
+	stdinReader, stdinWriter := io.Pipe()
+	b := &bidiStream{
+		format:        format,
+		localizeError: localizeError,
+		handleWarning: handleWarning,
+		stdinWriter:   stdinWriter,
+		frameC:        make(chan []byte),
+		doneC:         make(chan struct{}),
+	}
+	go func() {
+		// os/exec does not complete until stdin is closed, so we close stdin
+		// if the context is done to make sure the plugin is released.
+		select {
+		case <-ctx.Done():
+			_ = stdinReader.CloseWithError(ctx.Err())
+		case <-b.doneC:
+		}
+	}()
+	go func() {
+		defer close(b.doneC)
+		defer cancel()
+		stdout := newFrameWriter(
+			func(frame []byte) error {
+				select {
+				case b.frameC <- frame:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		).withMaxSize(maxResponseSize, cancel)
+		loggedCall := callLogger.start(ctx, procedurePath, args)
+		auditInvocation, env := auditLog.start(
+			procedurePath,
+			Env{
+				Args:   args,
+				Stdin:  stdinReader,
+				Stdout: stdout,
+				Stderr: stderr,
+			},
+		)
+		runErr := runner.Run(ctx, env)
+		// Any further sends will fail.
+		_ = stdinReader.Close()
+		switch {
+		case stdout.exceededErr != nil:
+			b.runErr = stdout.exceededErr
+		case runErr != nil:
+			b.runErr = WrapExitError(runErr)
+		default:
+			b.runErr = stdout.Close()
+		}
+		// Errors sent by the Procedure are only seen by Receive, so the record
+		// only reflects errors running the plugin.
+		loggedCall.finish(b.runErr)
+		b.runErr = auditInvocation.finish(b.runErr)
+		close(b.frameC)
+	}()
+	return b
+}
+
+func (b *bidiStream) Send(request any) error {
+	data, err := marshalRequest(b.format, request)
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(b.stdinWriter, data); err != nil {
+		if errors.Is(err, io.ErrClosedPipe) {
+			return io.EOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *bidiStream) CloseSend() error {
+	return b.stdinWriter.Close()
+}
+
+func (b *bidiStream) Receive(response any) error {
+	if b.receiveErr != nil {
+		return b.receiveErr
+	}
+	frame, ok := <-b.frameC
+	if !ok {
+		<-b.doneC
+		b.receiveErr = b.runErr
+		if b.receiveErr == nil {
+			b.receiveErr = io.EOF
+		}
+		return b.receiveErr
+	}
+	if err := unmarshalResponseWithMetadata(b.format, frame, response, nil, b.handleWarning); err != nil {
+		b.receiveErr = b.localizeError(err)
+		// The Procedure has ended, release the plugin if it is waiting on stdin.
+		_ = b.stdinWriter.Close()
+		return b.receiveErr
+	}
+	return nil
+}
+
+func (*bidiStream) isBidiStream() {}
@@ -0,0 +1,102 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameWriter(t *testing.T) {
+	t.Parallel()
+
+	buffer := bytes.NewBuffer(nil)
+	require.NoError(t, writeFrame(buffer, []byte("foo")))
+	require.NoError(t, writeFrame(buffer, nil))
+	require.NoError(t, writeFrame(buffer, []byte("bar")))
+	var frames []string
+	frameWriter := newFrameWriter(
+		func(frame []byte) error {
+			frames = append(frames, string(frame))
+			return nil
+		},
+	)
+	// Write byte by byte to verify frames are reassembled.
+	for _, b := range buffer.Bytes() {
+		_, err := frameWriter.Write([]byte{b})
+		require.NoError(t, err)
+	}
+	require.NoError(t, frameWriter.Close())
+	require.Equal(t, []string{"foo", "", "bar"}, frames)
+
+	frameWriter = newFrameWriter(func([]byte) error { return nil })
+	_, err := frameWriter.Write([]byte{0, 0, 0, 4, 'f'})
+	require.NoError(t, err)
+	require.Error(t, frameWriter.Close())
+}
+
+func TestServerStreamError(t *testing.T) {
+	t.Parallel()
+
+	procedure, err := NewProcedure("/foo/bar")
+	require.NoError(t, err)
+	spec, err := NewSpec(procedure)
+	require.NoError(t, err)
+	handler := NewHandler(spec)
+	serverRegistrar := NewServerRegistrar()
+	serverRegistrar.Register(
+		"/foo/bar",
+		func(ctx context.Context, handleEnv HandleEnv, options ...HandleOption) error {
+			return handler.HandleServerStream(
+				ctx,
+				handleEnv,
+				nil,
+				func(_ context.Context, _ any, send func(any) error) error {
+					if err := send(&pluginrpcv1.Procedure{Path: "/foo"}); err != nil {
+						return err
+					}
+					return NewError(CodeDataLoss, errors.New("failed"))
+				},
+				options...,
+			)
+		},
+	)
+	server, err := NewServer(spec, serverRegistrar)
+	require.NoError(t, err)
+
+	for _, format := range []Format{FormatBinary, FormatJSON} {
+		client := NewClient(NewServerRunner(server), ClientWithFormat(format))
+		var paths []string
+		err = client.CallServerStream(
+			context.Background(),
+			"/foo/bar",
+			nil,
+			func() any { return &pluginrpcv1.Procedure{} },
+			func(response any) error {
+				paths = append(paths, response.(*pluginrpcv1.Procedure).GetPath())
+				return nil
+			},
+		)
+		pluginrpcError := &Error{}
+		require.ErrorAs(t, err, &pluginrpcError)
+		require.Equal(t, CodeDataLoss, pluginrpcError.Code())
+		require.Equal(t, []string{"/foo"}, paths)
+	}
+}
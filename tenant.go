@@ -0,0 +1,87 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"errors"
+)
+
+// TenantMetadataKey is the request metadata key that carries the tenant of a call.
+const TenantMetadataKey = "pluginrpc-tenant"
+
+// Tenant identifies the tenant that a call is made on behalf of, for example a workspace or
+// project of a host that serves multiple tenants with the same plugin.
+type Tenant string
+
+// CallWithTenant returns a new CallOption that tags the call with the given tenant, available
+// to handlers via TenantFromContext.
+//
+// The tenant is sent as request metadata with TenantMetadataKey, so the plugin must support
+// the --metadata flag. To tag every call of a --serve session, pass TenantMetadataKey to
+// ExecRunnerWithSessionMetadata instead.
+//
+// If the tenant is empty, this is a no-op.
+func CallWithTenant(tenant Tenant) CallOption {
+	if tenant == "" {
+		return func(*callOptions) {}
+	}
+	return CallWithMetadata(map[string]string{TenantMetadataKey: string(tenant)})
+}
+
+// TenantFromContext returns the tenant of the call being handled, see CallWithTenant.
+//
+// This is for use within handlers. Returns empty if the client did not send a tenant.
+func TenantFromContext(ctx context.Context) Tenant {
+	metadata, _ := ctx.Value(requestMetadataContextKey{}).(map[string]string)
+	return Tenant(metadata[TenantMetadataKey])
+}
+
+// TenantValidator validates the tenant of a call before the call is handled.
+//
+// The tenant is empty if the client did not send a tenant. procedurePath is the path of the
+// Procedure being called, and is empty if the Handler was not invoked by a Server.
+type TenantValidator func(ctx context.Context, procedurePath string, tenant Tenant) error
+
+// HandlerWithTenantValidator returns a new HandlerOption that validates the tenant of every
+// call with the given TenantValidator after the request is read, and before the call is
+// handled.
+//
+// If the TenantValidator returns an error, the call is not handled, and the error is returned
+// to the client. Errors that are not an *Error are returned with CodePermissionDenied.
+// This option can be specified multiple times, in which case the TenantValidators are
+// called in order.
+func HandlerWithTenantValidator(tenantValidator TenantValidator) HandlerOption {
+	return func(handlerOptions *handlerOptions) {
+		handlerOptions.tenantValidators = append(handlerOptions.tenantValidators, tenantValidator)
+	}
+}
+
+// *** PRIVATE ***
+
+// validateTenant calls the TenantValidators with the tenant of the call.
+func validateTenant(ctx context.Context, procedurePath string, tenantValidators []TenantValidator) error {
+	tenant := TenantFromContext(ctx)
+	for _, tenantValidator := range tenantValidators {
+		if err := tenantValidator(ctx, procedurePath, tenant); err != nil {
+			pluginrpcError := &Error{}
+			if errors.As(err, &pluginrpcError) {
+				return err
+			}
+			return NewError(CodePermissionDenied, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"pluginrpc.com/pluginrpc"
+	examplev1 "pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1"
+	"pluginrpc.com/pluginrpc/internal/example/gen/pluginrpc/example/v1/examplev1pluginrpc"
+)
+
+func TestTenant(t *testing.T) {
+	t.Parallel()
+
+	server, err := examplev1pluginrpc.NewEchoServiceServerForHandler(
+		tenantEchoServiceHandler{},
+		pluginrpc.ServerForHandlerWithHandlerOptions(
+			pluginrpc.HandlerWithTenantValidator(
+				func(_ context.Context, procedurePath string, tenant pluginrpc.Tenant) error {
+					if procedurePath != examplev1pluginrpc.EchoServiceEchoRequestPath {
+						return pluginrpc.NewErrorf(pluginrpc.CodeInternal, "unexpected procedure %q", procedurePath)
+					}
+					switch tenant {
+					case "":
+						return pluginrpc.NewErrorf(pluginrpc.CodeUnauthenticated, "tenant required")
+					case "forbidden":
+						return errors.New("forbidden")
+					}
+					return nil
+				},
+			),
+		),
+	)
+	require.NoError(t, err)
+	echoServiceClient, err := examplev1pluginrpc.NewEchoServiceClient(pluginrpc.NewClient(pluginrpc.NewServerRunner(server)))
+	require.NoError(t, err)
+
+	response, err := echoServiceClient.EchoRequest(
+		context.Background(),
+		&examplev1.EchoRequestRequest{},
+		pluginrpc.CallWithTenant("workspace-1"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "workspace-1", response.GetMessage())
+
+	pluginrpcError := &pluginrpc.Error{}
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{})
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeUnauthenticated, pluginrpcError.Code())
+	// An empty tenant is not sent.
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{}, pluginrpc.CallWithTenant(""))
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodeUnauthenticated, pluginrpcError.Code())
+	_, err = echoServiceClient.EchoRequest(context.Background(), &examplev1.EchoRequestRequest{}, pluginrpc.CallWithTenant("forbidden"))
+	require.ErrorAs(t, err, &pluginrpcError)
+	require.Equal(t, pluginrpc.CodePermissionDenied, pluginrpcError.Code())
+}
+
+type tenantEchoServiceHandler struct {
+	examplev1pluginrpc.UnimplementedEchoServiceHandler
+}
+
+func (tenantEchoServiceHandler) EchoRequest(ctx context.Context, _ *examplev1.EchoRequestRequest) (*examplev1.EchoRequestResponse, error) {
+	return &examplev1.EchoRequestResponse{Message: string(pluginrpc.TenantFromContext(ctx))}, nil
+}
@@ -0,0 +1,31 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unix
+
+package pluginrpc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// prepareTerminate configures the command so that it can be terminated with terminateProcess.
+func prepareTerminate(*exec.Cmd) {}
+
+// terminateProcess asks the process to exit by sending it SIGTERM.
+func terminateProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package pluginrpc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var procGenerateConsoleCtrlEvent = syscall.NewLazyDLL("kernel32.dll").NewProc("GenerateConsoleCtrlEvent")
+
+// prepareTerminate configures the command so that it can be terminated with terminateProcess.
+//
+// CTRL_BREAK_EVENT is sent to every process in a process group, so the command is
+// started in a new process group to not interrupt the current process.
+func prepareTerminate(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// terminateProcess asks the process to exit by sending it CTRL_BREAK_EVENT.
+func terminateProcess(process *os.Process) error {
+	if ok, _, err := procGenerateConsoleCtrlEvent.Call(syscall.CTRL_BREAK_EVENT, uintptr(process.Pid)); ok == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// *** PRIVATE ***
+
+const (
+	// procedureTimingsMinBucket is the upper bound of the first bucket of a procedureTimings histogram.
+	procedureTimingsMinBucket = 100 * time.Microsecond
+	// procedureTimingsNumBuckets is the number of buckets of a procedureTimings histogram.
+	//
+	// Each bucket has twice the upper bound of the previous bucket, and the last bucket
+	// contains all durations above the upper bound of the previous bucket.
+	procedureTimingsNumBuckets = 24
+)
+
+type procedureTimingsContextKey struct{}
+
+// procedureTimings records histograms of the time spent handling each Procedure.
+//
+// See ServerWithProcedureTimings.
+type procedureTimings struct {
+	pathToHistogram map[string]*timingHistogram
+	lock            sync.Mutex
+}
+
+func newProcedureTimings() *procedureTimings {
+	return &procedureTimings{
+		pathToHistogram: make(map[string]*timingHistogram),
+	}
+}
+
+func withProcedureTimings(ctx context.Context, procedureTimings *procedureTimings) context.Context {
+	return context.WithValue(ctx, procedureTimingsContextKey{}, procedureTimings)
+}
+
+func procedureTimingsFromContext(ctx context.Context) (*procedureTimings, bool) {
+	procedureTimings, ok := ctx.Value(procedureTimingsContextKey{}).(*procedureTimings)
+	return procedureTimings, ok
+}
+
+func (p *procedureTimings) record(procedurePath string, duration time.Duration) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	histogram, ok := p.pathToHistogram[procedurePath]
+	if !ok {
+		histogram = &timingHistogram{}
+		p.pathToHistogram[procedurePath] = histogram
+	}
+	histogram.record(duration)
+}
+
+// writeTo writes a table with a summary for every Procedure that was called, sorted by
+// path, followed by a table with the non-empty buckets of the histogram of every Procedure.
+//
+// Percentiles are estimated as the upper bound of the bucket they fall in.
+func (p *procedureTimings) writeTo(writer io.Writer) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.pathToHistogram) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(p.pathToHistogram))
+	for path := range p.pathToHistogram {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	tabWriter := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tabWriter, "PROCEDURE\tCALLS\tMEAN\tP50\tP90\tP99\tMAX"); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		histogram := p.pathToHistogram[path]
+		if _, err := fmt.Fprintf(
+			tabWriter,
+			"%s\t%d\t%v\t%v\t%v\t%v\t%v\n",
+			path,
+			histogram.count,
+			(histogram.sum / time.Duration(histogram.count)).Round(time.Microsecond),
+			histogram.percentile(0.5),
+			histogram.percentile(0.9),
+			histogram.percentile(0.99),
+			histogram.max.Round(time.Microsecond),
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(tabWriter, "\nPROCEDURE\tDURATION\tCALLS"); err != nil {
+		return err
+	}
+	for _, path := range paths {
+		for i, count := range p.pathToHistogram[path].buckets {
+			if count == 0 {
+				continue
+			}
+			bound := "<=" + timingHistogramBucketUpperBound(i).String()
+			if i == procedureTimingsNumBuckets-1 {
+				bound = ">" + timingHistogramBucketUpperBound(i-1).String()
+			}
+			if _, err := fmt.Fprintf(tabWriter, "%s\t%s\t%d\n", path, bound, count); err != nil {
+				return err
+			}
+		}
+	}
+	return tabWriter.Flush()
+}
+
+type timingHistogram struct {
+	buckets [procedureTimingsNumBuckets]uint64
+	count   uint64
+	sum     time.Duration
+	max     time.Duration
+}
+
+func (t *timingHistogram) record(duration time.Duration) {
+	i := 0
+	for i < procedureTimingsNumBuckets-1 && duration > timingHistogramBucketUpperBound(i) {
+		i++
+	}
+	t.buckets[i]++
+	t.count++
+	t.sum += duration
+	if duration > t.max {
+		t.max = duration
+	}
+}
+
+func (t *timingHistogram) percentile(percentile float64) time.Duration {
+	// The nearest rank of the percentile, starting at 1.
+	rank := uint64(math.Ceil(percentile * float64(t.count)))
+	var seen uint64
+	for i, count := range t.buckets {
+		seen += count
+		if seen >= rank {
+			if i == procedureTimingsNumBuckets-1 {
+				return t.max.Round(time.Microsecond)
+			}
+			return min(timingHistogramBucketUpperBound(i), t.max.Round(time.Microsecond))
+		}
+	}
+	return t.max.Round(time.Microsecond)
+}
+
+func timingHistogramBucketUpperBound(i int) time.Duration {
+	return procedureTimingsMinBucket << i
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Buf Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pluginrpc
+
+import (
+	"fmt"
+	"strconv"
+
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+const (
+	// WarningKindDeprecatedProcedure says that the called Procedure is deprecated.
+	//
+	// See ProcedureWithDeprecation.
+	WarningKindDeprecatedProcedure WarningKind = 1
+	// WarningKindDeprecatedFormat says that the Format of the call is deprecated.
+	//
+	// See ServerWithDeprecatedFormat.
+	WarningKindDeprecatedFormat WarningKind = 2
+)
+
+// WarningKind is the kind of a Warning.
+type WarningKind int
+
+// String implements fmt.Stringer.
+func (w WarningKind) String() string {
+	switch w {
+	case WarningKindDeprecatedProcedure:
+		return "deprecated_procedure"
+	case WarningKindDeprecatedFormat:
+		return "deprecated_format"
+	default:
+		return "warning_kind_" + strconv.Itoa(int(w))
+	}
+}
+
+// Warning is a warning from a plugin about a call, for example that the called Procedure
+// is deprecated.
+//
+// Warnings are given to the handler given by ClientWithWarningHandler.
+type Warning struct {
+	// Kind is the kind of the warning.
+	Kind WarningKind
+	// Procedure is the path of the Procedure that was called.
+	Procedure string
+	// Message is a human-readable message describing the warning.
+	Message string
+}
+
+// *** PRIVATE ***
+
+// getWarnings returns the warnings for a call to the Procedure in the given Format.
+//
+// renamedFrom is the Procedure that the Procedure was renamed from if the call used its
+// path or args, or nil otherwise.
+func getWarnings(procedure Procedure, renamedFrom Procedure, format Format, formatToDeprecation map[Format]string) []*extv1.Warning {
+	var warnings []*extv1.Warning
+	if renamedFrom != nil {
+		warnings = append(
+			warnings,
+			&extv1.Warning{
+				Kind:    extv1.WarningKind_WARNING_KIND_DEPRECATED_PROCEDURE,
+				Message: fmt.Sprintf("procedure %q was renamed to %q", renamedFrom.Path(), procedure.Path()),
+			},
+		)
+	}
+	if deprecation := procedure.Deprecation(); deprecation != "" {
+		warnings = append(
+			warnings,
+			&extv1.Warning{
+				Kind:    extv1.WarningKind_WARNING_KIND_DEPRECATED_PROCEDURE,
+				Message: deprecation,
+			},
+		)
+	}
+	if deprecation, ok := formatToDeprecation[format]; ok {
+		warnings = append(
+			warnings,
+			&extv1.Warning{
+				Kind:    extv1.WarningKind_WARNING_KIND_DEPRECATED_FORMAT,
+				Message: deprecation,
+			},
+		)
+	}
+	return warnings
+}
+
+// newWarning returns a new Warning for the extv1.Warning of a call to the given Procedure.
+func newWarning(procedurePath string, protoWarning *extv1.Warning) Warning {
+	return Warning{
+		Kind:      WarningKind(protoWarning.GetKind()),
+		Procedure: procedurePath,
+		Message:   protoWarning.GetMessage(),
+	}
+}
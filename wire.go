@@ -15,9 +15,30 @@
 package pluginrpc
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+
 	pluginrpcv1 "buf.build/gen/go/pluginrpc/pluginrpc/protocolbuffers/go/pluginrpc/v1"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
+	extv1 "pluginrpc.com/pluginrpc/internal/gen/pluginrpc/ext/v1"
+)
+
+const (
+	// binaryHeaderVersion is the version of the binary header.
+	binaryHeaderVersion byte = 1
+)
+
+var (
+	// binaryHeaderMagic is the magic that prefixes binary-format envelopes when the
+	// client opts into the binary header. The full header is the magic followed by
+	// binaryHeaderVersion.
+	//
+	// The first byte is 0x00, which is never a valid first byte of a binary-encoded
+	// Request or Response, as field number 0 is invalid. This allows receivers
+	// to distinguish envelopes with and without the header.
+	binaryHeaderMagic = []byte{0x00, 'p', 'r', 'p', 'c'}
 )
 
 func marshalRequest(format Format, requestValue any) ([]byte, error) {
@@ -52,7 +73,7 @@ func unmarshalRequest(format Format, data []byte, requestValue any) error {
 	}
 	protoRequest := &pluginrpcv1.Request{}
 	if err := codec.Unmarshal(data, protoRequest); err != nil {
-		return err
+		return NewErrorf(CodeInvalidArgument, "stdin is not a valid pluginrpc request in format %q: %w", format.String(), err)
 	}
 	anyRequestValue := protoRequest.GetValue()
 	if anyRequestValue == nil {
@@ -65,9 +86,35 @@ func unmarshalRequest(format Format, data []byte, requestValue any) error {
 	return anypb.UnmarshalTo(anyRequestValue, protoRequestValue, proto.UnmarshalOptions{})
 }
 
-func marshalResponse(format Format, responseValue any, err error) ([]byte, error) {
+// marshalResponse marshals the response value and error.
+//
+// If includeErrorDetails is true and the error has details, the value of the response will be
+// set to an extv1.ErrorDetails if the response value is nil, and to an extv1.PartialResultValue
+// wrapping the response value otherwise. This should only be done if the client specified the
+// --error-details flag, as older clients will otherwise fail to unmarshal the value into the
+// response.
+func marshalResponse(format Format, responseValue any, err error, includeErrorDetails bool) ([]byte, error) {
+	return marshalResponseWithMetadata(format, responseValue, err, includeErrorDetails, nil, nil)
+}
+
+// marshalResponseWithMetadata marshals the response value and error with the given response
+// metadata and warnings.
+//
+// If responseMetadata or warnings are not empty, the value of the response is wrapped in an
+// extv1.MetadataValue. This should only be done if the client specified the --response-metadata
+// or --warnings flag, as older clients will otherwise fail to unmarshal the value into the response.
+func marshalResponseWithMetadata(
+	format Format,
+	responseValue any,
+	err error,
+	includeErrorDetails bool,
+	responseMetadata map[string]string,
+	warnings []*extv1.Warning,
+) ([]byte, error) {
+	pluginrpcError := WrapError(err)
 	var anyResponseValue *anypb.Any
-	if responseValue != nil {
+	switch {
+	case responseValue != nil:
 		protoResponseValue, err := toProtoMessage(responseValue)
 		if err != nil {
 			return nil, err
@@ -76,10 +123,43 @@ func marshalResponse(format Format, responseValue any, err error) ([]byte, error
 		if err != nil {
 			return nil, err
 		}
+		if includeErrorDetails {
+			// A response value alongside an error is a partial result.
+			if protoErrorDetails := pluginrpcError.toProtoErrorDetails(); protoErrorDetails != nil {
+				anyResponseValue, err = anypb.New(
+					&extv1.PartialResultValue{
+						Value:        anyResponseValue,
+						ErrorDetails: protoErrorDetails,
+					},
+				)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	case includeErrorDetails:
+		if protoErrorDetails := pluginrpcError.toProtoErrorDetails(); protoErrorDetails != nil {
+			anyResponseValue, err = anypb.New(protoErrorDetails)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(responseMetadata) > 0 || len(warnings) > 0 {
+		anyResponseValue, err = anypb.New(
+			&extv1.MetadataValue{
+				Value:    anyResponseValue,
+				Metadata: responseMetadata,
+				Warnings: warnings,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 	protoResponse := &pluginrpcv1.Response{
 		Value: anyResponseValue,
-		Error: WrapError(err).ToProto(),
+		Error: pluginrpcError.ToProto(),
 	}
 	codec, err := codecForFormat(format)
 	if err != nil {
@@ -89,6 +169,19 @@ func marshalResponse(format Format, responseValue any, err error) ([]byte, error
 }
 
 func unmarshalResponse(format Format, data []byte, responseValue any) error {
+	return unmarshalResponseWithMetadata(format, data, responseValue, nil, nil)
+}
+
+// unmarshalResponseWithMetadata unmarshals the response value and error, copies any
+// response metadata into the given map if it is non-nil, and calls handleWarning for
+// every warning if it is non-nil.
+func unmarshalResponseWithMetadata(
+	format Format,
+	data []byte,
+	responseValue any,
+	responseMetadata map[string]string,
+	handleWarning func(*extv1.Warning),
+) error {
 	if len(data) == 0 {
 		return nil
 	}
@@ -96,11 +189,57 @@ func unmarshalResponse(format Format, data []byte, responseValue any) error {
 	if err != nil {
 		return err
 	}
+	if format == FormatBinary {
+		data, _, err = stripBinaryHeader(data)
+		if err != nil {
+			return fmt.Errorf("plugin stdout is not a valid pluginrpc response: %w", err)
+		}
+		if len(data) == 0 {
+			return nil
+		}
+	}
 	protoResponse := &pluginrpcv1.Response{}
 	if err := codec.Unmarshal(data, protoResponse); err != nil {
-		return err
+		return fmt.Errorf("plugin stdout is not a valid pluginrpc response in format %q: %w", format.String(), err)
 	}
-	if anyResponseValue := protoResponse.GetValue(); anyResponseValue != nil {
+	protoError := protoResponse.GetError()
+	anyResponseValue := protoResponse.GetValue()
+	if anyResponseValue != nil && anyResponseValue.MessageIs(&extv1.MetadataValue{}) {
+		protoMetadataValue := &extv1.MetadataValue{}
+		if err := anypb.UnmarshalTo(anyResponseValue, protoMetadataValue, proto.UnmarshalOptions{}); err != nil {
+			return err
+		}
+		if responseMetadata != nil {
+			for key, value := range protoMetadataValue.GetMetadata() {
+				responseMetadata[key] = value
+			}
+		}
+		if handleWarning != nil {
+			for _, protoWarning := range protoMetadataValue.GetWarnings() {
+				handleWarning(protoWarning)
+			}
+		}
+		anyResponseValue = protoMetadataValue.GetValue()
+	}
+	if protoError != nil && anyResponseValue != nil && anyResponseValue.MessageIs(&extv1.ErrorDetails{}) {
+		protoErrorDetails := &extv1.ErrorDetails{}
+		if err := anypb.UnmarshalTo(anyResponseValue, protoErrorDetails, proto.UnmarshalOptions{}); err != nil {
+			return err
+		}
+		return NewErrorForProto(protoError).withProtoErrorDetails(protoErrorDetails)
+	}
+	// The details of the error accompanying a partial result, if any.
+	var protoErrorDetails *extv1.ErrorDetails
+	if protoError != nil && anyResponseValue != nil && anyResponseValue.MessageIs(&extv1.PartialResultValue{}) {
+		protoPartialResultValue := &extv1.PartialResultValue{}
+		if err := anypb.UnmarshalTo(anyResponseValue, protoPartialResultValue, proto.UnmarshalOptions{}); err != nil {
+			return err
+		}
+		anyResponseValue = protoPartialResultValue.GetValue()
+		protoErrorDetails = protoPartialResultValue.GetErrorDetails()
+	}
+	// A nil response value discards the response.
+	if anyResponseValue != nil && responseValue != nil {
 		protoResponseValue, err := toProtoMessage(responseValue)
 		if err != nil {
 			return err
@@ -109,8 +248,40 @@ func unmarshalResponse(format Format, data []byte, responseValue any) error {
 			return err
 		}
 	}
-	if protoError := protoResponse.GetError(); protoError != nil {
-		return NewErrorForProto(protoError)
+	if protoError != nil {
+		pluginrpcError := NewErrorForProto(protoError).withProtoErrorDetails(protoErrorDetails)
+		if anyResponseValue != nil {
+			// A response alongside an error is a partial result.
+			pluginrpcError.partialResult = true
+		}
+		return pluginrpcError
 	}
 	return nil
 }
+
+// addBinaryHeader prefixes the data with the binary header.
+func addBinaryHeader(data []byte) []byte {
+	header := append(bytes.Clone(binaryHeaderMagic), binaryHeaderVersion)
+	return append(header, data...)
+}
+
+// stripBinaryHeader strips the binary header from the data if present.
+//
+// Returns true if the header was present. If the data starts with 0x00 but does not
+// have a valid header, an error is returned, as the data cannot be a pluginrpc payload.
+func stripBinaryHeader(data []byte) ([]byte, bool, error) {
+	if len(data) == 0 || data[0] != binaryHeaderMagic[0] {
+		return data, false, nil
+	}
+	if !bytes.HasPrefix(data, binaryHeaderMagic) {
+		return nil, false, errors.New("data does not start with the pluginrpc binary header or a valid binary envelope")
+	}
+	data = data[len(binaryHeaderMagic):]
+	if len(data) == 0 {
+		return nil, false, errors.New("pluginrpc binary header is missing a version")
+	}
+	if version := data[0]; version != binaryHeaderVersion {
+		return nil, false, fmt.Errorf("unsupported pluginrpc binary header version %d", version)
+	}
+	return data[1:], true, nil
+}